@@ -43,7 +43,7 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 	if opt.Remote == "" {
 		return nil, errors.New("alias can't point to an empty remote - check the value of the remote setting")
 	}
-	if strings.HasPrefix(opt.Remote, name+":") {
+	if strings.HasPrefix(opt.Remote, name+":") || strings.HasPrefix(opt.Remote, name+",") {
 		return nil, errors.New("can't point alias remote at itself - check the value of the remote setting")
 	}
 	fsInfo, configName, fsPath, config, err := fs.ConfigFs(opt.Remote)
@@ -351,7 +351,7 @@ func NewFs(name, rootPath string, m configmap.Mapper) (fs.Fs, error) {
 			opt.ChunkTotalSize, opt.ChunkSize, opt.TotalWorkers)
 	}
 
-	if strings.HasPrefix(opt.Remote, name+":") {
+	if strings.HasPrefix(opt.Remote, name+":") || strings.HasPrefix(opt.Remote, name+",") {
 		return nil, errors.New("can't point cache remote at itself - check the value of the remote setting")
 	}
 
@@ -0,0 +1,1303 @@
+// Package chunker provides wrappers for Fs and Object which split large files in chunks
+package chunker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config/configmap"
+	"github.com/ncw/rclone/fs/config/configstruct"
+	"github.com/ncw/rclone/fs/fspath"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/lib/readers"
+	"github.com/pkg/errors"
+)
+
+// Constants
+const (
+	// maxMetadataSize is the maximum size of a metadata object.
+	// Anything bigger than this is considered to be a normal file.
+	maxMetadataSize = 255
+	// metadataVersion is the version of the metadata written
+	metadataVersion = 1
+)
+
+// Register with Fs
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name:        "chunker",
+		Description: "Transparently chunk/split large files",
+		NewFs:       NewFs,
+		Options: []fs.Option{{
+			Name:     "remote",
+			Help:     "Remote to chunk/unchunk.\nNormally should contain a ':' and a path, eg \"myremote:path/to/dir\",\n\"myremote:bucket\" or maybe \"myremote:\" (not recommended).",
+			Required: true,
+		}, {
+			Name:    "chunk_size",
+			Help:    "Files larger than chunk size will be split in chunks.",
+			Default: fs.SizeSuffix(2 * 1024 * 1024 * 1024),
+		}, {
+			Name: "name_format",
+			Help: `String format of chunk file names.
+
+The "*" is replaced by the name of the file being chunked and the run
+of "#" characters by the chunk number, zero padded to the number of
+"#" characters. The format must contain exactly one "*" and one run of
+"#" characters and can't contain a "/".`,
+			Default:  "*.rclone_chunk.###",
+			Advanced: true,
+		}, {
+			Name:     "start_from",
+			Help:     "Minimum valid chunk number. Usually 0 or 1.",
+			Default:  1,
+			Advanced: true,
+		}, {
+			Name: "meta_format",
+			Help: `Format of the metadata object or "none".
+
+The metadata object is a small file stored under the name of the
+original file which records its size, number of chunks and hash.`,
+			Default:  "simplejson",
+			Advanced: true,
+			Examples: []fs.OptionExample{{
+				Value: "none",
+				Help:  "Do not use metadata files at all. Requires hash type \"none\".",
+			}, {
+				Value: "simplejson",
+				Help:  "Simple JSON supports hash sums and chunk validation.",
+			}},
+		}, {
+			Name:    "hash_type",
+			Help:    "Choose how chunker handles hash sums.",
+			Default: "md5",
+			Examples: []fs.OptionExample{{
+				Value: "none",
+				Help:  "Don't support hashes.",
+			}, {
+				Value: "md5",
+				Help:  "MD5 for composite files.",
+			}, {
+				Value: "sha1",
+				Help:  "SHA1 for composite files.",
+			}},
+		}},
+	})
+}
+
+// Options defines the configuration for this backend
+type Options struct {
+	Remote     string        `config:"remote"`
+	ChunkSize  fs.SizeSuffix `config:"chunk_size"`
+	NameFormat string        `config:"name_format"`
+	StartFrom  int           `config:"start_from"`
+	MetaFormat string        `config:"meta_format"`
+	HashType   string        `config:"hash_type"`
+}
+
+// Fs represents a wrapped fs.Fs
+type Fs struct {
+	fs.Fs
+	wrapper    fs.Fs
+	name       string
+	root       string
+	opt        Options
+	features   *fs.Features   // optional features
+	hashType   hash.Type      // hash stored in the metadata for composite files
+	useMeta    bool           // whether metadata objects are used
+	nameFormat string         // Sprintf format for chunk names
+	nameRegexp *regexp.Regexp // regexp to recognise chunk names
+	nameGroup  int            // index of the file name in nameRegexp
+	numGroup   int            // index of the chunk number in nameRegexp
+}
+
+// NewFs constructs an Fs from the path, container:path
+func NewFs(name, rpath string, m configmap.Mapper) (fs.Fs, error) {
+	// Parse config into Options struct
+	opt := new(Options)
+	err := configstruct.Set(m, opt)
+	if err != nil {
+		return nil, err
+	}
+	if opt.ChunkSize <= 0 {
+		return nil, errors.New("chunk_size must be positive")
+	}
+	if opt.StartFrom < 0 {
+		return nil, errors.New("start_from must be non-negative")
+	}
+	remote := opt.Remote
+	if strings.HasPrefix(remote, name+":") || strings.HasPrefix(remote, name+",") {
+		return nil, errors.New("can't point chunker remote at itself - check the value of the remote setting")
+	}
+	baseInfo, baseName, basePath, baseConfig, err := fs.ConfigFs(remote)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse remote %q to wrap", remote)
+	}
+	// Look for a file first
+	remotePath := fspath.JoinRootPath(basePath, rpath)
+	baseFs, err := baseInfo.NewFs(baseName, remotePath, baseConfig)
+	if err != fs.ErrorIsFile && err != nil {
+		return nil, errors.Wrapf(err, "failed to make remote %s:%q to wrap", baseName, remotePath)
+	}
+	f := &Fs{
+		Fs:   baseFs,
+		name: name,
+		root: rpath,
+		opt:  *opt,
+	}
+	// Correct root if definitely pointing to a file
+	if err == fs.ErrorIsFile {
+		f.root = path.Dir(f.root)
+		if f.root == "." || f.root == "/" {
+			f.root = ""
+		}
+	}
+	if err := f.setNameFormat(opt.NameFormat); err != nil {
+		return nil, err
+	}
+	if err := f.setMetaFormat(opt.MetaFormat, opt.HashType); err != nil {
+		return nil, err
+	}
+
+	// the features here are ones we could support, and they are
+	// ANDed with the ones from baseFs
+	f.features = (&fs.Features{
+		CaseInsensitive:         true,
+		DuplicateFiles:          false,
+		ReadMimeType:            false, // MimeTypes not supported with chunking
+		WriteMimeType:           false,
+		BucketBased:             true,
+		CanHaveEmptyDirectories: true,
+		SetTier:                 true,
+		GetTier:                 true,
+	}).Fill(f).Mask(baseFs).WrapsFs(f, baseFs)
+
+	return f, err
+}
+
+// setNameFormat parses the chunk name format into a Sprintf format and
+// a regexp to recognise chunk names with
+func (f *Fs) setNameFormat(pattern string) error {
+	if strings.Count(pattern, "*") != 1 {
+		return errors.Errorf("name_format %q must contain exactly one \"*\"", pattern)
+	}
+	if strings.Contains(pattern, "/") {
+		return errors.Errorf("name_format %q must not contain \"/\"", pattern)
+	}
+	reHashes := regexp.MustCompile("#+")
+	hashes := reHashes.FindAllStringIndex(pattern, -1)
+	if len(hashes) != 1 {
+		return errors.Errorf("name_format %q must contain exactly one run of \"#\"", pattern)
+	}
+	digits := hashes[0][1] - hashes[0][0]
+
+	format := strings.Replace(pattern, "%", "%%", -1)
+	format = strings.Replace(format, "*", "%[1]s", 1)
+	format = reHashes.ReplaceAllLiteralString(format, fmt.Sprintf("%%0%d[2]d", digits))
+
+	re := regexp.QuoteMeta(pattern)
+	re = strings.Replace(re, `\*`, `(.+)`, 1)
+	re = reHashes.ReplaceAllLiteralString(re, fmt.Sprintf(`([0-9]{%d,})`, digits))
+
+	f.nameFormat = format
+	f.nameRegexp = regexp.MustCompile("^" + re + "$")
+	f.nameGroup, f.numGroup = 1, 2
+	if strings.Index(pattern, "*") > hashes[0][0] {
+		f.nameGroup, f.numGroup = 2, 1
+	}
+	return nil
+}
+
+// setMetaFormat checks the metadata format and hash type
+func (f *Fs) setMetaFormat(metaFormat, hashType string) error {
+	switch metaFormat {
+	case "none":
+		f.useMeta = false
+	case "simplejson":
+		f.useMeta = true
+	default:
+		return errors.Errorf("unsupported meta_format %q", metaFormat)
+	}
+	switch hashType {
+	case "none":
+		f.hashType = hash.None
+	case "md5":
+		f.hashType = hash.MD5
+	case "sha1":
+		f.hashType = hash.SHA1
+	default:
+		return errors.Errorf("unsupported hash_type %q", hashType)
+	}
+	if f.hashType != hash.None && !f.useMeta {
+		return errors.Errorf("hash_type %q requires meta_format to be set", hashType)
+	}
+	return nil
+}
+
+// makeChunkName makes the name of chunk chunkNo for mainRemote
+func (f *Fs) makeChunkName(mainRemote string, chunkNo int) string {
+	dir, leaf := path.Split(mainRemote)
+	return dir + fmt.Sprintf(f.nameFormat, leaf, chunkNo+f.opt.StartFrom)
+}
+
+// parseChunkName checks whether remote is the name of a chunk and if
+// so returns the name of the file it belongs to and the chunk
+// number. It returns "", -1 if remote isn't a chunk name.
+func (f *Fs) parseChunkName(remote string) (mainRemote string, chunkNo int) {
+	dir, leaf := path.Split(remote)
+	match := f.nameRegexp.FindStringSubmatch(leaf)
+	if match == nil {
+		return "", -1
+	}
+	chunkNo, err := strconv.Atoi(match[f.numGroup])
+	if err != nil || chunkNo < f.opt.StartFrom {
+		return "", -1
+	}
+	return dir + match[f.nameGroup], chunkNo - f.opt.StartFrom
+}
+
+// Name of the remote (as passed into NewFs)
+func (f *Fs) Name() string {
+	return f.name
+}
+
+// Root of the remote (as passed into NewFs)
+func (f *Fs) Root() string {
+	return f.root
+}
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features {
+	return f.features
+}
+
+// String returns a description of the FS
+func (f *Fs) String() string {
+	return fmt.Sprintf("Chunked '%s:%s'", f.name, f.root)
+}
+
+// Hashes returns the supported hash sets.
+func (f *Fs) Hashes() hash.Set {
+	return hash.Set(f.hashType)
+}
+
+// setChunkSize changes the chunk size returning the old one
+func (f *Fs) setChunkSize(cs fs.SizeSuffix) (old fs.SizeSuffix, err error) {
+	if cs <= 0 {
+		return f.opt.ChunkSize, errors.Errorf("chunk size %v must be positive", cs)
+	}
+	old, f.opt.ChunkSize = f.opt.ChunkSize, cs
+	return old, nil
+}
+
+// processEntries assembles the chunks found in entries into composite
+// objects, returning the new entries.
+func (f *Fs) processEntries(ctx context.Context, entries fs.DirEntries) (newEntries fs.DirEntries, err error) {
+	var (
+		objects  []*Object
+		byRemote = make(map[string]*Object)
+	)
+	get := func(remote string) *Object {
+		o := byRemote[remote]
+		if o == nil {
+			o = f.newObject(remote, nil, nil)
+			byRemote[remote] = o
+			objects = append(objects, o)
+		}
+		return o
+	}
+	newEntries = entries[:0] // in place filter
+	for _, entry := range entries {
+		switch x := entry.(type) {
+		case fs.Object:
+			if mainRemote, chunkNo := f.parseChunkName(x.Remote()); mainRemote != "" {
+				get(mainRemote).addChunk(x, chunkNo)
+			} else {
+				get(x.Remote()).main = x
+			}
+		case fs.Directory:
+			newEntries = append(newEntries, x)
+		default:
+			return nil, errors.Errorf("Unknown object type %T", entry)
+		}
+	}
+	for _, o := range objects {
+		if err := o.validate(); err != nil {
+			fs.Errorf(o, "Skipping invalid composite file: %v", err)
+			continue
+		}
+		newEntries = append(newEntries, o)
+	}
+	return newEntries, nil
+}
+
+// List the objects and directories in dir into entries.  The
+// entries can be returned in any order but should be for a
+// complete directory.
+//
+// dir should be "" to list the root, and should not have
+// trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	entries, err = f.Fs.List(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	return f.processEntries(ctx, entries)
+}
+
+// ListR lists the objects and directories of the Fs starting
+// from dir recursively into out.
+//
+// dir should be "" to start from the root, and should not
+// have trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+//
+// It should call callback for each tranche of entries read.
+// These need not be returned in any particular order.  If
+// callback returns an error then the listing will stop
+// immediately.
+//
+// Chunks of a file may be returned in different tranches by the
+// wrapped remote so all the entries are collected before any are
+// returned.
+func (f *Fs) ListR(ctx context.Context, dir string, callback fs.ListRCallback) (err error) {
+	var entries fs.DirEntries
+	err = f.Fs.Features().ListR(ctx, dir, func(tranche fs.DirEntries) error {
+		entries = append(entries, tranche...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	entries, err = f.processEntries(ctx, entries)
+	if err != nil {
+		return err
+	}
+	return callback(entries)
+}
+
+// NewObject finds the Object at remote.
+func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	if mainRemote, _ := f.parseChunkName(remote); mainRemote != "" {
+		return nil, fs.ErrorObjectNotFound
+	}
+	main, err := f.Fs.NewObject(ctx, remote)
+	if err != nil && err != fs.ErrorObjectNotFound {
+		return nil, err
+	}
+	o := f.newObject(remote, main, nil)
+	// A file too big to be metadata can't be part of a composite
+	// so there is no need to look for chunks
+	if main != nil && (!f.useMeta || main.Size() > maxMetadataSize) {
+		return o, nil
+	}
+	dir := path.Dir(remote)
+	if dir == "." {
+		dir = ""
+	}
+	entries, err := f.Fs.List(ctx, dir)
+	if err == fs.ErrorDirNotFound {
+		entries, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		chunk, ok := entry.(fs.Object)
+		if !ok {
+			continue
+		}
+		if mainRemote, chunkNo := f.parseChunkName(chunk.Remote()); mainRemote == remote {
+			o.addChunk(chunk, chunkNo)
+		}
+	}
+	if main == nil && len(o.chunks) == 0 {
+		return nil, fs.ErrorObjectNotFound
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// put uploads in to remote splitting it into chunks if it is larger
+// than the chunk size.
+//
+// If old is not nil it is the object being replaced and any of its
+// chunks which are no longer needed are removed.
+func (f *Fs) put(ctx context.Context, in io.Reader, src fs.ObjectInfo, remote string, options []fs.OpenOption, old *Object) (*Object, error) {
+	size := src.Size()
+	chunkSize := int64(f.opt.ChunkSize)
+
+	// Small files are stored as they are
+	if size >= 0 && size <= chunkSize {
+		var existing fs.Object
+		if old != nil {
+			existing = old.main
+		}
+		main, err := f.putOne(ctx, in, f.wrapInfo(src, remote, size), existing, f.Fs.Put, options)
+		if err != nil {
+			return nil, err
+		}
+		o := f.newObject(remote, main, nil)
+		return o, f.removeOld(ctx, old, o)
+	}
+
+	basePut := f.Fs.Put
+	if size < 0 {
+		basePut = f.Fs.Features().PutStream
+		if basePut == nil {
+			return nil, errors.New("can't upload files of unknown size to this remote")
+		}
+	}
+
+	var hasher *hash.MultiHasher
+	if f.hashType != hash.None {
+		var err error
+		hasher, err = hash.NewMultiHasherTypes(hash.NewHashSet(f.hashType))
+		if err != nil {
+			return nil, err
+		}
+		in = io.TeeReader(in, hasher)
+	}
+	buf := bufio.NewReader(in)
+
+	var (
+		chunks    []fs.Object // chunks uploaded so far
+		isNew     []bool      // whether the chunk was newly created
+		sizeTotal int64
+	)
+	rollback := func() {
+		for i, chunk := range chunks {
+			if !isNew[i] {
+				continue
+			}
+			if err := chunk.Remove(ctx); err != nil {
+				fs.Errorf(chunk, "Failed to remove chunk after failed upload: %v", err)
+			}
+		}
+	}
+	for chunkNo := 0; ; chunkNo++ {
+		thisSize := int64(-1)
+		if size >= 0 {
+			if sizeTotal >= size {
+				break
+			}
+			thisSize = size - sizeTotal
+			if thisSize > chunkSize {
+				thisSize = chunkSize
+			}
+		} else if _, err := buf.Peek(1); err == io.EOF {
+			break
+		} else if err != nil {
+			rollback()
+			return nil, err
+		}
+		var existing fs.Object
+		if old != nil && chunkNo < len(old.chunks) {
+			existing = old.chunks[chunkNo]
+		}
+		chunkRemote := f.makeChunkName(remote, chunkNo)
+		chunkIn := readers.NewCountingReader(io.LimitReader(buf, chunkSize))
+		chunk, err := f.putOne(ctx, chunkIn, f.wrapInfo(src, chunkRemote, thisSize), existing, basePut, options)
+		if chunk != nil {
+			chunks = append(chunks, chunk)
+			isNew = append(isNew, existing == nil)
+		}
+		if err == nil && thisSize >= 0 && int64(chunkIn.BytesRead()) != thisSize {
+			err = errors.Errorf("chunk %d: read %d bytes expecting %d", chunkNo, chunkIn.BytesRead(), thisSize)
+		}
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+		sizeTotal += int64(chunkIn.BytesRead())
+	}
+
+	// If a stream turned out to fit in a single chunk then rename
+	// the chunk to the file name if possible
+	if size < 0 && len(chunks) <= 1 {
+		if do := f.Fs.Features().Move; do != nil && len(chunks) == 1 {
+			if old != nil && old.main != nil {
+				if err := old.main.Remove(ctx); err != nil {
+					rollback()
+					return nil, err
+				}
+				old.main = nil
+			}
+			main, err := do(ctx, chunks[0], remote)
+			if err != nil {
+				rollback()
+				return nil, err
+			}
+			// the first old chunk, if any, has just been moved
+			if old != nil && len(old.chunks) > 0 {
+				old.chunks = old.chunks[1:]
+			}
+			o := f.newObject(remote, main, nil)
+			return o, f.removeOld(ctx, old, o)
+		}
+		if len(chunks) == 0 {
+			return f.put(ctx, bytes.NewReader(nil), f.wrapInfo(src, remote, 0), remote, options, old)
+		}
+	}
+
+	o := f.newObject(remote, nil, chunks)
+	o.size = sizeTotal
+	if hasher != nil {
+		sums := hasher.Sums()
+		o.md5, o.sha1 = sums[hash.MD5], sums[hash.SHA1]
+	}
+	o.metaLoaded = true
+
+	// Write the metadata object
+	if f.useMeta {
+		meta, err := marshalSimpleJSON(o)
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+		var existing fs.Object
+		if old != nil {
+			existing = old.main
+		}
+		o.main, err = f.putOne(ctx, bytes.NewReader(meta), f.wrapInfo(src, remote, int64(len(meta))), existing, f.Fs.Put, options)
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+	}
+	return o, f.removeOld(ctx, old, o)
+}
+
+// putOne uploads in updating existing if it is not nil or using put
+// to create a new object otherwise
+func (f *Fs) putOne(ctx context.Context, in io.Reader, src fs.ObjectInfo, existing fs.Object, put putFn, options []fs.OpenOption) (fs.Object, error) {
+	if existing != nil {
+		return existing, existing.Update(ctx, in, src, options...)
+	}
+	return put(ctx, in, src, options...)
+}
+
+type putFn func(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error)
+
+// removeOld removes the parts of old which aren't used by o
+func (f *Fs) removeOld(ctx context.Context, old *Object, o *Object) (err error) {
+	if old == nil {
+		return nil
+	}
+	if old.main != nil && o.main == nil {
+		err = old.main.Remove(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to remove old file")
+		}
+	}
+	for _, chunk := range old.chunks[len(o.chunks):] {
+		err = chunk.Remove(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to remove old chunk")
+		}
+	}
+	return nil
+}
+
+// Put in to the remote path with the modTime given of the given size
+//
+// May create the object even if it returns an error - if so
+// will return the object and the error, otherwise will return
+// nil and the error
+func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	o, err := f.NewObject(ctx, src.Remote())
+	switch err {
+	case nil:
+		return o, o.Update(ctx, in, src, options...)
+	case fs.ErrorObjectNotFound:
+		return f.put(ctx, in, src, src.Remote(), options, nil)
+	default:
+		return nil, err
+	}
+}
+
+// PutStream uploads to the remote path with the modTime given of indeterminate size
+func (f *Fs) PutStream(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	return f.Put(ctx, in, src, options...)
+}
+
+// Purge all files in the root and the root directory
+//
+// Implement this if you have a way of deleting all the files
+// quicker than just running Remove() on the result of List()
+//
+// Return an error if it doesn't exist
+func (f *Fs) Purge(ctx context.Context) error {
+	do := f.Fs.Features().Purge
+	if do == nil {
+		return fs.ErrorCantPurge
+	}
+	return do(ctx)
+}
+
+type copyMoveFn func(ctx context.Context, src fs.Object, remote string) (fs.Object, error)
+
+// copyOrMove copies or moves all the parts of src to remote using do
+func (f *Fs) copyOrMove(ctx context.Context, src *Object, remote string, do copyMoveFn, opName string) (fs.Object, error) {
+	old, err := f.NewObject(ctx, remote)
+	if err != nil && err != fs.ErrorObjectNotFound {
+		return nil, err
+	}
+	o := f.newObject(remote, nil, nil)
+	o.size, o.md5, o.sha1, o.metaLoaded = src.size, src.md5, src.sha1, src.metaLoaded
+	for chunkNo, chunk := range src.chunks {
+		newChunk, err := do(ctx, chunk, f.makeChunkName(remote, chunkNo))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to %s chunk %d", opName, chunkNo)
+		}
+		o.chunks = append(o.chunks, newChunk)
+	}
+	if src.main != nil {
+		o.main, err = do(ctx, src.main, remote)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !o.isComposite() {
+		o.size = o.main.Size()
+	}
+	if old != nil {
+		err = f.removeOld(ctx, old.(*Object), o)
+	}
+	return o, err
+}
+
+// Copy src to this remote using server side copy operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantCopy
+func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	do := f.Fs.Features().Copy
+	if do == nil {
+		return nil, fs.ErrorCantCopy
+	}
+	o, ok := src.(*Object)
+	if !ok {
+		return nil, fs.ErrorCantCopy
+	}
+	return f.copyOrMove(ctx, o, remote, do, "copy")
+}
+
+// Move src to this remote using server side move operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantMove
+func (f *Fs) Move(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	do := f.Fs.Features().Move
+	if do == nil {
+		return nil, fs.ErrorCantMove
+	}
+	o, ok := src.(*Object)
+	if !ok {
+		return nil, fs.ErrorCantMove
+	}
+	return f.copyOrMove(ctx, o, remote, do, "move")
+}
+
+// DirMove moves src, srcRemote to this remote at dstRemote
+// using server side move operations.
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantDirMove
+//
+// If destination exists then return fs.ErrorDirExists
+func (f *Fs) DirMove(ctx context.Context, src fs.Fs, srcRemote, dstRemote string) error {
+	do := f.Fs.Features().DirMove
+	if do == nil {
+		return fs.ErrorCantDirMove
+	}
+	srcFs, ok := src.(*Fs)
+	if !ok {
+		fs.Debugf(srcFs, "Can't move directory - not same remote type")
+		return fs.ErrorCantDirMove
+	}
+	return do(ctx, srcFs.Fs, srcRemote, dstRemote)
+}
+
+// CleanUp the trash in the Fs
+//
+// Implement this if you have a way of emptying the trash or
+// otherwise cleaning up old versions of files.
+func (f *Fs) CleanUp(ctx context.Context) error {
+	do := f.Fs.Features().CleanUp
+	if do == nil {
+		return errors.New("can't CleanUp")
+	}
+	return do(ctx)
+}
+
+// About gets quota information from the Fs
+func (f *Fs) About(ctx context.Context) (*fs.Usage, error) {
+	do := f.Fs.Features().About
+	if do == nil {
+		return nil, errors.New("About not supported")
+	}
+	return do(ctx)
+}
+
+// UnWrap returns the Fs that this Fs is wrapping
+func (f *Fs) UnWrap() fs.Fs {
+	return f.Fs
+}
+
+// WrapFs returns the Fs that is wrapping this Fs
+func (f *Fs) WrapFs() fs.Fs {
+	return f.wrapper
+}
+
+// SetWrapper sets the Fs that is wrapping this Fs
+func (f *Fs) SetWrapper(wrapper fs.Fs) {
+	f.wrapper = wrapper
+}
+
+// MergeDirs merges the contents of all the directories passed
+// in into the first one and rmdirs the other directories.
+func (f *Fs) MergeDirs(ctx context.Context, dirs []fs.Directory) error {
+	do := f.Fs.Features().MergeDirs
+	if do == nil {
+		return errors.New("MergeDirs not supported")
+	}
+	return do(ctx, dirs)
+}
+
+// DirCacheFlush resets the directory cache - used in testing
+// as an optional interface
+func (f *Fs) DirCacheFlush() {
+	do := f.Fs.Features().DirCacheFlush
+	if do != nil {
+		do()
+	}
+}
+
+// PublicLink generates a public link to the remote path (usually readable by anyone)
+//
+// This is only possible for files which haven't been chunked.
+func (f *Fs) PublicLink(ctx context.Context, remote string) (string, error) {
+	do := f.Fs.Features().PublicLink
+	if do == nil {
+		return "", errors.New("PublicLink not supported")
+	}
+	o, err := f.NewObject(ctx, remote)
+	if err != nil {
+		// assume it is a directory
+		return do(ctx, remote)
+	}
+	if o.(*Object).isComposite() {
+		return "", errors.New("can't make a public link to a chunked file")
+	}
+	return do(ctx, remote)
+}
+
+// ChangeNotify calls the passed function with a path
+// that has had changes. If the implementation
+// uses polling, it should adhere to the given interval.
+func (f *Fs) ChangeNotify(ctx context.Context, notifyFunc func(string, fs.EntryType), pollIntervalChan <-chan time.Duration) {
+	do := f.Fs.Features().ChangeNotify
+	if do == nil {
+		return
+	}
+	wrappedNotifyFunc := func(path string, entryType fs.EntryType) {
+		if entryType == fs.EntryObject {
+			if mainRemote, _ := f.parseChunkName(path); mainRemote != "" {
+				path = mainRemote
+			}
+		}
+		notifyFunc(path, entryType)
+	}
+	do(ctx, wrappedNotifyFunc, pollIntervalChan)
+}
+
+// Object represents either a normal file stored as it is on the
+// wrapped remote or a composite file made of chunks and an optional
+// metadata object.
+type Object struct {
+	f          *Fs
+	remote     string
+	main       fs.Object   // the normal file or the metadata object - may be nil
+	chunks     []fs.Object // the chunks if this is a composite file
+	size       int64       // size of the composite file
+	md5        string      // MD5 of the composite file if known
+	sha1       string      // SHA1 of the composite file if known
+	metaLoaded bool        // set if the metadata has been read
+}
+
+func (f *Fs) newObject(remote string, main fs.Object, chunks []fs.Object) *Object {
+	return &Object{
+		f:      f,
+		remote: remote,
+		main:   main,
+		chunks: chunks,
+	}
+}
+
+// addChunk adds chunk as chunk number chunkNo of the object
+func (o *Object) addChunk(chunk fs.Object, chunkNo int) {
+	for len(o.chunks) <= chunkNo {
+		o.chunks = append(o.chunks, nil)
+	}
+	o.chunks[chunkNo] = chunk
+}
+
+// validate checks the chunks of the object and works out its size
+func (o *Object) validate() error {
+	if len(o.chunks) > 0 && o.main != nil && (!o.f.useMeta || o.main.Size() > maxMetadataSize) {
+		fs.Debugf(o, "Ignoring %d chunks of normal file", len(o.chunks))
+		o.chunks = nil
+	}
+	if !o.isComposite() {
+		return nil
+	}
+	o.size = 0
+	for chunkNo, chunk := range o.chunks {
+		if chunk == nil {
+			return errors.Errorf("chunk %d missing", chunkNo)
+		}
+		o.size += chunk.Size()
+	}
+	return nil
+}
+
+// isComposite returns true if the object is made of chunks
+func (o *Object) isComposite() bool {
+	return len(o.chunks) > 0
+}
+
+// mainChunk returns the normal file, the metadata object or the first
+// chunk of the object
+func (o *Object) mainChunk() fs.Object {
+	if o.main != nil {
+		return o.main
+	}
+	return o.chunks[0]
+}
+
+// Fs returns read only access to the Fs that this object is part of
+func (o *Object) Fs() fs.Info {
+	return o.f
+}
+
+// Return a string version
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.remote
+}
+
+// Remote returns the remote path
+func (o *Object) Remote() string {
+	return o.remote
+}
+
+// Size returns the size of the file
+func (o *Object) Size() int64 {
+	if o.isComposite() {
+		return o.size
+	}
+	return o.main.Size()
+}
+
+// ModTime returns the modification time of the file
+func (o *Object) ModTime(ctx context.Context) time.Time {
+	return o.mainChunk().ModTime(ctx)
+}
+
+// SetModTime sets the modification time of the file
+func (o *Object) SetModTime(ctx context.Context, mtime time.Time) error {
+	return o.mainChunk().SetModTime(ctx, mtime)
+}
+
+// Storable returns whether object is storable
+func (o *Object) Storable() bool {
+	return o.mainChunk().Storable()
+}
+
+// Hash returns the selected checksum of the file
+// If no checksum is available it returns ""
+func (o *Object) Hash(ctx context.Context, ht hash.Type) (string, error) {
+	if ht != o.f.hashType || ht == hash.None {
+		return "", hash.ErrUnsupported
+	}
+	if !o.isComposite() {
+		sum, err := o.main.Hash(ctx, ht)
+		if err == hash.ErrUnsupported {
+			return "", nil
+		}
+		return sum, err
+	}
+	if err := o.readMetadata(ctx); err != nil {
+		return "", err
+	}
+	switch ht {
+	case hash.MD5:
+		return o.md5, nil
+	case hash.SHA1:
+		return o.sha1, nil
+	}
+	return "", nil
+}
+
+// metaSimpleJSON is the format of the metadata object
+type metaSimpleJSON struct {
+	Version int    `json:"ver"`
+	Size    int64  `json:"size"`
+	NChunks int    `json:"nchunks"`
+	MD5     string `json:"md5,omitempty"`
+	SHA1    string `json:"sha1,omitempty"`
+}
+
+// marshalSimpleJSON makes the metadata for o
+func marshalSimpleJSON(o *Object) ([]byte, error) {
+	return json.Marshal(&metaSimpleJSON{
+		Version: metadataVersion,
+		Size:    o.size,
+		NChunks: len(o.chunks),
+		MD5:     o.md5,
+		SHA1:    o.sha1,
+	})
+}
+
+// readMetadata reads the metadata object of a composite file if
+// there is one
+func (o *Object) readMetadata(ctx context.Context) (err error) {
+	if o.metaLoaded || o.main == nil {
+		return nil
+	}
+	in, err := o.main.Open(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to open metadata")
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(in, maxMetadataSize+1))
+	fs.CheckClose(in, &err)
+	if err != nil {
+		return errors.Wrap(err, "failed to read metadata")
+	}
+	var meta metaSimpleJSON
+	err = json.Unmarshal(data, &meta)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse metadata")
+	}
+	if meta.Version > metadataVersion {
+		return errors.Errorf("metadata version %d not supported", meta.Version)
+	}
+	if meta.NChunks != len(o.chunks) || meta.Size != o.size {
+		return errors.Errorf("metadata doesn't match chunks: %d chunks %d bytes vs %d chunks %d bytes", meta.NChunks, meta.Size, len(o.chunks), o.size)
+	}
+	o.md5, o.sha1 = meta.MD5, meta.SHA1
+	o.metaLoaded = true
+	return nil
+}
+
+// Open opens the file for read.  Call Close() on the returned io.ReadCloser
+func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (rc io.ReadCloser, err error) {
+	if !o.isComposite() {
+		return o.main.Open(ctx, options...)
+	}
+	var openOptions []fs.OpenOption
+	var offset, limit int64 = 0, -1
+	for _, option := range options {
+		switch x := option.(type) {
+		case *fs.SeekOption:
+			offset = x.Offset
+		case *fs.RangeOption:
+			offset, limit = x.Decode(o.size)
+		default:
+			// pass on Options to underlying open if appropriate
+			openOptions = append(openOptions, option)
+		}
+	}
+	if offset < 0 {
+		return nil, errors.New("invalid offset")
+	}
+	if limit < 0 || offset+limit > o.size {
+		limit = o.size - offset
+	}
+	return o.newLinearReader(ctx, offset, limit, openOptions), nil
+}
+
+// linearReader reads a range of a composite file chunk by chunk
+type linearReader struct {
+	ctx     context.Context
+	chunks  []fs.Object
+	options []fs.OpenOption
+	chunkNo int           // the chunk being read
+	offset  int64         // offset to start reading the chunk at
+	limit   int64         // number of bytes left to read
+	in      io.ReadCloser // the open chunk - may be nil
+	err     error         // sticky error
+}
+
+func (o *Object) newLinearReader(ctx context.Context, offset, limit int64, options []fs.OpenOption) *linearReader {
+	r := &linearReader{
+		ctx:     ctx,
+		chunks:  o.chunks,
+		options: options,
+		limit:   limit,
+	}
+	for r.chunkNo < len(r.chunks) && offset >= r.chunks[r.chunkNo].Size() {
+		offset -= r.chunks[r.chunkNo].Size()
+		r.chunkNo++
+	}
+	r.offset = offset
+	return r
+}
+
+// openChunk opens the current chunk
+func (r *linearReader) openChunk() (err error) {
+	chunk := r.chunks[r.chunkNo]
+	options := r.options
+	end := r.offset + r.limit - 1
+	if end >= chunk.Size()-1 {
+		end = -1
+	}
+	if r.offset > 0 || end >= 0 {
+		options = append(options[:len(options):len(options)], &fs.RangeOption{Start: r.offset, End: end})
+	}
+	r.in, err = chunk.Open(r.ctx, options...)
+	return err
+}
+
+// Read bytes from the chunks
+func (r *linearReader) Read(p []byte) (n int, err error) {
+	for r.err == nil && n == 0 {
+		if r.limit <= 0 || r.chunkNo >= len(r.chunks) {
+			r.err = io.EOF
+			break
+		}
+		if r.in == nil {
+			r.err = r.openChunk()
+			if r.err != nil {
+				break
+			}
+		}
+		if int64(len(p)) > r.limit {
+			p = p[:r.limit]
+		}
+		n, err = r.in.Read(p)
+		r.offset += int64(n)
+		r.limit -= int64(n)
+		if err == io.EOF {
+			err = r.in.Close()
+			r.in = nil
+			r.chunkNo++
+			r.offset = 0
+		}
+		r.err = err
+	}
+	if n > 0 {
+		return n, nil
+	}
+	return 0, r.err
+}
+
+// Close the reader
+func (r *linearReader) Close() error {
+	if r.in == nil {
+		return nil
+	}
+	err := r.in.Close()
+	r.in = nil
+	return err
+}
+
+// Update in to the object with the modTime given of the given size
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	newO, err := o.f.put(ctx, in, src, o.remote, options, o)
+	if newO != nil {
+		*o = *newO
+	}
+	return err
+}
+
+// Remove an object
+func (o *Object) Remove(ctx context.Context) (err error) {
+	if o.main != nil {
+		err = o.main.Remove(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	for _, chunk := range o.chunks {
+		err = chunk.Remove(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnWrap returns the wrapped Object
+func (o *Object) UnWrap() fs.Object {
+	return o.mainChunk()
+}
+
+// MimeType returns the content type of the Object if
+// known, or "" if not
+func (o *Object) MimeType(ctx context.Context) string {
+	if !o.isComposite() {
+		if do, ok := o.main.(fs.MimeTyper); ok {
+			return do.MimeType(ctx)
+		}
+	}
+	return fs.MimeTypeFromName(o.remote)
+}
+
+// ID returns the ID of the Object if known, or "" if not
+func (o *Object) ID() string {
+	if o.isComposite() {
+		return ""
+	}
+	do, ok := o.main.(fs.IDer)
+	if !ok {
+		return ""
+	}
+	return do.ID()
+}
+
+// SetTier performs changing storage tier of the Object if
+// multiple storage classes supported
+func (o *Object) SetTier(tier string) error {
+	for _, part := range append([]fs.Object{o.main}, o.chunks...) {
+		if part == nil {
+			continue
+		}
+		do, ok := part.(fs.SetTierer)
+		if !ok {
+			return errors.New("chunker: underlying remote does not support SetTier")
+		}
+		if err := do.SetTier(tier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTier returns storage tier or class of the Object
+func (o *Object) GetTier() string {
+	do, ok := o.mainChunk().(fs.GetTierer)
+	if !ok {
+		return ""
+	}
+	return do.GetTier()
+}
+
+// Metadata returns metadata for an object
+//
+// It should return nil if there is no Metadata
+func (o *Object) Metadata(ctx context.Context) (fs.Metadata, error) {
+	return fs.GetMetadata(ctx, o.mainChunk())
+}
+
+// SetMetadata writes metadata to an existing object
+func (o *Object) SetMetadata(ctx context.Context, metadata fs.Metadata) error {
+	do, ok := o.mainChunk().(fs.SetMetadataer)
+	if !ok {
+		return fs.ErrorCantSetMetadata
+	}
+	return do.SetMetadata(ctx, metadata)
+}
+
+// ObjectInfo describes a wrapped fs.ObjectInfo for being the source
+// of a chunk or a metadata object
+type ObjectInfo struct {
+	fs.ObjectInfo
+	f      *Fs
+	remote string
+	size   int64
+}
+
+func (f *Fs) wrapInfo(src fs.ObjectInfo, remote string, size int64) *ObjectInfo {
+	return &ObjectInfo{
+		ObjectInfo: src,
+		f:          f,
+		remote:     remote,
+		size:       size,
+	}
+}
+
+// Fs returns read only access to the Fs that this object is part of
+func (o *ObjectInfo) Fs() fs.Info {
+	return o.f
+}
+
+// Remote returns the remote path
+func (o *ObjectInfo) Remote() string {
+	return o.remote
+}
+
+// Size returns the size of the file
+func (o *ObjectInfo) Size() int64 {
+	return o.size
+}
+
+// Hash returns the selected checksum of the file
+//
+// The hashes of the source are for the whole file so they can't be
+// used for chunks or metadata.
+func (o *ObjectInfo) Hash(ctx context.Context, ht hash.Type) (string, error) {
+	if o.remote == o.ObjectInfo.Remote() && o.size == o.ObjectInfo.Size() {
+		return o.ObjectInfo.Hash(ctx, ht)
+	}
+	return "", nil
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs              = (*Fs)(nil)
+	_ fs.Purger          = (*Fs)(nil)
+	_ fs.Copier          = (*Fs)(nil)
+	_ fs.Mover           = (*Fs)(nil)
+	_ fs.DirMover        = (*Fs)(nil)
+	_ fs.PutStreamer     = (*Fs)(nil)
+	_ fs.CleanUpper      = (*Fs)(nil)
+	_ fs.UnWrapper       = (*Fs)(nil)
+	_ fs.ListRer         = (*Fs)(nil)
+	_ fs.Abouter         = (*Fs)(nil)
+	_ fs.Wrapper         = (*Fs)(nil)
+	_ fs.MergeDirser     = (*Fs)(nil)
+	_ fs.DirCacheFlusher = (*Fs)(nil)
+	_ fs.ChangeNotifier  = (*Fs)(nil)
+	_ fs.PublicLinker    = (*Fs)(nil)
+	_ fs.ObjectInfo      = (*ObjectInfo)(nil)
+	_ fs.Object          = (*Object)(nil)
+	_ fs.ObjectUnWrapper = (*Object)(nil)
+	_ fs.MimeTyper       = (*Object)(nil)
+	_ fs.IDer            = (*Object)(nil)
+	_ fs.SetTierer       = (*Object)(nil)
+	_ fs.GetTierer       = (*Object)(nil)
+	_ fs.Metadataer      = (*Object)(nil)
+	_ fs.SetMetadataer   = (*Object)(nil)
+)
@@ -0,0 +1,133 @@
+// Test Chunker filesystem interface
+package chunker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/ncw/rclone/backend/local"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fstest"
+	"github.com/ncw/rclone/fstest/fstests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIntegration runs integration tests against the remote
+func TestIntegration(t *testing.T) {
+	if *fstest.RemoteName == "" {
+		t.Skip("Skipping as -remote not set")
+	}
+	fstests.Run(t, &fstests.Opt{
+		RemoteName:               *fstest.RemoteName,
+		NilObject:                (*Object)(nil),
+		UnimplementableFsMethods: []string{"OpenWriterAt", "PutUnchecked"},
+	})
+}
+
+// TestStandard runs integration tests against a local remote
+func TestStandard(t *testing.T) {
+	if *fstest.RemoteName != "" {
+		t.Skip("Skipping as -remote set")
+	}
+	tempdir := filepath.Join(os.TempDir(), "rclone-chunker-test-standard")
+	name := "TestChunker"
+	fstests.Run(t, &fstests.Opt{
+		RemoteName: name + ":",
+		NilObject:  (*Object)(nil),
+		ExtraConfig: []fstests.ExtraConfigItem{
+			{Name: name, Key: "type", Value: "chunker"},
+			{Name: name, Key: "remote", Value: tempdir},
+		},
+		UnimplementableFsMethods: []string{"OpenWriterAt", "PutUnchecked"},
+	})
+}
+
+// TestSmallChunks runs integration tests with every test file split
+// into several chunks
+func TestSmallChunks(t *testing.T) {
+	if *fstest.RemoteName != "" {
+		t.Skip("Skipping as -remote set")
+	}
+	tempdir := filepath.Join(os.TempDir(), "rclone-chunker-test-small")
+	name := "TestChunkerSmall"
+	fstests.Run(t, &fstests.Opt{
+		RemoteName: name + ":",
+		NilObject:  (*Object)(nil),
+		ExtraConfig: []fstests.ExtraConfigItem{
+			{Name: name, Key: "type", Value: "chunker"},
+			{Name: name, Key: "remote", Value: tempdir},
+			{Name: name, Key: "chunk_size", Value: "13"},
+			{Name: name, Key: "hash_type", Value: "sha1"},
+		},
+		UnimplementableFsMethods: []string{"OpenWriterAt", "PutUnchecked"},
+	})
+}
+
+// TestNoMeta runs integration tests without metadata objects
+func TestNoMeta(t *testing.T) {
+	if *fstest.RemoteName != "" {
+		t.Skip("Skipping as -remote set")
+	}
+	tempdir := filepath.Join(os.TempDir(), "rclone-chunker-test-nometa")
+	name := "TestChunkerNoMeta"
+	fstests.Run(t, &fstests.Opt{
+		RemoteName: name + ":",
+		NilObject:  (*Object)(nil),
+		ExtraConfig: []fstests.ExtraConfigItem{
+			{Name: name, Key: "type", Value: "chunker"},
+			{Name: name, Key: "remote", Value: tempdir},
+			{Name: name, Key: "chunk_size", Value: "13"},
+			{Name: name, Key: "meta_format", Value: "none"},
+			{Name: name, Key: "hash_type", Value: "none"},
+			{Name: name, Key: "name_format", Value: "part##_*"},
+			{Name: name, Key: "start_from", Value: "0"},
+		},
+		UnimplementableFsMethods: []string{"OpenWriterAt", "PutUnchecked"},
+	})
+}
+
+func TestChunkNames(t *testing.T) {
+	for _, test := range []struct {
+		format    string
+		startFrom int
+		remote    string
+		chunkNo   int
+		want      string
+	}{
+		{"*.rclone_chunk.###", 1, "file.txt", 0, "file.txt.rclone_chunk.001"},
+		{"*.rclone_chunk.###", 1, "dir/file.txt", 1233, "dir/file.txt.rclone_chunk.1234"},
+		{"part##_*", 0, "a/b/c", 3, "a/b/part03_c"},
+		{"*%#", 0, "100", 7, "100%7"},
+	} {
+		f := &Fs{opt: Options{StartFrom: test.startFrom}}
+		require.NoError(t, f.setNameFormat(test.format))
+		got := f.makeChunkName(test.remote, test.chunkNo)
+		assert.Equal(t, test.want, got, test.format)
+		mainRemote, chunkNo := f.parseChunkName(got)
+		assert.Equal(t, test.remote, mainRemote, test.format)
+		assert.Equal(t, test.chunkNo, chunkNo, test.format)
+		mainRemote, chunkNo = f.parseChunkName(test.remote)
+		assert.Equal(t, "", mainRemote, test.format)
+		assert.Equal(t, -1, chunkNo, test.format)
+	}
+
+	f := &Fs{opt: Options{StartFrom: 1}}
+	require.NoError(t, f.setNameFormat("*.rclone_chunk.###"))
+	mainRemote, chunkNo := f.parseChunkName("file.txt.rclone_chunk.000")
+	assert.Equal(t, "", mainRemote)
+	assert.Equal(t, -1, chunkNo)
+	mainRemote, _ = f.parseChunkName("file.txt.rclone_chunk.01")
+	assert.Equal(t, "", mainRemote)
+
+	for _, bad := range []string{"", "###", "*", "**##", "*#_#", "dir/*##"} {
+		assert.Error(t, f.setNameFormat(bad), bad)
+	}
+}
+
+func (f *Fs) SetUploadChunkSize(cs fs.SizeSuffix) (fs.SizeSuffix, error) {
+	return f.setChunkSize(cs)
+}
+
+var _ fstests.SetUploadChunkSizer = (*Fs)(nil)
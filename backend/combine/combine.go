@@ -0,0 +1,885 @@
+// Package combine implements a backend to combine multiple remotes
+// into one directory tree, each remote appearing as a top level
+// directory.
+package combine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config/configmap"
+	"github.com/ncw/rclone/fs/config/configstruct"
+	"github.com/ncw/rclone/fs/fspath"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/pkg/errors"
+)
+
+// Register with Fs
+func init() {
+	fsi := &fs.RegInfo{
+		Name:        "combine",
+		Description: "Combine several remotes into one",
+		NewFs:       NewFs,
+		Options: []fs.Option{{
+			Name: "upstreams",
+			Help: `Upstreams for combining
+
+These should be in the form
+
+    dir=remote:path dir2=remote2:path
+
+Where before the = is specified the root directory and after is the remote to
+put there.
+
+Embedded spaces can be added using quotes
+
+    "dir=remote:path with space" "dir2=remote2:path with space"`,
+			Required: true,
+		}},
+	}
+	fs.Register(fsi)
+}
+
+// Options defines the configuration for this backend
+type Options struct {
+	Upstreams fs.SpaceSepList `config:"upstreams"`
+}
+
+// Fs represents a combine of upstreams
+type Fs struct {
+	name      string               // name of this remote
+	features  *fs.Features         // optional features
+	opt       Options              // options for this Fs
+	root      string               // the path we are working on
+	upstreams map[string]*upstream // map of upstreams keyed by their directory
+	inside    *upstream            // set if the root is inside an upstream
+	hashSet   hash.Set             // intersection of hash types
+	when      time.Time            // time the top level directories were made
+}
+
+// upstream is a remote which makes up a top level directory of the
+// combine
+type upstream struct {
+	f      fs.Fs  // the remote
+	parent *Fs    // the combine this is part of
+	dir    string // the top level directory this is mounted on
+	prefix string // what to add to the remotes on f to make combine remotes
+}
+
+// newUpstreamFs makes the Fs for the upstream remote with root
+// appended to its path
+func newUpstreamFs(remote, root string) (fs.Fs, error) {
+	fsInfo, configName, fsPath, config, err := fs.ConfigFs(remote)
+	if err != nil {
+		return nil, err
+	}
+	return fsInfo.NewFs(configName, fspath.JoinRootPath(fsPath, root), config)
+}
+
+// parseUpstreams parses the upstreams option into a map of directory
+// to remote
+func parseUpstreams(name string, upstreams []string) (map[string]string, error) {
+	remotes := make(map[string]string, len(upstreams))
+	for _, s := range upstreams {
+		equal := strings.IndexRune(s, '=')
+		if equal < 0 {
+			return nil, errors.Errorf("no \"=\" in upstream definition %q", s)
+		}
+		dir, remote := strings.Trim(s[:equal], "/"), s[equal+1:]
+		if dir == "" || dir == "." || dir == ".." || strings.Contains(dir, "/") {
+			return nil, errors.Errorf("bad directory %q in upstream definition %q - it must be a single path segment", dir, s)
+		}
+		if remote == "" {
+			return nil, errors.Errorf("empty remote in upstream definition %q", s)
+		}
+		if strings.HasPrefix(remote, name+":") || strings.HasPrefix(remote, name+",") {
+			return nil, errors.New("can't point combine remote at itself - check the value of the upstreams setting")
+		}
+		if _, found := remotes[dir]; found {
+			return nil, errors.Errorf("duplicate directory %q in upstream definition %q", dir, s)
+		}
+		remotes[dir] = remote
+	}
+	return remotes, nil
+}
+
+// splitRemote splits remote into its top level directory and the rest
+func splitRemote(remote string) (dir, rest string) {
+	if i := strings.IndexRune(remote, '/'); i >= 0 {
+		return remote[:i], remote[i+1:]
+	}
+	return remote, ""
+}
+
+// NewFs constructs an Fs from the path.
+//
+// The returned Fs is the actual Fs, referenced by remote in the config
+func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
+	// Parse config into Options struct
+	opt := new(Options)
+	err := configstruct.Set(m, opt)
+	if err != nil {
+		return nil, err
+	}
+	if len(opt.Upstreams) == 0 {
+		return nil, errors.New("combine can't have no upstreams - check the value of the upstreams setting")
+	}
+	remotes, err := parseUpstreams(name, opt.Upstreams)
+	if err != nil {
+		return nil, err
+	}
+
+	root = strings.Trim(root, "/")
+	f := &Fs{
+		name:      name,
+		root:      root,
+		opt:       *opt,
+		upstreams: make(map[string]*upstream, len(remotes)),
+		when:      time.Now(),
+	}
+
+	isFile := false
+	if root != "" {
+		// The root is inside one of the upstreams so only that
+		// upstream is needed
+		dir, rest := splitRemote(root)
+		remote, found := remotes[dir]
+		if !found {
+			return nil, errors.Errorf("directory %q not found - it must be one of the upstreams", dir)
+		}
+		uFs, err := newUpstreamFs(remote, rest)
+		if err == fs.ErrorIsFile {
+			isFile = true
+			f.root = path.Dir(root)
+			if f.root == "." {
+				f.root = ""
+			}
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to create upstream %q", dir)
+		}
+		f.inside = &upstream{f: uFs, parent: f, dir: dir}
+		f.upstreams[dir] = f.inside
+	} else {
+		for dir, remote := range remotes {
+			uFs, err := newUpstreamFs(remote, "")
+			if err == fs.ErrorIsFile {
+				return nil, errors.Errorf("upstream %q must be a directory", dir)
+			} else if err != nil {
+				return nil, errors.Wrapf(err, "failed to create upstream %q", dir)
+			}
+			f.upstreams[dir] = &upstream{f: uFs, parent: f, dir: dir, prefix: dir}
+		}
+	}
+
+	features := (&fs.Features{
+		CaseInsensitive:         true,
+		DuplicateFiles:          false,
+		ReadMimeType:            true,
+		WriteMimeType:           true,
+		CanHaveEmptyDirectories: true,
+		BucketBased:             true,
+		SetTier:                 true,
+		GetTier:                 true,
+	}).Fill(f)
+	for _, u := range f.upstreams {
+		features = features.Mask(u.f)
+	}
+
+	// These are routed to a single upstream and return the
+	// appropriate error if it doesn't support them, so re-instate
+	// them.
+	features.Copy = f.Copy
+	features.Move = f.Move
+	features.DirMove = f.DirMove
+	features.Purge = f.Purge
+	features.ChangeNotify = f.ChangeNotify
+	features.DirCacheFlush = f.DirCacheFlush
+	features.PublicLink = f.PublicLink
+	features.CleanUp = f.CleanUp
+	features.About = f.About
+	var clearChangeNotify, clearDirCacheFlush, clearPublicLink, clearCleanUp, clearAbout = true, true, true, true, true
+	for _, u := range f.upstreams {
+		uFeatures := u.f.Features()
+		if uFeatures.ChangeNotify != nil {
+			clearChangeNotify = false
+		}
+		if uFeatures.DirCacheFlush != nil {
+			clearDirCacheFlush = false
+		}
+		if uFeatures.PublicLink != nil {
+			clearPublicLink = false
+		}
+		if uFeatures.CleanUp != nil {
+			clearCleanUp = false
+		}
+		if uFeatures.About != nil {
+			clearAbout = false
+		}
+	}
+	if clearChangeNotify {
+		features.ChangeNotify = nil
+	}
+	if clearDirCacheFlush {
+		features.DirCacheFlush = nil
+	}
+	if clearPublicLink {
+		features.PublicLink = nil
+	}
+	if clearCleanUp {
+		features.CleanUp = nil
+	}
+	if clearAbout {
+		features.About = nil
+	}
+	f.features = features
+
+	// Get common intersection of hashes
+	f.hashSet = hash.Supported
+	for _, u := range f.upstreams {
+		f.hashSet = f.hashSet.Overlap(u.f.Hashes())
+	}
+
+	if isFile {
+		return f, fs.ErrorIsFile
+	}
+	return f, nil
+}
+
+// Name of the remote (as passed into NewFs)
+func (f *Fs) Name() string {
+	return f.name
+}
+
+// Root of the remote (as passed into NewFs)
+func (f *Fs) Root() string {
+	return f.root
+}
+
+// String converts this Fs to a string
+func (f *Fs) String() string {
+	return fmt.Sprintf("combine root '%s'", f.root)
+}
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features {
+	return f.features
+}
+
+// Hashes returns the hash types supported by all the upstreams
+func (f *Fs) Hashes() hash.Set {
+	return f.hashSet
+}
+
+// Precision is the greatest Precision of all upstreams
+func (f *Fs) Precision() time.Duration {
+	var greatestPrecision time.Duration
+	for _, u := range f.upstreams {
+		if p := u.f.Precision(); p > greatestPrecision {
+			greatestPrecision = p
+		}
+	}
+	return greatestPrecision
+}
+
+// sortedUpstreams returns the upstreams sorted by directory
+func (f *Fs) sortedUpstreams() []*upstream {
+	upstreams := make([]*upstream, 0, len(f.upstreams))
+	for _, u := range f.upstreams {
+		upstreams = append(upstreams, u)
+	}
+	sort.Slice(upstreams, func(i, j int) bool {
+		return upstreams[i].dir < upstreams[j].dir
+	})
+	return upstreams
+}
+
+// isRoot returns whether dir is the virtual root holding the upstreams
+func (f *Fs) isRoot(dir string) bool {
+	return f.inside == nil && dir == ""
+}
+
+// findUpstream returns the upstream remote is on and the path of
+// remote on that upstream
+//
+// It returns fs.ErrorDirNotFound if the top level directory of remote
+// isn't an upstream.
+func (f *Fs) findUpstream(remote string) (u *upstream, uRemote string, err error) {
+	if f.inside != nil {
+		return f.inside, remote, nil
+	}
+	dir, rest := splitRemote(remote)
+	u = f.upstreams[dir]
+	if u == nil {
+		return nil, "", fs.ErrorDirNotFound
+	}
+	return u, rest, nil
+}
+
+// findUpstreamFile is like findUpstream but returns an error if
+// remote can't be a file, ie it is an upstream's root directory
+func (f *Fs) findUpstreamFile(remote string) (u *upstream, uRemote string, err error) {
+	u, uRemote, err = f.findUpstream(remote)
+	if err != nil || uRemote == "" {
+		return nil, "", errors.Errorf("can't use %q as a file - files must be inside one of the upstream directories", remote)
+	}
+	return u, uRemote, nil
+}
+
+// List the objects and directories in dir into entries.  The
+// entries can be returned in any order but should be for a
+// complete directory.
+//
+// dir should be "" to list the root, and should not have
+// trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	if f.isRoot(dir) {
+		for _, u := range f.sortedUpstreams() {
+			entries = append(entries, fs.NewDir(u.dir, f.when))
+		}
+		return entries, nil
+	}
+	u, uRemote, err := f.findUpstream(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err = u.f.List(ctx, uRemote)
+	if err == fs.ErrorDirNotFound && f.inside == nil && uRemote == "" {
+		// The top level directories always exist
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u.wrapEntries(ctx, entries), nil
+}
+
+// ListR lists the objects and directories of the Fs starting
+// from dir recursively into out.
+//
+// dir should be "" to start from the root, and should not
+// have trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+//
+// It should call callback for each tranche of entries read.
+// These need not be returned in any particular order.  If
+// callback returns an error then the listing will stop
+// immediately.
+//
+// Don't implement this unless you have a more efficient way
+// of listing recursively that doing a directory traversal.
+func (f *Fs) ListR(ctx context.Context, dir string, callback fs.ListRCallback) (err error) {
+	if f.isRoot(dir) {
+		entries, err := f.List(ctx, "")
+		if err != nil {
+			return err
+		}
+		err = callback(entries)
+		if err != nil {
+			return err
+		}
+		for _, u := range f.sortedUpstreams() {
+			err = u.listR(ctx, "", callback)
+			if err == fs.ErrorDirNotFound {
+				// The top level directories always exist
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	u, uRemote, err := f.findUpstream(dir)
+	if err != nil {
+		return err
+	}
+	err = u.listR(ctx, uRemote, callback)
+	if err == fs.ErrorDirNotFound && f.inside == nil && uRemote == "" {
+		// The top level directories always exist
+		return nil
+	}
+	return err
+}
+
+// NewObject finds the Object at remote.  If it can't be found
+// it returns the error fs.ErrorObjectNotFound.
+func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	u, uRemote, err := f.findUpstream(remote)
+	if err != nil || uRemote == "" {
+		return nil, fs.ErrorObjectNotFound
+	}
+	o, err := u.f.NewObject(ctx, uRemote)
+	if err != nil {
+		return nil, err
+	}
+	return u.newObject(o), nil
+}
+
+// Put in to the remote path with the modTime given of the given size
+//
+// May create the object even if it returns an error - if so
+// will return the object and the error, otherwise will return
+// nil and the error
+func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	u, uRemote, err := f.findUpstreamFile(src.Remote())
+	if err != nil {
+		return nil, err
+	}
+	o, err := u.f.Put(ctx, in, newObjectInfo(src, uRemote), options...)
+	if err != nil {
+		return nil, err
+	}
+	return u.newObject(o), nil
+}
+
+// PutStream uploads to the remote path with the modTime given of indeterminate size
+//
+// May create the object even if it returns an error - if so
+// will return the object and the error, otherwise will return
+// nil and the error
+func (f *Fs) PutStream(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	u, uRemote, err := f.findUpstreamFile(src.Remote())
+	if err != nil {
+		return nil, err
+	}
+	o, err := u.f.Features().PutStream(ctx, in, newObjectInfo(src, uRemote), options...)
+	if err != nil {
+		return nil, err
+	}
+	return u.newObject(o), nil
+}
+
+// Mkdir makes the directory (container, bucket)
+//
+// Shouldn't return an error if it already exists
+func (f *Fs) Mkdir(ctx context.Context, dir string) error {
+	if f.isRoot(dir) {
+		return nil
+	}
+	u, uRemote, err := f.findUpstream(dir)
+	if err != nil {
+		return errors.Errorf("can't create directory %q - top level directories must be one of the upstreams", dir)
+	}
+	return u.f.Mkdir(ctx, uRemote)
+}
+
+// Rmdir removes the directory (container, bucket) if empty
+//
+// Return an error if it doesn't exist or isn't empty
+func (f *Fs) Rmdir(ctx context.Context, dir string) error {
+	if f.isRoot(dir) {
+		return errors.New("can't remove the root of a combine")
+	}
+	u, uRemote, err := f.findUpstream(dir)
+	if err != nil {
+		return err
+	}
+	return u.f.Rmdir(ctx, uRemote)
+}
+
+// Purge all files in the root and the root directory
+//
+// Implement this if you have a way of deleting all the files
+// quicker than just running Remove() on the result of List()
+//
+// Return an error if it doesn't exist
+func (f *Fs) Purge(ctx context.Context) error {
+	if f.inside == nil {
+		return fs.ErrorCantPurge
+	}
+	do := f.inside.f.Features().Purge
+	if do == nil {
+		return fs.ErrorCantPurge
+	}
+	return do(ctx)
+}
+
+// sameUpstream returns whether the upstreams can do server side
+// operations between each other, ie they are the same remote
+func sameUpstream(a, b *upstream) bool {
+	return a.f.Name() == b.f.Name()
+}
+
+// Copy src to this remote using server side copy operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantCopy
+func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	srcObj, ok := src.(*Object)
+	if !ok {
+		fs.Debugf(src, "Can't copy - not same remote type")
+		return nil, fs.ErrorCantCopy
+	}
+	u, uRemote, err := f.findUpstreamFile(remote)
+	if err != nil {
+		return nil, err
+	}
+	do := u.f.Features().Copy
+	if do == nil || !sameUpstream(srcObj.u, u) {
+		fs.Debugf(src, "Can't copy - not same upstream")
+		return nil, fs.ErrorCantCopy
+	}
+	o, err := do(ctx, srcObj.Object, uRemote)
+	if err != nil {
+		return nil, err
+	}
+	return u.newObject(o), nil
+}
+
+// Move src to this remote using server side move operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantMove
+func (f *Fs) Move(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	srcObj, ok := src.(*Object)
+	if !ok {
+		fs.Debugf(src, "Can't move - not same remote type")
+		return nil, fs.ErrorCantMove
+	}
+	u, uRemote, err := f.findUpstreamFile(remote)
+	if err != nil {
+		return nil, err
+	}
+	do := u.f.Features().Move
+	if do == nil || !sameUpstream(srcObj.u, u) {
+		fs.Debugf(src, "Can't move - not same upstream")
+		return nil, fs.ErrorCantMove
+	}
+	o, err := do(ctx, srcObj.Object, uRemote)
+	if err != nil {
+		return nil, err
+	}
+	return u.newObject(o), nil
+}
+
+// DirMove moves src, srcRemote to this remote at dstRemote
+// using server side move operations.
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantDirMove
+//
+// If destination exists then return fs.ErrorDirExists
+func (f *Fs) DirMove(ctx context.Context, src fs.Fs, srcRemote, dstRemote string) error {
+	srcFs, ok := src.(*Fs)
+	if !ok {
+		fs.Debugf(src, "Can't move directory - not same remote type")
+		return fs.ErrorCantDirMove
+	}
+	srcU, srcURemote, err := srcFs.findUpstream(srcRemote)
+	if err != nil {
+		return err
+	}
+	dstU, dstURemote, err := f.findUpstream(dstRemote)
+	if err != nil {
+		return fs.ErrorCantDirMove
+	}
+	// The upstream directories themselves can't be moved
+	if (srcFs.inside == nil && srcURemote == "") || (f.inside == nil && dstURemote == "") {
+		fs.Debugf(src, "Can't move directory - can't move upstream directories")
+		return fs.ErrorCantDirMove
+	}
+	do := dstU.f.Features().DirMove
+	if do == nil || !sameUpstream(srcU, dstU) {
+		fs.Debugf(src, "Can't move directory - not same upstream")
+		return fs.ErrorCantDirMove
+	}
+	return do(ctx, srcU.f, srcURemote, dstURemote)
+}
+
+// ChangeNotify calls the passed function with a path
+// that has had changes. If the implementation
+// uses polling, it should adhere to the given interval.
+// At least one value will be written to the channel,
+// specifying the initial value and updated values might
+// follow. A 0 Duration should pause the polling.
+// The ChangeNotify implementation must empty the channel
+// regularly. When the channel gets closed, the implementation
+// should stop polling and release resources.
+func (f *Fs) ChangeNotify(ctx context.Context, fn func(string, fs.EntryType), ch <-chan time.Duration) {
+	var uChans []chan time.Duration
+
+	for _, u := range f.upstreams {
+		if do := u.f.Features().ChangeNotify; do != nil {
+			uChan := make(chan time.Duration)
+			uChans = append(uChans, uChan)
+			prefix := u.prefix
+			do(ctx, func(remote string, entryType fs.EntryType) {
+				fn(path.Join(prefix, remote), entryType)
+			}, uChan)
+		}
+	}
+
+	go func() {
+		for i := range ch {
+			for _, c := range uChans {
+				c <- i
+			}
+		}
+		for _, c := range uChans {
+			close(c)
+		}
+	}()
+}
+
+// DirCacheFlush resets the directory cache - used in testing
+// as an optional interface
+func (f *Fs) DirCacheFlush() {
+	for _, u := range f.upstreams {
+		if do := u.f.Features().DirCacheFlush; do != nil {
+			do()
+		}
+	}
+}
+
+// PublicLink generates a public link to the remote path (usually readable by anyone)
+func (f *Fs) PublicLink(ctx context.Context, remote string) (string, error) {
+	u, uRemote, err := f.findUpstream(remote)
+	if err != nil {
+		return "", err
+	}
+	do := u.f.Features().PublicLink
+	if do == nil {
+		return "", errors.Errorf("upstream %q doesn't support PublicLink", u.dir)
+	}
+	return do(ctx, uRemote)
+}
+
+// CleanUp the trash in the Fs
+func (f *Fs) CleanUp(ctx context.Context) error {
+	for _, u := range f.sortedUpstreams() {
+		if do := u.f.Features().CleanUp; do != nil {
+			err := do(ctx)
+			if err != nil {
+				return errors.Wrapf(err, "failed to clean up upstream %q", u.dir)
+			}
+		}
+	}
+	return nil
+}
+
+// About gets quota information from the Fs by adding up the usage
+// of all the upstreams
+//
+// A field is only reported if all the upstreams which support About
+// report it.
+func (f *Fs) About(ctx context.Context) (*fs.Usage, error) {
+	usage := &fs.Usage{
+		Total:   new(int64),
+		Used:    new(int64),
+		Trashed: new(int64),
+		Other:   new(int64),
+		Free:    new(int64),
+		Objects: new(int64),
+	}
+	for _, u := range f.upstreams {
+		do := u.f.Features().About
+		if do == nil {
+			continue
+		}
+		uUsage, err := do(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read usage of upstream %q", u.dir)
+		}
+		usage.Total = addUsage(usage.Total, uUsage.Total)
+		usage.Used = addUsage(usage.Used, uUsage.Used)
+		usage.Trashed = addUsage(usage.Trashed, uUsage.Trashed)
+		usage.Other = addUsage(usage.Other, uUsage.Other)
+		usage.Free = addUsage(usage.Free, uUsage.Free)
+		usage.Objects = addUsage(usage.Objects, uUsage.Objects)
+	}
+	return usage, nil
+}
+
+// addUsage adds b to a returning nil if either is unknown
+func addUsage(a, b *int64) *int64 {
+	if a == nil || b == nil {
+		return nil
+	}
+	*a += *b
+	return a
+}
+
+// newObject wraps an Object on the upstream
+func (u *upstream) newObject(o fs.Object) *Object {
+	return &Object{
+		Object: o,
+		u:      u,
+	}
+}
+
+// wrapEntries converts entries on the upstream into combine entries
+func (u *upstream) wrapEntries(ctx context.Context, entries fs.DirEntries) fs.DirEntries {
+	for i, entry := range entries {
+		switch x := entry.(type) {
+		case fs.Object:
+			entries[i] = u.newObject(x)
+		case fs.Directory:
+			if u.prefix != "" {
+				entries[i] = fs.NewDirCopy(ctx, x).SetRemote(path.Join(u.prefix, x.Remote()))
+			}
+		default:
+			fs.Errorf(u.parent, "Unknown entry type %T", entry)
+		}
+	}
+	return entries
+}
+
+// listR lists dir on the upstream recursively, using the upstream's
+// ListR which must exist
+func (u *upstream) listR(ctx context.Context, dir string, callback fs.ListRCallback) error {
+	return u.f.Features().ListR(ctx, dir, func(entries fs.DirEntries) error {
+		return callback(u.wrapEntries(ctx, entries))
+	})
+}
+
+// Object describes a wrapped Object
+//
+// This is a wrapped Object which knows its path in the combine
+type Object struct {
+	fs.Object
+	u *upstream
+}
+
+// Fs returns read only access to the Fs that this object is part of
+func (o *Object) Fs() fs.Info {
+	return o.u.parent
+}
+
+// Remote returns the remote path
+func (o *Object) Remote() string {
+	return path.Join(o.u.prefix, o.Object.Remote())
+}
+
+// String returns a description of the Object
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.Remote()
+}
+
+// UnWrap returns the wrapped Object
+func (o *Object) UnWrap() fs.Object {
+	return o.Object
+}
+
+// Update in to the object with the modTime given of the given size
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	return o.Object.Update(ctx, in, newObjectInfo(src, o.Object.Remote()), options...)
+}
+
+// MimeType returns the content type of the Object if
+// known, or "" if not
+func (o *Object) MimeType(ctx context.Context) string {
+	return fs.MimeType(ctx, o.Object)
+}
+
+// ID returns the ID of the Object if known, or "" if not
+func (o *Object) ID() string {
+	do, ok := o.Object.(fs.IDer)
+	if !ok {
+		return ""
+	}
+	return do.ID()
+}
+
+// SetTier performs changing storage tier of the Object if
+// multiple storage classes supported
+func (o *Object) SetTier(tier string) error {
+	do, ok := o.Object.(fs.SetTierer)
+	if !ok {
+		return errors.New("combine: underlying remote does not support SetTier")
+	}
+	return do.SetTier(tier)
+}
+
+// GetTier returns storage tier or class of the Object
+func (o *Object) GetTier() string {
+	do, ok := o.Object.(fs.GetTierer)
+	if !ok {
+		return ""
+	}
+	return do.GetTier()
+}
+
+// Metadata returns metadata for an object
+//
+// It should return nil if there is no Metadata
+func (o *Object) Metadata(ctx context.Context) (fs.Metadata, error) {
+	return fs.GetMetadata(ctx, o.Object)
+}
+
+// SetMetadata writes metadata to an existing object
+func (o *Object) SetMetadata(ctx context.Context, metadata fs.Metadata) error {
+	do, ok := o.Object.(fs.SetMetadataer)
+	if !ok {
+		return fs.ErrorCantSetMetadata
+	}
+	return do.SetMetadata(ctx, metadata)
+}
+
+// ObjectInfo describes a wrapped fs.ObjectInfo for being the source
+//
+// This gives the source the remote path on the upstream
+type ObjectInfo struct {
+	fs.ObjectInfo
+	remote string
+}
+
+func newObjectInfo(src fs.ObjectInfo, remote string) *ObjectInfo {
+	return &ObjectInfo{
+		ObjectInfo: src,
+		remote:     remote,
+	}
+}
+
+// Remote returns the remote path
+func (o *ObjectInfo) Remote() string {
+	return o.remote
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs              = (*Fs)(nil)
+	_ fs.Purger          = (*Fs)(nil)
+	_ fs.PutStreamer     = (*Fs)(nil)
+	_ fs.Copier          = (*Fs)(nil)
+	_ fs.Mover           = (*Fs)(nil)
+	_ fs.DirMover        = (*Fs)(nil)
+	_ fs.DirCacheFlusher = (*Fs)(nil)
+	_ fs.ChangeNotifier  = (*Fs)(nil)
+	_ fs.PublicLinker    = (*Fs)(nil)
+	_ fs.CleanUpper      = (*Fs)(nil)
+	_ fs.ListRer         = (*Fs)(nil)
+	_ fs.Abouter         = (*Fs)(nil)
+	_ fs.Object          = (*Object)(nil)
+	_ fs.ObjectUnWrapper = (*Object)(nil)
+	_ fs.MimeTyper       = (*Object)(nil)
+	_ fs.IDer            = (*Object)(nil)
+	_ fs.SetTierer       = (*Object)(nil)
+	_ fs.GetTierer       = (*Object)(nil)
+	_ fs.Metadataer      = (*Object)(nil)
+	_ fs.SetMetadataer   = (*Object)(nil)
+)
@@ -0,0 +1,212 @@
+// Test Combine filesystem interface
+package combine
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	_ "github.com/ncw/rclone/backend/local"
+	_ "github.com/ncw/rclone/backend/memory"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/fs/object"
+	"github.com/ncw/rclone/fstest"
+	"github.com/ncw/rclone/fstest/fstests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIntegration runs integration tests against the remote
+func TestIntegration(t *testing.T) {
+	if *fstest.RemoteName == "" {
+		t.Skip("Skipping as -remote not set")
+	}
+	fstests.Run(t, &fstests.Opt{
+		RemoteName:               *fstest.RemoteName,
+		NilObject:                (*Object)(nil),
+		UnimplementableFsMethods: []string{"OpenWriterAt"},
+	})
+}
+
+// makeUpstreams makes the directories for the upstreams dir1, dir2
+// and dir3 returning the value for the upstreams option
+func makeUpstreams(t *testing.T, name string) string {
+	tempdir := filepath.Join(os.TempDir(), "rclone-combine-test-"+name)
+	require.NoError(t, os.RemoveAll(tempdir))
+	upstreams := ""
+	for _, dir := range []string{"dir1", "dir2", "dir3"} {
+		upstream := filepath.Join(tempdir, dir)
+		require.NoError(t, os.MkdirAll(upstream, 0700))
+		if upstreams != "" {
+			upstreams += " "
+		}
+		upstreams += dir + "=" + upstream
+	}
+	return upstreams
+}
+
+// TestStandard runs integration tests inside an upstream of a local
+// combine
+func TestStandard(t *testing.T) {
+	if *fstest.RemoteName != "" {
+		t.Skip("Skipping as -remote set")
+	}
+	name := "TestCombine"
+	fstests.Run(t, &fstests.Opt{
+		RemoteName: name + ":dir1",
+		NilObject:  (*Object)(nil),
+		ExtraConfig: []fstests.ExtraConfigItem{
+			{Name: name, Key: "type", Value: "combine"},
+			{Name: name, Key: "upstreams", Value: makeUpstreams(t, "standard")},
+		},
+		UnimplementableFsMethods: []string{"OpenWriterAt"},
+	})
+}
+
+// TestRoot checks the operations at the root of the combine which
+// span the upstreams
+func TestRoot(t *testing.T) {
+	if *fstest.RemoteName != "" {
+		t.Skip("Skipping as -remote set")
+	}
+	ctx := context.Background()
+	name := "TestCombineRoot"
+	fstest.Initialise()
+	config.FileSet(name, "type", "combine")
+	config.FileSet(name, "upstreams", makeUpstreams(t, "root"))
+	f, err := fs.NewFs(name + ":")
+	require.NoError(t, err)
+
+	// The upstreams are the top level directories
+	entries, err := f.List(ctx, "")
+	require.NoError(t, err)
+	var dirs []string
+	for _, entry := range entries {
+		_, isDir := entry.(fs.Directory)
+		assert.True(t, isDir)
+		dirs = append(dirs, entry.Remote())
+	}
+	assert.Equal(t, []string{"dir1", "dir2", "dir3"}, dirs)
+
+	// Files can't be put at the top level or in a directory which
+	// isn't an upstream
+	put := func(remote string) (fs.Object, error) {
+		contents := []byte("hello " + remote)
+		src := object.NewStaticObjectInfo(remote, time.Now(), int64(len(contents)), true, nil, nil)
+		return f.Put(ctx, bytes.NewBuffer(contents), src)
+	}
+	_, err = put("file.txt")
+	assert.Error(t, err)
+	_, err = put("potato/file.txt")
+	assert.Error(t, err)
+	assert.Error(t, f.Mkdir(ctx, "potato"))
+
+	// Files are routed to the upstreams
+	o1, err := put("dir1/sub/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "dir1/sub/file.txt", o1.Remote())
+	entries, err = f.List(ctx, "dir1/sub")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "dir1/sub/file.txt", entries[0].Remote())
+	o, err := f.NewObject(ctx, "dir1/sub/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "dir1/sub/file.txt", o.Remote())
+	_, err = f.NewObject(ctx, "dir1")
+	assert.Equal(t, fs.ErrorObjectNotFound, err)
+
+	// Copy is passed on to the upstream which for local isn't
+	// supported, but Move works between the upstreams as they are
+	// all local
+	_, err = f.Features().Copy(ctx, o1, "dir2/copied.txt")
+	assert.Equal(t, fs.ErrorCantCopy, err)
+	o2, err := f.Features().Move(ctx, o1, "dir3/moved.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "dir3/moved.txt", o2.Remote())
+	_, err = f.NewObject(ctx, "dir1/sub/file.txt")
+	assert.Equal(t, fs.ErrorObjectNotFound, err)
+	_, err = put("dir1/sub/file.txt")
+	require.NoError(t, err)
+
+	// The upstream directories can't be moved
+	assert.Equal(t, fs.ErrorCantDirMove, f.Features().DirMove(ctx, f, "dir1", "dir2/dir1"))
+	require.NoError(t, f.Features().DirMove(ctx, f, "dir1/sub", "dir2/sub"))
+	rc, err := f.NewObject(ctx, "dir2/sub/file.txt")
+	require.NoError(t, err)
+	in, err := rc.Open(ctx)
+	require.NoError(t, err)
+	contents, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	require.NoError(t, in.Close())
+	assert.Equal(t, "hello dir1/sub/file.txt", string(contents))
+
+	// ListR is only available if all the upstreams support it
+	assert.Nil(t, f.Features().ListR)
+
+	// About adds up the upstreams
+	usage, err := f.Features().About(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, usage.Total)
+	upstreamUsage, err := f.(*Fs).upstreams["dir1"].f.Features().About(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3*(*upstreamUsage.Total), *usage.Total)
+
+	// Pointing the root at a file returns the parent
+	fFile, err := fs.NewFs(name + ":dir3/moved.txt")
+	assert.Equal(t, fs.ErrorIsFile, err)
+	assert.Equal(t, "dir3", fFile.Root())
+
+	// Tidy up
+	for _, remote := range []string{"dir2/sub/file.txt", "dir3/moved.txt"} {
+		o, err := f.NewObject(ctx, remote)
+		require.NoError(t, err)
+		require.NoError(t, o.Remove(ctx))
+	}
+	require.NoError(t, f.Rmdir(ctx, "dir2/sub"))
+}
+
+// TestListRMissingUpstream checks ListR treats upstreams whose root
+// doesn't exist as empty like List does
+func TestListRMissingUpstream(t *testing.T) {
+	if *fstest.RemoteName != "" {
+		t.Skip("Skipping as -remote set")
+	}
+	ctx := context.Background()
+	name := "TestCombineListR"
+	fstest.Initialise()
+	config.FileSet(name+"Memory", "type", "memory")
+	config.FileSet(name, "type", "combine")
+	config.FileSet(name, "upstreams", "dir1="+name+"Memory:bucket1 dir2="+name+"Memory:bucket2")
+	f, err := fs.NewFs(name + ":")
+	require.NoError(t, err)
+	listR := f.Features().ListR
+	require.NotNil(t, listR)
+
+	contents := []byte("hello")
+	src := object.NewStaticObjectInfo("dir1/file.txt", time.Now(), int64(len(contents)), true, nil, nil)
+	o, err := f.Put(ctx, bytes.NewBuffer(contents), src)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, o.Remove(ctx))
+	}()
+
+	list := func(dir string) (remotes []string) {
+		err := listR(ctx, dir, func(entries fs.DirEntries) error {
+			for _, entry := range entries {
+				remotes = append(remotes, entry.Remote())
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		sort.Strings(remotes)
+		return remotes
+	}
+	assert.Equal(t, []string{"dir1", "dir1/file.txt", "dir2"}, list(""))
+	assert.Equal(t, []string(nil), list("dir2"))
+}
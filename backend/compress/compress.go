@@ -0,0 +1,974 @@
+// Package compress provides wrappers for Fs and Object which implement compression
+package compress
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config/configmap"
+	"github.com/ncw/rclone/fs/config/configstruct"
+	"github.com/ncw/rclone/fs/fspath"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/lib/readers"
+	"github.com/pkg/errors"
+)
+
+// Suffixes added to the names of objects on the wrapped remote
+const (
+	gzipSuffix         = ".gz"  // compressed - preceded by the uncompressed size in hex
+	uncompressedSuffix = ".bin" // stored as it is
+)
+
+// compressedMimeTypes are the mime types of data which is already
+// compressed so isn't worth compressing again
+var compressedMimeTypes = map[string]bool{
+	"application/gzip":                        true,
+	"application/x-gzip":                      true,
+	"application/zip":                         true,
+	"application/x-zip-compressed":            true,
+	"application/x-bzip2":                     true,
+	"application/x-xz":                        true,
+	"application/x-lzip":                      true,
+	"application/x-lzma":                      true,
+	"application/x-compress":                  true,
+	"application/x-7z-compressed":             true,
+	"application/x-rar-compressed":            true,
+	"application/vnd.rar":                     true,
+	"application/zstd":                        true,
+	"application/java-archive":                true,
+	"application/epub+zip":                    true,
+	"application/vnd.android.package-archive": true,
+	"image/jpeg":                              true,
+	"image/png":                               true,
+	"image/gif":                               true,
+	"image/webp":                              true,
+	"image/heic":                              true,
+	"audio/mpeg":                              true,
+	"audio/aac":                               true,
+	"audio/ogg":                               true,
+	"audio/flac":                              true,
+	"audio/mp4":                               true,
+	"audio/webm":                              true,
+}
+
+// compressedMimePrefixes are mime type prefixes of data which is
+// already compressed
+var compressedMimePrefixes = []string{
+	"video/",
+	"application/vnd.openxmlformats-officedocument.",
+	"application/vnd.oasis.opendocument.",
+}
+
+// Register with Fs
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name:        "compress",
+		Description: "Compress a remote",
+		NewFs:       NewFs,
+		Options: []fs.Option{{
+			Name:     "remote",
+			Help:     "Remote to compress.\nNormally should contain a ':' and a path, eg \"myremote:path/to/dir\",\n\"myremote:bucket\" or maybe \"myremote:\" (not recommended).",
+			Required: true,
+		}, {
+			Name: "level",
+			Help: `GZIP compression level (-2 to 9).
+
+-1 is the default compression level, 0 stores the data without
+compressing it, 1 is the fastest and 9 the best compression. -2 uses
+Huffman encoding only.`,
+			Default:  gzip.DefaultCompression,
+			Advanced: true,
+		}},
+	})
+}
+
+// Options defines the configuration for this backend
+type Options struct {
+	Remote string `config:"remote"`
+	Level  int    `config:"level"`
+}
+
+// Fs represents a wrapped fs.Fs
+type Fs struct {
+	fs.Fs
+	wrapper  fs.Fs
+	name     string
+	root     string
+	opt      Options
+	features *fs.Features // optional features
+}
+
+// NewFs constructs an Fs from the path, container:path
+func NewFs(name, rpath string, m configmap.Mapper) (fs.Fs, error) {
+	// Parse config into Options struct
+	opt := new(Options)
+	err := configstruct.Set(m, opt)
+	if err != nil {
+		return nil, err
+	}
+	if opt.Level < gzip.HuffmanOnly || opt.Level > gzip.BestCompression {
+		return nil, errors.Errorf("compression level %d out of range -2 to 9", opt.Level)
+	}
+	remote := opt.Remote
+	if strings.HasPrefix(remote, name+":") || strings.HasPrefix(remote, name+",") {
+		return nil, errors.New("can't point compress remote at itself - check the value of the remote setting")
+	}
+	wInfo, wName, wPath, wConfig, err := fs.ConfigFs(remote)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse remote %q to wrap", remote)
+	}
+	f, err := newFs(name, rpath, opt, wInfo, wName, wPath, wConfig)
+	if err != fs.ErrorIsFile && err != nil {
+		return nil, err
+	}
+
+	// Look for a file with one of the encodings in case rpath
+	// points to a file
+	if rpath != "" && err == nil {
+		root := path.Dir(rpath)
+		if root == "." || root == "/" {
+			root = ""
+		}
+		rootFs, rootErr := newFs(name, root, opt, wInfo, wName, wPath, wConfig)
+		if rootErr == nil {
+			_, rootErr = rootFs.NewObject(context.Background(), path.Base(rpath))
+			if rootErr == nil {
+				return rootFs, fs.ErrorIsFile
+			}
+		}
+	}
+	return f, nil
+}
+
+// newFs makes an Fs for the directory rpath wrapping wPath/rpath on
+// the remote described by wInfo, wName and wConfig
+//
+// It returns fs.ErrorIsFile with the Fs if the wrapped remote does.
+func newFs(name, rpath string, opt *Options, wInfo *fs.RegInfo, wName, wPath string, wConfig configmap.Mapper) (*Fs, error) {
+	// The names of files are changed on the wrapped remote so
+	// rpath can only be a directory
+	remotePath := fspath.JoinRootPath(wPath, rpath)
+	wrappedFs, err := wInfo.NewFs(wName, remotePath, wConfig)
+	if err != fs.ErrorIsFile && err != nil {
+		return nil, errors.Wrapf(err, "failed to make remote %s:%q to wrap", wName, remotePath)
+	}
+	f := &Fs{
+		Fs:   wrappedFs,
+		name: name,
+		root: rpath,
+		opt:  *opt,
+	}
+	// the features here are ones we could support, and they are
+	// ANDed with the ones from wrappedFs
+	f.features = (&fs.Features{
+		CaseInsensitive:         true,
+		DuplicateFiles:          false,
+		ReadMimeType:            false,
+		WriteMimeType:           false,
+		BucketBased:             true,
+		CanHaveEmptyDirectories: true,
+		SetTier:                 true,
+		GetTier:                 true,
+	}).Fill(f).Mask(wrappedFs).WrapsFs(f, wrappedFs)
+	// We can always stream uploads as they are spooled to disk if
+	// the wrapped remote can't take them
+	f.features.PutStream = f.PutStream
+	return f, err
+}
+
+// Name of the remote (as passed into NewFs)
+func (f *Fs) Name() string {
+	return f.name
+}
+
+// Root of the remote (as passed into NewFs)
+func (f *Fs) Root() string {
+	return f.root
+}
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features {
+	return f.features
+}
+
+// String returns a description of the FS
+func (f *Fs) String() string {
+	return fmt.Sprintf("Compressed drive '%s:%s'", f.name, f.root)
+}
+
+// encodeName makes the name of the object on the wrapped remote
+func encodeName(remote string, size int64, compressed bool) string {
+	if !compressed {
+		return remote + uncompressedSuffix
+	}
+	return remote + "." + strconv.FormatInt(size, 16) + gzipSuffix
+}
+
+// decodeName decodes the name of an object on the wrapped remote
+// returning ok == false if it wasn't encoded by this backend.
+func decodeName(name string) (remote string, size int64, compressed bool, ok bool) {
+	if strings.HasSuffix(name, uncompressedSuffix) {
+		return name[:len(name)-len(uncompressedSuffix)], -1, false, true
+	}
+	if !strings.HasSuffix(name, gzipSuffix) {
+		return "", -1, false, false
+	}
+	name = name[:len(name)-len(gzipSuffix)]
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 {
+		return "", -1, false, false
+	}
+	size, err := strconv.ParseInt(name[dot+1:], 16, 64)
+	if err != nil || size < 0 {
+		return "", -1, false, false
+	}
+	return name[:dot], size, true, true
+}
+
+// isCompressible returns whether src is worth compressing
+func isCompressible(ctx context.Context, src fs.ObjectInfo) bool {
+	mimeType := fs.MimeType(ctx, src)
+	if i := strings.IndexRune(mimeType, ';'); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	if compressedMimeTypes[mimeType] {
+		return false
+	}
+	for _, prefix := range compressedMimePrefixes {
+		if strings.HasPrefix(mimeType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// Decode a listing of the wrapped remote in place, skipping any
+// objects which weren't encoded by this backend.
+func (f *Fs) decodeEntries(ctx context.Context, entries fs.DirEntries) (newEntries fs.DirEntries, err error) {
+	newEntries = entries[:0] // in place filter
+	for _, entry := range entries {
+		switch x := entry.(type) {
+		case fs.Object:
+			o, err := f.newObject(x)
+			if err != nil {
+				fs.Debugf(x, "Skipping file: %v", err)
+				continue
+			}
+			newEntries = append(newEntries, o)
+		case fs.Directory:
+			newEntries = append(newEntries, x)
+		default:
+			return nil, errors.Errorf("Unknown object type %T", entry)
+		}
+	}
+	return newEntries, nil
+}
+
+// List the objects and directories in dir into entries.  The
+// entries can be returned in any order but should be for a
+// complete directory.
+//
+// dir should be "" to list the root, and should not have
+// trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	entries, err = f.Fs.List(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	return f.decodeEntries(ctx, entries)
+}
+
+// ListR lists the objects and directories of the Fs starting
+// from dir recursively into out.
+//
+// dir should be "" to start from the root, and should not
+// have trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+//
+// It should call callback for each tranche of entries read.
+// These need not be returned in any particular order.  If
+// callback returns an error then the listing will stop
+// immediately.
+//
+// Don't implement this unless you have a more efficient way
+// of listing recursively that doing a directory traversal.
+func (f *Fs) ListR(ctx context.Context, dir string, callback fs.ListRCallback) (err error) {
+	return f.Fs.Features().ListR(ctx, dir, func(entries fs.DirEntries) error {
+		newEntries, err := f.decodeEntries(ctx, entries)
+		if err != nil {
+			return err
+		}
+		return callback(newEntries)
+	})
+}
+
+// NewObject finds the Object at remote.
+//
+// As the name of a compressed object depends on its size the
+// directory is listed to find it.
+func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	o, err := f.Fs.NewObject(ctx, encodeName(remote, -1, false))
+	if err == nil {
+		return f.newObject(o)
+	}
+	if err != fs.ErrorObjectNotFound {
+		return nil, err
+	}
+	dir := path.Dir(remote)
+	if dir == "." {
+		dir = ""
+	}
+	entries, err := f.Fs.List(ctx, dir)
+	if err == fs.ErrorDirNotFound {
+		return nil, fs.ErrorObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		o, ok := entry.(fs.Object)
+		if !ok {
+			continue
+		}
+		if decoded, _, compressed, ok := decodeName(o.Remote()); ok && compressed && decoded == remote {
+			return f.newObject(o)
+		}
+	}
+	return nil, fs.ErrorObjectNotFound
+}
+
+// compressor is a reader which returns gzip compressed data read from in
+type compressor struct {
+	pr *io.PipeReader
+}
+
+func newCompressor(in io.Reader, level int) (*compressor, error) {
+	pr, pw := io.Pipe()
+	gz, err := gzip.NewWriterLevel(pw, level)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		_, err := io.Copy(gz, in)
+		if closeErr := gz.Close(); err == nil {
+			err = closeErr
+		}
+		_ = pw.CloseWithError(err)
+	}()
+	return &compressor{pr: pr}, nil
+}
+
+// Read compressed data
+func (c *compressor) Read(p []byte) (int, error) {
+	return c.pr.Read(p)
+}
+
+// Close the compressor, stopping the compression
+func (c *compressor) Close() error {
+	return c.pr.Close()
+}
+
+// spool compresses in into a temporary file returning it, the number
+// of uncompressed bytes read and the compressed size. The file should
+// be closed and removed after use.
+func (f *Fs) spool(in io.Reader) (spooled *os.File, size int64, compressedSize int64, err error) {
+	spooled, err = ioutil.TempFile("", "rclone-compress-")
+	if err != nil {
+		return nil, -1, -1, errors.Wrap(err, "failed to make temporary file")
+	}
+	defer func() {
+		if err != nil {
+			_ = spooled.Close()
+			_ = os.Remove(spooled.Name())
+		}
+	}()
+	gz, err := gzip.NewWriterLevel(spooled, f.opt.Level)
+	if err != nil {
+		return nil, -1, -1, err
+	}
+	size, err = io.Copy(gz, in)
+	if err != nil {
+		return nil, -1, -1, err
+	}
+	err = gz.Close()
+	if err != nil {
+		return nil, -1, -1, err
+	}
+	compressedSize, err = spooled.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, -1, -1, err
+	}
+	_, err = spooled.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, -1, -1, err
+	}
+	return spooled, size, compressedSize, nil
+}
+
+// put uploads in to the remote, replacing old if it is not nil.
+func (f *Fs) put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options []fs.OpenOption, old *Object) (o *Object, err error) {
+	remote := src.Remote()
+	compressed := isCompressible(ctx, src)
+	size := src.Size()
+	uploadSize := size
+	var wrappedIn io.Reader = in
+	var counter *readers.CountingReader
+
+	if compressed {
+		uploadSize = -1
+		if size < 0 || f.Fs.Features().PutStream == nil {
+			// Compress into a temporary file so both sizes are
+			// known before the upload
+			spooled, spooledSize, compressedSize, err := f.spool(in)
+			if err != nil {
+				return nil, err
+			}
+			defer func() {
+				_ = spooled.Close()
+				_ = os.Remove(spooled.Name())
+			}()
+			size, uploadSize, wrappedIn = spooledSize, compressedSize, spooled
+		} else {
+			counter = readers.NewCountingReader(in)
+			c, err := newCompressor(counter, f.opt.Level)
+			if err != nil {
+				return nil, err
+			}
+			defer fs.CheckClose(c, &err)
+			wrappedIn = c
+		}
+	}
+
+	info := f.newObjectInfo(src, encodeName(remote, size, compressed), uploadSize, compressed)
+	var newObject fs.Object
+	if old != nil && old.Object.Remote() == info.Remote() {
+		newObject, err = old.Object, old.Object.Update(ctx, wrappedIn, info, options...)
+	} else if uploadSize < 0 {
+		newObject, err = f.Fs.Features().PutStream(ctx, wrappedIn, info, options...)
+	} else {
+		newObject, err = f.Fs.Put(ctx, wrappedIn, info, options...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Check the size read as it is encoded in the name
+	if counter != nil {
+		size = int64(counter.BytesRead())
+	}
+	if compressed && src.Size() >= 0 && size != src.Size() {
+		_ = newObject.Remove(ctx)
+		return nil, errors.Errorf("corrupted on transfer: read %d bytes expecting %d", size, src.Size())
+	}
+
+	// Remove the old object if it had a different name
+	if old != nil && old.Object.Remote() != newObject.Remote() {
+		err = old.Object.Remove(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to remove old version")
+		}
+	}
+	return f.newObject(newObject)
+}
+
+// Put in to the remote path with the modTime given of the given size
+//
+// May create the object even if it returns an error - if so
+// will return the object and the error, otherwise will return
+// nil and the error
+func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	o, err := f.NewObject(ctx, src.Remote())
+	switch err {
+	case nil:
+		return o, o.Update(ctx, in, src, options...)
+	case fs.ErrorObjectNotFound:
+		return f.put(ctx, in, src, options, nil)
+	default:
+		return nil, err
+	}
+}
+
+// PutStream uploads to the remote path with the modTime given of indeterminate size
+func (f *Fs) PutStream(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	return f.Put(ctx, in, src, options...)
+}
+
+// PutUnchecked uploads the object
+//
+// This will create a duplicate if we upload a new file without
+// checking to see if there is one already - use Put() for that.
+func (f *Fs) PutUnchecked(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	if f.Fs.Features().PutUnchecked == nil {
+		return nil, errors.New("can't PutUnchecked")
+	}
+	return f.put(ctx, in, src, options, nil)
+}
+
+// Hashes returns the supported hash sets.
+//
+// Compressed objects have no hashes so none are supported.
+func (f *Fs) Hashes() hash.Set {
+	return hash.Set(hash.None)
+}
+
+// Purge all files in the root and the root directory
+//
+// Implement this if you have a way of deleting all the files
+// quicker than just running Remove() on the result of List()
+//
+// Return an error if it doesn't exist
+func (f *Fs) Purge(ctx context.Context) error {
+	do := f.Fs.Features().Purge
+	if do == nil {
+		return fs.ErrorCantPurge
+	}
+	return do(ctx)
+}
+
+type copyMoveFn func(ctx context.Context, src fs.Object, remote string) (fs.Object, error)
+
+// copyOrMove does a server side copy or move of src to remote
+func (f *Fs) copyOrMove(ctx context.Context, src *Object, remote string, do copyMoveFn) (fs.Object, error) {
+	old, err := f.NewObject(ctx, remote)
+	if err != nil && err != fs.ErrorObjectNotFound {
+		return nil, err
+	}
+	newName := encodeName(remote, src.size, src.compressed)
+	oResult, err := do(ctx, src.Object, newName)
+	if err != nil {
+		return nil, err
+	}
+	if old != nil && old.(*Object).Object.Remote() != newName {
+		err = old.Remove(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to remove old version")
+		}
+	}
+	return f.newObject(oResult)
+}
+
+// Copy src to this remote using server side copy operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantCopy
+func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	do := f.Fs.Features().Copy
+	if do == nil {
+		return nil, fs.ErrorCantCopy
+	}
+	o, ok := src.(*Object)
+	if !ok {
+		return nil, fs.ErrorCantCopy
+	}
+	return f.copyOrMove(ctx, o, remote, do)
+}
+
+// Move src to this remote using server side move operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantMove
+func (f *Fs) Move(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	do := f.Fs.Features().Move
+	if do == nil {
+		return nil, fs.ErrorCantMove
+	}
+	o, ok := src.(*Object)
+	if !ok {
+		return nil, fs.ErrorCantMove
+	}
+	return f.copyOrMove(ctx, o, remote, do)
+}
+
+// DirMove moves src, srcRemote to this remote at dstRemote
+// using server side move operations.
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantDirMove
+//
+// If destination exists then return fs.ErrorDirExists
+func (f *Fs) DirMove(ctx context.Context, src fs.Fs, srcRemote, dstRemote string) error {
+	do := f.Fs.Features().DirMove
+	if do == nil {
+		return fs.ErrorCantDirMove
+	}
+	srcFs, ok := src.(*Fs)
+	if !ok {
+		fs.Debugf(srcFs, "Can't move directory - not same remote type")
+		return fs.ErrorCantDirMove
+	}
+	return do(ctx, srcFs.Fs, srcRemote, dstRemote)
+}
+
+// CleanUp the trash in the Fs
+//
+// Implement this if you have a way of emptying the trash or
+// otherwise cleaning up old versions of files.
+func (f *Fs) CleanUp(ctx context.Context) error {
+	do := f.Fs.Features().CleanUp
+	if do == nil {
+		return errors.New("can't CleanUp")
+	}
+	return do(ctx)
+}
+
+// About gets quota information from the Fs
+func (f *Fs) About(ctx context.Context) (*fs.Usage, error) {
+	do := f.Fs.Features().About
+	if do == nil {
+		return nil, errors.New("About not supported")
+	}
+	return do(ctx)
+}
+
+// UnWrap returns the Fs that this Fs is wrapping
+func (f *Fs) UnWrap() fs.Fs {
+	return f.Fs
+}
+
+// WrapFs returns the Fs that is wrapping this Fs
+func (f *Fs) WrapFs() fs.Fs {
+	return f.wrapper
+}
+
+// SetWrapper sets the Fs that is wrapping this Fs
+func (f *Fs) SetWrapper(wrapper fs.Fs) {
+	f.wrapper = wrapper
+}
+
+// MergeDirs merges the contents of all the directories passed
+// in into the first one and rmdirs the other directories.
+func (f *Fs) MergeDirs(ctx context.Context, dirs []fs.Directory) error {
+	do := f.Fs.Features().MergeDirs
+	if do == nil {
+		return errors.New("MergeDirs not supported")
+	}
+	return do(ctx, dirs)
+}
+
+// DirCacheFlush resets the directory cache - used in testing
+// as an optional interface
+func (f *Fs) DirCacheFlush() {
+	do := f.Fs.Features().DirCacheFlush
+	if do != nil {
+		do()
+	}
+}
+
+// PublicLink generates a public link to the remote path (usually readable by anyone)
+func (f *Fs) PublicLink(ctx context.Context, remote string) (string, error) {
+	do := f.Fs.Features().PublicLink
+	if do == nil {
+		return "", errors.New("PublicLink not supported")
+	}
+	o, err := f.NewObject(ctx, remote)
+	if err != nil {
+		// assume it is a directory
+		return do(ctx, remote)
+	}
+	return do(ctx, o.(*Object).Object.Remote())
+}
+
+// ChangeNotify calls the passed function with a path
+// that has had changes. If the implementation
+// uses polling, it should adhere to the given interval.
+func (f *Fs) ChangeNotify(ctx context.Context, notifyFunc func(string, fs.EntryType), pollIntervalChan <-chan time.Duration) {
+	do := f.Fs.Features().ChangeNotify
+	if do == nil {
+		return
+	}
+	wrappedNotifyFunc := func(path string, entryType fs.EntryType) {
+		if entryType == fs.EntryObject {
+			decoded, _, _, ok := decodeName(path)
+			if !ok {
+				return
+			}
+			path = decoded
+		}
+		notifyFunc(path, entryType)
+	}
+	do(ctx, wrappedNotifyFunc, pollIntervalChan)
+}
+
+// Object describes a wrapped object which may be compressed
+type Object struct {
+	fs.Object
+	f          *Fs
+	remote     string // decoded remote
+	size       int64  // uncompressed size
+	compressed bool   // set if the object is compressed
+}
+
+func (f *Fs) newObject(o fs.Object) (*Object, error) {
+	remote, size, compressed, ok := decodeName(o.Remote())
+	if !ok {
+		return nil, errors.Errorf("%q doesn't have a %q or %q suffix", o.Remote(), uncompressedSuffix, gzipSuffix)
+	}
+	if !compressed {
+		size = o.Size()
+	}
+	return &Object{
+		Object:     o,
+		f:          f,
+		remote:     remote,
+		size:       size,
+		compressed: compressed,
+	}, nil
+}
+
+// Fs returns read only access to the Fs that this object is part of
+func (o *Object) Fs() fs.Info {
+	return o.f
+}
+
+// Return a string version
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.remote
+}
+
+// Remote returns the remote path
+func (o *Object) Remote() string {
+	return o.remote
+}
+
+// Size returns the uncompressed size of the file
+func (o *Object) Size() int64 {
+	return o.size
+}
+
+// Hash returns the selected checksum of the file
+//
+// Hashes aren't supported as compressed objects don't have them.
+func (o *Object) Hash(ctx context.Context, ht hash.Type) (string, error) {
+	return "", hash.ErrUnsupported
+}
+
+// UnWrap returns the wrapped Object
+func (o *Object) UnWrap() fs.Object {
+	return o.Object
+}
+
+// decompressor reads decompressed data from a wrapped object
+type decompressor struct {
+	io.Reader
+	gz *gzip.Reader
+	in io.ReadCloser
+}
+
+// Close the decompressor and the object it is reading from
+func (d *decompressor) Close() error {
+	err := d.gz.Close()
+	if closeErr := d.in.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Open opens the file for read.  Call Close() on the returned io.ReadCloser
+//
+// Compressed streams can't be seeked so seeks and ranges are done by
+// decompressing and discarding data.
+func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (rc io.ReadCloser, err error) {
+	if !o.compressed {
+		return o.Object.Open(ctx, options...)
+	}
+	var openOptions []fs.OpenOption
+	var offset, limit int64 = 0, -1
+	for _, option := range options {
+		switch x := option.(type) {
+		case *fs.SeekOption:
+			offset = x.Offset
+		case *fs.RangeOption:
+			offset, limit = x.Decode(o.size)
+		default:
+			// pass on Options to underlying open if appropriate
+			openOptions = append(openOptions, option)
+		}
+	}
+	in, err := o.Object.Open(ctx, openOptions...)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		_ = in.Close()
+		return nil, errors.Wrap(err, "failed to start decompression")
+	}
+	d := &decompressor{Reader: gz, gz: gz, in: in}
+	if offset > 0 {
+		_, err = io.CopyN(ioutil.Discard, gz, offset)
+		if err != nil && err != io.EOF {
+			_ = d.Close()
+			return nil, errors.Wrap(err, "failed to seek")
+		}
+	}
+	if limit >= 0 {
+		d.Reader = io.LimitReader(gz, limit)
+	}
+	return d, nil
+}
+
+// Update in to the object with the modTime given of the given size
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	newO, err := o.f.put(ctx, in, src, options, o)
+	if err != nil {
+		return err
+	}
+	*o = *newO
+	return nil
+}
+
+// MimeType returns the content type of the Object if
+// known, or "" if not
+func (o *Object) MimeType(ctx context.Context) string {
+	if !o.compressed {
+		if do, ok := o.Object.(fs.MimeTyper); ok {
+			return do.MimeType(ctx)
+		}
+	}
+	return fs.MimeTypeFromName(o.remote)
+}
+
+// ID returns the ID of the Object if known, or "" if not
+func (o *Object) ID() string {
+	do, ok := o.Object.(fs.IDer)
+	if !ok {
+		return ""
+	}
+	return do.ID()
+}
+
+// SetTier performs changing storage tier of the Object if
+// multiple storage classes supported
+func (o *Object) SetTier(tier string) error {
+	do, ok := o.Object.(fs.SetTierer)
+	if !ok {
+		return errors.New("compress: underlying remote does not support SetTier")
+	}
+	return do.SetTier(tier)
+}
+
+// GetTier returns storage tier or class of the Object
+func (o *Object) GetTier() string {
+	do, ok := o.Object.(fs.GetTierer)
+	if !ok {
+		return ""
+	}
+	return do.GetTier()
+}
+
+// Metadata returns metadata for an object
+//
+// It should return nil if there is no Metadata
+func (o *Object) Metadata(ctx context.Context) (fs.Metadata, error) {
+	return fs.GetMetadata(ctx, o.Object)
+}
+
+// SetMetadata writes metadata to an existing object
+func (o *Object) SetMetadata(ctx context.Context, metadata fs.Metadata) error {
+	do, ok := o.Object.(fs.SetMetadataer)
+	if !ok {
+		return fs.ErrorCantSetMetadata
+	}
+	return do.SetMetadata(ctx, metadata)
+}
+
+// ObjectInfo describes a wrapped fs.ObjectInfo for being the source
+//
+// This encodes the remote name and adjusts the size
+type ObjectInfo struct {
+	fs.ObjectInfo
+	f          *Fs
+	remote     string
+	size       int64
+	compressed bool
+}
+
+func (f *Fs) newObjectInfo(src fs.ObjectInfo, remote string, size int64, compressed bool) *ObjectInfo {
+	return &ObjectInfo{
+		ObjectInfo: src,
+		f:          f,
+		remote:     remote,
+		size:       size,
+		compressed: compressed,
+	}
+}
+
+// Fs returns read only access to the Fs that this object is part of
+func (o *ObjectInfo) Fs() fs.Info {
+	return o.f
+}
+
+// Remote returns the remote path
+func (o *ObjectInfo) Remote() string {
+	return o.remote
+}
+
+// Size returns the size of the data to be uploaded
+func (o *ObjectInfo) Size() int64 {
+	return o.size
+}
+
+// Hash returns the selected checksum of the file
+// If no checksum is available it returns ""
+func (o *ObjectInfo) Hash(ctx context.Context, ht hash.Type) (string, error) {
+	if o.compressed {
+		return "", nil
+	}
+	return o.ObjectInfo.Hash(ctx, ht)
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs              = (*Fs)(nil)
+	_ fs.Purger          = (*Fs)(nil)
+	_ fs.Copier          = (*Fs)(nil)
+	_ fs.Mover           = (*Fs)(nil)
+	_ fs.DirMover        = (*Fs)(nil)
+	_ fs.PutUncheckeder  = (*Fs)(nil)
+	_ fs.PutStreamer     = (*Fs)(nil)
+	_ fs.CleanUpper      = (*Fs)(nil)
+	_ fs.UnWrapper       = (*Fs)(nil)
+	_ fs.ListRer         = (*Fs)(nil)
+	_ fs.Abouter         = (*Fs)(nil)
+	_ fs.Wrapper         = (*Fs)(nil)
+	_ fs.MergeDirser     = (*Fs)(nil)
+	_ fs.DirCacheFlusher = (*Fs)(nil)
+	_ fs.ChangeNotifier  = (*Fs)(nil)
+	_ fs.PublicLinker    = (*Fs)(nil)
+	_ fs.ObjectInfo      = (*ObjectInfo)(nil)
+	_ fs.Object          = (*Object)(nil)
+	_ fs.ObjectUnWrapper = (*Object)(nil)
+	_ fs.MimeTyper       = (*Object)(nil)
+	_ fs.IDer            = (*Object)(nil)
+	_ fs.SetTierer       = (*Object)(nil)
+	_ fs.GetTierer       = (*Object)(nil)
+	_ fs.Metadataer      = (*Object)(nil)
+	_ fs.SetMetadataer   = (*Object)(nil)
+)
@@ -0,0 +1,135 @@
+// Test Compress filesystem interface
+package compress
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/ncw/rclone/backend/local"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config/configmap"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/fs/object"
+	"github.com/ncw/rclone/fstest"
+	"github.com/ncw/rclone/fstest/fstests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIntegration runs integration tests against the remote
+func TestIntegration(t *testing.T) {
+	if *fstest.RemoteName == "" {
+		t.Skip("Skipping as -remote not set")
+	}
+	fstests.Run(t, &fstests.Opt{
+		RemoteName:               *fstest.RemoteName,
+		NilObject:                (*Object)(nil),
+		UnimplementableFsMethods: []string{"OpenWriterAt"},
+	})
+}
+
+// TestStandard runs integration tests against a local remote
+func TestStandard(t *testing.T) {
+	if *fstest.RemoteName != "" {
+		t.Skip("Skipping as -remote set")
+	}
+	tempdir := filepath.Join(os.TempDir(), "rclone-compress-test-standard")
+	name := "TestCompress"
+	fstests.Run(t, &fstests.Opt{
+		RemoteName: name + ":",
+		NilObject:  (*Object)(nil),
+		ExtraConfig: []fstests.ExtraConfigItem{
+			{Name: name, Key: "type", Value: "compress"},
+			{Name: name, Key: "remote", Value: tempdir},
+		},
+		UnimplementableFsMethods: []string{"OpenWriterAt"},
+	})
+}
+
+func TestNames(t *testing.T) {
+	for _, test := range []struct {
+		remote     string
+		size       int64
+		compressed bool
+		want       string
+	}{
+		{"file.txt", 0, true, "file.txt.0.gz"},
+		{"dir/file.txt", 1234567, true, "dir/file.txt.12d687.gz"},
+		{"file.txt.gz", 255, true, "file.txt.gz.ff.gz"},
+		{"photo.jpg", 100, false, "photo.jpg.bin"},
+		{"file.bin", -1, false, "file.bin.bin"},
+	} {
+		got := encodeName(test.remote, test.size, test.compressed)
+		assert.Equal(t, test.want, got)
+		remote, size, compressed, ok := decodeName(got)
+		assert.True(t, ok, got)
+		assert.Equal(t, test.remote, remote, got)
+		assert.Equal(t, test.compressed, compressed, got)
+		if compressed {
+			assert.Equal(t, test.size, size, got)
+		}
+	}
+	for _, bad := range []string{"", "file.txt", "file.gz", "file.xyz.gz", "file.-1.gz"} {
+		_, _, _, ok := decodeName(bad)
+		assert.False(t, ok, bad)
+	}
+}
+
+// Test a streamed upload which is shorter than its source said is
+// removed
+func TestPutShort(t *testing.T) {
+	ctx := context.Background()
+	tempdir, err := ioutil.TempDir("", "rclone-compress-test-short")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempdir))
+	}()
+	f, err := NewFs("TestCompressShort", "", configmap.Simple{"remote": tempdir})
+	require.NoError(t, err)
+
+	src := object.NewStaticObjectInfo("file.txt", time.Now(), 100, true, nil, nil)
+	_, err = f.Put(ctx, strings.NewReader("too short"), src)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "read 9 bytes expecting 100")
+
+	names, err := ioutil.ReadDir(tempdir)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+}
+
+// Test a root pointing at a file returns the parent directory
+func TestNewFsFile(t *testing.T) {
+	ctx := context.Background()
+	tempdir, err := ioutil.TempDir("", "rclone-compress-test-file")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(tempdir))
+	}()
+	m := configmap.Simple{"remote": tempdir}
+	f, err := NewFs("TestCompressFile", "", m)
+	require.NoError(t, err)
+	assert.Equal(t, hash.Set(hash.None), f.Hashes())
+
+	contents := "hello hello hello hello"
+	src := object.NewStaticObjectInfo("dir/file.txt", time.Now(), int64(len(contents)), true, nil, nil)
+	o, err := f.Put(ctx, strings.NewReader(contents), src)
+	require.NoError(t, err)
+	_, err = o.Hash(ctx, hash.MD5)
+	assert.Equal(t, hash.ErrUnsupported, err)
+
+	fFile, err := NewFs("TestCompressFile", "dir/file.txt", m)
+	assert.Equal(t, fs.ErrorIsFile, err)
+	require.NotNil(t, fFile)
+	assert.Equal(t, "dir", fFile.Root())
+	_, err = fFile.NewObject(ctx, "file.txt")
+	assert.NoError(t, err)
+
+	fDir, err := NewFs("TestCompressFile", "dir/missing", m)
+	assert.NoError(t, err)
+	assert.Equal(t, "dir/missing", fDir.Root())
+}
@@ -136,7 +136,7 @@ func NewFs(name, rpath string, m configmap.Mapper) (fs.Fs, error) {
 		return nil, err
 	}
 	remote := opt.Remote
-	if strings.HasPrefix(remote, name+":") {
+	if strings.HasPrefix(remote, name+":") || strings.HasPrefix(remote, name+",") {
 		return nil, errors.New("can't point crypt remote at itself - check the value of the remote setting")
 	}
 	wInfo, wName, wPath, wConfig, err := fs.ConfigFs(remote)
@@ -870,6 +870,22 @@ func (o *Object) GetTier() string {
 	return do.GetTier()
 }
 
+// Metadata returns metadata for an object
+//
+// It should return nil if there is no Metadata
+func (o *Object) Metadata(ctx context.Context) (fs.Metadata, error) {
+	return fs.GetMetadata(ctx, o.Object)
+}
+
+// SetMetadata writes metadata to an existing object
+func (o *Object) SetMetadata(ctx context.Context, metadata fs.Metadata) error {
+	do, ok := o.Object.(fs.SetMetadataer)
+	if !ok {
+		return fs.ErrorCantSetMetadata
+	}
+	return do.SetMetadata(ctx, metadata)
+}
+
 // Check the interfaces are satisfied
 var (
 	_ fs.Fs              = (*Fs)(nil)
@@ -894,4 +910,6 @@ var (
 	_ fs.IDer            = (*Object)(nil)
 	_ fs.SetTierer       = (*Object)(nil)
 	_ fs.GetTierer       = (*Object)(nil)
+	_ fs.Metadataer      = (*Object)(nil)
+	_ fs.SetMetadataer   = (*Object)(nil)
 )
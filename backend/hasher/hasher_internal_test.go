@@ -0,0 +1,182 @@
+// +build !plan9
+
+package hasher
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/ncw/rclone/backend/memory"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/cache"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/fs/object"
+	"github.com/ncw/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testName    = "TestHasherInternal"
+	testContent = "hello world"
+	testSHA1    = "2aae6c35c94fcfb415dbe95f408b9ce91ee846ed"
+)
+
+// newTestFs makes a hasher remote caching SHA-1 checksums for a
+// memory remote, which only supports MD5, with its DB in a temporary
+// directory
+func newTestFs(t *testing.T, dir string) (f *Fs, cleanup func()) {
+	dbPath, err := ioutil.TempDir("", "rclone-hasher-test-db")
+	require.NoError(t, err)
+	config.FileSet(testName, "type", "hasher")
+	config.FileSet(testName, "remote", testName+"Base:"+dir)
+	config.FileSet(testName, "hashes", "md5,sha1")
+	config.FileSet(testName, "db_path", dbPath)
+	config.FileSet(testName+"Base", "type", "memory")
+	f = openTestFs(t)
+	return f, func() {
+		require.NoError(t, f.Fs.Features().Purge(context.Background()))
+		restart()
+		config.DeleteRemote(testName)
+		config.DeleteRemote(testName + "Base")
+		require.NoError(t, os.RemoveAll(dbPath))
+	}
+}
+
+// openTestFs opens the hasher remote configured by newTestFs
+func openTestFs(t *testing.T) *Fs {
+	f, err := cache.Get(testName + ":")
+	require.NoError(t, err)
+	return f.(*Fs)
+}
+
+// restart closes and forgets all the hash DBs and cached remotes as if
+// rclone had been restarted
+func restart() {
+	hashDBsMu.Lock()
+	defer hashDBsMu.Unlock()
+	for dbPath, d := range hashDBs {
+		d.mu.Lock()
+		if d.timer != nil {
+			d.timer.Stop()
+		}
+		d.mu.Unlock()
+		d.close()
+		delete(hashDBs, dbPath)
+	}
+	cache.Clear()
+}
+
+// putTestObject uploads testContent to remote
+func putTestObject(t *testing.T, f *Fs, remote string) fs.Object {
+	ctx := context.Background()
+	src := object.NewStaticObjectInfo(remote, time.Now(), int64(len(testContent)), true, nil, nil)
+	o, err := f.Put(ctx, bytes.NewBufferString(testContent), src)
+	require.NoError(t, err)
+	return o
+}
+
+// getSHA1 returns the SHA-1 of remote
+func getSHA1(t *testing.T, f *Fs, remote string) string {
+	ctx := context.Background()
+	o, err := f.NewObject(ctx, remote)
+	require.NoError(t, err)
+	sum, err := o.Hash(ctx, hash.SHA1)
+	require.NoError(t, err)
+	return sum
+}
+
+func TestDBPath(t *testing.T) {
+	f, cleanup := newTestFs(t, "dbpath")
+	defer cleanup()
+
+	// db_path is always a directory, even if it looks like a file
+	dbPath := f.opt.DbPath + "/hashes.db"
+	config.FileSet(testName, "db_path", dbPath)
+	restart()
+	f = openTestFs(t)
+	putTestObject(t, f, "file.txt")
+	_, err := os.Stat(dbPath + "/" + testName + ".db")
+	assert.NoError(t, err)
+}
+
+func TestPersist(t *testing.T) {
+	f, cleanup := newTestFs(t, "persist")
+	defer cleanup()
+
+	putTestObject(t, f, "file.txt")
+	assert.Equal(t, testSHA1, getSHA1(t, f, "file.txt"))
+
+	// The checksum must come from the DB as auto_size is off so
+	// nothing would calculate it
+	restart()
+	f = openTestFs(t)
+	assert.Equal(t, testSHA1, getSHA1(t, f, "file.txt"))
+}
+
+func TestMaxAge(t *testing.T) {
+	f, cleanup := newTestFs(t, "maxage")
+	defer cleanup()
+
+	putTestObject(t, f, "file.txt")
+	config.FileSet(testName, "max_age", "1h")
+	restart()
+	f = openTestFs(t)
+	assert.Equal(t, testSHA1, getSHA1(t, f, "file.txt"))
+
+	// Age the record beyond max_age
+	r, err := f.db.get(f.key("file.txt"))
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	r.Created = r.Created.Add(-2 * time.Hour)
+	require.NoError(t, f.db.put(f.key("file.txt"), r))
+	assert.Equal(t, "", getSHA1(t, f, "file.txt"))
+
+	// The record is still used with max_age off
+	config.FileSet(testName, "max_age", "off")
+	restart()
+	f = openTestFs(t)
+	assert.Equal(t, testSHA1, getSHA1(t, f, "file.txt"))
+}
+
+func TestRc(t *testing.T) {
+	ctx := context.Background()
+	f, cleanup := newTestFs(t, "rc")
+	defer cleanup()
+
+	// Upload directly to the wrapped remote so no checksums are stored
+	src := object.NewStaticObjectInfo("dir/file.txt", time.Now(), int64(len(testContent)), true, nil, nil)
+	_, err := f.Fs.Put(ctx, bytes.NewBufferString(testContent), src)
+	require.NoError(t, err)
+	putTestObject(t, f, "dir/other.txt")
+	assert.Equal(t, "", getSHA1(t, f, "dir/file.txt"))
+
+	fill := rc.Calls.Get("hasher/fill")
+	require.NotNil(t, fill)
+	out, err := fill.Fn(ctx, rc.Params{"fs": testName + ":dir"})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{"hashed": 1, "skipped": 1}, out)
+	assert.Equal(t, testSHA1, getSHA1(t, f, "dir/file.txt"))
+
+	export := rc.Calls.Get("hasher/export")
+	require.NotNil(t, export)
+	out, err = export.Fn(ctx, rc.Params{"fs": testName + ":dir"})
+	require.NoError(t, err)
+	hashes, ok := out["hashes"].(map[string]*hashRecord)
+	require.True(t, ok)
+	require.Len(t, hashes, 2)
+	for _, remote := range []string{"file.txt", "other.txt"} {
+		r := hashes[remote]
+		require.NotNil(t, r, remote)
+		assert.Equal(t, int64(len(testContent)), r.Size)
+		assert.Equal(t, testSHA1, r.get(hash.SHA1))
+	}
+
+	_, err = fill.Fn(ctx, rc.Params{"fs": testName + "Base:"})
+	assert.Error(t, err)
+}
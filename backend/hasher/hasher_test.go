@@ -0,0 +1,88 @@
+// Test Hasher filesystem interface
+
+// +build !plan9
+
+package hasher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/ncw/rclone/backend/crypt"
+	_ "github.com/ncw/rclone/backend/local"
+	"github.com/ncw/rclone/fs/config/obscure"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/fstest"
+	"github.com/ncw/rclone/fstest/fstests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIntegration runs integration tests against the remote
+func TestIntegration(t *testing.T) {
+	if *fstest.RemoteName == "" {
+		t.Skip("Skipping as -remote not set")
+	}
+	fstests.Run(t, &fstests.Opt{
+		RemoteName:               *fstest.RemoteName,
+		NilObject:                (*Object)(nil),
+		UnimplementableFsMethods: []string{"OpenWriterAt"},
+	})
+}
+
+// TestStandard runs integration tests against a local remote
+func TestStandard(t *testing.T) {
+	if *fstest.RemoteName != "" {
+		t.Skip("Skipping as -remote set")
+	}
+	tempdir := filepath.Join(os.TempDir(), "rclone-hasher-test-standard")
+	name := "TestHasher"
+	fstests.Run(t, &fstests.Opt{
+		RemoteName: name + ":",
+		NilObject:  (*Object)(nil),
+		ExtraConfig: []fstests.ExtraConfigItem{
+			{Name: name, Key: "type", Value: "hasher"},
+			{Name: name, Key: "remote", Value: tempdir},
+			{Name: name, Key: "db_path", Value: tempdir + "-db"},
+		},
+		UnimplementableFsMethods: []string{"OpenWriterAt"},
+	})
+}
+
+// TestCrypt runs integration tests against a crypt remote which
+// doesn't support any hashes so they are all cached
+func TestCrypt(t *testing.T) {
+	if *fstest.RemoteName != "" {
+		t.Skip("Skipping as -remote set")
+	}
+	tempdir := filepath.Join(os.TempDir(), "rclone-hasher-test-crypt")
+	name := "TestHasherCrypt"
+	fstests.Run(t, &fstests.Opt{
+		RemoteName: name + ":",
+		NilObject:  (*Object)(nil),
+		ExtraConfig: []fstests.ExtraConfigItem{
+			{Name: name, Key: "type", Value: "hasher"},
+			{Name: name, Key: "remote", Value: name + "Base:"},
+			{Name: name, Key: "hashes", Value: "md5,sha1,whirlpool"},
+			{Name: name, Key: "db_path", Value: tempdir + "-db"},
+			{Name: name + "Base", Key: "type", Value: "crypt"},
+			{Name: name + "Base", Key: "remote", Value: tempdir},
+			{Name: name + "Base", Key: "password", Value: obscure.MustObscure("potato")},
+		},
+		UnimplementableFsMethods: []string{"OpenWriterAt"},
+	})
+}
+
+func TestParseHashes(t *testing.T) {
+	set, err := parseHashes([]string{"md5", "SHA1", " whirlpool"})
+	require.NoError(t, err)
+	assert.Equal(t, hash.NewHashSet(hash.MD5, hash.SHA1, hash.Whirlpool), set)
+
+	set, err = parseHashes(nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, set.Count())
+
+	_, err = parseHashes([]string{"md5", "crc32"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,6 @@
+// Build for hasher for unsupported platforms to stop go complaining
+// about "no buildable Go source files "
+
+// +build plan9
+
+package hasher
@@ -0,0 +1,282 @@
+// +build !plan9
+
+package hasher
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "github.com/coreos/bbolt"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/pkg/errors"
+)
+
+const (
+	hashBucket = "hashes"
+	dbWaitTime = 5 * time.Second  // time to wait for another rclone to release the DB
+	dbIdleTime = 10 * time.Second // time the DB is kept open after last use
+)
+
+// hashRecord is what is stored in the DB for each object
+//
+// The hashes are only valid while the size and modification time of
+// the object match the fingerprint.
+type hashRecord struct {
+	Size    int64             `json:"size"`
+	ModTime time.Time         `json:"modtime"`
+	Created time.Time         `json:"created"`
+	Hashes  map[string]string `json:"hashes"`
+}
+
+// matches returns true if the record is valid for an object of this
+// size and modification time
+func (r *hashRecord) matches(size int64, modTime time.Time) bool {
+	return r.Size == size && r.ModTime.Equal(modTime)
+}
+
+// expired returns true if the record was stored longer ago than maxAge
+func (r *hashRecord) expired(maxAge fs.Duration) bool {
+	return maxAge.IsSet() && time.Since(r.Created) > time.Duration(maxAge)
+}
+
+// get returns the hash of type ht from the record or ""
+func (r *hashRecord) get(ht hash.Type) string {
+	return r.Hashes[ht.String()]
+}
+
+// newHashRecord makes a hashRecord from the sums passed in
+func newHashRecord(size int64, modTime time.Time, sums map[hash.Type]string) *hashRecord {
+	r := &hashRecord{
+		Size:    size,
+		ModTime: modTime,
+		Created: time.Now(),
+		Hashes:  make(map[string]string, len(sums)),
+	}
+	for ht, sum := range sums {
+		if sum != "" {
+			r.Hashes[ht.String()] = sum
+		}
+	}
+	return r
+}
+
+// hashDB is a bolt DB holding the hashes of the objects of a remote
+//
+// The DB is opened on first use and closed again when it has been idle
+// for a while so other rclone processes can use the same remote.
+type hashDB struct {
+	path  string
+	mu    sync.Mutex
+	db    *bolt.DB
+	timer *time.Timer
+}
+
+var (
+	hashDBsMu sync.Mutex
+	hashDBs   = make(map[string]*hashDB)
+)
+
+// getHashDB returns the single instance of the DB stored at dbPath
+func getHashDB(dbPath string) (*hashDB, error) {
+	hashDBsMu.Lock()
+	defer hashDBsMu.Unlock()
+	if d, ok := hashDBs[dbPath]; ok {
+		return d, nil
+	}
+	err := os.MkdirAll(filepath.Dir(dbPath), os.ModePerm)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create hash DB directory %q", filepath.Dir(dbPath))
+	}
+	d := &hashDB{path: dbPath}
+	hashDBs[dbPath] = d
+	return d, nil
+}
+
+// String returns a description of the DB
+func (d *hashDB) String() string {
+	return "<Hash DB> " + d.path
+}
+
+// open the DB if necessary and (re)start the idle timer - call with mu held
+func (d *hashDB) open() error {
+	if d.db == nil {
+		db, err := bolt.Open(d.path, 0600, &bolt.Options{Timeout: dbWaitTime})
+		if err != nil {
+			return errors.Wrapf(err, "failed to open hash DB %q - is another rclone using it?", d.path)
+		}
+		err = db.Update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists([]byte(hashBucket))
+			return err
+		})
+		if err != nil {
+			_ = db.Close()
+			return errors.Wrapf(err, "failed to initialise hash DB %q", d.path)
+		}
+		d.db = db
+	}
+	if d.timer == nil {
+		d.timer = time.AfterFunc(dbIdleTime, d.close)
+	} else {
+		d.timer.Reset(dbIdleTime)
+	}
+	return nil
+}
+
+// close the DB when it has become idle
+func (d *hashDB) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.db != nil {
+		err := d.db.Close()
+		if err != nil {
+			fs.Errorf(d, "Failed to close: %v", err)
+		}
+		d.db = nil
+	}
+}
+
+// do runs fn on the hash bucket in a read only or a read-write
+// transaction
+func (d *hashDB) do(write bool, fn func(b *bolt.Bucket) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err := d.open()
+	if err != nil {
+		return err
+	}
+	txFn := func(tx *bolt.Tx) error {
+		return fn(tx.Bucket([]byte(hashBucket)))
+	}
+	if write {
+		return d.db.Update(txFn)
+	}
+	return d.db.View(txFn)
+}
+
+// get the record stored under key or nil if not found
+func (d *hashDB) get(key string) (r *hashRecord, err error) {
+	err = d.do(false, func(b *bolt.Bucket) error {
+		data := b.Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		r = new(hashRecord)
+		return json.Unmarshal(data, r)
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read hashes of %q", key)
+	}
+	return r, nil
+}
+
+// put stores r under key
+func (d *hashDB) put(key string, r *hashRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	err = d.do(true, func(b *bolt.Bucket) error {
+		return b.Put([]byte(key), data)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to store hashes of %q", key)
+	}
+	return nil
+}
+
+// delete the record stored under key
+func (d *hashDB) delete(key string) error {
+	return d.do(true, func(b *bolt.Bucket) error {
+		return b.Delete([]byte(key))
+	})
+}
+
+// move the record stored under srcKey to dstKey
+func (d *hashDB) move(srcKey, dstKey string) error {
+	return d.do(true, func(b *bolt.Bucket) error {
+		data := b.Get([]byte(srcKey))
+		if data == nil {
+			return nil
+		}
+		err := b.Put([]byte(dstKey), append([]byte(nil), data...))
+		if err != nil {
+			return err
+		}
+		return b.Delete([]byte(srcKey))
+	})
+}
+
+// dirPrefix returns the prefix of the keys of the objects in dir
+func dirPrefix(dir string) string {
+	if dir == "" || strings.HasSuffix(dir, "/") {
+		return dir
+	}
+	return dir + "/"
+}
+
+// walk calls fn for each record with a key in dir
+func (d *hashDB) walk(dir string, fn func(key string, r *hashRecord) error) error {
+	prefix := []byte(dirPrefix(dir))
+	return d.do(false, func(b *bolt.Bucket) error {
+		c := b.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			r := new(hashRecord)
+			if err := json.Unmarshal(v, r); err != nil {
+				fs.Debugf(d, "Ignoring corrupt record %q: %v", k, err)
+				continue
+			}
+			if err := fn(string(k), r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// moveDir renames the keys of all the records in srcDir into dstDir
+func (d *hashDB) moveDir(srcDir, dstDir string) error {
+	srcPrefix, dstPrefix := []byte(dirPrefix(srcDir)), dirPrefix(dstDir)
+	return d.do(true, func(b *bolt.Bucket) error {
+		var keys [][]byte
+		c := b.Cursor()
+		for k, _ := c.Seek(srcPrefix); k != nil && bytes.HasPrefix(k, srcPrefix); k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for _, k := range keys {
+			data := append([]byte(nil), b.Get(k)...)
+			err := b.Put([]byte(dstPrefix+string(k[len(srcPrefix):])), data)
+			if err != nil {
+				return err
+			}
+			err = b.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// deleteDir removes all the records in dir
+func (d *hashDB) deleteDir(dir string) error {
+	prefix := []byte(dirPrefix(dir))
+	return d.do(true, func(b *bolt.Bucket) error {
+		var keys [][]byte
+		c := b.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
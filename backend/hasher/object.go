@@ -0,0 +1,300 @@
+// +build !plan9
+
+package hasher
+
+import (
+	"context"
+	"io"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/pkg/errors"
+)
+
+// Object represents an object on the wrapped remote with cached hashes
+type Object struct {
+	fs.Object
+	f *Fs
+}
+
+func (f *Fs) newObject(o fs.Object) *Object {
+	return &Object{
+		Object: o,
+		f:      f,
+	}
+}
+
+// Fs returns read only access to the Fs that this object is part of
+func (o *Object) Fs() fs.Info {
+	return o.f
+}
+
+// Return a string version
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.Object.String()
+}
+
+// UnWrap returns the wrapped Object
+func (o *Object) UnWrap() fs.Object {
+	return o.Object
+}
+
+// key returns the key of the object in the hash DB
+func (o *Object) key() string {
+	return o.f.key(o.Remote())
+}
+
+// getRecord returns the hashes stored for the object or nil if they
+// are missing, out of date or older than max_age
+func (o *Object) getRecord(ctx context.Context) (*hashRecord, error) {
+	r, err := o.f.db.get(o.key())
+	if err != nil || r == nil {
+		return nil, err
+	}
+	if !r.matches(o.Size(), o.ModTime(ctx)) {
+		fs.Debugf(o, "Ignoring out of date hashes")
+		return nil, nil
+	}
+	if r.expired(o.f.opt.MaxAge) {
+		fs.Debugf(o, "Ignoring hashes older than %v", o.f.opt.MaxAge)
+		return nil, nil
+	}
+	return r, nil
+}
+
+// storeHashes stores the sums calculated by hasher if all of the object
+// was hashed
+func (o *Object) storeHashes(ctx context.Context, hasher *hash.MultiHasher) {
+	if hasher.Size() != o.Size() {
+		fs.Debugf(o, "Not storing hashes as only read %d of %d bytes", hasher.Size(), o.Size())
+		return
+	}
+	err := o.f.db.put(o.key(), newHashRecord(o.Size(), o.ModTime(ctx), hasher.Sums()))
+	if err != nil {
+		fs.Errorf(o, "Failed to store hashes: %v", err)
+	}
+}
+
+// copyHashes stores the hashes of src for the object if they are valid
+func (o *Object) copyHashes(ctx context.Context, src *Object) {
+	r, err := src.getRecord(ctx)
+	if err != nil {
+		fs.Errorf(src, "Failed to read hashes: %v", err)
+		return
+	}
+	if r == nil || src.Size() != o.Size() {
+		return
+	}
+	r.ModTime = o.ModTime(ctx)
+	err = o.f.db.put(o.key(), r)
+	if err != nil {
+		fs.Errorf(o, "Failed to store hashes: %v", err)
+	}
+}
+
+// updateHashes reads the object to calculate and store its hashes
+func (o *Object) updateHashes(ctx context.Context) (*hashRecord, error) {
+	hasher, err := hash.NewMultiHasherTypes(o.f.cachedHashes())
+	if err != nil {
+		return nil, err
+	}
+	in, err := o.Object.Open(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open for hashing")
+	}
+	_, err = io.Copy(hasher, in)
+	fs.CheckClose(in, &err)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read for hashing")
+	}
+	if hasher.Size() != o.Size() {
+		return nil, errors.Errorf("read %d bytes expecting %d while hashing", hasher.Size(), o.Size())
+	}
+	r := newHashRecord(o.Size(), o.ModTime(ctx), hasher.Sums())
+	err = o.f.db.put(o.key(), r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Hash returns the selected checksum of the file
+// If no checksum is available it returns ""
+//
+// Hashes supported by the wrapped remote are passed through, the
+// others are read from the DB.
+func (o *Object) Hash(ctx context.Context, ht hash.Type) (string, error) {
+	if o.f.Fs.Hashes().Contains(ht) {
+		return o.Object.Hash(ctx, ht)
+	}
+	if !o.f.hashes.Contains(ht) {
+		return "", hash.ErrUnsupported
+	}
+	r, err := o.getRecord(ctx)
+	if err != nil {
+		return "", err
+	}
+	if r == nil && o.f.opt.AutoSize > 0 && o.Size() >= 0 && o.Size() <= int64(o.f.opt.AutoSize) {
+		r, err = o.updateHashes(ctx)
+		if err != nil {
+			return "", err
+		}
+	}
+	if r == nil {
+		return "", nil
+	}
+	return r.get(ht), nil
+}
+
+// hashingReader calculates the hashes of an object as it is read and
+// stores them when it is closed if all of the object was read
+type hashingReader struct {
+	io.ReadCloser
+	ctx    context.Context
+	o      *Object
+	hasher *hash.MultiHasher
+	err    error
+}
+
+// Read bytes from the object, hashing them
+func (r *hashingReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	_, _ = r.hasher.Write(p[:n])
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// Close the object, storing the hashes
+func (r *hashingReader) Close() error {
+	err := r.ReadCloser.Close()
+	if err == nil && r.err == nil {
+		r.o.storeHashes(r.ctx, r.hasher)
+	}
+	return err
+}
+
+// Open opens the file for read.  Call Close() on the returned io.ReadCloser
+//
+// If the whole object is read, any hashes missing from the DB are
+// calculated on the way.
+func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	in, err := o.Object.Open(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+	hashes := o.f.cachedHashes()
+	if hashes.Count() == 0 || len(options) != 0 {
+		return in, nil
+	}
+	if r, err := o.getRecord(ctx); err != nil || r != nil {
+		return in, nil
+	}
+	hasher, err := hash.NewMultiHasherTypes(hashes)
+	if err != nil {
+		_ = in.Close()
+		return nil, err
+	}
+	return &hashingReader{
+		ReadCloser: in,
+		ctx:        ctx,
+		o:          o,
+		hasher:     hasher,
+	}, nil
+}
+
+// Update in to the object with the modTime given of the given size
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	hashes := o.f.cachedHashes()
+	if hashes.Count() == 0 {
+		return o.Object.Update(ctx, in, src, options...)
+	}
+	hasher, err := hash.NewMultiHasherTypes(hashes)
+	if err != nil {
+		return err
+	}
+	err = o.Object.Update(ctx, io.TeeReader(in, hasher), src, options...)
+	if err != nil {
+		return err
+	}
+	o.storeHashes(ctx, hasher)
+	return nil
+}
+
+// Remove an object
+func (o *Object) Remove(ctx context.Context) error {
+	err := o.Object.Remove(ctx)
+	if err != nil {
+		return err
+	}
+	if err := o.f.db.delete(o.key()); err != nil {
+		fs.Errorf(o, "Failed to remove hashes: %v", err)
+	}
+	return nil
+}
+
+// MimeType returns the content type of the Object if
+// known, or "" if not
+func (o *Object) MimeType(ctx context.Context) string {
+	return fs.MimeType(ctx, o.Object)
+}
+
+// ID returns the ID of the Object if known, or "" if not
+func (o *Object) ID() string {
+	do, ok := o.Object.(fs.IDer)
+	if !ok {
+		return ""
+	}
+	return do.ID()
+}
+
+// SetTier performs changing storage tier of the Object if
+// multiple storage classes supported
+func (o *Object) SetTier(tier string) error {
+	do, ok := o.Object.(fs.SetTierer)
+	if !ok {
+		return errors.New("hasher: underlying remote does not support SetTier")
+	}
+	return do.SetTier(tier)
+}
+
+// GetTier returns storage tier or class of the Object
+func (o *Object) GetTier() string {
+	do, ok := o.Object.(fs.GetTierer)
+	if !ok {
+		return ""
+	}
+	return do.GetTier()
+}
+
+// Metadata returns metadata for an object
+//
+// It should return nil if there is no Metadata
+func (o *Object) Metadata(ctx context.Context) (fs.Metadata, error) {
+	return fs.GetMetadata(ctx, o.Object)
+}
+
+// SetMetadata writes metadata to an existing object
+func (o *Object) SetMetadata(ctx context.Context, metadata fs.Metadata) error {
+	do, ok := o.Object.(fs.SetMetadataer)
+	if !ok {
+		return fs.ErrorCantSetMetadata
+	}
+	return do.SetMetadata(ctx, metadata)
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Object          = (*Object)(nil)
+	_ fs.ObjectUnWrapper = (*Object)(nil)
+	_ fs.MimeTyper       = (*Object)(nil)
+	_ fs.IDer            = (*Object)(nil)
+	_ fs.SetTierer       = (*Object)(nil)
+	_ fs.GetTierer       = (*Object)(nil)
+	_ fs.Metadataer      = (*Object)(nil)
+	_ fs.SetMetadataer   = (*Object)(nil)
+)
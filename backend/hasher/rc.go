@@ -0,0 +1,144 @@
+// +build !plan9
+
+package hasher
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/rc"
+	"github.com/ncw/rclone/fs/walk"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "hasher/fill",
+		Fn:    rcFill,
+		Title: "Fill the checksum DB of a hasher remote",
+		Help: `
+This reads every file in a hasher remote which doesn't have up to date
+checksums in the DB and stores them. This downloads the files so can
+take a long time - consider using _async=true.
+
+Params:
+  - fs = hasher remote, eg "myhasher:" or "myhasher:path/to/dir" (required)
+
+Eg
+
+    rclone rc hasher/fill fs=myhasher:path/to/dir
+
+Returns the number of files checksummed and the number of files which
+already had up to date checksums.
+`,
+	})
+	rc.Add(rc.Call{
+		Path:  "hasher/export",
+		Fn:    rcExport,
+		Title: "Export the checksum DB of a hasher remote",
+		Help: `
+This returns the checksums stored in the DB for the files in a hasher
+remote, including ones which may be out of date.
+
+Params:
+  - fs = hasher remote, eg "myhasher:" or "myhasher:path/to/dir" (required)
+
+Eg
+
+    rclone rc hasher/export fs=myhasher:
+
+Returns
+
+    {
+        "hashes": {
+            "path/to/file": {
+                "size": 1234,
+                "modtime": "2019-07-15T12:00:00.000000000Z",
+                "hashes": {
+                    "MD5": "...",
+                    "SHA-1": "..."
+                }
+            }
+        }
+    }
+`,
+	})
+}
+
+// getHasherFs gets the hasher Fs named by the "fs" parameter
+func getHasherFs(in rc.Params) (*Fs, error) {
+	f, err := rc.GetFs(in)
+	if err != nil {
+		return nil, err
+	}
+	hf, ok := f.(*Fs)
+	if !ok {
+		return nil, errors.Errorf("%v is not a hasher remote", f)
+	}
+	return hf, nil
+}
+
+// rcFill calculates and stores the missing hashes of a hasher remote
+func rcFill(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	f, err := getHasherFs(in)
+	if err != nil {
+		return nil, err
+	}
+	if f.cachedHashes().Count() == 0 {
+		return nil, errors.Errorf("%v supports all the configured hashes so there is nothing to cache", f.Fs)
+	}
+	hashed, skipped := 0, 0
+	err = walk.ListR(ctx, f, "", true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		for _, entry := range entries {
+			o, ok := entry.(*Object)
+			if !ok {
+				continue
+			}
+			r, err := o.getRecord(ctx)
+			if err != nil {
+				return err
+			}
+			if r != nil {
+				skipped++
+				continue
+			}
+			_, err = o.updateHashes(ctx)
+			if err != nil {
+				fs.Errorf(o, "Failed to calculate hashes: %v", err)
+				continue
+			}
+			fs.Debugf(o, "Stored hashes")
+			hashed++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rc.Params{
+		"hashed":  hashed,
+		"skipped": skipped,
+	}, nil
+}
+
+// rcExport returns the contents of the hash DB of a hasher remote
+func rcExport(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	f, err := getHasherFs(in)
+	if err != nil {
+		return nil, err
+	}
+	root := f.key("")
+	prefix := dirPrefix(root)
+	hashes := make(map[string]*hashRecord)
+	err = f.db.walk(root, func(key string, r *hashRecord) error {
+		hashes[strings.TrimPrefix(key, prefix)] = r
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rc.Params{
+		"hashes": hashes,
+	}, nil
+}
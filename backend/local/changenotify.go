@@ -0,0 +1,319 @@
+// Notifications of changes to the local file system
+
+package local
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+)
+
+// ChangeNotify calls the passed function with a path that has had
+// changes.
+//
+// Where the OS supports it (inotify on Linux) the directory tree is
+// watched and changes are notified as they happen, otherwise, or if
+// watching fails, the tree is scanned for changes every poll
+// interval.
+//
+// Walking the tree to start watching or take the first scan happens
+// in the background so this returns straight away.  The walk notifies
+// the entries modified since this was called so changes made before
+// it reaches them aren't missed.
+//
+// Close the returned channel or cancel ctx to stop being notified.
+func (f *Fs) ChangeNotify(ctx context.Context, notifyFunc func(string, fs.EntryType), pollIntervalChan <-chan time.Duration) {
+	go f.changeNotify(ctx, notifyFunc, pollIntervalChan, changedSince())
+}
+
+// changedSince returns the time from which entries found by a walk
+// starting now should be notified
+//
+// This is a little in the past as file systems may set modification
+// times from a coarser clock.
+func changedSince() time.Time {
+	return time.Now().Add(-time.Second)
+}
+
+// changeStart is the result of starting to look for changes
+type changeStart struct {
+	w    *watcher            // set if watching for changes
+	dirs map[string]*pollDir // set if polling for changes
+}
+
+// changeNotify runs the loop which looks for changes for ChangeNotify
+func (f *Fs) changeNotify(ctx context.Context, notifyFunc func(string, fs.EntryType), pollIntervalChan <-chan time.Duration, since time.Time) {
+	var (
+		w            *watcher
+		failed       <-chan struct{}
+		ticker       *time.Ticker
+		tickerC      <-chan time.Time
+		dirs         map[string]*pollDir // nil if not polling
+		starting     chan changeStart    // set while starting
+		pollInterval time.Duration
+	)
+	// start watching for changes, or if watch isn't set or that
+	// fails take the first scan of the tree to poll for them, in
+	// the background, notifying the entries modified since since
+	start := func(watch bool, since time.Time) {
+		starting = make(chan changeStart, 1)
+		go func(starting chan<- changeStart) {
+			if watch {
+				w, err := newWatcher(f, notifyFunc, since)
+				if err == nil {
+					fs.Debugf(f, "Watching for changes")
+					starting <- changeStart{w: w}
+					return
+				}
+				fs.Debugf(f, "Polling for changes: %v", err)
+			}
+			dirs := f.scanTree()
+			f.notifySince(dirs, notifyFunc, since)
+			starting <- changeStart{dirs: dirs}
+		}(starting)
+	}
+	startTicker := func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+		ticker = time.NewTicker(pollInterval)
+		tickerC = ticker.C
+	}
+	stop := func() {
+		if starting != nil {
+			// Stop the watcher when it has started
+			go func(starting <-chan changeStart) {
+				if started := <-starting; started.w != nil {
+					started.w.close()
+				}
+			}(starting)
+			starting = nil
+		}
+		if w != nil {
+			w.close()
+			w, failed = nil, nil
+		}
+		if ticker != nil {
+			ticker.Stop()
+			ticker, tickerC = nil, nil
+		}
+		dirs = nil
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			stop()
+			return
+		case newInterval, ok := <-pollIntervalChan:
+			if !ok {
+				stop()
+				return
+			}
+			pollInterval = newInterval
+			if pollInterval == 0 {
+				stop()
+				since = time.Time{}
+				continue
+			}
+			if since.IsZero() {
+				since = changedSince()
+			}
+			if w == nil && dirs == nil && starting == nil {
+				start(true, since)
+			}
+			if dirs != nil {
+				startTicker()
+			}
+		case started := <-starting:
+			starting = nil
+			w, dirs = started.w, started.dirs
+			if w != nil {
+				failed = w.failed
+			} else {
+				startTicker()
+			}
+		case <-failed:
+			fs.Logf(f, "Watching for changes failed - polling for changes every %v instead", pollInterval)
+			stop()
+			start(false, changedSince())
+		case <-tickerC:
+			dirs = f.pollChanges(dirs, notifyFunc)
+		}
+	}
+}
+
+// notifyChange calls notifyFunc with the remote for relPath, the OS
+// path of the entry relative to the root
+func (f *Fs) notifyChange(notifyFunc func(string, fs.EntryType), relPath string, isDir, isLink bool) {
+	remote := f.cleanRemote(relPath)
+	if isDir {
+		notifyFunc(f.dirNames.Save(filepath.ToSlash(relPath), remote), fs.EntryDirectory)
+		return
+	}
+	if isLink && f.opt.TranslateSymlinks {
+		remote += linkSuffix
+	}
+	notifyFunc(remote, fs.EntryObject)
+}
+
+// pollEntry is the state of an entry when the tree was last scanned
+type pollEntry struct {
+	isDir   bool
+	isLink  bool
+	size    int64
+	modTime time.Time
+	dev     uint64 // device of a directory
+}
+
+// newPollEntry makes a pollEntry from the result of an Lstat
+func (f *Fs) newPollEntry(fi os.FileInfo) pollEntry {
+	entry := pollEntry{
+		isDir:   fi.IsDir(),
+		isLink:  fi.Mode()&os.ModeSymlink != 0,
+		size:    fi.Size(),
+		modTime: fi.ModTime(),
+	}
+	if entry.isDir {
+		entry.dev = readDevice(fi, f.opt.OneFileSystem)
+	}
+	return entry
+}
+
+// changed returns true if the entry needs notifying because it has
+// changed from old
+//
+// Directories only change when they are created or removed as their
+// modification times change whenever their contents do.
+func (entry pollEntry) changed(old pollEntry) bool {
+	if entry.isDir != old.isDir {
+		return true
+	}
+	return !entry.isDir && (entry.size != old.size || !entry.modTime.Equal(old.modTime))
+}
+
+// pollDir is the state of a directory when the tree was last scanned
+type pollDir struct {
+	modTime time.Time            // modification time of the directory
+	entries map[string]pollEntry // entries in the directory by name
+}
+
+// scanTree reads the state of all the directories under the root,
+// indexed by their OS path relative to the root
+func (f *Fs) scanTree() map[string]*pollDir {
+	return f.pollChanges(nil, nil)
+}
+
+// notifySince notifies the entries in dirs modified at or after since
+//
+// A directory is notified if its modification time has changed so
+// entries removed from it are seen too.
+func (f *Fs) notifySince(dirs map[string]*pollDir, notifyFunc func(string, fs.EntryType), since time.Time) {
+	if root := dirs[""]; root != nil && !root.modTime.Before(since) {
+		f.notifyChange(notifyFunc, "", true, false)
+	}
+	for relDir, dir := range dirs {
+		for name, entry := range dir.entries {
+			if !entry.modTime.Before(since) {
+				f.notifyChange(notifyFunc, filepath.Join(relDir, name), entry.isDir, entry.isLink)
+			}
+		}
+	}
+}
+
+// pollChanges scans the tree notifying the entries which have changed
+// since it was scanned into old, returning the new state
+//
+// Only the directories whose modification times have changed are read
+// again.  The entries in the others are checked with Lstat.
+//
+// If notifyFunc is nil nothing is notified.
+func (f *Fs) pollChanges(old map[string]*pollDir, notifyFunc func(string, fs.EntryType)) map[string]*pollDir {
+	dirs := make(map[string]*pollDir, len(old))
+	f.pollDir(old, dirs, "", notifyFunc)
+	if notifyFunc == nil {
+		return dirs
+	}
+	// Notify the entries in directories which have gone
+	for relDir, oldDir := range old {
+		if _, ok := dirs[relDir]; ok {
+			continue
+		}
+		for name, oldEntry := range oldDir.entries {
+			relPath := filepath.Join(relDir, name)
+			if oldEntry.isDir {
+				if _, ok := dirs[relPath]; ok {
+					continue
+				}
+			}
+			f.notifyChange(notifyFunc, relPath, oldEntry.isDir, oldEntry.isLink)
+		}
+	}
+	return dirs
+}
+
+// pollDir scans the directory relDir and the directories under it
+// into dirs, notifying the entries which have changed since old
+func (f *Fs) pollDir(old, dirs map[string]*pollDir, relDir string, notifyFunc func(string, fs.EntryType)) {
+	osDir := filepath.Join(f.root, relDir)
+	fi, err := os.Lstat(osDir)
+	if err != nil || !fi.IsDir() {
+		// Ignore directories which can't be read
+		return
+	}
+	oldDir := old[relDir]
+	dir := &pollDir{
+		modTime: fi.ModTime(),
+		entries: make(map[string]pollEntry),
+	}
+	if oldDir != nil && oldDir.modTime.Equal(dir.modTime) {
+		// No entries have been added or removed so only check
+		// the ones already known
+		for name := range oldDir.entries {
+			fi, err := os.Lstat(filepath.Join(osDir, name))
+			if err == nil {
+				dir.entries[name] = f.newPollEntry(fi)
+			}
+		}
+	} else {
+		fd, err := os.Open(osDir)
+		if err != nil {
+			return
+		}
+		fis, _ := fd.Readdir(-1)
+		_ = fd.Close()
+		for _, fi := range fis {
+			dir.entries[fi.Name()] = f.newPollEntry(fi)
+		}
+	}
+	dirs[relDir] = dir
+	for name, entry := range dir.entries {
+		relPath := filepath.Join(relDir, name)
+		if entry.isDir {
+			if entry.dev != f.dev {
+				delete(dir.entries, name)
+				continue
+			}
+			f.pollDir(old, dirs, relPath, notifyFunc)
+		}
+		if notifyFunc == nil {
+			continue
+		}
+		if oldDir != nil {
+			if oldEntry, ok := oldDir.entries[name]; ok && !entry.changed(oldEntry) {
+				continue
+			}
+		}
+		f.notifyChange(notifyFunc, relPath, entry.isDir, entry.isLink)
+	}
+	if notifyFunc == nil || oldDir == nil {
+		return
+	}
+	for name, oldEntry := range oldDir.entries {
+		if _, ok := dir.entries[name]; !ok {
+			f.notifyChange(notifyFunc, filepath.Join(relDir, name), oldEntry.isDir, oldEntry.isLink)
+		}
+	}
+}
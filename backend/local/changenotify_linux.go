@@ -0,0 +1,235 @@
+// +build linux
+
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// watchMask is the inotify events watched for on each directory
+const watchMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_ATTRIB |
+	unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE_SELF |
+	unix.IN_ONLYDIR | unix.IN_DONT_FOLLOW | unix.IN_EXCL_UNLINK
+
+// watcher watches the directory tree under the root of an Fs for
+// changes with inotify
+//
+// inotify only watches single directories so a watch is added for
+// every directory in the tree, and for new directories as they
+// appear.
+type watcher struct {
+	f          *Fs
+	notifyFunc func(string, fs.EntryType)
+	fd         int            // the inotify instance
+	file       *os.File       // fd as a file so reads can be interrupted
+	paths      map[int]string // relative path of each watched directory by watch descriptor
+	wds        map[string]int // watch descriptor by relative path
+	quit       chan struct{}  // closed to stop the watcher
+	failed     chan struct{}  // closed if the watcher stops because of an error
+	done       chan struct{}  // closed when the watcher has stopped
+}
+
+// newWatcher starts watching the tree under the root of f, notifying
+// the entries modified at or after since
+func newWatcher(f *Fs, notifyFunc func(string, fs.EntryType), since time.Time) (*watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to start inotify")
+	}
+	w := &watcher{
+		f:          f,
+		notifyFunc: notifyFunc,
+		fd:         fd,
+		file:       os.NewFile(uintptr(fd), "inotify"),
+		paths:      make(map[int]string),
+		wds:        make(map[string]int),
+		quit:       make(chan struct{}),
+		failed:     make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	err = w.addTree("", since)
+	if err != nil {
+		_ = w.file.Close()
+		return nil, err
+	}
+	go w.run()
+	return w, nil
+}
+
+// addWatch watches the directory relPath
+func (w *watcher) addWatch(relPath string) error {
+	wd, err := unix.InotifyAddWatch(w.fd, filepath.Join(w.f.root, relPath), watchMask)
+	if err != nil {
+		return err
+	}
+	w.paths[wd] = relPath
+	w.wds[relPath] = wd
+	return nil
+}
+
+// addTree watches the directory relPath and all the directories
+// under it
+//
+// The entries modified at or after since are notified as they may
+// have changed before they were watched.  Pass the zero time to
+// notify all the entries under relPath.  relPath itself is only
+// notified if it is the root.
+//
+// Directories which disappear or can't be read are skipped, but
+// running out of watches is an error.
+func (w *watcher) addTree(relPath string, since time.Time) error {
+	root := filepath.Join(w.f.root, relPath)
+	return filepath.Walk(root, func(osPath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if osPath == root && relPath == "" {
+				return err
+			}
+			return nil
+		}
+		if fi.IsDir() && osPath != w.f.root && w.f.dev != readDevice(fi, w.f.opt.OneFileSystem) {
+			return filepath.SkipDir
+		}
+		dirPath, err := filepath.Rel(w.f.root, osPath)
+		if err != nil {
+			return nil
+		}
+		if dirPath == "." {
+			dirPath = ""
+		}
+		if (osPath != root || relPath == "") && !fi.ModTime().Before(since) {
+			w.f.notifyChange(w.notifyFunc, dirPath, fi.IsDir(), fi.Mode()&os.ModeSymlink != 0)
+		}
+		if !fi.IsDir() {
+			return nil
+		}
+		err = w.addWatch(dirPath)
+		switch err {
+		case nil:
+		case unix.ENOSPC:
+			return errors.New("out of inotify watches - increase fs.inotify.max_user_watches")
+		default:
+			if osPath == w.f.root {
+				return errors.Wrap(err, "failed to watch root")
+			}
+			fs.Debugf(w.f, "Failed to watch %q: %v", dirPath, err)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// removeTree stops watching the directory relPath and all the
+// directories under it
+func (w *watcher) removeTree(relPath string) {
+	prefix := relPath + string(filepath.Separator)
+	for dirPath, wd := range w.wds {
+		if dirPath == relPath || strings.HasPrefix(dirPath, prefix) {
+			_, _ = unix.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.wds, dirPath)
+			delete(w.paths, wd)
+		}
+	}
+}
+
+// run reads the inotify events until the watcher is closed
+func (w *watcher) run() {
+	defer close(w.done)
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		select {
+		case <-w.quit:
+			return
+		default:
+		}
+		if err == nil {
+			err = w.handleEvents(buf[:n])
+		}
+		if err != nil {
+			fs.Errorf(w.f, "Failed to watch for changes: %v", err)
+			close(w.failed)
+			return
+		}
+	}
+}
+
+// handleEvents decodes the inotify events in buf and handles them
+func (w *watcher) handleEvents(buf []byte) error {
+	for len(buf) >= unix.SizeofInotifyEvent {
+		event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[0]))
+		end := unix.SizeofInotifyEvent + int(event.Len)
+		if end > len(buf) {
+			return errors.New("short inotify event")
+		}
+		name := strings.TrimRight(string(buf[unix.SizeofInotifyEvent:end]), "\x00")
+		buf = buf[end:]
+		err := w.handleEvent(int(event.Wd), event.Mask, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleEvent notifies the change for an inotify event, watching or
+// unwatching directories as they come and go
+func (w *watcher) handleEvent(wd int, mask uint32, name string) error {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		// Events were lost so anything may have changed
+		w.notifyFunc("", fs.EntryDirectory)
+		return nil
+	}
+	dirPath, ok := w.paths[wd]
+	if !ok {
+		return nil
+	}
+	switch {
+	case mask&unix.IN_IGNORED != 0:
+		// The directory was removed so its watch has gone
+		delete(w.paths, wd)
+		delete(w.wds, dirPath)
+		return nil
+	case mask&unix.IN_DELETE_SELF != 0:
+		// The change is notified by the parent unless this is
+		// the root
+		if dirPath == "" {
+			w.notifyFunc("", fs.EntryDirectory)
+		}
+		return nil
+	case name == "":
+		return nil
+	}
+	relPath := filepath.Join(dirPath, name)
+	isDir := mask&unix.IN_ISDIR != 0
+	isLink := false
+	if isDir {
+		if mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+			err := w.addTree(relPath, time.Time{})
+			if err != nil {
+				return err
+			}
+		} else if mask&unix.IN_MOVED_FROM != 0 {
+			w.removeTree(relPath)
+		}
+	} else if w.f.opt.TranslateSymlinks {
+		fi, err := os.Lstat(filepath.Join(w.f.root, relPath))
+		isLink = err == nil && fi.Mode()&os.ModeSymlink != 0
+	}
+	w.f.notifyChange(w.notifyFunc, relPath, isDir, isLink)
+	return nil
+}
+
+// close stops the watcher
+func (w *watcher) close() {
+	close(w.quit)
+	_ = w.file.Close()
+	<-w.done
+}
@@ -0,0 +1,23 @@
+// +build !linux
+
+package local
+
+import (
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/pkg/errors"
+)
+
+// watcher can't watch for changes on this OS
+type watcher struct {
+	failed chan struct{}
+}
+
+// newWatcher returns an error as changes are polled for instead
+func newWatcher(f *Fs, notifyFunc func(string, fs.EntryType), since time.Time) (*watcher, error) {
+	return nil, errors.New("watching for changes isn't supported on this OS")
+}
+
+// close does nothing
+func (w *watcher) close() {}
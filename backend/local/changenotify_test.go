@@ -0,0 +1,245 @@
+package local
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config/configmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// changes records the notifications received
+type changes struct {
+	mu      sync.Mutex
+	entries map[string]fs.EntryType
+}
+
+func newChanges() *changes {
+	return &changes{entries: make(map[string]fs.EntryType)}
+}
+
+// notify is the notifyFunc to pass to ChangeNotify
+func (c *changes) notify(remote string, entryType fs.EntryType) {
+	c.mu.Lock()
+	c.entries[remote] = entryType
+	c.mu.Unlock()
+}
+
+// waitFor waits for want to be notified and resets the changes
+func (c *changes) waitFor(t *testing.T, want map[string]fs.EntryType) {
+	var got map[string]fs.EntryType
+	for tries := 0; tries < 50; tries++ {
+		c.mu.Lock()
+		got = make(map[string]fs.EntryType, len(c.entries))
+		found := true
+		for remote, entryType := range want {
+			if c.entries[remote] != entryType {
+				found = false
+			}
+		}
+		for remote, entryType := range c.entries {
+			got[remote] = entryType
+		}
+		if found {
+			c.entries = make(map[string]fs.EntryType)
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Errorf("didn't get changes %v, got %v", want, got)
+}
+
+func newTestFs(t *testing.T) (*Fs, string) {
+	dir, err := ioutil.TempDir("", "rclone-local-changenotify")
+	require.NoError(t, err)
+	f, err := NewFs("local", dir, configmap.Simple{})
+	require.NoError(t, err)
+	return f.(*Fs), dir
+}
+
+func TestChangeNotify(t *testing.T) {
+	f, dir := newTestFs(t)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "b"), 0777))
+
+	c := newChanges()
+	pollInterval := make(chan time.Duration)
+	f.ChangeNotify(context.Background(), c.notify, pollInterval)
+	defer close(pollInterval)
+	pollInterval <- 100 * time.Millisecond
+	// Wait for the polling to start
+	time.Sleep(200 * time.Millisecond)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a", "b", "file"), []byte("hello"), 0666))
+	c.waitFor(t, map[string]fs.EntryType{
+		"a/b/file": fs.EntryObject,
+	})
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "c", "d"), 0777))
+	c.waitFor(t, map[string]fs.EntryType{
+		"c": fs.EntryDirectory,
+	})
+
+	// Directories moved into the tree are watched
+	require.NoError(t, os.Rename(filepath.Join(dir, "a"), filepath.Join(dir, "c", "d", "a")))
+	c.waitFor(t, map[string]fs.EntryType{
+		"a":     fs.EntryDirectory,
+		"c/d/a": fs.EntryDirectory,
+	})
+	require.NoError(t, os.Remove(filepath.Join(dir, "c", "d", "a", "b", "file")))
+	c.waitFor(t, map[string]fs.EntryType{
+		"c/d/a/b/file": fs.EntryObject,
+	})
+
+	// Pausing stops the notifications
+	pollInterval <- 0
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "paused"), []byte("hello"), 0666))
+	time.Sleep(300 * time.Millisecond)
+	c.mu.Lock()
+	assert.Equal(t, map[string]fs.EntryType{}, c.entries)
+	c.mu.Unlock()
+}
+
+func TestChangeNotifyStart(t *testing.T) {
+	f, dir := newTestFs(t)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "old"), 0777))
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "old"), old, old))
+
+	c := newChanges()
+	pollInterval := make(chan time.Duration)
+	f.ChangeNotify(context.Background(), c.notify, pollInterval)
+	defer close(pollInterval)
+	pollInterval <- 100 * time.Millisecond
+
+	// Changes made straight away are notified even if the tree
+	// hasn't been walked yet
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "new", "dir"), 0777))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "new", "file"), []byte("hello"), 0666))
+	c.waitFor(t, map[string]fs.EntryType{
+		"new/dir":  fs.EntryDirectory,
+		"new/file": fs.EntryObject,
+	})
+}
+
+func TestChangeNotifySince(t *testing.T) {
+	f, dir := newTestFs(t)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "dir"), 0777))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "dir", "old"), []byte("hello"), 0666))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "dir", "new"), []byte("hello"), 0666))
+	old := time.Now().Add(-time.Hour)
+	for _, name := range []string{"dir/old", "dir", ""} {
+		require.NoError(t, os.Chtimes(filepath.Join(dir, name), old, old))
+	}
+	since := time.Now().Add(-time.Minute)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "dir", "new"), time.Now(), time.Now()))
+
+	c := newChanges()
+	f.notifySince(f.scanTree(), c.notify, since)
+	assert.Equal(t, map[string]fs.EntryType{
+		"dir/new": fs.EntryObject,
+	}, c.entries)
+}
+
+// countEntries returns the number of entries in the scanned dirs
+func countEntries(dirs map[string]*pollDir) (n int) {
+	for _, dir := range dirs {
+		n += len(dir.entries)
+	}
+	return n
+}
+
+func TestChangeNotifyPolling(t *testing.T) {
+	f, dir := newTestFs(t)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "dir"), 0777))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "dir", "changed"), []byte("hello"), 0666))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "removed"), []byte("hello"), 0666))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "unchanged"), []byte("hello"), 0666))
+
+	dirs := f.scanTree()
+	assert.Equal(t, 2, len(dirs))
+	assert.Equal(t, 4, countEntries(dirs))
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "dir", "changed"), []byte("hello world"), 0666))
+	require.NoError(t, os.Remove(filepath.Join(dir, "removed")))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "new"), 0777))
+
+	c := newChanges()
+	dirs = f.pollChanges(dirs, c.notify)
+	assert.Equal(t, map[string]fs.EntryType{
+		"dir/changed": fs.EntryObject,
+		"removed":     fs.EntryObject,
+		"new":         fs.EntryDirectory,
+	}, c.entries)
+	assert.Equal(t, 3, len(dirs))
+	assert.Equal(t, 4, countEntries(dirs))
+
+	c = newChanges()
+	dirs = f.pollChanges(dirs, c.notify)
+	assert.Equal(t, map[string]fs.EntryType{}, c.entries)
+
+	// Removing a directory notifies everything in it
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "dir")))
+	c = newChanges()
+	f.pollChanges(dirs, c.notify)
+	assert.Equal(t, map[string]fs.EntryType{
+		"dir":         fs.EntryDirectory,
+		"dir/changed": fs.EntryObject,
+	}, c.entries)
+}
+
+func TestChangeNotifyPollingModTime(t *testing.T) {
+	f, dir := newTestFs(t)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "dir"), 0777))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "dir", "file"), []byte("hello"), 0666))
+	fi, err := os.Stat(filepath.Join(dir, "dir"))
+	require.NoError(t, err)
+	dirs := f.scanTree()
+
+	// A directory whose modification time hasn't changed isn't
+	// read again, so an entry added behind its back isn't seen...
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "dir", "hidden"), []byte("hello"), 0666))
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "dir"), fi.ModTime(), fi.ModTime()))
+	c := newChanges()
+	dirs = f.pollChanges(dirs, c.notify)
+	assert.Equal(t, map[string]fs.EntryType{}, c.entries)
+
+	// ...but the entries it already had are still checked
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "dir", "file"), []byte("hello world"), 0666))
+	c = newChanges()
+	dirs = f.pollChanges(dirs, c.notify)
+	assert.Equal(t, map[string]fs.EntryType{
+		"dir/file": fs.EntryObject,
+	}, c.entries)
+
+	// When it changes it is read again
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "dir"), time.Now(), time.Now()))
+	c = newChanges()
+	f.pollChanges(dirs, c.notify)
+	assert.Equal(t, map[string]fs.EntryType{
+		"dir/hidden": fs.EntryObject,
+	}, c.entries)
+}
@@ -0,0 +1,59 @@
+package local
+
+import (
+	"context"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/pkg/errors"
+)
+
+// Metadata returns the metadata for the object
+//
+// This is the mode, owner and times of the file and its user
+// extended attributes where supported.
+func (o *Object) Metadata(ctx context.Context) (metadata fs.Metadata, err error) {
+	info, err := o.fs.lstat(o.path)
+	if err != nil {
+		return nil, err
+	}
+	metadata = fs.Metadata{
+		fs.MetadataMtime: info.ModTime().Format(time.RFC3339Nano),
+	}
+	err = o.readMetadata(info, metadata)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read metadata")
+	}
+	return metadata, nil
+}
+
+// SetMetadata writes metadata to an existing object
+func (o *Object) SetMetadata(ctx context.Context, metadata fs.Metadata) error {
+	err := o.writeMetadata(metadata)
+	if err != nil {
+		return errors.Wrap(err, "failed to set metadata")
+	}
+	return o.lstat()
+}
+
+// metadataTimes returns the access and modification times to set
+// from metadata, defaulting to the current modification time of the
+// object. It returns ok false if metadata has neither.
+func (o *Object) metadataTimes(metadata fs.Metadata) (atime, mtime time.Time, ok bool, err error) {
+	atime, mtime = o.modTime, o.modTime
+	if v, found := metadata[fs.MetadataMtime]; found {
+		mtime, err = time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return atime, mtime, false, errors.Wrapf(err, "bad %s", fs.MetadataMtime)
+		}
+		atime, ok = mtime, true
+	}
+	if v, found := metadata[fs.MetadataAtime]; found {
+		atime, err = time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return atime, mtime, false, errors.Wrapf(err, "bad %s", fs.MetadataAtime)
+		}
+		ok = true
+	}
+	return atime, mtime, ok, nil
+}
@@ -0,0 +1,160 @@
+// +build linux
+
+package local
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"golang.org/x/sys/unix"
+)
+
+// xattrPrefix is the namespace of the extended attributes used for
+// user metadata
+const xattrPrefix = "user."
+
+// readMetadata adds the metadata from info and the user extended
+// attributes of the file to metadata
+func (o *Object) readMetadata(info os.FileInfo, metadata fs.Metadata) error {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		metadata[fs.MetadataMode] = strconv.FormatUint(uint64(stat.Mode), 8)
+		metadata[fs.MetadataUID] = strconv.FormatUint(uint64(stat.Uid), 10)
+		metadata[fs.MetadataGID] = strconv.FormatUint(uint64(stat.Gid), 10)
+		metadata[fs.MetadataAtime] = time.Unix(int64(stat.Atim.Sec), int64(stat.Atim.Nsec)).Format(time.RFC3339Nano) // nolint: unconvert
+	}
+	// Symlinks can't have user extended attributes
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	names, err := listXattr(o.path)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if !strings.HasPrefix(name, xattrPrefix) {
+			continue
+		}
+		k := name[len(xattrPrefix):]
+		if fs.IsSystemMetadata(k) {
+			continue
+		}
+		v, err := getXattr(o.path, name)
+		if err == unix.ENODATA {
+			continue // removed since it was listed
+		} else if err != nil {
+			return err
+		}
+		metadata[k] = v
+	}
+	return nil
+}
+
+// writeMetadata sets the permissions, owner, times and user extended
+// attributes from metadata on the file
+func (o *Object) writeMetadata(metadata fs.Metadata) (err error) {
+	if o.translatedLink {
+		fs.Debugf(o, "Not setting metadata on symlink")
+		return nil
+	}
+	if mode, ok, err := fs.ParseMetadataMode(metadata); err != nil {
+		return err
+	} else if ok {
+		err = os.Chmod(o.path, mode)
+		if err != nil {
+			return err
+		}
+	}
+	uid, gid := -1, -1
+	if id, ok, err := fs.ParseMetadataID(metadata, fs.MetadataUID); err != nil {
+		return err
+	} else if ok {
+		uid = int(id)
+	}
+	if id, ok, err := fs.ParseMetadataID(metadata, fs.MetadataGID); err != nil {
+		return err
+	} else if ok {
+		gid = int(id)
+	}
+	if uid >= 0 || gid >= 0 {
+		err = os.Lchown(o.path, uid, gid)
+		if err != nil {
+			// Only root can give files away so don't treat this as an error
+			fs.Debugf(o, "Failed to set owner: %v", err)
+		}
+	}
+	for k, v := range metadata {
+		if fs.IsSystemMetadata(k) {
+			continue
+		}
+		err = unix.Setxattr(o.path, xattrPrefix+k, []byte(v), 0)
+		if err == unix.ENOTSUP {
+			fs.Debugf(o, "Not setting extended attributes as the file system doesn't support them")
+			break
+		} else if err != nil {
+			return &os.PathError{Op: "setxattr", Path: o.path, Err: err}
+		}
+	}
+	// Set the times last as setting the other metadata may change them
+	if atime, mtime, ok, err := o.metadataTimes(metadata); err != nil {
+		return err
+	} else if ok {
+		err = os.Chtimes(o.path, atime, mtime)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listXattr returns the names of the extended attributes of path
+func listXattr(path string) (names []string, err error) {
+	buf, err := readXattr(func(dest []byte) (int, error) {
+		return unix.Listxattr(path, dest)
+	})
+	if err == unix.ENOTSUP {
+		return nil, nil
+	} else if err != nil {
+		return nil, &os.PathError{Op: "listxattr", Path: path, Err: err}
+	}
+	for _, name := range bytes.Split(buf, []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+	return names, nil
+}
+
+// getXattr returns the value of the extended attribute name of path
+func getXattr(path, name string) (string, error) {
+	buf, err := readXattr(func(dest []byte) (int, error) {
+		return unix.Getxattr(path, name, dest)
+	})
+	return string(buf), err
+}
+
+// readXattr calls fn first to find the size of the buffer needed
+// then to fill it, retrying if the size changed in between.
+func readXattr(fn func(dest []byte) (int, error)) ([]byte, error) {
+	for {
+		size, err := fn(nil)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size)
+		size, err = fn(buf)
+		if err == unix.ERANGE {
+			continue // grew since we asked
+		} else if err != nil {
+			return nil, err
+		}
+		return buf[:size], nil
+	}
+}
@@ -0,0 +1,40 @@
+// +build !linux
+
+package local
+
+import (
+	"os"
+
+	"github.com/ncw/rclone/fs"
+)
+
+// readMetadata adds the metadata from info to metadata
+func (o *Object) readMetadata(info os.FileInfo, metadata fs.Metadata) error {
+	return nil
+}
+
+// writeMetadata sets the permissions and times from metadata on the
+// file
+func (o *Object) writeMetadata(metadata fs.Metadata) (err error) {
+	if o.translatedLink {
+		fs.Debugf(o, "Not setting metadata on symlink")
+		return nil
+	}
+	if mode, ok, err := fs.ParseMetadataMode(metadata); err != nil {
+		return err
+	} else if ok {
+		err = os.Chmod(o.path, mode)
+		if err != nil {
+			return err
+		}
+	}
+	if atime, mtime, ok, err := o.metadataTimes(metadata); err != nil {
+		return err
+	} else if ok {
+		err = os.Chtimes(o.path, atime, mtime)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,659 @@
+// Package memory provides an interface to an in memory object storage system
+//
+// All the memory remotes in a process share the same storage, which
+// is lost when the process exits.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config/configmap"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/pkg/errors"
+)
+
+var errorIsDir = errors.New("can't store an object where there is a directory")
+
+// Register with Fs
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name:        "memory",
+		Description: "In memory object storage system.",
+		NewFs:       NewFs,
+	})
+}
+
+// objectData is the contents and metadata of an object
+//
+// It is never modified once stored so it can be shared between
+// objects and readers without locking.
+type objectData struct {
+	modTime  time.Time
+	hash     string // MD5 of the data in hex
+	mimeType string
+	data     []byte
+}
+
+// dirData is a directory in the store
+type dirData struct {
+	modTime time.Time
+	dirs    map[string]struct{}    // leaf names of the subdirectories
+	objects map[string]*objectData // objects in the directory by leaf name
+}
+
+func newDirData() *dirData {
+	return &dirData{
+		modTime: time.Now(),
+		dirs:    make(map[string]struct{}),
+		objects: make(map[string]*objectData),
+	}
+}
+
+// store holds all the directories and objects by their full path
+type store struct {
+	mu   sync.RWMutex
+	dirs map[string]*dirData
+}
+
+// the storage shared by all memory remotes
+var memoryStore = &store{
+	dirs: map[string]*dirData{"": newDirData()},
+}
+
+// split a path into its parent directory and leaf
+func split(p string) (dir, leaf string) {
+	dir, leaf = path.Split(p)
+	return strings.TrimSuffix(dir, "/"), leaf
+}
+
+// mkdir makes dir and any parents - call with the lock held
+func (s *store) mkdir(dir string) *dirData {
+	if d, ok := s.dirs[dir]; ok {
+		return d
+	}
+	parentDir, leaf := split(dir)
+	parent := s.mkdir(parentDir)
+	parent.dirs[leaf] = struct{}{}
+	d := newDirData()
+	s.dirs[dir] = d
+	return d
+}
+
+// getObject returns the object at p - call with the lock held
+func (s *store) getObject(p string) (*objectData, error) {
+	dir, leaf := split(p)
+	if d, ok := s.dirs[dir]; ok {
+		if od, ok := d.objects[leaf]; ok {
+			return od, nil
+		}
+	}
+	if _, ok := s.dirs[p]; ok {
+		return nil, fs.ErrorNotAFile
+	}
+	return nil, fs.ErrorObjectNotFound
+}
+
+// putObject stores od at p making any directories needed - call with
+// the lock held
+func (s *store) putObject(p string, od *objectData) error {
+	if _, ok := s.dirs[p]; ok {
+		return errorIsDir
+	}
+	dir, leaf := split(p)
+	s.mkdir(dir).objects[leaf] = od
+	return nil
+}
+
+// removeObject removes the object at p - call with the lock held
+func (s *store) removeObject(p string) error {
+	dir, leaf := split(p)
+	d, ok := s.dirs[dir]
+	if !ok {
+		return fs.ErrorObjectNotFound
+	}
+	if _, ok := d.objects[leaf]; !ok {
+		return fs.ErrorObjectNotFound
+	}
+	delete(d.objects, leaf)
+	return nil
+}
+
+// walk calls fn for dir and all the directories under it - call with
+// the lock held
+func (s *store) walk(dir string, fn func(dir string, d *dirData) error) error {
+	d, ok := s.dirs[dir]
+	if !ok {
+		return fs.ErrorDirNotFound
+	}
+	err := fn(dir, d)
+	if err != nil {
+		return err
+	}
+	for leaf := range d.dirs {
+		err = s.walk(path.Join(dir, leaf), fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeDir removes dir and everything in it - call with the lock held
+func (s *store) removeDir(dir string) {
+	var dirs []string
+	_ = s.walk(dir, func(dir string, d *dirData) error {
+		dirs = append(dirs, dir)
+		return nil
+	})
+	for _, dir := range dirs {
+		delete(s.dirs, dir)
+	}
+	if dir == "" {
+		s.dirs[""] = newDirData()
+		return
+	}
+	parentDir, leaf := split(dir)
+	if parent, ok := s.dirs[parentDir]; ok {
+		delete(parent.dirs, leaf)
+	}
+}
+
+// Fs represents a path in the memory store
+type Fs struct {
+	name     string       // name of this remote
+	root     string       // the path we are working on
+	features *fs.Features // optional features
+	store    *store       // where the data is kept
+}
+
+// NewFs constructs an Fs from the path
+func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
+	root = strings.Trim(path.Clean("/"+root), "/")
+	f := &Fs{
+		name:  name,
+		root:  root,
+		store: memoryStore,
+	}
+	f.features = (&fs.Features{
+		ReadMimeType:            true,
+		WriteMimeType:           true,
+		CanHaveEmptyDirectories: true,
+		ServerSideAcrossConfigs: true,
+	}).Fill(f)
+	if root != "" {
+		f.store.mu.RLock()
+		_, err := f.store.getObject(root)
+		f.store.mu.RUnlock()
+		if err == nil {
+			f.root, _ = split(root)
+			// return an error with an fs which points to the parent
+			return f, fs.ErrorIsFile
+		}
+	}
+	return f, nil
+}
+
+// Name of the remote (as passed into NewFs)
+func (f *Fs) Name() string {
+	return f.name
+}
+
+// Root of the remote (as passed into NewFs)
+func (f *Fs) Root() string {
+	return f.root
+}
+
+// String converts this Fs to a string
+func (f *Fs) String() string {
+	return fmt.Sprintf("Memory root '%s'", f.root)
+}
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features {
+	return f.features
+}
+
+// Precision of the ModTimes in this Fs
+func (f *Fs) Precision() time.Duration {
+	return time.Nanosecond
+}
+
+// Hashes returns the supported hash sets.
+func (f *Fs) Hashes() hash.Set {
+	return hash.Set(hash.MD5)
+}
+
+// fullPath returns the path of remote in the store
+func (f *Fs) fullPath(remote string) string {
+	return path.Join(f.root, remote)
+}
+
+// listDir adds the contents of the directory d at dir in the store to
+// entries
+func (f *Fs) listDir(dir string, d *dirData, entries fs.DirEntries) fs.DirEntries {
+	remoteDir := strings.TrimPrefix(strings.TrimPrefix(dir, f.root), "/")
+	for leaf := range d.dirs {
+		sub := f.store.dirs[path.Join(dir, leaf)]
+		entries = append(entries, fs.NewDir(path.Join(remoteDir, leaf), sub.modTime))
+	}
+	for leaf, od := range d.objects {
+		entries = append(entries, &Object{
+			fs:     f,
+			remote: path.Join(remoteDir, leaf),
+			od:     od,
+		})
+	}
+	return entries
+}
+
+// List the objects and directories in dir into entries.  The
+// entries can be returned in any order but should be for a
+// complete directory.
+//
+// dir should be "" to list the root, and should not have
+// trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	f.store.mu.RLock()
+	defer f.store.mu.RUnlock()
+	fullDir := f.fullPath(dir)
+	d, ok := f.store.dirs[fullDir]
+	if !ok {
+		return nil, fs.ErrorDirNotFound
+	}
+	return f.listDir(fullDir, d, nil), nil
+}
+
+// ListR lists the objects and directories of the Fs starting
+// from dir recursively into out.
+//
+// dir should be "" to start from the root, and should not
+// have trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+//
+// It should call callback for each tranche of entries read.
+// These need not be returned in any particular order.  If
+// callback returns an error then the listing will stop
+// immediately.
+func (f *Fs) ListR(ctx context.Context, dir string, callback fs.ListRCallback) (err error) {
+	var entries fs.DirEntries
+	f.store.mu.RLock()
+	err = f.store.walk(f.fullPath(dir), func(dir string, d *dirData) error {
+		entries = f.listDir(dir, d, entries)
+		return nil
+	})
+	f.store.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return callback(entries)
+}
+
+// NewObject finds the Object at remote.  If it can't be found
+// it returns the error fs.ErrorObjectNotFound.
+func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	f.store.mu.RLock()
+	defer f.store.mu.RUnlock()
+	od, err := f.store.getObject(f.fullPath(remote))
+	if err != nil {
+		return nil, err
+	}
+	return &Object{
+		fs:     f,
+		remote: remote,
+		od:     od,
+	}, nil
+}
+
+// Put the object into the store
+//
+// Copy the reader in to the new object which is returned
+//
+// The new object may have been created if an error is returned
+func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	o := &Object{
+		fs:     f,
+		remote: src.Remote(),
+	}
+	return o, o.Update(ctx, in, src, options...)
+}
+
+// PutStream uploads to the remote path with the modTime given of indeterminate size
+func (f *Fs) PutStream(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	return f.Put(ctx, in, src, options...)
+}
+
+// Mkdir creates the directory if it doesn't exist
+func (f *Fs) Mkdir(ctx context.Context, dir string) error {
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+	fullDir := f.fullPath(dir)
+	if _, err := f.store.getObject(fullDir); err == nil {
+		return fs.ErrorIsFile
+	}
+	f.store.mkdir(fullDir)
+	return nil
+}
+
+// Rmdir removes the directory if it is empty
+//
+// Returns an error if it isn't empty or doesn't exist
+func (f *Fs) Rmdir(ctx context.Context, dir string) error {
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+	fullDir := f.fullPath(dir)
+	d, ok := f.store.dirs[fullDir]
+	if !ok {
+		return fs.ErrorDirNotFound
+	}
+	if len(d.dirs) != 0 || len(d.objects) != 0 {
+		return fs.ErrorDirectoryNotEmpty
+	}
+	f.store.removeDir(fullDir)
+	return nil
+}
+
+// Purge deletes all the files and directories including the root
+func (f *Fs) Purge(ctx context.Context) error {
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+	fullDir := f.fullPath("")
+	if _, ok := f.store.dirs[fullDir]; !ok {
+		return fs.ErrorDirNotFound
+	}
+	f.store.removeDir(fullDir)
+	return nil
+}
+
+// copyOrMove copies or moves src to remote
+func (f *Fs) copyOrMove(src fs.Object, remote string, move bool) (fs.Object, error) {
+	srcObj, ok := src.(*Object)
+	if !ok || srcObj.od == nil {
+		fs.Debugf(src, "Can't copy - not same remote type")
+		if move {
+			return nil, fs.ErrorCantMove
+		}
+		return nil, fs.ErrorCantCopy
+	}
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+	srcPath := srcObj.fs.fullPath(srcObj.remote)
+	od, err := f.store.getObject(srcPath)
+	if err != nil {
+		return nil, err
+	}
+	dstPath := f.fullPath(remote)
+	err = f.store.putObject(dstPath, od)
+	if err != nil {
+		return nil, err
+	}
+	if move && srcPath != dstPath {
+		err = f.store.removeObject(srcPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Object{
+		fs:     f,
+		remote: remote,
+		od:     od,
+	}, nil
+}
+
+// Copy src to this remote using server side copy operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantCopy
+func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	return f.copyOrMove(src, remote, false)
+}
+
+// Move src to this remote using server side move operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantMove
+func (f *Fs) Move(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	return f.copyOrMove(src, remote, true)
+}
+
+// DirMove moves src, srcRemote to this remote at dstRemote
+// using server side move operations.
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantDirMove
+//
+// If destination exists then return fs.ErrorDirExists
+func (f *Fs) DirMove(ctx context.Context, src fs.Fs, srcRemote, dstRemote string) error {
+	srcFs, ok := src.(*Fs)
+	if !ok {
+		fs.Debugf(srcFs, "Can't move directory - not same remote type")
+		return fs.ErrorCantDirMove
+	}
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+	srcPath := srcFs.fullPath(srcRemote)
+	dstPath := f.fullPath(dstRemote)
+	if _, ok := f.store.dirs[srcPath]; !ok {
+		return fs.ErrorDirNotFound
+	}
+	if _, ok := f.store.dirs[dstPath]; ok {
+		return fs.ErrorDirExists
+	}
+	if _, err := f.store.getObject(dstPath); err == nil {
+		return fs.ErrorDirExists
+	}
+	if srcPath == "" || strings.HasPrefix(dstPath+"/", srcPath+"/") {
+		return errors.Errorf("can't move directory %q into itself", srcPath)
+	}
+	// Take the directories out of the store then put them back
+	// under their new names
+	moved := map[string]*dirData{}
+	_ = f.store.walk(srcPath, func(dir string, d *dirData) error {
+		moved[dir] = d
+		return nil
+	})
+	f.store.removeDir(srcPath)
+	dstParent, dstLeaf := split(dstPath)
+	f.store.mkdir(dstParent).dirs[dstLeaf] = struct{}{}
+	for dir, d := range moved {
+		f.store.dirs[dstPath+strings.TrimPrefix(dir, srcPath)] = d
+	}
+	return nil
+}
+
+// About gets quota information
+func (f *Fs) About(ctx context.Context) (*fs.Usage, error) {
+	f.store.mu.RLock()
+	defer f.store.mu.RUnlock()
+	var used, objects int64
+	for _, d := range f.store.dirs {
+		for _, od := range d.objects {
+			used += int64(len(od.data))
+			objects++
+		}
+	}
+	return &fs.Usage{
+		Used:    fs.NewUsageValue(used),
+		Objects: fs.NewUsageValue(objects),
+	}, nil
+}
+
+// ------------------------------------------------------------
+
+// Object describes an object in the memory store
+type Object struct {
+	fs     *Fs         // what this object is part of
+	remote string      // The remote path
+	od     *objectData // the data and metadata - nil until uploaded
+}
+
+// Fs returns the parent Fs
+func (o *Object) Fs() fs.Info {
+	return o.fs
+}
+
+// Return a string version
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.remote
+}
+
+// Remote returns the remote path
+func (o *Object) Remote() string {
+	return o.remote
+}
+
+// Hash returns the MD5 of the object as a lowercase hex string
+func (o *Object) Hash(ctx context.Context, t hash.Type) (string, error) {
+	if t != hash.MD5 {
+		return "", hash.ErrUnsupported
+	}
+	return o.od.hash, nil
+}
+
+// Size returns the size of an object in bytes
+func (o *Object) Size() int64 {
+	return int64(len(o.od.data))
+}
+
+// ModTime returns the modification time of the object
+func (o *Object) ModTime(ctx context.Context) time.Time {
+	return o.od.modTime
+}
+
+// SetModTime sets the modification time of the object
+func (o *Object) SetModTime(ctx context.Context, modTime time.Time) error {
+	o.fs.store.mu.Lock()
+	defer o.fs.store.mu.Unlock()
+	p := o.fs.fullPath(o.remote)
+	od, err := o.fs.store.getObject(p)
+	if err != nil {
+		return err
+	}
+	newOd := *od
+	newOd.modTime = modTime
+	err = o.fs.store.putObject(p, &newOd)
+	if err != nil {
+		return err
+	}
+	o.od = &newOd
+	return nil
+}
+
+// Storable returns a boolean showing if this object is storable
+func (o *Object) Storable() bool {
+	return true
+}
+
+// Open an object for read
+func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (in io.ReadCloser, err error) {
+	data := o.od.data
+	var offset, limit int64 = 0, -1
+	for _, option := range options {
+		switch x := option.(type) {
+		case *fs.SeekOption:
+			offset = x.Offset
+		case *fs.RangeOption:
+			offset, limit = x.Decode(int64(len(data)))
+		default:
+			if option.Mandatory() {
+				fs.Logf(o, "Unsupported mandatory option: %v", option)
+			}
+		}
+	}
+	// Clamp the offset as a suffix range longer than the data
+	// gives a negative offset
+	if offset < 0 {
+		offset = 0
+	} else if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	data = data[offset:]
+	if limit >= 0 && limit < int64(len(data)) {
+		data = data[:limit]
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Update the object with the contents of the io.Reader, modTime and size
+//
+// The new object may have been created if an error is returned
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return errors.Wrap(err, "failed to read data")
+	}
+	size := src.Size()
+	if size >= 0 && int64(len(data)) != size {
+		return errors.Errorf("corrupted on transfer: read %d bytes expecting %d", len(data), size)
+	}
+	sum := md5.Sum(data)
+	od := &objectData{
+		modTime:  src.ModTime(ctx),
+		hash:     hex.EncodeToString(sum[:]),
+		mimeType: fs.MimeType(ctx, src),
+		data:     data,
+	}
+	o.fs.store.mu.Lock()
+	defer o.fs.store.mu.Unlock()
+	err = o.fs.store.putObject(o.fs.fullPath(o.remote), od)
+	if err != nil {
+		return err
+	}
+	o.od = od
+	return nil
+}
+
+// Remove an object
+func (o *Object) Remove(ctx context.Context) error {
+	o.fs.store.mu.Lock()
+	defer o.fs.store.mu.Unlock()
+	return o.fs.store.removeObject(o.fs.fullPath(o.remote))
+}
+
+// MimeType of an Object if known, "" otherwise
+func (o *Object) MimeType(ctx context.Context) string {
+	return o.od.mimeType
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs          = (*Fs)(nil)
+	_ fs.Purger      = (*Fs)(nil)
+	_ fs.Copier      = (*Fs)(nil)
+	_ fs.Mover       = (*Fs)(nil)
+	_ fs.DirMover    = (*Fs)(nil)
+	_ fs.PutStreamer = (*Fs)(nil)
+	_ fs.ListRer     = (*Fs)(nil)
+	_ fs.Abouter     = (*Fs)(nil)
+	_ fs.Object      = (*Object)(nil)
+	_ fs.MimeTyper   = (*Object)(nil)
+)
@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config/configmap"
+	"github.com/ncw/rclone/fs/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test Open with options which go outside the object
+func TestOpenRanges(t *testing.T) {
+	ctx := context.Background()
+	f, err := NewFs("TestMemoryRanges", "", configmap.Simple{})
+	require.NoError(t, err)
+	data := []byte("0123456789")
+	src := object.NewStaticObjectInfo("file.txt", time.Now(), int64(len(data)), true, nil, f)
+	o, err := f.Put(ctx, bytes.NewReader(data), src)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, o.Remove(ctx))
+	}()
+
+	for _, test := range []struct {
+		option fs.OpenOption
+		want   string
+	}{
+		{&fs.RangeOption{Start: 2, End: 4}, "234"},
+		{&fs.RangeOption{Start: 8, End: 20}, "89"},
+		{&fs.RangeOption{Start: 20, End: -1}, ""},
+		{&fs.RangeOption{Start: -1, End: 3}, "789"},
+		{&fs.RangeOption{Start: -1, End: 20}, "0123456789"},
+		{&fs.SeekOption{Offset: 7}, "789"},
+		{&fs.SeekOption{Offset: 20}, ""},
+	} {
+		in, err := o.Open(ctx, test.option)
+		require.NoError(t, err, test.option)
+		got, err := ioutil.ReadAll(in)
+		require.NoError(t, err)
+		require.NoError(t, in.Close())
+		assert.Equal(t, test.want, string(got), test.option)
+	}
+}
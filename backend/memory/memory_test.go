@@ -0,0 +1,20 @@
+// Test memory filesystem interface
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/ncw/rclone/backend/memory"
+	"github.com/ncw/rclone/fstest/fstests"
+)
+
+// TestIntegration runs integration tests against the remote
+func TestIntegration(t *testing.T) {
+	fstests.Run(t, &fstests.Opt{
+		RemoteName: "TestMemory:",
+		NilObject:  (*memory.Object)(nil),
+		ExtraConfig: []fstests.ExtraConfigItem{
+			{Name: "TestMemory", Key: "type", Value: "memory"},
+		},
+	})
+}
@@ -1825,11 +1825,19 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		})
 	}
 
-	// Set the mtime in the meta data
-	metadata := map[string]*string{
-		metaMtime: aws.String(swift.TimeToFloatString(modTime)),
+	// Add any metadata passed in
+	metadata := map[string]*string{}
+	metaModTime, metaMimeType, err := userMetadata(options, metadata)
+	if err != nil {
+		return err
+	}
+	if !metaModTime.IsZero() {
+		modTime = metaModTime
 	}
 
+	// Set the mtime in the meta data
+	metadata[metaMtime] = aws.String(swift.TimeToFloatString(modTime))
+
 	// read the md5sum if available for non multpart and if
 	// disable checksum isn't present.
 	var md5sum string
@@ -1848,6 +1856,9 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 
 	// Guess the content type
 	mimeType := fs.MimeType(ctx, src)
+	if metaMimeType != "" {
+		mimeType = metaMimeType
+	}
 
 	key := o.fs.root + o.remote
 	if multipart {
@@ -1975,6 +1986,52 @@ func (o *Object) MimeType(ctx context.Context) string {
 	return o.mimeType
 }
 
+// Metadata returns the S3 user metadata of the object with the keys
+// in lower case, along with its modification time as "mtime" and
+// content type as "content-type".
+func (o *Object) Metadata(ctx context.Context) (metadata fs.Metadata, err error) {
+	err = o.readMetaData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metadata = make(fs.Metadata, len(o.meta)+2)
+	for k, v := range o.meta {
+		if v == nil || k == metaMtime || k == metaMD5Hash {
+			continue
+		}
+		metadata[strings.ToLower(k)] = *v
+	}
+	metadata["mtime"] = o.ModTime(ctx).Format(time.RFC3339Nano)
+	if o.mimeType != "" {
+		metadata["content-type"] = o.mimeType
+	}
+	return metadata, nil
+}
+
+// userMetadata adds the metadata passed in options to the S3 user
+// metadata in meta, returning the modification time and content
+// type to use instead of the ones from the source if set
+func userMetadata(options []fs.OpenOption, meta map[string]*string) (modTime time.Time, mimeType string, err error) {
+	for k, v := range fs.MetadataFromOptions(options) {
+		switch k {
+		case "mtime":
+			modTime, err = time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return modTime, mimeType, errors.Wrap(err, "failed to parse mtime from metadata")
+			}
+		case "content-type":
+			mimeType = v
+		default:
+			// Don't let user metadata overwrite rclone's
+			if strings.EqualFold(k, metaMtime) || strings.EqualFold(k, metaMD5Hash) {
+				continue
+			}
+			meta[k] = aws.String(v)
+		}
+	}
+	return modTime, mimeType, nil
+}
+
 // Check the interfaces are satisfied
 var (
 	_ fs.Fs          = &Fs{}
@@ -1983,4 +2040,5 @@ var (
 	_ fs.ListRer     = &Fs{}
 	_ fs.Object      = &Object{}
 	_ fs.MimeTyper   = &Object{}
+	_ fs.Metadataer  = &Object{}
 )
@@ -0,0 +1,151 @@
+package union
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/ncw/rclone/backend/union/upstream"
+	"github.com/ncw/rclone/fs"
+	"github.com/pkg/errors"
+)
+
+// Object describes a union Object
+//
+// This is a wrapped object which returns the Union Fs as its parent.
+// It reads from the copy chosen by the search policy and modifies the
+// copies chosen by the action policy.
+type Object struct {
+	*upstream.Object
+	fs *Fs              // what this object is part of
+	co []upstream.Entry // the copies of the object on the upstreams
+}
+
+// Fs returns the union Fs as the parent
+func (o *Object) Fs() fs.Info {
+	return o.fs
+}
+
+// candidates returns the copies of the object on the upstreams
+func (o *Object) candidates() []upstream.Entry {
+	return o.co
+}
+
+// onUpstream returns the copy of the object on the upstream with
+// index i or nil if there isn't one
+func (o *Object) onUpstream(i int) fs.Object {
+	if i < 0 || i >= len(o.fs.upstreams) {
+		return nil
+	}
+	for _, e := range o.co {
+		if e.UpstreamFs() == o.fs.upstreams[i] {
+			return e.(*upstream.Object).UnWrap()
+		}
+	}
+	return nil
+}
+
+// actionObjects returns the copies of the object chosen by the action
+// policy
+func (o *Object) actionObjects() ([]*upstream.Object, error) {
+	entries, err := o.fs.actionPolicy.ActionEntries(o.co...)
+	if err == fs.ErrorObjectNotFound {
+		return nil, errors.Wrap(fs.ErrorPermissionDenied, "object is only on read only upstreams")
+	}
+	if err != nil {
+		return nil, err
+	}
+	objs := make([]*upstream.Object, len(entries))
+	for i, e := range entries {
+		objs[i] = e.(*upstream.Object)
+	}
+	return objs, nil
+}
+
+// Update in to the object with the modTime given of the given size
+//
+// The copies chosen by the action policy are updated
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	objs, err := o.actionObjects()
+	if err != nil {
+		return err
+	}
+	return fanOut(in, len(objs), func(i int, in io.Reader) error {
+		err := objs[i].Update(ctx, in, src, options...)
+		if err != nil {
+			return errors.Wrap(err, objs[i].UpstreamFs().Name())
+		}
+		return nil
+	})
+}
+
+// Remove the copies of the object chosen by the action policy
+func (o *Object) Remove(ctx context.Context) error {
+	objs, err := o.actionObjects()
+	if err != nil {
+		return err
+	}
+	errs := Errors(make([]error, len(objs)))
+	multithread(len(objs), func(i int) {
+		err := objs[i].Remove(ctx)
+		if err != nil {
+			errs[i] = errors.Wrap(err, objs[i].UpstreamFs().Name())
+		}
+	})
+	return errs.Err()
+}
+
+// SetModTime sets the modification time on the copies of the object
+// chosen by the action policy
+func (o *Object) SetModTime(ctx context.Context, t time.Time) error {
+	objs, err := o.actionObjects()
+	if err != nil {
+		return err
+	}
+	errs := Errors(make([]error, len(objs)))
+	multithread(len(objs), func(i int) {
+		errs[i] = objs[i].SetModTime(ctx, t)
+	})
+	return errs.Err()
+}
+
+// Metadata returns metadata for an object
+//
+// It should return nil if there is no Metadata
+func (o *Object) Metadata(ctx context.Context) (fs.Metadata, error) {
+	return fs.GetMetadata(ctx, o.UnWrap())
+}
+
+// SetMetadata writes metadata to the copies chosen by the action
+// policy
+func (o *Object) SetMetadata(ctx context.Context, metadata fs.Metadata) error {
+	objs, err := o.actionObjects()
+	if err != nil {
+		return err
+	}
+	errs := Errors(make([]error, len(objs)))
+	multithread(len(objs), func(i int) {
+		do, ok := objs[i].UnWrap().(fs.SetMetadataer)
+		if !ok {
+			errs[i] = fs.ErrorCantSetMetadata
+			return
+		}
+		errs[i] = do.SetMetadata(ctx, metadata)
+	})
+	return errs.Err()
+}
+
+// MimeType returns the content type of the Object if
+// known, or "" if not
+func (o *Object) MimeType(ctx context.Context) string {
+	return fs.MimeType(ctx, o.UnWrap())
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Object          = (*Object)(nil)
+	_ fs.ObjectUnWrapper = (*Object)(nil)
+	_ fs.MimeTyper       = (*Object)(nil)
+	_ fs.Metadataer      = (*Object)(nil)
+	_ fs.SetMetadataer   = (*Object)(nil)
+)
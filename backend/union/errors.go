@@ -0,0 +1,56 @@
+package union
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Errors is a slice of errors, one for each upstream operated on
+type Errors []error
+
+// FilterNil returns the Errors without nil
+func (e Errors) FilterNil() Errors {
+	ne := Errors{}
+	for _, err := range e {
+		if err != nil {
+			ne = append(ne, err)
+		}
+	}
+	return ne
+}
+
+// Err returns nil if there are no non-nil errors, the error itself if
+// there is exactly one, otherwise the Errors
+func (e Errors) Err() error {
+	ne := e.FilterNil()
+	switch len(ne) {
+	case 0:
+		return nil
+	case 1:
+		return ne[0]
+	}
+	return ne
+}
+
+// Error returns a concatenated string of the contained errors
+func (e Errors) Error() string {
+	var buf bytes.Buffer
+	if len(e) == 0 {
+		buf.WriteString("no error")
+	} else if len(e) == 1 {
+		buf.WriteString("1 error: ")
+	} else {
+		fmt.Fprintf(&buf, "%d errors: ", len(e))
+	}
+	for i, err := range e {
+		if i != 0 {
+			buf.WriteString("; ")
+		}
+		if err != nil {
+			buf.WriteString(err.Error())
+		} else {
+			buf.WriteString("nil error")
+		}
+	}
+	return buf.String()
+}
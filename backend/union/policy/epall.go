@@ -0,0 +1,39 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/ncw/rclone/backend/union/upstream"
+)
+
+func init() {
+	registerPolicy("epall", &EpAll{})
+}
+
+// EpAll stands for "existing path, all"
+// Action category: apply to all found.
+// Create category: apply to all found.
+// Search category: same as epff.
+type EpAll struct {
+	EpFF
+}
+
+// Action category policy, governing the modification of files and directories
+func (p *EpAll) Action(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	return nonEmpty(filterExisting(ctx, filterRO(upstreams), path))
+}
+
+// ActionEntries is ACTION category policy but receiving a set of candidate entries
+func (p *EpAll) ActionEntries(entries ...upstream.Entry) ([]upstream.Entry, error) {
+	return nonEmptyEntries(filterROEntries(entries))
+}
+
+// Create category policy, governing the creation of files and directories
+func (p *EpAll) Create(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	return nonEmpty(filterParentExisting(ctx, filterNC(upstreams), path))
+}
+
+// CreateEntries is CREATE category policy but receiving a set of candidate entries
+func (p *EpAll) CreateEntries(entries ...upstream.Entry) ([]upstream.Entry, error) {
+	return nonEmptyEntries(filterNCEntries(entries))
+}
@@ -0,0 +1,46 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/ncw/rclone/backend/union/upstream"
+)
+
+func init() {
+	registerPolicy("epff", &EpFF{})
+}
+
+// EpFF stands for "existing path, first found"
+// Given the order of the candidates, act on the first one found where
+// the relative path exists.
+type EpFF struct{}
+
+// Action category policy, governing the modification of files and directories
+func (p *EpFF) Action(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	return one(first(filterExisting(ctx, filterRO(upstreams), path)))
+}
+
+// ActionEntries is ACTION category policy but receiving a set of candidate entries
+func (p *EpFF) ActionEntries(entries ...upstream.Entry) ([]upstream.Entry, error) {
+	return oneEntry(firstEntry(filterROEntries(entries)))
+}
+
+// Create category policy, governing the creation of files and directories
+func (p *EpFF) Create(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	return one(first(filterParentExisting(ctx, filterNC(upstreams), path)))
+}
+
+// CreateEntries is CREATE category policy but receiving a set of candidate entries
+func (p *EpFF) CreateEntries(entries ...upstream.Entry) ([]upstream.Entry, error) {
+	return oneEntry(firstEntry(filterNCEntries(entries)))
+}
+
+// Search category policy, governing the access to files and directories
+func (p *EpFF) Search(ctx context.Context, upstreams []*upstream.Fs, path string) (*upstream.Fs, error) {
+	return first(filterExisting(ctx, upstreams, path))
+}
+
+// SearchEntries is SEARCH category policy but receiving a set of candidate entries
+func (p *EpFF) SearchEntries(entries ...upstream.Entry) (upstream.Entry, error) {
+	return firstEntry(entries)
+}
@@ -0,0 +1,46 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/ncw/rclone/backend/union/upstream"
+)
+
+func init() {
+	registerPolicy("eplfs", &EpLfs{})
+}
+
+// EpLfs stands for "existing path, least free space"
+// Of all the candidates on which the path exists choose the one with
+// the least free space.
+type EpLfs struct{}
+
+// Action category policy, governing the modification of files and directories
+func (p *EpLfs) Action(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	return one(pick(ctx, filterExisting(ctx, filterRO(upstreams), path), freeSpace, true))
+}
+
+// ActionEntries is ACTION category policy but receiving a set of candidate entries
+func (p *EpLfs) ActionEntries(entries ...upstream.Entry) ([]upstream.Entry, error) {
+	return oneEntry(pickEntry(filterROEntries(entries), freeSpace, true))
+}
+
+// Create category policy, governing the creation of files and directories
+func (p *EpLfs) Create(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	return one(pick(ctx, filterParentExisting(ctx, filterNC(upstreams), path), freeSpace, true))
+}
+
+// CreateEntries is CREATE category policy but receiving a set of candidate entries
+func (p *EpLfs) CreateEntries(entries ...upstream.Entry) ([]upstream.Entry, error) {
+	return oneEntry(pickEntry(filterNCEntries(entries), freeSpace, true))
+}
+
+// Search category policy, governing the access to files and directories
+func (p *EpLfs) Search(ctx context.Context, upstreams []*upstream.Fs, path string) (*upstream.Fs, error) {
+	return pick(ctx, filterExisting(ctx, upstreams, path), freeSpace, true)
+}
+
+// SearchEntries is SEARCH category policy but receiving a set of candidate entries
+func (p *EpLfs) SearchEntries(entries ...upstream.Entry) (upstream.Entry, error) {
+	return pickEntry(entries, freeSpace, true)
+}
@@ -0,0 +1,46 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/ncw/rclone/backend/union/upstream"
+)
+
+func init() {
+	registerPolicy("eplus", &EpLus{})
+}
+
+// EpLus stands for "existing path, least used space"
+// Of all the candidates on which the path exists choose the one with
+// the least used space.
+type EpLus struct{}
+
+// Action category policy, governing the modification of files and directories
+func (p *EpLus) Action(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	return one(pick(ctx, filterExisting(ctx, filterRO(upstreams), path), usedSpace, true))
+}
+
+// ActionEntries is ACTION category policy but receiving a set of candidate entries
+func (p *EpLus) ActionEntries(entries ...upstream.Entry) ([]upstream.Entry, error) {
+	return oneEntry(pickEntry(filterROEntries(entries), usedSpace, true))
+}
+
+// Create category policy, governing the creation of files and directories
+func (p *EpLus) Create(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	return one(pick(ctx, filterParentExisting(ctx, filterNC(upstreams), path), usedSpace, true))
+}
+
+// CreateEntries is CREATE category policy but receiving a set of candidate entries
+func (p *EpLus) CreateEntries(entries ...upstream.Entry) ([]upstream.Entry, error) {
+	return oneEntry(pickEntry(filterNCEntries(entries), usedSpace, true))
+}
+
+// Search category policy, governing the access to files and directories
+func (p *EpLus) Search(ctx context.Context, upstreams []*upstream.Fs, path string) (*upstream.Fs, error) {
+	return pick(ctx, filterExisting(ctx, upstreams, path), usedSpace, true)
+}
+
+// SearchEntries is SEARCH category policy but receiving a set of candidate entries
+func (p *EpLus) SearchEntries(entries ...upstream.Entry) (upstream.Entry, error) {
+	return pickEntry(entries, usedSpace, true)
+}
@@ -0,0 +1,46 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/ncw/rclone/backend/union/upstream"
+)
+
+func init() {
+	registerPolicy("epmfs", &EpMfs{})
+}
+
+// EpMfs stands for "existing path, most free space"
+// Of all the candidates on which the path exists choose the one with
+// the most free space.
+type EpMfs struct{}
+
+// Action category policy, governing the modification of files and directories
+func (p *EpMfs) Action(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	return one(pick(ctx, filterExisting(ctx, filterRO(upstreams), path), freeSpace, false))
+}
+
+// ActionEntries is ACTION category policy but receiving a set of candidate entries
+func (p *EpMfs) ActionEntries(entries ...upstream.Entry) ([]upstream.Entry, error) {
+	return oneEntry(pickEntry(filterROEntries(entries), freeSpace, false))
+}
+
+// Create category policy, governing the creation of files and directories
+func (p *EpMfs) Create(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	return one(pick(ctx, filterParentExisting(ctx, filterNC(upstreams), path), freeSpace, false))
+}
+
+// CreateEntries is CREATE category policy but receiving a set of candidate entries
+func (p *EpMfs) CreateEntries(entries ...upstream.Entry) ([]upstream.Entry, error) {
+	return oneEntry(pickEntry(filterNCEntries(entries), freeSpace, false))
+}
+
+// Search category policy, governing the access to files and directories
+func (p *EpMfs) Search(ctx context.Context, upstreams []*upstream.Fs, path string) (*upstream.Fs, error) {
+	return pick(ctx, filterExisting(ctx, upstreams, path), freeSpace, false)
+}
+
+// SearchEntries is SEARCH category policy but receiving a set of candidate entries
+func (p *EpMfs) SearchEntries(entries ...upstream.Entry) (upstream.Entry, error) {
+	return pickEntry(entries, freeSpace, false)
+}
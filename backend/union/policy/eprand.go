@@ -0,0 +1,47 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/ncw/rclone/backend/union/upstream"
+)
+
+func init() {
+	registerPolicy("eprand", &EpRand{})
+}
+
+// EpRand stands for "existing path, random"
+// Calls epall and then randomizes. Returns one candidate.
+type EpRand struct {
+	EpAll
+}
+
+// Action category policy, governing the modification of files and directories
+func (p *EpRand) Action(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	return one(random(filterExisting(ctx, filterRO(upstreams), path)))
+}
+
+// ActionEntries is ACTION category policy but receiving a set of candidate entries
+func (p *EpRand) ActionEntries(entries ...upstream.Entry) ([]upstream.Entry, error) {
+	return oneEntry(randomEntry(filterROEntries(entries)))
+}
+
+// Create category policy, governing the creation of files and directories
+func (p *EpRand) Create(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	return one(random(filterParentExisting(ctx, filterNC(upstreams), path)))
+}
+
+// CreateEntries is CREATE category policy but receiving a set of candidate entries
+func (p *EpRand) CreateEntries(entries ...upstream.Entry) ([]upstream.Entry, error) {
+	return oneEntry(randomEntry(filterNCEntries(entries)))
+}
+
+// Search category policy, governing the access to files and directories
+func (p *EpRand) Search(ctx context.Context, upstreams []*upstream.Fs, path string) (*upstream.Fs, error) {
+	return random(filterExisting(ctx, upstreams, path))
+}
+
+// SearchEntries is SEARCH category policy but receiving a set of candidate entries
+func (p *EpRand) SearchEntries(entries ...upstream.Entry) (upstream.Entry, error) {
+	return randomEntry(entries)
+}
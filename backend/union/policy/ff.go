@@ -0,0 +1,24 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/ncw/rclone/backend/union/upstream"
+)
+
+func init() {
+	registerPolicy("ff", &FF{})
+}
+
+// FF stands for "first found"
+// Search category: same as epff.
+// Action category: same as epff.
+// Create category: Given the order of the candidates, act on the first one found.
+type FF struct {
+	EpFF
+}
+
+// Create category policy, governing the creation of files and directories
+func (p *FF) Create(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	return one(first(filterNC(upstreams)))
+}
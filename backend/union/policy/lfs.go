@@ -0,0 +1,24 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/ncw/rclone/backend/union/upstream"
+)
+
+func init() {
+	registerPolicy("lfs", &Lfs{})
+}
+
+// Lfs stands for "least free space"
+// Search category: same as eplfs.
+// Action category: same as eplfs.
+// Create category: Pick the upstream with the least free space.
+type Lfs struct {
+	EpLfs
+}
+
+// Create category policy, governing the creation of files and directories
+func (p *Lfs) Create(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	return one(pick(ctx, filterNC(upstreams), freeSpace, true))
+}
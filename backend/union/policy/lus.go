@@ -0,0 +1,24 @@
+package policy
+
+import (
+	"context"
+
+	"github.com/ncw/rclone/backend/union/upstream"
+)
+
+func init() {
+	registerPolicy("lus", &Lus{})
+}
+
+// Lus stands for "least used space"
+// Search category: same as eplus.
+// Action category: same as eplus.
+// Create category: Pick the upstream with the least used space.
+type Lus struct {
+	EpLus
+}
+
+// Create category policy, governing the creation of files and directories
+func (p *Lus) Create(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	return one(pick(ctx, filterNC(upstreams), usedSpace, true))
+}
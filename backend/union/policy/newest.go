@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"context"
+	"time"
+
+	"github.com/ncw/rclone/backend/union/upstream"
+	"github.com/ncw/rclone/fs"
+)
+
+func init() {
+	registerPolicy("newest", &Newest{})
+}
+
+// Newest policy picks the file / directory with the largest mtime
+// It implies the existence of a path
+type Newest struct{}
+
+// newest returns the upstream where path has the latest modification
+// time
+func newest(ctx context.Context, upstreams []*upstream.Fs, path string) (*upstream.Fs, error) {
+	var best *upstream.Fs
+	var bestTime time.Time
+	for _, u := range upstreams {
+		entry := findEntry(ctx, u, path)
+		if entry == nil {
+			continue
+		}
+		modTime := entry.ModTime(ctx)
+		if best == nil || modTime.After(bestTime) {
+			best, bestTime = u, modTime
+		}
+	}
+	if best == nil {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return best, nil
+}
+
+// newestEntry returns the entry with the latest modification time
+func newestEntry(entries []upstream.Entry) (upstream.Entry, error) {
+	ctx := context.Background()
+	var best upstream.Entry
+	var bestTime time.Time
+	for _, e := range entries {
+		modTime := e.ModTime(ctx)
+		if best == nil || modTime.After(bestTime) {
+			best, bestTime = e, modTime
+		}
+	}
+	if best == nil {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return best, nil
+}
+
+// Action category policy, governing the modification of files and directories
+func (p *Newest) Action(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	return one(newest(ctx, filterRO(upstreams), path))
+}
+
+// ActionEntries is ACTION category policy but receiving a set of candidate entries
+func (p *Newest) ActionEntries(entries ...upstream.Entry) ([]upstream.Entry, error) {
+	return oneEntry(newestEntry(filterROEntries(entries)))
+}
+
+// Create category policy, governing the creation of files and directories
+//
+// This picks the upstream where the parent directory is newest
+func (p *Newest) Create(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error) {
+	upstreams = filterNC(upstreams)
+	if parentDir(path) == "" {
+		return one(first(upstreams))
+	}
+	return one(newest(ctx, upstreams, parentDir(path)))
+}
+
+// CreateEntries is CREATE category policy but receiving a set of candidate entries
+func (p *Newest) CreateEntries(entries ...upstream.Entry) ([]upstream.Entry, error) {
+	return oneEntry(newestEntry(filterNCEntries(entries)))
+}
+
+// Search category policy, governing the access to files and directories
+func (p *Newest) Search(ctx context.Context, upstreams []*upstream.Fs, path string) (*upstream.Fs, error) {
+	return newest(ctx, upstreams, path)
+}
+
+// SearchEntries is SEARCH category policy but receiving a set of candidate entries
+func (p *Newest) SearchEntries(entries ...upstream.Entry) (upstream.Entry, error) {
+	return newestEntry(entries)
+}
@@ -0,0 +1,304 @@
+// Package policy implements the policies used by the union backend
+// to choose which upstreams files are read from, created on and
+// modified on.
+//
+// The policies follow the ones in mergerfs. The policies with an "ep"
+// (existing path) prefix only consider upstreams where the path (or
+// for creation the parent directory of the path) already exists.
+package policy
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ncw/rclone/backend/union/upstream"
+	"github.com/ncw/rclone/fs"
+	"github.com/pkg/errors"
+)
+
+// Policy is the interface of a set of defined behavior choosing
+// the upstream Fs to operate on
+type Policy interface {
+	// Action category policy, governing the modification of files and directories
+	Action(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error)
+
+	// Create category policy, governing the creation of files and directories
+	Create(ctx context.Context, upstreams []*upstream.Fs, path string) ([]*upstream.Fs, error)
+
+	// Search category policy, governing the access to files and directories
+	Search(ctx context.Context, upstreams []*upstream.Fs, path string) (*upstream.Fs, error)
+
+	// ActionEntries is ACTION category policy but receiving a set of candidate entries
+	ActionEntries(entries ...upstream.Entry) ([]upstream.Entry, error)
+
+	// CreateEntries is CREATE category policy but receiving a set of candidate entries
+	CreateEntries(entries ...upstream.Entry) ([]upstream.Entry, error)
+
+	// SearchEntries is SEARCH category policy but receiving a set of candidate entries
+	SearchEntries(entries ...upstream.Entry) (upstream.Entry, error)
+}
+
+var policies = make(map[string]Policy)
+
+// registerPolicy registers a policy under name
+func registerPolicy(name string, p Policy) {
+	policies[strings.ToLower(name)] = p
+}
+
+// Get a Policy from the list
+func Get(name string) (Policy, error) {
+	p, ok := policies[strings.ToLower(name)]
+	if !ok {
+		return nil, errors.Errorf("didn't find policy called %q", name)
+	}
+	return p, nil
+}
+
+// Names returns the names of all the policies
+func Names() (names []string) {
+	for name := range policies {
+		names = append(names, name)
+	}
+	return names
+}
+
+// parentDir returns the parent directory of p, "" for the root
+func parentDir(p string) string {
+	parent := path.Dir(strings.Trim(p, "/"))
+	if parent == "." || parent == "/" {
+		return ""
+	}
+	return parent
+}
+
+// findEntry looks for remote on f returning the entry or nil if it
+// wasn't found
+func findEntry(ctx context.Context, f fs.Fs, remote string) fs.DirEntry {
+	remote = strings.Trim(remote, "/")
+	if remote == "" {
+		// the root exists if it can be listed
+		if _, err := f.List(ctx, ""); err != nil {
+			return nil
+		}
+		return fs.NewDir("", time.Time{})
+	}
+	if o, err := f.NewObject(ctx, remote); err == nil {
+		return o
+	}
+	entries, err := f.List(ctx, parentDir(remote))
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		if entry.Remote() == remote {
+			return entry
+		}
+	}
+	return nil
+}
+
+// exists returns whether remote exists on f
+func exists(ctx context.Context, f fs.Fs, remote string) bool {
+	return findEntry(ctx, f, remote) != nil
+}
+
+// parentExists returns whether the directory remote would be created
+// in exists on f. The root of the union always counts as existing.
+func parentExists(ctx context.Context, f fs.Fs, remote string) bool {
+	parent := parentDir(remote)
+	return parent == "" || exists(ctx, f, parent)
+}
+
+// filterRO returns the upstreams which may be modified
+func filterRO(upstreams []*upstream.Fs) (wr []*upstream.Fs) {
+	for _, u := range upstreams {
+		if u.IsWritable() {
+			wr = append(wr, u)
+		}
+	}
+	return wr
+}
+
+// filterROEntries returns the entries on upstreams which may be modified
+func filterROEntries(entries []upstream.Entry) (wr []upstream.Entry) {
+	for _, e := range entries {
+		if e.UpstreamFs().IsWritable() {
+			wr = append(wr, e)
+		}
+	}
+	return wr
+}
+
+// filterNC returns the upstreams which may be created on
+func filterNC(upstreams []*upstream.Fs) (nc []*upstream.Fs) {
+	for _, u := range upstreams {
+		if u.IsCreatable() {
+			nc = append(nc, u)
+		}
+	}
+	return nc
+}
+
+// filterNCEntries returns the entries on upstreams which may be created on
+func filterNCEntries(entries []upstream.Entry) (nc []upstream.Entry) {
+	for _, e := range entries {
+		if e.UpstreamFs().IsCreatable() {
+			nc = append(nc, e)
+		}
+	}
+	return nc
+}
+
+// filterExisting returns the upstreams where path exists
+func filterExisting(ctx context.Context, upstreams []*upstream.Fs, path string) (found []*upstream.Fs) {
+	for _, u := range upstreams {
+		if exists(ctx, u, path) {
+			found = append(found, u)
+		}
+	}
+	return found
+}
+
+// filterParentExisting returns the upstreams where the parent of path exists
+func filterParentExisting(ctx context.Context, upstreams []*upstream.Fs, path string) (found []*upstream.Fs) {
+	for _, u := range upstreams {
+		if parentExists(ctx, u, path) {
+			found = append(found, u)
+		}
+	}
+	return found
+}
+
+// usageFn reads a usage value from an upstream
+type usageFn func(ctx context.Context, u *upstream.Fs) (int64, error)
+
+// freeSpace reads the free space of an upstream, treating it as
+// infinite if the upstream can't report it
+func freeSpace(ctx context.Context, u *upstream.Fs) (int64, error) {
+	space, err := u.GetFreeSpace(ctx)
+	if err == upstream.ErrUsageFieldNotSupported {
+		fs.Debugf(u, "Free space is not supported - treating as infinite")
+		return math.MaxInt64, nil
+	}
+	return space, err
+}
+
+// usedSpace reads the used space of an upstream, treating it as zero
+// if the upstream can't report it
+func usedSpace(ctx context.Context, u *upstream.Fs) (int64, error) {
+	space, err := u.GetUsedSpace(ctx)
+	if err == upstream.ErrUsageFieldNotSupported {
+		fs.Debugf(u, "Used space is not supported - treating as zero")
+		return 0, nil
+	}
+	return space, err
+}
+
+// pick returns the upstream with the most (or least if least is set)
+// of the usage value
+func pick(ctx context.Context, upstreams []*upstream.Fs, value usageFn, least bool) (*upstream.Fs, error) {
+	var best *upstream.Fs
+	var bestValue int64
+	for _, u := range upstreams {
+		v, err := value(ctx, u)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read usage of %v", u)
+		}
+		if best == nil || (least && v < bestValue) || (!least && v > bestValue) {
+			best, bestValue = u, v
+		}
+	}
+	if best == nil {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return best, nil
+}
+
+// pickEntry returns the entry on the upstream with the most (or
+// least if least is set) of the usage value
+func pickEntry(entries []upstream.Entry, value usageFn, least bool) (upstream.Entry, error) {
+	ctx := context.Background()
+	var best upstream.Entry
+	var bestValue int64
+	for _, e := range entries {
+		v, err := value(ctx, e.UpstreamFs())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read usage of %v", e.UpstreamFs())
+		}
+		if best == nil || (least && v < bestValue) || (!least && v > bestValue) {
+			best, bestValue = e, v
+		}
+	}
+	if best == nil {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return best, nil
+}
+
+// first returns the first upstream or an error if there are none
+func first(upstreams []*upstream.Fs) (*upstream.Fs, error) {
+	if len(upstreams) == 0 {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return upstreams[0], nil
+}
+
+// firstEntry returns the first entry or an error if there are none
+func firstEntry(entries []upstream.Entry) (upstream.Entry, error) {
+	if len(entries) == 0 {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return entries[0], nil
+}
+
+// random returns a random upstream or an error if there are none
+func random(upstreams []*upstream.Fs) (*upstream.Fs, error) {
+	if len(upstreams) == 0 {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return upstreams[rand.Intn(len(upstreams))], nil
+}
+
+// randomEntry returns a random entry or an error if there are none
+func randomEntry(entries []upstream.Entry) (upstream.Entry, error) {
+	if len(entries) == 0 {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return entries[rand.Intn(len(entries))], nil
+}
+
+// one makes a slice of the upstream passed in or returns err
+func one(u *upstream.Fs, err error) ([]*upstream.Fs, error) {
+	if err != nil {
+		return nil, err
+	}
+	return []*upstream.Fs{u}, nil
+}
+
+// oneEntry makes a slice of the entry passed in or returns err
+func oneEntry(e upstream.Entry, err error) ([]upstream.Entry, error) {
+	if err != nil {
+		return nil, err
+	}
+	return []upstream.Entry{e}, nil
+}
+
+// nonEmpty returns an error if there are no upstreams
+func nonEmpty(upstreams []*upstream.Fs) ([]*upstream.Fs, error) {
+	if len(upstreams) == 0 {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return upstreams, nil
+}
+
+// nonEmptyEntries returns an error if there are no entries
+func nonEmptyEntries(entries []upstream.Entry) ([]upstream.Entry, error) {
+	if len(entries) == 0 {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return entries, nil
+}
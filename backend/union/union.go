@@ -7,10 +7,12 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/ncw/rclone/backend/union/policy"
+	"github.com/ncw/rclone/backend/union/upstream"
 	"github.com/ncw/rclone/fs"
-	"github.com/ncw/rclone/fs/cache"
 	"github.com/ncw/rclone/fs/config/configmap"
 	"github.com/ncw/rclone/fs/config/configstruct"
 	"github.com/ncw/rclone/fs/hash"
@@ -24,9 +26,28 @@ func init() {
 		Description: "A stackable unification remote, which can appear to merge the contents of several remotes",
 		NewFs:       NewFs,
 		Options: []fs.Option{{
-			Name:     "remotes",
-			Help:     "List of space separated remotes.\nCan be 'remotea:test/dir remoteb:', '\"remotea:test/space dir\" remoteb:', etc.\nThe last remote is used to write to.",
+			Name: "remotes",
+			Help: `List of space separated remotes.
+Can be 'remotea:test/dir remoteb:', '"remotea:test/space dir" remoteb:', etc.
+Add ':ro' to the end of a remote to make it read only, eg 'remotea:dir:ro',
+or ':nc' to stop new files and directories being created on it.`,
 			Required: true,
+		}, {
+			Name:    "action_policy",
+			Help:    "Policy to choose upstream on ACTION category (modifying and deleting).",
+			Default: "epall",
+		}, {
+			Name:    "create_policy",
+			Help:    "Policy to choose upstream on CREATE category (creating files and directories).",
+			Default: "epmfs",
+		}, {
+			Name:    "search_policy",
+			Help:    "Policy to choose upstream on SEARCH category (reading files and directories).",
+			Default: "ff",
+		}, {
+			Name:    "cache_time",
+			Help:    "Cache time of usage and free space (in seconds). This option is only useful when a path preserving policy is used.",
+			Default: 120,
 		}},
 	}
 	fs.Register(fsi)
@@ -34,39 +55,24 @@ func init() {
 
 // Options defines the configuration for this backend
 type Options struct {
-	Remotes fs.SpaceSepList `config:"remotes"`
+	Remotes      fs.SpaceSepList `config:"remotes"`
+	ActionPolicy string          `config:"action_policy"`
+	CreatePolicy string          `config:"create_policy"`
+	SearchPolicy string          `config:"search_policy"`
+	CacheTime    int             `config:"cache_time"`
 }
 
-// Fs represents a union of remotes
+// Fs represents a union of upstreams
 type Fs struct {
-	name     string       // name of this remote
-	features *fs.Features // optional features
-	opt      Options      // options for this Fs
-	root     string       // the path we are working on
-	remotes  []fs.Fs      // slice of remotes
-	wr       fs.Fs        // writable remote
-	hashSet  hash.Set     // intersection of hash types
-}
-
-// Object describes a union Object
-//
-// This is a wrapped object which returns the Union Fs as its parent
-type Object struct {
-	fs.Object
-	fs *Fs // what this object is part of
-}
-
-// Wrap an existing object in the union Object
-func (f *Fs) wrapObject(o fs.Object) *Object {
-	return &Object{
-		Object: o,
-		fs:     f,
-	}
-}
-
-// Fs returns the union Fs as the parent
-func (o *Object) Fs() fs.Info {
-	return o.fs
+	name         string         // name of this remote
+	features     *fs.Features   // optional features
+	opt          Options        // options for this Fs
+	root         string         // the path we are working on
+	upstreams    []*upstream.Fs // slice of upstreams
+	hashSet      hash.Set       // intersection of hash types
+	actionPolicy policy.Policy  // policy for ACTION
+	createPolicy policy.Policy  // policy for CREATE
+	searchPolicy policy.Policy  // policy for SEARCH
 }
 
 // Name of the remote (as passed into NewFs)
@@ -89,19 +95,73 @@ func (f *Fs) Features() *fs.Features {
 	return f.features
 }
 
-// Rmdir removes the root directory of the Fs object
+// upstreamIndex returns the index of u in the upstreams or -1 if not found
+func (f *Fs) upstreamIndex(u *upstream.Fs) int {
+	for i := range f.upstreams {
+		if f.upstreams[i] == u {
+			return i
+		}
+	}
+	return -1
+}
+
+// sameUpstreams returns whether src has the same upstreams as f so
+// that upstreams can be matched by index
+func (f *Fs) sameUpstreams(src *Fs) bool {
+	return src.name == f.name && len(src.upstreams) == len(f.upstreams)
+}
+
+// Rmdir removes the directory dir on all the upstreams chosen by the
+// action policy
 func (f *Fs) Rmdir(ctx context.Context, dir string) error {
-	return f.wr.Rmdir(ctx, dir)
+	upstreams, err := f.actionPolicy.Action(ctx, f.upstreams, dir)
+	if err == fs.ErrorObjectNotFound {
+		return fs.ErrorDirNotFound
+	}
+	if err != nil {
+		return err
+	}
+	errs := Errors(make([]error, len(upstreams)))
+	multithread(len(upstreams), func(i int) {
+		err := upstreams[i].Rmdir(ctx, dir)
+		if err != nil {
+			errs[i] = errors.Wrap(err, upstreams[i].Name())
+		}
+	})
+	return errs.Err()
 }
 
-// Hashes returns hash.HashNone to indicate remote hashing is unavailable
+// Hashes returns the hash types supported by all the upstreams
 func (f *Fs) Hashes() hash.Set {
 	return f.hashSet
 }
 
-// Mkdir makes the root directory of the Fs object
+// mkdir makes dir on the upstreams chosen by the create policy,
+// making its parents first if no upstream has them, and returns the
+// upstreams it was made on
+func (f *Fs) mkdir(ctx context.Context, dir string) ([]*upstream.Fs, error) {
+	upstreams, err := f.createPolicy.Create(ctx, f.upstreams, dir)
+	if err == fs.ErrorObjectNotFound && dir != "" {
+		upstreams, err = f.mkdir(ctx, parentDir(dir))
+	}
+	if err != nil {
+		return nil, err
+	}
+	errs := Errors(make([]error, len(upstreams)))
+	multithread(len(upstreams), func(i int) {
+		err := upstreams[i].Mkdir(ctx, dir)
+		if err != nil {
+			errs[i] = errors.Wrap(err, upstreams[i].Name())
+		}
+	})
+	return upstreams, errs.Err()
+}
+
+// Mkdir makes the directory dir on the upstreams chosen by the create
+// policy
 func (f *Fs) Mkdir(ctx context.Context, dir string) error {
-	return f.wr.Mkdir(ctx, dir)
+	_, err := f.mkdir(ctx, dir)
+	return err
 }
 
 // Purge all files in the root and the root directory
@@ -111,7 +171,21 @@ func (f *Fs) Mkdir(ctx context.Context, dir string) error {
 //
 // Return an error if it doesn't exist
 func (f *Fs) Purge(ctx context.Context) error {
-	return f.wr.Features().Purge(ctx)
+	upstreams, err := f.actionPolicy.Action(ctx, f.upstreams, "")
+	if err == fs.ErrorObjectNotFound {
+		return fs.ErrorDirNotFound
+	}
+	if err != nil {
+		return err
+	}
+	errs := Errors(make([]error, len(upstreams)))
+	multithread(len(upstreams), func(i int) {
+		err := upstreams[i].Features().Purge(ctx)
+		if err != nil {
+			errs[i] = errors.Wrap(err, upstreams[i].Name())
+		}
+	})
+	return errs.Err()
 }
 
 // Copy src to this remote using server side copy operations.
@@ -124,15 +198,39 @@ func (f *Fs) Purge(ctx context.Context) error {
 //
 // If it isn't possible then return fs.ErrorCantCopy
 func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
-	if src.Fs() != f.wr {
+	srcObj, ok := src.(*Object)
+	if !ok || !f.sameUpstreams(srcObj.fs) {
 		fs.Debugf(src, "Can't copy - not same remote type")
 		return nil, fs.ErrorCantCopy
 	}
-	o, err := f.wr.Features().Copy(ctx, src, remote)
+	upstreams, err := f.createUpstreams(ctx, remote)
 	if err != nil {
 		return nil, err
 	}
-	return f.wrapObject(o), nil
+	// Find the source on each of the chosen upstreams
+	srcObjs := make([]fs.Object, len(upstreams))
+	for i, u := range upstreams {
+		srcObjs[i] = srcObj.onUpstream(f.upstreamIndex(u))
+		if srcObjs[i] == nil || u.Features().Copy == nil {
+			fs.Debugf(src, "Can't copy - not present on %v", u)
+			return nil, fs.ErrorCantCopy
+		}
+	}
+	objs := make([]upstream.Entry, len(upstreams))
+	errs := Errors(make([]error, len(upstreams)))
+	multithread(len(upstreams), func(i int) {
+		u := upstreams[i]
+		o, err := u.Features().Copy(ctx, srcObjs[i], remote)
+		if err != nil {
+			errs[i] = errors.Wrap(err, u.Name())
+			return
+		}
+		objs[i] = u.WrapObject(o)
+	})
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return f.wrapObject(objs...)
 }
 
 // Move src to this remote using server side move operations.
@@ -145,15 +243,38 @@ func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object,
 //
 // If it isn't possible then return fs.ErrorCantMove
 func (f *Fs) Move(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
-	if src.Fs() != f.wr {
+	srcObj, ok := src.(*Object)
+	if !ok || !f.sameUpstreams(srcObj.fs) {
 		fs.Debugf(src, "Can't move - not same remote type")
 		return nil, fs.ErrorCantMove
 	}
-	o, err := f.wr.Features().Move(ctx, src, remote)
-	if err != nil {
+	// Every copy of the source must be moved so it disappears
+	// from the union
+	srcEntries := srcObj.candidates()
+	upstreams := make([]*upstream.Fs, len(srcEntries))
+	for i, e := range srcEntries {
+		u := f.upstreams[srcObj.fs.upstreamIndex(e.UpstreamFs())]
+		if !e.UpstreamFs().IsWritable() || !u.IsWritable() || u.Features().Move == nil {
+			fs.Debugf(src, "Can't move - can't move on %v", u)
+			return nil, fs.ErrorCantMove
+		}
+		upstreams[i] = u
+	}
+	objs := make([]upstream.Entry, len(upstreams))
+	errs := Errors(make([]error, len(upstreams)))
+	multithread(len(upstreams), func(i int) {
+		u := upstreams[i]
+		o, err := u.Features().Move(ctx, srcEntries[i].(*upstream.Object).UnWrap(), remote)
+		if err != nil {
+			errs[i] = errors.Wrap(err, u.Name())
+			return
+		}
+		objs[i] = u.WrapObject(o)
+	})
+	if err := errs.Err(); err != nil {
 		return nil, err
 	}
-	return f.wrapObject(o), err
+	return f.wrapObject(objs...)
 }
 
 // DirMove moves src, srcRemote to this remote at dstRemote
@@ -166,11 +287,38 @@ func (f *Fs) Move(ctx context.Context, src fs.Object, remote string) (fs.Object,
 // If destination exists then return fs.ErrorDirExists
 func (f *Fs) DirMove(ctx context.Context, src fs.Fs, srcRemote, dstRemote string) error {
 	srcFs, ok := src.(*Fs)
-	if !ok {
+	if !ok || !f.sameUpstreams(srcFs) {
 		fs.Debugf(srcFs, "Can't move directory - not same remote type")
 		return fs.ErrorCantDirMove
 	}
-	return f.wr.Features().DirMove(ctx, srcFs.wr, srcRemote, dstRemote)
+	if _, err := f.List(ctx, dstRemote); err == nil {
+		return fs.ErrorDirExists
+	}
+	srcUpstreams, err := srcFs.actionPolicy.Action(ctx, srcFs.upstreams, srcRemote)
+	if err == fs.ErrorObjectNotFound {
+		return fs.ErrorDirNotFound
+	}
+	if err != nil {
+		return err
+	}
+	upstreams := make([]*upstream.Fs, len(srcUpstreams))
+	for i, su := range srcUpstreams {
+		u := f.upstreams[srcFs.upstreamIndex(su)]
+		if !u.IsWritable() || u.Features().DirMove == nil {
+			fs.Debugf(srcFs, "Can't move directory - can't move on %v", u)
+			return fs.ErrorCantDirMove
+		}
+		upstreams[i] = u
+	}
+	errs := Errors(make([]error, len(upstreams)))
+	multithread(len(upstreams), func(i int) {
+		u := upstreams[i]
+		err := u.Features().DirMove(ctx, srcUpstreams[i].Fs, srcRemote, dstRemote)
+		if err != nil {
+			errs[i] = errors.Wrap(err, u.Name())
+		}
+	})
+	return errs.Err()
 }
 
 // ChangeNotify calls the passed function with a path
@@ -185,8 +333,8 @@ func (f *Fs) DirMove(ctx context.Context, src fs.Fs, srcRemote, dstRemote string
 func (f *Fs) ChangeNotify(ctx context.Context, fn func(string, fs.EntryType), ch <-chan time.Duration) {
 	var remoteChans []chan time.Duration
 
-	for _, remote := range f.remotes {
-		if ChangeNotify := remote.Features().ChangeNotify; ChangeNotify != nil {
+	for _, u := range f.upstreams {
+		if ChangeNotify := u.Features().ChangeNotify; ChangeNotify != nil {
 			ch := make(chan time.Duration)
 			remoteChans = append(remoteChans, ch)
 			ChangeNotify(ctx, fn, ch)
@@ -208,42 +356,136 @@ func (f *Fs) ChangeNotify(ctx context.Context, fn func(string, fs.EntryType), ch
 // DirCacheFlush resets the directory cache - used in testing
 // as an optional interface
 func (f *Fs) DirCacheFlush() {
-	for _, remote := range f.remotes {
-		if DirCacheFlush := remote.Features().DirCacheFlush; DirCacheFlush != nil {
+	for _, u := range f.upstreams {
+		if DirCacheFlush := u.Features().DirCacheFlush; DirCacheFlush != nil {
 			DirCacheFlush()
 		}
 	}
 }
 
-// PutStream uploads to the remote path with the modTime given of indeterminate size
+// createUpstreams returns the upstreams a new object at remote should
+// be created on, making its parent directory if necessary
+func (f *Fs) createUpstreams(ctx context.Context, remote string) ([]*upstream.Fs, error) {
+	upstreams, err := f.createPolicy.Create(ctx, f.upstreams, remote)
+	if err == fs.ErrorObjectNotFound {
+		upstreams, err = f.mkdir(ctx, parentDir(remote))
+	}
+	return upstreams, err
+}
+
+// put uploads in to the upstreams chosen by the create policy
+func (f *Fs) put(ctx context.Context, in io.Reader, src fs.ObjectInfo, stream bool, options ...fs.OpenOption) (fs.Object, error) {
+	upstreams, err := f.createUpstreams(ctx, src.Remote())
+	if err != nil {
+		return nil, err
+	}
+	objs := make([]upstream.Entry, len(upstreams))
+	err = fanOut(in, len(upstreams), func(i int, in io.Reader) error {
+		u := upstreams[i]
+		var o fs.Object
+		var err error
+		if stream {
+			o, err = u.Features().PutStream(ctx, in, src, options...)
+		} else {
+			o, err = u.Put(ctx, in, src, options...)
+		}
+		if err != nil {
+			return errors.Wrap(err, u.Name())
+		}
+		objs[i] = u.WrapObject(o)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f.wrapObject(objs...)
+}
+
+// Put in to the remote path with the modTime given of the given size
+//
+// If the object exists it is updated on the upstreams chosen by the
+// action policy, otherwise it is created on the upstreams chosen by
+// the create policy.
 //
 // May create the object even if it returns an error - if so
 // will return the object and the error, otherwise will return
 // nil and the error
-func (f *Fs) PutStream(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
-	o, err := f.wr.Features().PutStream(ctx, in, src, options...)
-	if err != nil {
+func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	o, err := f.NewObject(ctx, src.Remote())
+	switch err {
+	case nil:
+		return o, o.Update(ctx, in, src, options...)
+	case fs.ErrorObjectNotFound:
+		return f.put(ctx, in, src, false, options...)
+	default:
 		return nil, err
 	}
-	return f.wrapObject(o), err
 }
 
-// About gets quota information from the Fs
-func (f *Fs) About(ctx context.Context) (*fs.Usage, error) {
-	return f.wr.Features().About(ctx)
-}
-
-// Put in to the remote path with the modTime given of the given size
+// PutStream uploads to the remote path with the modTime given of indeterminate size
 //
 // May create the object even if it returns an error - if so
 // will return the object and the error, otherwise will return
 // nil and the error
-func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
-	o, err := f.wr.Put(ctx, in, src, options...)
-	if err != nil {
+func (f *Fs) PutStream(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	o, err := f.NewObject(ctx, src.Remote())
+	switch err {
+	case nil:
+		return o, o.Update(ctx, in, src, options...)
+	case fs.ErrorObjectNotFound:
+		return f.put(ctx, in, src, true, options...)
+	default:
 		return nil, err
 	}
-	return f.wrapObject(o), err
+}
+
+// About gets quota information from the Fs by adding up the usage
+// of all the upstreams which support it
+func (f *Fs) About(ctx context.Context) (*fs.Usage, error) {
+	usage := &fs.Usage{
+		Total:   new(int64),
+		Used:    new(int64),
+		Trashed: new(int64),
+		Other:   new(int64),
+		Free:    new(int64),
+		Objects: new(int64),
+	}
+	found := false
+	for _, u := range f.upstreams {
+		usg, err := u.About(ctx)
+		if err == upstream.ErrUsageFieldNotSupported {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "About failed on %v", u)
+		}
+		found = true
+		// A field is only valid if all the upstreams report it
+		for _, p := range []struct {
+			sum **int64
+			v   *int64
+		}{
+			{&usage.Total, usg.Total},
+			{&usage.Used, usg.Used},
+			{&usage.Trashed, usg.Trashed},
+			{&usage.Other, usg.Other},
+			{&usage.Free, usg.Free},
+			{&usage.Objects, usg.Objects},
+		} {
+			if *p.sum == nil {
+				continue
+			}
+			if p.v == nil {
+				*p.sum = nil
+				continue
+			}
+			**p.sum += *p.v
+		}
+	}
+	if !found {
+		return nil, errors.New("none of the upstreams support About")
+	}
+	return usage, nil
 }
 
 // List the objects and directories in dir into entries.  The
@@ -256,60 +498,199 @@ func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options .
 // This should return ErrDirNotFound if the directory isn't
 // found.
 func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
-	set := make(map[string]fs.DirEntry)
+	entriess := make([][]upstream.Entry, len(f.upstreams))
+	errs := Errors(make([]error, len(f.upstreams)))
+	multithread(len(f.upstreams), func(i int) {
+		u := f.upstreams[i]
+		uEntries, err := u.List(ctx, dir)
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		for _, e := range uEntries {
+			ue, err := u.WrapEntry(e)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			entriess[i] = append(entriess[i], ue)
+		}
+	})
 	found := false
-	for _, remote := range f.remotes {
-		var remoteEntries, err = remote.List(ctx, dir)
+	for i, err := range errs {
 		if err == fs.ErrorDirNotFound {
+			errs[i] = nil
 			continue
 		}
-		if err != nil {
-			return nil, errors.Wrapf(err, "List failed on %v", remote)
-		}
-		found = true
-		for _, remoteEntry := range remoteEntries {
-			set[remoteEntry.Remote()] = remoteEntry
+		if err == nil {
+			found = true
+		} else {
+			errs[i] = errors.Wrapf(err, "List failed on %v", f.upstreams[i])
 		}
 	}
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
 	if !found {
 		return nil, fs.ErrorDirNotFound
 	}
-	for _, entry := range set {
-		if o, ok := entry.(fs.Object); ok {
-			entry = f.wrapObject(o)
+	// Group the entries by remote keeping the upstream order
+	var remotes []string
+	set := make(map[string][]upstream.Entry)
+	for _, uEntries := range entriess {
+		for _, e := range uEntries {
+			remote := e.Remote()
+			if _, ok := set[remote]; !ok {
+				remotes = append(remotes, remote)
+			}
+			set[remote] = append(set[remote], e)
+		}
+	}
+	for _, remote := range remotes {
+		entry, err := f.mergeEntries(set[remote]...)
+		if err != nil {
+			return nil, err
 		}
 		entries = append(entries, entry)
 	}
 	return entries, nil
 }
 
-// NewObject creates a new remote union file object based on the first Object it finds (reverse remote order)
-func (f *Fs) NewObject(ctx context.Context, path string) (fs.Object, error) {
-	for i := range f.remotes {
-		var remote = f.remotes[len(f.remotes)-i-1]
-		var obj, err = remote.NewObject(ctx, path)
-		if err == fs.ErrorObjectNotFound {
-			continue
-		}
+// NewObject creates a new remote union file object from the copies
+// of the object found on the upstreams using the search policy
+func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	objs := make([]upstream.Entry, len(f.upstreams))
+	errs := Errors(make([]error, len(f.upstreams)))
+	multithread(len(f.upstreams), func(i int) {
+		u := f.upstreams[i]
+		o, err := u.NewObject(ctx, remote)
 		if err != nil {
-			return nil, errors.Wrapf(err, "NewObject failed on %v", remote)
+			cause := errors.Cause(err)
+			if cause != fs.ErrorObjectNotFound && cause != fs.ErrorNotAFile {
+				errs[i] = errors.Wrapf(err, "NewObject failed on %v", u)
+			}
+			return
 		}
-		return f.wrapObject(obj), nil
+		objs[i] = u.WrapObject(o)
+	})
+	if err := errs.Err(); err != nil {
+		return nil, err
 	}
-	return nil, fs.ErrorObjectNotFound
+	return f.wrapObject(objs...)
 }
 
-// Precision is the greatest Precision of all remotes
+// Precision is the greatest Precision of all upstreams
 func (f *Fs) Precision() time.Duration {
 	var greatestPrecision time.Duration
-	for _, remote := range f.remotes {
-		if remote.Precision() > greatestPrecision {
-			greatestPrecision = remote.Precision()
+	for _, u := range f.upstreams {
+		if u.Precision() > greatestPrecision {
+			greatestPrecision = u.Precision()
 		}
 	}
 	return greatestPrecision
 }
 
+// wrapObject makes a union Object from the copies of an object on the
+// upstreams, ignoring nil entries, using the search policy to choose
+// the one to read from
+func (f *Fs) wrapObject(entries ...upstream.Entry) (*Object, error) {
+	var objs []upstream.Entry
+	for _, e := range entries {
+		if e != nil {
+			objs = append(objs, e)
+		}
+	}
+	e, err := f.searchPolicy.SearchEntries(objs...)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{
+		Object: e.(*upstream.Object),
+		fs:     f,
+		co:     objs,
+	}, nil
+}
+
+// mergeEntries merges the entries with the same remote on different
+// upstreams into a single union entry
+//
+// If the remote is a file on some upstreams and a directory on others
+// the type of the entry chosen by the search policy wins.
+func (f *Fs) mergeEntries(entries ...upstream.Entry) (fs.DirEntry, error) {
+	e, err := f.searchPolicy.SearchEntries(entries...)
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := e.(*upstream.Directory); ok {
+		return d.Directory, nil
+	}
+	var objs []upstream.Entry
+	for _, e := range entries {
+		if _, ok := e.(*upstream.Object); ok {
+			objs = append(objs, e)
+		}
+	}
+	return &Object{
+		Object: e.(*upstream.Object),
+		fs:     f,
+		co:     objs,
+	}, nil
+}
+
+// parentDir returns the parent directory of absPath, "" for the root
+func parentDir(absPath string) string {
+	parent := path.Dir(strings.TrimRight(filepath.ToSlash(absPath), "/"))
+	if parent == "." || parent == "/" {
+		parent = ""
+	}
+	return parent
+}
+
+// multithread runs fn(i) for i in 0..num-1 concurrently and waits
+// for them all to finish
+func multithread(num int, fn func(int)) {
+	var wg sync.WaitGroup
+	for i := 0; i < num; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// fanOut calls fn(i, reader) for i in 0..num-1 concurrently, with
+// each reader receiving a copy of the data in in
+func fanOut(in io.Reader, num int, fn func(int, io.Reader) error) error {
+	if num == 1 {
+		return fn(0, in)
+	}
+	errs := Errors(make([]error, num+1))
+	writers := make([]io.Writer, num)
+	pipes := make([]*io.PipeWriter, num)
+	var wg sync.WaitGroup
+	for i := 0; i < num; i++ {
+		pr, pw := io.Pipe()
+		writers[i], pipes[i] = pw, pw
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := fn(i, pr)
+			// Stop the copy if this reader failed
+			_ = pr.CloseWithError(err)
+			errs[i] = err
+		}(i)
+	}
+	_, err := io.Copy(io.MultiWriter(writers...), in)
+	for _, pw := range pipes {
+		_ = pw.CloseWithError(err)
+	}
+	wg.Wait()
+	errs[num] = err
+	return errs.Err()
+}
+
 // NewFs constructs an Fs from the path.
 //
 // The returned Fs is the actual Fs, referenced by remote in the config
@@ -327,45 +708,46 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 		return nil, errors.New("union can't point to a single remote - check the value of the remotes setting")
 	}
 	for _, remote := range opt.Remotes {
-		if strings.HasPrefix(remote, name+":") {
+		if strings.HasPrefix(remote, name+":") || strings.HasPrefix(remote, name+",") {
 			return nil, errors.New("can't point union remote at itself - check the value of the remote setting")
 		}
 	}
 
-	var remotes []fs.Fs
-	for i := range opt.Remotes {
-		// Last remote first so we return the correct (last) matching fs in case of fs.ErrorIsFile
-		var remote = opt.Remotes[len(opt.Remotes)-i-1]
-		_, configName, fsPath, err := fs.ParseRemote(remote)
-		if err != nil {
+	f := &Fs{
+		name:      name,
+		root:      root,
+		opt:       *opt,
+		upstreams: make([]*upstream.Fs, len(opt.Remotes)),
+	}
+	if f.actionPolicy, err = policy.Get(opt.ActionPolicy); err != nil {
+		return nil, err
+	}
+	if f.createPolicy, err = policy.Get(opt.CreatePolicy); err != nil {
+		return nil, err
+	}
+	if f.searchPolicy, err = policy.Get(opt.SearchPolicy); err != nil {
+		return nil, err
+	}
+
+	cacheTime := time.Duration(opt.CacheTime) * time.Second
+	isFile := false
+	for i, remote := range opt.Remotes {
+		f.upstreams[i], err = upstream.New(remote, root, cacheTime)
+		if err == fs.ErrorIsFile {
+			isFile = true
+		} else if err != nil {
 			return nil, err
 		}
-		var rootString = path.Join(fsPath, filepath.ToSlash(root))
-		if configName != "local" {
-			rootString = configName + ":" + rootString
-		}
-		myFs, err := cache.Get(rootString)
+	}
+	if isFile {
+		// Point the union at the parent directory of the file
+		parent, err := NewFs(name, parentDir(root), m)
 		if err != nil {
-			if err == fs.ErrorIsFile {
-				return myFs, err
-			}
 			return nil, err
 		}
-		remotes = append(remotes, myFs)
+		return parent, fs.ErrorIsFile
 	}
 
-	// Reverse the remotes again so they are in the order as before
-	for i, j := 0, len(remotes)-1; i < j; i, j = i+1, j-1 {
-		remotes[i], remotes[j] = remotes[j], remotes[i]
-	}
-
-	f := &Fs{
-		name:    name,
-		root:    root,
-		opt:     *opt,
-		remotes: remotes,
-		wr:      remotes[len(remotes)-1],
-	}
 	var features = (&fs.Features{
 		CaseInsensitive:         true,
 		DuplicateFiles:          false,
@@ -376,26 +758,41 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 		SetTier:                 true,
 		GetTier:                 true,
 	}).Fill(f)
-	features = features.Mask(f.wr) // mask the features just on the writable fs
+	// mask the features on the upstreams which can be written to
+	writable := 0
+	for _, u := range f.upstreams {
+		if u.IsWritable() {
+			features = features.Mask(u)
+			writable++
+		}
+	}
+	if writable == 0 {
+		for _, u := range f.upstreams {
+			features = features.Mask(u)
+		}
+	}
 
-	// Really need the union of all remotes for these, so
+	// Really need the union of all upstreams for these, so
 	// re-instate and calculate separately.
 	features.ChangeNotify = f.ChangeNotify
 	features.DirCacheFlush = f.DirCacheFlush
+	features.About = f.About
 
-	// FIXME maybe should be masking the bools here?
-
-	// Clear ChangeNotify and DirCacheFlush if all are nil
+	// Clear ChangeNotify, DirCacheFlush and About if all are nil
 	clearChangeNotify := true
 	clearDirCacheFlush := true
-	for _, remote := range f.remotes {
-		remoteFeatures := remote.Features()
-		if remoteFeatures.ChangeNotify != nil {
+	clearAbout := true
+	for _, u := range f.upstreams {
+		uFeatures := u.Features()
+		if uFeatures.ChangeNotify != nil {
 			clearChangeNotify = false
 		}
-		if remoteFeatures.DirCacheFlush != nil {
+		if uFeatures.DirCacheFlush != nil {
 			clearDirCacheFlush = false
 		}
+		if uFeatures.About != nil {
+			clearAbout = false
+		}
 	}
 	if clearChangeNotify {
 		features.ChangeNotify = nil
@@ -403,13 +800,16 @@ func NewFs(name, root string, m configmap.Mapper) (fs.Fs, error) {
 	if clearDirCacheFlush {
 		features.DirCacheFlush = nil
 	}
+	if clearAbout {
+		features.About = nil
+	}
 
 	f.features = features
 
 	// Get common intersection of hashes
-	hashSet := f.remotes[0].Hashes()
-	for _, remote := range f.remotes[1:] {
-		hashSet = hashSet.Overlap(remote.Hashes())
+	hashSet := f.upstreams[0].Hashes()
+	for _, u := range f.upstreams[1:] {
+		hashSet = hashSet.Overlap(u.Hashes())
 	}
 	f.hashSet = hashSet
 
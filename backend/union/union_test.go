@@ -2,17 +2,83 @@
 package union_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	_ "github.com/ncw/rclone/backend/local"
+	"github.com/ncw/rclone/fstest"
 	"github.com/ncw/rclone/fstest/fstests"
+	"github.com/stretchr/testify/require"
 )
 
 // TestIntegration runs integration tests against the remote
 func TestIntegration(t *testing.T) {
+	if *fstest.RemoteName == "" {
+		t.Skip("Skipping as -remote not set")
+	}
 	fstests.Run(t, &fstests.Opt{
-		RemoteName:  "TestUnion:",
+		RemoteName:  *fstest.RemoteName,
 		NilObject:   nil,
 		SkipFsMatch: true,
 	})
 }
+
+// makeUpstreams makes n temporary directories for the upstreams
+// returning them with the modifiers appended
+func makeUpstreams(t *testing.T, modifiers ...string) (remotes string) {
+	for i, modifier := range modifiers {
+		dir := filepath.Join(os.TempDir(), "rclone-union-test-"+string(rune('a'+i)))
+		require.NoError(t, os.MkdirAll(dir, 0700))
+		if remotes != "" {
+			remotes += " "
+		}
+		remotes += dir + modifier
+	}
+	return remotes
+}
+
+// runPolicies runs the standard tests with the policies given
+func runPolicies(t *testing.T, remotes, action, create, search string) {
+	if *fstest.RemoteName != "" {
+		t.Skip("Skipping as -remote set")
+	}
+	name := "TestUnion"
+	fstests.Run(t, &fstests.Opt{
+		RemoteName: name + ":",
+		ExtraConfig: []fstests.ExtraConfigItem{
+			{Name: name, Key: "type", Value: "union"},
+			{Name: name, Key: "remotes", Value: remotes},
+			{Name: name, Key: "action_policy", Value: action},
+			{Name: name, Key: "create_policy", Value: create},
+			{Name: name, Key: "search_policy", Value: search},
+		},
+		UnimplementableFsMethods:     []string{"OpenWriterAt", "DuplicateFiles"},
+		UnimplementableObjectMethods: []string{"MimeType"},
+		SkipFsMatch:                  true,
+	})
+}
+
+func TestStandard(t *testing.T) {
+	runPolicies(t, makeUpstreams(t, "", "", ""), "epall", "epmfs", "ff")
+}
+
+func TestRO(t *testing.T) {
+	runPolicies(t, makeUpstreams(t, "", ":ro", ""), "epall", "epmfs", "ff")
+}
+
+func TestNC(t *testing.T) {
+	runPolicies(t, makeUpstreams(t, "", ":nc", ""), "epall", "epmfs", "ff")
+}
+
+func TestPolicy1(t *testing.T) {
+	runPolicies(t, makeUpstreams(t, "", "", ""), "all", "lus", "all")
+}
+
+func TestPolicy2(t *testing.T) {
+	runPolicies(t, makeUpstreams(t, "", "", ""), "all", "rand", "ff")
+}
+
+func TestPolicy3(t *testing.T) {
+	runPolicies(t, makeUpstreams(t, "", "", ""), "all", "all", "newest")
+}
@@ -0,0 +1,214 @@
+// Package upstream wraps the remotes making up a union with the
+// information the policies need to choose between them.
+package upstream
+
+import (
+	"context"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/cache"
+	"github.com/ncw/rclone/fs/fspath"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrUsageFieldNotSupported is returned when the upstream
+	// doesn't report the usage field a policy needs
+	ErrUsageFieldNotSupported = errors.New("this usage field is not supported")
+)
+
+// Fs is a wrapped fs.Fs which is one of the upstreams of a union
+type Fs struct {
+	fs.Fs
+	RootFs      fs.Fs // the upstream without the union root, for usage
+	writable    bool
+	creatable   bool
+	cacheTime   time.Duration // time to cache the usage for
+	cacheMu     sync.Mutex
+	cacheExpiry time.Time // when the usage expires
+	usage       *fs.Usage // cached usage
+}
+
+// Entry describes a directory entry on an upstream
+type Entry interface {
+	fs.DirEntry
+	UpstreamFs() *Fs
+}
+
+// Object describes a wrapped Object
+//
+// This is a wrapped Object which knows its upstream
+type Object struct {
+	fs.Object
+	f *Fs
+}
+
+// Directory describes a wrapped Directory
+//
+// This is a wrapped Directory which knows its upstream
+type Directory struct {
+	fs.Directory
+	f *Fs
+}
+
+// New creates the upstream for remote with root appended to its path
+//
+// The remote may have a ":ro" suffix to make it read only or a ":nc"
+// suffix to stop new files and directories being created on it.
+//
+// If root points to a file the upstream is returned with
+// fs.ErrorIsFile.
+func New(remote, root string, cacheTime time.Duration) (*Fs, error) {
+	f := &Fs{
+		writable:  true,
+		creatable: true,
+		cacheTime: cacheTime,
+	}
+	switch {
+	case strings.HasSuffix(remote, ":ro"):
+		remote = remote[:len(remote)-3]
+		f.writable = false
+		f.creatable = false
+	case strings.HasSuffix(remote, ":nc"):
+		remote = remote[:len(remote)-3]
+		f.creatable = false
+	}
+	_, _, fsPath, err := fs.ParseRemote(remote)
+	if err != nil {
+		return nil, err
+	}
+	// keep any connection string parameters in the remote name
+	configName, _ := fspath.Parse(remote)
+	rootString := path.Join(fsPath, filepath.ToSlash(root))
+	baseString := fsPath
+	if configName != "" {
+		rootString = configName + ":" + rootString
+		baseString = configName + ":" + baseString
+	}
+	f.RootFs, err = cache.Get(baseString)
+	if err != nil && err != fs.ErrorIsFile {
+		return nil, err
+	}
+	myFs, err := cache.Get(rootString)
+	if err != nil && err != fs.ErrorIsFile {
+		return nil, err
+	}
+	f.Fs = myFs
+	return f, err
+}
+
+// UnWrap returns the Fs that this Fs is wrapping
+func (f *Fs) UnWrap() fs.Fs {
+	return f.Fs
+}
+
+// IsWritable returns whether the upstream may be modified
+func (f *Fs) IsWritable() bool {
+	return f.writable
+}
+
+// IsCreatable returns whether new files and directories may be
+// created on the upstream
+func (f *Fs) IsCreatable() bool {
+	return f.creatable
+}
+
+// WrapObject wraps an Object so it knows its upstream
+func (f *Fs) WrapObject(o fs.Object) *Object {
+	return &Object{
+		Object: o,
+		f:      f,
+	}
+}
+
+// WrapDirectory wraps a Directory so it knows its upstream
+func (f *Fs) WrapDirectory(d fs.Directory) *Directory {
+	return &Directory{
+		Directory: d,
+		f:         f,
+	}
+}
+
+// WrapEntry wraps a DirEntry so it knows its upstream
+func (f *Fs) WrapEntry(e fs.DirEntry) (Entry, error) {
+	switch x := e.(type) {
+	case fs.Object:
+		return f.WrapObject(x), nil
+	case fs.Directory:
+		return f.WrapDirectory(x), nil
+	default:
+		return nil, errors.Errorf("unknown object type %T", e)
+	}
+}
+
+// UpstreamFs returns the upstream the Object is on
+func (o *Object) UpstreamFs() *Fs {
+	return o.f
+}
+
+// UnWrap returns the wrapped Object
+func (o *Object) UnWrap() fs.Object {
+	return o.Object
+}
+
+// UpstreamFs returns the upstream the Directory is on
+func (d *Directory) UpstreamFs() *Fs {
+	return d.f
+}
+
+// About gets the usage of the upstream, caching it for the cache time
+func (f *Fs) About(ctx context.Context) (*fs.Usage, error) {
+	f.cacheMu.Lock()
+	defer f.cacheMu.Unlock()
+	if f.usage != nil && time.Now().Before(f.cacheExpiry) {
+		return f.usage, nil
+	}
+	do := f.RootFs.Features().About
+	if do == nil {
+		return nil, ErrUsageFieldNotSupported
+	}
+	usage, err := do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	f.usage = usage
+	f.cacheExpiry = time.Now().Add(f.cacheTime)
+	return usage, nil
+}
+
+// GetFreeSpace gets the free space of the upstream
+func (f *Fs) GetFreeSpace(ctx context.Context) (int64, error) {
+	usage, err := f.About(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if usage.Free == nil {
+		return 0, ErrUsageFieldNotSupported
+	}
+	return *usage.Free, nil
+}
+
+// GetUsedSpace gets the used space of the upstream
+func (f *Fs) GetUsedSpace(ctx context.Context) (int64, error) {
+	usage, err := f.About(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if usage.Used == nil {
+		return 0, ErrUsageFieldNotSupported
+	}
+	return *usage.Used, nil
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.UnWrapper       = (*Fs)(nil)
+	_ fs.ObjectUnWrapper = (*Object)(nil)
+	_ Entry              = (*Object)(nil)
+	_ Entry              = (*Directory)(nil)
+)
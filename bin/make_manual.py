@@ -30,17 +30,22 @@ docs = [
     "b2.md",
     "box.md",
     "cache.md",
+    "chunker.md",
+    "combine.md",
+    "compress.md",
     "crypt.md",
     "dropbox.md",
     "ftp.md",
     "googlecloudstorage.md",
     "drive.md",
     "googlephotos.md",
+    "hasher.md",
     "http.md",
     "hubic.md",
     "jottacloud.md",
     "koofr.md",
     "mega.md",
+    "memory.md",
     "azureblob.md",
     "onedrive.md",
     "opendrive.md",
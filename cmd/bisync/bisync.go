@@ -0,0 +1,504 @@
+package bisync
+
+import (
+	"context"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/fs/operations"
+	fssync "github.com/ncw/rclone/fs/sync"
+	"github.com/pkg/errors"
+)
+
+// Options control the bidirectional sync
+type Options struct {
+	Resync           bool   // copy each side to the other and start again
+	Force            bool   // ignore MaxDeletePercent
+	MaxDeletePercent int    // abort if more than this percentage of files were deleted on a side
+	ConflictSuffix   string // suffix for the losing file of a conflict
+	Workdir          string // directory to keep the listings in
+}
+
+// DefaultOptions returns the default options
+func DefaultOptions() Options {
+	return Options{
+		MaxDeletePercent: 50,
+		ConflictSuffix:   "conflict",
+	}
+}
+
+// change is how a file has changed on one side since the last run
+type change int
+
+const (
+	unchanged change = iota
+	changeNew
+	changeModified
+	changeDeleted
+)
+
+// String turns a change into a string for the logs
+func (c change) String() string {
+	switch c {
+	case unchanged:
+		return "unchanged"
+	case changeNew:
+		return "new"
+	case changeModified:
+		return "modified"
+	case changeDeleted:
+		return "deleted"
+	}
+	return "unknown change " + strconv.Itoa(int(c))
+}
+
+// changed returns whether the file is new or modified
+func (c change) changed() bool {
+	return c == changeNew || c == changeModified
+}
+
+// side is one of the two directories being synced
+type side struct {
+	name  string               // Path1 or Path2 for the logs
+	f     fs.Fs                // the directory
+	prior listing              // the listing at the end of the last run
+	now   listing              // the listing at the start of this run
+	objs  map[string]fs.Object // the objects in now
+	delta map[string]change    // the changes since the last run
+	next  listing              // the listing to save at the end of this run
+}
+
+// bisync holds the state of a bidirectional sync
+type bisync struct {
+	opt       Options
+	path1     *side
+	path2     *side
+	precision time.Duration // precision to compare modification times with
+	hashType  hash.Type     // common hash type or hash.None
+	mu        sync.Mutex    // protects next in the sides
+}
+
+// action is something to do to bring the sides into sync
+type action struct {
+	remote string
+	src    *side // side to copy from or the winner of a conflict
+	dst    *side // side to copy to, delete from or the loser of a conflict
+	kind   actionKind
+}
+
+// actionKind says what an action is
+type actionKind int
+
+const (
+	actionCopy actionKind = iota
+	actionDelete
+	actionConflict
+)
+
+// Bisync syncs the directories f1 and f2 in both directions
+//
+// It uses the listings saved at the end of the previous run to work
+// out what has changed on each side. The listings are only saved if
+// the sync was successful.
+func Bisync(ctx context.Context, f1, f2 fs.Fs, opt Options) (err error) {
+	if opt.Workdir == "" {
+		return errors.New("bisync: no working directory set")
+	}
+	if opt.ConflictSuffix == "" {
+		return errors.New("bisync: conflict suffix can't be empty")
+	}
+	err = os.MkdirAll(opt.Workdir, 0700)
+	if err != nil {
+		return errors.Wrap(err, "bisync: failed to make working directory")
+	}
+	file1, file2, lockFile := listingPaths(opt.Workdir, f1, f2)
+
+	// Only allow one bisync of these paths at once
+	lock, err := os.OpenFile(lockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if os.IsExist(err) {
+		return errors.Errorf("bisync: lock file %q exists - another bisync may be running - remove it if not", lockFile)
+	}
+	if err != nil {
+		return errors.Wrap(err, "bisync: failed to make lock file")
+	}
+	_ = lock.Close()
+	defer func() {
+		if removeErr := os.Remove(lockFile); removeErr != nil && err == nil {
+			err = errors.Wrap(removeErr, "bisync: failed to remove lock file")
+		}
+	}()
+
+	if opt.Resync {
+		return resync(ctx, f1, f2, file1, file2)
+	}
+
+	b := &bisync{
+		opt:       opt,
+		path1:     &side{name: "Path1", f: f1},
+		path2:     &side{name: "Path2", f: f2},
+		precision: fs.GetModifyWindow(f1, f2),
+		hashType:  f1.Hashes().Overlap(f2.Hashes()).GetOne(),
+	}
+	b.path1.prior, err = loadListing(file1)
+	if err == nil {
+		b.path2.prior, err = loadListing(file2)
+	}
+	if err == errNoListing {
+		return errors.New("bisync: no prior listings found - run with --resync first to make them")
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, s := range []*side{b.path1, b.path2} {
+		s.now, s.objs, err = makeListing(ctx, s.f)
+		if err != nil {
+			return err
+		}
+		s.delta = b.findChanges(s.prior, s.now)
+		err = b.checkDeletes(s)
+		if err != nil {
+			return err
+		}
+	}
+
+	actions := b.plan(ctx)
+	if fs.Config.DryRun {
+		for _, a := range actions {
+			b.report(a)
+		}
+		fs.Logf(nil, "Bisync: not syncing as --dry-run set - %d actions would be taken", len(actions))
+		return nil
+	}
+
+	err = b.run(ctx, actions)
+	if err != nil {
+		return errors.Wrap(err, "bisync failed - the listings have not been updated so the next run will try again")
+	}
+	err = b.path1.next.save(file1)
+	if err == nil {
+		err = b.path2.next.save(file2)
+	}
+	if err != nil {
+		return errors.Wrap(err, "bisync: failed to save listings - run with --resync")
+	}
+	fs.Infof(nil, "Bisync successful")
+	return nil
+}
+
+// resync makes both sides contain the files of both, Path1 winning
+// if a file is on both but different, then saves the listings
+func resync(ctx context.Context, f1, f2 fs.Fs, file1, file2 string) error {
+	// Remove the old listings first so a failed resync is
+	// never followed by a normal run
+	if !fs.Config.DryRun {
+		if err := removeListing(file1); err != nil {
+			return err
+		}
+		if err := removeListing(file2); err != nil {
+			return err
+		}
+	}
+	fs.Infof(nil, "Bisync resync: copying Path1 to Path2")
+	err := fssync.CopyDir(ctx, f2, f1, false)
+	if err != nil {
+		return errors.Wrap(err, "bisync resync: failed to copy Path1 to Path2")
+	}
+	fs.Infof(nil, "Bisync resync: copying Path2 to Path1")
+	err = fssync.CopyDir(ctx, f1, f2, false)
+	if err != nil {
+		return errors.Wrap(err, "bisync resync: failed to copy Path2 to Path1")
+	}
+	if fs.Config.DryRun {
+		fs.Logf(nil, "Bisync resync: not saving listings as --dry-run set")
+		return nil
+	}
+	for _, p := range []struct {
+		f    fs.Fs
+		file string
+	}{{f1, file1}, {f2, file2}} {
+		ls, _, err := makeListing(ctx, p.f)
+		if err != nil {
+			return err
+		}
+		err = ls.save(p.file)
+		if err != nil {
+			return err
+		}
+	}
+	fs.Infof(nil, "Bisync resync successful")
+	return nil
+}
+
+// sameInfo returns whether a and b look like the same file
+func (b *bisync) sameInfo(a, c fileInfo) bool {
+	if a.Size != c.Size {
+		return false
+	}
+	if b.precision == fs.ModTimeNotSupported {
+		return true
+	}
+	dt := a.ModTime.Sub(c.ModTime)
+	return dt < b.precision && dt > -b.precision
+}
+
+// findChanges works out what has changed between prior and now
+func (b *bisync) findChanges(prior, now listing) map[string]change {
+	delta := make(map[string]change)
+	for remote, info := range now {
+		priorInfo, found := prior[remote]
+		if !found {
+			delta[remote] = changeNew
+		} else if !b.sameInfo(priorInfo, info) {
+			delta[remote] = changeModified
+		}
+	}
+	for remote := range prior {
+		if _, found := now[remote]; !found {
+			delta[remote] = changeDeleted
+		}
+	}
+	return delta
+}
+
+// checkDeletes returns an error if too many files were deleted on s
+func (b *bisync) checkDeletes(s *side) error {
+	deletes := 0
+	for _, c := range s.delta {
+		if c == changeDeleted {
+			deletes++
+		}
+	}
+	if deletes == 0 || len(s.prior) == 0 {
+		return nil
+	}
+	percent := deletes * 100 / len(s.prior)
+	if percent <= b.opt.MaxDeletePercent {
+		return nil
+	}
+	if b.opt.Force {
+		fs.Logf(nil, "%s: %d of %d files deleted (%d%%) - continuing as --force set", s.name, deletes, len(s.prior), percent)
+		return nil
+	}
+	return errors.Errorf("bisync aborted: %d of %d files deleted on %s (%d%%) which is more than --max-delete-percent %d%% - use --force to sync anyway", deletes, len(s.prior), s.name, percent, b.opt.MaxDeletePercent)
+}
+
+// identical returns whether the current files at remote on both sides
+// are the same
+func (b *bisync) identical(ctx context.Context, remote string) bool {
+	info1, info2 := b.path1.now[remote], b.path2.now[remote]
+	if b.sameInfo(info1, info2) {
+		return true
+	}
+	if info1.Size != info2.Size || b.hashType == hash.None {
+		return false
+	}
+	hash1, err1 := b.path1.objs[remote].Hash(ctx, b.hashType)
+	hash2, err2 := b.path2.objs[remote].Hash(ctx, b.hashType)
+	return err1 == nil && err2 == nil && hash1 != "" && hash1 == hash2
+}
+
+// plan works out the actions needed to bring the sides into sync
+//
+// It also sets up the listings to save if they all succeed.
+func (b *bisync) plan(ctx context.Context) (actions []action) {
+	b.path1.next = copyListing(b.path1.prior)
+	b.path2.next = copyListing(b.path2.prior)
+
+	remotes := make(map[string]struct{})
+	for _, s := range []*side{b.path1, b.path2} {
+		for remote := range s.delta {
+			remotes[remote] = struct{}{}
+		}
+	}
+	sorted := make([]string, 0, len(remotes))
+	for remote := range remotes {
+		sorted = append(sorted, remote)
+	}
+	sort.Strings(sorted)
+
+	for _, remote := range sorted {
+		c1, c2 := b.path1.delta[remote], b.path2.delta[remote]
+		switch {
+		case c1 == changeDeleted && c2 == changeDeleted:
+			// Deleted on both sides
+			delete(b.path1.next, remote)
+			delete(b.path2.next, remote)
+		case c1.changed() && c2.changed():
+			if b.identical(ctx, remote) {
+				b.path1.next[remote] = b.path1.now[remote]
+				b.path2.next[remote] = b.path2.now[remote]
+				continue
+			}
+			// Changed on both sides - the newest wins
+			winner, loser := b.path1, b.path2
+			if b.path2.now[remote].ModTime.After(b.path1.now[remote].ModTime) {
+				winner, loser = b.path2, b.path1
+			}
+			actions = append(actions, action{remote: remote, src: winner, dst: loser, kind: actionConflict})
+		case c1.changed() && c2 == changeDeleted:
+			// Changes win over deletes
+			actions = append(actions, action{remote: remote, src: b.path1, dst: b.path2, kind: actionCopy})
+		case c2.changed() && c1 == changeDeleted:
+			actions = append(actions, action{remote: remote, src: b.path2, dst: b.path1, kind: actionCopy})
+		case c1.changed():
+			actions = append(actions, action{remote: remote, src: b.path1, dst: b.path2, kind: actionCopy})
+		case c2.changed():
+			actions = append(actions, action{remote: remote, src: b.path2, dst: b.path1, kind: actionCopy})
+		case c1 == changeDeleted:
+			if _, found := b.path2.now[remote]; !found {
+				delete(b.path1.next, remote)
+				continue
+			}
+			actions = append(actions, action{remote: remote, src: b.path1, dst: b.path2, kind: actionDelete})
+		case c2 == changeDeleted:
+			if _, found := b.path1.now[remote]; !found {
+				delete(b.path2.next, remote)
+				continue
+			}
+			actions = append(actions, action{remote: remote, src: b.path2, dst: b.path1, kind: actionDelete})
+		}
+	}
+	return actions
+}
+
+// copyListing makes a copy of ls
+func copyListing(ls listing) listing {
+	out := make(listing, len(ls))
+	for remote, info := range ls {
+		out[remote] = info
+	}
+	return out
+}
+
+// report logs what the action would do
+func (b *bisync) report(a action) {
+	switch a.kind {
+	case actionCopy:
+		fs.Logf(a.remote, "Would copy %s file from %s to %s", a.src.delta[a.remote], a.src.name, a.dst.name)
+	case actionDelete:
+		fs.Logf(a.remote, "Would delete from %s as it was deleted on %s", a.dst.name, a.src.name)
+	case actionConflict:
+		fs.Logf(a.remote, "Would resolve conflict: keeping newer file from %s and renaming the one on %s to %q", a.src.name, a.dst.name, b.conflictName(a.remote))
+	}
+}
+
+// conflictName returns the name to rename the loser of a conflict at
+// remote to
+//
+// It is remote with the conflict suffix before the extension, with a
+// number added if the name is already in use.
+func (b *bisync) conflictName(remote string) string {
+	ext := path.Ext(remote)
+	base := strings.TrimSuffix(remote, ext)
+	for i := 1; ; i++ {
+		suffix := b.opt.ConflictSuffix
+		if i > 1 {
+			suffix += strconv.Itoa(i)
+		}
+		name := base + "." + suffix + ext
+		_, found1 := b.path1.now[name]
+		_, found2 := b.path2.now[name]
+		if !found1 && !found2 {
+			return name
+		}
+	}
+}
+
+// setNext records info as the state of remote on s after the run
+func (b *bisync) setNext(s *side, remote string, info fileInfo) {
+	b.mu.Lock()
+	s.next[remote] = info
+	b.mu.Unlock()
+}
+
+// deleteNext records remote as not existing on s after the run
+func (b *bisync) deleteNext(s *side, remote string) {
+	b.mu.Lock()
+	delete(s.next, remote)
+	b.mu.Unlock()
+}
+
+// do carries out the action
+func (b *bisync) do(ctx context.Context, a action) error {
+	src, dst, remote := a.src, a.dst, a.remote
+	switch a.kind {
+	case actionCopy:
+		fs.Infof(remote, "Copying %s file from %s to %s", src.delta[remote], src.name, dst.name)
+		_, err := operations.Copy(ctx, dst.f, dst.objs[remote], remote, src.objs[remote])
+		if err != nil {
+			return err
+		}
+		b.setNext(src, remote, src.now[remote])
+		b.setNext(dst, remote, src.now[remote])
+	case actionDelete:
+		fs.Infof(remote, "Deleting from %s as it was deleted on %s", dst.name, src.name)
+		err := operations.DeleteFile(ctx, dst.objs[remote])
+		if err != nil {
+			return err
+		}
+		b.deleteNext(src, remote)
+		b.deleteNext(dst, remote)
+	case actionConflict:
+		newName := b.conflictName(remote)
+		fs.Logf(remote, "Conflict: changed on both sides - keeping newer file from %s and renaming the one on %s to %q", src.name, dst.name, newName)
+		loser, err := operations.Move(ctx, dst.f, nil, newName, dst.objs[remote])
+		if err != nil {
+			return errors.Wrap(err, "failed to rename conflicting file")
+		}
+		_, err = operations.Copy(ctx, dst.f, nil, remote, src.objs[remote])
+		if err != nil {
+			return err
+		}
+		_, err = operations.Copy(ctx, src.f, nil, newName, loser)
+		if err != nil {
+			return err
+		}
+		for _, s := range []*side{src, dst} {
+			b.setNext(s, remote, src.now[remote])
+			b.setNext(s, newName, dst.now[remote])
+		}
+	}
+	return nil
+}
+
+// run carries out the actions using --transfers at once
+//
+// It returns the last error if any of them fail.
+func (b *bisync) run(ctx context.Context, actions []action) error {
+	var (
+		wg      sync.WaitGroup
+		errMu   sync.Mutex
+		lastErr error
+		in      = make(chan action)
+	)
+	for i := 0; i < fs.Config.Transfers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for a := range in {
+				err := b.do(ctx, a)
+				if err != nil {
+					fs.CountError(ctx, err)
+					fs.Errorf(a.remote, "Bisync failed: %v", err)
+					errMu.Lock()
+					lastErr = err
+					errMu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, a := range actions {
+		in <- a
+	}
+	close(in)
+	wg.Wait()
+	return lastErr
+}
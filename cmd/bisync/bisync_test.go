@@ -0,0 +1,194 @@
+package bisync
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	_ "github.com/ncw/rclone/backend/local"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	t1 = fstest.Time("2001-02-03T04:05:06.499999999Z")
+	t2 = fstest.Time("2011-12-25T12:59:59.123456789Z")
+	t3 = fstest.Time("2013-01-02T03:04:05.000000000Z")
+)
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+// newOpt returns the default options using a temporary workdir
+func newOpt(t *testing.T) (Options, func()) {
+	workdir, err := ioutil.TempDir("", "rclone-bisync-test")
+	require.NoError(t, err)
+	opt := DefaultOptions()
+	opt.Workdir = workdir
+	return opt, func() {
+		_ = os.RemoveAll(workdir)
+	}
+}
+
+func remove(ctx context.Context, t *testing.T, f fs.Fs, remote string) {
+	o, err := f.NewObject(ctx, remote)
+	require.NoError(t, err)
+	require.NoError(t, o.Remove(ctx))
+}
+
+func TestBisyncNeedsResync(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	opt, cleanup := newOpt(t)
+	defer cleanup()
+
+	err := Bisync(ctx, r.Flocal, r.Fremote, opt)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--resync")
+}
+
+func TestBisyncResync(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	opt, cleanup := newOpt(t)
+	defer cleanup()
+
+	file1 := r.WriteFile("one.txt", "path1 one", t1)
+	file2 := r.WriteObject(ctx, "two.txt", "path2 two", t2)
+	file3 := r.WriteFile("both.txt", "path1 wins", t1)
+	r.WriteObject(ctx, "both.txt", "path2 loses", t2)
+
+	opt.Resync = true
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	fstest.CheckItems(t, r.Flocal, file1, file2, file3)
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3)
+
+	// Nothing to do on the next run
+	opt.Resync = false
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	fstest.CheckItems(t, r.Flocal, file1, file2, file3)
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3)
+}
+
+func TestBisyncChanges(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	opt, cleanup := newOpt(t)
+	defer cleanup()
+
+	for _, remote := range []string{"a.txt", "b.txt", "c.txt", "d.txt", "sub/e.txt"} {
+		r.WriteFile(remote, "original "+remote, t1)
+	}
+	opt.Resync = true
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	opt.Resync = false
+
+	// New and modified on path1, deleted on path2
+	newFile := r.WriteFile("new1.txt", "new on path1", t2)
+	a := r.WriteFile("a.txt", "modified on path1", t2)
+	remove(ctx, t, r.Fremote, "b.txt")
+	// New and modified on path2, deleted on path1
+	newFile2 := r.WriteObject(ctx, "sub/new2.txt", "new on path2", t2)
+	c := r.WriteObject(ctx, "c.txt", "modified on path2", t2)
+	remove(ctx, t, r.Flocal, "d.txt")
+	e := fstest.NewItem("sub/e.txt", "original sub/e.txt", t1)
+
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	fstest.CheckItems(t, r.Flocal, newFile, newFile2, a, c, e)
+	fstest.CheckItems(t, r.Fremote, newFile, newFile2, a, c, e)
+
+	// Modified on one side and deleted on the other is copied back
+	a = r.WriteFile("a.txt", "modified again", t3)
+	remove(ctx, t, r.Fremote, "a.txt")
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	fstest.CheckItems(t, r.Flocal, newFile, newFile2, a, c, e)
+	fstest.CheckItems(t, r.Fremote, newFile, newFile2, a, c, e)
+}
+
+func TestBisyncConflict(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	opt, cleanup := newOpt(t)
+	defer cleanup()
+
+	r.WriteFile("file.txt", "original", t1)
+	opt.Resync = true
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	opt.Resync = false
+
+	// The newer file wins and the older one is renamed
+	winner := r.WriteObject(ctx, "file.txt", "newer on path2", t3)
+	r.WriteFile("file.txt", "older on path1", t2)
+	loser := fstest.NewItem("file.conflict.txt", "older on path1", t2)
+
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	fstest.CheckItems(t, r.Flocal, winner, loser)
+	fstest.CheckItems(t, r.Fremote, winner, loser)
+
+	// Identical changes on both sides aren't a conflict
+	same := r.WriteFile("file.txt", "the same", t3)
+	r.WriteObject(ctx, "file.txt", "the same", t3)
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	fstest.CheckItems(t, r.Flocal, same, loser)
+	fstest.CheckItems(t, r.Fremote, same, loser)
+}
+
+func TestBisyncMaxDelete(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	opt, cleanup := newOpt(t)
+	defer cleanup()
+
+	file1 := r.WriteFile("one.txt", "one", t1)
+	file2 := r.WriteFile("two.txt", "two", t1)
+	file3 := r.WriteFile("three.txt", "three", t1)
+	opt.Resync = true
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	opt.Resync = false
+
+	remove(ctx, t, r.Flocal, "one.txt")
+	remove(ctx, t, r.Flocal, "two.txt")
+	err := Bisync(ctx, r.Flocal, r.Fremote, opt)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--max-delete-percent")
+	fstest.CheckItems(t, r.Fremote, file1, file2, file3)
+
+	opt.Force = true
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	fstest.CheckItems(t, r.Flocal, file3)
+	fstest.CheckItems(t, r.Fremote, file3)
+}
+
+func TestBisyncDryRun(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	opt, cleanup := newOpt(t)
+	defer cleanup()
+
+	file1 := r.WriteFile("one.txt", "one", t1)
+	opt.Resync = true
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	opt.Resync = false
+
+	file2 := r.WriteFile("two.txt", "two", t2)
+	fs.Config.DryRun = true
+	err := Bisync(ctx, r.Flocal, r.Fremote, opt)
+	fs.Config.DryRun = false
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	// The listings weren't updated so the change is still found
+	require.NoError(t, Bisync(ctx, r.Flocal, r.Fremote, opt))
+	fstest.CheckItems(t, r.Fremote, file1, file2)
+}
@@ -0,0 +1,87 @@
+// Package bisync implements the bisync command which syncs two
+// directories in both directions.
+package bisync
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/ncw/rclone/cmd"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/fs/config/flags"
+	"github.com/spf13/cobra"
+)
+
+var (
+	opt = DefaultOptions()
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefintion)
+	cmdFlags := commandDefintion.Flags()
+	flags.BoolVarP(cmdFlags, &opt.Resync, "resync", "", opt.Resync, "Copy each side to the other, Path1 winning, and make new listings")
+	flags.BoolVarP(cmdFlags, &opt.Force, "force", "", opt.Force, "Bypass --max-delete-percent safety check")
+	flags.IntVarP(cmdFlags, &opt.MaxDeletePercent, "max-delete-percent", "", opt.MaxDeletePercent, "Abort if more than this percentage of files were deleted on either side")
+	flags.StringVarP(cmdFlags, &opt.ConflictSuffix, "conflict-suffix", "", opt.ConflictSuffix, "Suffix to add to the older file of a conflict")
+	flags.StringVarP(cmdFlags, &opt.Workdir, "workdir", "", opt.Workdir, "Directory to keep the listings in (default: bisync in the cache dir)")
+}
+
+var commandDefintion = &cobra.Command{
+	Use:   "bisync remote1:path1 remote2:path2",
+	Short: `Bidirectional synchronization between two paths.`,
+	Long: `
+Bisync keeps two paths in sync by copying changes made on either side
+to the other side. Path1 and Path2 can be local paths or remotes.
+
+At the end of each successful run bisync saves a listing of the files
+on each side. The next run compares each side with its listing to find
+the files which are new, modified (changed size or modification time)
+or deleted on that side, and makes the same changes on the other side.
+
+- Files new or modified on one side are copied to the other side.
+- Files deleted on one side are deleted on the other side, unless
+  they have been modified there, in which case they are copied back.
+- Files new or modified on both sides which aren't the same are
+  conflicts. The newer file wins. The older one is renamed by adding
+  ` + "`--conflict-suffix`" + ` before the extension, eg ` + "`file.conflict.txt`" + `,
+  and both files are copied to both sides.
+
+The first run must use ` + "`--resync`" + ` to make the listings. This copies
+Path1 to Path2 and then Path2 to Path1, without deleting anything, so
+both sides end up with all the files. If a file differs between the
+sides the Path1 version wins. Use ` + "`--resync`" + ` again to recover if the
+listings are lost or a run fails in a way that can't be retried.
+
+If a run fails the listings aren't updated so the next run will try
+again.
+
+As a safety check, bisync aborts without making any changes if more
+than ` + "`--max-delete-percent`" + ` (default 50) of the files on either side
+have been deleted since the last run. This protects against, for
+example, a drive which failed to mount looking like all its files have
+been deleted. Use ` + "`--force`" + ` to sync anyway.
+
+Use ` + "`--dry-run`" + ` to see what would be copied, deleted and renamed
+without changing anything.
+
+Only files are synced - empty directories are ignored. The filtering
+flags, eg ` + "`--exclude`" + `, apply to both sides and should be the same
+for every run otherwise files may appear to be new or deleted.
+
+The listings are stored in ` + "`--workdir`" + `, which defaults to the ` + "`bisync`" + `
+directory in the rclone cache directory. Only one bisync of the same
+pair of paths may run at once.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		f1 := cmd.NewFsDir(args[0:1])
+		f2 := cmd.NewFsDir(args[1:2])
+		cmd.Run(false, true, command, func() error {
+			runOpt := opt
+			if runOpt.Workdir == "" {
+				runOpt.Workdir = filepath.Join(config.CacheDir, "bisync")
+			}
+			return Bisync(context.Background(), f1, f2, runOpt)
+		})
+	},
+}
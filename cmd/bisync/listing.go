@@ -0,0 +1,140 @@
+package bisync
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/walk"
+	"github.com/pkg/errors"
+)
+
+// listingVersion is the version of the listing file format
+const listingVersion = 1
+
+// errNoListing is returned by loadListing if there is no listing saved
+var errNoListing = errors.New("no prior listing found")
+
+// fileInfo is what is remembered about each file between runs
+type fileInfo struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modtime"`
+}
+
+// listing is the files on one side of the sync indexed by remote
+type listing map[string]fileInfo
+
+// listingFile is the format of the saved listing
+type listingFile struct {
+	Version int     `json:"version"`
+	Files   listing `json:"files"`
+}
+
+// makeListing lists all the files on f returning the listing and the
+// objects indexed by remote
+func makeListing(ctx context.Context, f fs.Fs) (listing, map[string]fs.Object, error) {
+	var mu sync.Mutex
+	files := listing{}
+	objs := make(map[string]fs.Object)
+	err := walk.ListR(ctx, f, "", false, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, entry := range entries {
+			o, ok := entry.(fs.Object)
+			if !ok {
+				continue
+			}
+			remote := o.Remote()
+			files[remote] = fileInfo{
+				Size:    o.Size(),
+				ModTime: o.ModTime(ctx),
+			}
+			objs[remote] = o
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to list %v", f)
+	}
+	return files, objs, nil
+}
+
+// loadListing loads the listing from file, returning errNoListing if
+// it doesn't exist
+func loadListing(file string) (listing, error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, errNoListing
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read listing")
+	}
+	var lf listingFile
+	err = json.Unmarshal(data, &lf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse listing %q", file)
+	}
+	if lf.Version != listingVersion {
+		return nil, errors.Errorf("listing %q has unknown version %d - run with --resync", file, lf.Version)
+	}
+	if lf.Files == nil {
+		lf.Files = listing{}
+	}
+	return lf.Files, nil
+}
+
+// save the listing to file atomically
+func (ls listing) save(file string) error {
+	data, err := json.Marshal(listingFile{
+		Version: listingVersion,
+		Files:   ls,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode listing")
+	}
+	tmpFile := file + ".tmp"
+	err = ioutil.WriteFile(tmpFile, data, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to write listing")
+	}
+	err = os.Rename(tmpFile, file)
+	if err != nil {
+		return errors.Wrap(err, "failed to save listing")
+	}
+	return nil
+}
+
+// remove deletes the listing file if it exists
+func removeListing(file string) error {
+	err := os.Remove(file)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// listingPaths returns the paths of the listing files and the lock
+// file for syncing f1 and f2 in workdir
+func listingPaths(workdir string, f1, f2 fs.Fs) (file1, file2, lockFile string) {
+	base := filepath.Join(workdir, safeName(f1)+".."+safeName(f2))
+	return base + ".path1.json", base + ".path2.json", base + ".lck"
+}
+
+// safeName makes a file name from the name and root of f
+func safeName(f fs.Fs) string {
+	name := f.Name() + ":" + f.Root()
+	out := make([]rune, 0, len(name))
+	for _, c := range name {
+		switch c {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+			c = '_'
+		}
+		out = append(out, c)
+	}
+	return string(out)
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/ncw/rclone/cmd"
 	"github.com/ncw/rclone/cmd/ls/lshelp"
+	"github.com/ncw/rclone/fs"
 	"github.com/ncw/rclone/fs/operations"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -53,6 +54,10 @@ The output is an array of Items, where each Item looks like this
       "Path" : "full/path/goes/here/file.txt",
       "Size" : 6,
       "Tier" : "hot",
+      "Metadata" : {
+         "mode" : "100644",
+         "uid" : "1000"
+      }
    }
 
 If --hash is not specified the Hashes property won't be emitted.
@@ -61,6 +66,9 @@ If --no-modtime is specified then ModTime will be blank.
 
 If --encrypted is not specified the Encrypted won't be emitted.
 
+If --metadata is not specified the Metadata won't be emitted. Objects
+on backends which don't support metadata won't have it either.
+
 If --dirs-only is not specified files in addition to directories are returned
 
 If --files-only is not specified directories in addition to the files will be returned.
@@ -88,6 +96,8 @@ can be processed line by line as each item is written one to a line.
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(1, 1, command, args)
 		fsrc := cmd.NewFsSrc(args)
+		// --metadata is a global flag
+		opt.Metadata = fs.Config.Metadata
 		cmd.Run(false, false, command, func() error {
 			fmt.Println("[")
 			first := true
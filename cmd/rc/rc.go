@@ -14,6 +14,7 @@ import (
 	"github.com/ncw/rclone/fs"
 	"github.com/ncw/rclone/fs/fshttp"
 	"github.com/ncw/rclone/fs/rc"
+	"github.com/ncw/rclone/fs/rc/jobs"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -117,7 +118,11 @@ func doCall(path string, in rc.Params) (out rc.Params, err error) {
 		if call == nil {
 			return nil, errors.Errorf("method %q not found", path)
 		}
-		return call.Fn(context.Background(), in)
+		ctx, err := jobs.WithConfig(context.Background(), in)
+		if err != nil {
+			return nil, err
+		}
+		return call.Fn(ctx, in)
 	}
 
 	// Do HTTP request
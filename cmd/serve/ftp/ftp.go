@@ -214,8 +214,8 @@ func (d *Driver) ListDir(path string, callback func(ftp.FileInfo) error) (err er
 	}
 
 	// Account the transfer
-	accounting.Stats.Transferring(path)
-	defer accounting.Stats.DoneTransferring(path, true)
+	accounting.GlobalStats().Transferring(path)
+	defer accounting.GlobalStats().DoneTransferring(path, true)
 
 	for _, file := range dirEntries {
 		err = callback(&FileInfo{file, file.Mode(), d.vfs.Opt.UID, d.vfs.Opt.GID})
@@ -311,8 +311,8 @@ func (d *Driver) GetFile(path string, offset int64) (size int64, fr io.ReadClose
 	}
 
 	// Account the transfer
-	accounting.Stats.Transferring(path)
-	defer accounting.Stats.DoneTransferring(path, true)
+	accounting.GlobalStats().Transferring(path)
+	defer accounting.GlobalStats().DoneTransferring(path, true)
 
 	return node.Size(), handle, nil
 }
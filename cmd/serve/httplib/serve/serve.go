@@ -75,8 +75,8 @@ func Object(w http.ResponseWriter, r *http.Request, o fs.Object) {
 		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 		return
 	}
-	accounting.Stats.Transferring(o.Remote())
-	in := accounting.NewAccount(file, o) // account the transfer (no buffering)
+	accounting.GlobalStats().Transferring(o.Remote())
+	in := accounting.GlobalStats().NewAccount(file, o) // account the transfer (no buffering)
 	defer func() {
 		closeErr := in.Close()
 		if closeErr != nil {
@@ -86,9 +86,9 @@ func Object(w http.ResponseWriter, r *http.Request, o fs.Object) {
 			}
 		}
 		ok := err == nil
-		accounting.Stats.DoneTransferring(o.Remote(), ok)
+		accounting.GlobalStats().DoneTransferring(o.Remote(), ok)
 		if !ok {
-			accounting.Stats.Error(err)
+			accounting.GlobalStats().Error(err)
 		}
 	}()
 
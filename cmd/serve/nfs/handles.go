@@ -0,0 +1,520 @@
+// File handles and the files held open for them
+
+package nfs
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/vfs"
+	"github.com/pkg/errors"
+)
+
+// Kinds of file handle, the first byte of each handle
+const (
+	handlePath = 1 // the path of the node follows
+	handleHash = 2 // the SHA-256 of the path of the node follows
+)
+
+// maxHandleSize is the longest file handle NFS version 3 allows
+const maxHandleSize = 64
+
+// handles maps the NFS file handles given to clients to VFS nodes
+//
+// A handle is made from the path of the node so it stays valid when
+// the VFS forgets the node and when the server is restarted.  Paths
+// which are too long to fit are replaced by their SHA-256 which is
+// looked up in longPaths.
+//
+// Handles of nodes renamed while the server is running are followed
+// to the new path so clients can keep using the files they have
+// open.
+type handles struct {
+	vfs     *vfs.VFS
+	files   *openFiles        // files held open by the server
+	root    *vfs.Dir          // root is always known
+	long    *longPaths        // paths of the handles made from hashes
+	mu      sync.Mutex        // protects the following variables
+	renamed map[string]string // new path by old path of renamed nodes
+	origins map[string]string // old path by new path of renamed nodes
+}
+
+// newHandles makes the handle table for v saving the long paths to
+// longPathsFile if it isn't empty
+func newHandles(v *vfs.VFS, files *openFiles, root *vfs.Dir, longPathsFile string) *handles {
+	return &handles{
+		vfs:     v,
+		files:   files,
+		root:    root,
+		long:    newLongPaths(longPathsFile),
+		renamed: make(map[string]string),
+		origins: make(map[string]string),
+	}
+}
+
+// hashPath returns the SHA-256 of nodePath
+func hashPath(nodePath string) []byte {
+	sum := sha256.Sum256([]byte(nodePath))
+	return sum[:]
+}
+
+// toHandle returns the file handle for node
+func (h *handles) toHandle(node vfs.Node) []byte {
+	nodePath := node.Path()
+	if 1+len(nodePath) <= maxHandleSize {
+		return append([]byte{handlePath}, nodePath...)
+	}
+	sum := hashPath(nodePath)
+	h.long.add(sum, nodePath)
+	return append([]byte{handleHash}, sum...)
+}
+
+// fromHandle returns the node for the file handle fh
+//
+// It returns nfs3ErrBadHandle if fh isn't a handle given out by the
+// server and nfs3ErrStale if the node it was for has gone.
+func (h *handles) fromHandle(fh []byte) (vfs.Node, uint32) {
+	if len(fh) == 0 || len(fh) > maxHandleSize {
+		return nil, nfs3ErrBadHandle
+	}
+	var nodePath string
+	switch fh[0] {
+	case handlePath:
+		nodePath = string(fh[1:])
+	case handleHash:
+		if len(fh) != 1+sha256.Size {
+			return nil, nfs3ErrBadHandle
+		}
+		var ok bool
+		nodePath, ok = h.long.get(fh[1:])
+		if !ok {
+			return nil, nfs3ErrStale
+		}
+	default:
+		return nil, nfs3ErrBadHandle
+	}
+	if nodePath == "" {
+		return h.root, nfs3OK
+	}
+	if node := h.find(nodePath); node != nil {
+		return node, nfs3OK
+	}
+	if newPath, ok := h.newPath(nodePath); ok {
+		if node := h.find(newPath); node != nil {
+			return node, nfs3OK
+		}
+	}
+	return nil, nfs3ErrStale
+}
+
+// find returns the node at nodePath or nil if there isn't one
+func (h *handles) find(nodePath string) vfs.Node {
+	node, err := h.vfs.Stat(nodePath)
+	if err == nil {
+		return node
+	}
+	// A file created without --vfs-cache-mode writes isn't in its
+	// directory until it has been written to
+	return h.files.find(nodePath)
+}
+
+// lookupMoved looks nodePath up in paths which maps old paths to new
+// ones, returning the new path of nodePath from the entry for it or
+// for its nearest parent
+func lookupMoved(paths map[string]string, nodePath string) (string, bool) {
+	for dir := nodePath; dir != "." && dir != "/" && dir != ""; dir = path.Dir(dir) {
+		if to, ok := paths[dir]; ok {
+			return to + nodePath[len(dir):], true
+		}
+	}
+	return "", false
+}
+
+// isAt returns true if nodePath is dirPath or below it
+func isAt(nodePath, dirPath string) bool {
+	return nodePath == dirPath || strings.HasPrefix(nodePath, dirPath+"/")
+}
+
+// movePath returns the path of nodePath after oldPath has been
+// renamed to newPath and whether it was moved
+func movePath(nodePath, oldPath, newPath string) (string, bool) {
+	if !isAt(nodePath, oldPath) {
+		return nodePath, false
+	}
+	return newPath + nodePath[len(oldPath):], true
+}
+
+// newPath returns the path a node renamed from nodePath has now
+func (h *handles) newPath(nodePath string) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return lookupMoved(h.renamed, nodePath)
+}
+
+// fileID returns the file id of node, which is made from its path
+// so it stays the same when the server is restarted
+//
+// A node renamed while the server is running keeps the file id of
+// its old path as the clients' handles for it are for that path.
+func (h *handles) fileID(node vfs.Node) uint64 {
+	nodePath := node.Path()
+	h.mu.Lock()
+	if oldPath, ok := lookupMoved(h.origins, nodePath); ok {
+		nodePath = oldPath
+	}
+	h.mu.Unlock()
+	return binary.BigEndian.Uint64(hashPath(nodePath))
+}
+
+// rename records that the node at oldPath has been renamed to newPath
+// so the handles for it and the nodes below it can be followed
+func (h *handles) rename(oldPath, newPath string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for from, to := range h.renamed {
+		if movedTo, ok := movePath(to, oldPath, newPath); ok {
+			h.renamed[from] = movedTo
+		}
+	}
+	h.renamed[oldPath] = newPath
+	// newPath is the renamed node now
+	delete(h.renamed, newPath)
+	moved := make(map[string]string)
+	for to, from := range h.origins {
+		if movedTo, ok := movePath(to, oldPath, newPath); ok {
+			moved[movedTo] = from
+			delete(h.origins, to)
+		}
+	}
+	for to, from := range moved {
+		h.origins[to] = from
+	}
+	if _, ok := moved[newPath]; !ok {
+		h.origins[newPath] = oldPath
+	}
+}
+
+// remove forgets the renames of the node at nodePath after it has
+// been removed
+func (h *handles) remove(nodePath string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for from, to := range h.renamed {
+		if isAt(to, nodePath) {
+			delete(h.renamed, from)
+		}
+	}
+	for to := range h.origins {
+		if isAt(to, nodePath) {
+			delete(h.origins, to)
+		}
+	}
+}
+
+// close saves and closes the long paths
+func (h *handles) close() {
+	h.long.close()
+}
+
+// longPaths remembers the paths of the handles made from hashes
+//
+// The paths are appended to a file, one quoted path per line, so the
+// handles still work after the server has been restarted.
+type longPaths struct {
+	mu    sync.Mutex
+	paths map[string]string // path by hash
+	file  *os.File          // nil if the paths aren't saved
+}
+
+// newLongPaths reads the paths saved in filePath if it isn't empty
+// and opens it to save new ones
+func newLongPaths(filePath string) *longPaths {
+	lp := &longPaths{
+		paths: make(map[string]string),
+	}
+	if filePath == "" {
+		return lp
+	}
+	err := lp.load(filePath)
+	if err != nil {
+		fs.Errorf(nil, "NFS handles of long paths won't work after a restart: %v", err)
+	}
+	return lp
+}
+
+// load reads the paths saved in filePath and leaves it open for
+// appending
+func (lp *longPaths) load(filePath string) error {
+	err := os.MkdirAll(filepath.Dir(filePath), 0700)
+	if err != nil {
+		return errors.Wrap(err, "failed to create handle directory")
+	}
+	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to open handle file")
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		nodePath, err := strconv.Unquote(scanner.Text())
+		if err != nil {
+			// skip lines cut short by a crash
+			continue
+		}
+		lp.paths[string(hashPath(nodePath))] = nodePath
+	}
+	err = scanner.Err()
+	if err != nil {
+		_ = file.Close()
+		return errors.Wrap(err, "failed to read handle file")
+	}
+	lp.file = file
+	return nil
+}
+
+// add remembers nodePath which hashes to sum
+func (lp *longPaths) add(sum []byte, nodePath string) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	if _, ok := lp.paths[string(sum)]; ok {
+		return
+	}
+	lp.paths[string(sum)] = nodePath
+	if lp.file == nil {
+		return
+	}
+	_, err := lp.file.WriteString(strconv.Quote(nodePath) + "\n")
+	if err != nil {
+		fs.Errorf(nil, "Failed to save NFS handle: %v", err)
+	}
+}
+
+// get returns the path which hashes to sum
+func (lp *longPaths) get(sum []byte) (string, bool) {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	nodePath, ok := lp.paths[string(sum)]
+	return nodePath, ok
+}
+
+// close closes the file the paths are saved in
+func (lp *longPaths) close() {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	if lp.file == nil {
+		return
+	}
+	err := lp.file.Close()
+	if err != nil {
+		fs.Errorf(nil, "Failed to close NFS handle file: %v", err)
+	}
+	lp.file = nil
+}
+
+// openFile is a file held open between NFS calls
+type openFile struct {
+	h      vfs.Handle
+	write  bool      // set if opened for write
+	used   time.Time // when last used
+	users  int       // number of calls using the handle
+	detach bool      // set to close the handle when the last user is done
+}
+
+// openFiles holds files open between NFS calls, as NFS has no open
+// or close, closing them once they haven't been used for a while
+// or when the client commits them.
+//
+// The calls on a connection run concurrently so each handle counts
+// its users and is only closed once none of them are using it.
+type openFiles struct {
+	vfs     *vfs.VFS
+	timeout time.Duration // close files unused for this long
+	mu      sync.Mutex    // protects the following variables
+	idle    *sync.Cond    // signalled when a file has no users
+	files   map[uint64]*openFile
+	closed  bool
+	done    chan struct{} // closed to stop the background closer
+}
+
+// newOpenFiles makes a new openFiles closing files unused for
+// timeout
+func newOpenFiles(v *vfs.VFS, timeout time.Duration) *openFiles {
+	of := &openFiles{
+		vfs:     v,
+		timeout: timeout,
+		files:   make(map[uint64]*openFile),
+		done:    make(chan struct{}),
+	}
+	of.idle = sync.NewCond(&of.mu)
+	go of.closer()
+	return of
+}
+
+// writeFlags returns the flags to open a file for writing with
+//
+// Without --vfs-cache-mode writes files can only be written
+// sequentially from the start after being created or truncated, so
+// O_TRUNC is never added here as reopening a file with it would lose
+// what had been written to it.
+func (of *openFiles) writeFlags() int {
+	if of.vfs.Opt.CacheMode >= vfs.CacheModeWrites {
+		return os.O_RDWR
+	}
+	return os.O_WRONLY
+}
+
+// get returns an open handle for file, opening it if necessary
+//
+// release must be called when the caller has finished with the
+// handle.
+func (of *openFiles) get(file *vfs.File, write bool, flags int) (h vfs.Handle, release func(), err error) {
+	of.mu.Lock()
+	defer of.mu.Unlock()
+	if of.closed {
+		return nil, nil, vfs.ECLOSED
+	}
+	inode := file.Inode()
+	if f, ok := of.files[inode]; ok {
+		if f.write || !write {
+			f.used = time.Now()
+			f.users++
+			return f.h, func() { of.release(f) }, nil
+		}
+		// reopen a read only file for write, leaving the read
+		// only handle to be closed by its last user
+		delete(of.files, inode)
+		if f.users > 0 {
+			f.detach = true
+		} else {
+			closeHandle(f.h)
+		}
+	}
+	if write {
+		flags |= of.writeFlags()
+	} else {
+		flags |= os.O_RDONLY
+	}
+	h, err = file.Open(flags)
+	if err != nil {
+		return nil, nil, err
+	}
+	f := &openFile{
+		h:     h,
+		write: write,
+		used:  time.Now(),
+		users: 1,
+	}
+	of.files[inode] = f
+	return h, func() { of.release(f) }, nil
+}
+
+// release marks a use of f from get as finished
+func (of *openFiles) release(f *openFile) {
+	of.mu.Lock()
+	f.users--
+	idle := f.users == 0
+	detach := idle && f.detach
+	of.mu.Unlock()
+	if !idle {
+		return
+	}
+	of.idle.Broadcast()
+	if detach {
+		closeHandle(f.h)
+	}
+}
+
+// closeHandle closes h logging any error
+func closeHandle(h vfs.Handle) {
+	err := h.Close()
+	if err != nil {
+		fs.Errorf(h.Node(), "Failed to close file: %v", err)
+	}
+}
+
+// find returns the node of the file open for write at nodePath or
+// nil if there isn't one
+func (of *openFiles) find(nodePath string) vfs.Node {
+	of.mu.Lock()
+	defer of.mu.Unlock()
+	for _, f := range of.files {
+		if node := f.h.Node(); f.write && node.Path() == nodePath {
+			return node
+		}
+	}
+	return nil
+}
+
+// close closes the handle for file if it is open, which uploads it if
+// it was written to
+//
+// It waits for any calls using the handle to finish first.
+func (of *openFiles) close(file *vfs.File) error {
+	of.mu.Lock()
+	f, ok := of.files[file.Inode()]
+	if ok {
+		delete(of.files, file.Inode())
+		for f.users > 0 {
+			of.idle.Wait()
+		}
+	}
+	of.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	err := f.h.Close()
+	if err != nil {
+		return errors.Wrap(err, "failed to close file")
+	}
+	return nil
+}
+
+// closer closes the files which haven't been used for the timeout
+func (of *openFiles) closer() {
+	ticker := time.NewTicker(of.timeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-of.done:
+			return
+		case <-ticker.C:
+		}
+		var idle []*openFile
+		of.mu.Lock()
+		for inode, f := range of.files {
+			if f.users == 0 && time.Since(f.used) >= of.timeout {
+				idle = append(idle, f)
+				delete(of.files, inode)
+			}
+		}
+		of.mu.Unlock()
+		for _, f := range idle {
+			closeHandle(f.h)
+		}
+	}
+}
+
+// closeAll closes all the open files and stops the background closer
+func (of *openFiles) closeAll() {
+	of.mu.Lock()
+	if of.closed {
+		of.mu.Unlock()
+		return
+	}
+	of.closed = true
+	files := of.files
+	of.files = nil
+	close(of.done)
+	of.mu.Unlock()
+	for _, f := range files {
+		closeHandle(f.h)
+	}
+}
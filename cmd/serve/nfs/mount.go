@@ -0,0 +1,94 @@
+// The MOUNT protocol version 3 as described in RFC 1813 appendix I
+
+package nfs
+
+import (
+	"path"
+
+	"github.com/ncw/rclone/fs"
+)
+
+// mountstat3
+const (
+	mnt3OK        = 0
+	mnt3ErrNoEnt  = 2
+	mnt3ErrNotDir = 20
+)
+
+// maxMountPath is the longest path which can be mounted
+const maxMountPath = 1024
+
+// mountProcedures are the procedures of the MOUNT program by number
+var mountProcedures = []procedure{
+	0: mountNull,
+	1: mountMnt,
+	2: mountDump,
+	3: mountUmnt,
+	4: mountUmntAll,
+	5: mountExport,
+}
+
+// mountNull does nothing
+func mountNull(s *server, args *xdrReader, res *xdrWriter) error {
+	return nil
+}
+
+// mountMnt returns the file handle of the directory to mount
+//
+// Any directory of the remote may be mounted
+func mountMnt(s *server, args *xdrReader, res *xdrWriter) error {
+	dirPath := args.string(maxMountPath)
+	if args.err != nil {
+		return args.err
+	}
+	vfsPath := path.Clean("/" + dirPath)[1:]
+	node, err := s.vfs.Stat(vfsPath)
+	if err != nil {
+		fs.Debugf(nil, "NFS mount of %q failed: %v", dirPath, err)
+		res.uint32(mnt3ErrNoEnt)
+		return nil
+	}
+	if !node.IsDir() {
+		res.uint32(mnt3ErrNotDir)
+		return nil
+	}
+	fs.Infof(nil, "NFS client mounted %q", dirPath)
+	res.uint32(mnt3OK)
+	res.opaque(s.handles.toHandle(node))
+	// Accepted auth flavors
+	res.uint32(2)
+	res.uint32(authUnix)
+	res.uint32(authNone)
+	return nil
+}
+
+// mountDump returns the list of mounts which isn't kept
+func mountDump(s *server, args *xdrReader, res *xdrWriter) error {
+	res.bool(false)
+	return nil
+}
+
+// mountUmnt unmounts a directory which doesn't need to do anything
+func mountUmnt(s *server, args *xdrReader, res *xdrWriter) error {
+	dirPath := args.string(maxMountPath)
+	if args.err != nil {
+		return args.err
+	}
+	fs.Infof(nil, "NFS client unmounted %q", dirPath)
+	return nil
+}
+
+// mountUmntAll unmounts everything which doesn't need to do anything
+func mountUmntAll(s *server, args *xdrReader, res *xdrWriter) error {
+	return nil
+}
+
+// mountExport returns the list of exports which is the root to
+// everyone
+func mountExport(s *server, args *xdrReader, res *xdrWriter) error {
+	res.bool(true)
+	res.string("/")
+	res.bool(false) // no groups
+	res.bool(false) // no more exports
+	return nil
+}
@@ -0,0 +1,110 @@
+// Package nfs implements an NFS server to serve an rclone VFS
+package nfs
+
+import (
+	"github.com/ncw/rclone/cmd"
+	"github.com/ncw/rclone/fs/config/flags"
+	"github.com/ncw/rclone/fs/rc"
+	"github.com/ncw/rclone/lib/atexit"
+	"github.com/ncw/rclone/vfs"
+	"github.com/ncw/rclone/vfs/vfsflags"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Options contains options for the NFS server
+type Options struct {
+	ListenAddr string // Port to listen on
+}
+
+// DefaultOpt is the default values used for Options
+var DefaultOpt = Options{
+	ListenAddr: "localhost:2049",
+}
+
+// Opt is options set by command line flags
+var Opt = DefaultOpt
+
+// AddFlags adds flags for the NFS server
+func AddFlags(flagSet *pflag.FlagSet, Opt *Options) {
+	rc.AddOption("nfs", &Opt)
+	flags.StringVarP(flagSet, &Opt.ListenAddr, "addr", "", Opt.ListenAddr, "IPaddress:Port or :Port to bind server to.")
+}
+
+func init() {
+	vfsflags.AddFlags(Command.Flags())
+	AddFlags(Command.Flags(), &Opt)
+}
+
+// Command definition for cobra
+var Command = &cobra.Command{
+	Use:   "nfs remote:path",
+	Short: `Serve remote:path over NFS.`,
+	Long: `rclone serve nfs implements an NFS version 3 server which serves
+the remote so it can be mounted by the NFS client built into most
+operating systems.  This is useful where FUSE isn't available for
+"rclone mount".
+
+The server includes the MOUNT protocol on the same port, so no
+portmapper is needed, but the client must be told the ports to use.
+On Linux the remote can be mounted with
+
+    mount -t nfs -o port=2049,mountport=2049,nfsvers=3,tcp,nolock localhost:/ /mnt
+
+and on macOS with
+
+    mount -t nfs -o port=2049,mountport=2049,nfsvers=3,tcp,nolocks localhost:/ /mnt
+
+Any directory of the remote may be mounted by giving its path instead
+of "/".  Locking isn't supported so the "nolock" option must be used.
+
+### Server options
+
+Use --addr to specify which IP address and port the server should
+listen on, eg --addr 1.2.3.4:2049 or --addr :2049 to listen to all
+IPs.  By default it only listens on localhost.
+
+The server doesn't authenticate clients so anyone who can connect to
+it can read and write the remote.  Only listen on trusted networks.
+
+Clients refer to files with file handles made from their paths, so
+the handles keep working when the server is restarted.  Paths too
+long to fit in a handle are replaced by a hash and saved in the
+--cache-dir so they can be looked up again after a restart.  A handle
+goes stale when its file is removed or renamed outside the server.
+
+### Writing files
+
+NFS clients write files in blocks which may arrive in any order, so
+to write files you will need --vfs-cache-mode writes or full.  Without
+the cache files can only be written sequentially from the start after
+being created or truncated to 0 length, and once a file has been
+uploaded any further writes to it fail rather than overwrite it.
+
+Files are held open between NFS calls.  Writes are uploaded when the
+client commits them (eg when the file is closed) or when the file
+hasn't been used for a few seconds.  Without the cache, writes the
+client asks to be stable are uploaded straight away.
+
+Symlinks can be created and read if --vfs-links is set.  Hard links,
+special files and locking aren't supported.
+
+` + vfs.Help,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsSrc(args)
+		cmd.Run(false, false, command, func() error {
+			s, err := newServer(f, &Opt)
+			if err != nil {
+				return err
+			}
+			err = s.Serve()
+			if err != nil {
+				return err
+			}
+			atexit.Register(s.Close)
+			s.Wait()
+			return nil
+		})
+	},
+}
@@ -0,0 +1,1103 @@
+// The NFS version 3 protocol as described in RFC 1813
+
+package nfs
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/vfs"
+	"github.com/pkg/errors"
+)
+
+// nfsstat3
+const (
+	nfs3OK             = 0
+	nfs3ErrPerm        = 1
+	nfs3ErrNoEnt       = 2
+	nfs3ErrIO          = 5
+	nfs3ErrExist       = 17
+	nfs3ErrNotDir      = 20
+	nfs3ErrIsDir       = 21
+	nfs3ErrInval       = 22
+	nfs3ErrRoFs        = 30
+	nfs3ErrNameTooLong = 63
+	nfs3ErrNotEmpty    = 66
+	nfs3ErrStale       = 70
+	nfs3ErrBadHandle   = 10001
+	nfs3ErrNotSync     = 10002
+	nfs3ErrNotSupp     = 10004
+	nfs3ErrTooSmall    = 10005
+)
+
+// ftype3
+const (
+	nf3Reg = 1
+	nf3Dir = 2
+	nf3Lnk = 5
+)
+
+// time_how
+const (
+	dontChange      = 0
+	setToServerTime = 1
+	setToClientTime = 2
+)
+
+// createmode3
+const (
+	createUnchecked = 0
+	createGuarded   = 1
+	createExclusive = 2
+)
+
+// stable_how
+const (
+	writeUnstable = 0
+	writeFileSync = 2
+)
+
+// ACCESS bits which modify
+const (
+	access3Modify = 0x04
+	access3Extend = 0x08
+	access3Delete = 0x10
+)
+
+// FSINFO properties
+const (
+	fsf3Symlink     = 0x02
+	fsf3Homogeneous = 0x08
+	fsf3CanSetTime  = 0x10
+)
+
+// Sizes and limits
+const (
+	createVerfSize   = 8
+	cookieVerfSize   = 8
+	maxNameLen       = 255
+	maxNameArg       = 1024
+	maxPathLen       = 4096
+	maxIOSize        = 1024 * 1024
+	dirPreferredSize = 64 * 1024
+	unknownSize      = 1 << 50
+	unknownFiles     = 1 << 32
+
+	// sizes of the encoded READDIR results for checking the
+	// client's limits
+	fattr3Size        = 84
+	readdirFixedSize  = 4 + 4 + fattr3Size + cookieVerfSize + 4 + 4
+	readdirEntrySize  = 4 + 8 + 4 + 8
+	readdirPlusExtra  = 4 + fattr3Size + 4 + 4 // and the padded handle
+	readdirPlusDirent = 8 + 4 + 8
+)
+
+// nfsProcedures are the procedures of the NFS program by number
+var nfsProcedures = []procedure{
+	0:  nfsNull,
+	1:  nfsGetattr,
+	2:  nfsSetattr,
+	3:  nfsLookup,
+	4:  nfsAccess,
+	5:  nfsReadlink,
+	6:  nfsRead,
+	7:  nfsWrite,
+	8:  nfsCreate,
+	9:  nfsMkdir,
+	10: nfsSymlink,
+	11: nfsMknod,
+	12: nfsRemove,
+	13: nfsRmdir,
+	14: nfsRename,
+	15: nfsLink,
+	16: nfsReaddir,
+	17: nfsReaddirplus,
+	18: nfsFsstat,
+	19: nfsFsinfo,
+	20: nfsPathconf,
+	21: nfsCommit,
+}
+
+// nfsStatus converts an error from the VFS into an nfsstat3
+func nfsStatus(err error) uint32 {
+	switch errors.Cause(err) {
+	case nil, vfs.OK:
+		return nfs3OK
+	case vfs.ENOENT, fs.ErrorObjectNotFound, fs.ErrorDirNotFound:
+		return nfs3ErrNoEnt
+	case vfs.EEXIST:
+		return nfs3ErrExist
+	case vfs.EPERM:
+		return nfs3ErrPerm
+	case vfs.ENOTEMPTY:
+		return nfs3ErrNotEmpty
+	case vfs.EROFS:
+		return nfs3ErrRoFs
+	case vfs.ENOSYS:
+		return nfs3ErrNotSupp
+	case vfs.EINVAL:
+		return nfs3ErrInval
+	}
+	fs.Errorf(nil, "NFS IO error: %v", err)
+	return nfs3ErrIO
+}
+
+// checkName returns the status for a name to be created in a
+// directory
+func checkName(name string) uint32 {
+	switch {
+	case name == "" || name == "." || name == ".." || strings.Contains(name, "/"):
+		return nfs3ErrInval
+	case len(name) > maxNameLen:
+		return nfs3ErrNameTooLong
+	}
+	return nfs3OK
+}
+
+// unixMode returns the permission bits of mode as in st_mode
+func unixMode(mode os.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		m |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= 01000
+	}
+	return m
+}
+
+// fileMode returns the os.FileMode for the permission bits of
+// st_mode
+func fileMode(m uint32) os.FileMode {
+	mode := os.FileMode(m & 0777)
+	if m&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if m&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if m&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+// writeTime writes t as an nfstime3
+func writeTime(res *xdrWriter, t time.Time) {
+	if t.Unix() < 0 {
+		res.uint64(0)
+		return
+	}
+	res.uint32(uint32(t.Unix()))
+	res.uint32(uint32(t.Nanosecond()))
+}
+
+// readTime reads an nfstime3
+func readTime(args *xdrReader) time.Time {
+	seconds := args.uint32()
+	nanoseconds := args.uint32()
+	return time.Unix(int64(seconds), int64(nanoseconds))
+}
+
+// writeFattr writes the fattr3 of node
+func (s *server) writeFattr(res *xdrWriter, node vfs.Node) {
+	ftype, nlink := uint32(nf3Reg), uint32(1)
+	if node.IsDir() {
+		ftype, nlink = nf3Dir, 2
+	} else if node.IsSymlink() {
+		ftype = nf3Lnk
+	}
+	size := node.Size()
+	if size < 0 {
+		size = 0
+	}
+	uid, gid := node.Owner()
+	modTime := node.ModTime()
+	res.uint32(ftype)
+	res.uint32(unixMode(node.Mode()))
+	res.uint32(nlink)
+	res.uint32(uid)
+	res.uint32(gid)
+	res.uint64(uint64(size))
+	res.uint64(uint64(size+511) &^ 511) // used
+	res.uint64(0)                       // rdev
+	res.uint64(1)                       // fsid
+	res.uint64(s.handles.fileID(node))  // fileid
+	writeTime(res, modTime)             // atime
+	writeTime(res, modTime)             // mtime
+	writeTime(res, modTime)             // ctime
+}
+
+// writePostOpAttr writes the attributes of node if it isn't nil
+func (s *server) writePostOpAttr(res *xdrWriter, node vfs.Node) {
+	if node == nil {
+		res.bool(false)
+		return
+	}
+	res.bool(true)
+	s.writeFattr(res, node)
+}
+
+// writeWcc writes the wcc_data of node which only includes the
+// attributes after the operation
+func (s *server) writeWcc(res *xdrWriter, node vfs.Node) {
+	res.bool(false)
+	s.writePostOpAttr(res, node)
+}
+
+// sattr3 is the attributes to set on a node
+type sattr3 struct {
+	setMode bool
+	mode    uint32
+	setUID  bool
+	uid     uint32
+	setGID  bool
+	gid     uint32
+	setSize bool
+	size    uint64
+	setTime bool
+	mtime   time.Time
+}
+
+// readSattr reads a sattr3
+func readSattr(args *xdrReader) (a sattr3) {
+	if a.setMode = args.bool(); a.setMode {
+		a.mode = args.uint32()
+	}
+	if a.setUID = args.bool(); a.setUID {
+		a.uid = args.uint32()
+	}
+	if a.setGID = args.bool(); a.setGID {
+		a.gid = args.uint32()
+	}
+	if a.setSize = args.bool(); a.setSize {
+		a.size = args.uint64()
+	}
+	// The access time isn't stored
+	if args.uint32() == setToClientTime {
+		_ = readTime(args)
+	}
+	switch args.uint32() {
+	case setToServerTime:
+		a.setTime, a.mtime = true, time.Now()
+	case setToClientTime:
+		a.setTime, a.mtime = true, readTime(args)
+	}
+	return a
+}
+
+// apply sets the attributes on node
+//
+// If the node has just been created errors setting the mode and
+// owner are ignored as the remote may not be able to store them yet.
+func (a *sattr3) apply(s *server, node vfs.Node, created bool) error {
+	if a.setSize {
+		if node.IsDir() {
+			return vfs.EINVAL
+		}
+		err := s.truncate(node, int64(a.size))
+		if err != nil {
+			return err
+		}
+	}
+	if a.setMode {
+		err := node.Chmod(fileMode(a.mode))
+		if err != nil && !created {
+			return err
+		}
+	}
+	if a.setUID || a.setGID {
+		uid, gid := -1, -1
+		if a.setUID {
+			uid = int(a.uid)
+		}
+		if a.setGID {
+			gid = int(a.gid)
+		}
+		err := node.Chown(uid, gid)
+		if err != nil && !created {
+			return err
+		}
+	}
+	if a.setTime && !node.VFS().Opt.NoModTime {
+		err := node.SetModTime(a.mtime)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncate sets the size of node
+//
+// Without --vfs-cache-mode writes a file can only be written after it
+// has been truncated to 0, so rather than uploading an empty file it
+// is opened with O_TRUNC and held open for the writes which follow.
+func (s *server) truncate(node vfs.Node, size int64) error {
+	file, ok := node.(*vfs.File)
+	if !ok || size != 0 || s.vfs.Opt.CacheMode >= vfs.CacheModeWrites {
+		return node.Truncate(size)
+	}
+	h, release, err := s.files.get(file, true, os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return h.Truncate(0)
+}
+
+// lookupDir returns the directory for the file handle fh
+func (s *server) lookupDir(fh []byte) (*vfs.Dir, uint32) {
+	node, status := s.handles.fromHandle(fh)
+	if status != nfs3OK {
+		return nil, status
+	}
+	dir, ok := node.(*vfs.Dir)
+	if !ok {
+		return nil, nfs3ErrNotDir
+	}
+	return dir, nfs3OK
+}
+
+// lookupFile returns the file for the file handle fh
+func (s *server) lookupFile(fh []byte) (*vfs.File, uint32) {
+	node, status := s.handles.fromHandle(fh)
+	if status != nfs3OK {
+		return nil, status
+	}
+	file, ok := node.(*vfs.File)
+	if !ok {
+		return nil, nfs3ErrIsDir
+	}
+	return file, nfs3OK
+}
+
+// parentOf returns the parent of dir, or dir if it is the root
+func (s *server) parentOf(dir *vfs.Dir) vfs.Node {
+	if dir.Path() == "" {
+		return dir
+	}
+	parent, err := s.vfs.Stat(path.Dir(dir.Path()))
+	if err != nil {
+		return dir
+	}
+	return parent
+}
+
+// nfsNull does nothing
+func nfsNull(s *server, args *xdrReader, res *xdrWriter) error {
+	return nil
+}
+
+// nfsGetattr returns the attributes of a node
+func nfsGetattr(s *server, args *xdrReader, res *xdrWriter) error {
+	fh := args.opaque(maxHandleSize)
+	if args.err != nil {
+		return args.err
+	}
+	node, status := s.handles.fromHandle(fh)
+	res.uint32(status)
+	if status == nfs3OK {
+		s.writeFattr(res, node)
+	}
+	return nil
+}
+
+// nfsSetattr sets the attributes of a node
+func nfsSetattr(s *server, args *xdrReader, res *xdrWriter) error {
+	fh := args.opaque(maxHandleSize)
+	attr := readSattr(args)
+	var guard time.Time
+	check := args.bool()
+	if check {
+		guard = readTime(args)
+	}
+	if args.err != nil {
+		return args.err
+	}
+	node, status := s.handles.fromHandle(fh)
+	if status == nfs3OK && s.vfs.Opt.ReadOnly {
+		status = nfs3ErrRoFs
+	}
+	if status == nfs3OK && check {
+		modTime := node.ModTime()
+		if modTime.Unix() != guard.Unix() || modTime.Nanosecond() != guard.Nanosecond() {
+			status = nfs3ErrNotSync
+		}
+	}
+	if status == nfs3OK {
+		status = nfsStatus(attr.apply(s, node, false))
+	}
+	res.uint32(status)
+	s.writeWcc(res, node)
+	return nil
+}
+
+// nfsLookup looks up a name in a directory
+func nfsLookup(s *server, args *xdrReader, res *xdrWriter) error {
+	fh := args.opaque(maxHandleSize)
+	name := args.string(maxNameArg)
+	if args.err != nil {
+		return args.err
+	}
+	dir, status := s.lookupDir(fh)
+	var node vfs.Node
+	if status == nfs3OK {
+		switch name {
+		case ".":
+			node = dir
+		case "..":
+			node = s.parentOf(dir)
+		default:
+			var err error
+			node, err = dir.Stat(name)
+			status = nfsStatus(err)
+		}
+	}
+	res.uint32(status)
+	if status != nfs3OK {
+		if dir != nil {
+			s.writePostOpAttr(res, dir)
+		} else {
+			s.writePostOpAttr(res, nil)
+		}
+		return nil
+	}
+	res.opaque(s.handles.toHandle(node))
+	s.writePostOpAttr(res, node)
+	s.writePostOpAttr(res, dir)
+	return nil
+}
+
+// nfsAccess checks the access the client has to a node
+//
+// Permissions aren't checked so this allows everything except
+// modification on a read only server.
+func nfsAccess(s *server, args *xdrReader, res *xdrWriter) error {
+	fh := args.opaque(maxHandleSize)
+	access := args.uint32()
+	if args.err != nil {
+		return args.err
+	}
+	node, status := s.handles.fromHandle(fh)
+	res.uint32(status)
+	s.writePostOpAttr(res, node)
+	if status == nfs3OK {
+		if s.vfs.Opt.ReadOnly {
+			access &^= access3Modify | access3Extend | access3Delete
+		}
+		res.uint32(access)
+	}
+	return nil
+}
+
+// nfsReadlink reads the target of a symlink
+func nfsReadlink(s *server, args *xdrReader, res *xdrWriter) error {
+	fh := args.opaque(maxHandleSize)
+	if args.err != nil {
+		return args.err
+	}
+	file, status := s.lookupFile(fh)
+	var target string
+	if status == nfs3OK {
+		var err error
+		target, err = file.Readlink()
+		status = nfsStatus(err)
+	}
+	res.uint32(status)
+	if file != nil {
+		s.writePostOpAttr(res, file)
+	} else {
+		s.writePostOpAttr(res, nil)
+	}
+	if status == nfs3OK {
+		res.string(target)
+	}
+	return nil
+}
+
+// nfsRead reads data from a file
+func nfsRead(s *server, args *xdrReader, res *xdrWriter) error {
+	fh := args.opaque(maxHandleSize)
+	offset := args.uint64()
+	count := args.uint32()
+	if args.err != nil {
+		return args.err
+	}
+	if count > maxIOSize {
+		count = maxIOSize
+	}
+	file, status := s.lookupFile(fh)
+	var (
+		data []byte
+		eof  bool
+	)
+	if status == nfs3OK {
+		h, release, err := s.files.get(file, false, 0)
+		if err == nil {
+			data = make([]byte, count)
+			var n int
+			n, err = h.ReadAt(data, int64(offset))
+			release()
+			data = data[:n]
+			if err == io.EOF {
+				err = nil
+				eof = true
+			}
+		}
+		if int64(offset)+int64(len(data)) >= file.Size() {
+			eof = true
+		}
+		status = nfsStatus(err)
+	}
+	res.uint32(status)
+	if file != nil {
+		s.writePostOpAttr(res, file)
+	} else {
+		s.writePostOpAttr(res, nil)
+	}
+	if status == nfs3OK {
+		res.uint32(uint32(len(data)))
+		res.bool(eof)
+		res.opaque(data)
+	}
+	return nil
+}
+
+// nfsWrite writes data to a file
+//
+// Unstable writes are uploaded when the client commits them or the
+// file hasn't been used for a while.  With --vfs-cache-mode writes
+// the cache is stable storage so stable writes are treated the same,
+// otherwise they are uploaded straight away.
+func nfsWrite(s *server, args *xdrReader, res *xdrWriter) error {
+	fh := args.opaque(maxHandleSize)
+	offset := args.uint64()
+	_ = args.uint32() // count which is the same as len(data)
+	stable := args.uint32()
+	data := args.opaque(maxIOSize)
+	if args.err != nil {
+		return args.err
+	}
+	file, status := s.lookupFile(fh)
+	if status == nfs3OK && s.vfs.Opt.ReadOnly {
+		status = nfs3ErrRoFs
+	}
+	var n int
+	if status == nfs3OK {
+		h, release, err := s.files.get(file, true, 0)
+		if err == nil {
+			n, err = h.WriteAt(data, int64(offset))
+			release()
+		}
+		if err == nil && stable != writeUnstable && s.vfs.Opt.CacheMode < vfs.CacheModeWrites {
+			err = s.files.close(file)
+		}
+		status = nfsStatus(err)
+	}
+	res.uint32(status)
+	if file != nil {
+		s.writeWcc(res, file)
+	} else {
+		s.writeWcc(res, nil)
+	}
+	if status == nfs3OK {
+		res.uint32(uint32(n))
+		if stable != writeUnstable {
+			res.uint32(writeFileSync)
+		} else {
+			res.uint32(writeUnstable)
+		}
+		res.fixed(s.verf[:])
+	}
+	return nil
+}
+
+// writeNewNode writes the result of creating node in dir
+func (s *server) writeNewNode(res *xdrWriter, status uint32, dir *vfs.Dir, node vfs.Node) {
+	res.uint32(status)
+	if status == nfs3OK {
+		res.bool(true)
+		res.opaque(s.handles.toHandle(node))
+		s.writePostOpAttr(res, node)
+	}
+	if dir != nil {
+		s.writeWcc(res, dir)
+	} else {
+		s.writeWcc(res, nil)
+	}
+}
+
+// checkCreate looks up the directory for fh and checks name can be
+// created in it, returning the node if it exists already
+func (s *server) checkCreate(fh []byte, name string) (dir *vfs.Dir, node vfs.Node, status uint32) {
+	dir, status = s.lookupDir(fh)
+	if status != nfs3OK {
+		return nil, nil, status
+	}
+	if s.vfs.Opt.ReadOnly {
+		return dir, nil, nfs3ErrRoFs
+	}
+	status = checkName(name)
+	if status != nfs3OK {
+		return dir, nil, status
+	}
+	node, err := dir.Stat(name)
+	if err == vfs.ENOENT {
+		return dir, nil, nfs3OK
+	}
+	return dir, node, nfsStatus(err)
+}
+
+// nfsCreate creates a file
+func nfsCreate(s *server, args *xdrReader, res *xdrWriter) error {
+	fh := args.opaque(maxHandleSize)
+	name := args.string(maxNameArg)
+	how := args.uint32()
+	var (
+		attr sattr3
+		verf []byte
+	)
+	switch how {
+	case createUnchecked, createGuarded:
+		attr = readSattr(args)
+	case createExclusive:
+		verf = args.fixed(createVerfSize)
+	default:
+		return errGarbageArgs
+	}
+	if args.err != nil {
+		return args.err
+	}
+	dir, node, status := s.checkCreate(fh, name)
+	if status != nfs3OK {
+		s.writeNewNode(res, status, dir, nil)
+		return nil
+	}
+	filePath := path.Join(dir.Path(), name)
+	created := node == nil
+	if node != nil {
+		switch {
+		case node.IsDir():
+			status = nfs3ErrExist
+		case how == createGuarded:
+			status = nfs3ErrExist
+		case how == createExclusive:
+			// A retry of an exclusive create which worked
+			s.mu.Lock()
+			if !bytes.Equal(s.creates[filePath], verf) {
+				status = nfs3ErrExist
+			}
+			s.mu.Unlock()
+		}
+	} else {
+		file, err := dir.Create(name, os.O_RDWR|os.O_CREATE)
+		if err == nil {
+			var release func()
+			_, release, err = s.files.get(file, true, os.O_CREATE|os.O_TRUNC)
+			if err == nil {
+				release()
+			}
+		}
+		status = nfsStatus(err)
+		node = file
+		if status == nfs3OK && how == createExclusive {
+			s.mu.Lock()
+			s.creates[filePath] = verf
+			s.mu.Unlock()
+		}
+	}
+	if status == nfs3OK {
+		status = nfsStatus(attr.apply(s, node, created))
+	}
+	s.writeNewNode(res, status, dir, node)
+	return nil
+}
+
+// nfsMkdir creates a directory
+func nfsMkdir(s *server, args *xdrReader, res *xdrWriter) error {
+	fh := args.opaque(maxHandleSize)
+	name := args.string(maxNameArg)
+	attr := readSattr(args)
+	if args.err != nil {
+		return args.err
+	}
+	dir, node, status := s.checkCreate(fh, name)
+	if status == nfs3OK && node != nil {
+		status = nfs3ErrExist
+	}
+	if status == nfs3OK {
+		var err error
+		node, err = dir.Mkdir(name)
+		if err == nil {
+			err = attr.apply(s, node, true)
+		}
+		status = nfsStatus(err)
+	}
+	s.writeNewNode(res, status, dir, node)
+	return nil
+}
+
+// nfsSymlink creates a symlink
+func nfsSymlink(s *server, args *xdrReader, res *xdrWriter) error {
+	fh := args.opaque(maxHandleSize)
+	name := args.string(maxNameArg)
+	_ = readSattr(args)
+	target := args.string(maxPathLen)
+	if args.err != nil {
+		return args.err
+	}
+	dir, node, status := s.checkCreate(fh, name)
+	if status == nfs3OK && node != nil {
+		status = nfs3ErrExist
+	}
+	if status == nfs3OK {
+		var err error
+		node, err = dir.Symlink(target, name)
+		status = nfsStatus(err)
+	}
+	s.writeNewNode(res, status, dir, node)
+	return nil
+}
+
+// nfsMknod can't make special files
+func nfsMknod(s *server, args *xdrReader, res *xdrWriter) error {
+	res.uint32(nfs3ErrNotSupp)
+	s.writeWcc(res, nil)
+	return nil
+}
+
+// nfsRemove removes a file
+func nfsRemove(s *server, args *xdrReader, res *xdrWriter) error {
+	return s.remove(args, res, false)
+}
+
+// nfsRmdir removes a directory
+func nfsRmdir(s *server, args *xdrReader, res *xdrWriter) error {
+	return s.remove(args, res, true)
+}
+
+// remove removes a file, or a directory if isDir is set
+func (s *server) remove(args *xdrReader, res *xdrWriter, isDir bool) error {
+	fh := args.opaque(maxHandleSize)
+	name := args.string(maxNameArg)
+	if args.err != nil {
+		return args.err
+	}
+	dir, status := s.lookupDir(fh)
+	if status == nfs3OK && s.vfs.Opt.ReadOnly {
+		status = nfs3ErrRoFs
+	}
+	if status == nfs3OK {
+		node, err := dir.Stat(name)
+		switch {
+		case err != nil:
+			status = nfsStatus(err)
+		case isDir && !node.IsDir():
+			status = nfs3ErrNotDir
+		case !isDir && node.IsDir():
+			status = nfs3ErrIsDir
+		default:
+			if file, ok := node.(*vfs.File); ok {
+				err = s.files.close(file)
+				if err != nil {
+					fs.Errorf(file, "NFS remove: %v", err)
+				}
+			}
+			err = node.Remove()
+			if err == nil {
+				s.handles.remove(path.Join(dir.Path(), name))
+			}
+			status = nfsStatus(err)
+		}
+	}
+	res.uint32(status)
+	if dir != nil {
+		s.writeWcc(res, dir)
+	} else {
+		s.writeWcc(res, nil)
+	}
+	return nil
+}
+
+// nfsRename renames a file or directory
+func nfsRename(s *server, args *xdrReader, res *xdrWriter) error {
+	fromFH := args.opaque(maxHandleSize)
+	fromName := args.string(maxNameArg)
+	toFH := args.opaque(maxHandleSize)
+	toName := args.string(maxNameArg)
+	if args.err != nil {
+		return args.err
+	}
+	fromDir, status := s.lookupDir(fromFH)
+	toDir, toStatus := s.lookupDir(toFH)
+	if status == nfs3OK {
+		status = toStatus
+	}
+	if status == nfs3OK && s.vfs.Opt.ReadOnly {
+		status = nfs3ErrRoFs
+	}
+	if status == nfs3OK {
+		status = checkName(toName)
+	}
+	if status == nfs3OK {
+		// Close the file being replaced so it isn't uploaded
+		// over the renamed one later
+		if node, err := toDir.Stat(toName); err == nil {
+			if file, ok := node.(*vfs.File); ok {
+				_ = s.files.close(file)
+			}
+		}
+		status = nfsStatus(fromDir.Rename(fromName, toName, toDir))
+		if status == nfs3OK {
+			s.handles.rename(path.Join(fromDir.Path(), fromName), path.Join(toDir.Path(), toName))
+		}
+	}
+	res.uint32(status)
+	for _, dir := range []*vfs.Dir{fromDir, toDir} {
+		if dir != nil {
+			s.writeWcc(res, dir)
+		} else {
+			s.writeWcc(res, nil)
+		}
+	}
+	return nil
+}
+
+// nfsLink can't make hard links
+func nfsLink(s *server, args *xdrReader, res *xdrWriter) error {
+	res.uint32(nfs3ErrNotSupp)
+	s.writePostOpAttr(res, nil)
+	s.writeWcc(res, nil)
+	return nil
+}
+
+// dirEntry is an entry returned by READDIR
+type dirEntry struct {
+	name string
+	node vfs.Node
+}
+
+// readDir returns the entries of dir including "." and ".."
+func (s *server) readDir(dir *vfs.Dir) ([]dirEntry, error) {
+	nodes, err := dir.ReadDirAll()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]dirEntry, 0, len(nodes)+2)
+	entries = append(entries, dirEntry{".", dir}, dirEntry{"..", s.parentOf(dir)})
+	for _, node := range nodes {
+		entries = append(entries, dirEntry{node.Name(), node})
+	}
+	return entries, nil
+}
+
+// nfsReaddir lists a directory
+func nfsReaddir(s *server, args *xdrReader, res *xdrWriter) error {
+	fh := args.opaque(maxHandleSize)
+	cookie := args.uint64()
+	_ = args.fixed(cookieVerfSize)
+	count := args.uint32()
+	if args.err != nil {
+		return args.err
+	}
+	return s.readdir(res, fh, cookie, count, count, false)
+}
+
+// nfsReaddirplus lists a directory with the attributes and handles
+// of the entries
+func nfsReaddirplus(s *server, args *xdrReader, res *xdrWriter) error {
+	fh := args.opaque(maxHandleSize)
+	cookie := args.uint64()
+	_ = args.fixed(cookieVerfSize)
+	dirCount := args.uint32()
+	maxCount := args.uint32()
+	if args.err != nil {
+		return args.err
+	}
+	return s.readdir(res, fh, cookie, dirCount, maxCount, true)
+}
+
+// readdir writes the entries of the directory fh after cookie
+//
+// The cookie of an entry is its position in the listing plus one.
+// Entries are written until there are no more or the size of the
+// names would exceed dirCount or the whole reply maxCount.
+func (s *server) readdir(res *xdrWriter, fh []byte, cookie uint64, dirCount, maxCount uint32, plus bool) error {
+	dir, status := s.lookupDir(fh)
+	var entries []dirEntry
+	if status == nfs3OK {
+		var err error
+		entries, err = s.readDir(dir)
+		status = nfsStatus(err)
+	}
+	var (
+		out      xdrWriter
+		dirBytes = 0
+		total    = readdirFixedSize
+		n        = 0
+		eof      = true
+	)
+	if status == nfs3OK {
+		for i := int(cookie); i < len(entries); i++ {
+			entry := entries[i]
+			nameSize := xdrPad(len(entry.name))
+			size := readdirEntrySize + nameSize
+			var fh []byte
+			if plus {
+				fh = s.handles.toHandle(entry.node)
+				size += readdirPlusExtra + xdrPad(len(fh))
+			}
+			dirBytes += readdirPlusDirent + nameSize
+			total += size
+			if uint32(total) > maxCount || (plus && uint32(dirBytes) > dirCount) {
+				eof = false
+				break
+			}
+			out.bool(true)
+			out.uint64(s.handles.fileID(entry.node))
+			out.string(entry.name)
+			out.uint64(uint64(i + 1))
+			if plus {
+				s.writePostOpAttr(&out, entry.node)
+				out.bool(true)
+				out.opaque(fh)
+			}
+			n++
+		}
+		if n == 0 && !eof {
+			status = nfs3ErrTooSmall
+		}
+	}
+	res.uint32(status)
+	if dir != nil {
+		s.writePostOpAttr(res, dir)
+	} else {
+		s.writePostOpAttr(res, nil)
+	}
+	if status == nfs3OK {
+		res.fixed(make([]byte, cookieVerfSize))
+		_, _ = res.Write(out.Bytes())
+		res.bool(false) // no more entries
+		res.bool(eof)
+	}
+	return nil
+}
+
+// nfsFsstat returns the space used and free on the remote
+func nfsFsstat(s *server, args *xdrReader, res *xdrWriter) error {
+	fh := args.opaque(maxHandleSize)
+	if args.err != nil {
+		return args.err
+	}
+	node, status := s.handles.fromHandle(fh)
+	res.uint32(status)
+	s.writePostOpAttr(res, node)
+	if status != nfs3OK {
+		return nil
+	}
+	total, used, free := s.vfs.Statfs()
+	if total < 0 {
+		total = unknownSize
+	}
+	if used < 0 {
+		used = 0
+	}
+	if free < 0 {
+		free = total - used
+		if free < 0 {
+			free = 0
+		}
+	}
+	res.uint64(uint64(total))
+	res.uint64(uint64(free))
+	res.uint64(uint64(free))
+	res.uint64(unknownFiles)
+	res.uint64(unknownFiles)
+	res.uint64(unknownFiles)
+	res.uint32(0) // invarsec
+	return nil
+}
+
+// nfsFsinfo returns the capabilities of the server
+func nfsFsinfo(s *server, args *xdrReader, res *xdrWriter) error {
+	fh := args.opaque(maxHandleSize)
+	if args.err != nil {
+		return args.err
+	}
+	node, status := s.handles.fromHandle(fh)
+	res.uint32(status)
+	s.writePostOpAttr(res, node)
+	if status != nfs3OK {
+		return nil
+	}
+	properties := uint32(fsf3Homogeneous | fsf3CanSetTime)
+	if s.vfs.Opt.Links {
+		properties |= fsf3Symlink
+	}
+	res.uint32(maxIOSize)        // rtmax
+	res.uint32(maxIOSize)        // rtpref
+	res.uint32(4096)             // rtmult
+	res.uint32(maxIOSize)        // wtmax
+	res.uint32(maxIOSize)        // wtpref
+	res.uint32(4096)             // wtmult
+	res.uint32(dirPreferredSize) // dtpref
+	res.uint64(1<<63 - 1)        // maxfilesize
+	res.uint32(0)                // time_delta seconds
+	res.uint32(1)                // time_delta nanoseconds
+	res.uint32(properties)
+	return nil
+}
+
+// nfsPathconf returns the limits on names
+func nfsPathconf(s *server, args *xdrReader, res *xdrWriter) error {
+	fh := args.opaque(maxHandleSize)
+	if args.err != nil {
+		return args.err
+	}
+	node, status := s.handles.fromHandle(fh)
+	res.uint32(status)
+	s.writePostOpAttr(res, node)
+	if status != nfs3OK {
+		return nil
+	}
+	res.uint32(1)          // linkmax
+	res.uint32(maxNameLen) // name_max
+	res.bool(true)         // no_trunc
+	res.bool(true)         // chown_restricted
+	res.bool(false)        // case_insensitive
+	res.bool(true)         // case_preserving
+	return nil
+}
+
+// nfsCommit uploads the data written to a file
+func nfsCommit(s *server, args *xdrReader, res *xdrWriter) error {
+	fh := args.opaque(maxHandleSize)
+	_ = args.uint64() // offset
+	_ = args.uint32() // count
+	if args.err != nil {
+		return args.err
+	}
+	file, status := s.lookupFile(fh)
+	if status == nfs3OK {
+		status = nfsStatus(s.files.close(file))
+	}
+	res.uint32(status)
+	if file != nil {
+		s.writeWcc(res, file)
+	} else {
+		s.writeWcc(res, nil)
+	}
+	if status == nfs3OK {
+		res.fixed(s.verf[:])
+	}
+	return nil
+}
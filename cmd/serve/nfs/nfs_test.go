@@ -0,0 +1,900 @@
+// Serve nfs tests set up a server and exercise it with an NFS client
+// speaking the protocol over TCP
+//
+// The test client shares the XDR code with the server so the layout
+// of the key calls and replies is checked against hand written bytes
+// in TestWireFormat.
+
+package nfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/ncw/rclone/backend/local"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/vfs"
+	"github.com/ncw/rclone/vfs/vfsflags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testBindAddress = "localhost:0"
+
+// testClient is a minimal NFS client making one call at a time
+type testClient struct {
+	t    *testing.T
+	s    *server
+	conn net.Conn
+	xid  uint32
+}
+
+// startServer starts a server serving a temporary directory with the
+// VFS cache mode given and returns a client connected to it
+func startServer(t *testing.T, cacheMode vfs.CacheMode) (dir string, c *testClient, finalise func()) {
+	dir, err := ioutil.TempDir("", "rclone-serve-nfs-test")
+	require.NoError(t, err)
+	cacheDir, err := ioutil.TempDir("", "rclone-serve-nfs-test-cache")
+	require.NoError(t, err)
+
+	oldOpt, oldCacheDir := vfsflags.Opt, config.CacheDir
+	vfsflags.Opt.CacheMode = cacheMode
+	config.CacheDir = cacheDir
+	c = &testClient{t: t}
+	c.start(dir)
+	finalise = func() {
+		c.stop()
+		vfsflags.Opt, config.CacheDir = oldOpt, oldCacheDir
+		require.NoError(t, os.RemoveAll(dir))
+		require.NoError(t, os.RemoveAll(cacheDir))
+	}
+	return dir, c, finalise
+}
+
+// start starts a server serving dir and connects the client to it
+func (c *testClient) start(dir string) {
+	f, err := fs.NewFs(dir)
+	require.NoError(c.t, err)
+	opt := DefaultOpt
+	opt.ListenAddr = testBindAddress
+	c.s, err = newServer(f, &opt)
+	require.NoError(c.t, err)
+	require.NoError(c.t, c.s.Serve())
+	c.conn, err = net.Dial("tcp", c.s.Addr())
+	require.NoError(c.t, err)
+}
+
+// stop disconnects the client and shuts the server down
+func (c *testClient) stop() {
+	_ = c.conn.Close()
+	c.s.Close()
+	c.s.Wait()
+}
+
+// call makes an RPC call returning the accept_stat and the results
+func (c *testClient) call(prog, vers, proc uint32, args *xdrWriter) (uint32, *xdrReader) {
+	c.xid++
+	var w xdrWriter
+	w.uint32(c.xid)
+	w.uint32(rpcCall)
+	w.uint32(rpcVersion)
+	w.uint32(prog)
+	w.uint32(vers)
+	w.uint32(proc)
+	// AUTH_UNIX credentials
+	var cred xdrWriter
+	cred.uint32(0) // stamp
+	cred.string("test")
+	cred.uint32(1000) // uid
+	cred.uint32(1000) // gid
+	cred.uint32(0)    // gids
+	w.uint32(authUnix)
+	w.opaque(cred.Bytes())
+	w.uint32(authNone)
+	w.opaque(nil)
+	if args != nil {
+		_, _ = w.Write(args.Bytes())
+	}
+	require.NoError(c.t, writeRecord(c.conn, w.Bytes()))
+
+	data, err := readRecord(c.conn)
+	require.NoError(c.t, err)
+	r := newXDRReader(data)
+	assert.Equal(c.t, c.xid, r.uint32())
+	assert.Equal(c.t, uint32(rpcReply), r.uint32())
+	assert.Equal(c.t, uint32(msgAccepted), r.uint32())
+	_ = r.uint32() // verifier
+	_ = r.opaque(maxAuthSize)
+	acceptStat := r.uint32()
+	require.NoError(c.t, r.err)
+	return acceptStat, r
+}
+
+// nfs calls an NFS procedure returning the status and the rest of
+// the results
+func (c *testClient) nfs(proc uint32, args *xdrWriter) (uint32, *xdrReader) {
+	acceptStat, r := c.call(nfsProgram, nfsVersion, proc, args)
+	require.Equal(c.t, uint32(acceptSuccess), acceptStat)
+	return r.uint32(), r
+}
+
+// mount returns the handle of the directory dirPath
+func (c *testClient) mount(dirPath string) []byte {
+	var args xdrWriter
+	args.string(dirPath)
+	acceptStat, r := c.call(mountProgram, mountVersion, 1, &args)
+	require.Equal(c.t, uint32(acceptSuccess), acceptStat)
+	require.Equal(c.t, uint32(mnt3OK), r.uint32())
+	fh := r.opaque(maxHandleSize)
+	require.NoError(c.t, r.err)
+	return fh
+}
+
+// fattr is the decoded attributes of a node
+type fattr struct {
+	ftype  uint32
+	mode   uint32
+	size   uint64
+	fileid uint64
+	mtime  time.Time
+}
+
+// readFattr decodes a fattr3
+func readFattr(r *xdrReader) (a fattr) {
+	a.ftype = r.uint32()
+	a.mode = r.uint32()
+	_ = r.uint32() // nlink
+	_ = r.uint32() // uid
+	_ = r.uint32() // gid
+	a.size = r.uint64()
+	_ = r.uint64() // used
+	_ = r.uint64() // rdev
+	_ = r.uint64() // fsid
+	a.fileid = r.uint64()
+	_ = readTime(r) // atime
+	a.mtime = readTime(r)
+	_ = readTime(r) // ctime
+	return a
+}
+
+// readPostOpAttr decodes a post_op_attr
+func readPostOpAttr(r *xdrReader) *fattr {
+	if !r.bool() {
+		return nil
+	}
+	a := readFattr(r)
+	return &a
+}
+
+// readWcc decodes a wcc_data returning the attributes after
+func readWcc(r *xdrReader) *fattr {
+	if r.bool() {
+		_ = r.next(8 + 8 + 8) // size, mtime, ctime
+	}
+	return readPostOpAttr(r)
+}
+
+// dirOpArgs encodes a handle and a name
+func dirOpArgs(fh []byte, name string) *xdrWriter {
+	var args xdrWriter
+	args.opaque(fh)
+	args.string(name)
+	return &args
+}
+
+// writeEmptySattr encodes a sattr3 which sets nothing
+func writeEmptySattr(args *xdrWriter) {
+	for i := 0; i < 6; i++ {
+		args.uint32(dontChange)
+	}
+}
+
+// getattr returns the attributes of fh
+func (c *testClient) getattr(fh []byte) (uint32, fattr) {
+	var args xdrWriter
+	args.opaque(fh)
+	status, r := c.nfs(1, &args)
+	var a fattr
+	if status == nfs3OK {
+		a = readFattr(r)
+	}
+	require.NoError(c.t, r.err)
+	return status, a
+}
+
+// lookup returns the handle of name in dir
+func (c *testClient) lookup(dir []byte, name string) (uint32, []byte) {
+	status, r := c.nfs(3, dirOpArgs(dir, name))
+	var fh []byte
+	if status == nfs3OK {
+		fh = r.opaque(maxHandleSize)
+	}
+	require.NoError(c.t, r.err)
+	return status, fh
+}
+
+// readNewNode decodes the result of CREATE, MKDIR or SYMLINK
+func (c *testClient) readNewNode(status uint32, r *xdrReader) []byte {
+	var fh []byte
+	if status == nfs3OK {
+		require.True(c.t, r.bool())
+		fh = r.opaque(maxHandleSize)
+		require.NotNil(c.t, readPostOpAttr(r))
+	}
+	readWcc(r)
+	require.NoError(c.t, r.err)
+	return fh
+}
+
+// create creates a file called name in dir
+func (c *testClient) create(dir []byte, name string, how uint32) (uint32, []byte) {
+	args := dirOpArgs(dir, name)
+	args.uint32(how)
+	if how == createExclusive {
+		args.fixed([]byte("verifier"))
+	} else {
+		writeEmptySattr(args)
+	}
+	status, r := c.nfs(8, args)
+	return status, c.readNewNode(status, r)
+}
+
+// mkdir creates a directory called name in dir
+func (c *testClient) mkdir(dir []byte, name string) (uint32, []byte) {
+	args := dirOpArgs(dir, name)
+	writeEmptySattr(args)
+	status, r := c.nfs(9, args)
+	return status, c.readNewNode(status, r)
+}
+
+// tryWrite writes data to fh at offset returning the status and the
+// rest of the results
+func (c *testClient) tryWrite(fh []byte, offset uint64, data string, stable uint32) (uint32, *xdrReader) {
+	var args xdrWriter
+	args.opaque(fh)
+	args.uint64(offset)
+	args.uint32(uint32(len(data)))
+	args.uint32(stable)
+	args.opaque([]byte(data))
+	status, r := c.nfs(7, &args)
+	readWcc(r)
+	return status, r
+}
+
+// write writes data to fh at offset returning the verifier
+func (c *testClient) write(fh []byte, offset uint64, data string, stable uint32) []byte {
+	status, r := c.tryWrite(fh, offset, data, stable)
+	require.Equal(c.t, uint32(nfs3OK), status)
+	assert.Equal(c.t, uint32(len(data)), r.uint32())
+	assert.Equal(c.t, stable, r.uint32())
+	verf := r.fixed(8)
+	require.NoError(c.t, r.err)
+	return verf
+}
+
+// truncate sets the size of fh to size with SETATTR
+func (c *testClient) truncate(fh []byte, size uint64) uint32 {
+	var args xdrWriter
+	args.opaque(fh)
+	args.bool(false) // mode
+	args.bool(false) // uid
+	args.bool(false) // gid
+	args.bool(true)  // size
+	args.uint64(size)
+	args.uint32(dontChange) // atime
+	args.uint32(dontChange) // mtime
+	args.bool(false)        // guard
+	status, r := c.nfs(2, &args)
+	readWcc(r)
+	require.NoError(c.t, r.err)
+	return status
+}
+
+// commit commits the writes to fh returning the verifier
+func (c *testClient) commit(fh []byte) []byte {
+	var args xdrWriter
+	args.opaque(fh)
+	args.uint64(0)
+	args.uint32(0)
+	status, r := c.nfs(21, &args)
+	require.Equal(c.t, uint32(nfs3OK), status)
+	readWcc(r)
+	verf := r.fixed(8)
+	require.NoError(c.t, r.err)
+	return verf
+}
+
+// read reads count bytes from fh at offset
+func (c *testClient) read(fh []byte, offset uint64, count uint32) (string, bool) {
+	var args xdrWriter
+	args.opaque(fh)
+	args.uint64(offset)
+	args.uint32(count)
+	status, r := c.nfs(6, &args)
+	require.Equal(c.t, uint32(nfs3OK), status)
+	readPostOpAttr(r)
+	n := r.uint32()
+	eof := r.bool()
+	data := r.opaque(maxIOSize)
+	require.NoError(c.t, r.err)
+	assert.Equal(c.t, int(n), len(data))
+	return string(data), eof
+}
+
+// readdirplus lists dir returning the names and whether the listing
+// was complete, starting after cookie
+func (c *testClient) readdirplus(dir []byte, cookie uint64, maxCount uint32) (names []string, lastCookie uint64, eof bool, status uint32) {
+	var args xdrWriter
+	args.opaque(dir)
+	args.uint64(cookie)
+	args.fixed(make([]byte, cookieVerfSize))
+	args.uint32(maxCount)
+	args.uint32(maxCount)
+	status, r := c.nfs(17, &args)
+	readPostOpAttr(r)
+	if status != nfs3OK {
+		return nil, 0, false, status
+	}
+	_ = r.fixed(cookieVerfSize)
+	for r.bool() {
+		_ = r.uint64() // fileid
+		names = append(names, r.string(maxNameArg))
+		lastCookie = r.uint64()
+		require.NotNil(c.t, readPostOpAttr(r))
+		require.True(c.t, r.bool())
+		_ = r.opaque(maxHandleSize)
+	}
+	eof = r.bool()
+	require.NoError(c.t, r.err)
+	return names, lastCookie, eof, status
+}
+
+// remove removes name from dir with REMOVE or RMDIR
+func (c *testClient) remove(proc uint32, dir []byte, name string) uint32 {
+	status, r := c.nfs(proc, dirOpArgs(dir, name))
+	readWcc(r)
+	require.NoError(c.t, r.err)
+	return status
+}
+
+func TestNullAndExport(t *testing.T) {
+	_, c, finalise := startServer(t, vfs.CacheModeWrites)
+	defer finalise()
+
+	acceptStat, _ := c.call(nfsProgram, nfsVersion, 0, nil)
+	assert.Equal(t, uint32(acceptSuccess), acceptStat)
+	acceptStat, _ = c.call(mountProgram, mountVersion, 0, nil)
+	assert.Equal(t, uint32(acceptSuccess), acceptStat)
+
+	acceptStat, r := c.call(mountProgram, mountVersion, 5, nil)
+	require.Equal(t, uint32(acceptSuccess), acceptStat)
+	assert.True(t, r.bool())
+	assert.Equal(t, "/", r.string(maxMountPath))
+
+	// Wrong version and unknown procedures and programs
+	acceptStat, _ = c.call(nfsProgram, 2, 0, nil)
+	assert.Equal(t, uint32(acceptProgMismatch), acceptStat)
+	acceptStat, _ = c.call(nfsProgram, nfsVersion, 22, nil)
+	assert.Equal(t, uint32(acceptProcUnavail), acceptStat)
+	acceptStat, _ = c.call(100000, 2, 0, nil)
+	assert.Equal(t, uint32(acceptProgUnavail), acceptStat)
+
+	// Missing arguments
+	acceptStat, _ = c.call(nfsProgram, nfsVersion, 1, nil)
+	assert.Equal(t, uint32(acceptGarbageArgs), acceptStat)
+}
+
+func TestMount(t *testing.T) {
+	dir, c, finalise := startServer(t, vfs.CacheModeWrites)
+	defer finalise()
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0777))
+
+	root := c.mount("/")
+	status, a := c.getattr(root)
+	require.Equal(t, uint32(nfs3OK), status)
+	assert.Equal(t, uint32(nf3Dir), a.ftype)
+
+	sub := c.mount("/sub")
+	status, a = c.getattr(sub)
+	require.Equal(t, uint32(nfs3OK), status)
+	assert.Equal(t, uint32(nf3Dir), a.ftype)
+	status, fh := c.lookup(root, "sub")
+	require.Equal(t, uint32(nfs3OK), status)
+	assert.Equal(t, sub, fh)
+
+	var args xdrWriter
+	args.string("/notfound")
+	_, r := c.call(mountProgram, mountVersion, 1, &args)
+	assert.Equal(t, uint32(mnt3ErrNoEnt), r.uint32())
+}
+
+func TestReadWrite(t *testing.T) {
+	dir, c, finalise := startServer(t, vfs.CacheModeWrites)
+	defer finalise()
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "outside.txt"), []byte("potato"), 0666))
+	root := c.mount("/")
+
+	status, fh := c.create(root, "hello.txt", createUnchecked)
+	require.Equal(t, uint32(nfs3OK), status)
+
+	// Write the blocks out of order
+	verf := c.write(fh, 6, "world", writeUnstable)
+	assert.Equal(t, verf, c.write(fh, 0, "hello ", writeUnstable))
+	status, a := c.getattr(fh)
+	require.Equal(t, uint32(nfs3OK), status)
+	assert.Equal(t, uint32(nf3Reg), a.ftype)
+	assert.Equal(t, uint64(11), a.size)
+
+	assert.Equal(t, verf, c.commit(fh))
+	data, err := ioutil.ReadFile(filepath.Join(dir, "hello.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+
+	data2, eof := c.read(fh, 0, 5)
+	assert.Equal(t, "hello", data2)
+	assert.False(t, eof)
+	data2, eof = c.read(fh, 6, 100)
+	assert.Equal(t, "world", data2)
+	assert.True(t, eof)
+
+	// Stable writes are held in the cache until committed
+	c.write(fh, 11, "!", writeFileSync)
+	c.commit(fh)
+	data, err = ioutil.ReadFile(filepath.Join(dir, "hello.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello world!", string(data))
+
+	// Files created outside the server can be read
+	status, fh = c.lookup(root, "outside.txt")
+	require.Equal(t, uint32(nfs3OK), status)
+	data2, eof = c.read(fh, 0, 100)
+	assert.Equal(t, "potato", data2)
+	assert.True(t, eof)
+
+	// Creates
+	status, _ = c.create(root, "hello.txt", createGuarded)
+	assert.Equal(t, uint32(nfs3ErrExist), status)
+	status, fh = c.create(root, "excl.txt", createExclusive)
+	require.Equal(t, uint32(nfs3OK), status)
+	status, fh2 := c.create(root, "excl.txt", createExclusive)
+	require.Equal(t, uint32(nfs3OK), status, "retry of exclusive create")
+	assert.Equal(t, fh, fh2)
+	status, _ = c.create(root, "bad/name", createUnchecked)
+	assert.Equal(t, uint32(nfs3ErrInval), status)
+}
+
+func TestDirectories(t *testing.T) {
+	dir, c, finalise := startServer(t, vfs.CacheModeWrites)
+	defer finalise()
+
+	root := c.mount("/")
+
+	status, sub := c.mkdir(root, "sub")
+	require.Equal(t, uint32(nfs3OK), status)
+	status, _ = c.mkdir(root, "sub")
+	assert.Equal(t, uint32(nfs3ErrExist), status)
+	fi, err := os.Stat(filepath.Join(dir, "sub"))
+	require.NoError(t, err)
+	assert.True(t, fi.IsDir())
+
+	for _, name := range []string{"a", "b", "c"} {
+		status, fh := c.create(sub, name, createUnchecked)
+		require.Equal(t, uint32(nfs3OK), status)
+		c.write(fh, 0, name, writeFileSync)
+		c.commit(fh)
+	}
+
+	names, _, eof, status := c.readdirplus(sub, 0, 64*1024)
+	require.Equal(t, uint32(nfs3OK), status)
+	assert.True(t, eof)
+	assert.Equal(t, []string{".", "..", "a", "b", "c"}, names)
+
+	// List in pieces
+	var all []string
+	var cookie uint64
+	for {
+		names, lastCookie, eof, status := c.readdirplus(sub, cookie, 400)
+		require.Equal(t, uint32(nfs3OK), status)
+		require.NotEmpty(t, names)
+		all = append(all, names...)
+		cookie = lastCookie
+		if eof {
+			break
+		}
+	}
+	sort.Strings(all)
+	assert.Equal(t, []string{".", "..", "a", "b", "c"}, all)
+	_, _, _, status = c.readdirplus(sub, 0, 100)
+	assert.Equal(t, uint32(nfs3ErrTooSmall), status)
+
+	// Rename keeps the handle valid and the file id the same
+	status, fh := c.lookup(sub, "a")
+	require.Equal(t, uint32(nfs3OK), status)
+	status, a := c.getattr(fh)
+	require.Equal(t, uint32(nfs3OK), status)
+	args := dirOpArgs(sub, "a")
+	args.opaque(root)
+	args.string("renamed")
+	status, r := c.nfs(14, args)
+	require.Equal(t, uint32(nfs3OK), status)
+	readWcc(r)
+	readWcc(r)
+	require.NoError(t, r.err)
+	_, err = os.Stat(filepath.Join(dir, "renamed"))
+	require.NoError(t, err)
+	status, fh2 := c.lookup(root, "renamed")
+	require.Equal(t, uint32(nfs3OK), status)
+	status, a2 := c.getattr(fh2)
+	require.Equal(t, uint32(nfs3OK), status)
+	assert.Equal(t, a.fileid, a2.fileid)
+	status, a2 = c.getattr(fh)
+	require.Equal(t, uint32(nfs3OK), status)
+	assert.Equal(t, a.fileid, a2.fileid)
+	data, _ := c.read(fh, 0, 100)
+	assert.Equal(t, "a", data)
+
+	// Remove
+	assert.Equal(t, uint32(nfs3ErrNotEmpty), c.remove(13, root, "sub"))
+	assert.Equal(t, uint32(nfs3ErrIsDir), c.remove(12, root, "sub"))
+	assert.Equal(t, uint32(nfs3ErrNotDir), c.remove(13, root, "renamed"))
+	assert.Equal(t, uint32(nfs3OK), c.remove(12, root, "renamed"))
+	assert.Equal(t, uint32(nfs3ErrNoEnt), c.remove(12, root, "renamed"))
+	_, err = os.Stat(filepath.Join(dir, "renamed"))
+	assert.True(t, os.IsNotExist(err))
+	status, _ = c.getattr(fh)
+	assert.Equal(t, uint32(nfs3ErrStale), status)
+
+	for _, name := range []string{"b", "c"} {
+		assert.Equal(t, uint32(nfs3OK), c.remove(12, sub, name))
+	}
+	assert.Equal(t, uint32(nfs3OK), c.remove(13, root, "sub"))
+	status, _ = c.getattr(sub)
+	assert.Equal(t, uint32(nfs3ErrStale), status)
+	status, _ = c.lookup(root, "sub")
+	assert.Equal(t, uint32(nfs3ErrNoEnt), status)
+}
+
+func TestHandles(t *testing.T) {
+	_, c, finalise := startServer(t, vfs.CacheModeWrites)
+	defer finalise()
+
+	root := c.mount("/")
+
+	// Handles of files which don't exist
+	status, _ := c.getattr([]byte("\x01missing"))
+	assert.Equal(t, uint32(nfs3ErrStale), status)
+	status, _ = c.getattr(append([]byte{handleHash}, hashPath("missing")...))
+	assert.Equal(t, uint32(nfs3ErrStale), status)
+
+	// Not a handle at all
+	status, _ = c.getattr([]byte("bad"))
+	assert.Equal(t, uint32(nfs3ErrBadHandle), status)
+	status, _ = c.getattr([]byte("\x02bad"))
+	assert.Equal(t, uint32(nfs3ErrBadHandle), status)
+
+	// Looking up in a file
+	status, fh := c.create(root, "file", createUnchecked)
+	require.Equal(t, uint32(nfs3OK), status)
+	status, _ = c.lookup(fh, "x")
+	assert.Equal(t, uint32(nfs3ErrNotDir), status)
+
+	// FSINFO
+	var args xdrWriter
+	args.opaque(root)
+	status, r := c.nfs(19, &args)
+	require.Equal(t, uint32(nfs3OK), status)
+	readPostOpAttr(r)
+	assert.Equal(t, uint32(maxIOSize), r.uint32()) // rtmax
+	require.NoError(t, r.err)
+}
+
+func TestWriteNoCache(t *testing.T) {
+	dir, c, finalise := startServer(t, vfs.CacheModeOff)
+	defer finalise()
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "existing.txt"), []byte("potato"), 0666))
+	root := c.mount("/")
+
+	// New files can be written sequentially
+	status, fh := c.create(root, "hello.txt", createUnchecked)
+	require.Equal(t, uint32(nfs3OK), status)
+	c.write(fh, 0, "hello ", writeUnstable)
+	c.write(fh, 6, "world", writeUnstable)
+	c.commit(fh)
+	data, err := ioutil.ReadFile(filepath.Join(dir, "hello.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+
+	// Writing after the file has been uploaded fails rather than
+	// truncating it
+	status, _ = c.tryWrite(fh, 11, "!", writeUnstable)
+	assert.NotEqual(t, uint32(nfs3OK), status)
+	c.commit(fh)
+	data, err = ioutil.ReadFile(filepath.Join(dir, "hello.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+
+	// Out of order writes fail
+	status, fh = c.create(root, "ooo.txt", createUnchecked)
+	require.Equal(t, uint32(nfs3OK), status)
+	status, _ = c.tryWrite(fh, 5, "world", writeUnstable)
+	assert.NotEqual(t, uint32(nfs3OK), status)
+
+	// Existing files can't be written without truncating them first
+	status, fh = c.lookup(root, "existing.txt")
+	require.Equal(t, uint32(nfs3OK), status)
+	status, _ = c.tryWrite(fh, 0, "carrot", writeUnstable)
+	assert.Equal(t, uint32(nfs3ErrPerm), status)
+	c.commit(fh)
+	data, err = ioutil.ReadFile(filepath.Join(dir, "existing.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "potato", string(data))
+
+	// Truncating holds the file open for the writes which follow
+	assert.Equal(t, uint32(nfs3OK), c.truncate(fh, 0))
+	c.write(fh, 0, "car", writeUnstable)
+	c.write(fh, 3, "rot", writeFileSync)
+	data, err = ioutil.ReadFile(filepath.Join(dir, "existing.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "carrot", string(data))
+
+	// A stable write uploads the file so the next write fails
+	// without losing it
+	status, _ = c.tryWrite(fh, 6, "s", writeFileSync)
+	assert.NotEqual(t, uint32(nfs3OK), status)
+	status, _ = c.tryWrite(fh, 0, "s", writeFileSync)
+	assert.Equal(t, uint32(nfs3ErrPerm), status)
+	c.commit(fh)
+	data, err = ioutil.ReadFile(filepath.Join(dir, "existing.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "carrot", string(data))
+}
+
+func TestHandlesForgotten(t *testing.T) {
+	dir, c, finalise := startServer(t, vfs.CacheModeWrites)
+	defer finalise()
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0777))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "file.txt"), []byte("potato"), 0666))
+	root := c.mount("/")
+	status, sub := c.lookup(root, "sub")
+	require.Equal(t, uint32(nfs3OK), status)
+	status, fh := c.lookup(sub, "file.txt")
+	require.Equal(t, uint32(nfs3OK), status)
+
+	// The VFS makes new nodes when it forgets the directory but
+	// the handles still work
+	vfsRoot, err := c.s.vfs.Root()
+	require.NoError(t, err)
+	vfsRoot.ForgetAll()
+	status, a := c.getattr(fh)
+	require.Equal(t, uint32(nfs3OK), status)
+	assert.Equal(t, uint64(6), a.size)
+	data, _ := c.read(fh, 0, 100)
+	assert.Equal(t, "potato", data)
+	status, _ = c.lookup(sub, "file.txt")
+	assert.Equal(t, uint32(nfs3OK), status)
+
+	// Handles of files which have gone are stale
+	require.NoError(t, os.Remove(filepath.Join(dir, "sub", "file.txt")))
+	vfsRoot.ForgetAll()
+	status, _ = c.getattr(fh)
+	assert.Equal(t, uint32(nfs3ErrStale), status)
+}
+
+func TestRestart(t *testing.T) {
+	dir, c, finalise := startServer(t, vfs.CacheModeOff)
+	defer finalise()
+
+	long := strings.Repeat("d", 40)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, long, long), 0777))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte("potato"), 0666))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, long, long, "file.txt"), []byte("carrot"), 0666))
+	root := c.mount("/")
+	status, short := c.lookup(root, "file.txt")
+	require.Equal(t, uint32(nfs3OK), status)
+	status, sub := c.lookup(root, long)
+	require.Equal(t, uint32(nfs3OK), status)
+	status, sub = c.lookup(sub, long)
+	require.Equal(t, uint32(nfs3OK), status)
+	status, deep := c.lookup(sub, "file.txt")
+	require.Equal(t, uint32(nfs3OK), status)
+	assert.Equal(t, []byte{handleHash}, deep[:1], "path too long for the handle")
+
+	handles := [][]byte{root, short, sub, deep}
+	var fileids []uint64
+	for _, fh := range handles {
+		status, a := c.getattr(fh)
+		require.Equal(t, uint32(nfs3OK), status)
+		fileids = append(fileids, a.fileid)
+	}
+
+	// The handles and file ids are the same after a restart
+	c.stop()
+	c.start(dir)
+	assert.Equal(t, root, c.mount("/"))
+	for i, fh := range handles {
+		status, a := c.getattr(fh)
+		require.Equal(t, uint32(nfs3OK), status)
+		assert.Equal(t, fileids[i], a.fileid)
+	}
+	data, _ := c.read(short, 0, 100)
+	assert.Equal(t, "potato", data)
+	data, _ = c.read(deep, 0, 100)
+	assert.Equal(t, "carrot", data)
+	status, fh := c.lookup(sub, "file.txt")
+	require.Equal(t, uint32(nfs3OK), status)
+	assert.Equal(t, deep, fh)
+}
+
+func TestOpenFilesInUse(t *testing.T) {
+	dir, c, finalise := startServer(t, vfs.CacheModeWrites)
+	defer finalise()
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte("potato"), 0666))
+	node, err := c.s.vfs.Stat("file.txt")
+	require.NoError(t, err)
+	file := node.(*vfs.File)
+	of := c.s.files
+
+	// Reopening for write leaves the read handle open for its user
+	rh, releaseRead, err := of.get(file, false, 0)
+	require.NoError(t, err)
+	wh, releaseWrite, err := of.get(file, true, 0)
+	require.NoError(t, err)
+	buf := make([]byte, 6)
+	_, err = rh.ReadAt(buf, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "potato", string(buf))
+	releaseRead()
+
+	// Closing waits for the handle to be released
+	closed := make(chan error)
+	go func() {
+		closed <- of.close(file)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("file closed while in use")
+	case <-time.After(100 * time.Millisecond):
+	}
+	_, err = wh.WriteAt([]byte("carrot"), 0)
+	require.NoError(t, err)
+	releaseWrite()
+	require.NoError(t, <-closed)
+	data, err := ioutil.ReadFile(filepath.Join(dir, "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "carrot", string(data))
+}
+
+// rawCall sends an RPC call with AUTH_NONE encoded by hand and
+// returns the reply without its record marker
+func rawCall(t *testing.T, conn net.Conn, xid, prog, vers, proc uint32, args ...[]byte) []byte {
+	call := []uint32{xid, 0, 2, prog, vers, proc, 0, 0, 0, 0}
+	var buf bytes.Buffer
+	for _, x := range call {
+		require.NoError(t, binary.Write(&buf, binary.BigEndian, x))
+	}
+	for _, arg := range args {
+		buf.Write(arg)
+	}
+	marker := make([]byte, 4)
+	binary.BigEndian.PutUint32(marker, 0x80000000|uint32(buf.Len()))
+	_, err := conn.Write(append(marker, buf.Bytes()...))
+	require.NoError(t, err)
+	_, err = io.ReadFull(conn, marker)
+	require.NoError(t, err)
+	require.Equal(t, byte(0x80), marker[0], "last fragment bit")
+	reply := make([]byte, binary.BigEndian.Uint32(marker)&0x7fffffff)
+	_, err = io.ReadFull(conn, reply)
+	require.NoError(t, err)
+	return reply
+}
+
+// attrSize is the size of an encoded fattr3 from RFC 1813
+const attrSize = 84
+
+func TestWireFormat(t *testing.T) {
+	dir, c, finalise := startServer(t, vfs.CacheModeWrites)
+	defer finalise()
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "file"), []byte("potato"), 0666))
+
+	// NULL call and reply written out in full
+	_, err := c.conn.Write([]byte{
+		0x80, 0x00, 0x00, 0x28, // record marker: last fragment, 40 bytes
+		0x00, 0x00, 0x00, 0x2a, // xid
+		0x00, 0x00, 0x00, 0x00, // CALL
+		0x00, 0x00, 0x00, 0x02, // RPC version 2
+		0x00, 0x01, 0x86, 0xa3, // program 100003 NFS
+		0x00, 0x00, 0x00, 0x03, // version 3
+		0x00, 0x00, 0x00, 0x00, // procedure 0 NULL
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // AUTH_NONE credentials
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // AUTH_NONE verifier
+	})
+	require.NoError(t, err)
+	reply := make([]byte, 28)
+	_, err = io.ReadFull(c.conn, reply)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{
+		0x80, 0x00, 0x00, 0x18, // record marker: last fragment, 24 bytes
+		0x00, 0x00, 0x00, 0x2a, // xid
+		0x00, 0x00, 0x00, 0x01, // REPLY
+		0x00, 0x00, 0x00, 0x00, // MSG_ACCEPTED
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // AUTH_NONE verifier
+		0x00, 0x00, 0x00, 0x00, // SUCCESS
+	}, reply)
+	acceptedHeader := func(xid byte) []byte {
+		return []byte{0, 0, 0, xid, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	}
+
+	// MNT of "/" returns the handle and the auth flavors
+	reply = rawCall(t, c.conn, 1, 100005, 3, 1, []byte{0, 0, 0, 1, '/', 0, 0, 0})
+	require.Len(t, reply, 24+4+4+4+12)
+	assert.Equal(t, acceptedHeader(1), reply[:24])
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 1}, reply[24:32])           // MNT3_OK, handle length
+	assert.Equal(t, []byte{1, 0, 0, 0}, reply[32:36])                       // handle of the empty path
+	assert.Equal(t, []byte{0, 0, 0, 2, 0, 0, 0, 1, 0, 0, 0, 0}, reply[36:]) // AUTH_UNIX, AUTH_NONE
+	root := []byte{0, 0, 0, 1, 1, 0, 0, 0}
+	fh := []byte{0, 0, 0, 5, 1, 'f', 'i', 'l', 'e', 0, 0, 0}
+
+	// LOOKUP returns the handle and the attributes of the file and
+	// the directory
+	reply = rawCall(t, c.conn, 2, 100003, 3, 3, root, []byte{0, 0, 0, 4, 'f', 'i', 'l', 'e'})
+	require.Len(t, reply, 24+4+12+4+attrSize+4+attrSize)
+	assert.Equal(t, acceptedHeader(2), reply[:24])
+	assert.Equal(t, []byte{0, 0, 0, 0}, reply[24:28]) // NFS3_OK
+	assert.Equal(t, fh, reply[28:40])
+	attr := reply[40:]
+	assert.Equal(t, []byte{0, 0, 0, 1}, attr[:4])                    // attributes follow
+	assert.Equal(t, []byte{0, 0, 0, 1}, attr[4:8])                   // NF3REG
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 6}, attr[4+20:4+28]) // size
+	assert.Equal(t, []byte{0, 0, 0, 1}, attr[4+attrSize:8+attrSize]) // directory attributes follow
+	assert.Equal(t, []byte{0, 0, 0, 2}, attr[8+attrSize:12+attrSize])
+
+	// GETATTR of the file
+	reply = rawCall(t, c.conn, 3, 100003, 3, 1, fh)
+	require.Len(t, reply, 24+4+attrSize)
+	assert.Equal(t, acceptedHeader(3), reply[:24])
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 1}, reply[24:32]) // NFS3_OK, NF3REG
+	assert.Equal(t, attr[4:4+attrSize], reply[28:])
+
+	// Unstable WRITE of 2 bytes
+	reply = rawCall(t, c.conn, 4, 100003, 3, 7, fh, []byte{
+		0, 0, 0, 0, 0, 0, 0, 0, // offset
+		0, 0, 0, 2, // count
+		0, 0, 0, 0, // UNSTABLE
+		0, 0, 0, 2, 'c', 'a', 0, 0, // data
+	})
+	require.Len(t, reply, 24+4+4+4+attrSize+4+4+8)
+	assert.Equal(t, acceptedHeader(4), reply[:24])
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, reply[24:36])       // NFS3_OK, no pre attributes, post attributes follow
+	assert.Equal(t, []byte{0, 0, 0, 2, 0, 0, 0, 0}, reply[36+attrSize:44+attrSize]) // count, UNSTABLE
+	assert.Equal(t, c.s.verf[:], reply[44+attrSize:])
+
+	// READ of the file
+	reply = rawCall(t, c.conn, 5, 100003, 3, 6, fh, []byte{
+		0, 0, 0, 0, 0, 0, 0, 0, // offset
+		0, 0, 0, 100, // count
+	})
+	require.Len(t, reply, 24+4+4+attrSize+4+4+4+8)
+	assert.Equal(t, acceptedHeader(5), reply[:24])
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 1}, reply[24:32]) // NFS3_OK, attributes follow
+	assert.Equal(t, []byte{
+		0, 0, 0, 6, // count
+		0, 0, 0, 1, // eof
+		0, 0, 0, 6, 'c', 'a', 't', 'a', 't', 'o', 0, 0, // data
+	}, reply[32+attrSize:])
+}
@@ -0,0 +1,153 @@
+// ONC RPC over TCP as described in RFC 5531
+
+package nfs
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// RPC constants
+const (
+	rpcVersion = 2
+
+	// msg_type
+	rpcCall  = 0
+	rpcReply = 1
+
+	// reply_stat
+	msgAccepted = 0
+	msgDenied   = 1
+
+	// accept_stat
+	acceptSuccess      = 0
+	acceptProgUnavail  = 1
+	acceptProgMismatch = 2
+	acceptProcUnavail  = 3
+	acceptGarbageArgs  = 4
+
+	// reject_stat
+	rejectRPCMismatch = 0
+
+	// auth_flavor
+	authNone = 0
+	authUnix = 1
+
+	// maxAuthSize is the largest credential or verifier allowed
+	maxAuthSize = 400
+
+	// maxRecordSize is the largest RPC message accepted which
+	// must be big enough for a WRITE of maxIOSize
+	maxRecordSize = maxIOSize + 64*1024
+
+	// lastFragment is set in the record marker of the last
+	// fragment of a record
+	lastFragment = 1 << 31
+)
+
+// rpcCallMsg is a decoded RPC call
+type rpcCallMsg struct {
+	xid  uint32
+	prog uint32
+	vers uint32
+	proc uint32
+	args *xdrReader // the procedure arguments
+}
+
+// readRecord reads an RPC message split into fragments with record
+// marking from r
+func readRecord(r io.Reader) ([]byte, error) {
+	var record []byte
+	for {
+		var marker [4]byte
+		_, err := io.ReadFull(r, marker[:])
+		if err != nil {
+			return nil, err
+		}
+		header := binary.BigEndian.Uint32(marker[:])
+		size := int(header &^ lastFragment)
+		if len(record)+size > maxRecordSize {
+			return nil, errors.Errorf("RPC message too big (%d bytes)", len(record)+size)
+		}
+		fragment := make([]byte, size)
+		_, err = io.ReadFull(r, fragment)
+		if err != nil {
+			return nil, err
+		}
+		record = append(record, fragment...)
+		if header&lastFragment != 0 {
+			return record, nil
+		}
+	}
+}
+
+// writeRecord writes an RPC message to w as a single fragment
+func writeRecord(w io.Writer, data []byte) error {
+	buf := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(buf, lastFragment|uint32(len(data)))
+	copy(buf[4:], data)
+	_, err := w.Write(buf)
+	return err
+}
+
+// parseCall decodes the header of an RPC call message
+//
+// If the message is a call with the wrong RPC version it returns the
+// reply to send with the error.
+func parseCall(data []byte) (call *rpcCallMsg, reply *xdrWriter, err error) {
+	r := newXDRReader(data)
+	call = &rpcCallMsg{
+		xid: r.uint32(),
+	}
+	msgType := r.uint32()
+	if r.err == nil && msgType != rpcCall {
+		return nil, nil, errors.Errorf("unexpected RPC message type %d", msgType)
+	}
+	version := r.uint32()
+	if r.err == nil && version != rpcVersion {
+		reply = new(xdrWriter)
+		reply.uint32(call.xid)
+		reply.uint32(rpcReply)
+		reply.uint32(msgDenied)
+		reply.uint32(rejectRPCMismatch)
+		reply.uint32(rpcVersion)
+		reply.uint32(rpcVersion)
+		return nil, reply, errors.Errorf("unsupported RPC version %d", version)
+	}
+	call.prog = r.uint32()
+	call.vers = r.uint32()
+	call.proc = r.uint32()
+	// The credentials and verifier aren't checked
+	for i := 0; i < 2; i++ {
+		_ = r.uint32() // flavor
+		_ = r.opaque(maxAuthSize)
+	}
+	if r.err != nil {
+		return nil, nil, errors.Wrap(r.err, "bad RPC call header")
+	}
+	call.args = r
+	return call, nil, nil
+}
+
+// newReply starts the reply to call with the accept_stat given
+func newReply(call *rpcCallMsg, acceptStat uint32) *xdrWriter {
+	w := new(xdrWriter)
+	w.uint32(call.xid)
+	w.uint32(rpcReply)
+	w.uint32(msgAccepted)
+	w.uint32(authNone) // verifier
+	w.opaque(nil)
+	w.uint32(acceptStat)
+	return w
+}
+
+// newMismatchReply makes the reply to a call to a version of a
+// program which isn't supported
+func newMismatchReply(call *rpcCallMsg, version uint32) *xdrWriter {
+	w := newReply(call, acceptProgMismatch)
+	w.uint32(version)
+	w.uint32(version)
+	return w
+}
@@ -0,0 +1,235 @@
+package nfs
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/vfs"
+	"github.com/ncw/rclone/vfs/vfsflags"
+	"github.com/pkg/errors"
+)
+
+// RPC program numbers
+const (
+	nfsProgram   = 100003
+	mountProgram = 100005
+	nfsVersion   = 3
+	mountVersion = 3
+)
+
+// maxRequests is the maximum number of calls processed at once on
+// each connection
+const maxRequests = 16
+
+// openFileTimeout is how long files are held open after they were
+// last read or written
+const openFileTimeout = 5 * time.Second
+
+// procedure decodes the arguments of an RPC call from args and
+// writes the results to res, returning errGarbageArgs if the
+// arguments couldn't be decoded.
+type procedure func(s *server, args *xdrReader, res *xdrWriter) error
+
+// server contains everything to run the server
+type server struct {
+	f        fs.Fs
+	opt      Options
+	vfs      *vfs.VFS
+	handles  *handles
+	files    *openFiles
+	verf     [8]byte // write verifier which changes when the server restarts
+	listener net.Listener
+	waitChan chan struct{} // for waiting on the listener to close
+	mu       sync.Mutex    // protects the following variables
+	conns    map[net.Conn]struct{}
+	closed   bool
+	creates  map[string][]byte // verifiers of exclusive creates by path
+}
+
+// newServer makes a new server to serve f over NFS
+func newServer(f fs.Fs, opt *Options) (*server, error) {
+	s := &server{
+		f:        f,
+		opt:      *opt,
+		vfs:      vfs.New(f, &vfsflags.Opt),
+		waitChan: make(chan struct{}),
+		conns:    make(map[net.Conn]struct{}),
+		creates:  make(map[string][]byte),
+	}
+	root, err := s.vfs.Root()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read root directory")
+	}
+	s.files = newOpenFiles(s.vfs, openFileTimeout)
+	s.handles = newHandles(s.vfs, s.files, root, longPathsFile(f))
+	binary.BigEndian.PutUint64(s.verf[:], uint64(time.Now().UnixNano()))
+	return s, nil
+}
+
+// longPathsFile returns the file in --cache-dir the paths of the
+// handles made from hashes are saved in for f
+func longPathsFile(f fs.Fs) string {
+	sum := sha256.Sum256([]byte(f.Name() + ":" + f.Root()))
+	return filepath.Join(config.CacheDir, "serve-nfs", hex.EncodeToString(sum[:16])+".paths")
+}
+
+// Serve starts the server listening in the background
+//
+// Use s.Close() and s.Wait() to shut the server down
+func (s *server) Serve() (err error) {
+	s.listener, err = net.Listen("tcp", s.opt.ListenAddr)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen for connection")
+	}
+	fs.Logf(s.f, "NFS server listening on %v", s.listener.Addr())
+	go s.acceptConnections()
+	return nil
+}
+
+// Addr returns the address the server is listening on
+func (s *server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Wait blocks while the listener is open.
+func (s *server) Wait() {
+	<-s.waitChan
+}
+
+// Close shuts the running server down, closing any open files and
+// connections
+func (s *server) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	err := s.listener.Close()
+	if err != nil {
+		fs.Errorf(nil, "Error on closing NFS server: %v", err)
+	}
+	s.files.closeAll()
+	s.handles.close()
+	close(s.waitChan)
+}
+
+// acceptConnections serves each connection made to the listener
+func (s *server) acceptConnections() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if strings.Contains(err.Error(), "use of closed network connection") {
+				return
+			}
+			fs.Errorf(nil, "Failed to accept incoming connection: %v", err)
+			continue
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn reads RPC calls from conn and writes the replies back
+//
+// Calls are processed concurrently so the replies may be sent in a
+// different order to the calls, which RPC allows.
+func (s *server) serveConn(conn net.Conn) {
+	fs.Debugf(nil, "NFS connection from %v", conn.RemoteAddr())
+	var (
+		wg      sync.WaitGroup
+		writeMu sync.Mutex
+		limit   = make(chan struct{}, maxRequests)
+	)
+	defer func() {
+		wg.Wait()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+		fs.Debugf(nil, "NFS connection from %v closed", conn.RemoteAddr())
+	}()
+	for {
+		data, err := readRecord(conn)
+		if err != nil {
+			if err != io.EOF && !strings.Contains(err.Error(), "use of closed network connection") {
+				fs.Errorf(nil, "NFS connection from %v: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		limit <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-limit
+				wg.Done()
+			}()
+			reply := s.handleRecord(data)
+			if reply == nil {
+				return
+			}
+			writeMu.Lock()
+			err := writeRecord(conn, reply)
+			writeMu.Unlock()
+			if err != nil {
+				fs.Debugf(nil, "NFS connection from %v: failed to write reply: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// handleRecord processes the RPC call in data returning the reply
+// to send or nil for none
+func (s *server) handleRecord(data []byte) []byte {
+	call, reply, err := parseCall(data)
+	if err != nil {
+		fs.Debugf(nil, "NFS: %v", err)
+		if reply == nil {
+			return nil
+		}
+		return reply.Bytes()
+	}
+	var procs []procedure
+	var version uint32
+	switch call.prog {
+	case nfsProgram:
+		procs, version = nfsProcedures, nfsVersion
+	case mountProgram:
+		procs, version = mountProcedures, mountVersion
+	default:
+		return newReply(call, acceptProgUnavail).Bytes()
+	}
+	if call.vers != version {
+		return newMismatchReply(call, version).Bytes()
+	}
+	if int(call.proc) >= len(procs) || procs[call.proc] == nil {
+		return newReply(call, acceptProcUnavail).Bytes()
+	}
+	res := newReply(call, acceptSuccess)
+	err = procs[call.proc](s, call.args, res)
+	if err != nil {
+		fs.Debugf(nil, "NFS: program %d procedure %d: %v", call.prog, call.proc, err)
+		return newReply(call, acceptGarbageArgs).Bytes()
+	}
+	return res.Bytes()
+}
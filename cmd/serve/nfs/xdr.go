@@ -0,0 +1,145 @@
+// XDR encoding and decoding as described in RFC 4506
+
+package nfs
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// errGarbageArgs is returned when the arguments of a call can't be
+// decoded
+var errGarbageArgs = errors.New("can't decode arguments")
+
+// xdrReader decodes XDR data from a buffer
+//
+// The first error is remembered in err and all reads after it return
+// zero values, so the error only needs checking once all the values
+// have been read.
+type xdrReader struct {
+	buf []byte
+	err error
+}
+
+// newXDRReader makes a reader to decode buf
+func newXDRReader(buf []byte) *xdrReader {
+	return &xdrReader{buf: buf}
+}
+
+// next returns the next n bytes of the buffer or nil if there aren't
+// enough
+func (r *xdrReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.buf) {
+		r.err = errGarbageArgs
+		return nil
+	}
+	p := r.buf[:n]
+	r.buf = r.buf[n:]
+	return p
+}
+
+// uint32 reads an unsigned int
+func (r *xdrReader) uint32() uint32 {
+	p := r.next(4)
+	if p == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(p)
+}
+
+// uint64 reads an unsigned hyper
+func (r *xdrReader) uint64() uint64 {
+	p := r.next(8)
+	if p == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(p)
+}
+
+// bool reads a boolean
+func (r *xdrReader) bool() bool {
+	return r.uint32() != 0
+}
+
+// fixed reads fixed length opaque data of n bytes
+func (r *xdrReader) fixed(n int) []byte {
+	p := r.next(xdrPad(n))
+	if p == nil {
+		return nil
+	}
+	return p[:n]
+}
+
+// opaque reads variable length opaque data of at most max bytes
+func (r *xdrReader) opaque(max int) []byte {
+	n := r.uint32()
+	if r.err == nil && n > uint32(max) {
+		r.err = errGarbageArgs
+	}
+	if r.err != nil {
+		return nil
+	}
+	return r.fixed(int(n))
+}
+
+// string reads a string of at most max bytes
+func (r *xdrReader) string(max int) string {
+	return string(r.opaque(max))
+}
+
+// xdrPad returns n rounded up to a multiple of 4
+func xdrPad(n int) int {
+	return (n + 3) &^ 3
+}
+
+// xdrWriter encodes XDR data into a buffer
+type xdrWriter struct {
+	bytes.Buffer
+}
+
+// uint32 writes an unsigned int
+func (w *xdrWriter) uint32(v uint32) {
+	var p [4]byte
+	binary.BigEndian.PutUint32(p[:], v)
+	_, _ = w.Write(p[:])
+}
+
+// uint64 writes an unsigned hyper
+func (w *xdrWriter) uint64(v uint64) {
+	var p [8]byte
+	binary.BigEndian.PutUint64(p[:], v)
+	_, _ = w.Write(p[:])
+}
+
+// bool writes a boolean
+func (w *xdrWriter) bool(v bool) {
+	if v {
+		w.uint32(1)
+	} else {
+		w.uint32(0)
+	}
+}
+
+// fixed writes fixed length opaque data
+func (w *xdrWriter) fixed(p []byte) {
+	_, _ = w.Write(p)
+	for i := len(p); i < xdrPad(len(p)); i++ {
+		_ = w.WriteByte(0)
+	}
+}
+
+// opaque writes variable length opaque data
+func (w *xdrWriter) opaque(p []byte) {
+	w.uint32(uint32(len(p)))
+	w.fixed(p)
+}
+
+// string writes a string
+func (w *xdrWriter) string(s string) {
+	w.opaque([]byte(s))
+}
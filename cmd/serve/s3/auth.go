@@ -0,0 +1,445 @@
+// AWS Signature Version 4 authentication
+
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	signV4Algorithm      = "AWS4-HMAC-SHA256"
+	streamingAlgorithm   = "AWS4-HMAC-SHA256-PAYLOAD"
+	unsignedPayload      = "UNSIGNED-PAYLOAD"
+	streamingPayload     = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	amzDateFormat        = "20060102T150405Z"
+	maxClockSkew         = 15 * time.Minute
+	maxPresignExpiry     = 7 * 24 * time.Hour
+	maxStreamingChunk    = 16 * 1024 * 1024
+	emptyStringSHA256Hex = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// timeNow is the current time - can be overridden in tests
+var timeNow = time.Now
+
+// parseAuthKeys parses the --auth-key flags which are in the form
+// "accessKeyID,secretAccessKey" into a map of access key ID to secret
+func parseAuthKeys(authKeys []string) (map[string]string, error) {
+	keys := make(map[string]string, len(authKeys))
+	for _, authKey := range authKeys {
+		i := strings.IndexRune(authKey, ',')
+		if i <= 0 || i == len(authKey)-1 {
+			return nil, errors.Errorf("bad --auth-key %q: must be in the form accessKeyID,secretAccessKey", authKey)
+		}
+		keys[authKey[:i]] = authKey[i+1:]
+	}
+	return keys, nil
+}
+
+// signature describes the parsed signature of a request
+type signature struct {
+	accessKeyID   string
+	date          string // yyyymmdd from the credential scope
+	scope         string // date/region/service/aws4_request
+	signedHeaders []string
+	signature     string
+	amzDate       string // time of the request in amzDateFormat
+	payloadHash   string
+	presigned     bool
+}
+
+// parseCredential parses an AWS credential of the form
+// accessKeyID/date/region/service/aws4_request into sig
+func (sig *signature) parseCredential(credential string) error {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[4] != "aws4_request" || parts[0] == "" {
+		return errors.Errorf("malformed credential %q", credential)
+	}
+	sig.accessKeyID = parts[0]
+	sig.date = parts[1]
+	sig.scope = strings.Join(parts[1:], "/")
+	return nil
+}
+
+// parseAuthHeader parses the Authorization header of a request
+// signed with signature version 4
+func parseAuthHeader(r *http.Request, authHeader string) (*signature, error) {
+	sig := &signature{}
+	for _, field := range strings.Split(strings.TrimPrefix(authHeader, signV4Algorithm), ",") {
+		field = strings.TrimSpace(field)
+		i := strings.IndexRune(field, '=')
+		if i < 0 {
+			return nil, errors.Errorf("malformed authorization field %q", field)
+		}
+		key, value := field[:i], field[i+1:]
+		switch key {
+		case "Credential":
+			err := sig.parseCredential(value)
+			if err != nil {
+				return nil, err
+			}
+		case "SignedHeaders":
+			sig.signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			sig.signature = value
+		}
+	}
+	if sig.accessKeyID == "" || len(sig.signedHeaders) == 0 || sig.signature == "" {
+		return nil, errors.New("authorization header is missing fields")
+	}
+	sig.amzDate = r.Header.Get("X-Amz-Date")
+	if sig.amzDate == "" {
+		date, err := http.ParseTime(r.Header.Get("Date"))
+		if err != nil {
+			return nil, errors.New("request has no date")
+		}
+		sig.amzDate = date.UTC().Format(amzDateFormat)
+	}
+	sig.payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+	if sig.payloadHash == "" {
+		return nil, errMissingSecurityHeader
+	}
+	return sig, nil
+}
+
+// parsePresigned parses the query parameters of a presigned URL
+func parsePresigned(query url.Values) (*signature, error) {
+	sig := &signature{presigned: true}
+	err := sig.parseCredential(query.Get("X-Amz-Credential"))
+	if err != nil {
+		return nil, err
+	}
+	sig.signedHeaders = strings.Split(query.Get("X-Amz-SignedHeaders"), ";")
+	sig.signature = query.Get("X-Amz-Signature")
+	sig.amzDate = query.Get("X-Amz-Date")
+	sig.payloadHash = unsignedPayload
+	if sig.signature == "" || sig.amzDate == "" {
+		return nil, errors.New("presigned URL is missing parameters")
+	}
+	return sig, nil
+}
+
+// authenticate checks the signature of r against keys
+//
+// If the body of the request is signed then it replaces r.Body with a
+// reader which checks the signature as it is read.
+func authenticate(r *http.Request, keys map[string]string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	var (
+		sig        *signature
+		err        error
+		authHeader = r.Header.Get("Authorization")
+		query      = r.URL.Query()
+	)
+	switch {
+	case strings.HasPrefix(authHeader, signV4Algorithm+" "):
+		sig, err = parseAuthHeader(r, authHeader)
+	case query.Get("X-Amz-Algorithm") == signV4Algorithm:
+		sig, err = parsePresigned(query)
+	case authHeader == "" && query.Get("AWSAccessKeyId") == "":
+		return errAccessDenied
+	default:
+		return newError(http.StatusBadRequest, "InvalidRequest", "Only AWS Signature Version 4 is supported")
+	}
+	if err != nil {
+		if e, ok := err.(*s3Error); ok {
+			return e
+		}
+		return newError(http.StatusBadRequest, "AuthorizationHeaderMalformed", err.Error())
+	}
+	secret, ok := keys[sig.accessKeyID]
+	if !ok {
+		return errInvalidAccessKeyID
+	}
+
+	// Check the time of the request
+	when, err := time.Parse(amzDateFormat, sig.amzDate)
+	if err != nil || !strings.HasPrefix(sig.amzDate, sig.date) {
+		return newError(http.StatusBadRequest, "AuthorizationHeaderMalformed", "bad date in request")
+	}
+	now := timeNow()
+	if sig.presigned {
+		expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+		if err != nil || expires < 0 || time.Duration(expires)*time.Second > maxPresignExpiry {
+			return newError(http.StatusBadRequest, "AuthorizationQueryParametersError", "X-Amz-Expires is invalid")
+		}
+		if now.Before(when.Add(-maxClockSkew)) {
+			return errRequestTimeTooSkewed
+		}
+		if now.After(when.Add(time.Duration(expires) * time.Second)) {
+			return errExpiredToken
+		}
+	} else if d := now.Sub(when); d > maxClockSkew || d < -maxClockSkew {
+		return errRequestTimeTooSkewed
+	}
+
+	// Check the signature
+	key := signingKey(secret, sig.scope)
+	canonical := canonicalRequest(r, sig)
+	expected := hex.EncodeToString(hmacSHA256(key, stringToSign(sig.amzDate, sig.scope, canonical)))
+	if !hmac.Equal([]byte(expected), []byte(sig.signature)) {
+		return errSignatureDoesNotMatch
+	}
+
+	// Check the body as it is read if necessary
+	switch sig.payloadHash {
+	case unsignedPayload:
+	case streamingPayload:
+		decodedLength, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if err != nil {
+			return errMissingSecurityHeader
+		}
+		r.ContentLength = decodedLength
+		r.Body = newChunkedReader(r.Body, key, sig.amzDate, sig.scope, sig.signature)
+	default:
+		if strings.HasPrefix(sig.payloadHash, "STREAMING-") {
+			return errNotImplemented
+		}
+		r.Body = newHashCheckReader(r.Body, sig.payloadHash)
+	}
+	return nil
+}
+
+// canonicalRequest makes the canonical form of r used for signing
+func canonicalRequest(r *http.Request, sig *signature) string {
+	var buf strings.Builder
+	buf.WriteString(r.Method)
+	buf.WriteByte('\n')
+	buf.WriteString(uriEncode(r.URL.Path, false))
+	buf.WriteByte('\n')
+	buf.WriteString(canonicalQuery(r.URL.Query()))
+	buf.WriteByte('\n')
+	for _, name := range sig.signedHeaders {
+		buf.WriteString(name)
+		buf.WriteByte(':')
+		buf.WriteString(headerValue(r, name))
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	buf.WriteString(strings.Join(sig.signedHeaders, ";"))
+	buf.WriteByte('\n')
+	buf.WriteString(sig.payloadHash)
+	return buf.String()
+}
+
+// canonicalQuery makes the canonical form of the query string leaving
+// out the signature
+func canonicalQuery(query url.Values) string {
+	var params []string
+	for key, values := range query {
+		if key == "X-Amz-Signature" {
+			continue
+		}
+		for _, value := range values {
+			params = append(params, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// headerValue returns the canonical value of the header name in r
+func headerValue(r *http.Request, name string) string {
+	switch name {
+	case "host":
+		return r.Host
+	case "content-length":
+		// Go removes this from the headers
+		return strconv.FormatInt(r.ContentLength, 10)
+	}
+	values := r.Header[textproto.CanonicalMIMEHeaderKey(name)]
+	trimmed := make([]string, len(values))
+	for i, value := range values {
+		trimmed[i] = strings.Join(strings.Fields(value), " ")
+	}
+	return strings.Join(trimmed, ",")
+}
+
+// uriEncode encodes s as AWS requires in signatures, leaving "/"
+// alone unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			buf.WriteByte(c)
+		} else {
+			buf.WriteByte('%')
+			buf.WriteByte(hexDigits[c>>4])
+			buf.WriteByte(hexDigits[c&15])
+		}
+	}
+	return buf.String()
+}
+
+// stringToSign makes the string to sign from the canonical request
+func stringToSign(amzDate, scope, canonical string) []byte {
+	return []byte(signV4Algorithm + "\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical)))
+}
+
+// signingKey derives the signing key for the credential scope
+func signingKey(secret, scope string) []byte {
+	key := []byte("AWS4" + secret)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, []byte(part))
+	}
+	return key
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write(data)
+	return h.Sum(nil)
+}
+
+// sha256Hex returns the hex encoded SHA256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hashCheckReader checks the SHA256 of the data read matches the
+// expected hash when EOF is reached
+type hashCheckReader struct {
+	in       io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+// newHashCheckReader makes a reader which checks the SHA256 of in
+// is expected
+func newHashCheckReader(in io.ReadCloser, expected string) *hashCheckReader {
+	return &hashCheckReader{
+		in:       in,
+		hash:     sha256.New(),
+		expected: strings.ToLower(expected),
+	}
+}
+
+// Read bytes checking the hash at EOF
+func (h *hashCheckReader) Read(p []byte) (n int, err error) {
+	n, err = h.in.Read(p)
+	_, _ = h.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(h.hash.Sum(nil)) != h.expected {
+		err = errContentSHA256Mismatch
+	}
+	return n, err
+}
+
+// Close the underlying reader
+func (h *hashCheckReader) Close() error {
+	return h.in.Close()
+}
+
+// chunkedReader decodes a body sent with aws-chunked encoding,
+// checking the signature of each chunk.
+//
+// Each chunk looks like
+//
+//     hex(size);chunk-signature=signature\r\n
+//     data\r\n
+//
+// and the body is terminated by a chunk of size 0.
+type chunkedReader struct {
+	in      io.ReadCloser
+	r       *bufio.Reader
+	key     []byte
+	amzDate string
+	scope   string
+	prevSig string
+	buf     []byte // buffer for reading chunks
+	chunk   []byte // unread data from the current chunk
+	err     error  // sticky error
+}
+
+// newChunkedReader makes a reader to decode aws-chunked data from in
+// which was signed with key starting with seedSignature
+func newChunkedReader(in io.ReadCloser, key []byte, amzDate, scope, seedSignature string) *chunkedReader {
+	return &chunkedReader{
+		in:      in,
+		r:       bufio.NewReader(in),
+		key:     key,
+		amzDate: amzDate,
+		scope:   scope,
+		prevSig: seedSignature,
+	}
+}
+
+// readChunk reads the next chunk and checks its signature
+func (c *chunkedReader) readChunk() error {
+	rawLine, err := c.r.ReadSlice('\n')
+	if err != nil {
+		return errIncompleteBody
+	}
+	line := strings.TrimSuffix(string(rawLine), "\r\n")
+	i := strings.Index(line, ";chunk-signature=")
+	if i < 0 {
+		return errors.Wrap(errIncompleteBody, "malformed chunk header")
+	}
+	size, err := strconv.ParseInt(line[:i], 16, 64)
+	if err != nil || size < 0 || size > maxStreamingChunk {
+		return errors.Wrap(errIncompleteBody, "bad chunk size")
+	}
+	chunkSig := line[i+len(";chunk-signature="):]
+	if cap(c.buf) < int(size) {
+		c.buf = make([]byte, size)
+	}
+	data := c.buf[:size]
+	_, err = io.ReadFull(c.r, data)
+	if err != nil {
+		return errIncompleteBody
+	}
+	var crlf [2]byte
+	_, err = io.ReadFull(c.r, crlf[:])
+	if err != nil || !bytes.Equal(crlf[:], []byte("\r\n")) {
+		return errIncompleteBody
+	}
+	toSign := streamingAlgorithm + "\n" + c.amzDate + "\n" + c.scope + "\n" + c.prevSig + "\n" + emptyStringSHA256Hex + "\n" + sha256Hex(data)
+	expected := hex.EncodeToString(hmacSHA256(c.key, []byte(toSign)))
+	if !hmac.Equal([]byte(expected), []byte(chunkSig)) {
+		return errSignatureDoesNotMatch
+	}
+	c.prevSig = chunkSig
+	if size == 0 {
+		return io.EOF
+	}
+	c.chunk = data
+	return nil
+}
+
+// Read decoded data
+func (c *chunkedReader) Read(p []byte) (n int, err error) {
+	for len(c.chunk) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		c.err = c.readChunk()
+	}
+	n = copy(p, c.chunk)
+	c.chunk = c.chunk[n:]
+	return n, nil
+}
+
+// Close the underlying reader
+func (c *chunkedReader) Close() error {
+	return c.in.Close()
+}
@@ -0,0 +1,70 @@
+package s3
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAuthKeys(t *testing.T) {
+	keys, err := parseAuthKeys([]string{"a,b", "c,d,e"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "b", "c": "d,e"}, keys)
+	for _, bad := range []string{"", "a", ",b", "a,"} {
+		_, err = parseAuthKeys([]string{bad})
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestURIEncode(t *testing.T) {
+	assert.Equal(t, "/bucket/a%20file%2Bplus~_-.txt", uriEncode("/bucket/a file+plus~_-.txt", false))
+	assert.Equal(t, "a%2Fb", uriEncode("a/b", true))
+}
+
+// Example from the AWS documentation for signing chunked uploads
+//
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
+func TestChunkedReader(t *testing.T) {
+	const (
+		amzDate = "20130524T000000Z"
+		scope   = "20130524/us-east-1/s3/aws4_request"
+		seed    = "4f232c4386841ef735655705268965c44a0e4690baa4adea153f7db9fa80a0a9"
+	)
+	key := signingKey("wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY", scope)
+	chunk1 := strings.Repeat("a", 65536)
+	chunk2 := strings.Repeat("a", 1024)
+	body := "10000;chunk-signature=ad80c730a21e5b8d04586a2213dd63b9a0e99e0e2307b0ade35a65485a288648\r\n" + chunk1 + "\r\n" +
+		"400;chunk-signature=0055627c9e194cb4542bae2aa5492e3c1575bbb81b612b7d234b86a503ef5497\r\n" + chunk2 + "\r\n" +
+		"0;chunk-signature=b6c6ea8a5354eaf15b3cb7646744f4275b71ea724fed81ceb9323e279d449df9\r\n\r\n"
+
+	in := ioutil.NopCloser(strings.NewReader(body))
+	got, err := ioutil.ReadAll(newChunkedReader(in, key, amzDate, scope, seed))
+	require.NoError(t, err)
+	assert.Equal(t, chunk1+chunk2, string(got))
+
+	// Corrupt the data in the second chunk
+	bad := []byte(body)
+	bad[len(body)-200] = 'b'
+	in = ioutil.NopCloser(bytes.NewReader(bad))
+	got, err = ioutil.ReadAll(newChunkedReader(in, key, amzDate, scope, seed))
+	assert.Equal(t, errSignatureDoesNotMatch, err)
+	assert.Equal(t, chunk1, string(got))
+
+	// Truncate the body
+	in = ioutil.NopCloser(strings.NewReader(body[:1000]))
+	_, err = ioutil.ReadAll(newChunkedReader(in, key, amzDate, scope, seed))
+	assert.Equal(t, errIncompleteBody, err)
+}
+
+func TestHashCheckReader(t *testing.T) {
+	in := ioutil.NopCloser(strings.NewReader(""))
+	_, err := ioutil.ReadAll(newHashCheckReader(in, emptyStringSHA256Hex))
+	assert.NoError(t, err)
+	in = ioutil.NopCloser(strings.NewReader("x"))
+	_, err = ioutil.ReadAll(newHashCheckReader(in, emptyStringSHA256Hex))
+	assert.Equal(t, errContentSHA256Mismatch, err)
+}
@@ -0,0 +1,227 @@
+// Listing objects in buckets
+
+package s3
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ncw/rclone/vfs"
+)
+
+// maxListKeys is the maximum number of keys returned in a listing
+const maxListKeys = 1000
+
+// listEntry is an object or a common prefix found when listing
+type listEntry struct {
+	key  string
+	node vfs.Node // nil for a common prefix
+}
+
+// walk calls fn for every file under dir recursively
+func walk(dir *vfs.Dir, fn func(node vfs.Node)) error {
+	nodes, err := dir.ReadDirAll()
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if node.IsDir() {
+			err = walk(node.(*vfs.Dir), fn)
+			if err != nil {
+				return err
+			}
+		} else {
+			fn(node)
+		}
+	}
+	return nil
+}
+
+// listEntries returns the objects and common prefixes in bucket
+// which start with prefix, sorted by key.
+//
+// Objects with the delimiter in their key after the prefix are rolled
+// up into common prefixes.
+func (s *server) listEntries(bucket, prefix, delimiter string) (entries []listEntry, err error) {
+	bucketDir, err := s.bucketDir(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the directory containing the prefix - nothing can match
+	// if it doesn't exist
+	dir := bucketDir
+	dirPrefix := prefix[:strings.LastIndex(prefix, "/")+1]
+	if dirPrefix != "" {
+		if !validKey(dirPrefix) {
+			return nil, nil
+		}
+		node, err := s.vfs.Stat(path.Join(bucket, dirPrefix))
+		if err == vfs.ENOENT || (err == nil && !node.IsDir()) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		dir = node.(*vfs.Dir)
+	}
+	keyOf := func(node vfs.Node) string {
+		return strings.TrimPrefix(node.Path(), bucket+"/")
+	}
+
+	if delimiter == "/" {
+		// Only need to read one directory as directories are
+		// the common prefixes
+		nodes, err := dir.ReadDirAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			if isUploading(node) {
+				continue
+			}
+			key := keyOf(node)
+			if node.IsDir() {
+				key += "/"
+				node = nil
+			}
+			if strings.HasPrefix(key, prefix) {
+				entries = append(entries, listEntry{key: key, node: node})
+			}
+		}
+	} else {
+		seen := map[string]bool{}
+		err = walk(dir, func(node vfs.Node) {
+			key := keyOf(node)
+			if isUploading(node) || !strings.HasPrefix(key, prefix) {
+				return
+			}
+			if delimiter != "" {
+				if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+					commonPrefix := key[:len(prefix)+i+len(delimiter)]
+					if !seen[commonPrefix] {
+						seen[commonPrefix] = true
+						entries = append(entries, listEntry{key: commonPrefix})
+					}
+					return
+				}
+			}
+			entries = append(entries, listEntry{key: key, node: node})
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	return entries, nil
+}
+
+// encodeKey encodes key for the listing if encoding-type=url was
+// requested
+func encodeKey(key string, encodingType string) string {
+	if encodingType != "url" {
+		return key
+	}
+	segments := strings.Split(key, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	return strings.Join(segments, "/")
+}
+
+// listObjects lists the objects in bucket for ListObjects and
+// ListObjectsV2
+func (s *server) listObjects(w http.ResponseWriter, r *http.Request, bucket string, query url.Values) error {
+	var (
+		prefix       = query.Get("prefix")
+		delimiter    = query.Get("delimiter")
+		encodingType = query.Get("encoding-type")
+		isV2         = query.Get("list-type") == "2"
+		maxKeys      = maxListKeys
+		start        string // list keys after this
+	)
+	if encodingType != "" && encodingType != "url" {
+		return newError(http.StatusBadRequest, "InvalidArgument", "Invalid Encoding Method specified in Request")
+	}
+	if maxKeysString := query.Get("max-keys"); maxKeysString != "" {
+		n, err := strconv.Atoi(maxKeysString)
+		if err != nil || n < 0 {
+			return newError(http.StatusBadRequest, "InvalidArgument", "max-keys must be a non negative integer")
+		}
+		if n < maxKeys {
+			maxKeys = n
+		}
+	}
+	result := ListBucketResult{
+		Xmlns:        xmlns,
+		Name:         bucket,
+		Prefix:       encodeKey(prefix, encodingType),
+		Delimiter:    encodeKey(delimiter, encodingType),
+		EncodingType: encodingType,
+		MaxKeys:      maxKeys,
+	}
+	if isV2 {
+		result.StartAfter = encodeKey(query.Get("start-after"), encodingType)
+		start = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			decoded, err := base64.URLEncoding.DecodeString(token)
+			if err != nil {
+				return newError(http.StatusBadRequest, "InvalidArgument", "The continuation token provided is incorrect")
+			}
+			result.ContinuationToken = token
+			start = string(decoded)
+		}
+	} else {
+		start = query.Get("marker")
+		marker := encodeKey(start, encodingType)
+		result.Marker = &marker
+	}
+
+	entries, err := s.listEntries(bucket, prefix, delimiter)
+	if err != nil {
+		return err
+	}
+	if start != "" {
+		i := sort.Search(len(entries), func(i int) bool {
+			return entries[i].key > start
+		})
+		entries = entries[i:]
+	}
+	if len(entries) > maxKeys {
+		entries = entries[:maxKeys]
+		result.IsTruncated = true
+		last := entries[len(entries)-1].key
+		if isV2 {
+			result.NextContinuationToken = base64.URLEncoding.EncodeToString([]byte(last))
+		} else {
+			result.NextMarker = encodeKey(last, encodingType)
+		}
+	}
+	for _, entry := range entries {
+		if entry.node == nil {
+			result.CommonPrefixes = append(result.CommonPrefixes, CommonPrefix{
+				Prefix: encodeKey(entry.key, encodingType),
+			})
+			continue
+		}
+		result.Contents = append(result.Contents, Content{
+			Key:          encodeKey(entry.key, encodingType),
+			LastModified: s3Time(entry.node.ModTime()),
+			ETag:         s.etag(r.Context(), entry.node),
+			Size:         entry.node.Size(),
+			StorageClass: "STANDARD",
+		})
+	}
+	if isV2 {
+		keyCount := len(entries)
+		result.KeyCount = &keyCount
+	}
+	writeXML(w, http.StatusOK, result)
+	return nil
+}
@@ -0,0 +1,289 @@
+// Multipart uploads
+
+package s3
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/pkg/errors"
+)
+
+// maxPartNumber is the largest part number allowed
+const maxPartNumber = 10000
+
+// upload is a multipart upload in progress
+//
+// The parts are stored in a temporary directory until the upload is
+// completed when they are joined together and written to the VFS.
+type upload struct {
+	mu         sync.Mutex
+	bucket     string
+	key        string
+	modTime    time.Time      // modification time to set if setModTime
+	setModTime bool           // set if the client supplied a modification time
+	dir        string         // temporary directory holding the parts
+	parts      map[int]string // part number to hex MD5 of the part
+}
+
+// partPath returns the path of the temporary file for part n
+func (u *upload) partPath(n int) string {
+	return filepath.Join(u.dir, strconv.Itoa(n))
+}
+
+// uploads holds the multipart uploads in progress
+type uploads struct {
+	mu      sync.Mutex
+	dir     string             // temporary directory for all the uploads
+	uploads map[string]*upload // upload ID to upload
+}
+
+// newUploads makes a new uploads
+func newUploads() (*uploads, error) {
+	dir, err := ioutil.TempDir("", "rclone-serve-s3-")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make temporary directory for multipart uploads")
+	}
+	return &uploads{
+		dir:     dir,
+		uploads: make(map[string]*upload),
+	}, nil
+}
+
+// create starts a new upload returning its ID
+func (us *uploads) create(bucket, key string, modTime time.Time, setModTime bool) (string, error) {
+	var id [16]byte
+	_, err := io.ReadFull(rand.Reader, id[:])
+	if err != nil {
+		return "", err
+	}
+	uploadID := hex.EncodeToString(id[:])
+	dir := filepath.Join(us.dir, uploadID)
+	err = os.Mkdir(dir, 0700)
+	if err != nil {
+		return "", err
+	}
+	us.mu.Lock()
+	us.uploads[uploadID] = &upload{
+		bucket:     bucket,
+		key:        key,
+		modTime:    modTime,
+		setModTime: setModTime,
+		dir:        dir,
+		parts:      make(map[int]string),
+	}
+	us.mu.Unlock()
+	return uploadID, nil
+}
+
+// get finds the upload with uploadID for bucket and key
+func (us *uploads) get(bucket, key, uploadID string) (*upload, error) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	u, ok := us.uploads[uploadID]
+	if !ok || u.bucket != bucket || u.key != key {
+		return nil, errNoSuchUpload
+	}
+	return u, nil
+}
+
+// remove removes the upload with uploadID and its parts
+func (us *uploads) remove(uploadID string) {
+	us.mu.Lock()
+	u, ok := us.uploads[uploadID]
+	delete(us.uploads, uploadID)
+	us.mu.Unlock()
+	if !ok {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	err := os.RemoveAll(u.dir)
+	if err != nil {
+		fs.Errorf(nil, "Failed to remove multipart upload %q: %v", uploadID, err)
+	}
+}
+
+// abortAll removes all the uploads
+func (us *uploads) abortAll() {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	us.uploads = make(map[string]*upload)
+	err := os.RemoveAll(us.dir)
+	if err != nil {
+		fs.Errorf(nil, "Failed to remove multipart uploads: %v", err)
+	}
+}
+
+// createMultipartUpload starts a multipart upload
+func (s *server) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	_, err := s.bucketDir(bucket)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(key, "/") {
+		return newError(http.StatusBadRequest, "InvalidArgument", "Directory markers can't have content")
+	}
+	modTime, setModTime := modTimeFromHeaders(r)
+	uploadID, err := s.uploads.create(bucket, key, modTime, setModTime)
+	if err != nil {
+		return err
+	}
+	writeXML(w, http.StatusOK, InitiateMultipartUploadResult{
+		Xmlns:    xmlns,
+		Bucket:   bucket,
+		Key:      key,
+		UploadID: uploadID,
+	})
+	return nil
+}
+
+// uploadPart stores a part of a multipart upload
+func (s *server) uploadPart(w http.ResponseWriter, r *http.Request, bucket, key, uploadID, partNumber string) error {
+	n, err := strconv.Atoi(partNumber)
+	if err != nil || n < 1 || n > maxPartNumber {
+		return newError(http.StatusBadRequest, "InvalidArgument", fmt.Sprintf("Part number must be an integer between 1 and %d, inclusive", maxPartNumber))
+	}
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		return errNotImplemented
+	}
+	u, err := s.uploads.get(bucket, key, uploadID)
+	if err != nil {
+		return err
+	}
+
+	// Write the part to a temporary file then rename it into place
+	// so a part being uploaded again doesn't spoil the first one
+	tmp, err := ioutil.TempFile(u.dir, "part-")
+	if err != nil {
+		return err
+	}
+	hasher := md5.New()
+	_, err = io.Copy(tmp, io.TeeReader(r.Body, hasher))
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	md5sum := hex.EncodeToString(hasher.Sum(nil))
+	if err == nil {
+		err = checkContentMD5(r, md5sum)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	err = os.Rename(tmp.Name(), u.partPath(n))
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		if os.IsNotExist(err) {
+			// the upload was aborted while uploading the part
+			return errNoSuchUpload
+		}
+		return err
+	}
+	u.parts[n] = md5sum
+	w.Header().Set("ETag", `"`+md5sum+`"`)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// completeMultipartUpload joins the parts listed in the request
+// together to make the object
+func (s *server) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key, uploadID string) error {
+	u, err := s.uploads.get(bucket, key, uploadID)
+	if err != nil {
+		return err
+	}
+	var req CompleteMultipartUpload
+	err = xml.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		if e, ok := errors.Cause(err).(*s3Error); ok {
+			return e
+		}
+		return errMalformedXML
+	}
+	if len(req.Parts) == 0 {
+		return errMalformedXML
+	}
+
+	// Check the parts and open them
+	u.mu.Lock()
+	var (
+		readers  []io.Reader
+		files    []*os.File
+		lastPart = 0
+	)
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+	for _, part := range req.Parts {
+		if part.PartNumber <= lastPart {
+			u.mu.Unlock()
+			return errInvalidPartOrder
+		}
+		lastPart = part.PartNumber
+		md5sum, ok := u.parts[part.PartNumber]
+		if !ok || strings.Trim(part.ETag, `"`) != md5sum {
+			u.mu.Unlock()
+			return errInvalidPart
+		}
+		f, err := os.Open(u.partPath(part.PartNumber))
+		if err != nil {
+			u.mu.Unlock()
+			return err
+		}
+		files = append(files, f)
+		readers = append(readers, f)
+	}
+	u.mu.Unlock()
+
+	// Write the object
+	remote := path.Join(bucket, key)
+	accounting.GlobalStats().Transferring(remote)
+	md5sum, err := s.writeFile(remote, io.MultiReader(readers...), u.modTime, u.setModTime, nil)
+	accounting.GlobalStats().DoneTransferring(remote, err == nil)
+	if err != nil {
+		return err
+	}
+	s.uploads.remove(uploadID)
+
+	writeXML(w, http.StatusOK, CompleteMultipartUploadResult{
+		Xmlns:    xmlns,
+		Location: "/" + remote,
+		Bucket:   bucket,
+		Key:      key,
+		ETag:     s.uploadETag(r.Context(), remote, md5sum),
+	})
+	return nil
+}
+
+// abortMultipartUpload discards a multipart upload
+func (s *server) abortMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key, uploadID string) error {
+	_, err := s.uploads.get(bucket, key, uploadID)
+	if err != nil {
+		return err
+	}
+	s.uploads.remove(uploadID)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
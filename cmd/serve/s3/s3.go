@@ -0,0 +1,130 @@
+// Package s3 implements an S3 compatible server to serve an rclone VFS
+package s3
+
+import (
+	"github.com/ncw/rclone/cmd"
+	"github.com/ncw/rclone/cmd/serve/httplib"
+	"github.com/ncw/rclone/cmd/serve/httplib/httpflags"
+	"github.com/ncw/rclone/fs/config/flags"
+	"github.com/ncw/rclone/lib/atexit"
+	"github.com/ncw/rclone/vfs"
+	"github.com/ncw/rclone/vfs/vfsflags"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	authKeys []string
+)
+
+func init() {
+	httpflags.AddFlags(Command.Flags())
+	vfsflags.AddFlags(Command.Flags())
+	flags.StringArrayVarP(Command.Flags(), &authKeys, "auth-key", "", nil, "Set key pair for v4 authorization, split by comma")
+}
+
+// Command definition for cobra
+var Command = &cobra.Command{
+	Use:   "s3 remote:path",
+	Short: `Serve remote:path over s3.`,
+	Long: `rclone serve s3 implements a basic S3 server which serves the remote
+over the S3 protocol.  This can be used with S3 clients such as the
+aws cli, or you can make a remote of type s3 to use with it.
+
+Each directory in the root of remote:path is served as a bucket and
+the files within it as objects.  Objects with "/" in their keys are
+stored in subdirectories.  Empty directories aren't shown in listings
+(as on S3) and directories left empty when an object is deleted are
+removed.
+
+The server supports these operations
+
+  - ListBuckets, CreateBucket, HeadBucket, DeleteBucket, GetBucketLocation
+  - ListObjects, ListObjectsV2
+  - GetObject (including Range requests), HeadObject
+  - PutObject, CopyObject, DeleteObject, DeleteObjects
+  - CreateMultipartUpload, UploadPart, CompleteMultipartUpload, AbortMultipartUpload
+
+Only path style requests (eg http://host:port/bucket/key) are
+supported, so clients must be configured to use them, eg with
+force_path_style = true in an rclone s3 remote.
+
+Parts of multipart uploads are stored in the temporary directory until
+the upload is completed.
+
+You can use the filter flags (eg --include, --exclude) to control what
+is served.
+
+The server will log errors.  Use -v to see access logs.
+
+--bwlimit will be respected for file transfers.  Use --stats to
+control the stats printing.
+
+### S3 options
+
+#### --auth-key
+
+Use --auth-key accessKey,secretKey to set the key pair clients must
+sign their requests with.  Requests are authenticated with AWS
+Signature Version 4, either in the Authorization header or in a
+presigned URL.  Use --auth-key multiple times to allow more than one
+key pair.
+
+If no --auth-key is given then anyone can access the server.  The
+--user, --pass and --htpasswd flags can't be used as S3 clients can't
+send HTTP basic authentication.
+
+#### ETags
+
+The ETag of an object is its MD5 hash if the remote supports MD5
+hashes, otherwise it is made from the modification time and size of
+the object.  Unlike AWS S3 this is also true of objects uploaded with
+a multipart upload.
+
+Uploads are written to a temporary name in the same directory and
+renamed into place once they have been checked, so a failed upload
+doesn't overwrite an existing object.  If the remote can't move or
+copy objects then uploads are written in place.
+
+Objects uploaded with an X-Amz-Meta-Mtime header (as rclone does) have
+their modification time set from it, and it is returned when they are
+read.
+
+An rclone s3 remote to use with this server might look like this
+
+    [serves3]
+    type = s3
+    provider = Other
+    access_key_id = accessKey
+    secret_access_key = secretKey
+    endpoint = http://127.0.0.1:8080/
+    force_path_style = true
+
+` + httplib.Help + vfs.Help,
+	RunE: func(command *cobra.Command, args []string) error {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsSrc(args)
+		opt := httpflags.Opt
+		if opt.BasicUser != "" || opt.HtPasswd != "" {
+			return errors.New("use --auth-key for authentication not --user, --pass or --htpasswd")
+		}
+		keys, err := parseAuthKeys(authKeys)
+		if err != nil {
+			return err
+		}
+		cmd.Run(false, false, command, func() error {
+			s, err := newServer(f, &opt, keys)
+			if err != nil {
+				return err
+			}
+			atexit.Register(s.uploads.abortAll)
+			err = s.Serve()
+			if err != nil {
+				return err
+			}
+			s.Wait()
+			return nil
+		})
+		return nil
+	},
+}
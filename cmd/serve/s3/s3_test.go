@@ -0,0 +1,387 @@
+// Serve s3 tests set up a server and exercise it with the AWS SDK
+
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	_ "github.com/ncw/rclone/backend/local"
+	"github.com/ncw/rclone/cmd/serve/httplib"
+	"github.com/ncw/rclone/fs"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBindAddress = "localhost:0"
+	testAccessKey   = "access"
+	testSecretKey   = "secret"
+)
+
+// startServer starts a server serving a temporary directory and
+// returns a client for it with the credentials given
+func startServer(t *testing.T) (dir string, newClient func(accessKey, secretKey string) *awss3.S3, finalise func()) {
+	dir, err := ioutil.TempDir("", "rclone-serve-s3-test")
+	require.NoError(t, err)
+	f, err := fs.NewFs(dir)
+	require.NoError(t, err)
+
+	opt := httplib.DefaultOpt
+	opt.ListenAddr = testBindAddress
+	s, err := newServer(f, &opt, map[string]string{testAccessKey: testSecretKey})
+	require.NoError(t, err)
+	require.NoError(t, s.Serve())
+
+	newClient = func(accessKey, secretKey string) *awss3.S3 {
+		ses, err := session.NewSession(&aws.Config{
+			Credentials:      credentials.NewStaticCredentials(accessKey, secretKey, ""),
+			Endpoint:         aws.String(s.URL()),
+			Region:           aws.String("us-east-1"),
+			S3ForcePathStyle: aws.Bool(true),
+			DisableSSL:       aws.Bool(true),
+		})
+		require.NoError(t, err)
+		return awss3.New(ses)
+	}
+	finalise = func() {
+		s.Close()
+		s.Wait()
+		require.NoError(t, os.RemoveAll(dir))
+	}
+	return dir, newClient, finalise
+}
+
+// httpGet reads url returning an error if the status isn't 200
+func httpGet(url string) (string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer fs.CheckClose(resp.Body, &err)
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("bad status %s: %s", resp.Status, data)
+	}
+	return string(data), nil
+}
+
+// md5Hex returns the hex MD5 of data
+func md5Hex(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// errorCode returns the S3 error code of err or "" if it isn't one
+func errorCode(err error) string {
+	if e, ok := err.(awserr.Error); ok {
+		return e.Code()
+	}
+	return ""
+}
+
+func TestServeS3(t *testing.T) {
+	_, newClient, finalise := startServer(t)
+	defer finalise()
+	c := newClient(testAccessKey, testSecretKey)
+
+	// Make a bucket
+	_, err := c.CreateBucket(&awss3.CreateBucketInput{Bucket: aws.String("bucket")})
+	require.NoError(t, err)
+	_, err = c.HeadBucket(&awss3.HeadBucketInput{Bucket: aws.String("bucket")})
+	require.NoError(t, err)
+	buckets, err := c.ListBuckets(&awss3.ListBucketsInput{})
+	require.NoError(t, err)
+	require.Len(t, buckets.Buckets, 1)
+	assert.Equal(t, "bucket", *buckets.Buckets[0].Name)
+
+	// Put some objects
+	modTime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	contents := map[string]string{
+		"file.txt":        "hello world",
+		"dir/one.txt":     "one",
+		"dir/sub/two.txt": "two",
+		"other/three.txt": "three",
+	}
+	for key, content := range contents {
+		out, err := c.PutObject(&awss3.PutObjectInput{
+			Bucket:   aws.String("bucket"),
+			Key:      aws.String(key),
+			Body:     strings.NewReader(content),
+			Metadata: map[string]*string{"Mtime": aws.String("981173106.0")},
+		})
+		require.NoError(t, err, key)
+		assert.Equal(t, `"`+md5Hex([]byte(content))+`"`, *out.ETag, key)
+	}
+
+	// Check the metadata
+	head, err := c.HeadObject(&awss3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("file.txt")})
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), *head.ContentLength)
+	assert.Equal(t, `"`+md5Hex([]byte("hello world"))+`"`, *head.ETag)
+	assert.True(t, modTime.Equal(*head.LastModified), head.LastModified.String())
+	_, err = c.HeadObject(&awss3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("missing")})
+	assert.Error(t, err)
+
+	// Read with a Range
+	get, err := c.GetObject(&awss3.GetObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("file.txt"),
+		Range:  aws.String("bytes=6-"),
+	})
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(get.Body)
+	require.NoError(t, err)
+	require.NoError(t, get.Body.Close())
+	assert.Equal(t, "world", string(data))
+	_, err = c.GetObject(&awss3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("dir")})
+	assert.Equal(t, "NoSuchKey", errorCode(err))
+	_, err = c.GetObject(&awss3.GetObjectInput{Bucket: aws.String("missing"), Key: aws.String("file.txt")})
+	assert.Equal(t, "NoSuchBucket", errorCode(err))
+
+	// List with a delimiter
+	list, err := c.ListObjectsV2(&awss3.ListObjectsV2Input{
+		Bucket:    aws.String("bucket"),
+		Delimiter: aws.String("/"),
+	})
+	require.NoError(t, err)
+	require.Len(t, list.Contents, 1)
+	assert.Equal(t, "file.txt", *list.Contents[0].Key)
+	assert.Equal(t, int64(11), *list.Contents[0].Size)
+	require.Len(t, list.CommonPrefixes, 2)
+	assert.Equal(t, "dir/", *list.CommonPrefixes[0].Prefix)
+	assert.Equal(t, "other/", *list.CommonPrefixes[1].Prefix)
+
+	// List recursively with a prefix a page at a time
+	var keys []string
+	err = c.ListObjectsV2Pages(&awss3.ListObjectsV2Input{
+		Bucket:  aws.String("bucket"),
+		Prefix:  aws.String("d"),
+		MaxKeys: aws.Int64(1),
+	}, func(page *awss3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			keys = append(keys, *object.Key)
+		}
+		return true
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir/one.txt", "dir/sub/two.txt"}, keys)
+
+	// List with ListObjects (V1) and a delimiter other than "/"
+	listV1, err := c.ListObjects(&awss3.ListObjectsInput{
+		Bucket:    aws.String("bucket"),
+		Delimiter: aws.String("e"),
+	})
+	require.NoError(t, err)
+	require.Len(t, listV1.CommonPrefixes, 3)
+	assert.Equal(t, "dir/one", *listV1.CommonPrefixes[0].Prefix)
+	assert.Equal(t, "dir/sub/two.txt", *listV1.Contents[0].Key)
+
+	// Copy an object
+	_, err = c.CopyObject(&awss3.CopyObjectInput{
+		Bucket:     aws.String("bucket"),
+		Key:        aws.String("copy/file.txt"),
+		CopySource: aws.String("bucket/file.txt"),
+	})
+	require.NoError(t, err)
+	head, err = c.HeadObject(&awss3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("copy/file.txt")})
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), *head.ContentLength)
+	assert.True(t, modTime.Equal(*head.LastModified), head.LastModified.String())
+
+	// Delete objects
+	_, err = c.DeleteObject(&awss3.DeleteObjectInput{Bucket: aws.String("bucket"), Key: aws.String("copy/file.txt")})
+	require.NoError(t, err)
+	del, err := c.DeleteObjects(&awss3.DeleteObjectsInput{
+		Bucket: aws.String("bucket"),
+		Delete: &awss3.Delete{Objects: []*awss3.ObjectIdentifier{
+			{Key: aws.String("dir/one.txt")},
+			{Key: aws.String("dir/sub/two.txt")},
+			{Key: aws.String("missing")},
+		}},
+	})
+	require.NoError(t, err)
+	assert.Len(t, del.Deleted, 3)
+	assert.Len(t, del.Errors, 0)
+
+	// Check the prefixes have gone with their objects
+	list, err = c.ListObjectsV2(&awss3.ListObjectsV2Input{
+		Bucket:    aws.String("bucket"),
+		Delimiter: aws.String("/"),
+	})
+	require.NoError(t, err)
+	require.Len(t, list.CommonPrefixes, 1)
+	assert.Equal(t, "other/", *list.CommonPrefixes[0].Prefix)
+
+	// Can't delete a bucket with objects in
+	_, err = c.DeleteBucket(&awss3.DeleteBucketInput{Bucket: aws.String("bucket")})
+	assert.Equal(t, "BucketNotEmpty", errorCode(err))
+}
+
+func TestServeS3Multipart(t *testing.T) {
+	_, newClient, finalise := startServer(t)
+	defer finalise()
+	c := newClient(testAccessKey, testSecretKey)
+
+	_, err := c.CreateBucket(&awss3.CreateBucketInput{Bucket: aws.String("bucket")})
+	require.NoError(t, err)
+
+	// Upload in 3 parts
+	data := make([]byte, 2*s3manager.MinUploadPartSize+1234)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	uploader := s3manager.NewUploaderWithClient(c, func(u *s3manager.Uploader) {
+		u.PartSize = s3manager.MinUploadPartSize
+		u.Concurrency = 2
+	})
+	_, err = uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("dir/big"),
+		Body:   bytes.NewReader(data),
+	})
+	require.NoError(t, err)
+
+	get, err := c.GetObject(&awss3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("dir/big")})
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(get.Body)
+	require.NoError(t, err)
+	require.NoError(t, get.Body.Close())
+	assert.Equal(t, md5Hex(data), md5Hex(got))
+
+	// The ETag returned on completion should be the same as HEAD
+	// returns
+	create, err := c.CreateMultipartUpload(&awss3.CreateMultipartUploadInput{Bucket: aws.String("bucket"), Key: aws.String("parts")})
+	require.NoError(t, err)
+	var parts []*awss3.CompletedPart
+	for i, part := range []string{"hello ", "world"} {
+		out, err := c.UploadPart(&awss3.UploadPartInput{
+			Bucket:     aws.String("bucket"),
+			Key:        aws.String("parts"),
+			UploadId:   create.UploadId,
+			PartNumber: aws.Int64(int64(i + 1)),
+			Body:       strings.NewReader(part),
+		})
+		require.NoError(t, err)
+		parts = append(parts, &awss3.CompletedPart{ETag: out.ETag, PartNumber: aws.Int64(int64(i + 1))})
+	}
+	complete, err := c.CompleteMultipartUpload(&awss3.CompleteMultipartUploadInput{
+		Bucket:          aws.String("bucket"),
+		Key:             aws.String("parts"),
+		UploadId:        create.UploadId,
+		MultipartUpload: &awss3.CompletedMultipartUpload{Parts: parts},
+	})
+	require.NoError(t, err)
+	head, err := c.HeadObject(&awss3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("parts")})
+	require.NoError(t, err)
+	assert.Equal(t, `"`+md5Hex([]byte("hello world"))+`"`, *head.ETag)
+	assert.Equal(t, *head.ETag, *complete.ETag)
+
+	// Abort an upload
+	create, err = c.CreateMultipartUpload(&awss3.CreateMultipartUploadInput{Bucket: aws.String("bucket"), Key: aws.String("aborted")})
+	require.NoError(t, err)
+	_, err = c.UploadPart(&awss3.UploadPartInput{
+		Bucket:     aws.String("bucket"),
+		Key:        aws.String("aborted"),
+		UploadId:   create.UploadId,
+		PartNumber: aws.Int64(1),
+		Body:       strings.NewReader("part"),
+	})
+	require.NoError(t, err)
+	_, err = c.AbortMultipartUpload(&awss3.AbortMultipartUploadInput{Bucket: aws.String("bucket"), Key: aws.String("aborted"), UploadId: create.UploadId})
+	require.NoError(t, err)
+	_, err = c.CompleteMultipartUpload(&awss3.CompleteMultipartUploadInput{Bucket: aws.String("bucket"), Key: aws.String("aborted"), UploadId: create.UploadId})
+	assert.Equal(t, "NoSuchUpload", errorCode(err))
+	_, err = c.HeadObject(&awss3.HeadObjectInput{Bucket: aws.String("bucket"), Key: aws.String("aborted")})
+	assert.Error(t, err)
+}
+
+func TestServeS3Auth(t *testing.T) {
+	_, newClient, finalise := startServer(t)
+	defer finalise()
+
+	_, err := newClient(testAccessKey, "wrong").ListBuckets(&awss3.ListBucketsInput{})
+	assert.Equal(t, "SignatureDoesNotMatch", errorCode(err))
+	_, err = newClient("unknown", testSecretKey).ListBuckets(&awss3.ListBucketsInput{})
+	assert.Equal(t, "InvalidAccessKeyId", errorCode(err))
+
+	// Check presigned URLs work
+	c := newClient(testAccessKey, testSecretKey)
+	_, err = c.CreateBucket(&awss3.CreateBucketInput{Bucket: aws.String("bucket")})
+	require.NoError(t, err)
+	_, err = c.PutObject(&awss3.PutObjectInput{Bucket: aws.String("bucket"), Key: aws.String("a file"), Body: strings.NewReader("potato")})
+	require.NoError(t, err)
+	req, _ := c.GetObjectRequest(&awss3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("a file")})
+	url, err := req.Presign(time.Minute)
+	require.NoError(t, err)
+	resp, err := httpGet(url)
+	require.NoError(t, err)
+	assert.Equal(t, "potato", resp)
+	_, err = httpGet(url + "x")
+	assert.Error(t, err)
+}
+
+func TestServeS3FailedOverwrite(t *testing.T) {
+	dir, newClient, finalise := startServer(t)
+	defer finalise()
+	c := newClient(testAccessKey, testSecretKey)
+
+	_, err := c.CreateBucket(&awss3.CreateBucketInput{Bucket: aws.String("bucket")})
+	require.NoError(t, err)
+	_, err = c.PutObject(&awss3.PutObjectInput{Bucket: aws.String("bucket"), Key: aws.String("file.txt"), Body: strings.NewReader("original")})
+	require.NoError(t, err)
+
+	// A PUT with the wrong Content-MD5 should fail and leave the
+	// existing object alone
+	badMD5 := md5.Sum([]byte("something else"))
+	_, err = c.PutObject(&awss3.PutObjectInput{
+		Bucket:     aws.String("bucket"),
+		Key:        aws.String("file.txt"),
+		Body:       strings.NewReader("replacement"),
+		ContentMD5: aws.String(base64.StdEncoding.EncodeToString(badMD5[:])),
+	})
+	assert.Equal(t, "BadDigest", errorCode(err))
+
+	get, err := c.GetObject(&awss3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("file.txt")})
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(get.Body)
+	require.NoError(t, err)
+	require.NoError(t, get.Body.Close())
+	assert.Equal(t, "original", string(got))
+
+	// No temporary files should be left behind
+	entries, err := ioutil.ReadDir(filepath.Join(dir, "bucket"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "file.txt", entries[0].Name())
+
+	// A good overwrite replaces the object
+	out, err := c.PutObject(&awss3.PutObjectInput{Bucket: aws.String("bucket"), Key: aws.String("file.txt"), Body: strings.NewReader("replacement")})
+	require.NoError(t, err)
+	assert.Equal(t, `"`+md5Hex([]byte("replacement"))+`"`, *out.ETag)
+	get, err = c.GetObject(&awss3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("file.txt")})
+	require.NoError(t, err)
+	got, err = ioutil.ReadAll(get.Body)
+	require.NoError(t, err)
+	require.NoError(t, get.Body.Close())
+	assert.Equal(t, "replacement", string(got))
+}
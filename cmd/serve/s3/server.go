@@ -0,0 +1,771 @@
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ncw/rclone/cmd/serve/httplib"
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/vfs"
+	"github.com/ncw/rclone/vfs/vfsflags"
+	"github.com/ncw/swift"
+	"github.com/pkg/errors"
+)
+
+// emptyMD5 is the MD5 of no data
+const emptyMD5 = "d41d8cd98f00b204e9800998ecf8427e"
+
+// maxDeleteObjects is the maximum number of keys in a DeleteObjects
+// request
+const maxDeleteObjects = 1000
+
+// bucket sub resources which aren't supported
+var unsupportedSubResources = []string{"acl", "cors", "encryption", "lifecycle", "logging", "policy", "replication", "tagging", "versioning", "versions", "website"}
+
+// server contains everything to run the server
+type server struct {
+	*httplib.Server
+	f       fs.Fs
+	vfs     *vfs.VFS
+	keys    map[string]string // access key ID to secret access key
+	useMD5  bool              // set if the remote supports MD5 for ETags
+	uploads *uploads          // multipart uploads in progress
+}
+
+// newServer makes a new server to serve f with the http options
+// given and authenticating with keys
+func newServer(f fs.Fs, opt *httplib.Options, keys map[string]string) (*server, error) {
+	uploads, err := newUploads()
+	if err != nil {
+		return nil, err
+	}
+	s := &server{
+		f:       f,
+		vfs:     vfs.New(f, &vfsflags.Opt),
+		keys:    keys,
+		useMD5:  f.Hashes().Contains(hash.MD5),
+		uploads: uploads,
+	}
+	s.Server = httplib.NewServer(http.HandlerFunc(s.handler), opt)
+	return s, nil
+}
+
+// Serve runs the http server in the background.
+//
+// Use s.Close() and s.Wait() to shutdown server
+func (s *server) Serve() error {
+	err := s.Server.Serve()
+	if err != nil {
+		return err
+	}
+	fs.Logf(s.f, "S3 server started on %s", s.URL())
+	return nil
+}
+
+// Close shuts the server down and removes any unfinished multipart
+// uploads
+func (s *server) Close() {
+	s.Server.Close()
+	s.uploads.abortAll()
+}
+
+// splitPath splits the URL path into a bucket and a key
+func splitPath(urlPath string) (bucket, key string) {
+	urlPath = strings.TrimPrefix(urlPath, "/")
+	i := strings.IndexRune(urlPath, '/')
+	if i < 0 {
+		return urlPath, ""
+	}
+	return urlPath[:i], urlPath[i+1:]
+}
+
+// validKey returns true if key can be represented in the VFS
+//
+// Keys may end in "/" to represent a directory, but may not otherwise
+// contain empty, "." or ".." segments.
+func validKey(key string) bool {
+	for _, segment := range strings.Split(strings.TrimSuffix(key, "/"), "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// hasAny returns true if query has any of the keys
+func hasAny(query url.Values, keys ...string) bool {
+	for _, key := range keys {
+		if _, ok := query[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// handler reads incoming requests and dispatches them
+func (s *server) handler(w http.ResponseWriter, r *http.Request) {
+	fs.Infof(r.URL.Path, "%s from %s", r.Method, r.RemoteAddr)
+	w.Header().Set("Server", "rclone/"+fs.Version)
+
+	err := authenticate(r, s.keys)
+	if err != nil {
+		writeError(w, r, err, errNoSuchKey)
+		return
+	}
+
+	bucket, key := splitPath(r.URL.Path)
+	query := r.URL.Query()
+	switch {
+	case bucket == "":
+		if r.Method != "GET" {
+			writeError(w, r, errMethodNotAllowed, nil)
+			return
+		}
+		err = s.listBuckets(w, r)
+	case key == "":
+		err = s.serveBucket(w, r, bucket, query)
+		if err != nil {
+			writeError(w, r, err, errNoSuchBucket)
+		}
+		return
+	case !validKey(key):
+		err = newError(http.StatusBadRequest, "InvalidArgument", "Key can't be represented by this server")
+	default:
+		err = s.serveObject(w, r, bucket, key, query)
+	}
+	if err != nil {
+		writeError(w, r, err, errNoSuchKey)
+	}
+}
+
+// serveBucket dispatches requests on a bucket
+func (s *server) serveBucket(w http.ResponseWriter, r *http.Request, bucket string, query url.Values) error {
+	if hasAny(query, unsupportedSubResources...) {
+		return errNotImplemented
+	}
+	switch r.Method {
+	case "GET":
+		switch {
+		case hasAny(query, "location"):
+			return s.getBucketLocation(w, r, bucket)
+		case hasAny(query, "uploads"):
+			return errNotImplemented
+		}
+		return s.listObjects(w, r, bucket, query)
+	case "HEAD":
+		_, err := s.bucketDir(bucket)
+		return err
+	case "PUT":
+		return s.createBucket(w, r, bucket)
+	case "DELETE":
+		return s.deleteBucket(w, r, bucket)
+	case "POST":
+		if hasAny(query, "delete") {
+			return s.deleteObjects(w, r, bucket)
+		}
+	}
+	return errMethodNotAllowed
+}
+
+// serveObject dispatches requests on an object
+func (s *server) serveObject(w http.ResponseWriter, r *http.Request, bucket, key string, query url.Values) error {
+	if hasAny(query, "acl", "tagging", "retention", "legal-hold", "torrent") {
+		return errNotImplemented
+	}
+	uploadID := query.Get("uploadId")
+	switch r.Method {
+	case "GET", "HEAD":
+		if uploadID != "" {
+			return errNotImplemented
+		}
+		return s.getObject(w, r, bucket, key)
+	case "PUT":
+		switch {
+		case uploadID != "":
+			return s.uploadPart(w, r, bucket, key, uploadID, query.Get("partNumber"))
+		case r.Header.Get("X-Amz-Copy-Source") != "":
+			return s.copyObject(w, r, bucket, key)
+		}
+		return s.putObject(w, r, bucket, key)
+	case "POST":
+		switch {
+		case hasAny(query, "uploads"):
+			return s.createMultipartUpload(w, r, bucket, key)
+		case uploadID != "":
+			return s.completeMultipartUpload(w, r, bucket, key, uploadID)
+		}
+	case "DELETE":
+		if uploadID != "" {
+			return s.abortMultipartUpload(w, r, bucket, key, uploadID)
+		}
+		return s.deleteObject(w, r, bucket, key)
+	}
+	return errMethodNotAllowed
+}
+
+// bucketDir returns the directory for bucket
+func (s *server) bucketDir(bucket string) (*vfs.Dir, error) {
+	if bucket == "" || !validKey(bucket) || strings.ContainsRune(bucket, '/') {
+		return nil, errInvalidBucketName
+	}
+	node, err := s.vfs.Stat(bucket)
+	if err == vfs.ENOENT || (err == nil && !node.IsDir()) {
+		return nil, errNoSuchBucket
+	} else if err != nil {
+		return nil, err
+	}
+	return node.(*vfs.Dir), nil
+}
+
+// stat finds the node for key in bucket
+//
+// Keys ending in "/" refer to directories, all others to files.
+func (s *server) stat(bucket, key string) (vfs.Node, error) {
+	_, err := s.bucketDir(bucket)
+	if err != nil {
+		return nil, err
+	}
+	node, err := s.vfs.Stat(path.Join(bucket, key))
+	if err != nil {
+		return nil, err
+	}
+	if node.IsDir() != strings.HasSuffix(key, "/") {
+		return nil, errNoSuchKey
+	}
+	return node, nil
+}
+
+// mkdirAll makes the directory dirPath and any parents it needs
+func (s *server) mkdirAll(dirPath string) (*vfs.Dir, error) {
+	dir, err := s.vfs.Root()
+	if err != nil {
+		return nil, err
+	}
+	if dirPath == "" || dirPath == "." {
+		return dir, nil
+	}
+	for _, leaf := range strings.Split(dirPath, "/") {
+		dir, err = dir.Mkdir(leaf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dir, nil
+}
+
+// removeEmptyDirs removes dirPath and its parents while they are
+// empty, stopping at the bucket.
+//
+// This means that prefixes disappear when the last object in them is
+// deleted as they would on S3.
+func (s *server) removeEmptyDirs(bucket, dirPath string) {
+	for dirPath != bucket && strings.HasPrefix(dirPath, bucket+"/") {
+		node, err := s.vfs.Stat(dirPath)
+		if err != nil || !node.IsDir() {
+			return
+		}
+		entries, err := node.(*vfs.Dir).ReadDirAll()
+		if err != nil || len(entries) != 0 {
+			return
+		}
+		err = node.Remove()
+		if err != nil {
+			fs.Debugf(dirPath, "Failed to remove empty directory: %v", err)
+			return
+		}
+		dirPath = path.Dir(dirPath)
+	}
+}
+
+// etag returns the ETag for node
+//
+// This is the MD5 of the object if the remote supports it, otherwise
+// it is made from the modification time and size which can't be
+// mistaken for an MD5.
+func (s *server) etag(ctx context.Context, node vfs.Node) string {
+	if node.IsDir() {
+		return `"` + emptyMD5 + `"`
+	}
+	if s.useMD5 {
+		if o, ok := node.DirEntry().(fs.Object); ok {
+			md5sum, err := o.Hash(ctx, hash.MD5)
+			if err != nil {
+				fs.Debugf(o, "Failed to read MD5 for ETag: %v", err)
+			} else if md5sum != "" {
+				return `"` + md5sum + `"`
+			}
+		}
+	}
+	return fmt.Sprintf(`"%x-%x"`, node.ModTime().UnixNano(), node.Size())
+}
+
+// listBuckets lists the directories in the root as buckets
+func (s *server) listBuckets(w http.ResponseWriter, r *http.Request) error {
+	root, err := s.vfs.Root()
+	if err != nil {
+		return err
+	}
+	nodes, err := root.ReadDirAll()
+	if err != nil {
+		return err
+	}
+	result := ListAllMyBucketsResult{
+		Xmlns:   xmlns,
+		Owner:   owner,
+		Buckets: []Bucket{},
+	}
+	for _, node := range nodes {
+		if node.IsDir() {
+			result.Buckets = append(result.Buckets, Bucket{
+				Name:         node.Name(),
+				CreationDate: s3Time(node.ModTime()),
+			})
+		}
+	}
+	writeXML(w, http.StatusOK, result)
+	return nil
+}
+
+// getBucketLocation returns the location of the bucket which is
+// always the default
+func (s *server) getBucketLocation(w http.ResponseWriter, r *http.Request, bucket string) error {
+	_, err := s.bucketDir(bucket)
+	if err != nil {
+		return err
+	}
+	writeXML(w, http.StatusOK, LocationConstraint{Xmlns: xmlns})
+	return nil
+}
+
+// createBucket makes a new directory in the root
+func (s *server) createBucket(w http.ResponseWriter, r *http.Request, bucket string) error {
+	_, err := s.bucketDir(bucket)
+	switch err {
+	case nil:
+		return errBucketAlreadyExists
+	case errNoSuchBucket:
+	default:
+		return err
+	}
+	root, err := s.vfs.Root()
+	if err != nil {
+		return err
+	}
+	_, err = root.Mkdir(bucket)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", "/"+bucket)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// deleteBucket removes an empty directory from the root
+func (s *server) deleteBucket(w http.ResponseWriter, r *http.Request, bucket string) error {
+	dir, err := s.bucketDir(bucket)
+	if err != nil {
+		return err
+	}
+	entries, err := dir.ReadDirAll()
+	if err != nil {
+		return err
+	}
+	if len(entries) != 0 {
+		return errBucketNotEmpty
+	}
+	err = dir.Remove()
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// setObjectHeaders sets the headers describing node
+func (s *server) setObjectHeaders(w http.ResponseWriter, r *http.Request, node vfs.Node) {
+	h := w.Header()
+	h.Set("ETag", s.etag(r.Context(), node))
+	h.Set("Last-Modified", node.ModTime().UTC().Format(http.TimeFormat))
+	h.Set("X-Amz-Meta-Mtime", swift.TimeToFloatString(node.ModTime()))
+	h.Set("Accept-Ranges", "bytes")
+	contentType := "application/octet-stream"
+	if o, ok := node.DirEntry().(fs.Object); ok {
+		contentType = fs.MimeType(r.Context(), o)
+	}
+	h.Set("Content-Type", contentType)
+}
+
+// getObject serves the object at key for GET and HEAD
+func (s *server) getObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	node, err := s.stat(bucket, key)
+	if err != nil {
+		return err
+	}
+	s.setObjectHeaders(w, r, node)
+	if node.IsDir() {
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	// If HEAD no need to read the object since we have set the headers
+	if r.Method == "HEAD" {
+		w.Header().Set("Content-Length", strconv.FormatInt(node.Size(), 10))
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	in, err := node.Open(os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := in.Close()
+		if err != nil {
+			fs.Errorf(node, "Failed to close file: %v", err)
+		}
+	}()
+
+	// Account the transfer
+	remote := node.Path()
+	accounting.GlobalStats().Transferring(remote)
+	defer accounting.GlobalStats().DoneTransferring(remote, true)
+
+	// Serve the file - this deals with Range and conditional requests
+	http.ServeContent(w, r, remote, node.ModTime(), in)
+	return nil
+}
+
+// checkContentMD5 checks the Content-MD5 header of r, if any, matches
+// md5sum which is hex encoded
+func checkContentMD5(r *http.Request, md5sum string) error {
+	contentMD5 := r.Header.Get("Content-Md5")
+	if contentMD5 == "" {
+		return nil
+	}
+	want, err := base64.StdEncoding.DecodeString(contentMD5)
+	if err != nil {
+		return newError(http.StatusBadRequest, "InvalidDigest", "The Content-MD5 you specified is not valid.")
+	}
+	if hex.EncodeToString(want) != md5sum {
+		return errBadDigest
+	}
+	return nil
+}
+
+// modTimeFromHeaders returns the modification time in the
+// X-Amz-Meta-Mtime header if there is a valid one
+func modTimeFromHeaders(r *http.Request) (modTime time.Time, ok bool) {
+	mtime := r.Header.Get("X-Amz-Meta-Mtime")
+	if mtime == "" {
+		return modTime, false
+	}
+	t, err := swift.FloatStringToTime(mtime)
+	if err != nil {
+		fs.Debugf(r.URL.Path, "Ignoring bad X-Amz-Meta-Mtime %q: %v", mtime, err)
+		return modTime, false
+	}
+	return t, true
+}
+
+// uploadPrefix is the prefix of the temporary names objects are
+// written to before being renamed into place
+const uploadPrefix = ".rclone-s3-upload-"
+
+// isUploading returns true if node is an object being uploaded
+func isUploading(node vfs.Node) bool {
+	return !node.IsDir() && strings.HasPrefix(node.Name(), uploadPrefix)
+}
+
+// uploadName returns a temporary name in the same directory as remote
+// to upload it to
+func uploadName(remote string) (string, error) {
+	var id [8]byte
+	_, err := rand.Read(id[:])
+	if err != nil {
+		return "", err
+	}
+	return path.Join(path.Dir(remote), uploadPrefix+hex.EncodeToString(id[:])), nil
+}
+
+// writeFile writes in to remote in the VFS creating any directories
+// needed, returning the hex encoded MD5 of the data written.
+//
+// The data is written to a temporary name and only renamed to remote
+// once it has all been written and check, if set, has passed the MD5
+// of it, so a failed upload leaves any existing object untouched.
+// Remotes which can't move or copy objects are written in place.
+//
+// If modTime is set then the modification time is set on the file.
+func (s *server) writeFile(remote string, in io.Reader, modTime time.Time, setModTime bool, check func(md5sum string) error) (md5sum string, err error) {
+	_, err = s.mkdirAll(path.Dir(remote))
+	if err != nil {
+		return "", err
+	}
+	tmpRemote := remote
+	if features := s.f.Features(); features.Move != nil || features.Copy != nil {
+		tmpRemote, err = uploadName(remote)
+		if err != nil {
+			return "", err
+		}
+	}
+	fh, err := s.vfs.OpenFile(tmpRemote, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+	if err != nil {
+		return "", err
+	}
+	hasher := md5.New()
+	_, err = io.Copy(fh, io.TeeReader(in, hasher))
+	closeErr := fh.Close()
+	if err == nil {
+		err = closeErr
+	}
+	md5sum = hex.EncodeToString(hasher.Sum(nil))
+	if err == nil && check != nil {
+		err = check(md5sum)
+	}
+	if err == nil && tmpRemote != remote {
+		err = s.vfs.Rename(tmpRemote, remote)
+	}
+	if err != nil {
+		s.removeFailed(tmpRemote)
+		return "", err
+	}
+	if setModTime {
+		s.setModTime(remote, modTime)
+	}
+	return md5sum, nil
+}
+
+// uploadETag returns the ETag of remote which has just been written
+// with data whose MD5 is md5sum.  This is the same as the ETag which
+// HEAD and listings return for it.
+func (s *server) uploadETag(ctx context.Context, remote, md5sum string) string {
+	if s.useMD5 {
+		return `"` + md5sum + `"`
+	}
+	node, err := s.vfs.Stat(remote)
+	if err != nil {
+		return `"` + md5sum + `"`
+	}
+	return s.etag(ctx, node)
+}
+
+// setModTime sets the modification time of remote, logging any errors
+func (s *server) setModTime(remote string, modTime time.Time) {
+	node, err := s.vfs.Stat(remote)
+	if err == nil {
+		err = node.SetModTime(modTime)
+	}
+	if err != nil {
+		fs.Errorf(remote, "Failed to set modification time: %v", err)
+	}
+}
+
+// removeFailed removes a file whose upload failed
+func (s *server) removeFailed(remote string) {
+	node, err := s.vfs.Stat(remote)
+	if err == nil {
+		err = node.Remove()
+	}
+	if err != nil && err != vfs.ENOENT {
+		fs.Errorf(remote, "Failed to remove failed upload: %v", err)
+	}
+}
+
+// putObject uploads the body of the request to key
+func (s *server) putObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	_, err := s.bucketDir(bucket)
+	if err != nil {
+		return err
+	}
+	remote := path.Join(bucket, key)
+
+	// Keys ending in / with no data are directory markers
+	if strings.HasSuffix(key, "/") {
+		if r.ContentLength > 0 {
+			return newError(http.StatusBadRequest, "InvalidArgument", "Directory markers can't have content")
+		}
+		_, err = s.mkdirAll(remote)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("ETag", `"`+emptyMD5+`"`)
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	modTime, setModTime := modTimeFromHeaders(r)
+	accounting.GlobalStats().Transferring(remote)
+	md5sum, err := s.writeFile(remote, r.Body, modTime, setModTime, func(md5sum string) error {
+		return checkContentMD5(r, md5sum)
+	})
+	accounting.GlobalStats().DoneTransferring(remote, err == nil)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("ETag", s.uploadETag(r.Context(), remote, md5sum))
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// copyObject copies the object named in the X-Amz-Copy-Source header
+// to key
+func (s *server) copyObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	source := r.Header.Get("X-Amz-Copy-Source")
+	if i := strings.IndexRune(source, '?'); i >= 0 {
+		source = source[:i] // remove any versionId
+	}
+	source, err := url.PathUnescape(source)
+	if err != nil {
+		return newError(http.StatusBadRequest, "InvalidArgument", "Bad X-Amz-Copy-Source")
+	}
+	srcBucket, srcKey := splitPath(source)
+	if srcKey == "" || !validKey(srcKey) || strings.HasSuffix(srcKey, "/") {
+		return newError(http.StatusBadRequest, "InvalidArgument", "Bad X-Amz-Copy-Source")
+	}
+	if strings.HasSuffix(key, "/") {
+		return newError(http.StatusBadRequest, "InvalidArgument", "Can't copy to a directory marker")
+	}
+	srcNode, err := s.stat(srcBucket, srcKey)
+	if err != nil {
+		return err
+	}
+	_, err = s.bucketDir(bucket)
+	if err != nil {
+		return err
+	}
+	srcRemote := srcNode.Path()
+	dstRemote := path.Join(bucket, key)
+
+	// Find the modification time for the destination
+	modTime, setModTime := srcNode.ModTime(), true
+	if strings.EqualFold(r.Header.Get("X-Amz-Metadata-Directive"), "REPLACE") {
+		modTime, setModTime = modTimeFromHeaders(r)
+	}
+
+	if srcRemote == dstRemote {
+		// Copying an object to itself is used to update its metadata
+		if setModTime {
+			s.setModTime(dstRemote, modTime)
+		}
+	} else {
+		in, err := srcNode.Open(os.O_RDONLY)
+		if err != nil {
+			return err
+		}
+		accounting.GlobalStats().Transferring(dstRemote)
+		_, err = s.writeFile(dstRemote, in, modTime, setModTime, nil)
+		accounting.GlobalStats().DoneTransferring(dstRemote, err == nil)
+		closeErr := in.Close()
+		if err != nil {
+			return err
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+	}
+
+	dstNode, err := s.vfs.Stat(dstRemote)
+	if err != nil {
+		return err
+	}
+	writeXML(w, http.StatusOK, CopyObjectResult{
+		Xmlns:        xmlns,
+		LastModified: s3Time(dstNode.ModTime()),
+		ETag:         s.etag(r.Context(), dstNode),
+	})
+	return nil
+}
+
+// removeObject removes key from bucket, tidying up any directories
+// left empty. It isn't an error if key doesn't exist.
+func (s *server) removeObject(bucket, key string) error {
+	node, err := s.stat(bucket, key)
+	if err == vfs.ENOENT || err == errNoSuchKey {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if node.IsDir() {
+		entries, err := node.(*vfs.Dir).ReadDirAll()
+		if err != nil {
+			return err
+		}
+		if len(entries) != 0 {
+			// Directory markers for prefixes with objects in
+			// don't exist on their own so there is nothing to
+			// remove.
+			return nil
+		}
+	}
+	err = node.Remove()
+	if err != nil {
+		return err
+	}
+	s.removeEmptyDirs(bucket, path.Dir(path.Join(bucket, key)))
+	return nil
+}
+
+// deleteObject deletes key
+func (s *server) deleteObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	err := s.removeObject(bucket, key)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// deleteObjects deletes the keys listed in the body of the request
+func (s *server) deleteObjects(w http.ResponseWriter, r *http.Request, bucket string) error {
+	_, err := s.bucketDir(bucket)
+	if err != nil {
+		return err
+	}
+	var req Delete
+	err = xml.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		if e, ok := errors.Cause(err).(*s3Error); ok {
+			return e
+		}
+		return errMalformedXML
+	}
+	if len(req.Objects) > maxDeleteObjects {
+		return errMalformedXML
+	}
+	result := DeleteResult{Xmlns: xmlns}
+	for _, object := range req.Objects {
+		var err error = errInvalidRequest
+		if validKey(object.Key) {
+			err = s.removeObject(bucket, object.Key)
+		}
+		if err != nil {
+			e := toS3Error(err, errNoSuchKey)
+			fs.Errorf(path.Join(bucket, object.Key), "Failed to delete: %v", err)
+			result.Errors = append(result.Errors, DeleteError{
+				Key:     object.Key,
+				Code:    e.Code,
+				Message: e.Message,
+			})
+		} else if !req.Quiet {
+			result.Deleted = append(result.Deleted, DeletedObject{Key: object.Key})
+		}
+	}
+	writeXML(w, http.StatusOK, result)
+	return nil
+}
@@ -0,0 +1,256 @@
+package s3
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/vfs"
+	"github.com/pkg/errors"
+)
+
+// xmlns is the namespace of the S3 XML documents
+const xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// timeFormat is the format S3 uses for times in XML documents
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+// s3Time formats t in the S3 XML format
+func s3Time(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+// Owner describes the owner of buckets and objects
+type Owner struct {
+	ID          string
+	DisplayName string
+}
+
+// owner is the owner returned for everything
+var owner = Owner{ID: "rclone", DisplayName: "rclone"}
+
+// Bucket is a single bucket in a ListAllMyBucketsResult
+type Bucket struct {
+	Name         string
+	CreationDate string
+}
+
+// ListAllMyBucketsResult is returned by ListBuckets
+type ListAllMyBucketsResult struct {
+	XMLName xml.Name `xml:"ListAllMyBucketsResult"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Owner   Owner
+	Buckets []Bucket `xml:"Buckets>Bucket"`
+}
+
+// Content is a single object in a ListBucketResult
+type Content struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int64
+	StorageClass string
+}
+
+// CommonPrefix is a single common prefix in a ListBucketResult
+type CommonPrefix struct {
+	Prefix string
+}
+
+// ListBucketResult is returned by ListObjects and ListObjectsV2
+type ListBucketResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Xmlns                 string   `xml:"xmlns,attr"`
+	Name                  string
+	Prefix                string
+	Delimiter             string `xml:",omitempty"`
+	EncodingType          string `xml:",omitempty"`
+	MaxKeys               int
+	IsTruncated           bool
+	Marker                *string `xml:",omitempty"`
+	NextMarker            string  `xml:",omitempty"`
+	KeyCount              *int    `xml:",omitempty"`
+	ContinuationToken     string  `xml:",omitempty"`
+	NextContinuationToken string  `xml:",omitempty"`
+	StartAfter            string  `xml:",omitempty"`
+	Contents              []Content
+	CommonPrefixes        []CommonPrefix
+}
+
+// LocationConstraint is returned by GetBucketLocation
+type LocationConstraint struct {
+	XMLName  xml.Name `xml:"LocationConstraint"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string   `xml:",chardata"`
+}
+
+// CopyObjectResult is returned by CopyObject
+type CopyObjectResult struct {
+	XMLName      xml.Name `xml:"CopyObjectResult"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	LastModified string
+	ETag         string
+}
+
+// ObjectIdentifier names an object in a Delete request
+type ObjectIdentifier struct {
+	Key string
+}
+
+// Delete is the body of a DeleteObjects request
+type Delete struct {
+	Quiet   bool
+	Objects []ObjectIdentifier `xml:"Object"`
+}
+
+// DeletedObject is a successfully deleted object in a DeleteResult
+type DeletedObject struct {
+	Key string
+}
+
+// DeleteError is an object which couldn't be deleted in a DeleteResult
+type DeleteError struct {
+	Key     string
+	Code    string
+	Message string
+}
+
+// DeleteResult is returned by DeleteObjects
+type DeleteResult struct {
+	XMLName xml.Name `xml:"DeleteResult"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Deleted []DeletedObject
+	Errors  []DeleteError `xml:"Error"`
+}
+
+// InitiateMultipartUploadResult is returned by CreateMultipartUpload
+type InitiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Bucket   string
+	Key      string
+	UploadID string `xml:"UploadId"`
+}
+
+// CompletedPart is a single part in a CompleteMultipartUpload request
+type CompletedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// CompleteMultipartUpload is the body of a CompleteMultipartUpload request
+type CompleteMultipartUpload struct {
+	Parts []CompletedPart `xml:"Part"`
+}
+
+// CompleteMultipartUploadResult is returned by CompleteMultipartUpload
+type CompleteMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string
+	Bucket   string
+	Key      string
+	ETag     string
+}
+
+// s3Error is an error which is returned to the client as an S3 error
+// document
+type s3Error struct {
+	status  int
+	Code    string
+	Message string
+}
+
+// Error satisfies the error interface
+func (e *s3Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// errorResponse is the S3 error document
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string
+	Message   string
+	Resource  string
+	RequestID string `xml:"RequestId"`
+}
+
+// newError makes a new s3Error
+func newError(status int, code, message string) *s3Error {
+	return &s3Error{status: status, Code: code, Message: message}
+}
+
+// Errors returned to the client
+var (
+	errAccessDenied          = newError(http.StatusForbidden, "AccessDenied", "Access Denied")
+	errBadDigest             = newError(http.StatusBadRequest, "BadDigest", "The Content-MD5 you specified did not match what we received.")
+	errBucketAlreadyExists   = newError(http.StatusConflict, "BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it.")
+	errBucketNotEmpty        = newError(http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty")
+	errContentSHA256Mismatch = newError(http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.")
+	errEntityTooLarge        = newError(http.StatusBadRequest, "EntityTooLarge", "Your proposed upload exceeds the maximum allowed size")
+	errExpiredToken          = newError(http.StatusForbidden, "AccessDenied", "Request has expired")
+	errIncompleteBody        = newError(http.StatusBadRequest, "IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header.")
+	errInvalidAccessKeyID    = newError(http.StatusForbidden, "InvalidAccessKeyId", "The AWS Access Key Id you provided does not exist in our records.")
+	errInvalidBucketName     = newError(http.StatusBadRequest, "InvalidBucketName", "The specified bucket is not valid.")
+	errInvalidPart           = newError(http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found.")
+	errInvalidPartOrder      = newError(http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order.")
+	errInvalidRequest        = newError(http.StatusBadRequest, "InvalidRequest", "Invalid Request")
+	errMalformedXML          = newError(http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema.")
+	errMethodNotAllowed      = newError(http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+	errMissingSecurityHeader = newError(http.StatusBadRequest, "MissingSecurityHeader", "Your request is missing a required header.")
+	errNoSuchBucket          = newError(http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+	errNoSuchKey             = newError(http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+	errNoSuchUpload          = newError(http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist.")
+	errNotImplemented        = newError(http.StatusNotImplemented, "NotImplemented", "A header you provided implies functionality that is not implemented.")
+	errRequestTimeTooSkewed  = newError(http.StatusForbidden, "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.")
+	errSignatureDoesNotMatch = newError(http.StatusForbidden, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided. Check your key and signing method.")
+)
+
+// toS3Error converts err into an s3Error, using notFound for
+// vfs.ENOENT
+func toS3Error(err error, notFound *s3Error) *s3Error {
+	switch errors.Cause(err) {
+	case vfs.ENOENT:
+		return notFound
+	case vfs.EPERM, vfs.EROFS:
+		return errAccessDenied
+	}
+	if e, ok := errors.Cause(err).(*s3Error); ok {
+		return e
+	}
+	return newError(http.StatusInternalServerError, "InternalError", err.Error())
+}
+
+// writeXML writes v as an XML document with the status given
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, err := w.Write([]byte(xml.Header))
+	if err == nil {
+		err = xml.NewEncoder(w).Encode(v)
+	}
+	if err != nil {
+		fs.Errorf(nil, "Failed to write XML response: %v", err)
+	}
+}
+
+// writeError writes err to the client as an S3 error document
+func writeError(w http.ResponseWriter, r *http.Request, err error, notFound *s3Error) {
+	e := toS3Error(err, notFound)
+	if e.status == http.StatusInternalServerError {
+		fs.Errorf(r.URL.Path, "%s %s failed: %v", r.Method, r.URL.Path, err)
+	} else {
+		fs.Infof(r.URL.Path, "%s %s failed: %v", r.Method, r.URL.Path, e)
+	}
+	if r.Method == "HEAD" {
+		w.WriteHeader(e.status)
+		return
+	}
+	writeXML(w, e.status, errorResponse{
+		Code:     e.Code,
+		Message:  e.Message,
+		Resource: r.URL.Path,
+	})
+}
@@ -7,7 +7,9 @@ import (
 	"github.com/ncw/rclone/cmd/serve/dlna"
 	"github.com/ncw/rclone/cmd/serve/ftp"
 	"github.com/ncw/rclone/cmd/serve/http"
+	"github.com/ncw/rclone/cmd/serve/nfs"
 	"github.com/ncw/rclone/cmd/serve/restic"
+	"github.com/ncw/rclone/cmd/serve/s3"
 	"github.com/ncw/rclone/cmd/serve/sftp"
 	"github.com/ncw/rclone/cmd/serve/webdav"
 	"github.com/spf13/cobra"
@@ -30,6 +32,12 @@ func init() {
 	if sftp.Command != nil {
 		Command.AddCommand(sftp.Command)
 	}
+	if s3.Command != nil {
+		Command.AddCommand(s3.Command)
+	}
+	if nfs.Command != nil {
+		Command.AddCommand(nfs.Command)
+	}
 	cmd.Root.AddCommand(Command)
 }
 
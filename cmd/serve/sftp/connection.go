@@ -216,7 +216,7 @@ func (c *conn) handleChannel(newChannel ssh.NewChannel) {
 	// Wait for either subsystem "sftp" or "exec" request
 	if <-isSFTP {
 		fs.Debugf(c.what, "Starting SFTP server")
-		server := sftp.NewRequestServer(channel, c.handlers)
+		server := sftp.NewRequestServer(newSymlinkReader(channel), c.handlers)
 		defer func() {
 			err := server.Close()
 			if err != nil {
@@ -85,18 +85,31 @@ func (v vfsHandler) Filecmd(r *sftp.Request) error {
 			return err
 		}
 	case "Symlink":
-		// FIXME
-		// _, err := v.fetch(r.Filepath)
-		// if err != nil {
-		// 	return err
-		// }
-		// link := newMemFile(r.Target, false)
-		// link.symlink = r.Filepath
-		// v.files[r.Target] = link
+		// r.Target is the new link and r.Filepath what it points to
+		target, err := decodeSymlinkTarget(r.Filepath)
+		if err != nil {
+			return err
+		}
+		err = v.Symlink(target, r.Target)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// linkInfo is the os.FileInfo returned for Readlink whose Name is
+// the target of the symlink
+type linkInfo struct {
+	os.FileInfo
+	target string
+}
+
+// Name returns the target of the symlink
+func (l linkInfo) Name() string {
+	return l.target
+}
+
 type listerat []os.FileInfo
 
 // Modeled after strings.Reader's ReadAt() implementation
@@ -141,14 +154,19 @@ func (v vfsHandler) Filelist(r *sftp.Request) (l sftp.ListerAt, err error) {
 		}
 		return listerat([]os.FileInfo{node}), nil
 	case "Readlink":
-		// FIXME
-		// if file.symlink != "" {
-		// 	file, err = v.fetch(file.symlink)
-		// 	if err != nil {
-		// 		return nil, err
-		// 	}
-		// }
-		// return listerat([]os.FileInfo{file}), nil
+		node, err = v.Stat(r.Filepath)
+		if err != nil {
+			return nil, err
+		}
+		file, ok := node.(*vfs.File)
+		if !ok {
+			return nil, vfs.EINVAL
+		}
+		target, err := file.Readlink()
+		if err != nil {
+			return nil, err
+		}
+		return listerat([]os.FileInfo{linkInfo{FileInfo: node, target: target}}), nil
 	}
 	return nil, nil
 }
@@ -0,0 +1,133 @@
+// +build !plan9
+
+package sftp
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// sshFxpSymlink is the type of the SSH_FXP_SYMLINK packet
+const sshFxpSymlink = 20
+
+// relativeTargetPrefix marks a relative symlink target which has been
+// hex encoded so pkg/sftp doesn't make it absolute
+const relativeTargetPrefix = "/.rclone-relative-target-"
+
+// symlinkReader reads the SFTP packets sent by the client, encoding
+// the targets of symlinks which are relative
+//
+// pkg/sftp cleans the target of a symlink into an absolute path, so
+// "../dir/file" would become "/dir/file".  Encoding the target
+// stops that and decodeSymlinkTarget gets it back.
+type symlinkReader struct {
+	io.ReadWriteCloser
+	buf  []byte // packet data waiting to be read
+	left uint32 // bytes of the current packet to pass straight through
+}
+
+// newSymlinkReader wraps the channel rw
+func newSymlinkReader(rw io.ReadWriteCloser) *symlinkReader {
+	return &symlinkReader{ReadWriteCloser: rw}
+}
+
+// Read the packets from the client
+func (s *symlinkReader) Read(p []byte) (n int, err error) {
+	if len(s.buf) > 0 {
+		n = copy(p, s.buf)
+		s.buf = s.buf[n:]
+		return n, nil
+	}
+	if s.left > 0 {
+		if uint32(len(p)) > s.left {
+			p = p[:s.left]
+		}
+		n, err = s.ReadWriteCloser.Read(p)
+		s.left -= uint32(n)
+		if err == io.EOF && s.left > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, err
+	}
+	err = s.readPacket()
+	if err != nil {
+		return 0, err
+	}
+	return s.Read(p)
+}
+
+// readPacket reads the header of the next packet, reading and
+// rewriting the whole packet if it is a symlink
+func (s *symlinkReader) readPacket() error {
+	var header [5]byte
+	_, err := io.ReadFull(s.ReadWriteCloser, header[:])
+	if err != nil {
+		return err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if length == 0 {
+		return errors.New("empty SFTP packet")
+	}
+	if header[4] != sshFxpSymlink {
+		s.buf = header[:]
+		s.left = length - 1
+		return nil
+	}
+	if length > maxSymlinkPacket {
+		return errors.Errorf("SFTP symlink packet too long (%d bytes)", length)
+	}
+	packet := make([]byte, length-1)
+	_, err = io.ReadFull(s.ReadWriteCloser, packet)
+	if err != nil {
+		return err
+	}
+	packet = encodeSymlinkPacket(packet)
+	s.buf = make([]byte, 5, 5+len(packet))
+	binary.BigEndian.PutUint32(s.buf, uint32(1+len(packet)))
+	s.buf[4] = sshFxpSymlink
+	s.buf = append(s.buf, packet...)
+	return nil
+}
+
+// maxSymlinkPacket is the longest symlink packet accepted
+const maxSymlinkPacket = 64 * 1024
+
+// encodeSymlinkPacket encodes the target of the symlink packet body p
+// if it is relative, returning p unchanged if it can't be decoded
+func encodeSymlinkPacket(p []byte) []byte {
+	// uint32 id, string targetpath, string linkpath
+	if len(p) < 8 {
+		return p
+	}
+	targetLen := binary.BigEndian.Uint32(p[4:])
+	if uint64(targetLen) > uint64(len(p)-8) {
+		return p
+	}
+	target := string(p[8 : 8+targetLen])
+	if target == "" || strings.HasPrefix(target, "/") {
+		return p
+	}
+	target = relativeTargetPrefix + hex.EncodeToString([]byte(target))
+	out := make([]byte, 8, 8+len(target)+len(p))
+	copy(out, p[:4])
+	binary.BigEndian.PutUint32(out[4:], uint32(len(target)))
+	out = append(out, target...)
+	return append(out, p[8+targetLen:]...)
+}
+
+// decodeSymlinkTarget returns the target of a symlink as sent by the
+// client
+func decodeSymlinkTarget(target string) (string, error) {
+	if !strings.HasPrefix(target, relativeTargetPrefix) {
+		return target, nil
+	}
+	raw, err := hex.DecodeString(target[len(relativeTargetPrefix):])
+	if err != nil {
+		return "", errors.Wrap(err, "bad symlink target")
+	}
+	return string(raw), nil
+}
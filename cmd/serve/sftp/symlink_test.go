@@ -0,0 +1,95 @@
+//+build !windows,!darwin,!plan9
+
+package sftp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/vfs/vfsflags"
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestEncodeSymlinkTarget(t *testing.T) {
+	for _, target := range []string{"file", "../dir/file", "./x", "a/../b"} {
+		p := encodeSymlinkPacket(append([]byte{0, 0, 0, 7, 0, 0, 0, byte(len(target))}, target+"\x00\x00\x00\x04link"...))
+		encodedLen := int(p[7])
+		encoded := string(p[8 : 8+encodedLen])
+		assert.Equal(t, "\x00\x00\x00\x04link", string(p[8+encodedLen:]), target)
+		got, err := decodeSymlinkTarget(encoded)
+		require.NoError(t, err)
+		assert.Equal(t, target, got)
+	}
+	// Absolute targets and bad packets are left alone
+	for _, p := range []string{"\x00\x00\x00\x07\x00\x00\x00\x04/abs", "\x00\x00\x00\x07\x00\x00\x00\xffshort", "bad"} {
+		assert.Equal(t, p, string(encodeSymlinkPacket([]byte(p))))
+	}
+	_, err := decodeSymlinkTarget(relativeTargetPrefix + "zz")
+	assert.Error(t, err)
+}
+
+// TestSymlink checks symlinks made over SFTP keep relative targets
+func TestSymlink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rclone-serve-sftp-symlink")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+	f, err := fs.NewFs(dir)
+	require.NoError(t, err)
+
+	oldLinks := vfsflags.Opt.Links
+	vfsflags.Opt.Links = true
+	defer func() {
+		vfsflags.Opt.Links = oldLinks
+	}()
+	opt := DefaultOpt
+	opt.ListenAddr = testBindAddress
+	opt.User = testUser
+	opt.Pass = testPass
+	w := newServer(f, &opt)
+	require.NoError(t, w.serve())
+	defer func() {
+		w.Close()
+		w.Wait()
+	}()
+
+	conn, err := ssh.Dial("tcp", w.Addr(), &ssh.ClientConfig{
+		User:            testUser,
+		Auth:            []ssh.AuthMethod{ssh.Password(testPass)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	client, err := sftp.NewClient(conn)
+	require.NoError(t, err)
+	defer func() {
+		_ = client.Close()
+	}()
+
+	require.NoError(t, client.Mkdir("/sub"))
+	for _, test := range []struct {
+		target string
+		link   string
+	}{
+		{"../dir/file1", "/sub/relative"},
+		{"file2", "/sub/plain"},
+		{"/dir/file3", "/sub/absolute"},
+	} {
+		require.NoError(t, client.Symlink(test.target, test.link), test.link)
+		got, err := client.ReadLink(test.link)
+		require.NoError(t, err, test.link)
+		assert.Equal(t, test.target, got, test.link)
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(test.link)+fs.LinkSuffix))
+		require.NoError(t, err, test.link)
+		assert.Equal(t, test.target, string(data), test.link)
+	}
+}
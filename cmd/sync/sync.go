@@ -11,11 +11,17 @@ import (
 
 var (
 	createEmptySrcDirs = false
+	watch              = false
+	watchOpt           = sync.DefaultWatchOpt
 )
 
 func init() {
 	cmd.Root.AddCommand(commandDefintion)
-	commandDefintion.Flags().BoolVarP(&createEmptySrcDirs, "create-empty-src-dirs", "", createEmptySrcDirs, "Create empty source dirs on destination after sync")
+	cmdFlags := commandDefintion.Flags()
+	cmdFlags.BoolVarP(&createEmptySrcDirs, "create-empty-src-dirs", "", createEmptySrcDirs, "Create empty source dirs on destination after sync")
+	cmdFlags.BoolVarP(&watch, "watch", "", watch, "Keep running, syncing changes to the source as they happen")
+	cmdFlags.DurationVarP(&watchOpt.Delay, "watch-delay", "", watchOpt.Delay, "Time to wait for more changes before syncing them with --watch")
+	cmdFlags.DurationVarP(&watchOpt.PollInterval, "watch-poll-interval", "", watchOpt.PollInterval, "Time between polls for changes with --watch")
 }
 
 var commandDefintion = &cobra.Command{
@@ -42,12 +48,41 @@ If dest:path doesn't exist, it is created and the source:path contents
 go there.
 
 **Note**: Use the ` + "`-P`" + `/` + "`--progress`" + ` flag to view real-time transfer statistics
+
+### Watching for changes
+
+With ` + "`--watch`" + ` the sync keeps running after the first sync and
+copies or deletes the files which change in the source, without
+listing everything again.  This is more efficient than running sync
+repeatedly, eg from cron.
+
+Changes are gathered for ` + "`--watch-delay`" + ` (default 5s) after the
+first one so bursts of changes are synced together.  Only the changed
+files and directories are synced.  Changes which fail to sync are
+tried 3 times before being given up on.  Errors are
+logged and watching carries on, except for fatal errors such as
+reaching ` + "`--max-delete`" + `.
+
+The source must notify changes for this to work well.  The local
+backend and many cloud backends (eg Google Drive and OneDrive) do.
+Backends which poll for changes do so every ` + "`--watch-poll-interval`" + `
+(default 1m).  If the source doesn't notify changes at all, the whole
+of it is synced every ` + "`--watch-poll-interval`" + ` instead.
+
+The stats are printed every ` + "`--stats`" + ` interval as usual.  A
+watching sync can also be run as an rc job with the ` + "`sync/sync`" + `
+call.
 `,
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(2, 2, command, args)
 		fsrc, srcFileName, fdst := cmd.NewFsSrcFileDst(args)
 		cmd.Run(true, true, command, func() error {
 			if srcFileName == "" {
+				if watch {
+					runOpt := watchOpt
+					runOpt.CreateEmptySrcDirs = createEmptySrcDirs
+					return sync.Watch(context.Background(), fdst, fsrc, runOpt)
+				}
 				return sync.Sync(context.Background(), fdst, fsrc, createEmptySrcDirs)
 			}
 			return operations.CopyFile(context.Background(), fdst, fsrc, srcFileName, srcFileName)
@@ -2,6 +2,7 @@
 package accounting
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"sync"
@@ -26,6 +27,7 @@ type Account struct {
 	// CancelRequest so this race can happen when it apparently
 	// shouldn't.
 	mu      sync.Mutex
+	stats   *StatsInfo // stats to account the transfer to
 	in      io.Reader
 	origIn  io.ReadCloser
 	close   io.Closer
@@ -41,14 +43,16 @@ type Account struct {
 	closed  bool          // set if the file is closed
 	exit    chan struct{} // channel that will be closed when transfer is finished
 	withBuf bool          // is using a buffered in
+	limit   *bwLimit      // if set, bandwidth limit to use instead of the global one
 }
 
 const averagePeriod = 16 // period to do exponentially weighted averages over
 
 // NewAccountSizeName makes a Account reader for an io.ReadCloser of
-// the given size and name
-func NewAccountSizeName(in io.ReadCloser, size int64, name string) *Account {
+// the given size and name, accounting it to s
+func (s *StatsInfo) NewAccountSizeName(in io.ReadCloser, size int64, name string) *Account {
 	acc := &Account{
+		stats:  s,
 		in:     in,
 		close:  in,
 		origIn: in,
@@ -60,13 +64,13 @@ func NewAccountSizeName(in io.ReadCloser, size int64, name string) *Account {
 		max:    int64(fs.Config.MaxTransfer),
 	}
 	go acc.averageLoop()
-	Stats.inProgress.set(acc.name, acc)
+	s.inProgress.set(acc.name, acc)
 	return acc
 }
 
-// NewAccount makes a Account reader for an object
-func NewAccount(in io.ReadCloser, obj fs.Object) *Account {
-	return NewAccountSizeName(in, obj.Size(), obj.Remote())
+// NewAccount makes a Account reader for an object, accounting it to s
+func (s *StatsInfo) NewAccount(in io.ReadCloser, obj fs.Object) *Account {
+	return s.NewAccountSizeName(in, obj.Size(), obj.Remote())
 }
 
 // WithBuffer - If the file is above a certain size it adds an Async reader
@@ -91,6 +95,15 @@ func (acc *Account) WithBuffer() *Account {
 	return acc
 }
 
+// WithBwLimit makes the account use the bandwidth limit set in ctx by
+// the WithBwLimit function, if any, instead of the global limit
+func (acc *Account) WithBwLimit(ctx context.Context) *Account {
+	if limit, ok := ctx.Value(bwLimitKey).(*bwLimit); ok {
+		acc.limit = limit
+	}
+	return acc
+}
+
 // GetReader returns the underlying io.ReadCloser under any Buffer
 func (acc *Account) GetReader() io.ReadCloser {
 	acc.mu.Lock()
@@ -157,7 +170,7 @@ func (acc *Account) averageLoop() {
 // Check the read is valid
 func (acc *Account) checkRead() (err error) {
 	acc.statmu.Lock()
-	if acc.max >= 0 && Stats.GetBytes() >= acc.max {
+	if acc.max >= 0 && acc.stats.GetBytes() >= acc.max {
 		acc.statmu.Unlock()
 		return ErrorMaxTransferLimitReached
 	}
@@ -177,9 +190,13 @@ func (acc *Account) accountRead(n int) {
 	acc.bytes += int64(n)
 	acc.statmu.Unlock()
 
-	Stats.Bytes(int64(n))
+	acc.stats.Bytes(int64(n))
 
-	limitBandwidth(n)
+	if acc.limit != nil {
+		acc.limit.wait(n)
+	} else {
+		limitBandwidth(n)
+	}
 }
 
 // read bytes from the io.Reader passed in and account them
@@ -219,7 +236,8 @@ func (acc *Account) Close() error {
 	}
 	acc.closed = true
 	close(acc.exit)
-	Stats.inProgress.clear(acc.name)
+	acc.stats.inProgress.clear(acc.name)
+	acc.stats.accountClosed(acc)
 	if acc.close == nil {
 		return nil
 	}
@@ -27,36 +27,36 @@ var (
 
 func TestNewAccountSizeName(t *testing.T) {
 	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1}))
-	acc := NewAccountSizeName(in, 1, "test")
+	acc := GlobalStats().NewAccountSizeName(in, 1, "test")
 	assert.Equal(t, in, acc.in)
-	assert.Equal(t, acc, Stats.inProgress.get("test"))
+	assert.Equal(t, acc, GlobalStats().inProgress.get("test"))
 	err := acc.Close()
 	assert.NoError(t, err)
-	assert.Nil(t, Stats.inProgress.get("test"))
+	assert.Nil(t, GlobalStats().inProgress.get("test"))
 }
 
 func TestNewAccount(t *testing.T) {
 	obj := mockobject.Object("test")
 	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1}))
-	acc := NewAccount(in, obj)
+	acc := GlobalStats().NewAccount(in, obj)
 	assert.Equal(t, in, acc.in)
-	assert.Equal(t, acc, Stats.inProgress.get("test"))
+	assert.Equal(t, acc, GlobalStats().inProgress.get("test"))
 	err := acc.Close()
 	assert.NoError(t, err)
-	assert.Nil(t, Stats.inProgress.get("test"))
+	assert.Nil(t, GlobalStats().inProgress.get("test"))
 }
 
 func TestAccountWithBuffer(t *testing.T) {
 	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1}))
 
-	acc := NewAccountSizeName(in, -1, "test")
+	acc := GlobalStats().NewAccountSizeName(in, -1, "test")
 	acc.WithBuffer()
 	// should have a buffer for an unknown size
 	_, ok := acc.in.(*asyncreader.AsyncReader)
 	require.True(t, ok)
 	assert.NoError(t, acc.Close())
 
-	acc = NewAccountSizeName(in, 1, "test")
+	acc = GlobalStats().NewAccountSizeName(in, 1, "test")
 	acc.WithBuffer()
 	// should not have a buffer for a small size
 	_, ok = acc.in.(*asyncreader.AsyncReader)
@@ -66,7 +66,7 @@ func TestAccountWithBuffer(t *testing.T) {
 
 func TestAccountGetUpdateReader(t *testing.T) {
 	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1}))
-	acc := NewAccountSizeName(in, 1, "test")
+	acc := GlobalStats().NewAccountSizeName(in, 1, "test")
 
 	assert.Equal(t, in, acc.GetReader())
 
@@ -80,12 +80,12 @@ func TestAccountGetUpdateReader(t *testing.T) {
 
 func TestAccountRead(t *testing.T) {
 	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1, 2, 3}))
-	acc := NewAccountSizeName(in, 1, "test")
+	acc := GlobalStats().NewAccountSizeName(in, 1, "test")
 
 	assert.True(t, acc.start.IsZero())
 	assert.Equal(t, 0, acc.lpBytes)
 	assert.Equal(t, int64(0), acc.bytes)
-	assert.Equal(t, int64(0), Stats.bytes)
+	assert.Equal(t, int64(0), GlobalStats().bytes)
 
 	var buf = make([]byte, 2)
 	n, err := acc.Read(buf)
@@ -96,7 +96,7 @@ func TestAccountRead(t *testing.T) {
 	assert.False(t, acc.start.IsZero())
 	assert.Equal(t, 2, acc.lpBytes)
 	assert.Equal(t, int64(2), acc.bytes)
-	assert.Equal(t, int64(2), Stats.bytes)
+	assert.Equal(t, int64(2), GlobalStats().bytes)
 
 	n, err = acc.Read(buf)
 	assert.NoError(t, err)
@@ -112,7 +112,7 @@ func TestAccountRead(t *testing.T) {
 
 func TestAccountString(t *testing.T) {
 	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1, 2, 3}))
-	acc := NewAccountSizeName(in, 3, "test")
+	acc := GlobalStats().NewAccountSizeName(in, 3, "test")
 
 	// FIXME not an exhaustive test!
 
@@ -131,7 +131,7 @@ func TestAccountString(t *testing.T) {
 // Test the Accounter interface methods on Account and accountStream
 func TestAccountAccounter(t *testing.T) {
 	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1, 2, 3}))
-	acc := NewAccountSizeName(in, 3, "test")
+	acc := GlobalStats().NewAccountSizeName(in, 3, "test")
 
 	assert.True(t, in == acc.OldStream())
 
@@ -192,10 +192,10 @@ func TestAccountMaxTransfer(t *testing.T) {
 	defer func() {
 		fs.Config.MaxTransfer = old
 	}()
-	Stats.ResetCounters()
+	GlobalStats().ResetCounters()
 
 	in := ioutil.NopCloser(bytes.NewBuffer(make([]byte, 100)))
-	acc := NewAccountSizeName(in, 1, "test")
+	acc := GlobalStats().NewAccountSizeName(in, 1, "test")
 
 	var b = make([]byte, 10)
 
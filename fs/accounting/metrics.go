@@ -0,0 +1,79 @@
+// Stats metrics for the /metrics endpoint of the rc server
+
+package accounting
+
+import (
+	"sync/atomic"
+
+	"github.com/ncw/rclone/fs/rc"
+)
+
+func init() {
+	rc.AddMetrics(metrics)
+}
+
+// metricTotals holds the counters for the metrics
+//
+// Unlike the stats these are never reset or removed with their
+// group so they only ever go up, as counters should.
+type metricTotals struct {
+	bytes     int64 // use atomic
+	checks    int64 // use atomic
+	transfers int64 // use atomic
+	deletes   int64 // use atomic
+	errors    int64 // use atomic
+}
+
+// totals counts everything done since the process started
+var totals metricTotals
+
+// add n to the counter if it is positive
+func (t *metricTotals) add(counter *int64, n int64) {
+	if n > 0 {
+		atomic.AddInt64(counter, n)
+	}
+}
+
+// metrics returns the totals and the stats in progress of all the
+// groups added together as metrics
+func metrics() []rc.Metric {
+	s := groups.sum()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return []rc.Metric{{
+		Name:  "rclone_bytes_transferred_total",
+		Help:  "Total bytes transferred.",
+		Type:  "counter",
+		Value: float64(atomic.LoadInt64(&totals.bytes)),
+	}, {
+		Name:  "rclone_checked_files_total",
+		Help:  "Total number of files checked.",
+		Type:  "counter",
+		Value: float64(atomic.LoadInt64(&totals.checks)),
+	}, {
+		Name:  "rclone_files_transferred_total",
+		Help:  "Total number of files transferred.",
+		Type:  "counter",
+		Value: float64(atomic.LoadInt64(&totals.transfers)),
+	}, {
+		Name:  "rclone_files_deleted_total",
+		Help:  "Total number of files deleted.",
+		Type:  "counter",
+		Value: float64(atomic.LoadInt64(&totals.deletes)),
+	}, {
+		Name:  "rclone_errors_total",
+		Help:  "Total number of errors.",
+		Type:  "counter",
+		Value: float64(atomic.LoadInt64(&totals.errors)),
+	}, {
+		Name:  "rclone_checks_in_progress",
+		Help:  "Number of files being checked.",
+		Type:  "gauge",
+		Value: float64(s.checking.count()),
+	}, {
+		Name:  "rclone_transfers_in_progress",
+		Help:  "Number of files being transferred.",
+		Type:  "gauge",
+		Value: float64(s.transferring.count()),
+	}}
+}
@@ -0,0 +1,48 @@
+package accounting
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsOnlyGoUp(t *testing.T) {
+	defer DeleteStatsGroup("test-metrics")
+
+	values := func() map[string]float64 {
+		out := make(map[string]float64)
+		for _, m := range metrics() {
+			out[m.Name] = m.Value
+		}
+		return out
+	}
+	before := values()
+
+	s := StatsGroup("test-metrics")
+	s.Bytes(100)
+	s.Transferring("a")
+	s.DoneTransferring("a", true)
+	s.Checking("b")
+	s.DoneChecking("b")
+	s.Deletes(2)
+	s.Error(errors.New("boom"))
+	s.Errors(-1)
+
+	want := map[string]float64{
+		"rclone_bytes_transferred_total": 100,
+		"rclone_files_transferred_total": 1,
+		"rclone_checked_files_total":     1,
+		"rclone_files_deleted_total":     2,
+		"rclone_errors_total":            1,
+	}
+	after := values()
+	for name, delta := range want {
+		assert.Equal(t, before[name]+delta, after[name], name)
+	}
+
+	// Resetting or deleting the group leaves the counters alone
+	s.ResetCounters()
+	DeleteStatsGroup("test-metrics")
+	assert.Equal(t, after, values())
+}
@@ -2,7 +2,7 @@ package accounting
 
 import (
 	"bytes"
-	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"sync"
@@ -13,59 +13,9 @@ import (
 	"github.com/ncw/rclone/fs/rc"
 )
 
-var (
-	// Stats is global statistics counter
-	Stats = NewStats()
-)
-
-func init() {
-	// Set the function pointer up in fs
-	fs.CountError = Stats.Error
-
-	rc.Add(rc.Call{
-		Path:  "core/stats",
-		Fn:    Stats.RemoteStats,
-		Title: "Returns stats about current transfers.",
-		Help: `
-This returns all available stats
-
-	rclone rc core/stats
-
-Returns the following values:
-
-` + "```" + `
-{
-	"speed": average speed in bytes/sec since start of the process,
-	"bytes": total transferred bytes since the start of the process,
-	"errors": number of errors,
-	"fatalError": whether there has been at least one FatalError,
-	"retryError": whether there has been at least one non-NoRetryError,
-	"checks": number of checked files,
-	"transfers": number of transferred files,
-	"deletes" : number of deleted files,
-	"elapsedTime": time in seconds since the start of the process,
-	"lastError": last occurred error,
-	"transferring": an array of currently active file transfers:
-		[
-			{
-				"bytes": total transferred bytes for this file,
-				"eta": estimated time in seconds until file transfer completion
-				"name": name of the file,
-				"percentage": progress of the file transfer in percent,
-				"speed": speed in bytes/sec,
-				"speedAvg": speed in bytes/sec as an exponentially weighted moving average,
-				"size": size of the file in bytes
-			}
-		],
-	"checking": an array of names of currently active file checks
-		[]
-}
-` + "```" + `
-Values for "transferring", "checking" and "lastError" are only assigned if data is available.
-The value for "eta" is null if an eta cannot be determined.
-`,
-	})
-}
+// maxTransferred is the number of completed transfers remembered by
+// each StatsInfo
+const maxTransferred = 100
 
 // StatsInfo accounts all transfers
 type StatsInfo struct {
@@ -89,20 +39,34 @@ type StatsInfo struct {
 	deletes           int64
 	start             time.Time
 	inProgress        *inProgress
+	current           map[string]*Transferred // transfers in progress
+	transferred       []Transferred           // the last maxTransferred completed transfers
+}
+
+// Transferred describes a completed transfer
+type Transferred struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	Bytes       int64     `json:"bytes"`
+	Success     bool      `json:"success"`
+	StartedAt   time.Time `json:"startedAt"`
+	CompletedAt time.Time `json:"completedAt"`
 }
 
 // NewStats creates an initialised StatsInfo
 func NewStats() *StatsInfo {
+	ip := newInProgress()
 	return &StatsInfo{
-		checking:     newStringSet(fs.Config.Checkers, "checking"),
-		transferring: newStringSet(fs.Config.Transfers, "transferring"),
+		checking:     newStringSet(fs.Config.Checkers, "checking", ip),
+		transferring: newStringSet(fs.Config.Transfers, "transferring", ip),
 		start:        time.Now(),
-		inProgress:   newInProgress(),
+		inProgress:   ip,
+		current:      make(map[string]*Transferred),
 	}
 }
 
 // RemoteStats returns stats for rc
-func (s *StatsInfo) RemoteStats(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+func (s *StatsInfo) RemoteStats() (out rc.Params, err error) {
 	out = make(rc.Params)
 	s.mu.RLock()
 	dt := time.Now().Sub(s.start)
@@ -143,8 +107,9 @@ func (s *StatsInfo) RemoteStats(ctx context.Context, in rc.Params) (out rc.Param
 		}
 		out["transferring"] = t
 	}
-	if s.errors > 0 {
-		out["lastError"] = s.lastError
+	if s.errors > 0 && s.lastError != nil {
+		// Use the text of the error so it marshals to JSON
+		out["lastError"] = s.lastError.Error()
 	}
 	return out, nil
 }
@@ -290,15 +255,43 @@ Elapsed time:  %10v
 }
 
 // Log outputs the StatsInfo to the log
+//
+// With --stats-format json the stats are output in the same format as
+// the core/stats rc call.
 func (s *StatsInfo) Log() {
+	if fs.Config.StatsFormat == "json" {
+		s.logJSON()
+		return
+	}
 	fs.LogLevelPrintf(fs.Config.StatsLogLevel, nil, "%v\n", s)
 }
 
+// logJSON outputs the StatsInfo to the log as JSON
+func (s *StatsInfo) logJSON() {
+	out, err := s.RemoteStats()
+	if err != nil {
+		fs.Errorf(nil, "Failed to read stats: %v", err)
+		return
+	}
+	// The JSON log has a field for the stats
+	if fs.Config.UseJSONLog {
+		fs.LogLevelPrintf(fs.Config.StatsLogLevel, nil, "Stats%v", fs.LogValue("stats", out))
+		return
+	}
+	buf, err := json.Marshal(out)
+	if err != nil {
+		fs.Errorf(nil, "Failed to marshal stats: %v", err)
+		return
+	}
+	fs.LogLevelPrintf(fs.Config.StatsLogLevel, nil, "%s\n", buf)
+}
+
 // Bytes updates the stats for bytes bytes
 func (s *StatsInfo) Bytes(bytes int64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.bytes += bytes
+	totals.add(&totals.bytes, bytes)
 }
 
 // GetBytes returns the number of bytes transferred so far
@@ -313,6 +306,7 @@ func (s *StatsInfo) Errors(errors int64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.errors += errors
+	totals.add(&totals.errors, errors)
 }
 
 // GetErrors reads the number of errors
@@ -369,6 +363,7 @@ func (s *StatsInfo) Deletes(deletes int64) int64 {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.deletes += deletes
+	totals.add(&totals.deletes, deletes)
 	return s.deletes
 }
 
@@ -385,6 +380,7 @@ func (s *StatsInfo) ResetCounters() {
 	s.checks = 0
 	s.transfers = 0
 	s.deletes = 0
+	s.transferred = nil
 }
 
 // ResetErrors sets the errors count to 0 and resets lastError, fatalError and retryError
@@ -413,6 +409,7 @@ func (s *StatsInfo) Error(err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.errors++
+	totals.add(&totals.errors, 1)
 	s.lastError = err
 	switch {
 	case fserrors.IsFatalError(err):
@@ -447,6 +444,7 @@ func (s *StatsInfo) DoneChecking(remote string) {
 	s.mu.Lock()
 	s.checks++
 	s.mu.Unlock()
+	totals.add(&totals.checks, 1)
 }
 
 // GetTransfers reads the number of transfers
@@ -459,6 +457,24 @@ func (s *StatsInfo) GetTransfers() int64 {
 // Transferring adds a transfer into the stats
 func (s *StatsInfo) Transferring(remote string) {
 	s.transferring.add(remote)
+	s.mu.Lock()
+	s.current[remote] = &Transferred{
+		Name:      remote,
+		Size:      -1,
+		StartedAt: time.Now(),
+	}
+	s.mu.Unlock()
+}
+
+// accountClosed records the size and bytes transferred of the
+// transfer acc was accounting, if any
+func (s *StatsInfo) accountClosed(acc *Account) {
+	bytes, size := acc.progress()
+	s.mu.Lock()
+	if tr, ok := s.current[acc.name]; ok {
+		tr.Size, tr.Bytes = size, bytes
+	}
+	s.mu.Unlock()
 }
 
 // DoneTransferring removes a transfer from the stats
@@ -466,11 +482,30 @@ func (s *StatsInfo) Transferring(remote string) {
 // if ok is true then it increments the transfers count
 func (s *StatsInfo) DoneTransferring(remote string, ok bool) {
 	s.transferring.del(remote)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if ok {
-		s.mu.Lock()
 		s.transfers++
-		s.mu.Unlock()
+		totals.add(&totals.transfers, 1)
+	}
+	tr, found := s.current[remote]
+	if !found {
+		return
+	}
+	delete(s.current, remote)
+	tr.Success = ok
+	tr.CompletedAt = time.Now()
+	if len(s.transferred) >= maxTransferred {
+		s.transferred = append(s.transferred[:0], s.transferred[1:]...)
 	}
+	s.transferred = append(s.transferred, *tr)
+}
+
+// Transferred returns the last completed transfers, oldest first
+func (s *StatsInfo) Transferred() []Transferred {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Transferred{}, s.transferred...)
 }
 
 // SetCheckQueue sets the number of queued checks
@@ -0,0 +1,365 @@
+// Groups of stats so the stats of each rc job can be kept separately
+
+package accounting
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/rc"
+	"github.com/pkg/errors"
+)
+
+// statsGroupKey is the context key for the stats group
+type statsGroupKey struct{}
+
+// WithStatsGroup returns a copy of ctx which accounts to the stats
+// group named group
+func WithStatsGroup(ctx context.Context, group string) context.Context {
+	return context.WithValue(ctx, statsGroupKey{}, group)
+}
+
+// StatsGroupFromContext returns the name of the stats group in ctx
+// if it has one
+func StatsGroupFromContext(ctx context.Context) (group string, ok bool) {
+	if ctx == nil {
+		return "", false
+	}
+	group, ok = ctx.Value(statsGroupKey{}).(string)
+	return group, ok
+}
+
+// statsGroups holds the named stats groups
+type statsGroups struct {
+	mu     sync.Mutex
+	groups map[string]*StatsInfo
+}
+
+// GlobalStatsGroup is the name of the stats group holding the stats
+// of anything not given a group, eg the command line
+const GlobalStatsGroup = "global"
+
+var (
+	// globalStats holds the stats of the GlobalStatsGroup
+	globalStats = NewStats()
+
+	// groups holds the named stats groups
+	groups = &statsGroups{groups: map[string]*StatsInfo{
+		GlobalStatsGroup: globalStats,
+	}}
+)
+
+// Stats returns the stats for the stats group in ctx, or the global
+// stats if it doesn't name one
+func Stats(ctx context.Context) *StatsInfo {
+	group, ok := StatsGroupFromContext(ctx)
+	if !ok {
+		return globalStats
+	}
+	return StatsGroup(group)
+}
+
+// GlobalStats returns the stats of the GlobalStatsGroup
+func GlobalStats() *StatsInfo {
+	return globalStats
+}
+
+// StatsGroup returns the stats for the named group, making them if
+// they don't exist
+func StatsGroup(group string) *StatsInfo {
+	groups.mu.Lock()
+	defer groups.mu.Unlock()
+	s, ok := groups.groups[group]
+	if !ok {
+		s = NewStats()
+		groups.groups[group] = s
+	}
+	return s
+}
+
+// DeleteStatsGroup removes the named group
+//
+// The GlobalStatsGroup can't be removed.
+func DeleteStatsGroup(group string) {
+	if group == GlobalStatsGroup {
+		return
+	}
+	groups.mu.Lock()
+	delete(groups.groups, group)
+	groups.mu.Unlock()
+}
+
+// get returns the stats for the named group or nil if not found
+func (sg *statsGroups) get(group string) *StatsInfo {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	return sg.groups[group]
+}
+
+// names returns the sorted names of the groups
+func (sg *statsGroups) names() []string {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	names := make([]string, 0, len(sg.groups))
+	for name := range sg.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// all returns the stats of all the groups
+func (sg *statsGroups) all() []*StatsInfo {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	all := make([]*StatsInfo, 0, len(sg.groups))
+	for _, s := range sg.groups {
+		all = append(all, s)
+	}
+	return all
+}
+
+// sum returns the stats of all the groups added together
+func (sg *statsGroups) sum() *StatsInfo {
+	sum := NewStats()
+	for _, s := range sg.all() {
+		sum.add(s)
+	}
+	sort.Slice(sum.transferred, func(i, j int) bool {
+		return sum.transferred[i].CompletedAt.Before(sum.transferred[j].CompletedAt)
+	})
+	if len(sum.transferred) > maxTransferred {
+		sum.transferred = sum.transferred[len(sum.transferred)-maxTransferred:]
+	}
+	return sum
+}
+
+// add adds the stats in o to s
+func (s *StatsInfo) add(o *StatsInfo) {
+	o.mu.RLock()
+	s.mu.Lock()
+	s.bytes += o.bytes
+	s.errors += o.errors
+	if o.lastError != nil {
+		s.lastError = o.lastError
+	}
+	s.fatalError = s.fatalError || o.fatalError
+	s.retryError = s.retryError || o.retryError
+	s.checks += o.checks
+	s.checkQueue += o.checkQueue
+	s.checkQueueSize += o.checkQueueSize
+	s.transfers += o.transfers
+	s.transferQueue += o.transferQueue
+	s.transferQueueSize += o.transferQueueSize
+	s.renameQueue += o.renameQueue
+	s.renameQueueSize += o.renameQueueSize
+	s.deletes += o.deletes
+	if o.start.Before(s.start) {
+		s.start = o.start
+	}
+	s.transferred = append(s.transferred, o.transferred...)
+	s.mu.Unlock()
+	o.mu.RUnlock()
+	s.checking.addAll(o.checking)
+	s.transferring.addAll(o.transferring)
+	o.inProgress.mu.Lock()
+	for name, acc := range o.inProgress.m {
+		s.inProgress.set(name, acc)
+	}
+	o.inProgress.mu.Unlock()
+}
+
+// getStats returns the stats for the group parameter in in, or the
+// stats of everything added together if it isn't present
+func getStats(in rc.Params) (*StatsInfo, error) {
+	group, err := in.GetString("group")
+	if rc.IsErrParamNotFound(err) {
+		return groups.sum(), nil
+	} else if err != nil {
+		return nil, err
+	}
+	s := groups.get(group)
+	if s == nil {
+		return nil, errors.Errorf("stats group %q not found", group)
+	}
+	return s, nil
+}
+
+func init() {
+	// Set the function pointer up in fs
+	fs.CountError = func(ctx context.Context, err error) {
+		Stats(ctx).Error(err)
+	}
+
+	rc.Add(rc.Call{
+		Path:  "core/stats",
+		Fn:    rcStats,
+		Title: "Returns stats about current transfers.",
+		Help: `
+This returns all available stats
+
+	rclone rc core/stats
+
+If group is not provided then summed up stats for all groups will be
+returned.
+
+Parameters
+- group - name of the stats group (string)
+
+Returns the following values:
+
+` + "```" + `
+{
+	"speed": average speed in bytes/sec since start of the process,
+	"bytes": total transferred bytes since the start of the process,
+	"errors": number of errors,
+	"fatalError": whether there has been at least one FatalError,
+	"retryError": whether there has been at least one non-NoRetryError,
+	"checks": number of checked files,
+	"transfers": number of transferred files,
+	"deletes" : number of deleted files,
+	"elapsedTime": time in seconds since the start of the process,
+	"lastError": last occurred error,
+	"transferring": an array of currently active file transfers:
+		[
+			{
+				"bytes": total transferred bytes for this file,
+				"eta": estimated time in seconds until file transfer completion
+				"name": name of the file,
+				"percentage": progress of the file transfer in percent,
+				"speed": speed in bytes/sec,
+				"speedAvg": speed in bytes/sec as an exponentially weighted moving average,
+				"size": size of the file in bytes
+			}
+		],
+	"checking": an array of names of currently active file checks
+		[]
+}
+` + "```" + `
+Values for "transferring", "checking" and "lastError" are only assigned if data is available.
+The value for "eta" is null if an eta cannot be determined.
+`,
+	})
+
+	rc.Add(rc.Call{
+		Path:  "core/group-list",
+		Fn:    rcGroupList,
+		Title: "Returns list of stats groups.",
+		Help: `
+This returns list of stats groups currently in memory.
+
+Each rc job started with _async has its own stats group, named
+"job/" followed by the job ID.  The stats of anything else, eg the
+command line, are in the "global" group.
+
+Returns the following values:
+` + "```" + `
+{
+	"groups":  an array of group names:
+		[
+			"global",
+			"job/1",
+			"job/2"
+		]
+}
+` + "```" + `
+`,
+	})
+
+	rc.Add(rc.Call{
+		Path:  "core/stats-reset",
+		Fn:    rcStatsReset,
+		Title: "Reset stats.",
+		Help: `
+This clears counters and errors for all stats or specific stats group
+if group is provided.
+
+Parameters
+- group - name of the stats group (string)
+`,
+	})
+
+	rc.Add(rc.Call{
+		Path:  "core/transferred",
+		Fn:    rcTransferred,
+		Title: "Returns stats about completed transfers.",
+		Help: `
+This returns stats about completed transfers:
+
+	rclone rc core/transferred
+
+If group is not provided then completed transfers for all groups will be
+returned.
+
+Note only the last 100 completed transfers are returned.
+
+Parameters
+- group - name of the stats group (string)
+
+Returns the following values:
+` + "```" + `
+{
+	"transferred":  an array of completed transfers (including failed ones):
+		[
+			{
+				"name": name of the file,
+				"size": size of the file in bytes,
+				"bytes": total transferred bytes for this file,
+				"success": whether the transfer succeeded,
+				"startedAt": time the transfer was started at (RFC3339),
+				"completedAt": time the transfer was completed at (RFC3339)
+			}
+		]
+}
+` + "```" + `
+`,
+	})
+}
+
+// Returns the stats of a group or all the groups
+func rcStats(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	s, err := getStats(in)
+	if err != nil {
+		return nil, err
+	}
+	return s.RemoteStats()
+}
+
+// Returns the names of the groups
+func rcGroupList(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	out = make(rc.Params)
+	out["groups"] = groups.names()
+	return out, nil
+}
+
+// Resets the stats of a group or all the groups
+func rcStatsReset(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	group, err := in.GetString("group")
+	if rc.IsErrParamNotFound(err) {
+		for _, s := range groups.all() {
+			s.ResetCounters()
+		}
+		return out, nil
+	} else if err != nil {
+		return nil, err
+	}
+	s := groups.get(group)
+	if s == nil {
+		return nil, errors.Errorf("stats group %q not found", group)
+	}
+	s.ResetCounters()
+	return out, nil
+}
+
+// Returns the completed transfers of a group or all the groups
+func rcTransferred(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	s, err := getStats(in)
+	if err != nil {
+		return nil, err
+	}
+	out = make(rc.Params)
+	out["transferred"] = s.Transferred()
+	return out, nil
+}
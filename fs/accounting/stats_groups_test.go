@@ -0,0 +1,103 @@
+package accounting
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ncw/rclone/fs/rc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsGroupContext(t *testing.T) {
+	defer DeleteStatsGroup("test-ctx")
+
+	assert.Equal(t, GlobalStats(), Stats(context.Background()))
+
+	ctx := WithStatsGroup(context.Background(), "test-ctx")
+	group, ok := StatsGroupFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "test-ctx", group)
+
+	s := Stats(ctx)
+	assert.NotEqual(t, GlobalStats(), s)
+	assert.Equal(t, s, Stats(ctx))
+	assert.Equal(t, s, StatsGroup("test-ctx"))
+
+	// The global stats are a group like any other
+	assert.Equal(t, GlobalStats(), StatsGroup(GlobalStatsGroup))
+	assert.Equal(t, GlobalStats(), Stats(WithStatsGroup(context.Background(), GlobalStatsGroup)))
+	DeleteStatsGroup(GlobalStatsGroup)
+	assert.Equal(t, GlobalStats(), StatsGroup(GlobalStatsGroup))
+}
+
+func TestStatsGroupRc(t *testing.T) {
+	defer DeleteStatsGroup("test-a")
+	defer DeleteStatsGroup("test-b")
+	GlobalStats().ResetCounters()
+
+	a := StatsGroup("test-a")
+	a.Bytes(10)
+	a.Transferring("a")
+	a.DoneTransferring("a", true)
+	b := StatsGroup("test-b")
+	b.Bytes(5)
+	b.Error(errors.New("boom"))
+
+	call := rc.Calls.Get("core/group-list")
+	require.NotNil(t, call)
+	out, err := call.Fn(context.Background(), rc.Params{})
+	require.NoError(t, err)
+	assert.Contains(t, out["groups"], "test-a")
+	assert.Contains(t, out["groups"], "test-b")
+	assert.Contains(t, out["groups"], GlobalStatsGroup)
+
+	call = rc.Calls.Get("core/stats")
+	require.NotNil(t, call)
+	out, err = call.Fn(context.Background(), rc.Params{"group": "test-a"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), out["bytes"])
+	assert.Equal(t, int64(1), out["transfers"])
+	assert.Equal(t, int64(0), out["errors"])
+
+	out, err = call.Fn(context.Background(), rc.Params{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), out["bytes"])
+	assert.Equal(t, int64(1), out["errors"])
+
+	// The command line stats are in the global group
+	GlobalStats().Bytes(3)
+	out, err = call.Fn(context.Background(), rc.Params{"group": GlobalStatsGroup})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), out["bytes"])
+	out, err = call.Fn(context.Background(), rc.Params{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(18), out["bytes"])
+
+	_, err = call.Fn(context.Background(), rc.Params{"group": "not-found"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not found")
+
+	call = rc.Calls.Get("core/transferred")
+	require.NotNil(t, call)
+	out, err = call.Fn(context.Background(), rc.Params{"group": "test-a"})
+	require.NoError(t, err)
+	transferred := out["transferred"].([]Transferred)
+	require.Equal(t, 1, len(transferred))
+	assert.Equal(t, "a", transferred[0].Name)
+	assert.True(t, transferred[0].Success)
+
+	call = rc.Calls.Get("core/stats-reset")
+	require.NotNil(t, call)
+	_, err = call.Fn(context.Background(), rc.Params{"group": "test-b"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), b.GetBytes())
+	assert.Equal(t, int64(0), b.GetErrors())
+	assert.Equal(t, int64(10), a.GetBytes())
+
+	_, err = call.Fn(context.Background(), rc.Params{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), a.GetBytes())
+	assert.Equal(t, 0, len(a.Transferred()))
+}
@@ -1,14 +1,17 @@
 package accounting
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"testing"
 	"time"
 
+	"github.com/ncw/rclone/fs"
 	"github.com/ncw/rclone/fs/fserrors"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestETA(t *testing.T) {
@@ -128,3 +131,40 @@ func TestStatsError(t *testing.T) {
 	assert.False(t, s.HadRetryError())
 	assert.Equal(t, time.Time{}, s.RetryAfter())
 }
+
+func TestStatsLogJSON(t *testing.T) {
+	oldStatsFormat, oldStatsLogLevel, oldUseJSONLog, oldLogPrint := fs.Config.StatsFormat, fs.Config.StatsLogLevel, fs.Config.UseJSONLog, fs.LogPrint
+	defer func() {
+		fs.Config.StatsFormat, fs.Config.StatsLogLevel, fs.Config.UseJSONLog, fs.LogPrint = oldStatsFormat, oldStatsLogLevel, oldUseJSONLog, oldLogPrint
+	}()
+	var lines []string
+	fs.LogPrint = func(level fs.LogLevel, text string) {
+		lines = append(lines, text)
+	}
+	s := NewStats()
+	s.Bytes(42)
+	s.Error(errors.New("boom"))
+	fs.Config.StatsFormat = "json"
+	fs.Config.StatsLogLevel = fs.LogLevelNotice
+
+	fs.Config.UseJSONLog = false
+	s.Log()
+	fs.Config.UseJSONLog = true
+	s.Log()
+	require.Equal(t, 2, len(lines))
+
+	var stats map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &stats))
+	assert.Equal(t, 42.0, stats["bytes"])
+	assert.Equal(t, 1.0, stats["errors"])
+	assert.Equal(t, "boom", stats["lastError"])
+
+	var entry struct {
+		Msg   string                 `json:"msg"`
+		Stats map[string]interface{} `json:"stats"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, "Stats", entry.Msg)
+	assert.Equal(t, 42.0, entry.Stats["bytes"])
+	assert.Equal(t, "boom", entry.Stats["lastError"])
+}
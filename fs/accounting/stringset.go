@@ -14,13 +14,15 @@ type stringSet struct {
 	mu    sync.RWMutex
 	items map[string]struct{}
 	name  string
+	ip    *inProgress // the accounts of the items in progress
 }
 
 // newStringSet creates a new empty string set of capacity size
-func newStringSet(size int, name string) *stringSet {
+func newStringSet(size int, name string, ip *inProgress) *stringSet {
 	return &stringSet{
 		items: make(map[string]struct{}, size),
 		name:  name,
+		ip:    ip,
 	}
 }
 
@@ -31,6 +33,17 @@ func (ss *stringSet) add(remote string) {
 	ss.mu.Unlock()
 }
 
+// addAll adds all the items in o to the set
+func (ss *stringSet) addAll(o *stringSet) {
+	o.mu.RLock()
+	ss.mu.Lock()
+	for remote := range o.items {
+		ss.items[remote] = struct{}{}
+	}
+	ss.mu.Unlock()
+	o.mu.RUnlock()
+}
+
 // del removes remote from the set
 func (ss *stringSet) del(remote string) {
 	ss.mu.Lock()
@@ -59,7 +72,7 @@ func (ss *stringSet) Strings() []string {
 	strings := make([]string, 0, len(ss.items))
 	for name := range ss.items {
 		var out string
-		if acc := Stats.inProgress.get(name); acc != nil {
+		if acc := ss.ip.get(name); acc != nil {
 			out = acc.String()
 		} else {
 			out = fmt.Sprintf("%*s: %s",
@@ -85,7 +98,7 @@ func (ss *stringSet) progress() (totalBytes, totalSize int64) {
 	ss.mu.RLock()
 	defer ss.mu.RUnlock()
 	for name := range ss.items {
-		if acc := Stats.inProgress.get(name); acc != nil {
+		if acc := ss.ip.get(name); acc != nil {
 			bytes, size := acc.progress()
 			if size >= 0 && bytes >= 0 {
 				totalBytes += bytes
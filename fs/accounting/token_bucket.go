@@ -115,6 +115,42 @@ func limitBandwidth(n int) {
 	tokenBucketMu.Unlock()
 }
 
+type bwLimitKeyType struct{}
+
+// Context key for the bandwidth limit
+var bwLimitKey = bwLimitKeyType{}
+
+// bwLimit is a bandwidth limit shared by the transfers using a context
+type bwLimit struct {
+	tokenBucket *rate.Limiter // nil if unlimited
+}
+
+// wait sleeps for the correct amount of time for the passage of n
+// bytes according to the limit
+func (l *bwLimit) wait(n int) {
+	if l.tokenBucket == nil {
+		return
+	}
+	err := l.tokenBucket.WaitN(context.Background(), n)
+	if err != nil {
+		fs.Errorf(nil, "Token bucket error: %v", err)
+	}
+}
+
+// WithBwLimit returns a copy of ctx with its own bandwidth limit which
+// is shared by all the transfers using it instead of the global
+// limit.  A bandwidth of 0 or less means unlimited.
+//
+// Transfers only use the limit if their Account was set up with
+// Account.WithBwLimit.
+func WithBwLimit(ctx context.Context, bandwidth fs.SizeSuffix) context.Context {
+	limit := &bwLimit{}
+	if bandwidth > 0 {
+		limit.tokenBucket = newTokenBucket(bandwidth)
+	}
+	return context.WithValue(ctx, bwLimitKey, limit)
+}
+
 // SetBwLimit sets the current bandwidth limit
 func SetBwLimit(bandwidth fs.SizeSuffix) {
 	tokenBucketMu.Lock()
@@ -1,6 +1,7 @@
 package fs
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
@@ -212,3 +213,20 @@ func (x BwTimetable) LimitAt(tt time.Time) BwTimeSlot {
 func (x BwTimetable) Type() string {
 	return "BwTimetable"
 }
+
+// UnmarshalJSON unmarshals a string in the format of --bwlimit or the
+// JSON form of a BwTimetable
+func (x *BwTimetable) UnmarshalJSON(in []byte) error {
+	var s string
+	err := json.Unmarshal(in, &s)
+	if err == nil {
+		return x.Set(s)
+	}
+	var slots []BwTimeSlot
+	err = json.Unmarshal(in, &slots)
+	if err != nil {
+		return err
+	}
+	*x = slots
+	return nil
+}
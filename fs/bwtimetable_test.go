@@ -1,6 +1,7 @@
 package fs
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -327,3 +328,33 @@ func TestBwTimetableLimitAt(t *testing.T) {
 		assert.Equal(t, test.want, slot)
 	}
 }
+
+func TestBwTimetableUnmarshalJSON(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want BwTimetable
+		err  bool
+	}{
+		{`"666"`, BwTimetable{BwTimeSlot{DayOfTheWeek: 0, HHMM: 0, Bandwidth: 666 * 1024}}, false},
+		{`"bad"`, nil, true},
+		{`[{"DayOfTheWeek":1,"HHMM":1020,"Bandwidth":1024}]`, BwTimetable{BwTimeSlot{DayOfTheWeek: 1, HHMM: 1020, Bandwidth: 1024}}, false},
+		{`17`, nil, true},
+	} {
+		var tt BwTimetable
+		err := json.Unmarshal([]byte(test.in), &tt)
+		if test.err {
+			assert.Error(t, err, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.want, tt, test.in)
+	}
+
+	// Check it round trips
+	want := BwTimetable{BwTimeSlot{DayOfTheWeek: 2, HHMM: 1340, Bandwidth: 666 * 1024}}
+	out, err := json.Marshal(want)
+	require.NoError(t, err)
+	var got BwTimetable
+	require.NoError(t, json.Unmarshal(out, &got))
+	assert.Equal(t, want, got)
+}
@@ -1,6 +1,7 @@
 package fs
 
 import (
+	"context"
 	"net"
 	"strings"
 	"time"
@@ -25,12 +26,13 @@ var (
 		Errorf(nil, "No config handler to set %q = %q in section %q of the config file", key, value, section)
 	}
 
-	// CountError counts an error.  If any errors have been
-	// counted then it will exit with a non zero error code.
+	// CountError counts an error in the stats for ctx.  If any
+	// errors have been counted then it will exit with a non zero
+	// error code.
 	//
 	// This is a function pointer to decouple the config
 	// implementation from the fs
-	CountError = func(err error) {}
+	CountError = func(ctx context.Context, err error) {}
 
 	// ConfigProvider is the config key used for provider options
 	ConfigProvider = "provider"
@@ -66,6 +68,9 @@ type ConfigInfo struct {
 	NoTraverse             bool
 	NoUpdateModTime        bool
 	DataRateUnit           string
+	Metadata               bool
+	CompareDest            []string
+	CopyDest               []string
 	BackupDir              string
 	Suffix                 string
 	SuffixKeepExtension    bool
@@ -88,6 +93,8 @@ type ConfigInfo struct {
 	StatsOneLine           bool
 	StatsOneLineDate       bool   // If we want a date prefix at all
 	StatsOneLineDateFormat string // If we want to customize the prefix
+	StatsFormat            string // "text" or "json"
+	UseJSONLog             bool
 	Progress               bool
 	Cookie                 bool
 	UseMmap                bool
@@ -100,6 +107,36 @@ type ConfigInfo struct {
 	RcJobExpireInterval    time.Duration
 }
 
+type configContextKeyType struct{}
+
+// Context key for config
+var configContextKey = configContextKeyType{}
+
+// GetConfig returns the config in ctx, or the global config if ctx
+// doesn't have one.
+//
+// The config returned should be treated as read only.  Use
+// AddConfig to make a copy which can be changed.
+func GetConfig(ctx context.Context) *ConfigInfo {
+	if ctx == nil {
+		return Config
+	}
+	c, ok := ctx.Value(configContextKey).(*ConfigInfo)
+	if !ok {
+		return Config
+	}
+	return c
+}
+
+// AddConfig returns a copy of the config in ctx, and a new context
+// containing that copy.  The copy may be changed to alter the config
+// for anything using the new context without affecting the original.
+func AddConfig(ctx context.Context) (context.Context, *ConfigInfo) {
+	c := new(ConfigInfo)
+	*c = *GetConfig(ctx)
+	return context.WithValue(ctx, configContextKey, c), c
+}
+
 // NewConfig creates a new config with everything set to the default
 // value.  These are the ultimate defaults and are overridden by the
 // config module.
@@ -129,6 +166,7 @@ func NewConfig() *ConfigInfo {
 	c.MaxBacklog = 10000
 	// We do not want to set the default here. We use this variable being empty as part of the fall-through of options.
 	//	c.StatsOneLineDateFormat = "2006/01/02 15:04:05 - "
+	c.StatsFormat = "text"
 	c.MultiThreadCutoff = SizeSuffix(250 * 1024 * 1024)
 	c.MultiThreadStreams = 4
 	c.RcJobExpireDuration = 60 * time.Second
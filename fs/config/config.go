@@ -808,7 +808,7 @@ func RemoteConfig(name string) {
 	fmt.Printf("Remote config\n")
 	f := MustFindByName(name)
 	if f.Config != nil {
-		m := fs.ConfigMap(f, name)
+		m := fs.ConfigMap(f, name, nil)
 		f.Config(name, m)
 	}
 }
@@ -1042,13 +1042,12 @@ func NewRemoteName() (name string) {
 	for {
 		fmt.Printf("name> ")
 		name = ReadLine()
-		parts := fspath.Matcher.FindStringSubmatch(name + ":")
 		switch {
 		case name == "":
 			fmt.Printf("Can't use empty name.\n")
 		case driveletter.IsDriveLetter(name):
 			fmt.Printf("Can't use %q as it can be confused with a drive letter.\n", name)
-		case parts == nil:
+		case fspath.CheckConfigName(name) != nil:
 			fmt.Printf("Can't use %q as it has invalid characters in it.\n", name)
 		default:
 			return name
@@ -1297,7 +1296,7 @@ func Authorize(args []string) {
 		getConfigData().SetValue(name, ConfigClientID, args[1])
 		getConfigData().SetValue(name, ConfigClientSecret, args[2])
 	}
-	m := fs.ConfigMap(f, name)
+	m := fs.ConfigMap(f, name, nil)
 	f.Config(name, m)
 }
 
@@ -0,0 +1,36 @@
+package fs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetConfig(t *testing.T) {
+	ctx := context.Background()
+
+	// Check nil
+	config := GetConfig(nil)
+	assert.Equal(t, Config, config)
+
+	// Check empty config
+	config = GetConfig(ctx)
+	assert.Equal(t, Config, config)
+
+	// Check adding a config
+	ctx2, config2 := AddConfig(ctx)
+	config2.Transfers++
+	assert.NotEqual(t, config2, config)
+	assert.Equal(t, Config.Transfers+1, config2.Transfers)
+
+	// Check can get config back
+	config2ctx := GetConfig(ctx2)
+	assert.Equal(t, config2, config2ctx)
+
+	// Check adding a config to a context with a config copies it
+	_, config3 := AddConfig(ctx2)
+	assert.Equal(t, config2.Transfers, config3.Transfers)
+	config3.Transfers++
+	assert.Equal(t, config2.Transfers+1, config3.Transfers)
+}
@@ -241,6 +241,30 @@ func mustNewFilter(opt *Opt) *Filter {
 	return f
 }
 
+type filterContextKeyType struct{}
+
+// Context key for the filter
+var filterContextKey = filterContextKeyType{}
+
+// GetConfig returns the filter in ctx, or the globally active filter
+// if ctx doesn't have one.
+func GetConfig(ctx context.Context) *Filter {
+	if ctx == nil {
+		return Active
+	}
+	f, ok := ctx.Value(filterContextKey).(*Filter)
+	if !ok {
+		return Active
+	}
+	return f
+}
+
+// ReplaceConfig returns a new context containing the filter f which
+// will be used instead of the globally active filter.
+func ReplaceConfig(ctx context.Context, f *Filter) context.Context {
+	return context.WithValue(ctx, filterContextKey, f)
+}
+
 // addDirGlobs adds directory globs from the file glob passed in
 func (f *Filter) addDirGlobs(Include bool, glob string) error {
 	for _, dirGlob := range globToDirGlobs(glob) {
@@ -540,11 +564,12 @@ func (f *Filter) MakeListR(ctx context.Context, NewObject func(ctx context.Conte
 		if !f.HaveFilesFrom() {
 			return errFilesFromNotSet
 		}
+		ci := fs.GetConfig(ctx)
 		var (
-			remotes = make(chan string, fs.Config.Checkers)
+			remotes = make(chan string, ci.Checkers)
 			g       errgroup.Group
 		)
-		for i := 0; i < fs.Config.Checkers; i++ {
+		for i := 0; i < ci.Checkers; i++ {
 			g.Go(func() (err error) {
 				var entries = make(fs.DirEntries, 1)
 				for remote := range remotes {
@@ -589,3 +589,20 @@ func TestFilterMatchesFromDocs(t *testing.T) {
 		}
 	}
 }
+
+func TestGetConfig(t *testing.T) {
+	ctx := context.Background()
+
+	// Check nil
+	assert.Equal(t, Active, GetConfig(nil))
+
+	// Check empty config
+	assert.Equal(t, Active, GetConfig(ctx))
+
+	// Check replacing the filter
+	f, err := NewFilter(nil)
+	require.NoError(t, err)
+	ctx2 := ReplaceConfig(ctx, f)
+	assert.Equal(t, f, GetConfig(ctx2))
+	assert.Equal(t, Active, GetConfig(ctx))
+}
@@ -12,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"sort"
 	"strings"
 	"time"
@@ -41,6 +42,10 @@ const (
 	EntryObject // 1
 )
 
+// LinkSuffix is the suffix added to the name of a file which holds
+// the target of a symbolic link, eg by the local backend with --links
+const LinkSuffix = ".rclonelink"
+
 // Globals
 var (
 	// Filesystem registry
@@ -68,6 +73,7 @@ var (
 	ErrorDirectoryNotEmpty           = errors.New("directory not empty")
 	ErrorImmutableModified           = errors.New("immutable file modified")
 	ErrorPermissionDenied            = errors.New("permission denied")
+	ErrorCantSetMetadata             = errors.New("can't set metadata")
 )
 
 // RegInfo provides information about a filesystem
@@ -422,6 +428,12 @@ func ObjectOptionalInterfaces(o Object) (supported, unsupported []string) {
 	_, ok = o.(GetTierer)
 	store(ok, "GetTier")
 
+	_, ok = o.(Metadataer)
+	store(ok, "Metadata")
+
+	_, ok = o.(SetMetadataer)
+	store(ok, "SetMetadata")
+
 	return supported, unsupported
 }
 
@@ -471,6 +483,7 @@ type Features struct {
 	SetTier                 bool // allows set tier functionality on objects
 	GetTier                 bool // allows to retrieve storage tier of objects
 	ServerSideAcrossConfigs bool // can server side copy between different remotes of the same type
+	SlowHash                bool // reading hashes is expensive, eg because they are computed from the data
 
 	// Purge all files in the root and the root directory
 	//
@@ -721,6 +734,7 @@ func (ft *Features) Mask(f Fs) *Features {
 	ft.BucketBased = ft.BucketBased && mask.BucketBased
 	ft.SetTier = ft.SetTier && mask.SetTier
 	ft.GetTier = ft.GetTier && mask.GetTier
+	ft.SlowHash = ft.SlowHash || mask.SlowHash
 
 	if mask.Purge == nil {
 		ft.Purge = nil
@@ -1004,6 +1018,17 @@ func Find(name string) (*RegInfo, error) {
 	return nil, errors.Errorf("didn't find backend called %q", name)
 }
 
+// findByName looks for the backend called name returning nil if not
+// found
+func findByName(name string) *RegInfo {
+	for _, item := range Registry {
+		if item.Name == name {
+			return item
+		}
+	}
+	return nil
+}
+
 // MustFind looks for an Info object for the type name passed in
 //
 // Services are looked up in the config file
@@ -1019,18 +1044,45 @@ func MustFind(name string) *RegInfo {
 
 // ParseRemote deconstructs a path into configName, fsPath, looking up
 // the fsName in the config file (returning NotFoundInConfigFile if not found)
+//
+// Any connection string parameters in the path, eg
+// "remote,param=value:path", are checked and kept in configName in a
+// canonical form, see fspath.MakeConfigName.  Use ConfigFs to read
+// them.
 func ParseRemote(path string) (fsInfo *RegInfo, configName, fsPath string, err error) {
+	var params configmap.Simple
+	fsInfo, configName, fsPath, params, err = parseRemote(path)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return fsInfo, fspath.MakeConfigName(configName, params), fsPath, nil
+}
+
+// parseRemote deconstructs a path into the name of the remote in the
+// config, fsPath and the connection string parameters, checking the
+// parameters are options of the backend.
+func parseRemote(path string) (fsInfo *RegInfo, configName, fsPath string, params configmap.Simple, err error) {
 	configName, fsPath = fspath.Parse(path)
+	configName, params, err = fspath.ParseConfigName(configName)
+	if err != nil {
+		return nil, "", "", nil, err
+	}
 	var fsName string
 	var ok bool
 	if configName != "" {
 		if strings.HasPrefix(configName, ":") {
 			fsName = configName[1:]
 		} else {
-			m := ConfigMap(nil, configName)
+			m := ConfigMap(nil, configName, nil)
 			fsName, ok = m.Get("type")
 			if !ok {
-				return nil, "", "", ErrorNotFoundInConfigFile
+				// Allow "backend,param=value:" for backends
+				// not named in the config file
+				if params == nil || findByName(configName) == nil {
+					return nil, "", "", nil, ErrorNotFoundInConfigFile
+				}
+				fsName = configName
+				configName = ":" + configName
 			}
 		}
 	} else {
@@ -1038,7 +1090,25 @@ func ParseRemote(path string) (fsInfo *RegInfo, configName, fsPath string, err e
 		configName = "local"
 	}
 	fsInfo, err = Find(fsName)
-	return fsInfo, configName, fsPath, err
+	if err != nil {
+		return nil, "", "", nil, err
+	}
+	for key := range params {
+		if !fsInfo.hasOption(key) {
+			return nil, "", "", nil, errors.Errorf("connection string parameter %q isn't an option of backend %q", key, fsInfo.Name)
+		}
+	}
+	return fsInfo, configName, fsPath, params, nil
+}
+
+// hasOption returns true if the backend has an option called name
+func (ri *RegInfo) hasOption(name string) bool {
+	for _, o := range ri.Options {
+		if o.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
 // A configmap.Getter to read from the environment RCLONE_CONFIG_backend_option_name
@@ -1102,16 +1172,23 @@ func (section getConfigFile) Get(key string) (value string, ok bool) {
 }
 
 // ConfigMap creates a configmap.Map from the *RegInfo and the
-// configName passed in.
+// configName passed in.  If connectionStringConfig is not nil then
+// its values, parsed from a connection string, override all the
+// others.
 //
 // If fsInfo is nil then the returned configmap.Map should only be
 // used for reading non backend specific parameters, such as "type".
-func ConfigMap(fsInfo *RegInfo, configName string) (config *configmap.Map) {
+func ConfigMap(fsInfo *RegInfo, configName string, connectionStringConfig configmap.Simple) (config *configmap.Map) {
 	// Create the config
 	config = configmap.New()
 
 	// Read the config, more specific to least specific
 
+	// connection string
+	if connectionStringConfig != nil {
+		config.AddGetter(connectionStringConfig)
+	}
+
 	// flag values
 	if fsInfo != nil {
 		config.AddGetter(&regInfoValues{fsInfo, false})
@@ -1140,23 +1217,34 @@ func ConfigMap(fsInfo *RegInfo, configName string) (config *configmap.Map) {
 
 // ConfigFs makes the config for calling NewFs with.
 //
-// It parses the path which is of the form remote:path
+// It parses the path which is of the form remote:path or
+// remote,param=value,...:path
 //
 // Remotes are looked up in the config file.  If the remote isn't
-// found then NotFoundInConfigFile will be returned.
+// found then NotFoundInConfigFile will be returned.  Any connection
+// string parameters override the config of the remote.
+//
+// The configName returned includes the connection string parameters
+// in a canonical form so the Fs made from it can be told apart from
+// the ones made with different parameters, and passing
+// configName+":"+fsPath to ConfigFs again gives the same config.
 func ConfigFs(path string) (fsInfo *RegInfo, configName, fsPath string, config *configmap.Map, err error) {
 	// Parse the remote path
-	fsInfo, configName, fsPath, err = ParseRemote(path)
+	var params configmap.Simple
+	fsInfo, configName, fsPath, params, err = parseRemote(path)
 	if err != nil {
 		return
 	}
-	config = ConfigMap(fsInfo, configName)
+	config = ConfigMap(fsInfo, configName, params)
+	configName = fspath.MakeConfigName(configName, params)
 	return
 }
 
 // NewFs makes a new Fs object from the path
 //
-// The path is of the form remote:path
+// The path is of the form remote:path, :backend:path, or either of
+// those with connection string parameters overriding the config, eg
+// remote,param=value:path
 //
 // Remotes are looked up in the config file.  If the remote isn't
 // found then NotFoundInConfigFile will be returned.
@@ -1236,9 +1324,13 @@ type logCalculator struct {
 }
 
 // NewPacer creates a Pacer for the given Fs and Calculator.
+//
+// The calls made through the Pacer are totalled in the pacer metrics
+// under the name of the package which called NewPacer, eg "s3".
 func NewPacer(c pacer.Calculator) *Pacer {
 	p := &Pacer{
 		Pacer: pacer.New(
+			pacer.NameOption(callerPackage()),
 			pacer.InvokerOption(pacerInvoker),
 			pacer.MaxConnectionsOption(Config.Checkers+Config.Transfers),
 			pacer.RetriesOption(Config.LowLevelRetries),
@@ -1249,6 +1341,24 @@ func NewPacer(c pacer.Calculator) *Pacer {
 	return p
 }
 
+// callerPackage returns the last element of the package name of the
+// caller of the function calling callerPackage, eg "s3" when called
+// from backend/s3
+func callerPackage() string {
+	pc, _, _, ok := runtime.Caller(2)
+	if !ok {
+		return "unknown"
+	}
+	name := runtime.FuncForPC(pc).Name()
+	if slash := strings.LastIndex(name, "/"); slash >= 0 {
+		name = name[slash+1:]
+	}
+	if dot := strings.Index(name, "."); dot >= 0 {
+		name = name[:dot]
+	}
+	return name
+}
+
 func (d *logCalculator) Calculate(state pacer.State) time.Duration {
 	oldSleepTime := state.SleepTime
 	newSleepTime := d.Calculator.Calculate(state)
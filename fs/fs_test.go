@@ -147,3 +147,82 @@ func TestPacerCallNoRetry(t *testing.T) {
 	require.Equal(t, 1, dp.called)
 	require.Implements(t, (*fserrors.Retrier)(nil), err)
 }
+
+func TestPacerMetrics(t *testing.T) {
+	before := pacer.GetMetrics()["fs"]
+	p := NewPacer(pacer.NewDefault(pacer.MinSleep(1*time.Millisecond), pacer.MaxSleep(2*time.Millisecond)))
+
+	dp := &dummyPaced{retry: true}
+	_ = p.CallNoRetry(dp.fn)
+	after := pacer.GetMetrics()["fs"]
+	assert.Equal(t, before.Calls+1, after.Calls)
+	assert.Equal(t, before.Retries+1, after.Retries)
+}
+
+func TestConfigFsConnectionString(t *testing.T) {
+	Register(&RegInfo{
+		Name: "connstringtest",
+		Options: Options{{
+			Name: "opt_one",
+		}, {
+			Name: "opt_two",
+		}},
+	})
+	oldConfigFileGet := ConfigFileGet
+	defer func() { ConfigFileGet = oldConfigFileGet }()
+	ConfigFileGet = func(section, key string) (string, bool) {
+		if section != "myremote" {
+			return "", false
+		}
+		value, ok := map[string]string{
+			"type":    "connstringtest",
+			"opt_one": "file1",
+			"opt_two": "file2",
+		}[key]
+		return value, ok
+	}
+
+	for _, test := range []struct {
+		in             string
+		wantConfigName string
+		wantFsPath     string
+		wantOptOne     string
+		wantOptTwo     string
+		wantErr        string
+	}{
+		{"myremote:path", "myremote", "path", "file1", "file2", ""},
+		{"myremote,opt_one=one:path", "myremote,opt_one=one", "path", "one", "file2", ""},
+		{"myremote,opt-one,opt_two='a:b':path", "myremote,opt_one=true,opt_two='a:b'", "path", "true", "a:b", ""},
+		{":connstringtest,opt_two=two:", ":connstringtest,opt_two=two", "", "", "two", ""},
+		{`connstringtest,opt_one=http\://x:path`, ":connstringtest,opt_one='http://x'", "path", "http://x", "", ""},
+		{"connstringtest:path", "", "", "", "", "didn't find section in config file"},
+		{"notfound,opt_one=one:path", "", "", "", "", "didn't find section in config file"},
+		{"myremote,potato=one:path", "", "", "", "", `connection string parameter "potato" isn't an option of backend "connstringtest"`},
+		{"myremote,,opt_one=one:path", "", "", "", "", "empty parameter"},
+	} {
+		fsInfo, configName, fsPath, config, err := ConfigFs(test.in)
+		if test.wantErr != "" {
+			require.Error(t, err, test.in)
+			assert.Contains(t, err.Error(), test.wantErr, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.Equal(t, "connstringtest", fsInfo.Name, test.in)
+		assert.Equal(t, test.wantConfigName, configName, test.in)
+		assert.Equal(t, test.wantFsPath, fsPath, test.in)
+		optOne, _ := config.Get("opt_one")
+		assert.Equal(t, test.wantOptOne, optOne, test.in)
+		optTwo, _ := config.Get("opt_two")
+		assert.Equal(t, test.wantOptTwo, optTwo, test.in)
+
+		// Check the config name round trips
+		_, configName2, fsPath2, config2, err := ConfigFs(configName + ":" + fsPath)
+		require.NoError(t, err, test.in)
+		assert.Equal(t, configName, configName2, test.in)
+		assert.Equal(t, fsPath, fsPath2, test.in)
+		optOne2, _ := config2.Get("opt_one")
+		assert.Equal(t, optOne, optOne2, test.in)
+		optTwo2, _ := config2.Get("opt_two")
+		assert.Equal(t, optTwo, optTwo2, test.in)
+	}
+}
@@ -5,13 +5,37 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
+	"github.com/ncw/rclone/fs/config/configmap"
 	"github.com/ncw/rclone/fs/driveletter"
+	"github.com/pkg/errors"
 )
 
 // Matcher is a pattern to match an rclone URL
-var Matcher = regexp.MustCompile(`^(:?[\w_ -]+):(.*)$`)
+//
+// The remote name may be followed by connection string parameters,
+// eg "remote,param=value:path".  The parameter values may contain a
+// ":" if it is quoted or escaped.
+var Matcher = regexp.MustCompile(`^(:?[\w_ -]+(?:,(?:[^:"'\\]|\\.|"(?:[^"]|"")*"|'(?:[^']|'')*')*)?):(.*)$`)
+
+// configNameMatcher is a pattern to match a valid remote name in the
+// config file
+var configNameMatcher = regexp.MustCompile(`^[\w_ -]+$`)
+
+// ErrorConfigNameInvalid is returned by CheckConfigName if the name
+// can't be used as a remote name
+var ErrorConfigNameInvalid = errors.New("remote name may only contain 0-9, A-Z, a-z, _, - and space")
+
+// CheckConfigName returns an error if configName isn't a valid name
+// for a remote in the config file
+func CheckConfigName(configName string) error {
+	if !configNameMatcher.MatchString(configName) {
+		return ErrorConfigNameInvalid
+	}
+	return nil
+}
 
 // Parse deconstructs a remote path into configName and fsPath
 //
@@ -20,6 +44,10 @@ var Matcher = regexp.MustCompile(`^(:?[\w_ -]+):(.*)$`)
 // So "remote:path/to/dir" will return "remote", "path/to/dir"
 // and "/path/to/local" will return ("", "/path/to/local")
 //
+// The configName includes any connection string parameters, so
+// "remote,param=value:path" will return "remote,param=value", "path".
+// Use ParseConfigName to split them up.
+//
 // Note that this will turn \ into / in the fsPath on Windows
 func Parse(path string) (configName, fsPath string) {
 	parts := Matcher.FindStringSubmatch(path)
@@ -32,6 +60,138 @@ func Parse(path string) (configName, fsPath string) {
 	return configName, fsPath
 }
 
+// ParseConfigName splits a configName as returned from Parse into the
+// name of the remote and its connection string parameters.
+//
+// So "remote,param=value,flag" will return "remote" and the
+// parameters {"param": "value", "flag": "true"}.
+//
+// A parameter with no value is set to "true".  Any "-" in parameter
+// names are turned into "_".  Parameter values can be quoted with "
+// or ' (use two quotes to put a quote in a quoted value), or have a
+// single character escaped with \, eg "endpoint=http\://host" or
+// "endpoint='http://host'".
+//
+// params will be nil if there aren't any parameters.
+func ParseConfigName(configName string) (name string, params configmap.Simple, err error) {
+	i := strings.IndexRune(configName, ',')
+	if i < 0 {
+		return configName, nil, nil
+	}
+	name, rest := configName[:i], configName[i+1:]
+	params = configmap.Simple{}
+	for {
+		var key, value string
+		key, value, rest, err = parseParam(rest)
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "bad connection string %q", configName)
+		}
+		params[key] = value
+		if rest == "" {
+			break
+		}
+	}
+	return name, params, nil
+}
+
+// MakeConfigName is the inverse of ParseConfigName.  It joins the
+// name of a remote and its connection string parameters into a
+// configName which Parse and ParseConfigName can read back.
+//
+// The parameters are sorted and their values quoted where necessary
+// so the same parameters always give the same configName.
+func MakeConfigName(name string, params configmap.Simple) string {
+	if len(params) == 0 {
+		return name
+	}
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var buf strings.Builder
+	buf.WriteString(name)
+	for _, key := range keys {
+		buf.WriteRune(',')
+		buf.WriteString(key)
+		buf.WriteRune('=')
+		value := params[key]
+		if strings.ContainsAny(value, `,:"'\`) {
+			value = "'" + strings.Replace(value, "'", "''", -1) + "'"
+		}
+		buf.WriteString(value)
+	}
+	return buf.String()
+}
+
+// parseParam parses the first "key=value" parameter from in
+// returning the rest of in after the separating comma
+func parseParam(in string) (key, value, rest string, err error) {
+	var (
+		buf      strings.Builder
+		hasValue = false
+		escaped  = false
+		quote    rune // quote character if in a quoted value
+		closed   rune // quote character which was closed at closedAt
+		closedAt = -1
+		end      = len(in)
+	)
+loop:
+	for i, c := range in {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if c == quote {
+				closed, closedAt, quote = quote, i, 0
+				continue
+			}
+		case c == '\\':
+			escaped = true
+			continue
+		case c == ',':
+			end = i
+			break loop
+		case hasValue && (c == '"' || c == '\''):
+			quote = c
+			if c == closed && closedAt == i-1 {
+				// doubled quote stands for a quote
+				break
+			}
+			continue
+		case !hasValue && c == '=':
+			key = buf.String()
+			buf.Reset()
+			hasValue = true
+			continue
+		}
+		buf.WriteRune(c)
+	}
+	switch {
+	case escaped:
+		return "", "", "", errors.New("\\ at end of parameter")
+	case quote != 0:
+		return "", "", "", errors.Errorf("unterminated quote %c", quote)
+	}
+	if hasValue {
+		value = buf.String()
+	} else {
+		key = buf.String()
+		value = "true"
+	}
+	key = strings.Replace(key, "-", "_", -1)
+	if key == "" {
+		return "", "", "", errors.New("empty parameter name")
+	}
+	if end < len(in) {
+		rest = in[end+1:]
+		if rest == "" {
+			return "", "", "", errors.New("empty parameter")
+		}
+	}
+	return key, value, rest, nil
+}
+
 // Split splits a remote into a parent and a leaf
 //
 // if it returns leaf as an empty string then remote is a directory
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/ncw/rclone/fs/config/configmap"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParse(t *testing.T) {
@@ -17,6 +19,12 @@ func TestParse(t *testing.T) {
 		{"remote:path/to/file", "remote", "path/to/file"},
 		{"remote:/path/to/file", "remote", "/path/to/file"},
 		{":backend:/path/to/file", ":backend", "/path/to/file"},
+		{"remote,param=value:path", "remote,param=value", "path"},
+		{":backend,flag,param=value:path", ":backend,flag,param=value", "path"},
+		{`remote,endpoint=http\://host\:8080,x=y:path:with:colons`, `remote,endpoint=http\://host\:8080,x=y`, "path:with:colons"},
+		{`remote,endpoint="http://host:8080":path`, `remote,endpoint="http://host:8080"`, "path"},
+		{`remote,endpoint='http://host:8080':path`, `remote,endpoint='http://host:8080'`, "path"},
+		{"remote:path,param=value:x", "remote", "path,param=value:x"},
 	} {
 		gotConfigName, gotFsPath := Parse(test.in)
 		assert.Equal(t, test.wantConfigName, gotConfigName)
@@ -24,6 +32,91 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParseConfigName(t *testing.T) {
+	for _, test := range []struct {
+		in         string
+		wantName   string
+		wantParams configmap.Simple
+		wantErr    string
+	}{
+		{"remote", "remote", nil, ""},
+		{":backend", ":backend", nil, ""},
+		{"remote,param=value", "remote", configmap.Simple{"param": "value"}, ""},
+		{"remote,flag", "remote", configmap.Simple{"flag": "true"}, ""},
+		{"remote,shared-with-me,a=", "remote", configmap.Simple{"shared_with_me": "true", "a": ""}, ""},
+		{":s3,provider=Minio,endpoint=http\\://x", ":s3", configmap.Simple{"provider": "Minio", "endpoint": "http://x"}, ""},
+		{`remote,a="x,y:z",b='it''s',c="say ""hi"""`, "remote", configmap.Simple{"a": "x,y:z", "b": "it's", "c": `say "hi"`}, ""},
+		{`remote,a=b\,c,d=e\\`, "remote", configmap.Simple{"a": "b,c", "d": `e\`}, ""},
+		{`remote,a="x`, "", nil, "unterminated quote"},
+		{`remote,a=x\`, "", nil, "at end of parameter"},
+		{`remote,a=x,`, "", nil, "empty parameter"},
+		{`remote,=x`, "", nil, "empty parameter name"},
+		{`remote,`, "", nil, "empty parameter name"},
+	} {
+		gotName, gotParams, err := ParseConfigName(test.in)
+		if test.wantErr != "" {
+			require.Error(t, err, test.in)
+			assert.Contains(t, err.Error(), test.wantErr, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.wantName, gotName, test.in)
+		assert.Equal(t, test.wantParams, gotParams, test.in)
+	}
+}
+
+func TestMakeConfigName(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		params configmap.Simple
+		want   string
+	}{
+		{"remote", nil, "remote"},
+		{":backend", configmap.Simple{}, ":backend"},
+		{"remote", configmap.Simple{"param": "value"}, "remote,param=value"},
+		{"remote", configmap.Simple{"b": "2", "a": "", "c": "true"}, "remote,a=,b=2,c=true"},
+		{":s3", configmap.Simple{"endpoint": "http://x"}, ":s3,endpoint='http://x'"},
+		{"remote", configmap.Simple{"a": "x,y:z", "b": "it's", "c": `say "hi"`, "d": `e\`}, `remote,a='x,y:z',b='it''s',c='say "hi"',d='e\'`},
+	} {
+		got := MakeConfigName(test.name, test.params)
+		assert.Equal(t, test.want, got)
+
+		// Check it round trips
+		configName, fsPath := Parse(got + ":path")
+		assert.Equal(t, got, configName)
+		assert.Equal(t, "path", fsPath)
+		gotName, gotParams, err := ParseConfigName(configName)
+		require.NoError(t, err)
+		assert.Equal(t, test.name, gotName)
+		if len(test.params) == 0 {
+			assert.Nil(t, gotParams)
+		} else {
+			assert.Equal(t, test.params, gotParams)
+		}
+	}
+}
+
+func TestCheckConfigName(t *testing.T) {
+	for _, test := range []struct {
+		in string
+		ok bool
+	}{
+		{"remote", true},
+		{"my remote-2_x", true},
+		{"", false},
+		{":remote", false},
+		{"remote,param=value", false},
+		{"rem:ote", false},
+	} {
+		err := CheckConfigName(test.in)
+		if test.ok {
+			assert.NoError(t, err, test.in)
+		} else {
+			assert.Equal(t, ErrorConfigNameInvalid, err, test.in)
+		}
+	}
+}
+
 func TestSplit(t *testing.T) {
 	for _, test := range []struct {
 		remote, wantParent, wantLeaf string
@@ -44,6 +137,8 @@ func TestSplit(t *testing.T) {
 		{":remote:/potato/potato", ":remote:/potato/", "potato"},
 		{":remote:potato/sausage", ":remote:potato/", "sausage"},
 
+		{"remote,param=value:potato/sausage", "remote,param=value:potato/", "sausage"},
+
 		{"/", "/", ""},
 		{"/root", "/", "root"},
 		{"/a/b", "/a/", "b"},
@@ -1,8 +1,13 @@
 package fs
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"path"
+	"runtime"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -73,9 +78,77 @@ var LogPrint = func(level LogLevel, text string) {
 	_ = log.Output(4, text)
 }
 
+// LogValueItem describes a keyed item for a JSON log entry
+type LogValueItem struct {
+	key   string
+	value interface{}
+}
+
+// LogValue should be used as an argument to any logging call to add
+// value to the JSON output under key when --use-json-log is in use.
+func LogValue(key string, value interface{}) LogValueItem {
+	return LogValueItem{key: key, value: value}
+}
+
+// String returns an empty string so LogValueItem entries don't show
+// in the text version of the logs.
+func (j LogValueItem) String() string {
+	return ""
+}
+
+// logSource returns the "dir/file.go:line" of the first caller
+// outside the logging code, or "" if not found
+func logSource() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasSuffix(frame.File, "/fs/log.go") && !strings.HasSuffix(frame.File, "/fs/log/log.go") {
+			return path.Join(path.Base(path.Dir(frame.File)), path.Base(frame.File)) + fmt.Sprintf(":%d", frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// logJSON makes a single line JSON log entry out of the level, the
+// object and the message with any LogValueItem found in args added.
+func logJSON(level LogLevel, o interface{}, text string, args []interface{}) string {
+	entry := map[string]interface{}{
+		"level":  strings.ToLower(level.String()),
+		"time":   time.Now().Format(time.RFC3339Nano),
+		"source": logSource(),
+		"msg":    strings.TrimRight(text, "\n"),
+	}
+	if o != nil {
+		entry["object"] = fmt.Sprintf("%v", o)
+		entry["objectType"] = fmt.Sprintf("%T", o)
+	}
+	for _, arg := range args {
+		if item, ok := arg.(LogValueItem); ok {
+			entry[item.key] = item.value
+		}
+	}
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		buf, _ = json.Marshal(map[string]string{
+			"level": strings.ToLower(LogLevelError.String()),
+			"time":  time.Now().Format(time.RFC3339Nano),
+			"msg":   fmt.Sprintf("Failed to make JSON log entry for %q: %v", text, err),
+		})
+	}
+	return string(buf)
+}
+
 // LogPrintf produces a log string from the arguments passed in
 func LogPrintf(level LogLevel, o interface{}, text string, args ...interface{}) {
 	out := fmt.Sprintf(text, args...)
+	if Config.UseJSONLog {
+		LogPrint(level, logJSON(level, o, out, args))
+		return
+	}
 	if o != nil {
 		out = fmt.Sprintf("%v: %s", o, out)
 	}
@@ -89,6 +89,14 @@ func InitLogging() {
 	}
 	log.SetFlags(flags)
 
+	// JSON output has its own time stamp and level
+	if fs.Config.UseJSONLog {
+		log.SetFlags(0)
+		fs.LogPrint = func(level fs.LogLevel, text string) {
+			_ = log.Output(4, text)
+		}
+	}
+
 	// Log file output
 	if *logFile != "" {
 		f, err := os.OpenFile(*logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
@@ -1,6 +1,50 @@
 package fs
 
-import "github.com/spf13/pflag"
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
 
 // Check it satisfies the interface
 var _ pflag.Value = (*LogLevel)(nil)
+
+func TestLogJSON(t *testing.T) {
+	oldUseJSONLog, oldLogPrint := Config.UseJSONLog, LogPrint
+	defer func() {
+		Config.UseJSONLog, LogPrint = oldUseJSONLog, oldLogPrint
+	}()
+	var lines []string
+	LogPrint = func(level LogLevel, text string) {
+		lines = append(lines, text)
+	}
+
+	Config.UseJSONLog = false
+	LogPrintf(LogLevelError, "potato", "hello %s%v", "world", LogValue("key", 1))
+	Config.UseJSONLog = true
+	LogPrintf(LogLevelInfo, nil, "hello %s\n", "world")
+	LogPrintf(LogLevelError, "potato", "hello %s%v", "world", LogValue("key", 1))
+	require.Equal(t, 3, len(lines))
+
+	assert.Equal(t, "potato: hello world", lines[0])
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, "info", entry["level"])
+	assert.Equal(t, "hello world", entry["msg"])
+	assert.True(t, strings.HasPrefix(entry["source"].(string), "fs/log_test.go:"), entry["source"])
+	assert.NotEqual(t, "", entry["time"])
+	assert.Nil(t, entry["object"])
+
+	entry = nil
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &entry))
+	assert.Equal(t, "error", entry["level"])
+	assert.Equal(t, "hello world", entry["msg"])
+	assert.Equal(t, "potato", entry["object"])
+	assert.Equal(t, "string", entry["objectType"])
+	assert.Equal(t, 1.0, entry["key"])
+}
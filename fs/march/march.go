@@ -31,8 +31,10 @@ type March struct {
 	DstIncludeAll bool            // don't include all files in the destination
 	Callback      Marcher         // object to call with results
 	// internal state
-	srcListDir listDirFn // function to call to list a directory in the src
-	dstListDir listDirFn // function to call to list a directory in the dst
+	ci         *fs.ConfigInfo // config in use
+	fi         *filter.Filter // filter in use
+	srcListDir listDirFn      // function to call to list a directory in the src
+	dstListDir listDirFn      // function to call to list a directory in the dst
 	transforms []matchTransformFn
 }
 
@@ -48,6 +50,8 @@ type Marcher interface {
 
 // init sets up a march over opt.Fsrc, and opt.Fdst calling back callback for each match
 func (m *March) init() {
+	m.ci = fs.GetConfig(m.Ctx)
+	m.fi = filter.GetConfig(m.Ctx)
 	m.srcListDir = m.makeListDir(m.Fsrc, m.SrcIncludeAll)
 	if !m.NoTraverse {
 		m.dstListDir = m.makeListDir(m.Fdst, m.DstIncludeAll)
@@ -61,7 +65,7 @@ func (m *March) init() {
 	//                  | Yes | No  | No                 |
 	//                  | No  | Yes | Yes                |
 	//                  | Yes | Yes | Yes                |
-	if m.Fdst.Features().CaseInsensitive || fs.Config.IgnoreCaseSync {
+	if m.Fdst.Features().CaseInsensitive || m.ci.IgnoreCaseSync {
 		m.transforms = append(m.transforms, strings.ToLower)
 	}
 }
@@ -71,7 +75,7 @@ type listDirFn func(dir string) (entries fs.DirEntries, err error)
 
 // makeListDir makes a listing function for the given fs and includeAll flags
 func (m *March) makeListDir(f fs.Fs, includeAll bool) listDirFn {
-	if (!fs.Config.UseListR || f.Features().ListR == nil) && !filter.Active.HaveFilesFrom() {
+	if (!m.ci.UseListR || f.Features().ListR == nil) && !m.fi.HaveFilesFrom() {
 		return func(dir string) (entries fs.DirEntries, err error) {
 			return list.DirSorted(m.Ctx, f, includeAll, dir)
 		}
@@ -86,7 +90,7 @@ func (m *March) makeListDir(f fs.Fs, includeAll bool) listDirFn {
 		mu.Lock()
 		defer mu.Unlock()
 		if !started {
-			dirs, dirsErr = walk.NewDirTree(m.Ctx, f, m.Dir, includeAll, fs.Config.MaxDepth)
+			dirs, dirsErr = walk.NewDirTree(m.Ctx, f, m.Dir, includeAll, m.ci.MaxDepth)
 			started = true
 		}
 		if dirsErr != nil {
@@ -116,12 +120,12 @@ type listDirJob struct {
 func (m *March) Run() error {
 	m.init()
 
-	srcDepth := fs.Config.MaxDepth
+	srcDepth := m.ci.MaxDepth
 	if srcDepth < 0 {
 		srcDepth = fs.MaxLevel
 	}
 	dstDepth := srcDepth
-	if filter.Active.Opt.DeleteExcluded {
+	if m.fi.Opt.DeleteExcluded {
 		dstDepth = fs.MaxLevel
 	}
 
@@ -132,8 +136,8 @@ func (m *March) Run() error {
 	// Start some directory listing go routines
 	var wg sync.WaitGroup         // sync closing of go routines
 	var traversing sync.WaitGroup // running directory traversals
-	in := make(chan listDirJob, fs.Config.Checkers)
-	for i := 0; i < fs.Config.Checkers; i++ {
+	in := make(chan listDirJob, m.ci.Checkers)
+	for i := 0; i < m.ci.Checkers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -388,14 +392,14 @@ func (m *March) processJob(job listDirJob) ([]listDirJob, error) {
 	wg.Wait()
 	if srcListErr != nil {
 		fs.Errorf(job.srcRemote, "error reading source directory: %v", srcListErr)
-		fs.CountError(srcListErr)
+		fs.CountError(m.Ctx, srcListErr)
 		return nil, srcListErr
 	}
 	if dstListErr == fs.ErrorDirNotFound {
 		// Copy the stuff anyway
 	} else if dstListErr != nil {
 		fs.Errorf(job.dstRemote, "error reading destination directory: %v", dstListErr)
-		fs.CountError(dstListErr)
+		fs.CountError(m.Ctx, dstListErr)
 		return nil, dstListErr
 	}
 
@@ -0,0 +1,206 @@
+// Object metadata
+
+package fs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Metadata represents arbitrary key/value metadata attached to an
+// Object, for example S3 user metadata or the mode and owner of a
+// local file.
+//
+// Keys are lower case.  The keys each backend reads and writes are
+// described in its documentation.
+type Metadata map[string]string
+
+// Set k to v on m
+//
+// If m is nil, then it will get made
+func (m *Metadata) Set(k, v string) {
+	if *m == nil {
+		*m = make(Metadata, 1)
+	}
+	(*m)[k] = v
+}
+
+// Merge other into m
+//
+// If m is nil, then it will get made
+func (m *Metadata) Merge(other Metadata) {
+	for k, v := range other {
+		m.Set(k, v)
+	}
+}
+
+// Metadata keys for the permissions, owner and times of a file, used
+// by backends which store them, such as local, and by the VFS.
+const (
+	MetadataMode  = "mode"  // file type and permissions in octal as st_mode
+	MetadataUID   = "uid"   // numeric user ID of the owner
+	MetadataGID   = "gid"   // numeric group ID of the owner
+	MetadataAtime = "atime" // access time in RFC 3339 format
+	MetadataMtime = "mtime" // modification time in RFC 3339 format
+)
+
+// IsSystemMetadata returns true if k is one of the keys for the
+// permissions, owner or times of a file rather than user metadata
+func IsSystemMetadata(k string) bool {
+	switch k {
+	case MetadataMode, MetadataUID, MetadataGID, MetadataAtime, MetadataMtime:
+		return true
+	}
+	return false
+}
+
+// ParseMetadataMode returns the permissions stored in metadata as an
+// octal st_mode, with ok false if there aren't any
+//
+// Only the permission bits are returned, converting the setuid,
+// setgid and sticky bits from st_mode to os.FileMode.
+func ParseMetadataMode(metadata Metadata) (mode os.FileMode, ok bool, err error) {
+	v, found := metadata[MetadataMode]
+	if !found {
+		return 0, false, nil
+	}
+	m, err := strconv.ParseUint(v, 8, 32)
+	if err != nil {
+		return 0, false, errors.Wrapf(err, "bad %s", MetadataMode)
+	}
+	mode = os.FileMode(m & 0777)
+	if m&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if m&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if m&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode, true, nil
+}
+
+// FormatMetadataMode returns the permissions in mode as the octal
+// st_mode of a regular file
+func FormatMetadataMode(mode os.FileMode) string {
+	m := uint64(0100000 | mode&0777)
+	if mode&os.ModeSetuid != 0 {
+		m |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= 01000
+	}
+	return strconv.FormatUint(m, 8)
+}
+
+// ParseMetadataID returns the numeric ID stored under key, which
+// should be MetadataUID or MetadataGID, with ok false if there isn't
+// one
+func ParseMetadataID(metadata Metadata, key string) (id uint32, ok bool, err error) {
+	v, found := metadata[key]
+	if !found {
+		return 0, false, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, false, errors.Wrapf(err, "bad %s", key)
+	}
+	return uint32(n), true, nil
+}
+
+// Metadataer is an optional interface for Object
+type Metadataer interface {
+	// Metadata returns the metadata for the Object
+	//
+	// It should return nil if there is no metadata
+	Metadata(ctx context.Context) (Metadata, error)
+}
+
+// SetMetadataer is an optional interface for Object
+type SetMetadataer interface {
+	// SetMetadata writes metadata to an existing Object
+	//
+	// Keys the backend doesn't understand may be ignored. It
+	// should return ErrorCantSetMetadata if it can't set any.
+	SetMetadata(ctx context.Context, metadata Metadata) error
+}
+
+// GetMetadata reads the metadata from o if it supports the
+// Metadataer interface, returning nil if it doesn't
+func GetMetadata(ctx context.Context, o ObjectInfo) (Metadata, error) {
+	do, ok := o.(Metadataer)
+	if !ok {
+		return nil, nil
+	}
+	return do.Metadata(ctx)
+}
+
+// MetadataMapper translates the metadata read from src into the
+// form it should be written to fdst in.
+//
+// It may return the metadata passed in, modified or not, or nil to
+// write no metadata.
+type MetadataMapper func(ctx context.Context, src ObjectInfo, fdst Info, metadata Metadata) (Metadata, error)
+
+// MapMetadata is called on the metadata of each object copied when
+// --metadata is in use so it can be translated between backends.
+//
+// If it is nil then the metadata is passed on unchanged.
+var MapMetadata MetadataMapper
+
+// GetMappedMetadata reads the metadata from src and passes it through
+// MapMetadata to make it suitable for writing to fdst.
+func GetMappedMetadata(ctx context.Context, src ObjectInfo, fdst Info) (Metadata, error) {
+	metadata, err := GetMetadata(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	if MapMetadata != nil {
+		metadata, err = MapMetadata(ctx, src, fdst, metadata)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return metadata, nil
+}
+
+// MetadataOption defines an Option used to pass metadata to be
+// written to Put, PutStream and Update.
+type MetadataOption Metadata
+
+// Header formats the option as an http header
+func (o MetadataOption) Header() (key string, value string) {
+	return "", ""
+}
+
+// String formats the option into human readable form
+func (o MetadataOption) String() string {
+	return fmt.Sprintf("MetadataOption(%v)", Metadata(o))
+}
+
+// Mandatory returns whether the option must be parsed or can be ignored
+func (o MetadataOption) Mandatory() bool {
+	return false
+}
+
+// MetadataFromOptions returns the metadata passed in any
+// MetadataOption in options, or nil if there wasn't one
+func MetadataFromOptions(options []OpenOption) (metadata Metadata) {
+	for _, option := range options {
+		if o, ok := option.(MetadataOption); ok {
+			metadata.Merge(Metadata(o))
+		}
+	}
+	return metadata
+}
+
+// check interface
+var _ OpenOption = MetadataOption(nil)
@@ -0,0 +1,55 @@
+package fs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataMode(t *testing.T) {
+	assert.Equal(t, "100640", FormatMetadataMode(0640))
+	assert.Equal(t, "104755", FormatMetadataMode(0755|os.ModeSetuid))
+	assert.Equal(t, "103777", FormatMetadataMode(0777|os.ModeSetgid|os.ModeSticky))
+
+	mode, ok, err := ParseMetadataMode(Metadata{MetadataMode: "104755"})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 0755|os.ModeSetuid, mode)
+
+	mode, ok, err = ParseMetadataMode(Metadata{MetadataMode: FormatMetadataMode(0640 | os.ModeSticky)})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 0640|os.ModeSticky, mode)
+
+	_, ok, err = ParseMetadataMode(Metadata{MetadataMode: "potato"})
+	assert.Error(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = ParseMetadataMode(nil)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestMetadataID(t *testing.T) {
+	id, ok, err := ParseMetadataID(Metadata{MetadataUID: "1000"}, MetadataUID)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1000), id)
+
+	_, ok, err = ParseMetadataID(Metadata{MetadataUID: "1000"}, MetadataGID)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = ParseMetadataID(Metadata{MetadataGID: "-1"}, MetadataGID)
+	assert.Error(t, err)
+	assert.False(t, ok)
+}
+
+func TestIsSystemMetadata(t *testing.T) {
+	for _, k := range []string{MetadataMode, MetadataUID, MetadataGID, MetadataAtime, MetadataMtime} {
+		assert.True(t, IsSystemMetadata(k), k)
+	}
+	assert.False(t, IsSystemMetadata("potato"))
+}
@@ -26,6 +26,7 @@ type ListJSONItem struct {
 	OrigID        string            `json:",omitempty"`
 	Tier          string            `json:",omitempty"`
 	IsBucket      bool              `json:",omitempty"`
+	Metadata      fs.Metadata       `json:",omitempty"`
 }
 
 // Timestamp a time in the provided format
@@ -76,6 +77,7 @@ type ListJSONOpt struct {
 	ShowHash      bool `json:"showHash"`
 	DirsOnly      bool `json:"dirsOnly"`
 	FilesOnly     bool `json:"filesOnly"`
+	Metadata      bool `json:"metadata"`
 }
 
 // ListJSON lists fsrc using the options in opt calling callback for each item
@@ -98,7 +100,7 @@ func ListJSON(ctx context.Context, fsrc fs.Fs, remote string, opt *ListJSONOpt,
 	canGetTier := features.GetTier
 	format := formatForPrecision(fsrc.Precision())
 	isBucket := features.BucketBased && remote == "" && fsrc.Root() == "" // if bucket based remote listing the root mark directories as buckets
-	err := walk.ListR(ctx, fsrc, remote, false, ConfigMaxDepth(opt.Recurse), walk.ListAll, func(entries fs.DirEntries) (err error) {
+	err := walk.ListR(ctx, fsrc, remote, false, ConfigMaxDepth(ctx, opt.Recurse), walk.ListAll, func(entries fs.DirEntries) (err error) {
 		for _, entry := range entries {
 			switch entry.(type) {
 			case fs.Directory:
@@ -175,6 +177,14 @@ func ListJSON(ctx context.Context, fsrc fs.Fs, remote string, opt *ListJSONOpt,
 						item.Tier = do.GetTier()
 					}
 				}
+				if opt.Metadata {
+					metadata, err := fs.GetMetadata(ctx, x)
+					if err != nil {
+						fs.Errorf(x, "Failed to read metadata: %v", err)
+					} else if metadata != nil {
+						item.Metadata = metadata
+					}
+				}
 			default:
 				fs.Errorf(nil, "Unknown type %T in listing in ListJSON", entry)
 			}
@@ -54,7 +54,7 @@ func CheckHashes(ctx context.Context, src fs.ObjectInfo, dst fs.Object) (equal b
 	ht = common.GetOne()
 	srcHash, err := src.Hash(ctx, ht)
 	if err != nil {
-		fs.CountError(err)
+		fs.CountError(ctx, err)
 		fs.Errorf(src, "Failed to calculate src hash: %v", err)
 		return false, ht, err
 	}
@@ -63,7 +63,7 @@ func CheckHashes(ctx context.Context, src fs.ObjectInfo, dst fs.Object) (equal b
 	}
 	dstHash, err := dst.Hash(ctx, ht)
 	if err != nil {
-		fs.CountError(err)
+		fs.CountError(ctx, err)
 		fs.Errorf(dst, "Failed to calculate dst hash: %v", err)
 		return false, ht, err
 	}
@@ -96,13 +96,15 @@ func CheckHashes(ctx context.Context, src fs.ObjectInfo, dst fs.Object) (equal b
 // Otherwise the file is considered to be not equal including if there
 // were errors reading info.
 func Equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object) bool {
-	return equal(ctx, src, dst, fs.Config.SizeOnly, fs.Config.CheckSum)
+	ci := fs.GetConfig(ctx)
+	return equal(ctx, src, dst, ci.SizeOnly, ci.CheckSum)
 }
 
 // sizeDiffers compare the size of src and dst taking into account the
 // various ways of ignoring sizes
-func sizeDiffers(src, dst fs.ObjectInfo) bool {
-	if fs.Config.IgnoreSize || src.Size() < 0 || dst.Size() < 0 {
+func sizeDiffers(ctx context.Context, src, dst fs.ObjectInfo) bool {
+	ci := fs.GetConfig(ctx)
+	if ci.IgnoreSize || src.Size() < 0 || dst.Size() < 0 {
 		return false
 	}
 	return src.Size() != dst.Size()
@@ -111,7 +113,8 @@ func sizeDiffers(src, dst fs.ObjectInfo) bool {
 var checksumWarning sync.Once
 
 func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, sizeOnly, checkSum bool) bool {
-	if sizeDiffers(src, dst) {
+	ci := fs.GetConfig(ctx)
+	if sizeDiffers(ctx, src, dst) {
 		fs.Debugf(src, "Sizes differ (src %d vs dst %d)", src.Size(), dst.Size())
 		return false
 	}
@@ -169,13 +172,13 @@ func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, sizeOnly, chec
 	}
 
 	// mod time differs but hash is the same to reset mod time if required
-	if !fs.Config.NoUpdateModTime {
-		if fs.Config.DryRun {
+	if !ci.NoUpdateModTime {
+		if ci.DryRun {
 			fs.Logf(src, "Not updating modification time as --dry-run")
 		} else {
 			// Size and hash the same but mtime different
 			// Error if objects are treated as immutable
-			if fs.Config.Immutable {
+			if ci.Immutable {
 				fs.Errorf(dst, "Timestamp mismatch between immutable objects")
 				return false
 			}
@@ -188,7 +191,7 @@ func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, sizeOnly, chec
 				fs.Debugf(dst, "src and dst identical but can't set mod time without deleting and re-uploading")
 				// Remove the file if BackupDir isn't set.  If BackupDir is set we would rather have the old file
 				// put in the BackupDir than deleted which is what will happen if we don't delete it.
-				if fs.Config.BackupDir == "" {
+				if ci.BackupDir == "" {
 					err = dst.Remove(ctx)
 					if err != nil {
 						fs.Errorf(dst, "failed to delete before re-upload: %v", err)
@@ -196,7 +199,7 @@ func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, sizeOnly, chec
 				}
 				return false
 			} else if err != nil {
-				fs.CountError(err)
+				fs.CountError(ctx, err)
 				fs.Errorf(dst, "Failed to set modification time: %v", err)
 			} else {
 				fs.Infof(src, "Updated modification time in destination")
@@ -251,22 +254,23 @@ var _ fs.MimeTyper = (*overrideRemoteObject)(nil)
 // It returns the destination object if possible.  Note that this may
 // be nil.
 func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Object) (newDst fs.Object, err error) {
-	accounting.Stats.Transferring(src.Remote())
+	ci := fs.GetConfig(ctx)
+	accounting.Stats(ctx).Transferring(src.Remote())
 	defer func() {
-		accounting.Stats.DoneTransferring(src.Remote(), err == nil)
+		accounting.Stats(ctx).DoneTransferring(src.Remote(), err == nil)
 	}()
 	newDst = dst
-	if fs.Config.DryRun {
+	if ci.DryRun {
 		fs.Logf(src, "Not copying as --dry-run")
 		return newDst, nil
 	}
-	maxTries := fs.Config.LowLevelRetries
+	maxTries := ci.LowLevelRetries
 	tries := 0
 	doUpdate := dst != nil
 	// work out which hash to use - limit to 1 hash in common
 	var common hash.Set
 	hashType := hash.None
-	if !fs.Config.SizeOnly {
+	if !ci.SizeOnly {
 		common = src.Fs().Hashes().Overlap(f.Hashes())
 		if common.Count() > 0 {
 			hashType = common.GetOne()
@@ -274,6 +278,21 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 		}
 	}
 	hashOption := &fs.HashesOption{Hashes: common}
+	options := []fs.OpenOption{hashOption}
+	// read the metadata to write to the destination if required
+	var metadata fs.Metadata
+	if ci.Metadata {
+		metadata, err = fs.GetMappedMetadata(ctx, src, f)
+		if err != nil {
+			err = errors.Wrap(err, "failed to read metadata")
+			fs.CountError(ctx, err)
+			fs.Errorf(src, "Failed to copy: %v", err)
+			return newDst, err
+		}
+		if metadata != nil {
+			options = append(options, fs.MetadataOption(metadata))
+		}
+	}
 	var actionTaken string
 	for {
 		// Try server side copy first - if has optional interface and
@@ -281,30 +300,36 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 		actionTaken = "Copied (server side copy)"
 		if doCopy := f.Features().Copy; doCopy != nil && (SameConfig(src.Fs(), f) || (SameRemoteType(src.Fs(), f) && f.Features().ServerSideAcrossConfigs)) {
 			// Check transfer limit for server side copies
-			if fs.Config.MaxTransfer >= 0 && accounting.Stats.GetBytes() >= int64(fs.Config.MaxTransfer) {
+			if ci.MaxTransfer >= 0 && accounting.Stats(ctx).GetBytes() >= int64(ci.MaxTransfer) {
 				return nil, accounting.ErrorMaxTransferLimitReached
 			}
 			newDst, err = doCopy(ctx, src, remote)
 			if err == nil {
 				dst = newDst
-				accounting.Stats.Bytes(dst.Size()) // account the bytes for the server side transfer
+				accounting.Stats(ctx).Bytes(dst.Size()) // account the bytes for the server side transfer
+				// The server side copy keeps the source metadata
+				// so write the mapped metadata over it
+				err = setMetadata(ctx, dst, metadata)
 			}
 		} else {
 			err = fs.ErrorCantCopy
 		}
 		// If can't server side copy, do it manually
 		if err == fs.ErrorCantCopy {
-			if doOpenWriterAt := f.Features().OpenWriterAt; doOpenWriterAt != nil && src.Size() >= int64(fs.Config.MultiThreadCutoff) && fs.Config.MultiThreadStreams > 1 {
+			if doOpenWriterAt := f.Features().OpenWriterAt; doOpenWriterAt != nil && src.Size() >= int64(ci.MultiThreadCutoff) && ci.MultiThreadStreams > 1 {
 				// Number of streams proportional to size
-				streams := src.Size() / int64(fs.Config.MultiThreadCutoff)
+				streams := src.Size() / int64(ci.MultiThreadCutoff)
 				// With maximum
-				if streams > int64(fs.Config.MultiThreadStreams) {
-					streams = int64(fs.Config.MultiThreadStreams)
+				if streams > int64(ci.MultiThreadStreams) {
+					streams = int64(ci.MultiThreadStreams)
 				}
 				if streams < 2 {
 					streams = 2
 				}
 				dst, err = multiThreadCopy(ctx, f, remote, src, int(streams))
+				if err == nil {
+					err = setMetadata(ctx, dst, metadata)
+				}
 				if doUpdate {
 					actionTaken = "Multi-thread Copied (replaced existing)"
 				} else {
@@ -312,7 +337,7 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 				}
 			} else {
 				var in0 io.ReadCloser
-				in0, err = newReOpen(ctx, src, hashOption, nil, fs.Config.LowLevelRetries)
+				in0, err = newReOpen(ctx, src, hashOption, nil, ci.LowLevelRetries)
 				if err != nil {
 					err = errors.Wrap(err, "failed to open source object")
 				} else {
@@ -324,9 +349,12 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 							actionTaken = "Copied (Rcat, new)"
 						}
 						dst, err = Rcat(ctx, f, remote, in0, src.ModTime(ctx))
+						if err == nil {
+							err = setMetadata(ctx, dst, metadata)
+						}
 						newDst = dst
 					} else {
-						in := accounting.NewAccount(in0, src).WithBuffer() // account and buffer the transfer
+						in := accounting.Stats(ctx).NewAccount(in0, src).WithBwLimit(ctx).WithBuffer() // account and buffer the transfer
 						var wrappedSrc fs.ObjectInfo = src
 						// We try to pass the original object if possible
 						if src.Remote() != remote {
@@ -334,10 +362,10 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 						}
 						if doUpdate {
 							actionTaken = "Copied (replaced existing)"
-							err = dst.Update(ctx, in, wrappedSrc, hashOption)
+							err = dst.Update(ctx, in, wrappedSrc, options...)
 						} else {
 							actionTaken = "Copied (new)"
-							dst, err = f.Put(ctx, in, wrappedSrc, hashOption)
+							dst, err = f.Put(ctx, in, wrappedSrc, options...)
 						}
 						closeErr := in.Close()
 						if err == nil {
@@ -361,37 +389,37 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 		break
 	}
 	if err != nil {
-		fs.CountError(err)
+		fs.CountError(ctx, err)
 		fs.Errorf(src, "Failed to copy: %v", err)
 		return newDst, err
 	}
 
 	// Verify sizes are the same after transfer
-	if sizeDiffers(src, dst) {
+	if sizeDiffers(ctx, src, dst) {
 		err = errors.Errorf("corrupted on transfer: sizes differ %d vs %d", src.Size(), dst.Size())
 		fs.Errorf(dst, "%v", err)
-		fs.CountError(err)
+		fs.CountError(ctx, err)
 		removeFailedCopy(ctx, dst)
 		return newDst, err
 	}
 
 	// Verify hashes are the same after transfer - ignoring blank hashes
-	if !fs.Config.IgnoreChecksum && hashType != hash.None {
+	if !ci.IgnoreChecksum && hashType != hash.None {
 		var srcSum string
 		srcSum, err = src.Hash(ctx, hashType)
 		if err != nil {
-			fs.CountError(err)
+			fs.CountError(ctx, err)
 			fs.Errorf(src, "Failed to read src hash: %v", err)
 		} else if srcSum != "" {
 			var dstSum string
 			dstSum, err = dst.Hash(ctx, hashType)
 			if err != nil {
-				fs.CountError(err)
+				fs.CountError(ctx, err)
 				fs.Errorf(dst, "Failed to read hash: %v", err)
 			} else if !hash.Equals(srcSum, dstSum) {
 				err = errors.Errorf("corrupted on transfer: %v hash differ %q vs %q", hashType, srcSum, dstSum)
 				fs.Errorf(dst, "%v", err)
-				fs.CountError(err)
+				fs.CountError(ctx, err)
 				removeFailedCopy(ctx, dst)
 				return newDst, err
 			}
@@ -402,6 +430,25 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 	return newDst, err
 }
 
+// setMetadata writes metadata to dst after a transfer which couldn't
+// pass it in with the upload
+func setMetadata(ctx context.Context, dst fs.Object, metadata fs.Metadata) error {
+	if metadata == nil {
+		return nil
+	}
+	do, ok := dst.(fs.SetMetadataer)
+	if !ok {
+		fs.Debugf(dst, "Not setting metadata as the destination doesn't support it")
+		return nil
+	}
+	err := do.SetMetadata(ctx, metadata)
+	if err == fs.ErrorCantSetMetadata {
+		fs.Debugf(dst, "Not setting metadata as the destination doesn't support it")
+		return nil
+	}
+	return err
+}
+
 // SameObject returns true if src and dst could be pointing to the
 // same object.
 func SameObject(src, dst fs.Object) bool {
@@ -428,12 +475,13 @@ func SameObject(src, dst fs.Object) bool {
 // It returns the destination object if possible.  Note that this may
 // be nil.
 func Move(ctx context.Context, fdst fs.Fs, dst fs.Object, remote string, src fs.Object) (newDst fs.Object, err error) {
-	accounting.Stats.Checking(src.Remote())
+	ci := fs.GetConfig(ctx)
+	accounting.Stats(ctx).Checking(src.Remote())
 	defer func() {
-		accounting.Stats.DoneChecking(src.Remote())
+		accounting.Stats(ctx).DoneChecking(src.Remote())
 	}()
 	newDst = dst
-	if fs.Config.DryRun {
+	if ci.DryRun {
 		fs.Logf(src, "Not moving as --dry-run")
 		return newDst, nil
 	}
@@ -455,7 +503,7 @@ func Move(ctx context.Context, fdst fs.Fs, dst fs.Object, remote string, src fs.
 		case fs.ErrorCantMove:
 			fs.Debugf(src, "Can't move, switching to copy")
 		default:
-			fs.CountError(err)
+			fs.CountError(ctx, err)
 			fs.Errorf(src, "Couldn't move: %v", err)
 			return newDst, err
 		}
@@ -483,16 +531,17 @@ func CanServerSideMove(fdst fs.Fs) bool {
 
 // SuffixName adds the current --suffix to the remote, obeying
 // --suffix-keep-extension if set
-func SuffixName(remote string) string {
-	if fs.Config.Suffix == "" {
+func SuffixName(ctx context.Context, remote string) string {
+	ci := fs.GetConfig(ctx)
+	if ci.Suffix == "" {
 		return remote
 	}
-	if fs.Config.SuffixKeepExtension {
+	if ci.SuffixKeepExtension {
 		ext := path.Ext(remote)
 		base := remote[:len(remote)-len(ext)]
-		return base + fs.Config.Suffix + ext
+		return base + ci.Suffix + ext
 	}
-	return remote + fs.Config.Suffix
+	return remote + ci.Suffix
 }
 
 // DeleteFileWithBackupDir deletes a single file respecting --dry-run
@@ -501,16 +550,17 @@ func SuffixName(remote string) string {
 // If backupDir is set then it moves the file to there instead of
 // deleting
 func DeleteFileWithBackupDir(ctx context.Context, dst fs.Object, backupDir fs.Fs) (err error) {
-	accounting.Stats.Checking(dst.Remote())
-	numDeletes := accounting.Stats.Deletes(1)
-	if fs.Config.MaxDelete != -1 && numDeletes > fs.Config.MaxDelete {
+	ci := fs.GetConfig(ctx)
+	accounting.Stats(ctx).Checking(dst.Remote())
+	numDeletes := accounting.Stats(ctx).Deletes(1)
+	if ci.MaxDelete != -1 && numDeletes > ci.MaxDelete {
 		return fserrors.FatalError(errors.New("--max-delete threshold reached"))
 	}
 	action, actioned, actioning := "delete", "Deleted", "deleting"
 	if backupDir != nil {
 		action, actioned, actioning = "move into backup dir", "Moved into backup dir", "moving into backup dir"
 	}
-	if fs.Config.DryRun {
+	if ci.DryRun {
 		fs.Logf(dst, "Not %s as --dry-run", actioning)
 	} else if backupDir != nil {
 		err = MoveBackupDir(ctx, backupDir, dst)
@@ -518,12 +568,12 @@ func DeleteFileWithBackupDir(ctx context.Context, dst fs.Object, backupDir fs.Fs
 		err = dst.Remove(ctx)
 	}
 	if err != nil {
-		fs.CountError(err)
+		fs.CountError(ctx, err)
 		fs.Errorf(dst, "Couldn't %s: %v", action, err)
-	} else if !fs.Config.DryRun {
+	} else if !ci.DryRun {
 		fs.Infof(dst, actioned)
 	}
-	accounting.Stats.DoneChecking(dst.Remote())
+	accounting.Stats(ctx).DoneChecking(dst.Remote())
 	return err
 }
 
@@ -541,12 +591,13 @@ func DeleteFile(ctx context.Context, dst fs.Object) (err error) {
 // If backupDir is set the files will be placed into that directory
 // instead of being deleted.
 func DeleteFilesWithBackupDir(ctx context.Context, toBeDeleted fs.ObjectsChan, backupDir fs.Fs) error {
+	ci := fs.GetConfig(ctx)
 	var wg sync.WaitGroup
-	wg.Add(fs.Config.Transfers)
+	wg.Add(ci.Transfers)
 	var errorCount int32
 	var fatalErrorCount int32
 
-	for i := 0; i < fs.Config.Transfers; i++ {
+	for i := 0; i < ci.Transfers; i++ {
 		go func() {
 			defer wg.Done()
 			for dst := range toBeDeleted {
@@ -646,7 +697,7 @@ func checkIdentical(ctx context.Context, dst, src fs.Object) (differ bool, noHas
 	if !same {
 		err = errors.Errorf("%v differ", ht)
 		fs.Errorf(src, "%v", err)
-		fs.CountError(err)
+		fs.CountError(ctx, err)
 		return true, false
 	}
 	return false, false
@@ -658,6 +709,7 @@ type checkFn func(ctx context.Context, a, b fs.Object) (differ bool, noHash bool
 // checkMarch is used to march over two Fses in the same way as
 // sync/copy
 type checkMarch struct {
+	ctx             context.Context
 	fdst, fsrc      fs.Fs
 	check           checkFn
 	oneway          bool
@@ -677,7 +729,7 @@ func (c *checkMarch) DstOnly(dst fs.DirEntry) (recurse bool) {
 		}
 		err := errors.Errorf("File not in %v", c.fsrc)
 		fs.Errorf(dst, "%v", err)
-		fs.CountError(err)
+		fs.CountError(c.ctx, err)
 		atomic.AddInt32(&c.differences, 1)
 		atomic.AddInt32(&c.srcFilesMissing, 1)
 	case fs.Directory:
@@ -695,7 +747,7 @@ func (c *checkMarch) SrcOnly(src fs.DirEntry) (recurse bool) {
 	case fs.Object:
 		err := errors.Errorf("File not in %v", c.fdst)
 		fs.Errorf(src, "%v", err)
-		fs.CountError(err)
+		fs.CountError(c.ctx, err)
 		atomic.AddInt32(&c.differences, 1)
 		atomic.AddInt32(&c.dstFilesMissing, 1)
 	case fs.Directory:
@@ -709,15 +761,16 @@ func (c *checkMarch) SrcOnly(src fs.DirEntry) (recurse bool) {
 
 // check to see if two objects are identical using the check function
 func (c *checkMarch) checkIdentical(ctx context.Context, dst, src fs.Object) (differ bool, noHash bool) {
-	accounting.Stats.Checking(src.Remote())
-	defer accounting.Stats.DoneChecking(src.Remote())
-	if sizeDiffers(src, dst) {
+	ci := fs.GetConfig(ctx)
+	accounting.Stats(ctx).Checking(src.Remote())
+	defer accounting.Stats(ctx).DoneChecking(src.Remote())
+	if sizeDiffers(ctx, src, dst) {
 		err := errors.Errorf("Sizes differ")
 		fs.Errorf(src, "%v", err)
-		fs.CountError(err)
+		fs.CountError(ctx, err)
 		return true, false
 	}
-	if fs.Config.SizeOnly {
+	if ci.SizeOnly {
 		return false, false
 	}
 	return c.check(ctx, dst, src)
@@ -742,7 +795,7 @@ func (c *checkMarch) Match(ctx context.Context, dst, src fs.DirEntry) (recurse b
 		} else {
 			err := errors.Errorf("is file on %v but directory on %v", c.fsrc, c.fdst)
 			fs.Errorf(src, "%v", err)
-			fs.CountError(err)
+			fs.CountError(ctx, err)
 			atomic.AddInt32(&c.differences, 1)
 			atomic.AddInt32(&c.dstFilesMissing, 1)
 		}
@@ -754,7 +807,7 @@ func (c *checkMarch) Match(ctx context.Context, dst, src fs.DirEntry) (recurse b
 		}
 		err := errors.Errorf("is file on %v but directory on %v", c.fdst, c.fsrc)
 		fs.Errorf(dst, "%v", err)
-		fs.CountError(err)
+		fs.CountError(c.ctx, err)
 		atomic.AddInt32(&c.differences, 1)
 		atomic.AddInt32(&c.srcFilesMissing, 1)
 
@@ -773,6 +826,7 @@ func (c *checkMarch) Match(ctx context.Context, dst, src fs.DirEntry) (recurse b
 // it also returns whether it couldn't be hashed
 func CheckFn(ctx context.Context, fdst, fsrc fs.Fs, check checkFn, oneway bool) error {
 	c := &checkMarch{
+		ctx:    ctx,
 		fdst:   fdst,
 		fsrc:   fsrc,
 		check:  check,
@@ -797,7 +851,7 @@ func CheckFn(ctx context.Context, fdst, fsrc fs.Fs, check checkFn, oneway bool)
 		fs.Logf(fsrc, "%d files missing", c.srcFilesMissing)
 	}
 
-	fs.Logf(fdst, "%d differences found", accounting.Stats.GetErrors())
+	fs.Logf(fdst, "%d differences found", accounting.Stats(ctx).GetErrors())
 	if c.noHashes > 0 {
 		fs.Logf(fdst, "%d hashes could not be checked", c.noHashes)
 	}
@@ -854,14 +908,14 @@ func CheckIdentical(ctx context.Context, dst, src fs.Object) (differ bool, err e
 	if err != nil {
 		return true, errors.Wrapf(err, "failed to open %q", dst)
 	}
-	in1 = accounting.NewAccount(in1, dst).WithBuffer() // account and buffer the transfer
+	in1 = accounting.Stats(ctx).NewAccount(in1, dst).WithBwLimit(ctx).WithBuffer() // account and buffer the transfer
 	defer fs.CheckClose(in1, &err)
 
 	in2, err := src.Open(ctx)
 	if err != nil {
 		return true, errors.Wrapf(err, "failed to open %q", src)
 	}
-	in2 = accounting.NewAccount(in2, src).WithBuffer() // account and buffer the transfer
+	in2 = accounting.Stats(ctx).NewAccount(in2, src).WithBwLimit(ctx).WithBuffer() // account and buffer the transfer
 	defer fs.CheckClose(in2, &err)
 
 	return CheckEqualReaders(in1, in2)
@@ -873,7 +927,7 @@ func CheckDownload(ctx context.Context, fdst, fsrc fs.Fs, oneway bool) error {
 	check := func(ctx context.Context, a, b fs.Object) (differ bool, noHash bool) {
 		differ, err := CheckIdentical(ctx, a, b)
 		if err != nil {
-			fs.CountError(err)
+			fs.CountError(ctx, err)
 			fs.Errorf(a, "Failed to download: %v", err)
 			return true, true
 		}
@@ -886,7 +940,8 @@ func CheckDownload(ctx context.Context, fdst, fsrc fs.Fs, oneway bool) error {
 //
 // Lists in parallel which may get them out of order
 func ListFn(ctx context.Context, f fs.Fs, fn func(fs.Object)) error {
-	return walk.ListR(ctx, f, "", false, fs.Config.MaxDepth, walk.ListObjects, func(entries fs.DirEntries) error {
+	ci := fs.GetConfig(ctx)
+	return walk.ListR(ctx, f, "", false, ci.MaxDepth, walk.ListObjects, func(entries fs.DirEntries) error {
 		entries.ForObject(fn)
 		return nil
 	})
@@ -922,9 +977,9 @@ func List(ctx context.Context, f fs.Fs, w io.Writer) error {
 // Lists in parallel which may get them out of order
 func ListLong(ctx context.Context, f fs.Fs, w io.Writer) error {
 	return ListFn(ctx, f, func(o fs.Object) {
-		accounting.Stats.Checking(o.Remote())
+		accounting.Stats(ctx).Checking(o.Remote())
 		modTime := o.ModTime(ctx)
-		accounting.Stats.DoneChecking(o.Remote())
+		accounting.Stats(ctx).DoneChecking(o.Remote())
 		syncFprintf(w, "%9d %s %s\n", o.Size(), modTime.Local().Format("2006-01-02 15:04:05.000000000"), o.Remote())
 	})
 }
@@ -960,9 +1015,9 @@ func DropboxHashSum(ctx context.Context, f fs.Fs, w io.Writer) error {
 // hashSum returns the human readable hash for ht passed in.  This may
 // be UNSUPPORTED or ERROR.
 func hashSum(ctx context.Context, ht hash.Type, o fs.Object) string {
-	accounting.Stats.Checking(o.Remote())
+	accounting.Stats(ctx).Checking(o.Remote())
 	sum, err := o.Hash(ctx, ht)
-	accounting.Stats.DoneChecking(o.Remote())
+	accounting.Stats(ctx).DoneChecking(o.Remote())
 	if err == hash.ErrUnsupported {
 		sum = "UNSUPPORTED"
 	} else if err != nil {
@@ -995,8 +1050,9 @@ func Count(ctx context.Context, f fs.Fs) (objects int64, size int64, err error)
 }
 
 // ConfigMaxDepth returns the depth to use for a recursive or non recursive listing.
-func ConfigMaxDepth(recursive bool) int {
-	depth := fs.Config.MaxDepth
+func ConfigMaxDepth(ctx context.Context, recursive bool) int {
+	ci := fs.GetConfig(ctx)
+	depth := ci.MaxDepth
 	if !recursive && depth < 0 {
 		depth = 1
 	}
@@ -1005,7 +1061,7 @@ func ConfigMaxDepth(recursive bool) int {
 
 // ListDir lists the directories/buckets/containers in the Fs to the supplied writer
 func ListDir(ctx context.Context, f fs.Fs, w io.Writer) error {
-	return walk.ListR(ctx, f, "", false, ConfigMaxDepth(false), walk.ListDirs, func(entries fs.DirEntries) error {
+	return walk.ListR(ctx, f, "", false, ConfigMaxDepth(ctx, false), walk.ListDirs, func(entries fs.DirEntries) error {
 		entries.ForDir(func(dir fs.Directory) {
 			if dir != nil {
 				syncFprintf(w, "%12d %13s %9d %s\n", dir.Size(), dir.ModTime(ctx).Local().Format("2006-01-02 15:04:05"), dir.Items(), dir.Remote())
@@ -1017,14 +1073,15 @@ func ListDir(ctx context.Context, f fs.Fs, w io.Writer) error {
 
 // Mkdir makes a destination directory or container
 func Mkdir(ctx context.Context, f fs.Fs, dir string) error {
-	if fs.Config.DryRun {
+	ci := fs.GetConfig(ctx)
+	if ci.DryRun {
 		fs.Logf(fs.LogDirName(f, dir), "Not making directory as dry run is set")
 		return nil
 	}
 	fs.Debugf(fs.LogDirName(f, dir), "Making directory")
 	err := f.Mkdir(ctx, dir)
 	if err != nil {
-		fs.CountError(err)
+		fs.CountError(ctx, err)
 		return err
 	}
 	return nil
@@ -1033,7 +1090,8 @@ func Mkdir(ctx context.Context, f fs.Fs, dir string) error {
 // TryRmdir removes a container but not if not empty.  It doesn't
 // count errors but may return one.
 func TryRmdir(ctx context.Context, f fs.Fs, dir string) error {
-	if fs.Config.DryRun {
+	ci := fs.GetConfig(ctx)
+	if ci.DryRun {
 		fs.Logf(fs.LogDirName(f, dir), "Not deleting as dry run is set")
 		return nil
 	}
@@ -1045,7 +1103,7 @@ func TryRmdir(ctx context.Context, f fs.Fs, dir string) error {
 func Rmdir(ctx context.Context, f fs.Fs, dir string) error {
 	err := TryRmdir(ctx, f, dir)
 	if err != nil {
-		fs.CountError(err)
+		fs.CountError(ctx, err)
 		return err
 	}
 	return err
@@ -1053,13 +1111,14 @@ func Rmdir(ctx context.Context, f fs.Fs, dir string) error {
 
 // Purge removes a directory and all of its contents
 func Purge(ctx context.Context, f fs.Fs, dir string) error {
+	ci := fs.GetConfig(ctx)
 	doFallbackPurge := true
 	var err error
 	if dir == "" {
 		// FIXME change the Purge interface so it takes a dir - see #1891
 		if doPurge := f.Features().Purge; doPurge != nil {
 			doFallbackPurge = false
-			if fs.Config.DryRun {
+			if ci.DryRun {
 				fs.Logf(f, "Not purging as --dry-run set")
 			} else {
 				err = doPurge(ctx)
@@ -1078,7 +1137,7 @@ func Purge(ctx context.Context, f fs.Fs, dir string) error {
 		err = Rmdirs(ctx, f, dir, false)
 	}
 	if err != nil {
-		fs.CountError(err)
+		fs.CountError(ctx, err)
 		return err
 	}
 	return nil
@@ -1087,7 +1146,8 @@ func Purge(ctx context.Context, f fs.Fs, dir string) error {
 // Delete removes all the contents of a container.  Unlike Purge, it
 // obeys includes and excludes.
 func Delete(ctx context.Context, f fs.Fs) error {
-	delChan := make(fs.ObjectsChan, fs.Config.Transfers)
+	ci := fs.GetConfig(ctx)
+	delChan := make(fs.ObjectsChan, ci.Transfers)
 	delErr := make(chan error, 1)
 	go func() {
 		delErr <- DeleteFiles(ctx, delChan)
@@ -1110,10 +1170,11 @@ func Delete(ctx context.Context, f fs.Fs) error {
 //
 // If the error was ErrorDirNotFound then it will be ignored
 func listToChan(ctx context.Context, f fs.Fs, dir string) fs.ObjectsChan {
-	o := make(fs.ObjectsChan, fs.Config.Checkers)
+	ci := fs.GetConfig(ctx)
+	o := make(fs.ObjectsChan, ci.Checkers)
 	go func() {
 		defer close(o)
-		err := walk.ListR(ctx, f, dir, true, fs.Config.MaxDepth, walk.ListObjects, func(entries fs.DirEntries) error {
+		err := walk.ListR(ctx, f, dir, true, ci.MaxDepth, walk.ListObjects, func(entries fs.DirEntries) error {
 			entries.ForObject(func(obj fs.Object) {
 				o <- obj
 			})
@@ -1121,7 +1182,7 @@ func listToChan(ctx context.Context, f fs.Fs, dir string) fs.ObjectsChan {
 		})
 		if err != nil && err != fs.ErrorDirNotFound {
 			err = errors.Wrap(err, "failed to list")
-			fs.CountError(err)
+			fs.CountError(ctx, err)
 			fs.Errorf(nil, "%v", err)
 		}
 	}()
@@ -1130,11 +1191,12 @@ func listToChan(ctx context.Context, f fs.Fs, dir string) fs.ObjectsChan {
 
 // CleanUp removes the trash for the Fs
 func CleanUp(ctx context.Context, f fs.Fs) error {
+	ci := fs.GetConfig(ctx)
 	doCleanUp := f.Features().CleanUp
 	if doCleanUp == nil {
 		return errors.Errorf("%v doesn't support cleanup", f)
 	}
-	if fs.Config.DryRun {
+	if ci.DryRun {
 		fs.Logf(f, "Not running cleanup as --dry-run set")
 		return nil
 	}
@@ -1159,9 +1221,9 @@ func Cat(ctx context.Context, f fs.Fs, w io.Writer, offset, count int64) error {
 	var mu sync.Mutex
 	return ListFn(ctx, f, func(o fs.Object) {
 		var err error
-		accounting.Stats.Transferring(o.Remote())
+		accounting.Stats(ctx).Transferring(o.Remote())
 		defer func() {
-			accounting.Stats.DoneTransferring(o.Remote(), err == nil)
+			accounting.Stats(ctx).DoneTransferring(o.Remote(), err == nil)
 		}()
 		opt := fs.RangeOption{Start: offset, End: -1}
 		size := o.Size()
@@ -1177,7 +1239,7 @@ func Cat(ctx context.Context, f fs.Fs, w io.Writer, offset, count int64) error {
 		}
 		in, err := o.Open(ctx, options...)
 		if err != nil {
-			fs.CountError(err)
+			fs.CountError(ctx, err)
 			fs.Errorf(o, "Failed to open: %v", err)
 			return
 		}
@@ -1188,11 +1250,11 @@ func Cat(ctx context.Context, f fs.Fs, w io.Writer, offset, count int64) error {
 				size = count
 			}
 		}
-		in = accounting.NewAccountSizeName(in, size, o.Remote()).WithBuffer() // account and buffer the transfer
+		in = accounting.Stats(ctx).NewAccountSizeName(in, size, o.Remote()).WithBwLimit(ctx).WithBuffer() // account and buffer the transfer
 		defer func() {
 			err = in.Close()
 			if err != nil {
-				fs.CountError(err)
+				fs.CountError(ctx, err)
 				fs.Errorf(o, "Failed to close: %v", err)
 			}
 		}()
@@ -1201,7 +1263,7 @@ func Cat(ctx context.Context, f fs.Fs, w io.Writer, offset, count int64) error {
 		defer mu.Unlock()
 		_, err = io.Copy(w, in)
 		if err != nil {
-			fs.CountError(err)
+			fs.CountError(ctx, err)
 			fs.Errorf(o, "Failed to send to output: %v", err)
 		}
 	})
@@ -1209,10 +1271,11 @@ func Cat(ctx context.Context, f fs.Fs, w io.Writer, offset, count int64) error {
 
 // Rcat reads data from the Reader until EOF and uploads it to a file on remote
 func Rcat(ctx context.Context, fdst fs.Fs, dstFileName string, in io.ReadCloser, modTime time.Time) (dst fs.Object, err error) {
-	accounting.Stats.Transferring(dstFileName)
-	in = accounting.NewAccountSizeName(in, -1, dstFileName).WithBuffer()
+	ci := fs.GetConfig(ctx)
+	accounting.Stats(ctx).Transferring(dstFileName)
+	in = accounting.Stats(ctx).NewAccountSizeName(in, -1, dstFileName).WithBwLimit(ctx).WithBuffer()
 	defer func() {
-		accounting.Stats.DoneTransferring(dstFileName, err == nil)
+		accounting.Stats(ctx).DoneTransferring(dstFileName, err == nil)
 		if otherErr := in.Close(); otherErr != nil {
 			fs.Debugf(fdst, "Rcat: failed to close source: %v", err)
 		}
@@ -1230,7 +1293,7 @@ func Rcat(ctx context.Context, fdst fs.Fs, dstFileName string, in io.ReadCloser,
 		src := object.NewStaticObjectInfo(dstFileName, modTime, int64(readCounter.BytesRead()), false, hash.Sums(), fdst)
 		if !Equal(ctx, src, dst) {
 			err = errors.Errorf("corrupted on transfer")
-			fs.CountError(err)
+			fs.CountError(ctx, err)
 			fs.Errorf(dst, "%v", err)
 			return err
 		}
@@ -1238,7 +1301,7 @@ func Rcat(ctx context.Context, fdst fs.Fs, dstFileName string, in io.ReadCloser,
 	}
 
 	// check if file small enough for direct upload
-	buf := make([]byte, fs.Config.StreamingUploadCutoff)
+	buf := make([]byte, ci.StreamingUploadCutoff)
 	if n, err := io.ReadFull(trackingIn, buf); err == io.EOF || err == io.ErrUnexpectedEOF {
 		fs.Debugf(fdst, "File to upload is small (%d bytes), uploading instead of streaming", n)
 		src := object.NewMemoryObject(dstFileName, modTime, buf[:n])
@@ -1268,7 +1331,7 @@ func Rcat(ctx context.Context, fdst fs.Fs, dstFileName string, in io.ReadCloser,
 		fStreamTo = tmpLocalFs
 	}
 
-	if fs.Config.DryRun {
+	if ci.DryRun {
 		fs.Logf("stdin", "Not uploading as --dry-run")
 		// prevents "broken pipe" errors
 		_, err = io.Copy(ioutil.Discard, in)
@@ -1301,11 +1364,12 @@ func PublicLink(ctx context.Context, f fs.Fs, remote string) (string, error) {
 // Rmdirs removes any empty directories (or directories only
 // containing empty directories) under f, including f.
 func Rmdirs(ctx context.Context, f fs.Fs, dir string, leaveRoot bool) error {
+	ci := fs.GetConfig(ctx)
 	dirEmpty := make(map[string]bool)
 	dirEmpty[dir] = !leaveRoot
-	err := walk.Walk(ctx, f, dir, true, fs.Config.MaxDepth, func(dirPath string, entries fs.DirEntries, err error) error {
+	err := walk.Walk(ctx, f, dir, true, ci.MaxDepth, func(dirPath string, entries fs.DirEntries, err error) error {
 		if err != nil {
-			fs.CountError(err)
+			fs.CountError(ctx, err)
 			fs.Errorf(f, "Failed to list %q: %v", dirPath, err)
 			return nil
 		}
@@ -1352,7 +1416,7 @@ func Rmdirs(ctx context.Context, f fs.Fs, dir string, leaveRoot bool) error {
 		dir := toDelete[i]
 		err := TryRmdir(ctx, f, dir)
 		if err != nil {
-			fs.CountError(err)
+			fs.CountError(ctx, err)
 			fs.Errorf(dir, "Failed to rmdir: %v", err)
 			return err
 		}
@@ -1366,22 +1430,23 @@ func Rmdirs(ctx context.Context, f fs.Fs, dir string, leaveRoot bool) error {
 // Returns a flag which indicates whether the file needs to be
 // transferred or not.
 func NeedTransfer(ctx context.Context, dst, src fs.Object) bool {
+	ci := fs.GetConfig(ctx)
 	if dst == nil {
 		fs.Debugf(src, "Couldn't find file - need to transfer")
 		return true
 	}
 	// If we should ignore existing files, don't transfer
-	if fs.Config.IgnoreExisting {
+	if ci.IgnoreExisting {
 		fs.Debugf(src, "Destination exists, skipping")
 		return false
 	}
 	// If we should upload unconditionally
-	if fs.Config.IgnoreTimes {
+	if ci.IgnoreTimes {
 		fs.Debugf(src, "Transferring unconditionally as --ignore-times is in use")
 		return true
 	}
 	// If UpdateOlder is in effect, skip if dst is newer than src
-	if fs.Config.UpdateOlder {
+	if ci.UpdateOlder {
 		srcModTime := src.ModTime(ctx)
 		dstModTime := dst.ModTime(ctx)
 		dt := dstModTime.Sub(srcModTime)
@@ -1419,15 +1484,16 @@ func NeedTransfer(ctx context.Context, dst, src fs.Object) bool {
 // RcatSize reads data from the Reader until EOF and uploads it to a file on remote.
 // Pass in size >=0 if known, <0 if not known
 func RcatSize(ctx context.Context, fdst fs.Fs, dstFileName string, in io.ReadCloser, size int64, modTime time.Time) (dst fs.Object, err error) {
+	ci := fs.GetConfig(ctx)
 	var obj fs.Object
 
 	if size >= 0 {
 		// Size known use Put
-		accounting.Stats.Transferring(dstFileName)
-		body := ioutil.NopCloser(in)                                 // we let the server close the body
-		in := accounting.NewAccountSizeName(body, size, dstFileName) // account the transfer (no buffering)
+		accounting.Stats(ctx).Transferring(dstFileName)
+		body := ioutil.NopCloser(in)                                            // we let the server close the body
+		in := accounting.Stats(ctx).NewAccountSizeName(body, size, dstFileName).WithBwLimit(ctx) // account the transfer (no buffering)
 
-		if fs.Config.DryRun {
+		if ci.DryRun {
 			fs.Logf("stdin", "Not uploading as --dry-run")
 			// prevents "broken pipe" errors
 			_, err = io.Copy(ioutil.Discard, in)
@@ -1438,10 +1504,10 @@ func RcatSize(ctx context.Context, fdst fs.Fs, dstFileName string, in io.ReadClo
 		defer func() {
 			closeErr := in.Close()
 			if closeErr != nil {
-				accounting.Stats.Error(closeErr)
+				accounting.Stats(ctx).Error(closeErr)
 				fs.Errorf(dstFileName, "Post request: close failed: %v", closeErr)
 			}
-			accounting.Stats.DoneTransferring(dstFileName, err == nil)
+			accounting.Stats(ctx).DoneTransferring(dstFileName, err == nil)
 		}()
 		info := object.NewStaticObjectInfo(dstFileName, modTime, size, true, nil, fdst)
 		obj, err = fdst.Put(ctx, in, info)
@@ -1465,7 +1531,8 @@ func RcatSize(ctx context.Context, fdst fs.Fs, dstFileName string, in io.ReadClo
 
 // CopyURL copies the data from the url to (fdst, dstFileName)
 func CopyURL(ctx context.Context, fdst fs.Fs, dstFileName string, url string) (dst fs.Object, err error) {
-	client := fshttp.NewClient(fs.Config)
+	ci := fs.GetConfig(ctx)
+	client := fshttp.NewClient(ci)
 	resp, err := client.Get(url)
 
 	if err != nil {
@@ -1476,11 +1543,12 @@ func CopyURL(ctx context.Context, fdst fs.Fs, dstFileName string, url string) (d
 }
 
 // BackupDir returns the correctly configured --backup-dir
-func BackupDir(fdst fs.Fs, fsrc fs.Fs, srcFileName string) (backupDir fs.Fs, err error) {
-	if fs.Config.BackupDir != "" {
-		backupDir, err = cache.Get(fs.Config.BackupDir)
+func BackupDir(ctx context.Context, fdst fs.Fs, fsrc fs.Fs, srcFileName string) (backupDir fs.Fs, err error) {
+	ci := fs.GetConfig(ctx)
+	if ci.BackupDir != "" {
+		backupDir, err = cache.Get(ci.BackupDir)
 		if err != nil {
-			return nil, fserrors.FatalError(errors.Errorf("Failed to make fs for --backup-dir %q: %v", fs.Config.BackupDir, err))
+			return nil, fserrors.FatalError(errors.Errorf("Failed to make fs for --backup-dir %q: %v", ci.BackupDir, err))
 		}
 		if !SameConfig(fdst, backupDir) {
 			return nil, fserrors.FatalError(errors.New("parameter to --backup-dir has to be on the same remote as destination"))
@@ -1493,7 +1561,7 @@ func BackupDir(fdst fs.Fs, fsrc fs.Fs, srcFileName string) (backupDir fs.Fs, err
 				return nil, fserrors.FatalError(errors.New("source and parameter to --backup-dir mustn't overlap"))
 			}
 		} else {
-			if fs.Config.Suffix == "" {
+			if ci.Suffix == "" {
 				if SameDir(fdst, backupDir) {
 					return nil, fserrors.FatalError(errors.New("destination and parameter to --backup-dir mustn't be the same"))
 				}
@@ -1517,14 +1585,109 @@ func BackupDir(fdst fs.Fs, fsrc fs.Fs, srcFileName string) (backupDir fs.Fs, err
 
 // MoveBackupDir moves a file to the backup dir
 func MoveBackupDir(ctx context.Context, backupDir fs.Fs, dst fs.Object) (err error) {
-	remoteWithSuffix := SuffixName(dst.Remote())
+	remoteWithSuffix := SuffixName(ctx, dst.Remote())
 	overwritten, _ := backupDir.NewObject(ctx, remoteWithSuffix)
 	_, err = Move(ctx, backupDir, overwritten, remoteWithSuffix, dst)
 	return err
 }
 
+// CompareOrCopyDest makes the Fs for the directories in
+// --compare-dest and --copy-dest, checking they are usable with fdst
+// and fsrc.
+func CompareOrCopyDest(ctx context.Context, fdst fs.Fs, fsrc fs.Fs) (compareDest, copyDest []fs.Fs, err error) {
+	ci := fs.GetConfig(ctx)
+	if len(ci.CompareDest) > 0 && len(ci.CopyDest) > 0 {
+		return nil, nil, fserrors.FatalError(errors.New("can't use --compare-dest with --copy-dest"))
+	}
+	makeDirs := func(flag string, dirs []string, sameRemote bool) (fss []fs.Fs, err error) {
+		for _, dir := range dirs {
+			f, err := cache.Get(dir)
+			if err != nil {
+				return nil, fserrors.FatalError(errors.Errorf("Failed to make fs for --%s %q: %v", flag, dir, err))
+			}
+			if sameRemote && !SameConfig(fdst, f) {
+				return nil, fserrors.FatalError(errors.Errorf("parameter to --%s has to be on the same remote as destination", flag))
+			}
+			if SameDir(fdst, f) {
+				return nil, fserrors.FatalError(errors.Errorf("destination and parameter to --%s mustn't be the same", flag))
+			}
+			if SameDir(fsrc, f) {
+				return nil, fserrors.FatalError(errors.Errorf("source and parameter to --%s mustn't be the same", flag))
+			}
+			fss = append(fss, f)
+		}
+		return fss, nil
+	}
+	compareDest, err = makeDirs("compare-dest", ci.CompareDest, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	copyDest, err = makeDirs("copy-dest", ci.CopyDest, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return compareDest, copyDest, nil
+}
+
+// findDest looks in each of dirs in turn for a file identical to src
+// returning the first one found or nil if there isn't one.
+func findDest(ctx context.Context, dirs []fs.Fs, src fs.Object) (fs.Object, error) {
+	for _, dir := range dirs {
+		o, err := dir.NewObject(ctx, src.Remote())
+		if err == fs.ErrorObjectNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if Equal(ctx, src, o) {
+			return o, nil
+		}
+	}
+	return nil, nil
+}
+
+// CompareDest checks the --compare-dest directories for a file
+// identical to src.
+//
+// It returns true if one was found and src needn't be transferred.
+func CompareDest(ctx context.Context, compareDest []fs.Fs, src fs.Object) (found bool, err error) {
+	o, err := findDest(ctx, compareDest, src)
+	if err != nil || o == nil {
+		return false, err
+	}
+	fs.Debugf(src, "Identical file found in --compare-dest %v, skipping", o.Fs())
+	return true, nil
+}
+
+// CopyDest checks the --copy-dest directories for a file identical to
+// src and if found copies it server side into fdst, replacing dst
+// which may be nil. If backupDir is set dst is moved there first.
+//
+// It returns true if the file was copied and src needn't be
+// transferred.
+func CopyDest(ctx context.Context, fdst fs.Fs, dst fs.Object, copyDest []fs.Fs, src fs.Object, backupDir fs.Fs) (copied bool, err error) {
+	o, err := findDest(ctx, copyDest, src)
+	if err != nil || o == nil {
+		return false, err
+	}
+	if dst != nil && backupDir != nil {
+		err = MoveBackupDir(ctx, backupDir, dst)
+		if err != nil {
+			return false, err
+		}
+		dst = nil
+	}
+	_, err = Copy(ctx, fdst, dst, src.Remote(), o)
+	if err != nil {
+		return false, err
+	}
+	fs.Debugf(src, "Copied identical file from --copy-dest %v", o.Fs())
+	return true, nil
+}
+
 // moveOrCopyFile moves or copies a single file possibly to a new name
 func moveOrCopyFile(ctx context.Context, fdst fs.Fs, fsrc fs.Fs, dstFileName string, srcFileName string, cp bool) (err error) {
+	ci := fs.GetConfig(ctx)
 	dstFilePath := path.Join(fdst.Root(), dstFileName)
 	srcFilePath := path.Join(fsrc.Root(), srcFileName)
 	if fdst.Name() == fsrc.Name() && dstFilePath == srcFilePath {
@@ -1566,21 +1729,21 @@ func moveOrCopyFile(ctx context.Context, fdst fs.Fs, fsrc fs.Fs, dstFileName str
 			}
 			return errors.Wrap(err, "error while attempting to move file to a temporary location")
 		}
-		accounting.Stats.Transferring(srcFileName)
+		accounting.Stats(ctx).Transferring(srcFileName)
 		tmpObj, err := Op(ctx, fdst, nil, tmpObjName, srcObj)
 		if err != nil {
-			accounting.Stats.DoneTransferring(srcFileName, false)
+			accounting.Stats(ctx).DoneTransferring(srcFileName, false)
 			return errors.Wrap(err, "error while moving file to temporary location")
 		}
 		_, err = Op(ctx, fdst, nil, dstFileName, tmpObj)
-		accounting.Stats.DoneTransferring(srcFileName, err == nil)
+		accounting.Stats(ctx).DoneTransferring(srcFileName, err == nil)
 		return err
 	}
 
 	if NeedTransfer(ctx, dstObj, srcObj) {
 		// If destination already exists, then we must move it into --backup-dir if required
-		if dstObj != nil && (fs.Config.BackupDir != "" || fs.Config.Suffix != "") {
-			backupDir, err := BackupDir(fdst, fsrc, srcFileName)
+		if dstObj != nil && (ci.BackupDir != "" || ci.Suffix != "") {
+			backupDir, err := BackupDir(ctx, fdst, fsrc, srcFileName)
 			if err != nil {
 				return errors.Wrap(err, "creating Fs for --backup-dir failed")
 			}
@@ -1594,11 +1757,11 @@ func moveOrCopyFile(ctx context.Context, fdst fs.Fs, fsrc fs.Fs, dstFileName str
 
 		_, err = Op(ctx, fdst, dstObj, dstFileName, srcObj)
 	} else {
-		accounting.Stats.Checking(srcFileName)
+		accounting.Stats(ctx).Checking(srcFileName)
 		if !cp {
 			err = DeleteFile(ctx, srcObj)
 		}
-		defer accounting.Stats.DoneChecking(srcFileName)
+		defer accounting.Stats(ctx).DoneChecking(srcFileName)
 	}
 	return err
 }
@@ -1789,6 +1952,7 @@ func (l *ListFormat) Format(entry *ListJSONItem) (result string) {
 // It does this by loading the directory tree into memory (using ListR
 // if available) and doing renames in parallel.
 func DirMove(ctx context.Context, f fs.Fs, srcRemote, dstRemote string) (err error) {
+	ci := fs.GetConfig(ctx)
 	// Use DirMove if possible
 	if doDirMove := f.Features().DirMove; doDirMove != nil {
 		return doDirMove(ctx, f, srcRemote, dstRemote)
@@ -1817,9 +1981,9 @@ func DirMove(ctx context.Context, f fs.Fs, srcRemote, dstRemote string) (err err
 		o       fs.Object
 		newPath string
 	}
-	renames := make(chan rename, fs.Config.Transfers)
+	renames := make(chan rename, ci.Transfers)
 	g, gCtx := errgroup.WithContext(context.Background())
-	for i := 0; i < fs.Config.Transfers; i++ {
+	for i := 0; i < ci.Transfers; i++ {
 		g.Go(func() error {
 			for job := range renames {
 				dstOverwritten, _ := f.NewObject(gCtx, job.newPath)
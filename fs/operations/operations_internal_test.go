@@ -3,6 +3,7 @@
 package operations
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -33,7 +34,7 @@ func TestSizeDiffers(t *testing.T) {
 		dst := object.NewStaticObjectInfo("a", when, test.dstSize, true, nil, nil)
 		oldIgnoreSize := fs.Config.IgnoreSize
 		fs.Config.IgnoreSize = test.ignoreSize
-		got := sizeDiffers(src, dst)
+		got := sizeDiffers(context.Background(), src, dst)
 		fs.Config.IgnoreSize = oldIgnoreSize
 		assert.Equal(t, test.want, got, fmt.Sprintf("ignoreSize=%v, srcSize=%v, dstSize=%v", test.ignoreSize, test.srcSize, test.dstSize))
 	}
@@ -14,7 +14,7 @@
 // fstest.CheckItems() before use.  This make sure the directory
 // listing is now consistent and stops cascading errors.
 //
-// Call accounting.Stats.ResetCounters() before every fs.Sync() as it
+// Call accounting.GlobalStats().ResetCounters() before every fs.Sync() as it
 // uses the error count internally.
 
 package operations_test
@@ -36,11 +36,15 @@ import (
 	"time"
 
 	_ "github.com/ncw/rclone/backend/all" // import all backends
+	"github.com/ncw/rclone/backend/crypt"
 	"github.com/ncw/rclone/fs"
 	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/config/configmap"
+	"github.com/ncw/rclone/fs/config/obscure"
 	"github.com/ncw/rclone/fs/filter"
 	"github.com/ncw/rclone/fs/fshttp"
 	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/fs/object"
 	"github.com/ncw/rclone/fs/operations"
 	"github.com/ncw/rclone/fstest"
 	"github.com/stretchr/testify/assert"
@@ -267,7 +271,7 @@ func TestSuffixName(t *testing.T) {
 	} {
 		fs.Config.Suffix = test.suffix
 		fs.Config.SuffixKeepExtension = test.keepExt
-		got := operations.SuffixName(test.remote)
+		got := operations.SuffixName(context.Background(), test.remote)
 		assert.Equal(t, test.want, got, fmt.Sprintf("%+v", test))
 	}
 }
@@ -315,15 +319,15 @@ func testCheck(t *testing.T, checkFunction func(ctx context.Context, fdst, fsrc
 
 	check := func(i int, wantErrors int64, wantChecks int64, oneway bool) {
 		fs.Debugf(r.Fremote, "%d: Starting check test", i)
-		accounting.Stats.ResetCounters()
+		accounting.GlobalStats().ResetCounters()
 		var buf bytes.Buffer
 		log.SetOutput(&buf)
 		defer func() {
 			log.SetOutput(os.Stderr)
 		}()
 		err := checkFunction(context.Background(), r.Fremote, r.Flocal, oneway)
-		gotErrors := accounting.Stats.GetErrors()
-		gotChecks := accounting.Stats.GetChecks()
+		gotErrors := accounting.GlobalStats().GetErrors()
+		gotChecks := accounting.GlobalStats().GetChecks()
 		if wantErrors == 0 && err != nil {
 			t.Errorf("%d: Got error when not expecting one: %v", i, err)
 		}
@@ -840,6 +844,46 @@ func TestCopyFile(t *testing.T) {
 	fstest.CheckItems(t, r.Fremote, file2)
 }
 
+func TestCopyFileMetadata(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	file1 := r.WriteFile("file1", "file1 contents", t1)
+	src, err := r.Flocal.NewObject(ctx, file1.Path)
+	require.NoError(t, err)
+	srcMetadata, err := fs.GetMetadata(ctx, src)
+	require.NoError(t, err)
+	if srcMetadata["mode"] == "" {
+		t.Skip("Skipping test as local doesn't read the mode on this platform")
+	}
+
+	// Map the mode so we can tell the metadata was written
+	fs.Config.Metadata = true
+	fs.MapMetadata = func(ctx context.Context, src fs.ObjectInfo, fdst fs.Info, metadata fs.Metadata) (fs.Metadata, error) {
+		assert.Equal(t, r.Fremote, fdst)
+		metadata["mode"] = "100640"
+		return metadata, nil
+	}
+	defer func() {
+		fs.Config.Metadata = false
+		fs.MapMetadata = nil
+	}()
+
+	err = operations.CopyFile(ctx, r.Fremote, r.Flocal, file1.Path, file1.Path)
+	require.NoError(t, err)
+	fstest.CheckItems(t, r.Fremote, file1)
+
+	dst, err := r.Fremote.NewObject(ctx, file1.Path)
+	require.NoError(t, err)
+	dstMetadata, err := fs.GetMetadata(ctx, dst)
+	require.NoError(t, err)
+	if _, ok := dst.(fs.Metadataer); !ok || dstMetadata["mode"] == "" {
+		t.Skip("Skipping test as remote doesn't support mode metadata")
+	}
+	assert.Equal(t, "100640", dstMetadata["mode"])
+}
+
 func TestCopyFileBackupDir(t *testing.T) {
 	r := fstest.NewRun(t)
 	defer r.Finalise()
@@ -1257,3 +1301,35 @@ func TestGetFsInfo(t *testing.T) {
 	assert.Equal(t, f.Hashes(), hashSet)
 	assert.Equal(t, f.Features().Enabled(), info.Features)
 }
+
+// Check the encrypted names are shown for crypt remotes configured
+// with connection strings
+func TestListJSONEncryptedConnectionString(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "rclone-lsjson-crypt")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	for _, password := range []string{"potato", "sausage"} {
+		obscured := obscure.MustObscure(password)
+		fsrc, err := fs.NewFs(fmt.Sprintf(":crypt,remote='%s/%s',password='%s':", dir, password, obscured))
+		require.NoError(t, err)
+		src := object.NewStaticObjectInfo("file.txt", t1, 5, true, nil, fsrc)
+		_, err = fsrc.Put(ctx, bytes.NewBufferString("hello"), src)
+		require.NoError(t, err)
+
+		cipher, err := crypt.NewCipher(configmap.Simple{"password": obscured, "filename_encryption": "standard"})
+		require.NoError(t, err)
+		var items []*operations.ListJSONItem
+		err = operations.ListJSON(ctx, fsrc, "", &operations.ListJSONOpt{ShowEncrypted: true}, func(item *operations.ListJSONItem) error {
+			items = append(items, item)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, len(items))
+		assert.Equal(t, "file.txt", items[0].Path)
+		assert.Equal(t, cipher.EncryptFileName("file.txt"), items[0].EncryptedPath, password)
+	}
+}
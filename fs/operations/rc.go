@@ -25,6 +25,7 @@ func init() {
     - showEncrypted -  If set show decrypted names
     - showOrigIDs - If set show the IDs for each item if known
     - showHash - If set return a dictionary of hashes
+    - metadata - If set return a dictionary of metadata
 
 The result is
 
@@ -331,6 +332,7 @@ This returns info about the remote passed in;
 		"ServerSideAcrossConfigs": false,
 		"SetTier": false,
 		"SetWrapper": false,
+		"SlowHash": false,
 		"UnWrap": false,
 		"WrapFs": false,
 		"WriteMimeType": false
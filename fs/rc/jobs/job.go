@@ -1,14 +1,17 @@
-// Manage background jobs that the rc is running
-
-package rc
+// Package jobs manages background jobs that the rc is running
+package jobs
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/filter"
+	"github.com/ncw/rclone/fs/rc"
 	"github.com/pkg/errors"
 )
 
@@ -16,13 +19,14 @@ import (
 type Job struct {
 	mu        sync.Mutex
 	ID        int64     `json:"id"`
+	Group     string    `json:"group"`
 	StartTime time.Time `json:"startTime"`
 	EndTime   time.Time `json:"endTime"`
 	Error     string    `json:"error"`
 	Finished  bool      `json:"finished"`
 	Success   bool      `json:"success"`
 	Duration  float64   `json:"duration"`
-	Output    Params    `json:"output"`
+	Output    rc.Params `json:"output"`
 	Stop      func()    `json:"-"`
 }
 
@@ -66,6 +70,7 @@ func (jobs *Jobs) Expire() {
 		job.mu.Lock()
 		if job.Finished && now.Sub(job.EndTime) > fs.Config.RcJobExpireDuration {
 			delete(jobs.jobs, ID)
+			accounting.DeleteStatsGroup(job.Group)
 		}
 		job.mu.Unlock()
 	}
@@ -96,11 +101,11 @@ func (jobs *Jobs) Get(ID int64) *Job {
 }
 
 // mark the job as finished
-func (job *Job) finish(out Params, err error) {
+func (job *Job) finish(out rc.Params, err error) {
 	job.mu.Lock()
 	job.EndTime = time.Now()
 	if out == nil {
-		out = make(Params)
+		out = make(rc.Params)
 	}
 	job.Output = out
 	job.Duration = job.EndTime.Sub(job.StartTime).Seconds()
@@ -117,7 +122,7 @@ func (job *Job) finish(out Params, err error) {
 }
 
 // run the job until completion writing the return status
-func (job *Job) run(ctx context.Context, fn Func, in Params) {
+func (job *Job) run(ctx context.Context, fn rc.Func, in rc.Params) {
 	defer func() {
 		if r := recover(); r != nil {
 			job.finish(nil, errors.Errorf("panic received: %v", r))
@@ -127,15 +132,25 @@ func (job *Job) run(ctx context.Context, fn Func, in Params) {
 }
 
 // NewJob start a new Job off
-func (jobs *Jobs) NewJob(fn Func, in Params) *Job {
-	ctx, cancel := context.WithCancel(context.Background())
+//
+// The job runs with a context derived from ctx so it picks up any
+// config and filter in ctx.  ctx should outlive the job, eg be
+// derived from context.Background(), as the job is stopped with Stop.
+//
+// The job runs with its own stats group called "job/<id>" so its
+// transfers are accounted separately from any other job.
+func (jobs *Jobs) NewJob(ctx context.Context, fn rc.Func, in rc.Params) *Job {
+	id := atomic.AddInt64(&jobID, 1)
+	group := fmt.Sprintf("job/%d", id)
+	ctx, cancel := context.WithCancel(accounting.WithStatsGroup(ctx, group))
 	stop := func() {
 		cancel()
 		// Wait for cancel to propagate before returning.
 		<-ctx.Done()
 	}
 	job := &Job{
-		ID:        atomic.AddInt64(&jobID, 1),
+		ID:        id,
+		Group:     group,
 		StartTime: time.Now(),
 		Stop:      stop,
 	}
@@ -147,16 +162,65 @@ func (jobs *Jobs) NewJob(fn Func, in Params) *Job {
 
 }
 
+// WithConfig returns a copy of ctx with the overrides in the _config
+// and _filter parameters of in applied.
+//
+// _config takes the same keys as the "main" block of options/get and
+// _filter the same keys as the "filter" block.  Only the keys given
+// are changed, the rest are inherited from ctx.
+func WithConfig(ctx context.Context, in rc.Params) (context.Context, error) {
+	if _, ok := in["_config"]; ok {
+		var overrides rc.Params
+		err := in.GetStruct("_config", &overrides)
+		if err != nil {
+			return nil, err
+		}
+		var ci *fs.ConfigInfo
+		ctx, ci = fs.AddConfig(ctx)
+		err = rc.Reshape(ci, overrides)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read _config")
+		}
+		if _, ok := overrides["BwLimit"]; ok {
+			// The limit is set once for the job so a timetable
+			// would never change it
+			if len(ci.BwLimit) != 1 {
+				return nil, errors.New("failed to read _config: BwLimit must be a single bandwidth, not a timetable")
+			}
+			ctx = accounting.WithBwLimit(ctx, ci.BwLimit[0].Bandwidth)
+		}
+	}
+	if _, ok := in["_filter"]; ok {
+		opt := filter.GetConfig(ctx).Opt
+		err := in.GetStruct("_filter", &opt)
+		if err != nil {
+			return nil, err
+		}
+		fi, err := filter.NewFilter(&opt)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read _filter")
+		}
+		ctx = filter.ReplaceConfig(ctx, fi)
+	}
+	return ctx, nil
+}
+
 // StartJob starts a new job and returns a Param suitable for output
-func StartJob(fn Func, in Params) (Params, error) {
-	job := running.NewJob(fn, in)
-	out := make(Params)
+//
+// The _config and _filter parameters in in are applied to the job.
+func StartJob(fn rc.Func, in rc.Params) (rc.Params, error) {
+	ctx, err := WithConfig(context.Background(), in)
+	if err != nil {
+		return nil, err
+	}
+	job := running.NewJob(ctx, fn, in)
+	out := make(rc.Params)
 	out["jobid"] = job.ID
 	return out, nil
 }
 
 func init() {
-	Add(Call{
+	rc.Add(rc.Call{
 		Path:  "job/status",
 		Fn:    rcJobStatus,
 		Title: "Reads the status of the job ID",
@@ -170,16 +234,18 @@ Results
 - error - error from the job or empty string for no error
 - finished - boolean whether the job has finished or not
 - id - as passed in above
+- group - name of the stats group the job's transfers are accounted in (eg "job/1")
 - startTime - time the job started (eg "2018-10-26T18:50:20.528336039+01:00")
 - success - boolean - true for success false otherwise
 - output - output of the job as would have been returned if called synchronously
-- progress - output of the progress related to the underlying job
+- progress - output of the progress related to the underlying job, as returned by core/stats for the job's group
+- transferred - list of the transfers the job has completed, as returned by core/transferred for the job's group
 `,
 	})
 }
 
 // Returns the status of a job
-func rcJobStatus(ctx context.Context, in Params) (out Params, err error) {
+func rcJobStatus(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 	jobID, err := in.GetInt64("jobid")
 	if err != nil {
 		return nil, err
@@ -190,16 +256,22 @@ func rcJobStatus(ctx context.Context, in Params) (out Params, err error) {
 	}
 	job.mu.Lock()
 	defer job.mu.Unlock()
-	out = make(Params)
-	err = Reshape(&out, job)
+	out = make(rc.Params)
+	err = rc.Reshape(&out, job)
 	if err != nil {
 		return nil, errors.Wrap(err, "reshape failed in job status")
 	}
+	stats := accounting.StatsGroup(job.Group)
+	out["progress"], err = stats.RemoteStats()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read progress in job status")
+	}
+	out["transferred"] = stats.Transferred()
 	return out, nil
 }
 
 func init() {
-	Add(Call{
+	rc.Add(rc.Call{
 		Path:  "job/list",
 		Fn:    rcJobList,
 		Title: "Lists the IDs of the running jobs",
@@ -212,14 +284,14 @@ Results
 }
 
 // Returns list of job ids.
-func rcJobList(ctx context.Context, in Params) (out Params, err error) {
-	out = make(Params)
+func rcJobList(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	out = make(rc.Params)
 	out["jobids"] = running.IDs()
 	return out, nil
 }
 
 func init() {
-	Add(Call{
+	rc.Add(rc.Call{
 		Path:  "job/stop",
 		Fn:    rcJobStop,
 		Title: "Stop the running job",
@@ -230,7 +302,7 @@ func init() {
 }
 
 // Stops the running job.
-func rcJobStop(ctx context.Context, in Params) (out Params, err error) {
+func rcJobStop(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 	jobID, err := in.GetInt64("jobid")
 	if err != nil {
 		return nil, err
@@ -241,7 +313,7 @@ func rcJobStop(ctx context.Context, in Params) (out Params, err error) {
 	}
 	job.mu.Lock()
 	defer job.mu.Unlock()
-	out = make(Params)
+	out = make(rc.Params)
 	job.Stop()
 	return out, nil
 }
@@ -1,4 +1,4 @@
-package rc
+package jobs
 
 import (
 	"context"
@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/filter"
+	"github.com/ncw/rclone/fs/rc"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -36,10 +39,10 @@ func TestJobsExpire(t *testing.T) {
 	jobs := newJobs()
 	jobs.expireInterval = time.Millisecond
 	assert.Equal(t, false, jobs.expireRunning)
-	job := jobs.NewJob(func(ctx context.Context, in Params) (Params, error) {
+	job := jobs.NewJob(context.Background(), func(ctx context.Context, in rc.Params) (rc.Params, error) {
 		defer close(wait)
 		return in, nil
-	}, Params{})
+	}, rc.Params{})
 	<-wait
 	assert.Equal(t, 1, len(jobs.jobs))
 	jobs.Expire()
@@ -57,14 +60,14 @@ func TestJobsExpire(t *testing.T) {
 	jobs.mu.Unlock()
 }
 
-var noopFn = func(ctx context.Context, in Params) (Params, error) {
+var noopFn = func(ctx context.Context, in rc.Params) (rc.Params, error) {
 	return nil, nil
 }
 
 func TestJobsIDs(t *testing.T) {
 	jobs := newJobs()
-	job1 := jobs.NewJob(noopFn, Params{})
-	job2 := jobs.NewJob(noopFn, Params{})
+	job1 := jobs.NewJob(context.Background(), noopFn, rc.Params{})
+	job2 := jobs.NewJob(context.Background(), noopFn, rc.Params{})
 	wantIDs := []int64{job1.ID, job2.ID}
 	gotIDs := jobs.IDs()
 	require.Equal(t, 2, len(gotIDs))
@@ -76,17 +79,17 @@ func TestJobsIDs(t *testing.T) {
 
 func TestJobsGet(t *testing.T) {
 	jobs := newJobs()
-	job := jobs.NewJob(noopFn, Params{})
+	job := jobs.NewJob(context.Background(), noopFn, rc.Params{})
 	assert.Equal(t, job, jobs.Get(job.ID))
 	assert.Nil(t, jobs.Get(123123123123))
 }
 
-var longFn = func(ctx context.Context, in Params) (Params, error) {
+var longFn = func(ctx context.Context, in rc.Params) (rc.Params, error) {
 	time.Sleep(1 * time.Hour)
 	return nil, nil
 }
 
-var ctxFn = func(ctx context.Context, in Params) (Params, error) {
+var ctxFn = func(ctx context.Context, in rc.Params) (rc.Params, error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
@@ -105,17 +108,17 @@ func sleepJob() {
 
 func TestJobFinish(t *testing.T) {
 	jobs := newJobs()
-	job := jobs.NewJob(longFn, Params{})
+	job := jobs.NewJob(context.Background(), longFn, rc.Params{})
 	sleepJob()
 
 	assert.Equal(t, true, job.EndTime.IsZero())
-	assert.Equal(t, Params(nil), job.Output)
+	assert.Equal(t, rc.Params(nil), job.Output)
 	assert.Equal(t, 0.0, job.Duration)
 	assert.Equal(t, "", job.Error)
 	assert.Equal(t, false, job.Success)
 	assert.Equal(t, false, job.Finished)
 
-	wantOut := Params{"a": 1}
+	wantOut := rc.Params{"a": 1}
 	job.finish(wantOut, nil)
 
 	assert.Equal(t, false, job.EndTime.IsZero())
@@ -125,18 +128,18 @@ func TestJobFinish(t *testing.T) {
 	assert.Equal(t, true, job.Success)
 	assert.Equal(t, true, job.Finished)
 
-	job = jobs.NewJob(longFn, Params{})
+	job = jobs.NewJob(context.Background(), longFn, rc.Params{})
 	sleepJob()
 	job.finish(nil, nil)
 
 	assert.Equal(t, false, job.EndTime.IsZero())
-	assert.Equal(t, Params{}, job.Output)
+	assert.Equal(t, rc.Params{}, job.Output)
 	assert.True(t, job.Duration >= floatSleepTime)
 	assert.Equal(t, "", job.Error)
 	assert.Equal(t, true, job.Success)
 	assert.Equal(t, true, job.Finished)
 
-	job = jobs.NewJob(longFn, Params{})
+	job = jobs.NewJob(context.Background(), longFn, rc.Params{})
 	sleepJob()
 	job.finish(wantOut, errors.New("potato"))
 
@@ -152,14 +155,14 @@ func TestJobFinish(t *testing.T) {
 // part of NewJob, now just test the panic catching
 func TestJobRunPanic(t *testing.T) {
 	wait := make(chan struct{})
-	boom := func(ctx context.Context, in Params) (Params, error) {
+	boom := func(ctx context.Context, in rc.Params) (rc.Params, error) {
 		sleepJob()
 		defer close(wait)
 		panic("boom")
 	}
 
 	jobs := newJobs()
-	job := jobs.NewJob(boom, Params{})
+	job := jobs.NewJob(context.Background(), boom, rc.Params{})
 	<-wait
 	runtime.Gosched() // yield to make sure job is updated
 
@@ -176,7 +179,7 @@ func TestJobRunPanic(t *testing.T) {
 
 	job.mu.Lock()
 	assert.Equal(t, false, job.EndTime.IsZero())
-	assert.Equal(t, Params{}, job.Output)
+	assert.Equal(t, rc.Params{}, job.Output)
 	assert.True(t, job.Duration >= floatSleepTime)
 	assert.Equal(t, "panic received: boom", job.Error)
 	assert.Equal(t, false, job.Success)
@@ -187,7 +190,7 @@ func TestJobRunPanic(t *testing.T) {
 func TestJobsNewJob(t *testing.T) {
 	jobID = 0
 	jobs := newJobs()
-	job := jobs.NewJob(noopFn, Params{})
+	job := jobs.NewJob(context.Background(), noopFn, rc.Params{})
 	assert.Equal(t, int64(1), job.ID)
 	assert.Equal(t, job, jobs.Get(1))
 	assert.NotEmpty(t, job.Stop)
@@ -195,19 +198,19 @@ func TestJobsNewJob(t *testing.T) {
 
 func TestStartJob(t *testing.T) {
 	jobID = 0
-	out, err := StartJob(longFn, Params{})
+	out, err := StartJob(longFn, rc.Params{})
 	assert.NoError(t, err)
-	assert.Equal(t, Params{"jobid": int64(1)}, out)
+	assert.Equal(t, rc.Params{"jobid": int64(1)}, out)
 }
 
 func TestRcJobStatus(t *testing.T) {
 	jobID = 0
-	_, err := StartJob(longFn, Params{})
+	_, err := StartJob(longFn, rc.Params{})
 	assert.NoError(t, err)
 
-	call := Calls.Get("job/status")
+	call := rc.Calls.Get("job/status")
 	assert.NotNil(t, call)
-	in := Params{"jobid": 1}
+	in := rc.Params{"jobid": 1}
 	out, err := call.Fn(context.Background(), in)
 	require.NoError(t, err)
 	require.NotNil(t, out)
@@ -216,12 +219,12 @@ func TestRcJobStatus(t *testing.T) {
 	assert.Equal(t, false, out["finished"])
 	assert.Equal(t, false, out["success"])
 
-	in = Params{"jobid": 123123123}
+	in = rc.Params{"jobid": 123123123}
 	_, err = call.Fn(context.Background(), in)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "job not found")
 
-	in = Params{"jobidx": 123123123}
+	in = rc.Params{"jobidx": 123123123}
 	_, err = call.Fn(context.Background(), in)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "Didn't find key")
@@ -229,45 +232,45 @@ func TestRcJobStatus(t *testing.T) {
 
 func TestRcJobList(t *testing.T) {
 	jobID = 0
-	_, err := StartJob(longFn, Params{})
+	_, err := StartJob(longFn, rc.Params{})
 	assert.NoError(t, err)
 
-	call := Calls.Get("job/list")
+	call := rc.Calls.Get("job/list")
 	assert.NotNil(t, call)
-	in := Params{}
+	in := rc.Params{}
 	out, err := call.Fn(context.Background(), in)
 	require.NoError(t, err)
 	require.NotNil(t, out)
-	assert.Equal(t, Params{"jobids": []int64{1}}, out)
+	assert.Equal(t, rc.Params{"jobids": []int64{1}}, out)
 }
 
 func TestRcJobStop(t *testing.T) {
 	jobID = 0
-	_, err := StartJob(ctxFn, Params{})
+	_, err := StartJob(ctxFn, rc.Params{})
 	assert.NoError(t, err)
 
-	call := Calls.Get("job/stop")
+	call := rc.Calls.Get("job/stop")
 	assert.NotNil(t, call)
-	in := Params{"jobid": 1}
+	in := rc.Params{"jobid": 1}
 	out, err := call.Fn(context.Background(), in)
 	require.NoError(t, err)
 	require.Empty(t, out)
 
-	in = Params{"jobid": 123123123}
+	in = rc.Params{"jobid": 123123123}
 	_, err = call.Fn(context.Background(), in)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "job not found")
 
-	in = Params{"jobidx": 123123123}
+	in = rc.Params{"jobidx": 123123123}
 	_, err = call.Fn(context.Background(), in)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "Didn't find key")
 
 	time.Sleep(10 * time.Millisecond)
 
-	call = Calls.Get("job/status")
+	call = rc.Calls.Get("job/status")
 	assert.NotNil(t, call)
-	in = Params{"jobid": 1}
+	in = rc.Params{"jobid": 1}
 	out, err = call.Fn(context.Background(), in)
 	require.NoError(t, err)
 	require.NotNil(t, out)
@@ -276,3 +279,94 @@ func TestRcJobStop(t *testing.T) {
 	assert.Equal(t, true, out["finished"])
 	assert.Equal(t, false, out["success"])
 }
+
+func TestRcJobStatusProgress(t *testing.T) {
+	jobID = 0
+	wait := make(chan struct{})
+	_, err := StartJob(func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		defer close(wait)
+		stats := accounting.Stats(ctx)
+		stats.Transferring("potato")
+		stats.Bytes(42)
+		stats.DoneTransferring("potato", true)
+		return nil, nil
+	}, rc.Params{})
+	assert.NoError(t, err)
+	<-wait
+
+	call := rc.Calls.Get("job/status")
+	assert.NotNil(t, call)
+	out, err := call.Fn(context.Background(), rc.Params{"jobid": 1})
+	require.NoError(t, err)
+	assert.Equal(t, "job/1", out["group"])
+	progress, ok := out["progress"].(rc.Params)
+	require.True(t, ok)
+	assert.Equal(t, int64(42), progress["bytes"])
+	assert.Equal(t, int64(1), progress["transfers"])
+	transferred, ok := out["transferred"].([]accounting.Transferred)
+	require.True(t, ok)
+	require.Equal(t, 1, len(transferred))
+	assert.Equal(t, "potato", transferred[0].Name)
+	assert.Equal(t, true, transferred[0].Success)
+
+	// the global stats should be untouched
+	assert.Equal(t, int64(0), accounting.GlobalStats().GetBytes())
+}
+
+func TestWithConfig(t *testing.T) {
+	ctx, err := WithConfig(context.Background(), rc.Params{})
+	require.NoError(t, err)
+	assert.Equal(t, fs.Config, fs.GetConfig(ctx))
+	assert.Equal(t, filter.Active, filter.GetConfig(ctx))
+
+	ctx, err = WithConfig(context.Background(), rc.Params{
+		"_config": rc.Params{
+			"Transfers": 17,
+			"BwLimit":   "1M",
+		},
+		"_filter": `{"IncludeRule": ["*.txt"], "MaxSize": "1k"}`,
+	})
+	require.NoError(t, err)
+	ci := fs.GetConfig(ctx)
+	assert.Equal(t, 17, ci.Transfers)
+	assert.Equal(t, fs.Config.Checkers, ci.Checkers)
+	assert.Equal(t, fs.SizeSuffix(1024*1024), ci.BwLimit.LimitAt(time.Now()).Bandwidth)
+	assert.NotEqual(t, 17, fs.Config.Transfers)
+	fi := filter.GetConfig(ctx)
+	assert.True(t, fi.Include("file.txt", 0, time.Now()))
+	assert.False(t, fi.Include("file.jpg", 0, time.Now()))
+	assert.Equal(t, fs.SizeSuffix(1024), fi.Opt.MaxSize)
+	assert.True(t, filter.Active.InActive())
+
+	_, err = WithConfig(context.Background(), rc.Params{"_config": rc.Params{"Transfers": "potato"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "_config")
+
+	// A timetable would never change during the job
+	_, err = WithConfig(context.Background(), rc.Params{"_config": rc.Params{"BwLimit": "08:00,512k 12:00,off"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timetable")
+	_, err = WithConfig(context.Background(), rc.Params{"_config": rc.Params{"BwLimit": "off"}})
+	require.NoError(t, err)
+
+	_, err = WithConfig(context.Background(), rc.Params{"_filter": rc.Params{"IncludeRule": []string{"[bad"}}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "_filter")
+}
+
+func TestStartJobWithConfig(t *testing.T) {
+	jobID = 0
+	wait := make(chan struct{})
+	var transfers int
+	_, err := StartJob(func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		defer close(wait)
+		transfers = fs.GetConfig(ctx).Transfers
+		return nil, nil
+	}, rc.Params{"_config": rc.Params{"Transfers": 23}})
+	require.NoError(t, err)
+	<-wait
+	assert.Equal(t, 23, transfers)
+
+	_, err = StartJob(noopFn, rc.Params{"_config": "not JSON"})
+	require.Error(t, err)
+}
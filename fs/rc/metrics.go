@@ -0,0 +1,92 @@
+// Metrics for the /metrics endpoint of the rc server
+
+package rc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric is a single sample to be exported in the Prometheus text
+// format
+type Metric struct {
+	Name   string            // name of the metric, eg "rclone_bytes_transferred_total"
+	Help   string            // one line description of the metric
+	Type   string            // "counter" or "gauge"
+	Labels map[string]string // labels for this sample, may be nil
+	Value  float64           // value of the sample
+}
+
+// MetricsFunc returns the current values of some metrics
+type MetricsFunc func() []Metric
+
+// metricsFuncs holds the registered MetricsFunc
+var metricsFuncs struct {
+	mu  sync.Mutex
+	fns []MetricsFunc
+}
+
+// AddMetrics registers fn to be called to read metrics whenever the
+// /metrics endpoint is read
+func AddMetrics(fn MetricsFunc) {
+	metricsFuncs.mu.Lock()
+	metricsFuncs.fns = append(metricsFuncs.fns, fn)
+	metricsFuncs.mu.Unlock()
+}
+
+// ReadMetrics calls all the registered MetricsFunc and returns the
+// metrics sorted by name
+func ReadMetrics() (metrics []Metric) {
+	metricsFuncs.mu.Lock()
+	fns := append([]MetricsFunc(nil), metricsFuncs.fns...)
+	metricsFuncs.mu.Unlock()
+	for _, fn := range fns {
+		metrics = append(metrics, fn()...)
+	}
+	sort.SliceStable(metrics, func(i, j int) bool {
+		return metrics[i].Name < metrics[j].Name
+	})
+	return metrics
+}
+
+// escape helpText and label values as the Prometheus text format
+// needs
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// WriteMetrics writes metrics, which should be sorted by name, to w
+// in the Prometheus text format
+func WriteMetrics(w io.Writer, metrics []Metric) error {
+	out := bufio.NewWriter(w)
+	for i, m := range metrics {
+		if i == 0 || metrics[i-1].Name != m.Name {
+			_, _ = fmt.Fprintf(out, "# HELP %s %s\n", m.Name, helpEscaper.Replace(m.Help))
+			_, _ = fmt.Fprintf(out, "# TYPE %s %s\n", m.Name, m.Type)
+		}
+		_, _ = out.WriteString(m.Name)
+		if len(m.Labels) > 0 {
+			keys := make([]string, 0, len(m.Labels))
+			for key := range m.Labels {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			_ = out.WriteByte('{')
+			for j, key := range keys {
+				if j > 0 {
+					_ = out.WriteByte(',')
+				}
+				_, _ = fmt.Fprintf(out, `%s="%s"`, key, labelEscaper.Replace(m.Labels[key]))
+			}
+			_ = out.WriteByte('}')
+		}
+		_, _ = fmt.Fprintf(out, " %s\n", strconv.FormatFloat(m.Value, 'g', -1, 64))
+	}
+	return out.Flush()
+}
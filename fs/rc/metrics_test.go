@@ -0,0 +1,61 @@
+package rc
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteMetrics(&buf, []Metric{{
+		Name:  "rclone_test_total",
+		Help:  "Test\ncounter with \\.",
+		Type:  "counter",
+		Value: 17,
+	}, {
+		Name:   "rclone_test_bytes",
+		Help:   "Test gauge.",
+		Type:   "gauge",
+		Labels: map[string]string{"remote": `a"b\c`, "backend": "s3"},
+		Value:  1.5,
+	}, {
+		Name:   "rclone_test_bytes",
+		Help:   "Test gauge.",
+		Type:   "gauge",
+		Labels: map[string]string{"remote": "d"},
+		Value:  math.Inf(1),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, `# HELP rclone_test_total Test\ncounter with \\.
+# TYPE rclone_test_total counter
+rclone_test_total 17
+# HELP rclone_test_bytes Test gauge.
+# TYPE rclone_test_bytes gauge
+rclone_test_bytes{backend="s3",remote="a\"b\\c"} 1.5
+rclone_test_bytes{remote="d"} +Inf
+`, buf.String())
+}
+
+func TestReadMetrics(t *testing.T) {
+	AddMetrics(func() []Metric {
+		return []Metric{{Name: "rclone_test_z"}, {Name: "rclone_test_a", Value: 1}}
+	})
+	AddMetrics(func() []Metric {
+		return []Metric{{Name: "rclone_test_a", Value: 2}}
+	})
+	var got []Metric
+	for _, m := range ReadMetrics() {
+		if m.Name == "rclone_test_a" || m.Name == "rclone_test_z" {
+			got = append(got, m)
+		}
+	}
+	assert.Equal(t, []Metric{
+		{Name: "rclone_test_a", Value: 1},
+		{Name: "rclone_test_a", Value: 2},
+		{Name: "rclone_test_z"},
+	}, got)
+}
@@ -189,6 +189,9 @@ func (p Params) GetBool(key string) (bool, error) {
 // GetStruct gets a struct from key from the input into the struct
 // pointed to by out. out must be a pointer type.
 //
+// If the value is a string, eg from a URL parameter, then it is
+// decoded as JSON.
+//
 // If the parameter isn't found then error will be of type
 // ErrParamNotFound and out will be unchanged.
 func (p Params) GetStruct(key string, out interface{}) error {
@@ -196,7 +199,11 @@ func (p Params) GetStruct(key string, out interface{}) error {
 	if err != nil {
 		return err
 	}
-	err = Reshape(out, value)
+	if s, ok := value.(string); ok {
+		err = json.Unmarshal([]byte(s), out)
+	} else {
+		err = Reshape(out, value)
+	}
 	if err != nil {
 		return ErrParamInvalid{errors.Wrapf(err, "key %q", key)}
 	}
@@ -248,4 +248,10 @@ func TestParamsGetStruct(t *testing.T) {
 	assert.Equal(t, "one", out.String)
 	assert.Equal(t, 4.2, out.Float)
 	assert.Equal(t, true, IsErrParamInvalid(e3), e3.Error())
+
+	in["struct"] = `{"String": "two", "Float": 4.3}`
+	e4 := in.GetStruct("struct", &out)
+	assert.NoError(t, e4)
+	assert.Equal(t, "two", out.String)
+	assert.Equal(t, 4.3, out.Float)
 }
@@ -22,6 +22,7 @@ type Options struct {
 	Serve       bool   // set to serve files from remotes
 	Files       string // set to enable serving files locally
 	NoAuth      bool   // set to disable auth checks on AuthRequired methods
+	Metrics     bool   // set to serve Prometheus metrics on /metrics
 }
 
 // DefaultOpt is the default values used for Options
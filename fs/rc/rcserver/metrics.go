@@ -0,0 +1,61 @@
+// Serve the /metrics endpoint in the Prometheus text format
+
+package rcserver
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/rc"
+	"github.com/ncw/rclone/lib/pacer"
+)
+
+func init() {
+	rc.AddMetrics(pacerMetrics)
+}
+
+// pacerMetrics returns the API calls made through the pacers of each
+// backend as metrics
+func pacerMetrics() (out []rc.Metric) {
+	metrics := pacer.GetMetrics()
+	backends := make([]string, 0, len(metrics))
+	for backend := range metrics {
+		backends = append(backends, backend)
+	}
+	sort.Strings(backends)
+	for _, backend := range backends {
+		m := metrics[backend]
+		labels := map[string]string{"backend": backend}
+		out = append(out, rc.Metric{
+			Name:   "rclone_backend_api_calls_total",
+			Help:   "Total number of API calls made by the backend.",
+			Type:   "counter",
+			Labels: labels,
+			Value:  float64(m.Calls),
+		}, rc.Metric{
+			Name:   "rclone_backend_api_retries_total",
+			Help:   "Total number of API calls made by the backend which needed retrying.",
+			Type:   "counter",
+			Labels: labels,
+			Value:  float64(m.Retries),
+		}, rc.Metric{
+			Name:   "rclone_backend_pacer_sleep_seconds_total",
+			Help:   "Total time the backend spent waiting for the pacer.",
+			Type:   "counter",
+			Labels: labels,
+			Value:  m.SleepTime.Seconds(),
+		})
+	}
+	return out
+}
+
+// serveMetrics writes all the metrics in the Prometheus text format
+func (s *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	err := rc.WriteMetrics(w, rc.ReadMetrics())
+	if err != nil {
+		// can't return the error at this point
+		fs.Errorf(nil, "rc: failed to write metrics: %v", err)
+	}
+}
@@ -2,6 +2,7 @@
 package rcserver
 
 import (
+	"context"
 	"encoding/json"
 	"mime"
 	"net/http"
@@ -17,6 +18,7 @@ import (
 	"github.com/ncw/rclone/fs/config"
 	"github.com/ncw/rclone/fs/list"
 	"github.com/ncw/rclone/fs/rc"
+	"github.com/ncw/rclone/fs/rc/jobs"
 	"github.com/pkg/errors"
 	"github.com/skratchdot/open-golang/open"
 )
@@ -185,9 +187,15 @@ func (s *Server) handlePost(w http.ResponseWriter, r *http.Request, path string)
 	fs.Debugf(nil, "rc: %q: with parameters %+v", path, in)
 	var out rc.Params
 	if isAsync {
-		out, err = rc.StartJob(call.Fn, in)
+		out, err = jobs.StartJob(call.Fn, in)
 	} else {
-		out, err = call.Fn(r.Context(), in)
+		var ctx context.Context
+		ctx, err = jobs.WithConfig(r.Context(), in)
+		if err != nil {
+			writeError(path, in, w, err, http.StatusBadRequest)
+			return
+		}
+		out, err = call.Fn(ctx, in)
 	}
 	if err != nil {
 		writeError(path, in, w, err, http.StatusInternalServerError)
@@ -260,6 +268,10 @@ func (s *Server) handleGet(w http.ResponseWriter, r *http.Request, path string)
 	// Look to see if this has an fs in the path
 	match := fsMatch.FindStringSubmatch(path)
 	switch {
+	case path == "metrics" && s.opt.Metrics:
+		// Serve the Prometheus metrics
+		s.serveMetrics(w, r)
+		return
 	case match != nil && s.opt.Serve:
 		// Serve /[fs]/remote files
 		s.serveRemote(w, r, match[2], match[1])
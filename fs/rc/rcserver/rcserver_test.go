@@ -637,3 +637,63 @@ func TestRCAsync(t *testing.T) {
 	opt.Files = ""
 	testServer(t, tests, &opt)
 }
+
+func TestRCConfig(t *testing.T) {
+	tests := []testRun{{
+		Name:        "ok",
+		URL:         "rc/noop",
+		Method:      "POST",
+		ContentType: "application/json",
+		Body:        `{ "_config":{"Transfers":17}, "_filter":{"IncludeRule":["*.txt"]} }`,
+		Status:      http.StatusOK,
+		Expected: `{
+	"_config": {
+		"Transfers": 17
+	},
+	"_filter": {
+		"IncludeRule": [
+			"*.txt"
+		]
+	}
+}
+`,
+	}, {
+		Name:        "bad",
+		URL:         "rc/noop",
+		Method:      "POST",
+		ContentType: "application/json",
+		Body:        `{ "_filter":{"IncludeRule":["[bad"]} }`,
+		Status:      http.StatusBadRequest,
+		Contains:    regexp.MustCompile(`failed to read _filter`),
+	}}
+	opt := newTestOpt()
+	opt.Serve = true
+	opt.Files = ""
+	testServer(t, tests, &opt)
+}
+
+func TestMetrics(t *testing.T) {
+	tests := []testRun{{
+		Name:     "metrics",
+		URL:      "metrics",
+		Status:   http.StatusOK,
+		Contains: regexp.MustCompile(`(?m)^# TYPE rclone_bytes_transferred_total counter\nrclone_bytes_transferred_total \d+$`),
+		Headers: map[string]string{
+			"Content-Type": "text/plain; version=0.0.4; charset=utf-8",
+		},
+	}}
+	opt := newTestOpt()
+	opt.Metrics = true
+	testServer(t, tests, &opt)
+
+	tests = []testRun{{
+		Name:     "disabled",
+		URL:      "metrics",
+		Status:   http.StatusNotFound,
+		Expected: "Not Found\n",
+	}}
+	opt = newTestOpt()
+	opt.Serve = false
+	opt.Files = ""
+	testServer(t, tests, &opt)
+}
@@ -2,6 +2,7 @@ package fs
 
 // SizeSuffix is parsed by flag with k/M/G suffixes
 import (
+	"encoding/json"
 	"fmt"
 	"math"
 	"sort"
@@ -132,6 +133,23 @@ func (x *SizeSuffix) Scan(s fmt.ScanState, ch rune) error {
 	return x.Set(string(token))
 }
 
+// UnmarshalJSON makes sure the value can be parsed as a string with
+// a suffix, eg "10M", or as a number of bytes in JSON
+func (x *SizeSuffix) UnmarshalJSON(in []byte) error {
+	var s string
+	err := json.Unmarshal(in, &s)
+	if err == nil {
+		return x.Set(s)
+	}
+	var i int64
+	err = json.Unmarshal(in, &i)
+	if err != nil {
+		return err
+	}
+	*x = SizeSuffix(i)
+	return nil
+}
+
 // SizeSuffixList is a slice SizeSuffix values
 type SizeSuffixList []SizeSuffix
 
@@ -1,6 +1,7 @@
 package fs
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 
@@ -102,3 +103,30 @@ func TestSizeSuffixScan(t *testing.T) {
 	assert.Equal(t, 1, n)
 	assert.Equal(t, SizeSuffix(17<<20), v)
 }
+
+func TestSizeSuffixUnmarshalJSON(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want int64
+		err  bool
+	}{
+		{`"0"`, 0, false},
+		{`"102B"`, 102, false},
+		{`"1M"`, 1024 * 1024, false},
+		{`"off"`, -1, false},
+		{`"notanumber"`, 0, true},
+		{`0`, 0, false},
+		{`102`, 102, false},
+		{`-1`, -1, false},
+		{`true`, 0, true},
+	} {
+		var ss SizeSuffix
+		err := json.Unmarshal([]byte(test.in), &ss)
+		if test.err {
+			require.Error(t, err, test.in)
+		} else {
+			require.NoError(t, err, test.in)
+		}
+		assert.Equal(t, test.want, int64(ss), test.in)
+	}
+}
@@ -2,16 +2,25 @@ package sync
 
 import (
 	"context"
+	"time"
 
+	"github.com/ncw/rclone/fs"
 	"github.com/ncw/rclone/fs/rc"
+	"github.com/pkg/errors"
 )
 
 func init() {
 	for _, name := range []string{"sync", "copy", "move"} {
 		name := name
-		moveHelp := ""
+		extraHelp := ""
 		if name == "move" {
-			moveHelp = "- deleteEmptySrcDirs - delete empty src directories if set\n"
+			extraHelp = "- deleteEmptySrcDirs - delete empty src directories if set\n"
+		}
+		if name == "sync" {
+			extraHelp = `- watch - keep syncing changes to the source if set - use with _async and stop with job/stop
+- watchDelay - time to wait for more changes before syncing them, eg "5s"
+- watchPollInterval - time between polls for changes, eg "1m"
+`
 		}
 		rc.Add(rc.Call{
 			Path:         "sync/" + name,
@@ -24,7 +33,7 @@ func init() {
 
 - srcFs - a remote name string eg "drive:src" for the source
 - dstFs - a remote name string eg "drive:dst" for the destination
-` + moveHelp + `
+` + extraHelp + `
 
 See the [` + name + ` command](/commands/rclone_` + name + `/) command for more information on the above.`,
 		})
@@ -47,7 +56,24 @@ func rcSyncCopyMove(ctx context.Context, in rc.Params, name string) (out rc.Para
 	}
 	switch name {
 	case "sync":
-		return nil, Sync(ctx, dstFs, srcFs, createEmptySrcDirs)
+		watch, err := in.GetBool("watch")
+		if rc.NotErrParamNotFound(err) {
+			return nil, err
+		}
+		if !watch {
+			return nil, Sync(ctx, dstFs, srcFs, createEmptySrcDirs)
+		}
+		opt := DefaultWatchOpt
+		opt.CreateEmptySrcDirs = createEmptySrcDirs
+		opt.Delay, err = getDuration(in, "watchDelay", opt.Delay)
+		if err != nil {
+			return nil, err
+		}
+		opt.PollInterval, err = getDuration(in, "watchPollInterval", opt.PollInterval)
+		if err != nil {
+			return nil, err
+		}
+		return nil, Watch(ctx, dstFs, srcFs, opt)
 	case "copy":
 		return nil, CopyDir(ctx, dstFs, srcFs, createEmptySrcDirs)
 	case "move":
@@ -59,3 +85,19 @@ func rcSyncCopyMove(ctx context.Context, in rc.Params, name string) (out rc.Para
 	}
 	panic("unknown rcSyncCopyMove type")
 }
+
+// getDuration reads the duration key from in, returning def if it
+// isn't present
+func getDuration(in rc.Params, key string, def time.Duration) (time.Duration, error) {
+	value, err := in.GetString(key)
+	if rc.IsErrParamNotFound(err) {
+		return def, nil
+	} else if err != nil {
+		return 0, err
+	}
+	d, err := fs.ParseDuration(value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "couldn't parse key %q (%v) as duration", key, value)
+	}
+	return d, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/ncw/rclone/fs/accounting"
 	"github.com/ncw/rclone/fs/cache"
 	"github.com/ncw/rclone/fs/rc"
 	"github.com/ncw/rclone/fstest"
@@ -97,3 +98,41 @@ func TestRcSync(t *testing.T) {
 	fstest.CheckItems(t, r.Flocal, file1, file2)
 	fstest.CheckItems(t, r.Fremote, file1, file2)
 }
+
+// sync/sync: watch a directory from source remote to destination remote
+func TestRcSyncWatch(t *testing.T) {
+	r, call := rcNewRun(t, "sync/sync")
+	defer r.Finalise()
+	r.Mkdir(context.Background(), r.Fremote)
+
+	file1 := r.WriteFile("file1", "file1 contents", t1)
+
+	accounting.GlobalStats().ResetCounters()
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := call.Fn(ctx, rc.Params{
+			"srcFs":             r.LocalName,
+			"dstFs":             r.FremoteName,
+			"watch":             true,
+			"watchDelay":        "100ms",
+			"watchPollInterval": "100ms",
+		})
+		errs <- err
+	}()
+	waitForItems(t, r.Fremote, file1)
+
+	file2 := r.WriteFile("subdir/file2", "file2 contents", t2)
+	waitForItems(t, r.Fremote, file1, file2)
+
+	cancel()
+	require.NoError(t, <-errs)
+
+	_, err := call.Fn(context.Background(), rc.Params{
+		"srcFs":      r.LocalName,
+		"dstFs":      r.FremoteName,
+		"watch":      true,
+		"watchDelay": "potato",
+	})
+	assert.Error(t, err)
+}
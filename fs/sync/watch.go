@@ -0,0 +1,372 @@
+// Continuously sync the changes made to the source
+
+package sync
+
+import (
+	"context"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/filter"
+	"github.com/ncw/rclone/fs/fserrors"
+	"github.com/ncw/rclone/fs/operations"
+	"github.com/ncw/rclone/fs/walk"
+	"github.com/pkg/errors"
+)
+
+// maxWatchTries is the number of times syncing a change is tried
+// before giving up on it
+const maxWatchTries = 3
+
+// WatchOpt holds the options for Watch
+type WatchOpt struct {
+	CreateEmptySrcDirs bool          // create empty source dirs on the destination
+	Delay              time.Duration // time to wait for more changes before syncing them
+	PollInterval       time.Duration // time between polls for changes
+}
+
+// DefaultWatchOpt is the default values for WatchOpt
+var DefaultWatchOpt = WatchOpt{
+	Delay:        5 * time.Second,
+	PollInterval: time.Minute,
+}
+
+// watchChange is a change to the source which hasn't been synced yet
+type watchChange struct {
+	entryType fs.EntryType
+	tries     int // number of failed attempts to sync it
+}
+
+// watcher syncs the changes notified by the source
+type watcher struct {
+	ctx       context.Context
+	ci        *fs.ConfigInfo // config in use
+	fi        *filter.Filter // filter in use
+	fdst      fs.Fs
+	fsrc      fs.Fs
+	opt       WatchOpt
+	backupDir fs.Fs // place to store overwrites/deletes
+	mu        sync.Mutex
+	changes   map[string]watchChange // changes to sync by source remote
+	changed   chan struct{}          // signalled when a change is added
+}
+
+// Watch syncs fsrc into fdst then keeps syncing the changes made to
+// fsrc until ctx is cancelled.
+//
+// If fsrc supports ChangeNotify then only the changed paths are
+// synced. The changes are gathered for opt.Delay after the first one
+// so bursts of changes are synced together. If fsrc doesn't support
+// ChangeNotify then the whole of fsrc is synced every
+// opt.PollInterval instead.
+//
+// Errors syncing the changes are counted and logged rather than
+// returned so watching carries on. The errors are reset before each
+// sync of the changes, as they would be between retries, otherwise
+// one error would stop files being deleted from then on.
+func Watch(ctx context.Context, fdst, fsrc fs.Fs, opt WatchOpt) (err error) {
+	ci := fs.GetConfig(ctx)
+	if opt.PollInterval <= 0 {
+		return errors.New("poll interval must be greater than 0")
+	}
+	w := &watcher{
+		ctx:     ctx,
+		ci:      ci,
+		fi:      filter.GetConfig(ctx),
+		fdst:    fdst,
+		fsrc:    fsrc,
+		opt:     opt,
+		changes: make(map[string]watchChange),
+		changed: make(chan struct{}, 1),
+	}
+	if ci.BackupDir != "" || ci.Suffix != "" {
+		w.backupDir, err = operations.BackupDir(ctx, fdst, fsrc, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	// Start watching before the sync so no changes are missed
+	var tickerC <-chan time.Time
+	if doChangeNotify := fsrc.Features().ChangeNotify; doChangeNotify != nil {
+		pollInterval := make(chan time.Duration, 1)
+		pollInterval <- opt.PollInterval
+		doChangeNotify(ctx, w.notify, pollInterval)
+		defer close(pollInterval)
+	} else {
+		fs.Logf(fsrc, "Changes aren't notified - syncing everything every %v instead", opt.PollInterval)
+		ticker := time.NewTicker(opt.PollInterval)
+		defer ticker.Stop()
+		tickerC = ticker.C
+	}
+
+	err = Sync(ctx, fdst, fsrc, opt.CreateEmptySrcDirs)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fs.Infof(fdst, "Synced - watching for changes")
+
+	var (
+		timer  *time.Timer
+		timerC <-chan time.Time
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil
+		case <-w.changed:
+			if timerC == nil {
+				timer = time.NewTimer(opt.Delay)
+				timerC = timer.C
+			}
+		case <-timerC:
+			timerC = nil
+			accounting.Stats(w.ctx).ResetErrors()
+			pending, err := w.syncChanges()
+			if err != nil {
+				return err
+			}
+			if pending {
+				timer = time.NewTimer(opt.Delay)
+				timerC = timer.C
+			}
+		case <-tickerC:
+			accounting.Stats(w.ctx).ResetErrors()
+			err = Sync(ctx, fdst, fsrc, opt.CreateEmptySrcDirs)
+			if err != nil && ctx.Err() == nil {
+				fs.CountError(w.ctx, err)
+				fs.Errorf(fdst, "Failed to sync: %v", err)
+			}
+		}
+	}
+}
+
+// notify records a change notified by the source
+func (w *watcher) notify(remote string, entryType fs.EntryType) {
+	w.mu.Lock()
+	if old, ok := w.changes[remote]; !ok || old.entryType != fs.EntryDirectory {
+		w.changes[remote] = watchChange{entryType: entryType, tries: old.tries}
+	}
+	w.mu.Unlock()
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+// syncChanges syncs the changes recorded so far
+//
+// Directories are synced first, one at a time, skipping any changes
+// inside them. Then the objects are synced in parallel.
+//
+// Changes which fail are put back to be tried again. It returns true
+// if there are changes still waiting to be synced, or an error if
+// there was a fatal error.
+func (w *watcher) syncChanges() (pending bool, err error) {
+	w.mu.Lock()
+	changes := w.changes
+	w.changes = make(map[string]watchChange)
+	w.mu.Unlock()
+
+	var dirs, objects []string
+	for remote, change := range changes {
+		if change.entryType == fs.EntryDirectory {
+			dirs = append(dirs, remote)
+		}
+	}
+	for remote, change := range changes {
+		if change.entryType != fs.EntryDirectory && !inDirs(changes, remote) {
+			objects = append(objects, remote)
+		}
+	}
+	sort.Strings(dirs)
+	sort.Strings(objects)
+	fs.Infof(w.fdst, "Syncing %d changed directories and %d changed files", len(dirs), len(objects))
+
+	var (
+		mu       sync.Mutex
+		failed   = make(map[string]watchChange)
+		fatalErr error
+	)
+	fail := func(remote string, err error) {
+		change := changes[remote]
+		change.tries++
+		fs.CountError(w.ctx, err)
+		mu.Lock()
+		defer mu.Unlock()
+		if fserrors.IsFatalError(err) {
+			fatalErr = err
+			return
+		}
+		if change.tries >= maxWatchTries {
+			fs.Errorf(remote, "Giving up syncing change after %d tries: %v", change.tries, err)
+			return
+		}
+		fs.Errorf(remote, "Failed to sync change - will retry: %v", err)
+		failed[remote] = change
+	}
+
+	for _, dir := range dirs {
+		if inDirs(changes, dir) {
+			continue
+		}
+		if w.ctx.Err() != nil || fatalErr != nil {
+			break
+		}
+		if err := w.syncDir(dir); err != nil {
+			fail(dir, err)
+		}
+	}
+
+	in := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < w.ci.Transfers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for remote := range in {
+				if err := w.syncObject(remote); err != nil {
+					fail(remote, err)
+				}
+			}
+		}()
+	}
+	for _, remote := range objects {
+		mu.Lock()
+		stop := fatalErr != nil
+		mu.Unlock()
+		if stop || w.ctx.Err() != nil {
+			break
+		}
+		in <- remote
+	}
+	close(in)
+	wg.Wait()
+	if fatalErr != nil {
+		return false, fatalErr
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for remote, change := range failed {
+		if _, ok := w.changes[remote]; !ok {
+			w.changes[remote] = change
+		}
+	}
+	return len(w.changes) > 0, nil
+}
+
+// inDirs returns true if remote is inside one of the directories in
+// changes
+func inDirs(changes map[string]watchChange, remote string) bool {
+	for remote != "" {
+		remote = path.Dir(remote)
+		if remote == "." || remote == "/" {
+			remote = ""
+		}
+		if change, ok := changes[remote]; ok && change.entryType == fs.EntryDirectory {
+			return true
+		}
+	}
+	return false
+}
+
+// syncObject makes the object remote in the destination match the
+// source, copying or deleting it as necessary
+func (w *watcher) syncObject(remote string) error {
+	src, err := w.fsrc.NewObject(w.ctx, remote)
+	if err == fs.ErrorObjectNotFound || err == fs.ErrorNotAFile {
+		src = nil
+	} else if err != nil {
+		return err
+	}
+	dst, err := w.fdst.NewObject(w.ctx, remote)
+	if err == fs.ErrorObjectNotFound || err == fs.ErrorNotAFile {
+		dst = nil
+	} else if err != nil {
+		return err
+	}
+
+	// Delete the destination if the source has gone or is excluded
+	if src == nil || !src.Storable() || !w.fi.IncludeObject(w.ctx, src) {
+		if dst == nil {
+			return nil
+		}
+		if !w.fi.Opt.DeleteExcluded && (src != nil || !w.fi.IncludeObject(w.ctx, dst)) {
+			return nil
+		}
+		return operations.DeleteFileWithBackupDir(w.ctx, dst, w.backupDir)
+	}
+
+	accounting.Stats(w.ctx).Checking(remote)
+	needTransfer := operations.NeedTransfer(w.ctx, dst, src)
+	accounting.Stats(w.ctx).DoneChecking(remote)
+	if !needTransfer {
+		return nil
+	}
+	if w.ci.Immutable && dst != nil {
+		fs.Errorf(dst, "Source and destination exist but do not match: immutable file modified")
+		return fs.ErrorImmutableModified
+	}
+	if dst != nil && w.backupDir != nil {
+		err = operations.MoveBackupDir(w.ctx, w.backupDir, dst)
+		if err != nil {
+			return err
+		}
+		dst = nil
+	}
+	accounting.Stats(w.ctx).Transferring(remote)
+	_, err = operations.Copy(w.ctx, w.fdst, dst, remote, src)
+	accounting.Stats(w.ctx).DoneTransferring(remote, err == nil)
+	return err
+}
+
+// syncDir syncs the directory dir and everything in it, removing it
+// from the destination if it has gone from the source
+func (w *watcher) syncDir(dir string) error {
+	include := true
+	if dir != "" {
+		var err error
+		include, err = w.fi.IncludeDirectory(w.ctx, w.fsrc)(dir)
+		if err != nil {
+			return err
+		}
+	}
+	if include {
+		_, err := w.fsrc.List(w.ctx, dir)
+		if err == nil {
+			return runSyncCopyMove(w.ctx, w.fdst, w.fsrc, dir, w.ci.DeleteMode, false, false, w.opt.CreateEmptySrcDirs)
+		}
+		if err != fs.ErrorDirNotFound || dir == "" {
+			return err
+		}
+	} else if !w.fi.Opt.DeleteExcluded {
+		return nil
+	}
+	return w.removeDir(dir)
+}
+
+// removeDir removes the directory dir from the destination
+func (w *watcher) removeDir(dir string) error {
+	err := walk.ListR(w.ctx, w.fdst, dir, w.fi.Opt.DeleteExcluded, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		return entries.ForObjectError(func(o fs.Object) error {
+			return operations.DeleteFileWithBackupDir(w.ctx, o, w.backupDir)
+		})
+	})
+	if err == fs.ErrorDirNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return operations.Rmdirs(w.ctx, w.fdst, dir, false)
+}
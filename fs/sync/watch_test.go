@@ -0,0 +1,128 @@
+// Test watch
+
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/walk"
+	"github.com/ncw/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startWatch runs Watch in the background returning a function to
+// stop it
+func startWatch(t *testing.T, fdst, fsrc fs.Fs) (stop func()) {
+	accounting.GlobalStats().ResetCounters()
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- Watch(ctx, fdst, fsrc, WatchOpt{
+			Delay:        100 * time.Millisecond,
+			PollInterval: 100 * time.Millisecond,
+		})
+	}()
+	return func() {
+		cancel()
+		assert.NoError(t, <-errs)
+	}
+}
+
+// waitForItems waits for the paths and sizes of the objects in f to
+// match the items then checks them
+func waitForItems(t *testing.T, f fs.Fs, items ...fstest.Item) {
+	var want []string
+	for _, item := range items {
+		want = append(want, fmt.Sprintf("%s %d", item.Path, item.Size))
+	}
+	sort.Strings(want)
+	for tries := 0; tries < 50; tries++ {
+		objs, _, err := walk.GetAll(context.Background(), f, "", true, -1)
+		if err != nil && err != fs.ErrorDirNotFound {
+			require.NoError(t, err)
+		}
+		var got []string
+		for _, o := range objs {
+			got = append(got, fmt.Sprintf("%s %d", o.Remote(), o.Size()))
+		}
+		sort.Strings(got)
+		if strings.Join(got, "\n") == strings.Join(want, "\n") {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	fstest.CheckItems(t, f, items...)
+}
+
+func TestWatch(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	file1 := r.WriteFile("file1", "file1 contents", t1)
+	file2 := r.WriteObject(context.Background(), "old/file2", "file2 contents", t2)
+	fstest.CheckItems(t, r.Fremote, file2)
+
+	stop := startWatch(t, r.Fremote, r.Flocal)
+	defer stop()
+	waitForItems(t, r.Fremote, file1)
+
+	// New files in new directories are copied
+	file3 := r.WriteFile("sub dir/file3", "file3 contents", t2)
+	waitForItems(t, r.Fremote, file1, file3)
+
+	// Changed files are copied
+	file1 = r.WriteFile("file1", "file1 changed contents", t3)
+	waitForItems(t, r.Fremote, file1, file3)
+
+	// Deleted files are deleted
+	require.NoError(t, os.Remove(path.Join(r.LocalName, "file1")))
+	waitForItems(t, r.Fremote, file3)
+
+	// Deleted directories are deleted
+	require.NoError(t, os.RemoveAll(path.Join(r.LocalName, "sub dir")))
+	waitForItems(t, r.Fremote)
+}
+
+func TestWatchWithoutChangeNotify(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	file1 := r.WriteFile("file1", "file1 contents", t1)
+
+	// Make a source which doesn't notify changes
+	fsrc, err := fs.NewFs(r.LocalName)
+	require.NoError(t, err)
+	fsrc.Features().ChangeNotify = nil
+
+	stop := startWatch(t, r.Fremote, fsrc)
+	defer stop()
+	waitForItems(t, r.Fremote, file1)
+
+	file2 := r.WriteFile("sub dir/file2", "file2 contents", t2)
+	require.NoError(t, os.Remove(path.Join(r.LocalName, "file1")))
+	waitForItems(t, r.Fremote, file2)
+}
+
+func TestInDirs(t *testing.T) {
+	changes := map[string]watchChange{
+		"dir":      {entryType: fs.EntryDirectory},
+		"dir/file": {entryType: fs.EntryObject},
+		"file":     {entryType: fs.EntryObject},
+	}
+	assert.True(t, inDirs(changes, "dir/file"))
+	assert.True(t, inDirs(changes, "dir/sub/file"))
+	assert.False(t, inDirs(changes, "dir"))
+	assert.False(t, inDirs(changes, "file"))
+	assert.False(t, inDirs(changes, "file/sub"))
+	changes[""] = watchChange{entryType: fs.EntryDirectory}
+	assert.True(t, inDirs(changes, "dir"))
+	assert.False(t, inDirs(changes, ""))
+}
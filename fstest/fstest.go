@@ -274,7 +274,7 @@ func CheckListingWithPrecision(t *testing.T, f fs.Fs, items []Item, expectedDirs
 		expectedDirs = filterEmptyDirs(t, items, expectedDirs)
 	}
 	is := NewItems(items)
-	oldErrors := accounting.Stats.GetErrors()
+	oldErrors := accounting.GlobalStats().GetErrors()
 	var objs []fs.Object
 	var dirs []fs.Directory
 	var err error
@@ -317,8 +317,8 @@ func CheckListingWithPrecision(t *testing.T, f fs.Fs, items []Item, expectedDirs
 	}
 	is.Done(t)
 	// Don't notice an error when listing an empty directory
-	if len(items) == 0 && oldErrors == 0 && accounting.Stats.GetErrors() == 1 {
-		accounting.Stats.ResetErrors()
+	if len(items) == 0 && oldErrors == 0 && accounting.GlobalStats().GetErrors() == 1 {
+		accounting.GlobalStats().ResetErrors()
 	}
 	// Check the directories
 	if expectedDirs != nil {
@@ -19,6 +19,7 @@ import (
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -735,6 +736,7 @@ func Run(t *testing.T, opt *Opt) {
 			require.NoError(t, err)
 
 			pollInterval := make(chan time.Duration)
+			var changesMu sync.Mutex
 			dirChanges := map[string]struct{}{}
 			objChanges := map[string]struct{}{}
 			doChangeNotify(context.Background(), func(x string, e fs.EntryType) {
@@ -743,6 +745,8 @@ func Run(t *testing.T, opt *Opt) {
 					fs.Debugf(nil, "Ignoring notify for file1 or file2: %q, %v", x, e)
 					return
 				}
+				changesMu.Lock()
+				defer changesMu.Unlock()
 				if e == fs.EntryDirectory {
 					dirChanges[x] = struct{}{}
 				} else if e == fs.EntryObject {
@@ -787,7 +791,9 @@ func Run(t *testing.T, opt *Opt) {
 			wantObjChanges := []string{"dir/file2", "dir/file4", "dir/file3"}
 			ok := false
 			for tries := 1; tries < 10; tries++ {
+				changesMu.Lock()
 				ok = contains(dirChanges, wantDirChanges) && contains(objChanges, wantObjChanges)
+				changesMu.Unlock()
 				if ok {
 					break
 				}
@@ -795,7 +801,9 @@ func Run(t *testing.T, opt *Opt) {
 				time.Sleep(3 * time.Second)
 			}
 			if !ok {
+				changesMu.Lock()
 				t.Errorf("%+v does not contain %+v or \n%+v does not contain %+v", dirChanges, wantDirChanges, objChanges, wantObjChanges)
+				changesMu.Unlock()
 			}
 
 			// tidy up afterwards
@@ -1251,6 +1259,25 @@ func Run(t *testing.T, opt *Opt) {
 				}
 			})
 
+			// TestObjectMetadata tests the Metadata of the object is correct
+			t.Run("ObjectMetadata", func(t *testing.T) {
+				skipIfNotOk(t)
+				obj := findObject(t, remote, file1.Path)
+				do, ok := obj.(fs.Metadataer)
+				if !ok {
+					t.Skip("Metadata method not supported")
+				}
+				metadata, err := do.Metadata(context.Background())
+				require.NoError(t, err)
+				// Check the mtime is correct if present
+				if v, ok := metadata["mtime"]; ok {
+					modTime, err := time.Parse(time.RFC3339Nano, v)
+					require.NoError(t, err)
+					dt, ok := fstest.CheckTimeEqualWithPrecision(file1.ModTime, modTime, remote.Precision())
+					assert.True(t, ok, "mtime in metadata differs by %v", dt)
+				}
+			})
+
 			// TestObjectSetModTime tests that SetModTime works
 			t.Run("ObjectSetModTime", func(t *testing.T) {
 				skipIfNotOk(t)
@@ -0,0 +1,50 @@
+package pacer
+
+import (
+	"sync"
+	"time"
+)
+
+// Metrics are the totals of the calls made through all the pacers
+// with the same name
+type Metrics struct {
+	Calls     int64         // number of calls made
+	Retries   int64         // number of calls which asked to be retried
+	SleepTime time.Duration // total time spent waiting for the pacer
+}
+
+// metrics holds the Metrics for each pacer name
+var metrics = struct {
+	mu sync.Mutex
+	m  map[string]*Metrics
+}{
+	m: make(map[string]*Metrics),
+}
+
+// addMetrics adds the values passed in to the metrics for name
+func addMetrics(name string, calls, retries int64, sleepTime time.Duration) {
+	if name == "" {
+		return
+	}
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	m := metrics.m[name]
+	if m == nil {
+		m = new(Metrics)
+		metrics.m[name] = m
+	}
+	m.Calls += calls
+	m.Retries += retries
+	m.SleepTime += sleepTime
+}
+
+// GetMetrics returns a copy of the Metrics for each pacer name
+func GetMetrics() map[string]Metrics {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	out := make(map[string]Metrics, len(metrics.m))
+	for name, m := range metrics.m {
+		out[name] = *m
+	}
+	return out
+}
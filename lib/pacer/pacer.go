@@ -37,6 +37,7 @@ type pacerOptions struct {
 	retries        int         // Max number of retries
 	calculator     Calculator  // switchable pacing algorithm - call with mu held
 	invoker        InvokerFunc // wrapper function used to invoke the target function
+	name           string      // name to total the calls under in the metrics
 }
 
 // InvokerFunc is the signature of the wrapper function used to invoke the
@@ -66,6 +67,13 @@ func InvokerOption(invoker InvokerFunc) Option {
 	return func(p *pacerOptions) { p.invoker = invoker }
 }
 
+// NameOption sets the name the calls of the new Pacer are totalled
+// under in the metrics returned by GetMetrics.  Pacers without a name
+// aren't included in the metrics.
+func NameOption(name string) Option {
+	return func(p *pacerOptions) { p.name = name }
+}
+
 // Paced is a function which is called by the Call and CallNoRetry
 // methods.  It should return a boolean, true if it would like to be
 // retried, and an error.  This error may be returned or returned
@@ -156,7 +164,9 @@ func (p *Pacer) beginCall() {
 	// XXX ms later we put another in.  We could do this with a
 	// Ticker more accurately, but then we'd have to work out how
 	// not to run it when it wasn't needed
+	start := time.Now()
 	<-p.pacer
+	addMetrics(p.name, 0, 0, time.Since(start))
 	if p.maxConnections > 0 {
 		<-p.connTokens
 	}
@@ -181,7 +191,9 @@ func (p *Pacer) endCall(retry bool, err error) {
 	p.mu.Lock()
 	if retry {
 		p.state.ConsecutiveRetries++
+		addMetrics(p.name, 1, 1, 0)
 	} else {
+		addMetrics(p.name, 1, 0, 0)
 		p.state.ConsecutiveRetries = 0
 	}
 	p.state.LastError = err
@@ -365,3 +365,24 @@ func TestCallParallel(t *testing.T) {
 	assert.Equal(t, 5, called)
 	wait.Broadcast()
 }
+
+func TestMetrics(t *testing.T) {
+	const name = "TestMetrics"
+	p := New(NameOption(name), RetriesOption(3), CalculatorOption(NewDefault(MinSleep(1*time.Microsecond))))
+	before := GetMetrics()[name]
+	n := 0
+	err := p.Call(func() (bool, error) {
+		n++
+		return n < 2, nil
+	})
+	assert.NoError(t, err)
+	after := GetMetrics()[name]
+	assert.Equal(t, before.Calls+2, after.Calls)
+	assert.Equal(t, before.Retries+1, after.Retries)
+	assert.True(t, after.SleepTime >= before.SleepTime)
+
+	// pacers without a name aren't counted
+	_ = New().Call(func() (bool, error) { return false, nil })
+	_, found := GetMetrics()[""]
+	assert.False(t, found)
+}
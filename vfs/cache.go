@@ -12,12 +12,14 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/djherbis/times"
 	"github.com/ncw/rclone/fs"
 	fscache "github.com/ncw/rclone/fs/cache"
 	"github.com/ncw/rclone/fs/config"
+	"github.com/ncw/rclone/fs/rc"
 	"github.com/pkg/errors"
 )
 
@@ -65,32 +67,55 @@ func (l *CacheMode) Type() string {
 
 // cache opened files
 type cache struct {
-	f      fs.Fs                 // fs for the cache directory
-	opt    *Options              // vfs Options
-	root   string                // root of the cache directory
-	itemMu sync.Mutex            // protects the following variables
-	item   map[string]*cacheItem // files/directories in the cache
-	used   int64                 // total size of files in the cache
+	f         fs.Fs                 // fs for the cache directory
+	fremote   fs.Fs                 // fs being cached
+	opt       *Options              // vfs Options
+	writeBack *writeBack            // uploads modified files to fremote
+	pins      *pinner               // keeps pinned files in the cache
+	root      string                // root of the cache directory
+	metaRoot  string                // root of the cache metadata directory
+	itemMu    sync.Mutex            // protects the following variables
+	item      map[string]*cacheItem // files/directories in the cache
+	used      int64                 // total size of files in the cache
+	hits      int64                 // reads served from the cache - use atomic
+	misses    int64                 // reads which needed data from the remote - use atomic
 }
 
 // cacheItem is stored in the item map
+//
+// The fields below mu are protected by it. If both are needed
+// itemMu must be locked before mu.
 type cacheItem struct {
-	opens  int       // number of times file is open
-	atime  time.Time // last time file was accessed
-	isFile bool      // if this is a file or a directory
-	size   int64     // size of the cached item
+	c       *cache        // cache this is part of
+	name    string        // remote path of the item
+	opens   int           // number of times file is open
+	atime   time.Time     // last time file was accessed
+	isFile  bool          // if this is a file or a directory
+	size    int64         // size of the cached item
+	present int64         // bytes of the file present in the cache or -1 if unknown - use atomic
+	hits    int64         // reads served from the cache - use atomic
+	misses  int64         // reads which needed data from the remote - use atomic
+	mu      sync.Mutex    // protects the following variables
+	loaded  bool          // set if the info has been read from disk
+	hasInfo bool          // set if info is valid
+	info    cacheItemInfo // which parts of the file are present
+	dl      downloader    // for reading data from the remote into the file
 }
 
 // newCacheItem returns an item for the cache
-func newCacheItem(isFile bool) *cacheItem {
-	return &cacheItem{atime: time.Now(), isFile: isFile}
+func newCacheItem(c *cache, name string, isFile bool) *cacheItem {
+	return &cacheItem{
+		c:       c,
+		name:    name,
+		atime:   time.Now(),
+		isFile:  isFile,
+		present: -1,
+	}
 }
 
-// newCache creates a new cache heirachy for f
-//
-// This starts background goroutines which can be cancelled with the
-// context passed in.
-func newCache(ctx context.Context, f fs.Fs, opt *Options) (*cache, error) {
+// cachePath returns the directory in --cache-dir for the kind of data
+// stored about f
+func cachePath(kind string, f fs.Fs) string {
 	fRoot := filepath.FromSlash(f.Root())
 	if runtime.GOOS == "windows" {
 		if strings.HasPrefix(fRoot, `\\?`) {
@@ -98,20 +123,36 @@ func newCache(ctx context.Context, f fs.Fs, opt *Options) (*cache, error) {
 		}
 		fRoot = strings.Replace(fRoot, ":", "", -1)
 	}
-	root := filepath.Join(config.CacheDir, "vfs", f.Name(), fRoot)
+	return filepath.Join(config.CacheDir, kind, f.Name(), fRoot)
+}
+
+// newCache creates a new cache heirachy for f
+//
+// This starts background goroutines which can be cancelled with the
+// context passed in.
+func newCache(ctx context.Context, f fs.Fs, opt *Options) (*cache, error) {
+	root := cachePath("vfs", f)
 	fs.Debugf(nil, "vfs cache root is %q", root)
+	metaRoot := cachePath("vfsMeta", f)
+	fs.Debugf(nil, "vfs metadata cache root is %q", metaRoot)
+	pinPath := filepath.Join(cachePath("vfsPin", f), "pins.json")
 
+	fremote := f
 	f, err := fscache.Get(root)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create cache remote")
 	}
 
 	c := &cache{
-		f:    f,
-		opt:  opt,
-		root: root,
-		item: make(map[string]*cacheItem),
+		f:        f,
+		fremote:  fremote,
+		opt:      opt,
+		root:     root,
+		metaRoot: metaRoot,
+		item:     make(map[string]*cacheItem),
 	}
+	c.writeBack = newWriteBack(ctx, c)
+	c.pins = newPinner(ctx, c, pinPath)
 
 	go c.cleaner(ctx)
 
@@ -168,7 +209,7 @@ func (c *cache) _get(isFile bool, name string) (item *cacheItem, found bool) {
 	item = c.item[name]
 	found = item != nil
 	if !found {
-		item = newCacheItem(isFile)
+		item = newCacheItem(c, name, isFile)
 		c.item[name] = item
 	}
 	return item, found
@@ -255,7 +296,7 @@ func (c *cache) cacheDir(name string) {
 		if item != nil {
 			break
 		}
-		c.item[name] = newCacheItem(false)
+		c.item[name] = newCacheItem(c, name, false)
 		if name == "" {
 			break
 		}
@@ -276,7 +317,7 @@ func (c *cache) _close(isFile bool, name string) {
 		fi, err := os.Stat(osPath)
 		// Update the size on close
 		if err == nil && !fi.IsDir() {
-			item.size = fi.Size()
+			item.size = item.presentSize(fi.Size())
 		}
 		if name == "" {
 			break
@@ -305,6 +346,10 @@ func (c *cache) remove(name string) {
 	} else {
 		fs.Infof(name, "Removed from cache")
 	}
+	err = os.Remove(filepath.Join(c.metaRoot, filepath.FromSlash(name)))
+	if err != nil && !os.IsNotExist(err) {
+		fs.Errorf(name, "Failed to remove metadata from cache: %v", err)
+	}
 }
 
 // removeDir should be called if dir is deleted and returns true if
@@ -326,7 +371,12 @@ func (c *cache) removeDir(dir string) bool {
 
 // cleanUp empties the cache of everything
 func (c *cache) cleanUp() error {
-	return os.RemoveAll(c.root)
+	err := os.RemoveAll(c.root)
+	metaErr := os.RemoveAll(c.metaRoot)
+	if err != nil {
+		return err
+	}
+	return metaErr
 }
 
 // walk walks the cache calling the function
@@ -350,17 +400,55 @@ func (c *cache) walk(fn func(osPath string, fi os.FileInfo, name string) error)
 	})
 }
 
+// loadItem returns the item for name with its info read from disk.
+//
+// If name isn't in the cache a temporary item is returned which isn't
+// added to it, so updateStat can set its atime.
+func (c *cache) loadItem(name string) *cacheItem {
+	c.itemMu.Lock()
+	item := c.item[name]
+	c.itemMu.Unlock()
+	if item == nil {
+		item = newCacheItem(c, name, true)
+	}
+	item.mu.Lock()
+	item._load()
+	item.mu.Unlock()
+	return item
+}
+
+// hit records a read of item served from the cache
+func (c *cache) hit(item *cacheItem) {
+	atomic.AddInt64(&item.hits, 1)
+	atomic.AddInt64(&c.hits, 1)
+}
+
+// miss records a read of item which needed data from the remote
+func (c *cache) miss(item *cacheItem) {
+	atomic.AddInt64(&item.misses, 1)
+	atomic.AddInt64(&c.misses, 1)
+}
+
 // updateStats walks the cache updating any atimes and sizes it finds
 //
+// The size of a file is the number of bytes of it present in the
+// cache which may be less than the size of the sparse file.
+//
 // it also updates used
 func (c *cache) updateStats() error {
 	var newUsed int64
 	err := c.walk(func(osPath string, fi os.FileInfo, name string) error {
 		if !fi.IsDir() {
-			// Update the atime with that of the file
+			item := c.loadItem(name)
+			size := item.presentSize(fi.Size())
+			// Update the atime with that of the file, or the
+			// one recorded if later as atimes may not be updated
 			atime := times.Get(fi).AccessTime()
-			c.updateStat(name, atime, fi.Size())
-			newUsed += fi.Size()
+			if recorded := item.accessTime(); recorded.After(atime) {
+				atime = recorded
+			}
+			c.updateStat(name, atime, size)
+			newUsed += size
 		} else {
 			c.cacheDir(name)
 		}
@@ -382,7 +470,7 @@ func (c *cache) _purgeOld(maxAge time.Duration, remove func(name string)) {
 	defer c.itemMu.Unlock()
 	cutoff := time.Now().Add(-maxAge)
 	for name, item := range c.item {
-		if item.isFile && item.opens == 0 {
+		if item.isFile && item.opens == 0 && !c.pins.isPinned(name) {
 			// If not locked and access time too long ago - delete the file
 			dt := item.atime.Sub(cutoff)
 			// fs.Debugf(name, "atime=%v cutoff=%v, dt=%v", item.atime, cutoff, dt)
@@ -447,9 +535,9 @@ func (c *cache) _purgeOverQuota(quota int64, remove func(name string)) {
 
 	var items cacheNamedItems
 
-	// Make a slice of unused files
+	// Make a slice of unused files which aren't pinned
 	for name, item := range c.item {
-		if item.isFile && item.opens == 0 {
+		if item.isFile && item.opens == 0 && !c.pins.isPinned(name) {
 			items = append(items, cacheNamedItem{
 				name: name,
 				item: item,
@@ -506,6 +594,94 @@ func (c *cache) clean() {
 	fs.Infof(nil, "Cleaned the cache: objects %d (was %d), total size %v (was %v)", newItems, oldItems, newUsed, oldUsed)
 }
 
+// cacheFileStatus describes a file in the cache
+type cacheFileStatus struct {
+	Name        string    `json:"name"`        // remote path of the file
+	Size        int64     `json:"size"`        // size of the file
+	BytesCached int64     `json:"bytesCached"` // bytes of the file stored in the cache
+	Opens       int       `json:"opens"`       // number of times the file is open
+	Dirty       bool      `json:"dirty"`       // set if the file needs uploading
+	Uploading   bool      `json:"uploading"`   // set if the file is in the upload queue
+	Pinned      bool      `json:"pinned"`      // set if the file should be kept in the cache
+	ATime       time.Time `json:"atime"`       // last time the file was accessed
+	Fingerprint string    `json:"fingerprint"` // fingerprint of the remote file the data came from
+	Hits        int64     `json:"hits"`        // reads served from the cache since rclone started
+	Misses      int64     `json:"misses"`      // reads which needed data from the remote since rclone started
+}
+
+// list returns the status of the files in the cache sorted by name
+func (c *cache) list() (out []cacheFileStatus, err error) {
+	out = []cacheFileStatus{}
+	err = c.walk(func(osPath string, fi os.FileInfo, name string) error {
+		if fi.IsDir() {
+			return nil
+		}
+		item := c.loadItem(name)
+		item.mu.Lock()
+		info := item.info
+		item.mu.Unlock()
+		c.itemMu.Lock()
+		opens := item.opens
+		c.itemMu.Unlock()
+		atime := times.Get(fi).AccessTime()
+		if info.ATime.After(atime) {
+			atime = info.ATime
+		}
+		out = append(out, cacheFileStatus{
+			Name:        name,
+			Size:        fi.Size(),
+			BytesCached: item.presentSize(fi.Size()),
+			Opens:       opens,
+			Dirty:       info.Dirty,
+			Uploading:   c.writeBack.isQueued(name),
+			Pinned:      info.Pinned || c.pins.isPinned(name),
+			ATime:       atime,
+			Fingerprint: info.Fingerprint,
+			Hits:        atomic.LoadInt64(&item.hits),
+			Misses:      atomic.LoadInt64(&item.misses),
+		})
+		return nil
+	})
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, err
+}
+
+// stats returns a summary of the state of the cache
+func (c *cache) stats() (out rc.Params, err error) {
+	files, err := c.list()
+	if err != nil {
+		return nil, err
+	}
+	var bytesUsed int64
+	dirty, pinned := 0, 0
+	for _, file := range files {
+		bytesUsed += file.BytesCached
+		if file.Dirty {
+			dirty++
+		}
+		if file.Pinned {
+			pinned++
+		}
+	}
+	queued, uploading := c.writeBack.counts()
+	return rc.Params{
+		"path":              c.root,
+		"pathMeta":          c.metaRoot,
+		"files":             len(files),
+		"bytesUsed":         bytesUsed,
+		"maxSize":           int64(c.opt.CacheMaxSize),
+		"dirty":             dirty,
+		"pinned":            pinned,
+		"uploadsQueued":     queued,
+		"uploadsInProgress": uploading,
+		"hits":              atomic.LoadInt64(&c.hits),
+		"misses":            atomic.LoadInt64(&c.misses),
+	}, nil
+}
+
 // cleaner calls clean at regular intervals
 //
 // doesn't return until context is cancelled
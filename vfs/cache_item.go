@@ -0,0 +1,510 @@
+// Sparse caching of file data
+//
+// Files in the cache are sparse - only the parts of the remote object
+// which have been read are downloaded. A record of which byte ranges
+// are present is kept in a metadata file for each cached file so the
+// cached data can be reused after a restart.
+
+package vfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/fs/operations"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxDownloadSkip is the furthest forward the open download
+	// stream will be read and discarded rather than re-opening it
+	maxDownloadSkip = 1024 * 1024
+	// downloadBufferSize is the size of the buffer used to copy
+	// data into the cache file
+	downloadBufferSize = 64 * 1024
+)
+
+// cacheItemInfo is persisted alongside the cached data for a file
+type cacheItemInfo struct {
+	Fingerprint string    // fingerprint of the remote object the data came from or "" if none
+	ATime       time.Time // last time the file was accessed
+	Rs          Ranges    // byte ranges of the data which are present
+	Dirty       bool      // set if the data needs uploading to the remote
+	Pinned      bool      // set if the file should be kept in the cache
+}
+
+// fingerprint returns a string which changes when o changes, made
+// from its size, modification time and hash.
+//
+// The hash is only used if it is quick to read from the remote.
+func fingerprint(ctx context.Context, o fs.Object) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "%d,%s", o.Size(), o.ModTime(ctx).UTC().Format(time.RFC3339Nano))
+	f := o.Fs()
+	if !f.Features().SlowHash {
+		if ht := f.Hashes().GetOne(); ht != hash.None {
+			sum, err := o.Hash(ctx, ht)
+			if err == nil && sum != "" {
+				fmt.Fprintf(&out, ",%v:%s", ht, sum)
+			}
+		}
+	}
+	return out.String()
+}
+
+// downloader streams data from the remote object into the cache file
+type downloader struct {
+	in     io.ReadCloser         // the open stream or nil if not open
+	stats  *accounting.StatsInfo // the stats in is accounted to
+	offset int64                 // offset of the next byte to be read from in
+	fd     *os.File              // the cache file opened for writing
+	buf    []byte                // buffer for copying data
+}
+
+// metaPath returns the OS path of the metadata file for the item
+func (item *cacheItem) metaPath() string {
+	return filepath.Join(item.c.metaRoot, filepath.FromSlash(item.name))
+}
+
+// _setPresent updates the count of bytes present from the info
+//
+// call with item.mu held
+func (item *cacheItem) _setPresent() {
+	atomic.StoreInt64(&item.present, item.info.Rs.Size())
+}
+
+// presentSize returns the number of bytes of the file present in the
+// cache, or fallback if this isn't known
+func (item *cacheItem) presentSize(fallback int64) int64 {
+	present := atomic.LoadInt64(&item.present)
+	if present < 0 {
+		return fallback
+	}
+	return present
+}
+
+// _load reads the info for the item from disk if it hasn't been
+// read already
+//
+// call with item.mu held
+func (item *cacheItem) _load() {
+	if item.loaded {
+		return
+	}
+	item.loaded = true
+	data, err := ioutil.ReadFile(item.metaPath())
+	if os.IsNotExist(err) {
+		return
+	} else if err != nil {
+		fs.Errorf(item.name, "vfs cache: failed to read metadata: %v", err)
+		return
+	}
+	var info cacheItemInfo
+	err = json.Unmarshal(data, &info)
+	if err != nil {
+		fs.Errorf(item.name, "vfs cache: ignoring corrupted metadata: %v", err)
+		return
+	}
+	item.info = info
+	item.hasInfo = true
+	item._setPresent()
+}
+
+// _save writes the info for the item to disk
+//
+// call with item.mu held
+func (item *cacheItem) _save() error {
+	metaPath := item.metaPath()
+	err := os.MkdirAll(filepath.Dir(metaPath), 0700)
+	if err != nil {
+		return errors.Wrap(err, "vfs cache: failed to make metadata directory")
+	}
+	data, err := json.Marshal(&item.info)
+	if err != nil {
+		return errors.Wrap(err, "vfs cache: failed to encode metadata")
+	}
+	// Write to a temporary file and rename so the metadata is
+	// never found half written
+	tmpPath := metaPath + ".tmp"
+	err = ioutil.WriteFile(tmpPath, data, 0600)
+	if err == nil {
+		err = os.Rename(tmpPath, metaPath)
+	}
+	if err != nil {
+		return errors.Wrap(err, "vfs cache: failed to write metadata")
+	}
+	return nil
+}
+
+// save writes the info for the item to disk if it has any
+func (item *cacheItem) save() error {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	if !item.hasInfo {
+		return nil
+	}
+	return item._save()
+}
+
+// _reset sets the info to describe o with the first size bytes of the
+// data present
+//
+// If o is nil then the data didn't come from the remote.
+//
+// call with item.mu held
+func (item *cacheItem) _reset(ctx context.Context, o fs.Object, size int64) {
+	item.info = cacheItemInfo{
+		ATime:  item.info.ATime,
+		Pinned: item.info.Pinned,
+	}
+	if o != nil {
+		item.info.Fingerprint = fingerprint(ctx, o)
+	}
+	item.info.Rs.Insert(Range{Pos: 0, Size: size})
+	item.hasInfo = true
+	item._setPresent()
+}
+
+// _matches returns true if the cached data came from o
+//
+// call with item.mu held
+func (item *cacheItem) _matches(ctx context.Context, o fs.Object) bool {
+	return item.hasInfo && o.Size() >= 0 && item.info.Fingerprint != "" && item.info.Fingerprint == fingerprint(ctx, o)
+}
+
+// legacyNeedsTransfer checks a cached file with no metadata, as
+// written by versions of rclone which always cached whole files,
+// returning true if it isn't a copy of o
+func (item *cacheItem) legacyNeedsTransfer(ctx context.Context, o fs.Object) bool {
+	cacheObj, err := item.c.f.NewObject(ctx, item.name)
+	if err != nil {
+		return true
+	}
+	return operations.NeedTransfer(ctx, cacheObj, o)
+}
+
+// open prepares the cache file at osPath for the item to be opened.
+//
+// If truncate is set the file is about to be truncated. If validate
+// is set then the cached data is checked against the remote object o
+// (which may be nil) and discarded if it is out of date.
+//
+// If the cached data is discarded the cache file is made into a
+// sparse file of the size of o with no data present.
+func (item *cacheItem) open(ctx context.Context, o fs.Object, osPath string, truncate, validate bool) error {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	item._load()
+	item.info.ATime = time.Now()
+	fi, err := os.Stat(osPath)
+	exists := err == nil
+	switch {
+	case truncate:
+		item._reset(ctx, nil, 0)
+	case o == nil:
+		// nothing on the remote so the cached data, if any, is
+		// all there is
+		var size int64
+		if exists {
+			size = fi.Size()
+		}
+		item._reset(ctx, nil, size)
+	case exists && item.hasInfo && !validate:
+		// other handles have the file open so use it as is
+		return nil
+	case exists && item.info.Dirty:
+		// the data hasn't been uploaded yet so is newer than o
+		fs.Debugf(item.name, "vfs cache: using cached data which is waiting for upload")
+	case exists && item._matches(ctx, o):
+		fs.Debugf(item.name, "vfs cache: using %v of cached data", fs.SizeSuffix(item.info.Rs.Size()))
+	case exists && !item.hasInfo && !item.legacyNeedsTransfer(ctx, o):
+		fs.Debugf(item.name, "vfs cache: using existing cached file")
+		item._reset(ctx, o, fi.Size())
+	default:
+		if exists {
+			fs.Debugf(item.name, "vfs cache: discarding out of date cached data")
+		}
+		item._closeDownloader()
+		item._reset(ctx, o, 0)
+		err = item._makeSparse(ctx, o, osPath)
+		if err != nil {
+			return err
+		}
+	}
+	return item._save()
+}
+
+// _makeSparse makes an empty cache file at osPath the size of o
+//
+// If the size of o isn't known then it downloads all of it.
+//
+// call with item.mu held
+func (item *cacheItem) _makeSparse(ctx context.Context, o fs.Object, osPath string) error {
+	fd, err := os.OpenFile(osPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrap(err, "vfs cache: failed to create cache file")
+	}
+	if o.Size() < 0 {
+		// Unknown size so read it all
+		accounting.Stats(ctx).Transferring(item.name)
+		size, err := item._downloadAll(ctx, o, fd)
+		accounting.Stats(ctx).DoneTransferring(item.name, err == nil)
+		closeErr := fd.Close()
+		if err != nil {
+			return err
+		}
+		item.info.Rs.Insert(Range{Pos: 0, Size: size})
+		item._setPresent()
+		return closeErr
+	}
+	err = fd.Truncate(o.Size())
+	closeErr := fd.Close()
+	if err != nil {
+		return errors.Wrap(err, "vfs cache: failed to size cache file")
+	}
+	return closeErr
+}
+
+// _downloadAll copies all of o into fd returning the number of bytes
+// copied
+//
+// call with item.mu held
+func (item *cacheItem) _downloadAll(ctx context.Context, o fs.Object, fd *os.File) (int64, error) {
+	in, err := o.Open(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "vfs cache: failed to open object")
+	}
+	acc := accounting.Stats(ctx).NewAccount(in, o)
+	n, err := io.Copy(fd, acc)
+	closeErr := acc.Close()
+	if err != nil {
+		return n, errors.Wrap(err, "vfs cache: failed to download")
+	}
+	return n, closeErr
+}
+
+// ensure makes sure the range r of the cache file at osPath is
+// present, downloading any missing parts of it from o.
+func (item *cacheItem) ensure(ctx context.Context, o fs.Object, osPath string, r Range) error {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	fi, err := os.Stat(osPath)
+	if err != nil {
+		return errors.Wrap(err, "vfs cache: failed to stat cache file")
+	}
+	size := fi.Size()
+	r.Clip(size)
+	item.info.ATime = time.Now()
+	if item.info.Rs.Present(r) {
+		item.c.hit(item)
+	} else {
+		item.c.miss(item)
+	}
+	for {
+		missing := item.info.Rs.FindMissing(r)
+		if missing.IsEmpty() {
+			return nil
+		}
+		if o == nil {
+			return errors.New("vfs cache: data is missing and there is no object to read it from")
+		}
+		err = item._download(ctx, o, osPath, missing, size)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ensureAll makes sure all the cache file at osPath is present
+func (item *cacheItem) ensureAll(ctx context.Context, o fs.Object, osPath string) error {
+	return item.ensure(ctx, o, osPath, Range{Pos: 0, Size: 1<<63 - 1})
+}
+
+// setPresent marks the first size bytes of the data as present and
+// the rest as absent
+//
+// This is called after the data has been written to, which should
+// only happen once all of it is present. The data no longer matches
+// the remote object until setObject is called.
+func (item *cacheItem) setPresent(size int64) {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	dirty := item.info.Dirty
+	item._reset(context.TODO(), nil, size)
+	item.info.Dirty = dirty
+}
+
+// setDirty marks whether the data needs uploading to the remote and
+// saves the info
+func (item *cacheItem) setDirty(dirty bool) error {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	item.info.Dirty = dirty
+	item.hasInfo = true
+	return item._save()
+}
+
+// setObject records that the cached data is now a copy of o, for
+// example after it has been uploaded, and saves the info
+func (item *cacheItem) setObject(ctx context.Context, o fs.Object) error {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	item.info.Fingerprint = fingerprint(ctx, o)
+	item.info.Dirty = false
+	item.hasInfo = true
+	return item._save()
+}
+
+// setPinned sets whether the file is pinned in the cache and saves the
+// info if it changed
+func (item *cacheItem) setPinned(pinned bool) error {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	item._load()
+	if item.info.Pinned == pinned {
+		return nil
+	}
+	item.info.Pinned = pinned
+	item.hasInfo = true
+	return item._save()
+}
+
+// isComplete returns true if all of o is in the cache file at osPath
+func (item *cacheItem) isComplete(ctx context.Context, o fs.Object, osPath string) bool {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	item._load()
+	fi, err := os.Stat(osPath)
+	if err != nil || fi.Size() != o.Size() || !item._matches(ctx, o) {
+		return false
+	}
+	return item.info.Rs.Present(Range{Pos: 0, Size: fi.Size()})
+}
+
+// _download fetches the missing range from o into the cache file at
+// osPath, which is size bytes long.
+//
+// It reads ahead by --vfs-read-ahead bytes beyond the missing range
+// and reuses the download stream from the last call if possible, so
+// sequential reads only open the object once.
+//
+// call with item.mu held
+func (item *cacheItem) _download(ctx context.Context, o fs.Object, osPath string, missing Range, size int64) (err error) {
+	// Read ahead up to the next data present
+	want := missing
+	want.Size += int64(item.c.opt.ReadAhead)
+	want.Clip(size)
+	want = item.info.Rs.FindMissing(want)
+
+	dl := &item.dl
+	if dl.in != nil && (want.Pos < dl.offset || want.Pos-dl.offset > maxDownloadSkip) {
+		item._closeDownloader()
+	}
+	if dl.fd == nil {
+		dl.fd, err = os.OpenFile(osPath, os.O_RDWR, 0600)
+		if err != nil {
+			return errors.Wrap(err, "vfs cache: failed to open cache file for download")
+		}
+	}
+	if dl.in == nil {
+		fs.Debugf(item.name, "vfs cache: downloading from offset %d", want.Pos)
+		in, err := o.Open(ctx, &fs.RangeOption{Start: want.Pos, End: -1})
+		if err != nil {
+			return errors.Wrap(err, "vfs cache: failed to open object")
+		}
+		dl.stats = accounting.Stats(ctx)
+		dl.in = dl.stats.NewAccount(in, o)
+		dl.offset = want.Pos
+		dl.stats.Transferring(item.name)
+	}
+	if dl.buf == nil {
+		dl.buf = make([]byte, downloadBufferSize)
+	}
+
+	// Skip data we already have
+	if dl.offset < want.Pos {
+		_, err = io.CopyN(ioutil.Discard, dl.in, want.Pos-dl.offset)
+		if err != nil {
+			item._closeDownloader()
+			return errors.Wrap(err, "vfs cache: failed to skip data")
+		}
+		dl.offset = want.Pos
+	}
+
+	for dl.offset < want.End() {
+		buf := dl.buf
+		if remaining := want.End() - dl.offset; remaining < int64(len(buf)) {
+			buf = buf[:remaining]
+		}
+		n, err := io.ReadFull(dl.in, buf)
+		if n > 0 {
+			_, writeErr := dl.fd.WriteAt(buf[:n], dl.offset)
+			if writeErr != nil {
+				item._closeDownloader()
+				return errors.Wrap(writeErr, "vfs cache: failed to write to cache file")
+			}
+			item.info.Rs.Insert(Range{Pos: dl.offset, Size: int64(n)})
+			dl.offset += int64(n)
+		}
+		if err != nil {
+			item._closeDownloader()
+			item._setPresent()
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return errors.New("vfs cache: object is shorter than expected")
+			}
+			return errors.Wrap(err, "vfs cache: failed to download")
+		}
+	}
+	item._setPresent()
+	return nil
+}
+
+// _closeDownloader closes the download stream and the cache file
+// opened for writing if they are open
+//
+// call with item.mu held
+func (item *cacheItem) _closeDownloader() {
+	dl := &item.dl
+	if dl.in != nil {
+		err := dl.in.Close()
+		if err != nil {
+			fs.Debugf(item.name, "vfs cache: error closing download: %v", err)
+		}
+		dl.stats.DoneTransferring(item.name, true)
+		dl.in = nil
+	}
+	if dl.fd != nil {
+		err := dl.fd.Close()
+		if err != nil {
+			fs.Errorf(item.name, "vfs cache: failed to close cache file: %v", err)
+		}
+		dl.fd = nil
+	}
+	dl.buf = nil
+}
+
+// accessTime returns the last time the item was accessed as recorded
+// in its info, or the zero time if not known
+func (item *cacheItem) accessTime() time.Time {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	return item.info.ATime
+}
+
+// closeDownloader closes the download stream and cache file if open
+func (item *cacheItem) closeDownloader() {
+	item.mu.Lock()
+	item._closeDownloader()
+	item.mu.Unlock()
+}
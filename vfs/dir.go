@@ -19,16 +19,18 @@ import (
 
 // Dir represents a directory entry
 type Dir struct {
-	vfs     *VFS
-	inode   uint64 // inode number
-	f       fs.Fs
-	parent  *Dir // parent, nil for root
-	path    string
-	modTime time.Time
-	entry   fs.Directory
-	mu      sync.Mutex      // protects the following
-	read    time.Time       // time directory entry last read
-	items   map[string]Node // directory entries - can be empty but not nil
+	vfs        *VFS
+	inode      uint64 // inode number
+	f          fs.Fs
+	parent     *Dir // parent, nil for root
+	path       string
+	modTime    time.Time
+	entry      fs.Directory
+	mu         sync.Mutex      // protects the following
+	read       time.Time       // time directory entry last read
+	items      map[string]Node // directory entries - can be empty but not nil
+	loaded     bool            // set if the persistent directory cache has been checked
+	refreshing bool            // set if the listing is being refreshed in the background
 }
 
 func newDir(vfs *VFS, f fs.Fs, parent *Dir, fsDir fs.Directory) *Dir {
@@ -109,11 +111,16 @@ func (d *Dir) ForgetAll() {
 // It is not possible to traverse the directory tree upwards, i.e.
 // you cannot clear the cache for the Dir's ancestors or siblings.
 func (d *Dir) ForgetPath(relativePath string, entryType fs.EntryType) {
-	if absPath := path.Join(d.path, relativePath); absPath != "" {
+	absPath := path.Join(d.path, relativePath)
+	if entryType == fs.EntryDirectory {
+		d.vfs.dirCache.forget(absPath, true)
+	}
+	if absPath != "" {
 		parent := path.Dir(absPath)
 		if parent == "." || parent == "/" {
 			parent = ""
 		}
+		d.vfs.dirCache.forget(parent, false)
 		parentNode := d.vfs.root.cachedNode(parent)
 		if dir, ok := parentNode.(*Dir); ok {
 			dir.mu.Lock()
@@ -181,6 +188,7 @@ func (d *Dir) rename(newParent *Dir, fsDir fs.Directory) {
 	d.path = fsDir.Remote()
 	d.modTime = fsDir.ModTime(context.TODO())
 	d.read = time.Time{}
+	d.vfs.dirCache.forget(d.path, true)
 }
 
 // addObject adds a new object or directory to the directory
@@ -189,6 +197,7 @@ func (d *Dir) rename(newParent *Dir, fsDir fs.Directory) {
 func (d *Dir) addObject(node Node) {
 	d.mu.Lock()
 	d.items[node.Name()] = node
+	d.vfs.dirCache.forget(d.path, false)
 	d.mu.Unlock()
 }
 
@@ -196,6 +205,7 @@ func (d *Dir) addObject(node Node) {
 func (d *Dir) delObject(leaf string) {
 	d.mu.Lock()
 	delete(d.items, leaf)
+	d.vfs.dirCache.forget(d.path, false)
 	d.mu.Unlock()
 }
 
@@ -209,6 +219,17 @@ func (d *Dir) _readDir() error {
 	} else {
 		return nil
 	}
+	if d.refreshing && !d.read.IsZero() {
+		// use the listing from the persistent directory cache
+		// until the refresh has finished
+		return nil
+	}
+	if d.read.IsZero() && !d.loaded {
+		d.loaded = true
+		if d._readDirFromCache(when) {
+			return nil
+		}
+	}
 	entries, err := list.DirSorted(context.TODO(), d.f, false, d.path)
 	if err == fs.ErrorDirNotFound {
 		// We treat directory not found as empty because we
@@ -223,19 +244,93 @@ func (d *Dir) _readDir() error {
 	}
 
 	d.read = when
+	d.vfs.dirCache.put(d.path, entries, when)
 	return nil
 }
 
+// _readDirFromCache sets d.items from the persistent directory cache
+// returning true if it was found there.
+//
+// If the listing is older than --dir-cache-time then it is used until
+// it has been read again from the remote in the background.
+//
+// must be called with the lock held
+func (d *Dir) _readDirFromCache(when time.Time) bool {
+	entries, read, ok := d.vfs.dirCache.get(d.path)
+	if !ok {
+		return false
+	}
+	err := d._readDirFromEntries(entries, nil, time.Time{})
+	if err != nil {
+		fs.Errorf(d.path, "Failed to use listing from directory cache: %v", err)
+		return false
+	}
+	fs.Debugf(d.path, "Using listing from directory cache (%v old)", when.Sub(read))
+	d.read = read
+	if d.stale(when) {
+		d.refreshing = true
+		go d.refresh()
+	}
+	return true
+}
+
+// refresh reads the directory from the remote in the background,
+// replacing the listing read from the persistent directory cache
+func (d *Dir) refresh() {
+	d.mu.Lock()
+	f, dirPath := d.f, d.path
+	d.mu.Unlock()
+	when := time.Now()
+	entries, err := list.DirSorted(context.TODO(), f, false, dirPath)
+	if err == fs.ErrorDirNotFound {
+		err = nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.refreshing = false
+	if err != nil {
+		fs.Errorf(dirPath, "Failed to refresh listing from directory cache: %v", err)
+		return
+	}
+	if d.path != dirPath {
+		// renamed while refreshing so the listing is out of date
+		return
+	}
+	err = d._readDirFromEntries(entries, nil, time.Time{})
+	if err != nil {
+		fs.Errorf(dirPath, "Failed to refresh listing from directory cache: %v", err)
+		d.read = time.Time{}
+		return
+	}
+	fs.Debugf(dirPath, "Refreshed listing from directory cache in %v", time.Since(when))
+	d.read = when
+	d.vfs.dirCache.put(dirPath, entries, when)
+}
+
 // update d.items for each dir in the DirTree below this one and
 // set the last read time - must be called with the lock held
 func (d *Dir) _readDirFromDirTree(dirTree dirtree.DirTree, when time.Time) error {
-	return d._readDirFromEntries(dirTree[d.path], dirTree, when)
+	err := d._readDirFromEntries(dirTree[d.path], dirTree, when)
+	if err != nil {
+		return err
+	}
+	d.vfs.dirCache.put(d.path, dirTree[d.path], when)
+	return nil
 }
 
 // update d.items and if dirTree is not nil update each dir in the DirTree below this one and
 // set the last read time - must be called with the lock held
 func (d *Dir) _readDirFromEntries(entries fs.DirEntries, dirTree dirtree.DirTree, when time.Time) error {
 	var err error
+	// Find the names of the entries so links with the same name as
+	// another entry can be shown as files instead of hiding it
+	var names map[string]struct{}
+	if d.vfs.Opt.Links {
+		names = make(map[string]struct{}, len(entries))
+		for _, entry := range entries {
+			names[path.Base(entry.Remote())] = struct{}{}
+		}
+	}
 	// Cache the items by name
 	found := make(map[string]struct{})
 	for _, entry := range entries {
@@ -243,16 +338,27 @@ func (d *Dir) _readDirFromEntries(entries fs.DirEntries, dirTree dirtree.DirTree
 		if name == "." || name == ".." {
 			continue
 		}
+		link := false
+		if _, ok := entry.(fs.Object); ok && d.vfs.Opt.Links && strings.HasSuffix(name, fs.LinkSuffix) && name != fs.LinkSuffix {
+			linkName := strings.TrimSuffix(name, fs.LinkSuffix)
+			if _, clash := names[linkName]; clash {
+				fs.Logf(d, "Symlink %q has the same name as another entry so showing it as a file %q", linkName, name)
+			} else {
+				name, link = linkName, true
+			}
+		}
 		node := d.items[name]
 		found[name] = struct{}{}
 		switch item := entry.(type) {
 		case fs.Object:
 			obj := item
 			// Reuse old file value if it exists
-			if file, ok := node.(*File); node != nil && ok {
+			if file, ok := node.(*File); node != nil && ok && file.link == link {
 				file.setObjectNoUpdate(obj)
 			} else {
-				node = newFile(d, obj, name)
+				file = newFile(d, obj, name)
+				file.link = link
+				node = file
 			}
 		case fs.Directory:
 			// Reuse old dir value if it exists
@@ -281,8 +387,12 @@ func (d *Dir) _readDirFromEntries(entries fs.DirEntries, dirTree dirtree.DirTree
 		d.items[name] = node
 	}
 	// delete unused entries
-	for name := range d.items {
+	for name, node := range d.items {
 		if _, ok := found[name]; !ok {
+			// keep files which haven't been uploaded yet
+			if file, ok := node.(*File); ok && file.isPendingWriteBack() {
+				continue
+			}
 			delete(d.items, name)
 		}
 	}
@@ -453,6 +563,17 @@ func (d *Dir) Create(name string, flags int) (*File, error) {
 	if d.vfs.Opt.ReadOnly {
 		return nil, EROFS
 	}
+	// Remove a symlink being replaced so its link object isn't
+	// left behind
+	if d.vfs.Opt.Links {
+		node, err := d.stat(name)
+		if file, ok := node.(*File); err == nil && ok && file.link {
+			err = file.Remove()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
 	// This gets added to the directory when the file is opened for write
 	return newFile(d, nil, name), nil
 }
@@ -0,0 +1,393 @@
+// Persistent cache of directory listings
+
+package vfs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/pkg/errors"
+)
+
+// dirCacheListingName is the name of the file each listing is stored in
+const dirCacheListingName = "listing.json"
+
+// dirCache saves directory listings to disk so they can be used
+// straight away when the VFS is next started, before they have been
+// read from the remote again.
+//
+// The listings are stored in a directory tree which mirrors the
+// remote. Each directory name is prefixed with "_" so it can't clash
+// with the listing files.
+type dirCache struct {
+	f         fs.Fs
+	root      string // root of the directory tree the listings are in
+	noModTime bool   // don't read the modification times of objects
+}
+
+// dirCacheListing is the data stored for a directory
+type dirCacheListing struct {
+	Read    time.Time       `json:"read"`    // when the listing was read from the remote
+	Entries []dirCacheEntry `json:"entries"` // the entries in the directory
+}
+
+// dirCacheEntry is the data stored for an entry in a directory
+type dirCacheEntry struct {
+	Leaf     string            `json:"l"`           // name of the entry
+	IsDir    bool              `json:"d,omitempty"` // set if this is a directory
+	Size     int64             `json:"s"`           // size of the entry
+	ModTime  time.Time         `json:"t"`           // modification time of the entry
+	Hashes   map[string]string `json:"h,omitempty"` // hashes of an object by hash name
+	MimeType string            `json:"m,omitempty"` // mime type of an object if known
+	ID       string            `json:"i,omitempty"` // ID of the entry if known
+}
+
+// newDirCache opens the persistent directory cache for f
+func newDirCache(f fs.Fs, opt *Options) (*dirCache, error) {
+	root := cachePath("vfsDir", f)
+	fs.Debugf(nil, "vfs directory cache root is %q", root)
+	err := os.MkdirAll(root, 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to make directory cache directory")
+	}
+	return &dirCache{
+		f:         f,
+		root:      root,
+		noModTime: opt.NoModTime,
+	}, nil
+}
+
+// toOSPath returns the directory the listing of dir is stored in
+func (dc *dirCache) toOSPath(dir string) string {
+	osPath := dc.root
+	if dir != "" {
+		for _, name := range strings.Split(dir, "/") {
+			osPath = filepath.Join(osPath, "_"+name)
+		}
+	}
+	return osPath
+}
+
+// get reads the listing for dir from the cache, returning the entries
+// and when they were read from the remote.
+//
+// It returns false if dir isn't in the cache.
+func (dc *dirCache) get(dir string) (entries fs.DirEntries, read time.Time, ok bool) {
+	if dc == nil {
+		return nil, read, false
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dc.toOSPath(dir), dirCacheListingName))
+	if os.IsNotExist(err) {
+		return nil, read, false
+	} else if err != nil {
+		fs.Errorf(dir, "vfs directory cache: failed to read listing: %v", err)
+		return nil, read, false
+	}
+	var listing dirCacheListing
+	err = json.Unmarshal(data, &listing)
+	if err != nil {
+		fs.Errorf(dir, "vfs directory cache: ignoring corrupted listing: %v", err)
+		return nil, read, false
+	}
+	entries = make(fs.DirEntries, 0, len(listing.Entries))
+	for i := range listing.Entries {
+		entry := &listing.Entries[i]
+		remote := path.Join(dir, entry.Leaf)
+		if entry.IsDir {
+			entries = append(entries, fs.NewDir(remote, entry.ModTime).SetSize(entry.Size).SetID(entry.ID))
+			continue
+		}
+		o := &cachedObject{
+			f:        dc.f,
+			remote:   remote,
+			size:     entry.Size,
+			modTime:  entry.ModTime,
+			mimeType: entry.MimeType,
+			id:       entry.ID,
+			hashes:   make(map[hash.Type]string, len(entry.Hashes)),
+		}
+		for name, sum := range entry.Hashes {
+			var ht hash.Type
+			if ht.Set(name) == nil {
+				o.hashes[ht] = sum
+			}
+		}
+		entries = append(entries, o)
+	}
+	return entries, listing.Read, true
+}
+
+// put saves the listing for dir which was read from the remote at
+// read
+func (dc *dirCache) put(dir string, entries fs.DirEntries, read time.Time) {
+	if dc == nil {
+		return
+	}
+	ctx := context.TODO()
+	slowHash := dc.f.Features().SlowHash
+	listing := dirCacheListing{
+		Read:    read,
+		Entries: make([]dirCacheEntry, 0, len(entries)),
+	}
+	for _, item := range entries {
+		entry := dirCacheEntry{
+			Leaf: path.Base(item.Remote()),
+			Size: item.Size(),
+		}
+		if do, ok := item.(fs.IDer); ok {
+			entry.ID = do.ID()
+		}
+		switch x := item.(type) {
+		case fs.Directory:
+			entry.IsDir = true
+			entry.ModTime = x.ModTime(ctx)
+		case fs.Object:
+			if !dc.noModTime {
+				entry.ModTime = x.ModTime(ctx)
+			}
+			if do, ok := x.(fs.MimeTyper); ok {
+				entry.MimeType = do.MimeType(ctx)
+			}
+			if !slowHash {
+				for _, ht := range dc.f.Hashes().Array() {
+					if sum, err := x.Hash(ctx, ht); err == nil && sum != "" {
+						if entry.Hashes == nil {
+							entry.Hashes = make(map[string]string)
+						}
+						entry.Hashes[ht.String()] = sum
+					}
+				}
+			}
+		}
+		listing.Entries = append(listing.Entries, entry)
+	}
+	data, err := json.Marshal(&listing)
+	if err == nil {
+		err = dc.write(dir, data)
+	}
+	if err != nil {
+		fs.Errorf(dir, "vfs directory cache: failed to save listing: %v", err)
+	}
+}
+
+// write writes the listing data for dir to disk
+func (dc *dirCache) write(dir string, data []byte) error {
+	osPath := dc.toOSPath(dir)
+	err := os.MkdirAll(osPath, 0700)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file and rename so the listing is
+	// never found half written
+	fd, err := ioutil.TempFile(osPath, dirCacheListingName+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = fd.Write(data)
+	closeErr := fd.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(fd.Name(), filepath.Join(osPath, dirCacheListingName))
+	}
+	if err != nil {
+		_ = os.Remove(fd.Name())
+	}
+	return err
+}
+
+// forget removes the listing for dir from the cache, and the listings
+// of all the directories below it if recursive is set.
+func (dc *dirCache) forget(dir string, recursive bool) {
+	if dc == nil {
+		return
+	}
+	osPath := dc.toOSPath(dir)
+	var err error
+	if recursive {
+		err = os.RemoveAll(osPath)
+	} else {
+		err = os.Remove(filepath.Join(osPath, dirCacheListingName))
+	}
+	if err != nil && !os.IsNotExist(err) {
+		fs.Errorf(dir, "vfs directory cache: failed to remove listing: %v", err)
+	}
+}
+
+// cachedObject is an object read from the persistent directory cache
+//
+// It returns the size, modification time and hashes which were saved
+// and finds the object on the remote the first time it is needed for
+// anything else.
+type cachedObject struct {
+	f        fs.Fs
+	remote   string
+	size     int64
+	modTime  time.Time
+	hashes   map[hash.Type]string
+	mimeType string
+	id       string
+	mu       sync.Mutex // protects the following variable
+	o        fs.Object  // the object on the remote once found
+}
+
+// check interfaces
+var (
+	_ fs.Object    = (*cachedObject)(nil)
+	_ fs.MimeTyper = (*cachedObject)(nil)
+	_ fs.IDer      = (*cachedObject)(nil)
+)
+
+// resolveObject returns the object on the remote for o if it came
+// from the persistent directory cache, or o otherwise
+func resolveObject(ctx context.Context, o fs.Object) (fs.Object, error) {
+	if co, ok := o.(*cachedObject); ok {
+		return co.resolve(ctx)
+	}
+	return o, nil
+}
+
+// resolve finds the object on the remote
+func (co *cachedObject) resolve(ctx context.Context) (fs.Object, error) {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	if co.o == nil {
+		o, err := co.f.NewObject(ctx, co.remote)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to find object from directory cache")
+		}
+		co.o = o
+	}
+	return co.o, nil
+}
+
+// resolved returns the object on the remote if it has been found or
+// nil
+func (co *cachedObject) resolved() fs.Object {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	return co.o
+}
+
+// Fs returns the Fs the object is part of
+func (co *cachedObject) Fs() fs.Info {
+	return co.f
+}
+
+// String returns a description of the object
+func (co *cachedObject) String() string {
+	if co == nil {
+		return "<nil>"
+	}
+	return co.remote
+}
+
+// Remote returns the remote path
+func (co *cachedObject) Remote() string {
+	return co.remote
+}
+
+// ModTime returns the modification time of the object
+func (co *cachedObject) ModTime(ctx context.Context) time.Time {
+	if o := co.resolved(); o != nil {
+		return o.ModTime(ctx)
+	}
+	return co.modTime
+}
+
+// Size returns the size of the object
+func (co *cachedObject) Size() int64 {
+	if o := co.resolved(); o != nil {
+		return o.Size()
+	}
+	return co.size
+}
+
+// Hash returns the hash of the object, from the cache if possible
+func (co *cachedObject) Hash(ctx context.Context, ty hash.Type) (string, error) {
+	if o := co.resolved(); o != nil {
+		return o.Hash(ctx, ty)
+	}
+	if sum, ok := co.hashes[ty]; ok {
+		return sum, nil
+	}
+	o, err := co.resolve(ctx)
+	if err != nil {
+		return "", err
+	}
+	return o.Hash(ctx, ty)
+}
+
+// Storable returns whether the object can be stored
+func (co *cachedObject) Storable() bool {
+	return true
+}
+
+// MimeType returns the mime type of the object
+func (co *cachedObject) MimeType(ctx context.Context) string {
+	if o := co.resolved(); o != nil {
+		if do, ok := o.(fs.MimeTyper); ok {
+			return do.MimeType(ctx)
+		}
+		return ""
+	}
+	return co.mimeType
+}
+
+// ID returns the ID of the object
+func (co *cachedObject) ID() string {
+	if o := co.resolved(); o != nil {
+		if do, ok := o.(fs.IDer); ok {
+			return do.ID()
+		}
+		return ""
+	}
+	return co.id
+}
+
+// SetModTime sets the modification time of the object on the remote
+func (co *cachedObject) SetModTime(ctx context.Context, t time.Time) error {
+	o, err := co.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return o.SetModTime(ctx, t)
+}
+
+// Open opens the object on the remote for read
+func (co *cachedObject) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	o, err := co.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return o.Open(ctx, options...)
+}
+
+// Update replaces the object on the remote with the data from in
+func (co *cachedObject) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	o, err := co.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return o.Update(ctx, in, src, options...)
+}
+
+// Remove removes the object from the remote
+func (co *cachedObject) Remove(ctx context.Context) error {
+	o, err := co.resolve(ctx)
+	if err != nil {
+		return err
+	}
+	return o.Remove(ctx)
+}
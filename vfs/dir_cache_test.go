@@ -0,0 +1,107 @@
+package vfs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Create a VFS using the persistent directory cache
+func dirCacheNewVFS(t *testing.T, r *fstest.Run, dirCacheTime time.Duration) (*VFS, *Dir) {
+	opt := DefaultOpt
+	opt.DirCachePersist = true
+	opt.DirCacheTime = dirCacheTime
+	opt.PollInterval = 0 // changes are notified by the test
+	vfs := New(r.Fremote, &opt)
+	require.NotNil(t, vfs.dirCache)
+	root, err := vfs.Root()
+	require.NoError(t, err)
+	return vfs, root
+}
+
+func TestDirCachePersist(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	defer func() {
+		_ = os.RemoveAll(cachePath("vfsDir", r.Fremote))
+	}()
+	ctx := context.Background()
+	r.WriteObject(ctx, "dir/file1", "one", t1)
+	r.WriteObject(ctx, "file2", "two!", t2)
+
+	vfs, root := dirCacheNewVFS(t, r, time.Hour)
+	checkListing(t, root, []string{"dir,0,true", "file2,4,false"})
+	node, err := vfs.Stat("dir")
+	require.NoError(t, err)
+	checkListing(t, node.(*Dir), []string{"file1,3,false"})
+	vfs.Shutdown()
+
+	// A new file on the remote isn't seen as the listings are
+	// read from the directory cache
+	r.WriteObject(ctx, "file3", "three", t3)
+	vfs, root = dirCacheNewVFS(t, r, time.Hour)
+	checkListing(t, root, []string{"dir,0,true", "file2,4,false"})
+	node, err = vfs.Stat("dir/file1")
+	require.NoError(t, err)
+	o := node.(*File).getObject()
+	assert.IsType(t, &cachedObject{}, o)
+	assert.Equal(t, t1, o.ModTime(ctx))
+
+	// Reading a file from the cached listing works
+	h, err := vfs.OpenFile("dir/file1", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(h)
+	require.NoError(t, err)
+	require.NoError(t, h.Close())
+	assert.Equal(t, "one", string(data))
+
+	// A change notification makes it read again
+	vfs.notify("file3", fs.EntryObject)
+	checkListing(t, root, []string{"dir,0,true", "file2,4,false", "file3,5,false"})
+	vfs.Shutdown()
+
+	// A listing older than --dir-cache-time is used and then
+	// refreshed in the background
+	o, err = r.Fremote.NewObject(ctx, "file3")
+	require.NoError(t, err)
+	require.NoError(t, o.Remove(ctx))
+	vfs, root = dirCacheNewVFS(t, r, time.Nanosecond)
+	checkListing(t, root, []string{"dir,0,true", "file2,4,false", "file3,5,false"})
+	for i := 0; i < 100; i++ {
+		root.mu.Lock()
+		refreshing := root.refreshing
+		root.mu.Unlock()
+		if !refreshing {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	vfs.Opt.DirCacheTime = time.Hour
+	checkListing(t, root, []string{"dir,0,true", "file2,4,false"})
+
+	// Modifying the directory removes its listing from the cache
+	_, _, ok := vfs.dirCache.get("")
+	assert.True(t, ok)
+	h, err = vfs.OpenFile("file4", os.O_WRONLY|os.O_CREATE, 0600)
+	require.NoError(t, err)
+	_, err = h.WriteString("four")
+	require.NoError(t, err)
+	require.NoError(t, h.Close())
+	_, _, ok = vfs.dirCache.get("")
+	assert.False(t, ok)
+	_, _, ok = vfs.dirCache.get("dir")
+	assert.True(t, ok)
+
+	// Forgetting everything empties the cache
+	root.ForgetAll()
+	_, _, ok = vfs.dirCache.get("dir")
+	assert.False(t, ok)
+	vfs.Shutdown()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -19,6 +20,7 @@ type File struct {
 	inode uint64 // inode number
 	size  int64  // size of file - read and written with atomic int64 - must be 64 bit aligned
 	d     *Dir   // parent directory - read only
+	link  bool   // set if this is a symlink stored in a file ending in fs.LinkSuffix - read only
 
 	mu                sync.Mutex                      // protects the following
 	o                 fs.Object                       // NB o may be nil if file is being written
@@ -29,8 +31,11 @@ type File struct {
 	readWriters       int                             // how many RWFileHandle are open for writing
 	readWriterClosing bool                            // is a RWFileHandle currently cosing?
 	modified          bool                            // has the cache file be modified by a RWFileHandle?
+	pendingWriteBack  bool                            // is the cache file waiting to be uploaded?
 	pendingModTime    time.Time                       // will be applied once o becomes available, i.e. after file was written
 	pendingRenameFun  func(ctx context.Context) error // will be run/renamed after all writers close
+	meta              fs.Metadata                     // metadata of metaObject if read
+	metaObject        fs.Object                       // the object meta was read from
 
 	muRW sync.Mutex // synchonize RWFileHandle.openPending(), RWFileHandle.close() and File.Remove
 }
@@ -65,6 +70,12 @@ func (f *File) IsDir() bool {
 
 // Mode bits of the file or directory - satisfies Node interface
 func (f *File) Mode() (mode os.FileMode) {
+	if f.link {
+		return os.ModeSymlink | 0777
+	}
+	if perms, ok, err := fs.ParseMetadataMode(f.metadata()); err == nil && ok {
+		return perms
+	}
 	return f.d.vfs.Opt.FilePerms
 }
 
@@ -118,8 +129,17 @@ func (f *File) rename(ctx context.Context, destDir *Dir, newName string) error {
 
 	renameCall := func(ctx context.Context) error {
 		newPath := path.Join(destDir.path, newName)
+		if f.link {
+			newPath += fs.LinkSuffix
+		}
 		dstOverwritten, _ := f.d.f.NewObject(ctx, newPath)
-		newObject, err := operations.Move(ctx, f.d.f, dstOverwritten, newPath, f.o)
+		// the object may have come from the persistent directory cache
+		srcObject, err := resolveObject(ctx, f.o)
+		if err != nil {
+			fs.Errorf(f.Path(), "File.Rename error: %v", err)
+			return err
+		}
+		newObject, err := operations.Move(ctx, f.d.f, dstOverwritten, newPath, srcObject)
 		if err != nil {
 			fs.Errorf(f.Path(), "File.Rename error: %v", err)
 			return err
@@ -137,6 +157,9 @@ func (f *File) rename(ctx context.Context, destDir *Dir, newName string) error {
 		f.o = newObject
 		f.d = destDir
 		f.leaf = path.Base(newObject.Remote())
+		if f.link {
+			f.leaf = strings.TrimSuffix(f.leaf, fs.LinkSuffix)
+		}
 		f.pendingRenameFun = nil
 		f.mu.Unlock()
 		return nil
@@ -248,10 +271,17 @@ func (f *File) ModTime() (modTime time.Time) {
 
 	if !f.d.vfs.Opt.NoModTime {
 		// if o is nil it isn't valid yet or there are writers, so return the size so far
-		if f.o == nil || len(f.writers) != 0 || f.readWriterClosing {
+		if f.writingInProgress() {
 			if !f.pendingModTime.IsZero() {
 				return f.pendingModTime
 			}
+			// if waiting for upload return the time of the data in the cache
+			if f.pendingWriteBack {
+				fi, err := os.Stat(f.d.vfs.cache.toOSPath(f.Path()))
+				if err == nil {
+					return fi.ModTime()
+				}
+			}
 		} else {
 			return f.o.ModTime(context.TODO())
 		}
@@ -315,6 +345,7 @@ func (f *File) applyPendingModTime() error {
 	switch err {
 	case nil:
 		fs.Debugf(f.o, "File.applyPendingModTime OK")
+		f.d.vfs.dirCache.forget(f.d.path, false)
 	case fs.ErrorCantSetModTime, fs.ErrorCantSetModTimeWithoutDelete:
 		// do nothing, in order to not break "touch somefile" if it exists already
 	default:
@@ -325,9 +356,29 @@ func (f *File) applyPendingModTime() error {
 	return nil
 }
 
-// writingInProgress returns true of there are any open writers
+// writingInProgress returns true of there are any open writers or the
+// file is waiting to be uploaded
 func (f *File) writingInProgress() bool {
-	return f.o == nil || len(f.writers) != 0 || f.readWriterClosing
+	return f.o == nil || len(f.writers) != 0 || f.readWriterClosing || f.pendingWriteBack
+}
+
+// setPendingWriteBack sets whether the file is waiting to be uploaded
+// from the cache, running any delayed rename once it isn't
+func (f *File) setPendingWriteBack(pending bool) {
+	f.mu.Lock()
+	f.pendingWriteBack = pending
+	f.mu.Unlock()
+	if !pending {
+		f.applyPendingRename()
+	}
+}
+
+// isPendingWriteBack returns whether the file is waiting to be
+// uploaded from the cache
+func (f *File) isPendingWriteBack() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pendingWriteBack
 }
 
 // Update the size while writing
@@ -451,6 +502,10 @@ func (f *File) Remove() error {
 	if f.d.vfs.Opt.ReadOnly {
 		return EROFS
 	}
+	// Don't upload the file if it is waiting to be uploaded
+	if f.d.vfs.Opt.CacheMode >= CacheModeMinimal {
+		f.d.vfs.cache.writeBack.cancel(f.Path())
+	}
 	f.muRW.Lock() // muRW must be locked before mu to avoid
 	f.mu.Lock()   // deadlock in RWFileHandle.openPending and .close
 	if f.o != nil {
@@ -546,6 +601,18 @@ func (f *File) Open(flags int) (fd Handle, err error) {
 		write = true
 	}
 
+	// Symlinks can only be read, and are never cached
+	if f.link {
+		if write {
+			return nil, EPERM
+		}
+		fd, err = f.openRead()
+		if err != nil {
+			return nil, err
+		}
+		return fd, nil
+	}
+
 	// FIXME discover if file is in cache or not?
 
 	// Open the correct sort of handle
@@ -27,6 +27,64 @@ Or individual files or directories:
 
     rclone rc vfs/forget file=path/to/file dir=path/to/dir
 
+#### Persistent directory cache
+
+With ` + "`--dir-cache-persist`" + ` the directory listings are saved on
+disk in the ` + "`--cache-dir`" + ` so when rclone is restarted it can use
+them straight away rather than listing the remote again.  This can
+save a lot of time and API calls on remotes with lots of files.
+
+A saved listing is used as if it had just been read from the remote
+when it was saved, so it is considered up to date for
+` + "`--dir-cache-time`" + ` after that.  If it is older than that it is
+used straight away and read again from the remote in the background.
+Listings are removed from the disk cache when the remote notifies a
+change (see ` + "`--poll-interval`" + `), when the directory is changed
+through rclone or when the directory cache is flushed.  Note that
+changes made on the remote while rclone wasn't running are only seen
+once the saved listing has expired.
+
+### Extended attributes and metadata
+
+Files have read only extended attributes describing the object on the
+remote, where the remote supports them:
+
+  * ` + "`user.rclone.md5`" + `, ` + "`user.rclone.sha1`" + ` etc - the hashes of the object
+  * ` + "`user.rclone.mimetype`" + ` - the MIME type of the object
+  * ` + "`user.rclone.tier`" + ` - the storage tier of the object
+  * ` + "`user.rclone.id`" + ` - the ID of the object (directories too)
+
+So, for example, ` + "`getfattr -n user.rclone.md5 file`" + ` on Linux
+reads the MD5 sum without downloading the file.  Note that hashes which
+the remote doesn't store, like those of the local backend, may still
+need the file to be read on the remote to calculate them.
+
+By default the permissions and owner of files are set by ` + "`--umask`" + `,
+` + "`--uid`" + ` and ` + "`--gid`" + `, and chmod and chown are ignored.  With
+` + "`--vfs-metadata`" + ` they are read from the object metadata if the
+remote stores them, and chmod, chown and setting ` + "`user.*`" + `
+extended attributes write them to the metadata, so tools like ` + "`rsync -X`" + `
+can preserve them.  This is only possible on remotes which can set
+metadata on existing objects, such as local, and on files which have
+been uploaded.  Extended attributes can't be removed, and the metadata
+of directories isn't supported.
+
+### Symlinks
+
+By default rclone doesn't support symlinks.  With ` + "`--vfs-links`" + `
+symlinks are stored on the remote as regular files with a
+` + "`.rclonelink`" + ` extension containing the target of the link, the
+same as the local backend does with ` + "`--links`" + `.  Those files then
+appear as symlinks without the extension, and symlinks made with, for
+example, ` + "`ln -s`" + ` on a mount or over sftp are stored like that.
+
+So a directory copied from local disk with ` + "`rclone copy --links`" + `
+can be mounted with ` + "`--vfs-links`" + ` and will have its symlinks
+intact.  Note that the targets of symlinks are not checked, so a link
+may point outside the mount.  If a link has the same name as another
+file or directory, eg ` + "`foo`" + ` and ` + "`foo.rclonelink`" + `, the link is shown
+as a regular file with its extension.
+
 ### File Buffering
 
 The ` + "`--buffer-size`" + ` flag determines the amount of memory,
@@ -61,6 +119,9 @@ may find that you need one or the other or both.
     --vfs-cache-mode string              Cache mode off|minimal|writes|full (default "off")
     --vfs-cache-poll-interval duration   Interval to poll the cache for stale objects. (default 1m0s)
     --vfs-cache-max-size int             Max total size of objects in the cache. (default off)
+    --vfs-pin stringArray                Path to keep downloaded for offline use in cache-mode full. Can be repeated.
+    --vfs-read-ahead int                 Extra bytes to read ahead into the cache in cache-mode full. (default 0)
+    --vfs-write-back duration            Time to wait after a file is closed before uploading it, 0 to upload it on close. (default 0s)
 
 If run with ` + "`-vv`" + ` rclone will print the location of the file cache.  The
 files are stored in the user cache file area which is OS dependent but
@@ -76,10 +137,43 @@ closed so if rclone is quit or dies with open files then these won't
 get written back to the remote.  However they will still be in the on
 disk cache.
 
+By default a file is uploaded when it is closed and the close doesn't
+return until the upload has finished.  If --vfs-write-back is set then
+the close returns straight away and the file is uploaded in the
+background once it has been closed for that long.  If the file is
+modified again before then the upload is delayed, so a file which is
+written repeatedly is only uploaded once.
+
+Files which fail to upload are retried in the background, waiting
+longer after each failure, up to 5 minutes between tries.  Files
+waiting to be uploaded are recorded in the cache so if rclone is quit
+or dies before they are uploaded they will be uploaded when it is next
+started with the same remote and cache directory.  Files which weren't
+known to rclone when it was started won't appear in directory listings
+until they have been uploaded.
+
+The files waiting to be uploaded can be listed with ` + "`rclone rc vfs/queue`" + `,
+uploaded now with ` + "`rclone rc vfs/queue-retry`" + ` or cancelled with
+` + "`rclone rc vfs/queue-cancel`" + `.
+
 If using --vfs-cache-max-size note that the cache may exceed this size
 for two reasons.  Firstly because it is only checked every
---vfs-cache-poll-interval.  Secondly because open files cannot be
-evicted from the cache.
+--vfs-cache-poll-interval.  Secondly because open files and files
+waiting to be uploaded cannot be evicted from the cache.
+
+Files are stored in the cache as sparse files, so only the parts of
+them which have been read take up space.  The size of a file for
+--vfs-cache-max-size is the amount of it stored in the cache.
+
+Alongside each cached file rclone stores a fingerprint of the remote
+file the data came from, made of its size, modification time and, if
+the remote can supply it cheaply, its hash.  If the file on the remote
+changes then the stale data in the cache is discarded when it is next
+opened.
+
+The state of the cache can be seen with ` + "`rclone rc vfs/stats`" + ` and the
+files in it, with how much of each is cached and how often it has been
+read from the cache, with ` + "`rclone rc vfs/cache-list`" + `.
 
 #### --vfs-cache-mode off
 
@@ -117,12 +211,23 @@ first.
 
 This mode should support all normal file system operations.
 
-If an upload fails it will be retried up to --low-level-retries times.
+If an upload fails it will be retried up to --low-level-retries times,
+and then in the background as described above.
 
 #### --vfs-cache-mode full
 
 In this mode all reads and writes are buffered to and from disk.  When
-a file is opened for read it will be downloaded in its entirety first.
+data is read from a file it is read from the remote into the cache
+first, and subsequent reads of the same data are served from the cache
+without going to the remote.  Only the parts of the file which are
+read are downloaded, along with --vfs-read-ahead bytes after them to
+speed up sequential reads.  The whole file is downloaded before it is
+first written to.
+
+A record of which parts of each file are present is kept alongside
+the cache so the cached data can be used after rclone is restarted,
+provided the size and modification time of the file on the remote
+haven't changed.
 
 This may be appropriate for your needs, or you may prefer to look at
 the cache backend which does a much more sophisticated job of caching,
@@ -136,4 +241,19 @@ This mode should support all normal file system operations.
 
 If an upload or download fails it will be retried up to
 --low-level-retries times.
+
+#### Pinning files for offline use
+
+In this mode files and directories can be pinned in the cache with
+--vfs-pin path, which can be repeated, or with ` + "`rclone rc vfs/pin path=dir`" + `.
+
+Pinned files are downloaded in the background and are never removed
+from the cache by --vfs-cache-max-age or --vfs-cache-max-size, so they
+can be read when the remote isn't available.  They are checked every
+--vfs-cache-poll-interval, and straight away if the remote supports
+polling for changes, and downloaded again if they have changed.  New
+files in pinned directories are downloaded too.
+
+Paths pinned with the rc are remembered when rclone is restarted.  Use
+` + "`rclone rc vfs/unpin path=dir`" + ` to unpin a path.
 `
@@ -0,0 +1,300 @@
+// Pinning of files and directories in the cache for offline use
+
+package vfs
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/pkg/errors"
+)
+
+// pinner keeps pinned files and directories downloaded in the cache.
+//
+// Pinned paths come from --vfs-pin and from the rc. The ones from the
+// rc are saved so they survive a restart.
+//
+// Pinned files are downloaded in the background and are never
+// evicted from the cache. They are checked against the remote every
+// --vfs-cache-poll-interval and when the remote notifies a change
+// and downloaded again if they have changed.
+type pinner struct {
+	c     *cache
+	vfs   *VFS            // set when the pinner is started
+	ctx   context.Context // cancelled when the cache is shut down
+	path  string          // file the pins from the rc are saved in
+	kick  chan struct{}   // wakes up the background download
+	mu    sync.Mutex      // protects the following variables
+	paths map[string]bool // pinned paths, true if they should be saved
+}
+
+// newPinner returns a pinner for the cache, reading the pins saved in
+// path
+func newPinner(ctx context.Context, c *cache, path string) *pinner {
+	p := &pinner{
+		c:     c,
+		ctx:   ctx,
+		path:  path,
+		kick:  make(chan struct{}, 1),
+		paths: make(map[string]bool),
+	}
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		var saved []string
+		err = json.Unmarshal(data, &saved)
+		for _, name := range saved {
+			p.paths[clean(name)] = true
+		}
+	}
+	if err != nil && !os.IsNotExist(err) {
+		fs.Errorf(nil, "vfs cache: failed to read pinned paths: %v", err)
+	}
+	return p
+}
+
+// start adds the pins from the config and starts downloading the
+// pinned files in the background
+func (p *pinner) start(vfs *VFS, pins []string) {
+	p.mu.Lock()
+	for _, name := range pins {
+		name = clean(name)
+		if _, found := p.paths[name]; !found {
+			p.paths[name] = false
+		}
+	}
+	p.vfs = vfs
+	p.mu.Unlock()
+	go p.run()
+}
+
+// _save writes the pins from the rc to disk
+//
+// call with p.mu held
+func (p *pinner) _save() error {
+	saved := []string{}
+	for name, save := range p.paths {
+		if save {
+			saved = append(saved, name)
+		}
+	}
+	sort.Strings(saved)
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return errors.Wrap(err, "vfs cache: failed to encode pinned paths")
+	}
+	err = os.MkdirAll(filepath.Dir(p.path), 0700)
+	if err == nil {
+		err = ioutil.WriteFile(p.path, data, 0600)
+	}
+	if err != nil {
+		return errors.Wrap(err, "vfs cache: failed to save pinned paths")
+	}
+	return nil
+}
+
+// pin adds name to the pinned paths, saving it, and starts
+// downloading it
+func (p *pinner) pin(name string) error {
+	name = clean(name)
+	p.mu.Lock()
+	p.paths[name] = true
+	err := p._save()
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	fs.Infof(name, "vfs cache: pinned")
+	p.refresh()
+	return nil
+}
+
+// unpin removes name from the pinned paths. The cached data is left
+// in the cache to be evicted as normal.
+//
+// It returns false if name wasn't pinned.
+func (p *pinner) unpin(name string) (bool, error) {
+	name = clean(name)
+	p.mu.Lock()
+	_, found := p.paths[name]
+	delete(p.paths, name)
+	err := p._save()
+	p.mu.Unlock()
+	if !found || err != nil {
+		return found, err
+	}
+	fs.Infof(name, "vfs cache: unpinned")
+	// clear the pinned flag of the files which are no longer pinned
+	err = p.c.walk(func(osPath string, fi os.FileInfo, itemName string) error {
+		if fi.IsDir() || !isUnder(itemName, name) || p.isPinned(itemName) {
+			return nil
+		}
+		return p.c.loadItem(itemName).setPinned(false)
+	})
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	return true, err
+}
+
+// list returns the pinned paths sorted by name
+func (p *pinner) list() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]string, 0, len(p.paths))
+	for name := range p.paths {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// isUnder returns true if name is dir or is inside it
+func isUnder(name, dir string) bool {
+	return dir == "" || name == dir || strings.HasPrefix(name, dir+"/")
+}
+
+// isPinned returns true if name or one of its parents is pinned
+//
+// This may be called with c.itemMu held.
+func (p *pinner) isPinned(name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		if _, found := p.paths[name]; found {
+			return true
+		}
+		if name == "" {
+			return false
+		}
+		name = findParent(name)
+	}
+}
+
+// changed is called when the remote reports that name has changed
+// and refreshes the pinned files if it affects them
+func (p *pinner) changed(name string) {
+	name = clean(name)
+	p.mu.Lock()
+	affected := false
+	for pinned := range p.paths {
+		if isUnder(name, pinned) || isUnder(pinned, name) {
+			affected = true
+			break
+		}
+	}
+	p.mu.Unlock()
+	if affected {
+		p.refresh()
+	}
+}
+
+// refresh wakes up the background download
+func (p *pinner) refresh() {
+	select {
+	case p.kick <- struct{}{}:
+	default:
+	}
+}
+
+// run downloads the pinned files whenever they might have changed
+//
+// doesn't return until the context is cancelled
+func (p *pinner) run() {
+	var tick <-chan time.Time
+	if p.c.opt.CachePollInterval > 0 {
+		ticker := time.NewTicker(p.c.opt.CachePollInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		p.sync()
+		select {
+		case <-p.kick:
+		case <-tick:
+		case <-p.ctx.Done():
+			fs.Debugf(nil, "vfs cache: pinner exiting")
+			return
+		}
+	}
+}
+
+// sync makes sure all the pinned files are downloaded and up to date
+func (p *pinner) sync() {
+	for _, name := range p.list() {
+		if p.ctx.Err() != nil {
+			return
+		}
+		node, err := p.vfs.Stat(name)
+		if err != nil {
+			fs.Errorf(name, "vfs cache: failed to find pinned path: %v", err)
+			continue
+		}
+		p.syncNode(node)
+	}
+}
+
+// syncNode downloads the file node or all the files in the directory
+// node
+func (p *pinner) syncNode(node Node) {
+	if p.ctx.Err() != nil {
+		return
+	}
+	switch x := node.(type) {
+	case *File:
+		err := p.fetch(x)
+		if err != nil {
+			fs.Errorf(x, "vfs cache: failed to download pinned file: %v", err)
+		}
+	case *Dir:
+		nodes, err := x.ReadDirAll()
+		if err != nil {
+			fs.Errorf(x, "vfs cache: failed to read pinned directory: %v", err)
+			return
+		}
+		for _, node := range nodes {
+			p.syncNode(node)
+		}
+	}
+}
+
+// fetch makes sure all of file is in the cache and up to date
+func (p *pinner) fetch(file *File) error {
+	if file.link {
+		// symlinks are read from the remote and never cached
+		return nil
+	}
+	name := file.Path()
+	item := p.c.get(name)
+	o := file.getObject()
+	if o == nil || file.isPendingWriteBack() || item.isComplete(p.ctx, o, p.c.toOSPath(name)) {
+		// nothing to download
+		return item.setPinned(true)
+	}
+	fs.Debugf(name, "vfs cache: downloading pinned file")
+	h, err := file.Open(os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	fh, ok := h.(*RWFileHandle)
+	if !ok {
+		_ = h.Close()
+		return errors.New("pinning files needs --vfs-cache-mode full")
+	}
+	err = fh.fetchAll(p.ctx)
+	closeErr := fh.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	return item.setPinned(true)
+}
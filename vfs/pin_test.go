@@ -0,0 +1,108 @@
+package vfs
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Create a VFS in cache mode full with the pins given
+func pinNewVFS(t *testing.T, r *fstest.Run, pins ...string) *VFS {
+	opt := DefaultOpt
+	opt.CacheMode = CacheModeFull
+	opt.CachePollInterval = 0
+	opt.Pin = pins
+	return New(r.Fremote, &opt)
+}
+
+// wait for name to be completely downloaded into the cache with
+// contents
+func pinWaitFor(t *testing.T, vfs *VFS, name, contents string) {
+	var data []byte
+	for i := 0; i < 100; i++ {
+		data, _ = ioutil.ReadFile(vfs.cache.toOSPath(name))
+		if string(data) == contents && vfs.cache.get(name).presentSize(-1) == int64(len(contents)) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.Equal(t, contents, string(data), name)
+}
+
+func TestPinDownload(t *testing.T) {
+	r := fstest.NewRun(t)
+	ctx := context.Background()
+	r.WriteObject(ctx, "dir/file1", "one", t1)
+	r.WriteObject(ctx, "dir/sub/file2", "two", t1)
+	r.WriteObject(ctx, "file3", "three", t1)
+	vfs := pinNewVFS(t, r)
+
+	require.NoError(t, vfs.cache.pins.pin("dir"))
+	defer func() {
+		_, err := vfs.cache.pins.unpin("dir")
+		assert.NoError(t, err)
+		cleanup(t, r, vfs)
+	}()
+	pinWaitFor(t, vfs, "dir/file1", "one")
+	pinWaitFor(t, vfs, "dir/sub/file2", "two")
+	assert.Equal(t, []string{"dir"}, vfs.cache.pins.list())
+
+	files, err := vfs.cache.list()
+	require.NoError(t, err)
+	require.Equal(t, 2, len(files))
+	assert.True(t, files[0].Pinned)
+	assert.True(t, files[1].Pinned)
+
+	// pinned files aren't evicted
+	vfs.cache.purgeOld(-time.Second)
+	vfs.cache.purgeOverQuota(1)
+	pinWaitFor(t, vfs, "dir/file1", "one")
+	pinWaitFor(t, vfs, "dir/sub/file2", "two")
+
+	// a change on the remote is downloaded
+	r.WriteObject(ctx, "dir/file1", "ONE!", t2)
+	vfs.notify("dir/file1", fs.EntryObject)
+	pinWaitFor(t, vfs, "dir/file1", "ONE!")
+
+	// unpinning a path inside a pinned directory leaves it pinned
+	found, err := vfs.cache.pins.unpin("dir/sub")
+	require.NoError(t, err)
+	assert.False(t, found)
+	require.NoError(t, vfs.cache.pins.pin("dir/sub"))
+	found, err = vfs.cache.pins.unpin("dir/sub")
+	require.NoError(t, err)
+	assert.True(t, found)
+	files, err = vfs.cache.list()
+	require.NoError(t, err)
+	require.Equal(t, 2, len(files))
+	assert.Equal(t, "dir/file1", files[0].Name)
+	assert.True(t, files[0].Pinned)
+	assert.Equal(t, "dir/sub/file2", files[1].Name)
+	assert.True(t, files[1].Pinned)
+
+	// the pins are remembered after a restart
+	vfs.Shutdown()
+	vfs = pinNewVFS(t, r, "file3")
+	assert.Equal(t, []string{"dir", "file3"}, vfs.cache.pins.list())
+	pinWaitFor(t, vfs, "file3", "three")
+	assert.True(t, vfs.cache.pins.isPinned("dir/sub/file2"))
+	assert.False(t, vfs.cache.pins.isPinned("file4"))
+
+	// unpinning clears the flag
+	found, err = vfs.cache.pins.unpin("dir")
+	require.NoError(t, err)
+	assert.True(t, found)
+	files, err = vfs.cache.list()
+	require.NoError(t, err)
+	require.Equal(t, 3, len(files))
+	assert.False(t, files[0].Pinned)
+	assert.False(t, files[1].Pinned)
+	assert.Equal(t, "file3", files[2].Name)
+	assert.True(t, files[2].Pinned)
+}
@@ -0,0 +1,120 @@
+// Byte ranges of a file present in the cache
+
+package vfs
+
+import (
+	"sort"
+)
+
+// Range describes a single byte range
+type Range struct {
+	Pos  int64
+	Size int64
+}
+
+// End returns the end of the Range (exclusive)
+func (r Range) End() int64 {
+	return r.Pos + r.Size
+}
+
+// IsEmpty returns true if the Range has no bytes in
+func (r Range) IsEmpty() bool {
+	return r.Size <= 0
+}
+
+// Clip clips r so it doesn't extend beyond offset
+func (r *Range) Clip(offset int64) {
+	if r.End() > offset {
+		r.Size = offset - r.Pos
+		if r.Size < 0 {
+			r.Size = 0
+		}
+	}
+}
+
+// Ranges describes a number of non overlapping Range segments in
+// ascending order.
+//
+// These should only be added with the Insert method so they stay
+// sorted and merged.
+type Ranges []Range
+
+// Insert the new Range into the Ranges, merging it with any it
+// overlaps or touches.
+func (rs *Ranges) Insert(r Range) {
+	if r.IsEmpty() {
+		return
+	}
+	// Find the first range which ends at or after the start of r
+	i := sort.Search(len(*rs), func(i int) bool {
+		return (*rs)[i].End() >= r.Pos
+	})
+	// Merge all the ranges which touch r
+	j := i
+	for j < len(*rs) && (*rs)[j].Pos <= r.End() {
+		if (*rs)[j].Pos < r.Pos {
+			r.Size += r.Pos - (*rs)[j].Pos
+			r.Pos = (*rs)[j].Pos
+		}
+		if (*rs)[j].End() > r.End() {
+			r.Size = (*rs)[j].End() - r.Pos
+		}
+		j++
+	}
+	// Replace (*rs)[i:j] with r
+	merged := append(Ranges{}, (*rs)[:i]...)
+	merged = append(merged, r)
+	merged = append(merged, (*rs)[j:]...)
+	*rs = merged
+}
+
+// Present returns true if all of r is in the Ranges
+func (rs Ranges) Present(r Range) bool {
+	return rs.FindMissing(r).IsEmpty()
+}
+
+// FindMissing returns the first part of r which isn't in the Ranges.
+//
+// If all of r is present it returns an empty Range.
+func (rs Ranges) FindMissing(r Range) Range {
+	for _, x := range rs {
+		if r.IsEmpty() {
+			break
+		}
+		if x.End() <= r.Pos {
+			continue
+		}
+		if x.Pos > r.Pos {
+			// r starts in a gap before x
+			r.Clip(x.Pos)
+			return r
+		}
+		// x covers the start of r so skip past it
+		r.Size -= x.End() - r.Pos
+		r.Pos = x.End()
+	}
+	if r.IsEmpty() {
+		return Range{}
+	}
+	return r
+}
+
+// Size returns the total number of bytes in the Ranges
+func (rs Ranges) Size() (size int64) {
+	for _, r := range rs {
+		size += r.Size
+	}
+	return size
+}
+
+// Truncate removes any parts of the Ranges at or beyond size
+func (rs *Ranges) Truncate(size int64) {
+	var out Ranges
+	for _, r := range *rs {
+		r.Clip(size)
+		if !r.IsEmpty() {
+			out = append(out, r)
+		}
+	}
+	*rs = out
+}
@@ -0,0 +1,86 @@
+package vfs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRangeEnd(t *testing.T) {
+	assert.Equal(t, int64(3), Range{Pos: 1, Size: 2}.End())
+}
+
+func TestRangeClip(t *testing.T) {
+	for _, test := range []struct {
+		r      Range
+		offset int64
+		want   Range
+	}{
+		{r: Range{Pos: 1, Size: 2}, offset: 10, want: Range{Pos: 1, Size: 2}},
+		{r: Range{Pos: 1, Size: 2}, offset: 2, want: Range{Pos: 1, Size: 1}},
+		{r: Range{Pos: 1, Size: 2}, offset: 0, want: Range{Pos: 1, Size: 0}},
+	} {
+		r := test.r
+		r.Clip(test.offset)
+		assert.Equal(t, test.want, r, fmt.Sprintf("%+v clip %d", test.r, test.offset))
+	}
+}
+
+func TestRangesInsert(t *testing.T) {
+	for _, test := range []struct {
+		rs   Ranges
+		r    Range
+		want Ranges
+	}{
+		{rs: nil, r: Range{Pos: 1, Size: 0}, want: nil},
+		{rs: nil, r: Range{Pos: 1, Size: 2}, want: Ranges{{Pos: 1, Size: 2}}},
+		{rs: Ranges{{Pos: 5, Size: 1}}, r: Range{Pos: 1, Size: 2}, want: Ranges{{Pos: 1, Size: 2}, {Pos: 5, Size: 1}}},
+		{rs: Ranges{{Pos: 1, Size: 2}}, r: Range{Pos: 5, Size: 1}, want: Ranges{{Pos: 1, Size: 2}, {Pos: 5, Size: 1}}},
+		{rs: Ranges{{Pos: 1, Size: 2}}, r: Range{Pos: 3, Size: 1}, want: Ranges{{Pos: 1, Size: 3}}},
+		{rs: Ranges{{Pos: 3, Size: 2}}, r: Range{Pos: 1, Size: 2}, want: Ranges{{Pos: 1, Size: 4}}},
+		{rs: Ranges{{Pos: 1, Size: 2}, {Pos: 5, Size: 2}, {Pos: 10, Size: 1}}, r: Range{Pos: 2, Size: 4}, want: Ranges{{Pos: 1, Size: 6}, {Pos: 10, Size: 1}}},
+		{rs: Ranges{{Pos: 1, Size: 2}, {Pos: 5, Size: 2}}, r: Range{Pos: 0, Size: 20}, want: Ranges{{Pos: 0, Size: 20}}},
+		{rs: Ranges{{Pos: 1, Size: 10}}, r: Range{Pos: 2, Size: 2}, want: Ranges{{Pos: 1, Size: 10}}},
+	} {
+		rs := append(Ranges(nil), test.rs...)
+		rs.Insert(test.r)
+		assert.Equal(t, test.want, rs, fmt.Sprintf("%+v insert %+v", test.rs, test.r))
+	}
+}
+
+func TestRangesFindMissing(t *testing.T) {
+	rs := Ranges{{Pos: 1, Size: 2}, {Pos: 5, Size: 2}}
+	for _, test := range []struct {
+		r    Range
+		want Range
+	}{
+		{r: Range{Pos: 0, Size: 0}, want: Range{}},
+		{r: Range{Pos: 0, Size: 1}, want: Range{Pos: 0, Size: 1}},
+		{r: Range{Pos: 0, Size: 10}, want: Range{Pos: 0, Size: 1}},
+		{r: Range{Pos: 1, Size: 2}, want: Range{}},
+		{r: Range{Pos: 1, Size: 10}, want: Range{Pos: 3, Size: 2}},
+		{r: Range{Pos: 2, Size: 4}, want: Range{Pos: 3, Size: 2}},
+		{r: Range{Pos: 5, Size: 2}, want: Range{}},
+		{r: Range{Pos: 6, Size: 4}, want: Range{Pos: 7, Size: 3}},
+		{r: Range{Pos: 20, Size: 4}, want: Range{Pos: 20, Size: 4}},
+	} {
+		assert.Equal(t, test.want, rs.FindMissing(test.r), fmt.Sprintf("%+v", test.r))
+		assert.Equal(t, test.want.IsEmpty(), rs.Present(test.r), fmt.Sprintf("%+v", test.r))
+	}
+}
+
+func TestRangesSize(t *testing.T) {
+	assert.Equal(t, int64(0), Ranges(nil).Size())
+	assert.Equal(t, int64(4), Ranges{{Pos: 1, Size: 2}, {Pos: 5, Size: 2}}.Size())
+}
+
+func TestRangesTruncate(t *testing.T) {
+	rs := Ranges{{Pos: 1, Size: 2}, {Pos: 5, Size: 2}}
+	rs.Truncate(6)
+	assert.Equal(t, Ranges{{Pos: 1, Size: 2}, {Pos: 5, Size: 1}}, rs)
+	rs.Truncate(2)
+	assert.Equal(t, Ranges{{Pos: 1, Size: 1}}, rs)
+	rs.Truncate(0)
+	assert.Equal(t, Ranges(nil), rs)
+}
@@ -2,6 +2,8 @@ package vfs
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -11,6 +13,37 @@ import (
 	"github.com/pkg/errors"
 )
 
+func init() {
+	rc.AddMetrics(metrics)
+}
+
+// metrics returns the size of each active VFS cache
+func metrics() (out []rc.Metric) {
+	used := make(map[string]int64)
+	active.mu.Lock()
+	for c := range active.caches {
+		c.itemMu.Lock()
+		used[c.fremote.Name()+":"+c.fremote.Root()] += c.used
+		c.itemMu.Unlock()
+	}
+	active.mu.Unlock()
+	remotes := make([]string, 0, len(used))
+	for remote := range used {
+		remotes = append(remotes, remote)
+	}
+	sort.Strings(remotes)
+	for _, remote := range remotes {
+		out = append(out, rc.Metric{
+			Name:   "rclone_vfs_cache_bytes",
+			Help:   "Total size of the files in the VFS cache.",
+			Type:   "gauge",
+			Labels: map[string]string{"remote": remote},
+			Value:  float64(used[remote]),
+		})
+	}
+	return out
+}
+
 // Add remote control for the VFS
 func (vfs *VFS) addRC() {
 	rc.Add(rc.Call{
@@ -168,6 +201,256 @@ starting with dir will refresh that directory, eg
 If the parameter recursive=true is given the whole directory tree
 will get refreshed. This refresh will use --fast-list if enabled.
 
+`,
+	})
+	rc.Add(rc.Call{
+		Path: "vfs/stats",
+		Fn: func(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+			out = rc.Params{
+				"fs":        fmt.Sprintf("%s:%s", vfs.f.Name(), vfs.f.Root()),
+				"cacheMode": vfs.Opt.CacheMode.String(),
+			}
+			if vfs.cache != nil {
+				out["diskCache"], err = vfs.cache.stats()
+				if err != nil {
+					return nil, err
+				}
+			}
+			return out, nil
+		},
+		Title: "Stats for the VFS.",
+		Help: `
+This returns stats for the VFS, including the on disk cache if
+--vfs-cache-mode is set.
+
+    rclone rc vfs/stats
+
+The diskCache section has
+
+- path - the directory the cached data is stored in
+- pathMeta - the directory the cache metadata is stored in
+- files - the number of files in the cache
+- bytesUsed - the number of bytes of data stored in the cache
+- maxSize - the value of --vfs-cache-max-size, -1 for no limit
+- dirty - the number of files which need uploading
+- pinned - the number of files pinned in the cache
+- uploadsQueued - the number of files waiting to be uploaded
+- uploadsInProgress - the number of files being uploaded
+- hits - the number of reads served from the cache
+- misses - the number of reads which needed data from the remote
+
+The hits and misses are counted from when rclone started.
+`,
+	})
+	rc.Add(rc.Call{
+		Path: "vfs/cache-list",
+		Fn: func(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+			if vfs.cache == nil {
+				return nil, errors.New("the cache list needs --vfs-cache-mode set")
+			}
+			files, err := vfs.cache.list()
+			if err != nil {
+				return nil, err
+			}
+			return rc.Params{
+				"files": files,
+			}, nil
+		},
+		Title: "List the files in the VFS cache.",
+		Help: `
+This lists the files stored in the on disk VFS cache.
+
+    rclone rc vfs/cache-list
+
+For each file it returns
+
+- name - the path of the file
+- size - the size of the file
+- bytesCached - the number of bytes of the file stored in the cache
+- opens - the number of times the file is open
+- dirty - true if the file needs uploading
+- uploading - true if the file is waiting to be uploaded or being uploaded
+- pinned - true if the file is pinned in the cache
+- atime - the last time the file was accessed
+- fingerprint - the size, modification time and hash of the file on the remote that the cached data came from
+- hits - the number of reads of the file served from the cache
+- misses - the number of reads of the file which needed data from the remote
+
+This needs --vfs-cache-mode set.
+`,
+	})
+	rc.Add(rc.Call{
+		Path: "vfs/pin",
+		Fn: func(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+			pins, err := vfs.rcPins()
+			if err != nil {
+				return nil, err
+			}
+			name, err := in.GetString("path")
+			if err == nil {
+				name = strings.Trim(name, "/")
+				if _, err = vfs.Stat(name); err != nil {
+					return nil, err
+				}
+				err = pins.pin(name)
+				if err != nil {
+					return nil, err
+				}
+			} else if rc.NotErrParamNotFound(err) {
+				return nil, err
+			}
+			return rc.Params{
+				"pins": pins.list(),
+			}, nil
+		},
+		Title: "Pin a file or directory in the VFS cache.",
+		Help: `
+This pins the file or directory passed in as path= so it is kept
+downloaded in the VFS cache for use when the remote isn't available.
+
+    rclone rc vfs/pin path=dir/file
+
+The files are downloaded in the background. Pinned files are never
+evicted from the cache by --vfs-cache-max-age or --vfs-cache-max-size
+and they are downloaded again if they change on the remote.
+
+If no path is passed in then it lists the pinned paths. It returns
+
+- pins - the list of pinned paths
+
+Paths pinned with this call are remembered when rclone is restarted.
+Paths can also be pinned with the --vfs-pin flag.
+
+This needs --vfs-cache-mode full.
+`,
+	})
+	rc.Add(rc.Call{
+		Path: "vfs/unpin",
+		Fn: func(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+			pins, err := vfs.rcPins()
+			if err != nil {
+				return nil, err
+			}
+			name, err := in.GetString("path")
+			if err != nil {
+				return nil, err
+			}
+			name = strings.Trim(name, "/")
+			found, err := pins.unpin(name)
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				return nil, errors.Errorf("%q is not pinned", name)
+			}
+			return rc.Params{
+				"pins": pins.list(),
+			}, nil
+		},
+		Title: "Unpin a file or directory in the VFS cache.",
+		Help: `
+This unpins the file or directory passed in as path= which was pinned
+with vfs/pin or the --vfs-pin flag.
+
+    rclone rc vfs/unpin path=dir/file
+
+The cached data is left in the cache and evicted as normal. It returns
+
+- pins - the list of pinned paths
+
+Paths pinned with the --vfs-pin flag will be pinned again when rclone
+is restarted.
+
+This needs --vfs-cache-mode full.
+`,
+	})
+	rc.Add(rc.Call{
+		Path: "vfs/queue",
+		Fn: func(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+			wb, err := vfs.rcWriteBack()
+			if err != nil {
+				return nil, err
+			}
+			return rc.Params{
+				"queue": wb.list(),
+			}, nil
+		},
+		Title: "List the files waiting to be uploaded from the cache.",
+		Help: `
+This lists the files in the VFS cache which have been modified and are
+waiting to be uploaded to the remote, or are being uploaded.
+
+    rclone rc vfs/queue
+
+For each file it returns
+
+- name - the path of the file
+- size - the size of the file in the cache
+- expiry - seconds until the upload will be tried, which is negative if it is overdue
+- tries - the number of failed uploads
+- uploading - true if the upload is in progress
+- error - the error from the last failed upload, if any
+
+This needs --vfs-cache-mode writes or full.
+`,
+	})
+	rc.Add(rc.Call{
+		Path: "vfs/queue-retry",
+		Fn: func(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+			wb, err := vfs.rcWriteBack()
+			if err != nil {
+				return nil, err
+			}
+			name, err := in.GetString("file")
+			if rc.NotErrParamNotFound(err) {
+				return nil, err
+			}
+			name = strings.Trim(name, "/")
+			if !wb.retry(name) && name != "" {
+				return nil, errors.Errorf("%q is not waiting to be uploaded", name)
+			}
+			return nil, nil
+		},
+		Title: "Upload files waiting in the cache now.",
+		Help: `
+This uploads the file passed in as file=path now, rather than waiting
+for the --vfs-write-back delay or the next retry of a failed upload.
+
+    rclone rc vfs/queue-retry file=dir/file.txt
+
+If no file is passed in then all the files waiting are uploaded.
+
+This needs --vfs-cache-mode writes or full.
+`,
+	})
+	rc.Add(rc.Call{
+		Path: "vfs/queue-cancel",
+		Fn: func(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+			wb, err := vfs.rcWriteBack()
+			if err != nil {
+				return nil, err
+			}
+			name, err := in.GetString("file")
+			if err != nil {
+				return nil, err
+			}
+			name = strings.Trim(name, "/")
+			if !wb.cancel(name) {
+				return nil, errors.Errorf("%q is not waiting to be uploaded", name)
+			}
+			return nil, nil
+		},
+		Title: "Cancel the upload of a file waiting in the cache.",
+		Help: `
+This stops the file passed in as file=path being uploaded, stopping the
+upload if it is in progress.
+
+    rclone rc vfs/queue-cancel file=dir/file.txt
+
+The changes to the file which haven't been uploaded are discarded when
+the file is next opened.
+
+This needs --vfs-cache-mode writes or full.
 `,
 	})
 	rc.Add(rc.Call{
@@ -198,6 +481,24 @@ used remote.
 	})
 }
 
+// rcWriteBack returns the write back queue or an error if there isn't
+// one
+func (vfs *VFS) rcWriteBack() (*writeBack, error) {
+	if vfs.cache == nil {
+		return nil, errors.New("the write back queue needs --vfs-cache-mode writes or full")
+	}
+	return vfs.cache.writeBack, nil
+}
+
+// rcPins returns the pinner for the rc or an error if pinning isn't
+// possible
+func (vfs *VFS) rcPins() (*pinner, error) {
+	if vfs.cache == nil || vfs.Opt.CacheMode < CacheModeFull {
+		return nil, errors.New("pinning needs --vfs-cache-mode full")
+	}
+	return vfs.cache.pins, nil
+}
+
 func rcPollFunc(vfs *VFS) (rcPollFunc rc.Func) {
 	getDuration := func(k string, v interface{}) (time.Duration, error) {
 		s, ok := v.(string)
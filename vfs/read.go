@@ -70,9 +70,9 @@ func (fh *ReadFileHandle) openPending() (err error) {
 	if err != nil {
 		return err
 	}
-	fh.r = accounting.NewAccount(r, o).WithBuffer() // account the transfer
+	fh.r = accounting.Stats(context.TODO()).NewAccount(r, o).WithBuffer() // account the transfer
 	fh.opened = true
-	accounting.Stats.Transferring(o.Remote())
+	accounting.Stats(context.TODO()).Transferring(o.Remote())
 	return nil
 }
 
@@ -347,7 +347,7 @@ func (fh *ReadFileHandle) close() error {
 	fh.closed = true
 
 	if fh.opened {
-		accounting.Stats.DoneTransferring(fh.remote, true)
+		accounting.Stats(context.TODO()).DoneTransferring(fh.remote, true)
 		// Close first so that we have hashes
 		err := fh.r.Close()
 		if err != nil {
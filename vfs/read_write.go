@@ -10,9 +10,7 @@ import (
 	"sync"
 
 	"github.com/ncw/rclone/fs"
-	"github.com/ncw/rclone/fs/accounting"
 	"github.com/ncw/rclone/fs/log"
-	"github.com/ncw/rclone/fs/operations"
 	"github.com/ncw/rclone/lib/file"
 	"github.com/pkg/errors"
 )
@@ -29,10 +27,11 @@ type RWFileHandle struct {
 	file        *File
 	d           *Dir
 	opened      bool
-	flags       int    // open flags
-	osPath      string // path to the file in the cache
-	writeCalled bool   // if any Write() methods have been called
-	changed     bool   // file contents was changed in any other way
+	flags       int        // open flags
+	osPath      string     // path to the file in the cache
+	item        *cacheItem // the cache item for the file
+	writeCalled bool       // if any Write() methods have been called
+	changed     bool       // file contents was changed in any other way
 }
 
 // Check interfaces
@@ -60,6 +59,7 @@ func newRWFileHandle(d *Dir, f *File, remote string, flags int) (fh *RWFileHandl
 
 	// mark the file as open in the cache - must be done before the mkdir
 	fh.d.vfs.cache.open(fh.remote)
+	fh.item = fh.d.vfs.cache.get(fh.remote)
 
 	// Make a place for the file
 	fh.osPath, err = d.vfs.cache.mkdir(remote)
@@ -84,18 +84,6 @@ func newRWFileHandle(d *Dir, f *File, remote string, flags int) (fh *RWFileHandl
 	return fh, nil
 }
 
-// copy an object to or from the remote while accounting for it
-func copyObj(f fs.Fs, dst fs.Object, remote string, src fs.Object) (newDst fs.Object, err error) {
-	if operations.NeedTransfer(context.TODO(), dst, src) {
-		accounting.Stats.Transferring(src.Remote())
-		newDst, err = operations.Copy(context.TODO(), f, dst, remote, src)
-		accounting.Stats.DoneTransferring(src.Remote(), err == nil)
-	} else {
-		newDst = dst
-	}
-	return newDst, err
-}
-
 // openPending opens the file if there is a pending open
 //
 // call with the lock held
@@ -113,47 +101,26 @@ func (fh *RWFileHandle) openPending(truncate bool) (err error) {
 	cacheFileOpenFlags := fh.flags
 	// if not truncating the file, need to read it first
 	if fh.flags&os.O_TRUNC == 0 && !truncate {
-		// If the remote object exists AND its cached file exists locally AND there are no
-		// other RW handles with it open, then attempt to update it.
-		if o != nil && fh.file.rwOpens() == 0 {
-			cacheObj, err := fh.d.vfs.cache.f.NewObject(context.TODO(), fh.remote)
-			if err == nil && cacheObj != nil {
-				_, err = copyObj(fh.d.vfs.cache.f, cacheObj, fh.remote, o)
-				if err != nil {
-					return errors.Wrap(err, "open RW handle failed to update cached file")
-				}
-			}
+		// Make the cache file ready to read. If the remote object
+		// exists this makes a sparse file which is filled in
+		// as it is read. The cached data is only checked against
+		// the remote object if no other RW handles have it open.
+		err = fh.item.open(context.TODO(), o, fh.osPath, false, fh.file.rwOpens() == 0)
+		if err != nil {
+			return errors.Wrap(err, "open RW handle failed to cache file")
 		}
 
 		// try to open a exising cache file
 		fd, err = file.OpenFile(fh.osPath, cacheFileOpenFlags&^os.O_CREATE, 0600)
 		if os.IsNotExist(err) {
-			// cache file does not exist, so need to fetch it if we have an object to fetch
-			// it from
-			if o != nil {
-				_, err = copyObj(fh.d.vfs.cache.f, nil, fh.remote, o)
-				if err != nil {
-					cause := errors.Cause(err)
-					if cause != fs.ErrorObjectNotFound && cause != fs.ErrorDirNotFound {
-						// return any non NotFound errors
-						return errors.Wrap(err, "open RW handle failed to cache file")
-					}
-					// continue here with err=fs.Error{Object,Dir}NotFound
-				}
-			}
-			// if err == nil, then we have cached the file successfully, otherwise err is
-			// indicating some kind of non existent file/directory either
-			// os.IsNotExist(err) or fs.Error{Object,Dir}NotFound
-			if err != nil {
-				if fh.flags&os.O_CREATE != 0 {
-					// if the object wasn't found AND O_CREATE is set then
-					// ignore error as we are about to create the file
-					fh.file.setSize(0)
-					fh.changed = true
-				} else {
-					return errors.Wrap(err, "open RW handle failed to cache file")
-				}
+			// there is no object and no cache file
+			if fh.flags&os.O_CREATE == 0 {
+				return errors.Wrap(err, "open RW handle failed to cache file")
 			}
+			// if O_CREATE is set then ignore error as we are
+			// about to create the file
+			fh.file.setSize(0)
+			fh.changed = true
 		} else if err != nil {
 			return errors.Wrap(err, "cache open file failed")
 		} else {
@@ -183,6 +150,10 @@ func (fh *RWFileHandle) openPending(truncate bool) (err error) {
 				}
 			}
 		}
+		err = fh.item.open(context.TODO(), o, fh.osPath, true, true)
+		if err != nil {
+			return errors.Wrap(err, "cache open failed to reset cache file")
+		}
 	}
 
 	if fd == nil {
@@ -252,6 +223,9 @@ func (fh *RWFileHandle) close() (err error) {
 	defer func() {
 		if fh.opened {
 			fh.file.delRWOpen()
+			if fh.file.rwOpens() == 0 {
+				fh.item.closeDownloader()
+			}
 		}
 		fh.d.vfs.cache.close(fh.remote)
 	}()
@@ -286,32 +260,36 @@ func (fh *RWFileHandle) close() (err error) {
 		}
 	}
 
-	// Close the underlying file
+	// Close the underlying file and save which parts of it are
+	// present
 	if fh.opened {
 		err = fh.File.Close()
 		if err != nil {
 			err = errors.Wrap(err, "failed to close cache file")
 			return err
 		}
+		err = fh.item.save()
+		if err != nil {
+			fs.Errorf(fh.logPrefix(), "%v", err)
+		}
 	}
 
 	if isCopied {
-		// Transfer the temp file to the remote
-		cacheObj, err := fh.d.vfs.cache.f.NewObject(context.TODO(), fh.remote)
+		// Mark the file as needing upload so it is uploaded
+		// even if rclone is restarted before it is done
+		err = fh.item.setDirty(true)
 		if err != nil {
-			err = errors.Wrap(err, "failed to find cache file")
 			fs.Errorf(fh.logPrefix(), "%v", err)
 			return err
 		}
-
-		o, err := copyObj(fh.d.vfs.f, fh.file.getObject(), fh.remote, cacheObj)
+		fh.file.setPendingWriteBack(true)
+		// Transfer the cache file to the remote, waiting for it
+		// to complete if there is no write back delay
+		err = fh.d.vfs.cache.writeBack.add(fh.remote, fh.file, fh.d.vfs.Opt.WriteBack <= 0)
 		if err != nil {
-			err = errors.Wrap(err, "failed to transfer file from cache to remote")
 			fs.Errorf(fh.logPrefix(), "%v", err)
 			return err
 		}
-		fh.file.setObject(o)
-		fs.Debugf(o, "transferred to remote")
 	}
 
 	return nil
@@ -401,8 +379,10 @@ func (fh *RWFileHandle) Stat() (os.FileInfo, error) {
 }
 
 // readFn is a general purpose read function - pass in a closure to do
-// the actual read
-func (fh *RWFileHandle) readFn(read func() (int, error)) (n int, err error) {
+// the actual read along with the offset (or -1 for the current file
+// position) and size of the data it will read so it can be fetched
+// into the cache
+func (fh *RWFileHandle) readFn(off int64, size int, read func() (int, error)) (n int, err error) {
 	fh.mu.Lock()
 	defer fh.mu.Unlock()
 	if fh.closed {
@@ -414,19 +394,47 @@ func (fh *RWFileHandle) readFn(read func() (int, error)) (n int, err error) {
 	if err = fh.openPending(false); err != nil {
 		return n, err
 	}
+	if off < 0 {
+		off, err = fh.File.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return n, err
+		}
+	}
+	err = fh.item.ensure(context.TODO(), fh.file.getObject(), fh.osPath, Range{Pos: off, Size: int64(size)})
+	if err != nil {
+		return n, errors.Wrap(err, "failed to read data into cache")
+	}
 	return read()
 }
 
+// fetchAll makes sure all of the file is in the cache without
+// reading it
+func (fh *RWFileHandle) fetchAll(ctx context.Context) (err error) {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	if fh.closed {
+		return ECLOSED
+	}
+	if err = fh.openPending(false); err != nil {
+		return err
+	}
+	err = fh.item.ensureAll(ctx, fh.file.getObject(), fh.osPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to read data into cache")
+	}
+	return nil
+}
+
 // Read bytes from the file
 func (fh *RWFileHandle) Read(b []byte) (n int, err error) {
-	return fh.readFn(func() (int, error) {
+	return fh.readFn(-1, len(b), func() (int, error) {
 		return fh.File.Read(b)
 	})
 }
 
 // ReadAt bytes from the file at off
 func (fh *RWFileHandle) ReadAt(b []byte, off int64) (n int, err error) {
-	return fh.readFn(func() (int, error) {
+	return fh.readFn(off, len(b), func() (int, error) {
 		return fh.File.ReadAt(b, off)
 	})
 }
@@ -462,6 +470,11 @@ func (fh *RWFileHandle) writeFn(write func() error) (err error) {
 	if err = fh.openPending(false); err != nil {
 		return err
 	}
+	// The whole file will be uploaded so it must all be present
+	err = fh.item.ensureAll(context.TODO(), fh.file.getObject(), fh.osPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to read data into cache")
+	}
 	fh.writeCalled = true
 	err = write()
 	if err != nil {
@@ -472,6 +485,7 @@ func (fh *RWFileHandle) writeFn(write func() error) (err error) {
 		return errors.Wrap(err, "failed to stat cache file")
 	}
 	fh.file.setSize(fi.Size())
+	fh.item.setPresent(fi.Size())
 	return nil
 }
 
@@ -512,9 +526,19 @@ func (fh *RWFileHandle) Truncate(size int64) (err error) {
 	if err = fh.openPending(size == 0); err != nil {
 		return err
 	}
+	// The part of the file which is kept must all be present
+	err = fh.item.ensure(context.TODO(), fh.file.getObject(), fh.osPath, Range{Pos: 0, Size: size})
+	if err != nil {
+		return errors.Wrap(err, "failed to read data into cache")
+	}
 	fh.changed = true
 	fh.file.setSize(size)
-	return fh.File.Truncate(size)
+	err = fh.File.Truncate(size)
+	if err != nil {
+		return err
+	}
+	fh.item.setPresent(size)
+	return nil
 }
 
 // Sync commits the current contents of the file to stable storage. Typically,
@@ -221,6 +221,103 @@ func TestRWFileHandleReadAt(t *testing.T) {
 	assert.Equal(t, ECLOSED, err)
 }
 
+func TestRWFileHandleReadSparse(t *testing.T) {
+	r := fstest.NewRun(t)
+	vfs, fh := rwHandleCreateReadOnly(t, r)
+	defer cleanup(t, r, vfs)
+
+	// read from the middle - only that part should be fetched
+	buf := make([]byte, 4)
+	n, err := fh.ReadAt(buf, 4)
+	require.NoError(t, err)
+	assert.Equal(t, "4567", string(buf[:n]))
+	assert.Equal(t, Ranges{{Pos: 4, Size: 4}}, fh.item.info.Rs)
+
+	// the cache file is the full size
+	assert.Equal(t, int64(16), fh.Size())
+
+	// read overlapping the present part
+	buf = make([]byte, 8)
+	n, err = fh.ReadAt(buf, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "23456789", string(buf[:n]))
+	assert.Equal(t, Ranges{{Pos: 2, Size: 8}}, fh.item.info.Rs)
+	assert.NoError(t, fh.Close())
+
+	// reopen and check the cached data is kept
+	h, err := vfs.OpenFile("dir/file1", os.O_RDONLY, 0777)
+	require.NoError(t, err)
+	fh = h.(*RWFileHandle)
+	assert.Equal(t, "0123", rwReadString(t, fh, 4))
+	assert.Equal(t, Ranges{{Pos: 0, Size: 10}}, fh.item.info.Rs)
+	assert.NoError(t, fh.Close())
+
+	// change the file on the remote and check the cached data is
+	// discarded
+	r.WriteObject(context.Background(), "dir/file1", "ABCDEFGHIJKLMNOPQRS", t2)
+	node, err := vfs.Stat("dir/file1")
+	require.NoError(t, err)
+	o, err := r.Fremote.NewObject(context.Background(), "dir/file1")
+	require.NoError(t, err)
+	node.(*File).setObject(o)
+	h, err = vfs.OpenFile("dir/file1", os.O_RDONLY, 0777)
+	require.NoError(t, err)
+	fh = h.(*RWFileHandle)
+	buf = make([]byte, 2)
+	n, err = fh.ReadAt(buf, 17)
+	require.NoError(t, err)
+	assert.Equal(t, "RS", string(buf[:n]))
+	assert.Equal(t, Ranges{{Pos: 17, Size: 2}}, fh.item.info.Rs)
+	assert.NoError(t, fh.Close())
+}
+
+func TestRWFileHandleCacheList(t *testing.T) {
+	r := fstest.NewRun(t)
+	vfs, fh := rwHandleCreateReadOnly(t, r)
+
+	// a miss then a hit on the same part of the file
+	buf := make([]byte, 4)
+	_, err := fh.ReadAt(buf, 4)
+	require.NoError(t, err)
+	_, err = fh.ReadAt(buf, 4)
+	require.NoError(t, err)
+
+	files, err := vfs.cache.list()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(files))
+	file := files[0]
+	assert.Equal(t, "dir/file1", file.Name)
+	assert.Equal(t, int64(16), file.Size)
+	assert.Equal(t, int64(4), file.BytesCached)
+	assert.Equal(t, 1, file.Opens)
+	assert.False(t, file.Dirty)
+	assert.False(t, file.Pinned)
+	assert.Equal(t, int64(1), file.Hits)
+	assert.Equal(t, int64(1), file.Misses)
+	assert.Contains(t, file.Fingerprint, "16,")
+	assert.NoError(t, fh.Close())
+
+	stats, err := vfs.cache.stats()
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats["files"])
+	assert.Equal(t, int64(4), stats["bytesUsed"])
+	assert.Equal(t, int64(1), stats["hits"])
+	assert.Equal(t, int64(1), stats["misses"])
+	assert.Equal(t, 0, stats["dirty"])
+
+	// the metadata survives a restart but the counters don't
+	vfs.Shutdown()
+	opt := vfs.Opt
+	vfs = New(r.Fremote, &opt)
+	defer cleanup(t, r, vfs)
+	files, err = vfs.cache.list()
+	require.NoError(t, err)
+	require.Equal(t, 1, len(files))
+	assert.Equal(t, int64(4), files[0].BytesCached)
+	assert.Equal(t, file.Fingerprint, files[0].Fingerprint)
+	assert.Equal(t, int64(0), files[0].Hits)
+}
+
 func TestRWFileHandleFlushRead(t *testing.T) {
 	r := fstest.NewRun(t)
 	vfs, fh := rwHandleCreateReadOnly(t, r)
@@ -0,0 +1,125 @@
+// Symlinks stored on the remote as files ending in fs.LinkSuffix
+
+package vfs
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/operations"
+	"github.com/pkg/errors"
+)
+
+// maxLinkSize is the longest symlink target which will be read
+const maxLinkSize = 64 * 1024
+
+// IsSymlink returns true if the file is a symlink - satisfies Node
+// interface
+func (f *File) IsSymlink() bool {
+	return f.link
+}
+
+// IsSymlink returns false for Dir - satisfies Node interface
+func (d *Dir) IsSymlink() bool {
+	return false
+}
+
+// Readlink returns the target of the symlink
+//
+// It returns EINVAL if the file isn't a symlink.
+func (f *File) Readlink() (target string, err error) {
+	if !f.link {
+		return "", EINVAL
+	}
+	ctx := context.TODO()
+	o, err := f.waitForValidObject()
+	if err != nil {
+		return "", err
+	}
+	o, err = resolveObject(ctx, o)
+	if err != nil {
+		return "", err
+	}
+	in, err := o.Open(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open symlink")
+	}
+	defer fs.CheckClose(in, &err)
+	data, err := ioutil.ReadAll(io.LimitReader(in, maxLinkSize+1))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read symlink")
+	}
+	if len(data) > maxLinkSize {
+		return "", errors.New("symlink target too long")
+	}
+	return string(data), nil
+}
+
+// Symlink makes a symlink called name in the directory pointing to
+// target
+//
+// It is stored on the remote as a file whose name ends in
+// fs.LinkSuffix containing the target, so it needs --vfs-links.
+func (d *Dir) Symlink(target, name string) (*File, error) {
+	if d.vfs.Opt.ReadOnly {
+		return nil, EROFS
+	}
+	if !d.vfs.Opt.Links {
+		return nil, ENOSYS
+	}
+	_, err := d.stat(name)
+	switch err {
+	case ENOENT:
+		// not found, carry on
+	case nil:
+		return nil, EEXIST
+	default:
+		fs.Errorf(d, "Dir.Symlink failed to read directory: %v", err)
+		return nil, err
+	}
+	remote := path.Join(d.path, name) + fs.LinkSuffix
+	in := ioutil.NopCloser(strings.NewReader(target))
+	o, err := operations.Rcat(context.TODO(), d.f, remote, in, time.Now())
+	if err == nil && o == nil {
+		// o can be nil here for example if --dry-run
+		err = errors.New("nil object returned")
+	}
+	if err != nil {
+		fs.Errorf(d, "Dir.Symlink failed to create symlink: %v", err)
+		return nil, err
+	}
+	file := newFile(d, o, name)
+	file.link = true
+	d.addObject(file)
+	return file, nil
+}
+
+// Symlink makes a symlink called name pointing to target
+func (vfs *VFS) Symlink(target, name string) error {
+	dir, leaf, err := vfs.StatParent(name)
+	if err != nil {
+		return err
+	}
+	_, err = dir.Symlink(target, leaf)
+	return err
+}
+
+// Readlink returns the target of the symlink called name
+//
+// It returns EINVAL if name isn't a symlink.
+func (vfs *VFS) Readlink(name string) (string, error) {
+	node, err := vfs.Stat(name)
+	if err != nil {
+		return "", err
+	}
+	file, ok := node.(*File)
+	if !ok {
+		return "", EINVAL
+	}
+	return file.Readlink()
+}
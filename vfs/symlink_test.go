@@ -0,0 +1,110 @@
+package vfs
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSymlink(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	ctx := context.Background()
+	file1 := r.WriteObject(ctx, "dir/file1", "file1 contents", t1)
+	link1 := r.WriteObject(ctx, "dir/link1"+fs.LinkSuffix, "file1", t2)
+
+	// Without --vfs-links the links are regular files
+	vfs := New(r.Fremote, nil)
+	node, err := vfs.Stat("dir")
+	require.NoError(t, err)
+	checkListing(t, node.(*Dir), []string{"file1,14,false", "link1.rclonelink,5,false"})
+	assert.Equal(t, ENOSYS, vfs.Symlink("file1", "dir/link2"))
+	_, err = vfs.Readlink("dir/file1")
+	assert.Equal(t, EINVAL, err)
+
+	opt := DefaultOpt
+	opt.Links = true
+	vfs = New(r.Fremote, &opt)
+	node, err = vfs.Stat("dir")
+	require.NoError(t, err)
+	dir := node.(*Dir)
+	checkListing(t, dir, []string{"file1,14,false", "link1,5,false"})
+
+	node, err = vfs.Stat("dir/link1")
+	require.NoError(t, err)
+	assert.True(t, node.IsSymlink())
+	assert.Equal(t, os.ModeSymlink, node.Mode()&os.ModeType)
+	target, err := vfs.Readlink("dir/link1")
+	require.NoError(t, err)
+	assert.Equal(t, "file1", target)
+
+	// Symlinks can be read but not written
+	_, err = node.Open(os.O_WRONLY)
+	assert.Equal(t, EPERM, err)
+
+	// Make a new symlink
+	require.NoError(t, vfs.Symlink("../dir/file1", "dir/link2"))
+	assert.Equal(t, EEXIST, vfs.Symlink("file1", "dir/link2"))
+	target, err = vfs.Readlink("dir/link2")
+	require.NoError(t, err)
+	assert.Equal(t, "../dir/file1", target)
+	checkListing(t, dir, []string{"file1,14,false", "link1,5,false", "link2,12,false"})
+	link2 := fstest.NewItem("dir/link2"+fs.LinkSuffix, "../dir/file1", t3)
+	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{file1, link1, link2}, []string{"dir"}, fs.ModTimeNotSupported)
+
+	// Rename and remove it
+	require.NoError(t, vfs.Rename("dir/link2", "link3"))
+	target, err = vfs.Readlink("link3")
+	require.NoError(t, err)
+	assert.Equal(t, "../dir/file1", target)
+	link3 := fstest.NewItem("link3"+fs.LinkSuffix, "../dir/file1", t3)
+	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{file1, link1, link3}, []string{"dir"}, fs.ModTimeNotSupported)
+	node, err = vfs.Stat("link3")
+	require.NoError(t, err)
+	require.NoError(t, node.Remove())
+	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{file1, link1}, []string{"dir"}, fs.ModTimeNotSupported)
+}
+
+func TestSymlinkClash(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	ctx := context.Background()
+	file1 := r.WriteObject(ctx, "dir/file1", "file1 contents", t1)
+	clash := r.WriteObject(ctx, "dir/file1"+fs.LinkSuffix, "elsewhere", t2)
+	r.WriteObject(ctx, "dir/link2"+fs.LinkSuffix, "file1", t2)
+
+	opt := DefaultOpt
+	opt.Links = true
+	vfs := New(r.Fremote, &opt)
+
+	// A link with the same name as a file is shown under its own name
+	node, err := vfs.Stat("dir")
+	require.NoError(t, err)
+	dir := node.(*Dir)
+	checkListing(t, dir, []string{"file1,14,false", "file1.rclonelink,9,false", "link2,5,false"})
+	node, err = vfs.Stat("dir/file1")
+	require.NoError(t, err)
+	assert.False(t, node.IsSymlink())
+
+	// Creating a file over a link removes the link object
+	fh, err := vfs.OpenFile("dir/link2", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+	assert.Equal(t, EPERM, err, "links can't be written")
+	assert.Nil(t, fh)
+	file, err := dir.Create("link2", os.O_WRONLY|os.O_CREATE)
+	require.NoError(t, err)
+	fh, err = file.Open(os.O_WRONLY | os.O_CREATE)
+	require.NoError(t, err)
+	_, err = fh.Write([]byte("not a link"))
+	require.NoError(t, err)
+	require.NoError(t, fh.Close())
+	node, err = vfs.Stat("dir/link2")
+	require.NoError(t, err)
+	assert.False(t, node.IsSymlink())
+	newFile := fstest.NewItem("dir/link2", "not a link", t3)
+	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{file1, clash, newFile}, []string{"dir"}, fs.ModTimeNotSupported)
+}
@@ -51,6 +51,8 @@ var DefaultOpt = Options{
 	ChunkSize:         128 * fs.MebiByte,
 	ChunkSizeLimit:    -1,
 	CacheMaxSize:      -1,
+	ReadAhead:         0,
+	WriteBack:         0,
 }
 
 // Node represents either a directory (*Dir) or a file (*File)
@@ -67,6 +69,14 @@ type Node interface {
 	Open(flags int) (Handle, error)
 	Truncate(size int64) error
 	Path() string
+	Owner() (uid, gid uint32)
+	Chmod(mode os.FileMode) error
+	Chown(uid, gid int) error
+	ListXattr() ([]string, error)
+	GetXattr(name string) (string, error)
+	SetXattr(name, value string) error
+	RemoveXattr(name string) error
+	IsSymlink() bool
 }
 
 // Check interfaces
@@ -169,15 +179,29 @@ var (
 
 // VFS represents the top level filing system
 type VFS struct {
-	f         fs.Fs
-	root      *Dir
-	Opt       Options
-	cache     *cache
-	cancel    context.CancelFunc
-	usageMu   sync.Mutex
-	usageTime time.Time
-	usage     *fs.Usage
-	pollChan  chan time.Duration
+	f          fs.Fs
+	root       *Dir
+	Opt        Options
+	cache      *cache
+	dirCache   *dirCache
+	cancel     context.CancelFunc
+	usageMu    sync.Mutex
+	usageTime  time.Time
+	usage      *fs.Usage
+	pollChan   chan time.Duration
+	pollCancel context.CancelFunc // stops the change notification
+}
+
+// active holds the caches which haven't been shut down
+//
+// The background goroutines of a cache refer to it until it is shut
+// down, so this doesn't keep anything alive which would otherwise be
+// freed.
+var active = struct {
+	mu     sync.Mutex
+	caches map[*cache]struct{}
+}{
+	caches: make(map[*cache]struct{}),
 }
 
 // Options is options for creating the vfs
@@ -187,6 +211,7 @@ type Options struct {
 	ReadOnly          bool          // if set VFS is read only
 	NoModTime         bool          // don't read mod times for files
 	DirCacheTime      time.Duration // how long to consider directory listing cache valid
+	DirCachePersist   bool          // save directory listings to disk for use on restart
 	PollInterval      time.Duration
 	Umask             int
 	UID               uint32
@@ -199,6 +224,11 @@ type Options struct {
 	CacheMaxAge       time.Duration
 	CacheMaxSize      fs.SizeSuffix
 	CachePollInterval time.Duration
+	ReadAhead         fs.SizeSuffix // bytes to read ahead in cache mode "full"
+	WriteBack         time.Duration // time to wait before uploading modified files, 0 to upload on close
+	Pin               []string      // paths to keep downloaded in cache mode "full"
+	Metadata          bool          // read permissions and owner from, and write them and xattrs to, object metadata
+	Links             bool          // show files ending in fs.LinkSuffix as symlinks
 }
 
 // New creates a new VFS and root directory.  If opt is nil, then
@@ -223,13 +253,25 @@ func New(f fs.Fs, opt *Options) *VFS {
 	// Make sure directories are returned as directories
 	vfs.Opt.DirPerms |= os.ModeDir
 
+	// Open the persistent directory cache
+	if vfs.Opt.DirCachePersist {
+		dirCache, err := newDirCache(f, &vfs.Opt)
+		if err != nil {
+			fs.Errorf(f, "Failed to open persistent directory cache - disabling: %v", err)
+		} else {
+			vfs.dirCache = dirCache
+		}
+	}
+
 	// Create root directory
 	vfs.root = newDir(vfs, f, nil, fsDir)
 
 	// Start polling function
 	if do := vfs.f.Features().ChangeNotify; do != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		vfs.pollCancel = cancel
 		vfs.pollChan = make(chan time.Duration)
-		do(context.TODO(), vfs.root.ForgetPath, vfs.pollChan)
+		do(ctx, vfs.notify, vfs.pollChan)
 		vfs.pollChan <- vfs.Opt.PollInterval
 	} else {
 		fs.Infof(f, "poll-interval is not supported by this remote")
@@ -239,12 +281,13 @@ func New(f fs.Fs, opt *Options) *VFS {
 
 	// add the remote control
 	vfs.addRC()
+
 	return vfs
 }
 
 // SetCacheMode change the cache mode
 func (vfs *VFS) SetCacheMode(cacheMode CacheMode) {
-	vfs.Shutdown()
+	vfs.shutdownCache()
 	vfs.cache = nil
 	if vfs.Opt.CacheMode > CacheModeOff {
 		ctx, cancel := context.WithCancel(context.Background())
@@ -257,11 +300,50 @@ func (vfs *VFS) SetCacheMode(cacheMode CacheMode) {
 		}
 		vfs.cancel = cancel
 		vfs.cache = cache
+		active.mu.Lock()
+		active.caches[cache] = struct{}{}
+		active.mu.Unlock()
+		// Upload any files which weren't uploaded last time
+		cache.writeBack.forget = func(name string) {
+			vfs.root.ForgetPath(findParent(name), fs.EntryDirectory)
+		}
+		err = cache.writeBack.queueDirty()
+		if err != nil {
+			fs.Errorf(nil, "Failed to queue files in the vfs cache for upload: %v", err)
+		}
+		// Download any pinned files
+		if vfs.Opt.CacheMode >= CacheModeFull {
+			cache.pins.start(vfs, vfs.Opt.Pin)
+		} else if len(vfs.Opt.Pin) > 0 {
+			fs.Errorf(nil, "Ignoring --vfs-pin as it needs --vfs-cache-mode full")
+		}
+	}
+}
+
+// notify is called when the remote reports that path has changed
+func (vfs *VFS) notify(path string, entryType fs.EntryType) {
+	vfs.root.ForgetPath(path, entryType)
+	if vfs.cache != nil {
+		vfs.cache.pins.changed(path)
 	}
 }
 
 // Shutdown stops any background go-routines
 func (vfs *VFS) Shutdown() {
+	vfs.shutdownCache()
+	if vfs.pollCancel != nil {
+		vfs.pollCancel()
+		vfs.pollCancel = nil
+	}
+}
+
+// shutdownCache stops the background go-routines of the cache
+func (vfs *VFS) shutdownCache() {
+	if vfs.cache != nil {
+		active.mu.Lock()
+		delete(active.caches, vfs.cache)
+		active.mu.Unlock()
+	}
 	if vfs.cancel != nil {
 		vfs.cancel()
 		vfs.cancel = nil
@@ -307,6 +389,10 @@ func (vfs *VFS) WaitForWriters(timeout time.Duration) {
 				}
 			}
 		})
+		// Count the files waiting to be uploaded as writers
+		if vfs.cache != nil {
+			writers += vfs.cache.writeBack.pending()
+		}
 		if writers == 0 {
 			return
 		}
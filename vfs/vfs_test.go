@@ -115,6 +115,54 @@ func TestVFSNew(t *testing.T) {
 	assert.Equal(t, os.FileMode(0664), vfs.Opt.FilePerms)
 }
 
+// TestVFSMetrics checks the cache size of the VFS is in the metrics
+func TestVFSMetrics(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	// Ignore the caches left over from other tests on the same remote
+	active.mu.Lock()
+	oldCaches := active.caches
+	active.caches = make(map[*cache]struct{})
+	active.mu.Unlock()
+	defer func() {
+		active.mu.Lock()
+		active.caches = oldCaches
+		active.mu.Unlock()
+	}()
+
+	// A VFS without a cache isn't remembered
+	New(r.Fremote, &DefaultOpt)
+	active.mu.Lock()
+	assert.Equal(t, 0, len(active.caches))
+	active.mu.Unlock()
+
+	opt := DefaultOpt
+	opt.CacheMode = CacheModeWrites
+	vfs := New(r.Fremote, &opt)
+	defer vfs.Shutdown()
+
+	remote := vfs.f.Name() + ":" + vfs.f.Root()
+	cacheBytes := func() (value float64, found bool) {
+		for _, m := range metrics() {
+			if m.Name == "rclone_vfs_cache_bytes" && m.Labels["remote"] == remote {
+				return m.Value, true
+			}
+		}
+		return 0, false
+	}
+
+	writeBackWriteFile(t, vfs, "file1", "hello")
+	require.NoError(t, vfs.cache.updateStats())
+	value, found := cacheBytes()
+	assert.True(t, found)
+	assert.Equal(t, 5.0, value)
+
+	vfs.Shutdown()
+	_, found = cacheBytes()
+	assert.False(t, found)
+}
+
 // TestRoot checks root directory is present and correct
 func TestVFSRoot(t *testing.T) {
 	r := fstest.NewRun(t)
@@ -0,0 +1,408 @@
+// Write back of files from the cache to the remote
+
+package vfs
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/accounting"
+	"github.com/ncw/rclone/fs/operations"
+	"github.com/pkg/errors"
+)
+
+const (
+	writeBackMinBackoff = time.Second     // delay before the first retry of a failed upload
+	writeBackMaxBackoff = 5 * time.Minute // longest delay between retries of a failed upload
+)
+
+// errWriteBackCancelled is returned to anyone waiting for an upload
+// which is cancelled
+var errWriteBackCancelled = errors.New("vfs cache: upload cancelled")
+
+// writeBack uploads files which have been modified in the cache to
+// the remote once they have been closed for Opt.WriteBack.
+//
+// Each file is only queued once - if it is modified again before it
+// is uploaded the upload is delayed (or restarted if in progress).
+type writeBack struct {
+	c       *cache
+	ctx     context.Context
+	mu      sync.Mutex                // protects the following variables
+	items   map[string]*writeBackItem // files waiting to be uploaded by name
+	timer   *time.Timer               // fires when the next item is due
+	running int                       // number of uploads in progress
+	forget  func(name string)         // called after a file not known to the VFS is uploaded
+}
+
+// writeBackItem is a file waiting to be uploaded
+type writeBackItem struct {
+	name      string             // remote path of the file
+	file      *File              // the file or nil if it was queued on startup
+	expiry    time.Time          // when the upload should be tried
+	tries     int                // number of failed uploads
+	lastErr   error              // error from the last failed upload
+	uploading bool               // set if the upload is in progress
+	requeue   bool               // set if the file was modified while uploading
+	cancel    context.CancelFunc // cancels the upload in progress
+	waiters   []chan error       // notified when the next upload attempt finishes
+}
+
+// newWriteBack returns a new write back queue for the cache
+//
+// Uploads are cancelled when the context is cancelled.
+func newWriteBack(ctx context.Context, c *cache) *writeBack {
+	return &writeBack{
+		c:      c,
+		ctx:    ctx,
+		items:  make(map[string]*writeBackItem),
+		forget: func(string) {},
+	}
+}
+
+// add queues the file called name for upload after the write back
+// delay, or restarts the delay if it is already queued.
+//
+// file may be nil if the file isn't known to the VFS.
+//
+// If wait is set it uploads the file immediately and waits for the
+// result of the upload.
+func (wb *writeBack) add(name string, file *File, wait bool) error {
+	wb.mu.Lock()
+	item := wb.items[name]
+	if item == nil {
+		item = &writeBackItem{name: name}
+		wb.items[name] = item
+		// hold the file open in the cache so it isn't evicted
+		wb.c.open(name)
+	}
+	if file != nil {
+		item.file = file
+	}
+	item.tries = 0
+	item.lastErr = nil
+	if item.uploading {
+		// the data being uploaded is out of date
+		fs.Debugf(name, "vfs cache: restarting upload as file modified")
+		item.requeue = true
+		item.cancel()
+	}
+	var result chan error
+	if wait && wb.ctx.Err() != nil {
+		// shutting down - the file will be queued again on restart
+		wb.mu.Unlock()
+		return errors.New("vfs cache: can't upload while shutting down")
+	}
+	if wait {
+		item.expiry = time.Now()
+		result = make(chan error, 1)
+		item.waiters = append(item.waiters, result)
+	} else {
+		item.expiry = time.Now().Add(wb.c.opt.WriteBack)
+		fs.Debugf(name, "vfs cache: queuing for upload in %v", wb.c.opt.WriteBack)
+	}
+	wb.mu.Unlock()
+	wb.process()
+	if !wait {
+		return nil
+	}
+	return <-result
+}
+
+// process starts the uploads of any items which are due and sets the
+// timer for the next one
+func (wb *writeBack) process() {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	if wb.ctx.Err() != nil {
+		return
+	}
+	now := time.Now()
+	var next time.Time
+	for _, item := range wb.items {
+		if item.uploading {
+			continue
+		}
+		if !item.expiry.After(now) && wb.running < fs.Config.Transfers {
+			wb._upload(item)
+			continue
+		}
+		if next.IsZero() || item.expiry.Before(next) {
+			next = item.expiry
+		}
+	}
+	if wb.timer != nil {
+		wb.timer.Stop()
+		wb.timer = nil
+	}
+	if !next.IsZero() {
+		delay := next.Sub(now)
+		if delay < 0 {
+			// waiting for an upload slot - try again shortly
+			delay = 100 * time.Millisecond
+		}
+		wb.timer = time.AfterFunc(delay, wb.process)
+	}
+}
+
+// _upload starts the upload of item in the background
+//
+// call with wb.mu held
+func (wb *writeBack) _upload(item *writeBackItem) {
+	ctx, cancel := context.WithCancel(wb.ctx)
+	item.uploading = true
+	item.requeue = false
+	item.cancel = cancel
+	wb.running++
+	go func() {
+		o, err := wb.c.upload(ctx, item.name, item.file)
+		cancel()
+		wb.finished(item, o, err)
+	}()
+}
+
+// finished is called when the upload of item has finished
+func (wb *writeBack) finished(item *writeBackItem, o fs.Object, err error) {
+	wb.mu.Lock()
+	item.uploading = false
+	wb.running--
+	if wb.items[item.name] != item {
+		// cancelled - the waiters have been notified already
+		wb.mu.Unlock()
+		wb.process()
+		return
+	}
+	if item.requeue {
+		// modified while uploading so go round again
+		wb.mu.Unlock()
+		wb.process()
+		return
+	}
+	if err == nil {
+		// do this with the lock held so the file can't be queued
+		// again until it is finished
+		wb._uploaded(item, o)
+		delete(wb.items, item.name)
+	} else if wb.ctx.Err() != nil {
+		// shutting down - the file will be queued again on restart
+		err = errors.Wrap(err, "vfs cache: upload interrupted")
+	} else {
+		item.tries++
+		item.lastErr = err
+		backoff := writeBackMinBackoff << uint(item.tries-1)
+		if backoff > writeBackMaxBackoff || backoff <= 0 {
+			backoff = writeBackMaxBackoff
+		}
+		item.expiry = time.Now().Add(backoff)
+		fs.Errorf(item.name, "vfs cache: failed to upload try #%d, will retry in %v: %v", item.tries, backoff, err)
+	}
+	waiters := item.waiters
+	item.waiters = nil
+	wb.mu.Unlock()
+
+	for _, waiter := range waiters {
+		waiter <- err
+	}
+	wb.process()
+}
+
+// _uploaded is called when item has been uploaded successfully as o
+//
+// call with wb.mu held
+func (wb *writeBack) _uploaded(item *writeBackItem, o fs.Object) {
+	// The cached data is now a copy of the remote object
+	err := wb.c.get(item.name).setObject(context.TODO(), o)
+	if err != nil {
+		fs.Errorf(item.name, "%v", err)
+	}
+	if item.file != nil {
+		item.file.setObject(o)
+		item.file.setPendingWriteBack(false)
+	} else {
+		wb.forget(item.name)
+	}
+	wb.c.close(item.name)
+	fs.Debugf(o, "transferred to remote")
+}
+
+// cancel stops the upload of the file called name, discarding the
+// changes to it which haven't been uploaded yet.
+//
+// It returns false if the file wasn't queued.
+func (wb *writeBack) cancel(name string) bool {
+	wb.mu.Lock()
+	item := wb.items[name]
+	if item == nil {
+		wb.mu.Unlock()
+		return false
+	}
+	delete(wb.items, name)
+	if item.uploading {
+		item.cancel()
+	}
+	waiters := item.waiters
+	item.waiters = nil
+	wb.mu.Unlock()
+
+	fs.Infof(name, "vfs cache: cancelled upload")
+	err := wb.c.get(name).setDirty(false)
+	if err != nil {
+		fs.Errorf(name, "%v", err)
+	}
+	if item.file != nil {
+		item.file.setPendingWriteBack(false)
+	}
+	wb.c.close(name)
+	for _, waiter := range waiters {
+		waiter <- errWriteBackCancelled
+	}
+	return true
+}
+
+// retry makes the file called name, or all files if name is "", due
+// for upload now.
+//
+// It returns false if the file wasn't queued.
+func (wb *writeBack) retry(name string) bool {
+	wb.mu.Lock()
+	found := false
+	now := time.Now()
+	for _, item := range wb.items {
+		if name == "" || item.name == name {
+			item.expiry = now
+			found = true
+		}
+	}
+	wb.mu.Unlock()
+	wb.process()
+	return found
+}
+
+// pending returns the number of files waiting to be uploaded
+func (wb *writeBack) pending() int {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return len(wb.items)
+}
+
+// isQueued returns whether the file called name is waiting to be
+// uploaded or is being uploaded
+func (wb *writeBack) isQueued(name string) bool {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return wb.items[name] != nil
+}
+
+// counts returns the number of files waiting to be uploaded and the
+// number of those which are being uploaded
+func (wb *writeBack) counts() (queued, uploading int) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	return len(wb.items), wb.running
+}
+
+// writeBackStatus describes a file in the write back queue
+type writeBackStatus struct {
+	Name      string  `json:"name"`      // remote path of the file
+	Size      int64   `json:"size"`      // size of the file in the cache
+	Expiry    float64 `json:"expiry"`    // seconds until the upload is due, may be -ve
+	Tries     int     `json:"tries"`     // number of failed uploads
+	Uploading bool    `json:"uploading"` // set if the upload is in progress
+	Error     string  `json:"error"`     // error from the last failed upload, if any
+}
+
+// list returns the status of the files in the queue sorted by name
+func (wb *writeBack) list() []writeBackStatus {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	now := time.Now()
+	out := make([]writeBackStatus, 0, len(wb.items))
+	for _, item := range wb.items {
+		status := writeBackStatus{
+			Name:      item.name,
+			Size:      -1,
+			Expiry:    item.expiry.Sub(now).Seconds(),
+			Tries:     item.tries,
+			Uploading: item.uploading,
+		}
+		if fi, err := os.Stat(wb.c.toOSPath(item.name)); err == nil {
+			status.Size = fi.Size()
+		}
+		if item.lastErr != nil {
+			status.Error = item.lastErr.Error()
+		}
+		out = append(out, status)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// queueDirty queues the files in the cache which were waiting to be
+// uploaded when rclone last stopped
+func (wb *writeBack) queueDirty() error {
+	return filepath.Walk(wb.c.metaRoot, func(metaPath string, fi os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if fi.IsDir() || strings.HasSuffix(metaPath, ".tmp") {
+			return nil
+		}
+		data, err := ioutil.ReadFile(metaPath)
+		if err != nil {
+			return err
+		}
+		var info cacheItemInfo
+		if json.Unmarshal(data, &info) != nil || !info.Dirty {
+			return nil
+		}
+		name, err := filepath.Rel(wb.c.metaRoot, metaPath)
+		if err != nil {
+			return errors.Wrap(err, "filepath.Rel failed in queueDirty")
+		}
+		name = filepath.ToSlash(name)
+		if _, err := os.Stat(wb.c.toOSPath(name)); err != nil {
+			fs.Errorf(name, "vfs cache: can't upload as cached file is missing: %v", err)
+			return nil
+		}
+		fs.Infof(name, "vfs cache: queuing file which wasn't uploaded for upload")
+		return wb.add(name, nil, false)
+	})
+}
+
+// upload copies the file called name from the cache to the remote,
+// returning the new object. file is the VFS node for it or nil if
+// not known.
+func (c *cache) upload(ctx context.Context, name string, file *File) (o fs.Object, err error) {
+	cacheObj, err := c.f.NewObject(ctx, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find cache file")
+	}
+	var dst fs.Object
+	if file != nil {
+		// the object may have come from the persistent directory cache
+		dst, _ = resolveObject(ctx, file.getObject())
+	} else {
+		dst, _ = c.fremote.NewObject(ctx, name)
+	}
+	if operations.NeedTransfer(ctx, dst, cacheObj) {
+		accounting.Stats(ctx).Transferring(name)
+		o, err = operations.Copy(ctx, c.fremote, dst, name, cacheObj)
+		accounting.Stats(ctx).DoneTransferring(name, err == nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to transfer file from cache to remote")
+		}
+	} else {
+		o = dst
+	}
+	return o, nil
+}
@@ -0,0 +1,129 @@
+package vfs
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Create a VFS with the write back delay given
+func writeBackNewVFS(t *testing.T, r *fstest.Run, delay time.Duration) *VFS {
+	opt := DefaultOpt
+	opt.CacheMode = CacheModeWrites
+	opt.WriteBack = delay
+	return New(r.Fremote, &opt)
+}
+
+// write contents to name in the vfs and close it
+func writeBackWriteFile(t *testing.T, vfs *VFS, name, contents string) {
+	h, err := vfs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+	require.NoError(t, err)
+	_, err = h.WriteString(contents)
+	require.NoError(t, err)
+	require.NoError(t, h.Close())
+}
+
+// wait for the write back queue to be empty
+func writeBackWait(t *testing.T, vfs *VFS) {
+	for i := 0; i < 100 && vfs.cache.writeBack.pending() != 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.Equal(t, 0, vfs.cache.writeBack.pending())
+}
+
+// check the remote has just the items and dirs passed in, not
+// checking the modtime
+func writeBackCheckRemote(t *testing.T, r *fstest.Run, items []fstest.Item, dirs []string) {
+	fstest.CheckListingWithPrecision(t, r.Fremote, items, dirs, fs.ModTimeNotSupported)
+}
+
+func TestWriteBackDelay(t *testing.T) {
+	r := fstest.NewRun(t)
+	vfs := writeBackNewVFS(t, r, 500*time.Millisecond)
+	defer cleanup(t, r, vfs)
+
+	writeBackWriteFile(t, vfs, "file1", "hello")
+
+	// Not uploaded yet but visible in the VFS
+	queue := vfs.cache.writeBack.list()
+	require.Equal(t, 1, len(queue))
+	assert.Equal(t, "file1", queue[0].Name)
+	assert.Equal(t, int64(5), queue[0].Size)
+	assert.Equal(t, 0, queue[0].Tries)
+	writeBackCheckRemote(t, r, nil, []string{})
+	root, err := vfs.Root()
+	require.NoError(t, err)
+	checkListing(t, root, []string{"file1,5,false"})
+
+	// Modify it again and check it is only queued once
+	writeBackWriteFile(t, vfs, "file1", "hello world")
+	assert.Equal(t, 1, vfs.cache.writeBack.pending())
+	checkListing(t, root, []string{"file1,11,false"})
+
+	writeBackWait(t, vfs)
+	writeBackCheckRemote(t, r, []fstest.Item{fstest.NewItem("file1", "hello world", t1)}, []string{})
+	checkListing(t, root, []string{"file1,11,false"})
+}
+
+func TestWriteBackCancel(t *testing.T) {
+	r := fstest.NewRun(t)
+	vfs := writeBackNewVFS(t, r, time.Hour)
+	defer cleanup(t, r, vfs)
+
+	writeBackWriteFile(t, vfs, "file1", "hello")
+	assert.Equal(t, 1, vfs.cache.writeBack.pending())
+
+	assert.True(t, vfs.cache.writeBack.cancel("file1"))
+	assert.False(t, vfs.cache.writeBack.cancel("file1"))
+	assert.Equal(t, 0, vfs.cache.writeBack.pending())
+	assert.False(t, vfs.cache.get("file1").info.Dirty)
+	writeBackCheckRemote(t, r, nil, []string{})
+}
+
+func TestWriteBackRetry(t *testing.T) {
+	r := fstest.NewRun(t)
+	vfs := writeBackNewVFS(t, r, time.Hour)
+	defer cleanup(t, r, vfs)
+
+	writeBackWriteFile(t, vfs, "file1", "hello")
+	assert.False(t, vfs.cache.writeBack.retry("potato"))
+	assert.True(t, vfs.cache.writeBack.retry("file1"))
+
+	writeBackWait(t, vfs)
+	writeBackCheckRemote(t, r, []fstest.Item{fstest.NewItem("file1", "hello", t1)}, []string{})
+	assert.False(t, vfs.cache.get("file1").info.Dirty)
+}
+
+func TestWriteBackRestart(t *testing.T) {
+	r := fstest.NewRun(t)
+	vfs := writeBackNewVFS(t, r, time.Hour)
+
+	root, err := vfs.Root()
+	require.NoError(t, err)
+	_, err = root.Mkdir("dir")
+	require.NoError(t, err)
+	writeBackWriteFile(t, vfs, "dir/file1", "hello")
+	assert.Equal(t, 1, vfs.cache.writeBack.pending())
+	vfs.Shutdown()
+	writeBackCheckRemote(t, r, nil, []string{"dir"})
+
+	// Start again and check the file is queued for upload
+	vfs = writeBackNewVFS(t, r, time.Hour)
+	defer cleanup(t, r, vfs)
+	queue := vfs.cache.writeBack.list()
+	require.Equal(t, 1, len(queue))
+	assert.Equal(t, "dir/file1", queue[0].Name)
+
+	vfs.cache.writeBack.retry("")
+	writeBackWait(t, vfs)
+	writeBackCheckRemote(t, r, []fstest.Item{fstest.NewItem("dir/file1", "hello", t1)}, []string{"dir"})
+
+	node, err := vfs.Stat("dir/file1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), node.Size())
+}
@@ -0,0 +1,332 @@
+// Extended attributes and metadata of files and directories
+
+package vfs
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/pkg/errors"
+)
+
+const (
+	// xattrUserPrefix is the namespace of the extended attributes
+	// which are read from and written to the object metadata
+	xattrUserPrefix = "user."
+
+	// xattrRclonePrefix is the namespace of the read only
+	// extended attributes describing the object on the remote
+	xattrRclonePrefix = xattrUserPrefix + "rclone."
+)
+
+// xattrHashName returns the name of the extended attribute for ht,
+// eg "user.rclone.md5" for hash.MD5
+func xattrHashName(ht hash.Type) string {
+	return xattrRclonePrefix + strings.Replace(strings.ToLower(ht.String()), "-", "", -1)
+}
+
+// xattrID returns the name and value of the extended attribute for
+// the ID of entry if it has one
+func xattrID(entry fs.DirEntry) (name, value string) {
+	if do, ok := entry.(fs.IDer); ok {
+		value = do.ID()
+	}
+	return xattrRclonePrefix + "id", value
+}
+
+// xattrKey returns the metadata key to store the extended attribute
+// name in.
+//
+// It returns EPERM for the read only attributes and ENOSYS for those
+// which can't be stored.
+func xattrKey(opt *Options, name string) (key string, err error) {
+	if strings.HasPrefix(name, xattrRclonePrefix) {
+		return "", EPERM
+	}
+	if !opt.Metadata || !strings.HasPrefix(name, xattrUserPrefix) {
+		return "", ENOSYS
+	}
+	key = name[len(xattrUserPrefix):]
+	if key == "" || fs.IsSystemMetadata(key) {
+		return "", EPERM
+	}
+	return key, nil
+}
+
+// metadata returns the metadata of the object if --vfs-metadata is
+// in use, reading it from the remote the first time it is needed
+// for each object.
+//
+// It returns nil if there isn't any or it couldn't be read.
+func (f *File) metadata() fs.Metadata {
+	if !f.d.vfs.Opt.Metadata {
+		return nil
+	}
+	f.mu.Lock()
+	o, metadata, metaObject := f.o, f.meta, f.metaObject
+	f.mu.Unlock()
+	if o == nil {
+		return nil
+	}
+	if o == metaObject {
+		return metadata
+	}
+	ctx := context.TODO()
+	ro, err := resolveObject(ctx, o)
+	if err == nil {
+		metadata, err = fs.GetMetadata(ctx, ro)
+	}
+	if err != nil {
+		fs.Debugf(f, "Failed to read metadata: %v", err)
+		return nil
+	}
+	f.mu.Lock()
+	if f.o == o {
+		f.meta, f.metaObject = metadata, o
+	}
+	f.mu.Unlock()
+	return metadata
+}
+
+// setMetadata writes metadata to the object on the remote
+func (f *File) setMetadata(metadata fs.Metadata) error {
+	if f.d.vfs.Opt.ReadOnly {
+		return EROFS
+	}
+	o := f.getObject()
+	if o == nil {
+		fs.Debugf(f, "Can't set metadata until the file has been uploaded")
+		return ENOSYS
+	}
+	ctx := context.TODO()
+	o, err := resolveObject(ctx, o)
+	if err != nil {
+		return err
+	}
+	do, ok := o.(fs.SetMetadataer)
+	if !ok {
+		return ENOSYS
+	}
+	err = do.SetMetadata(ctx, metadata)
+	if errors.Cause(err) == fs.ErrorCantSetMetadata {
+		return ENOSYS
+	}
+	f.mu.Lock()
+	f.metaObject = nil
+	f.mu.Unlock()
+	return err
+}
+
+// Owner returns the user and group IDs of the file - satisfies Node
+// interface
+//
+// These come from the metadata if --vfs-metadata is in use and the
+// remote stores them, otherwise from --uid and --gid.
+func (f *File) Owner() (uid, gid uint32) {
+	uid, gid = f.d.vfs.Opt.UID, f.d.vfs.Opt.GID
+	if metadata := f.metadata(); metadata != nil {
+		if id, ok, err := fs.ParseMetadataID(metadata, fs.MetadataUID); err == nil && ok {
+			uid = id
+		}
+		if id, ok, err := fs.ParseMetadataID(metadata, fs.MetadataGID); err == nil && ok {
+			gid = id
+		}
+	}
+	return uid, gid
+}
+
+// Chmod changes the permissions of the file - satisfies Node
+// interface
+//
+// This is stored in the metadata if --vfs-metadata is in use,
+// otherwise it does nothing.
+func (f *File) Chmod(mode os.FileMode) error {
+	if !f.d.vfs.Opt.Metadata {
+		return nil
+	}
+	return f.setMetadata(fs.Metadata{fs.MetadataMode: fs.FormatMetadataMode(mode)})
+}
+
+// Chown changes the owner of the file - satisfies Node interface
+//
+// A uid or gid of -1 is left unchanged.  This is stored in the
+// metadata if --vfs-metadata is in use, otherwise it does nothing.
+func (f *File) Chown(uid, gid int) error {
+	if !f.d.vfs.Opt.Metadata {
+		return nil
+	}
+	metadata := fs.Metadata{}
+	if uid >= 0 {
+		metadata[fs.MetadataUID] = strconv.Itoa(uid)
+	}
+	if gid >= 0 {
+		metadata[fs.MetadataGID] = strconv.Itoa(gid)
+	}
+	if len(metadata) == 0 {
+		return nil
+	}
+	return f.setMetadata(metadata)
+}
+
+// ListXattr returns the names of the extended attributes of the file
+// - satisfies Node interface
+func (f *File) ListXattr() (names []string, err error) {
+	o := f.getObject()
+	if o == nil {
+		return nil, nil
+	}
+	ctx := context.TODO()
+	for _, ht := range f.d.vfs.f.Hashes().Array() {
+		names = append(names, xattrHashName(ht))
+	}
+	if do, ok := o.(fs.MimeTyper); ok && do.MimeType(ctx) != "" {
+		names = append(names, xattrRclonePrefix+"mimetype")
+	}
+	if do, ok := o.(fs.GetTierer); ok && do.GetTier() != "" {
+		names = append(names, xattrRclonePrefix+"tier")
+	}
+	if name, value := xattrID(o); value != "" {
+		names = append(names, name)
+	}
+	for k := range f.metadata() {
+		if !fs.IsSystemMetadata(k) && !strings.HasPrefix(xattrUserPrefix+k, xattrRclonePrefix) {
+			names = append(names, xattrUserPrefix+k)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// GetXattr returns the value of the extended attribute name of the
+// file - satisfies Node interface
+//
+// It returns ENOATTR if there isn't one.
+func (f *File) GetXattr(name string) (value string, err error) {
+	o := f.getObject()
+	if o == nil {
+		return "", ENOATTR
+	}
+	ctx := context.TODO()
+	if strings.HasPrefix(name, xattrRclonePrefix) {
+		switch name[len(xattrRclonePrefix):] {
+		case "mimetype":
+			if do, ok := o.(fs.MimeTyper); ok {
+				value = do.MimeType(ctx)
+			}
+		case "tier":
+			if do, ok := o.(fs.GetTierer); ok {
+				value = do.GetTier()
+			}
+		case "id":
+			_, value = xattrID(o)
+		default:
+			for _, ht := range f.d.vfs.f.Hashes().Array() {
+				if name == xattrHashName(ht) {
+					value, err = o.Hash(ctx, ht)
+					if err == hash.ErrUnsupported {
+						return "", ENOATTR
+					} else if err != nil {
+						return "", err
+					}
+					break
+				}
+			}
+		}
+	} else if strings.HasPrefix(name, xattrUserPrefix) {
+		key := name[len(xattrUserPrefix):]
+		if !fs.IsSystemMetadata(key) {
+			value = f.metadata()[key]
+		}
+	}
+	if value == "" {
+		return "", ENOATTR
+	}
+	return value, nil
+}
+
+// SetXattr sets the extended attribute name of the file to value -
+// satisfies Node interface
+//
+// This is stored in the metadata if --vfs-metadata is in use and the
+// remote supports it, otherwise it returns ENOSYS.
+func (f *File) SetXattr(name, value string) error {
+	key, err := xattrKey(&f.d.vfs.Opt, name)
+	if err != nil {
+		return err
+	}
+	return f.setMetadata(fs.Metadata{key: value})
+}
+
+// RemoveXattr removes the extended attribute name from the file -
+// satisfies Node interface
+//
+// Metadata can't be removed from an object so this always fails.
+func (f *File) RemoveXattr(name string) error {
+	if _, err := f.GetXattr(name); err != nil {
+		return err
+	}
+	_, err := xattrKey(&f.d.vfs.Opt, name)
+	if err != nil {
+		return err
+	}
+	return ENOSYS
+}
+
+// Owner returns the user and group IDs of the directory - satisfies
+// Node interface
+func (d *Dir) Owner() (uid, gid uint32) {
+	return d.vfs.Opt.UID, d.vfs.Opt.GID
+}
+
+// Chmod does nothing for directories - satisfies Node interface
+func (d *Dir) Chmod(mode os.FileMode) error {
+	return nil
+}
+
+// Chown does nothing for directories - satisfies Node interface
+func (d *Dir) Chown(uid, gid int) error {
+	return nil
+}
+
+// ListXattr returns the names of the extended attributes of the
+// directory - satisfies Node interface
+func (d *Dir) ListXattr() (names []string, err error) {
+	if name, value := xattrID(d.DirEntry()); value != "" {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// GetXattr returns the value of the extended attribute name of the
+// directory - satisfies Node interface
+//
+// It returns ENOATTR if there isn't one.
+func (d *Dir) GetXattr(name string) (value string, err error) {
+	if idName, value := xattrID(d.DirEntry()); name == idName && value != "" {
+		return value, nil
+	}
+	return "", ENOATTR
+}
+
+// SetXattr can't set extended attributes on directories - satisfies
+// Node interface
+func (d *Dir) SetXattr(name, value string) error {
+	if strings.HasPrefix(name, xattrRclonePrefix) {
+		return EPERM
+	}
+	return ENOSYS
+}
+
+// RemoveXattr can't remove extended attributes from directories -
+// satisfies Node interface
+func (d *Dir) RemoveXattr(name string) error {
+	if _, err := d.GetXattr(name); err != nil {
+		return err
+	}
+	return EPERM
+}
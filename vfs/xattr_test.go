@@ -0,0 +1,93 @@
+package vfs
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/ncw/rclone/fs"
+	"github.com/ncw/rclone/fs/hash"
+	"github.com/ncw/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXattrHashName(t *testing.T) {
+	assert.Equal(t, "user.rclone.md5", xattrHashName(hash.MD5))
+	assert.Equal(t, "user.rclone.sha1", xattrHashName(hash.SHA1))
+}
+
+func TestFileXattr(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	vfs, file, file1 := fileCreate(t, r)
+	ctx := context.Background()
+
+	names, err := file.ListXattr()
+	require.NoError(t, err)
+	for _, ht := range r.Fremote.Hashes().Array() {
+		name := xattrHashName(ht)
+		assert.Contains(t, names, name)
+		value, err := file.GetXattr(name)
+		require.NoError(t, err)
+		sum, err := file.getObject().Hash(ctx, ht)
+		require.NoError(t, err)
+		assert.Equal(t, sum, value)
+	}
+
+	_, err = file.GetXattr("user.rclone.potato")
+	assert.Equal(t, ENOATTR, err)
+	_, err = file.GetXattr("user.potato")
+	assert.Equal(t, ENOATTR, err)
+	assert.Equal(t, EPERM, file.SetXattr("user.rclone.md5", "potato"))
+	assert.Equal(t, ENOSYS, file.SetXattr("user.potato", "jersey"))
+
+	// Without --vfs-metadata chmod and chown are ignored
+	require.NoError(t, file.Chmod(0600))
+	assert.Equal(t, vfs.Opt.FilePerms, file.Mode())
+	uid, gid := file.Owner()
+	assert.Equal(t, vfs.Opt.UID, uid)
+	assert.Equal(t, vfs.Opt.GID, gid)
+
+	// With --vfs-metadata they are stored on remotes which can
+	if _, ok := file.getObject().(fs.SetMetadataer); !ok || runtime.GOOS == "windows" {
+		t.Skip("remote can't store permissions in metadata")
+	}
+	vfs.Opt.Metadata = true
+	require.NoError(t, file.Chmod(0640))
+	assert.Equal(t, os.FileMode(0640), file.Mode())
+	require.NoError(t, file.Chown(-1, -1))
+	assert.Equal(t, EPERM, file.SetXattr("user.mode", "777"))
+
+	err = file.SetXattr("user.potato", "jersey")
+	require.NoError(t, err)
+	value, err := file.GetXattr("user.potato")
+	if err == ENOATTR {
+		t.Log("remote can't store extended attributes")
+	} else {
+		require.NoError(t, err)
+		assert.Equal(t, "jersey", value)
+		names, err = file.ListXattr()
+		require.NoError(t, err)
+		assert.Contains(t, names, "user.potato")
+		assert.Equal(t, ENOSYS, file.RemoveXattr("user.potato"))
+	}
+	assert.Equal(t, ENOATTR, file.RemoveXattr("user.missing"))
+
+	// The contents are unchanged
+	fstest.CheckItems(t, r.Fremote, file1)
+}
+
+func TestDirXattr(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	vfs, dir, _ := dirCreate(t, r)
+
+	_, err := dir.GetXattr("user.potato")
+	assert.Equal(t, ENOATTR, err)
+	assert.Equal(t, EPERM, dir.SetXattr("user.rclone.id", "potato"))
+	assert.Equal(t, ENOSYS, dir.SetXattr("user.potato", "jersey"))
+	require.NoError(t, dir.Chmod(0700))
+	assert.Equal(t, vfs.Opt.DirPerms, dir.Mode())
+}